@@ -0,0 +1,92 @@
+package grsync
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ErrCommandTooLong is returned for commands exceeding the argument limit of the system whose
+// patterns can't be passed on the input of rsync, because it is used by Task.SetStdin or UsePty
+var ErrCommandTooLong = errors.New("command line exceeds the argument limit of the system, pass the patterns with ExcludeFrom")
+
+// maxCommandLength is the size of the arguments and the environment exec is expected to accept.
+// It is the smallest ARG_MAX of the supported systems, 256 KiB on older macOS and BSDs, as Go has
+// no portable way to ask. Windows limits the command line to 32767 characters
+var maxCommandLength = 256 * 1024
+
+// commandLength returns the size args and env take in the exec call, with a pointer for each
+func commandLength(args, env []string) int {
+	if runtime.GOOS == "windows" {
+		// quoting adds a few characters per argument, the environment is separate
+		n := 0
+		for _, arg := range args {
+			n += len(arg) + 3
+		}
+		return n
+	}
+	n := 0
+	for _, arg := range args {
+		n += len(arg) + 1 + 8
+	}
+	for _, variable := range env {
+		n += len(variable) + 1 + 8
+	}
+	return n
+}
+
+// fitArguments moves Include, Exclude and the rules of IgnoreFiles to the input of rsync, read
+// with --filter="merge -", if the command would exceed the argument limit otherwise. The rules
+// keep their order. Tasks have a single source, so long command lines are always made up of
+// patterns
+func fitArguments(options RsyncOptions, args []string) (RsyncOptions, error) {
+	env := processEnv(options)
+	if env == nil {
+		env = os.Environ()
+	}
+	limit := maxCommandLength
+	if runtime.GOOS == "windows" {
+		limit = 32767
+	}
+	if commandLength(args, env) <= limit {
+		return options, nil
+	}
+
+	rules := append([]string(nil), options.partitionRules...)
+	for _, pattern := range options.Include {
+		rules = append(rules, "+ "+pattern)
+	}
+	for _, pattern := range options.Exclude {
+		rules = append(rules, "- "+pattern)
+	}
+	// the rules of ignore files follow the ones between them and Exclude
+	moveIgnoreRules := options.IncludeFrom == "" && options.ExcludeFrom == "" && options.Filter == ""
+	if moveIgnoreRules {
+		rules = append(rules, options.ignoreRules...)
+	}
+	if len(rules) == 0 {
+		return options, nil
+	}
+	if options.stdin != nil || options.UsePty || usesStdin(options) {
+		return options, ErrCommandTooLong
+	}
+	for _, rule := range rules {
+		if strings.ContainsAny(rule, "\r\n") {
+			return options, ErrCommandTooLong
+		}
+	}
+
+	options.partitionRules, options.Include, options.Exclude = nil, nil, nil
+	if moveIgnoreRules {
+		options.ignoreRules = nil
+	}
+	options.stdinRules = rules
+	options.stdin = strings.NewReader(strings.Join(rules, "\n") + "\n")
+	return options, nil
+}
+
+// usesStdin reports whether options read anything else from the input of rsync
+func usesStdin(options RsyncOptions) bool {
+	return options.FilesFrom == "-" || options.ExcludeFrom == "-" || options.IncludeFrom == "-"
+}
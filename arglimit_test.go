@@ -0,0 +1,107 @@
+package grsync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func limitCommandLength(t *testing.T, length int) {
+	previous := maxCommandLength
+	maxCommandLength = length
+	t.Cleanup(func() { maxCommandLength = previous })
+}
+
+func manyPatterns(prefix string, n int) []string {
+	patterns := make([]string, n)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf("%s-%04d/*.tmp", prefix, i)
+	}
+	return patterns
+}
+
+func TestFitArguments(t *testing.T) {
+	limitCommandLength(t, len(strings.Join(os.Environ(), " "))+8*1024)
+	rsync, err := NewRsync("/data/", "/backup/", false, false, RsyncOptions{
+		Archive: true,
+		Include: []string{"keep/"},
+		Exclude: manyPatterns("cache", 500),
+		Filter:  "protect /backup/.snapshot",
+	})
+	assert.Nil(t, err)
+	args := rsync.command.Args
+	assert.Contains(t, args, "--filter=merge -")
+	assert.Contains(t, args, "--filter=protect /backup/.snapshot")
+	assert.Less(t, indexOf(args, "--filter=merge -"), indexOf(args, "--filter=protect /backup/.snapshot"))
+	for _, arg := range args {
+		assert.False(t, strings.HasPrefix(arg, "--exclude=") || strings.HasPrefix(arg, "--include="), arg)
+	}
+	assert.Equal(t, []string{"/data/", "/backup/"}, args[len(args)-2:])
+
+	input, err := io.ReadAll(rsync.command.Stdin)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(input), "\n"), "\n")
+	assert.Len(t, lines, 501)
+	assert.Equal(t, "+ keep/", lines[0])
+	assert.Equal(t, "- cache-0000/*.tmp", lines[1])
+
+	// short command lines are left alone
+	rsync, err = NewRsync("/data/", "/backup/", false, false, RsyncOptions{Exclude: []string{"*.tmp"}})
+	assert.Nil(t, err)
+	assert.Contains(t, rsync.command.Args, "--exclude=*.tmp")
+	assert.Nil(t, rsync.command.Stdin)
+}
+
+func TestFitArgumentsIgnoreRules(t *testing.T) {
+	limitCommandLength(t, len(strings.Join(os.Environ(), " "))+8*1024)
+	dir := t.TempDir()
+	ignore := filepath.Join(dir, ".backupignore")
+	assert.Nil(t, os.WriteFile(ignore, []byte(strings.Join(manyPatterns("build", 500), "\n")), 0644))
+
+	rsync, err := NewRsync(dir+"/", "/backup/", false, false, RsyncOptions{
+		Exclude:     []string{"*.log"},
+		IgnoreFiles: []string{ignore},
+	})
+	assert.Nil(t, err)
+	input, err := io.ReadAll(rsync.command.Stdin)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(input), "- *.log\n"))
+	assert.Contains(t, string(input), "build-0499")
+	for _, arg := range rsync.command.Args {
+		assert.NotContains(t, arg, "build-0")
+	}
+}
+
+func TestFitArgumentsStdinTaken(t *testing.T) {
+	limitCommandLength(t, len(strings.Join(os.Environ(), " "))+8*1024)
+	_, err := NewRsync("/data/", "/backup/", false, false, RsyncOptions{
+		Exclude:   manyPatterns("cache", 500),
+		FilesFrom: "-",
+	})
+	assert.ErrorIs(t, err, ErrCommandTooLong)
+}
+
+func TestFitArgumentsTask(t *testing.T) {
+	limitCommandLength(t, len(strings.Join(os.Environ(), " "))+8*1024)
+	task, err := NewTask("/data/", "/backup/", false, false, RsyncOptions{
+		RsyncBinaryPath: fakeRsync(t, "cat"),
+		Exclude:         manyPatterns("cache", 500),
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Contains(t, task.Log().Stdout, "- cache-0499/*.tmp")
+}
+
+func indexOf(args []string, arg string) int {
+	for i, a := range args {
+		if a == arg {
+			return i
+		}
+	}
+	return -1
+}
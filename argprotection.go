@@ -0,0 +1,29 @@
+package grsync
+
+import "fmt"
+
+// ArgProtection selects how the file names of remote paths reach the remote rsync. rsync 3.2.4
+// changed the default: names are no longer split on spaces or expanded by the remote shell, which
+// breaks paths relying on it, e.g. with older servers or `host:'dir/*.log'` quoted for the shell
+type ArgProtection string
+
+const (
+	// ArgsDefault keeps the behavior of the local rsync
+	ArgsDefault ArgProtection = ""
+	// ArgsOld passes the names through the remote shell as before rsync 3.2.4, which splits them on
+	// spaces and expands wildcards. It sets RSYNC_OLD_ARGS=1, which older clients ignore as they
+	// behave like this anyway
+	ArgsOld ArgProtection = "old"
+	// ArgsSecluded sends the names over the rsync protocol instead of the remote shell command
+	// line, --protect-args, which rsync 3.3 calls --secluded-args. Both sides need rsync 3.0
+	ArgsSecluded ArgProtection = "secluded"
+)
+
+// checkArgProtection rejects unknown values of ArgProtection
+func checkArgProtection(options RsyncOptions) error {
+	switch options.ArgProtection {
+	case ArgsDefault, ArgsOld, ArgsSecluded:
+		return nil
+	}
+	return fmt.Errorf("unknown argument protection %q", options.ArgProtection)
+}
@@ -0,0 +1,26 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgProtection(t *testing.T) {
+	assert.Contains(t, getArguments(RsyncOptions{ArgProtection: ArgsSecluded}), "--protect-args")
+	assert.NotContains(t, getArguments(RsyncOptions{ArgProtection: ArgsOld}), "--protect-args")
+
+	assert.Equal(t, []string{"RSYNC_OLD_ARGS=1"}, processEnv(RsyncOptions{Locale: InheritLocale, ClearEnv: true, ArgProtection: ArgsOld}))
+	// Env takes precedence
+	assert.Equal(t, []string{"LC_ALL=C", "LANG=C", "RSYNC_OLD_ARGS=1", "RSYNC_OLD_ARGS=2"},
+		processEnv(RsyncOptions{ClearEnv: true, ArgProtection: ArgsOld, Env: []string{"RSYNC_OLD_ARGS=2"}}))
+
+	_, err := NewTask("host:dir/*.log", "b", false, false, RsyncOptions{ArgProtection: "quoted"})
+	assert.EqualError(t, err, `unknown argument protection "quoted"`)
+
+	options := RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "$RSYNC_OLD_ARGS"`), ArgProtection: ArgsOld}
+	task, err := NewTask("host:dir/*.log", "b", false, false, options)
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "1\n", task.Log().Stdout)
+}
@@ -0,0 +1,67 @@
+package grsync
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces the secrets of command lines
+const redacted = "***"
+
+// sshpassPattern matches the password of a sshpass command embedded in a single argument, e.g. a
+// remote shell or the command line of script
+var sshpassPattern = regexp.MustCompile(`(sshpass'?(?:\s+'?-[a-oq-zA-Z]\S*)*\s+'?-p'?\s*)('[^']*'|"[^"]*"|[^\s']+)`)
+
+// Command returns the command line of the rsync process, its path followed by its arguments, with
+// the passwords passed to sshpass and the secrets of its environment redacted
+func (r Rsync) Command() []string {
+	return redactCommand(r.command)
+}
+
+// Command returns the command line of the rsync process of the current or last attempt, e.g. for
+// audit logs. Before the first run it is the command the run would start with. Secrets are
+// redacted, see Rsync.Command
+func (t *Task) Command() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.rsync == nil {
+		return nil
+	}
+	return t.rsync.Command()
+}
+
+// redactArgv returns a copy of argv with the passwords passed to sshpass replaced by redacted
+func redactArgv(argv []string) []string {
+	argv, _ = sshpassPasswords(argv)
+	return argv
+}
+
+// sshpassPasswords returns a copy of argv with the passwords passed to sshpass replaced by
+// redacted, and the passwords
+func sshpassPasswords(argv []string) (redactedArgv, passwords []string) {
+	argv = append([]string(nil), argv...)
+	sshpass := false
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		switch {
+		case path.Base(arg) == "sshpass":
+			sshpass = true
+		case sshpass && arg == "-p" && i+1 < len(argv):
+			i++
+			passwords = append(passwords, argv[i])
+			argv[i] = redacted
+			sshpass = false
+		case sshpass && strings.HasPrefix(arg, "-p"):
+			passwords = append(passwords, arg[2:])
+			argv[i] = "-p" + redacted
+			sshpass = false
+		case strings.Contains(arg, "sshpass"):
+			for _, match := range sshpassPattern.FindAllStringSubmatch(arg, -1) {
+				passwords = append(passwords, strings.Trim(match[2], `'"`))
+			}
+			argv[i] = sshpassPattern.ReplaceAllString(arg, "${1}"+redacted)
+		}
+	}
+	return argv, passwords
+}
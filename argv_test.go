@@ -0,0 +1,48 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactArgv(t *testing.T) {
+	for _, test := range []struct {
+		argv, expected []string
+	}{
+		{[]string{"rsync", "-a", "a", "b"}, []string{"rsync", "-a", "a", "b"}},
+		{[]string{"/usr/bin/sshpass", "-p", "secret", "rsync", "-p", "a"}, []string{"/usr/bin/sshpass", "-p", "***", "rsync", "-p", "a"}},
+		{[]string{"nice", "-n", "10", "sshpass", "-psecret", "rsync"}, []string{"nice", "-n", "10", "sshpass", "-p***", "rsync"}},
+		{[]string{"rsync", "--rsh", "sshpass -p secret ssh", "a"}, []string{"rsync", "--rsh", "sshpass -p *** ssh", "a"}},
+		{[]string{"script", "-c", `'/usr/bin/sshpass' '-p' 'secret' 'rsync'`}, []string{"script", "-c", `'/usr/bin/sshpass' '-p' *** 'rsync'`}},
+		{[]string{"rsync", "-e", "sshpass -e ssh"}, []string{"rsync", "-e", "sshpass -e ssh"}},
+	} {
+		assert.Equal(t, test.expected, redactArgv(test.argv), test.argv)
+	}
+}
+
+func TestTaskCommand(t *testing.T) {
+	binary := fakeRsync(t, "exit 0")
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: binary, Archive: true})
+	assert.Nil(t, err)
+	command := task.Command()
+	assert.Equal(t, binary, command[0])
+	assert.Contains(t, command, "--archive")
+	assert.Equal(t, []string{"a", "b"}, command[len(command)-2:])
+
+	assert.Nil(t, task.Run())
+	assert.Equal(t, command, task.Command())
+}
+
+func TestTaskCommandSshPass(t *testing.T) {
+	password := filepath.Join(t.TempDir(), "password")
+	assert.Nil(t, os.WriteFile(password, []byte("secret\n"), 0o600))
+
+	task, err := NewTask("a", "host:b", true, false, RsyncOptions{PasswordFile: password})
+	assert.Nil(t, err)
+	command := task.Command()
+	assert.Equal(t, []string{"/usr/bin/sshpass", "-p", "***"}, command[:3])
+	assert.NotContains(t, command, "secret")
+}
@@ -0,0 +1,35 @@
+package grsync
+
+import (
+	"errors"
+)
+
+// ErrEmptyArgv is returned if an ArgvHook returns no command line
+var ErrEmptyArgv = errors.New("argv hook returned an empty command line")
+
+// ArgvHook rewrites the command line of rsync right before it starts, its path followed by its
+// arguments, e.g. to wrap it in `timeout` or `stdbuf` or to add arguments RsyncOptions don't
+// cover. argv is the final command line, including the wrappers for UseSshPass, UsePty, Priority
+// and Limits, and may be modified
+type ArgvHook func(argv []string) []string
+
+// SetArgvHook rewrites the command line of the rsync command with hook, Command returns the
+// rewritten one. It must be called before Start
+func (r *Rsync) SetArgvHook(hook ArgvHook) error {
+	argv := hook(append([]string{r.command.Name}, r.command.Args...))
+	if len(argv) == 0 {
+		return ErrEmptyArgv
+	}
+	r.command.Name, r.command.Args = argv[0], argv[1:]
+	r.process = r.runner.NewProcess(r.command)
+	return nil
+}
+
+// SetArgvHook makes every attempt of the task rewrite the command line of rsync with hook, see
+// ArgvHook. Task.Command and the secrets redacted by the task reflect the rewritten command line.
+// nil removes the hook
+func (t *Task) SetArgvHook(hook ArgvHook) {
+	t.mutex.Lock()
+	t.argvHook = hook
+	t.mutex.Unlock()
+}
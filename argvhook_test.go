@@ -0,0 +1,42 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskArgvHook(t *testing.T) {
+	binary := fakeRsync(t, `echo "$GRSYNC_HOOKED $*"`)
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: binary})
+	assert.Nil(t, err)
+	task.SetArgvHook(func(argv []string) []string {
+		argv = append([]string{"env", "GRSYNC_HOOKED=yes"}, argv...)
+		// move the source and destination behind an added argument
+		n := len(argv)
+		return append(append(argv[:n-2:n-2], "--fake-super"), argv[n-2:]...)
+	})
+	assert.Nil(t, task.Run())
+	assert.Regexp(t, `^yes .* --fake-super a b\n$`, task.Log().Stdout)
+
+	command := task.Command()
+	assert.Equal(t, []string{"env", "GRSYNC_HOOKED=yes", binary}, command[:3])
+	assert.Equal(t, []string{"--fake-super", "a", "b"}, command[len(command)-3:])
+
+	task.SetArgvHook(func([]string) []string { return nil })
+	assert.Equal(t, ErrEmptyArgv, task.Run())
+
+	task.SetArgvHook(nil)
+	assert.Nil(t, task.Run())
+	assert.Regexp(t, `^ .* a b\n$`, task.Log().Stdout)
+}
+
+func TestRsyncArgvHook(t *testing.T) {
+	rsync, err := NewRsync("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+	assert.Nil(t, err)
+	assert.Nil(t, rsync.SetArgvHook(func(argv []string) []string {
+		return append([]string{"env", "GRSYNC_HOOKED=yes"}, argv...)
+	}))
+	assert.Equal(t, []string{"env", "GRSYNC_HOOKED=yes"}, rsync.Command()[:2])
+	assert.Nil(t, rsync.Run())
+}
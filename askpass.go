@@ -0,0 +1,130 @@
+package grsync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ErrAskPassUnsupported is returned for tasks with an AskPassFunc on Windows
+var ErrAskPassUnsupported = errors.New("askpass callbacks are not supported on windows")
+
+// AskPassFunc answers a prompt of ssh, e.g. "user@host's password: " or "Enter passphrase for key
+// '/home/user/.ssh/id_ed25519': ". An error makes ssh treat the prompt as cancelled
+type AskPassFunc func(prompt string) (string, error)
+
+// askPassScript is the SSH_ASKPASS program. It hands the prompt to the task through files in
+// GRSYNC_ASKPASS_DIR and prints the answer, the directory disappears once the run finished
+const askPassScript = `#!/bin/sh
+dir="$GRSYNC_ASKPASS_DIR"
+id=$$
+printf '%s' "$1" > "$dir/$id.tmp" && mv "$dir/$id.tmp" "$dir/$id.prompt" || exit 1
+while [ ! -e "$dir/$id.answer" ] && [ ! -e "$dir/$id.denied" ]; do
+	[ -d "$dir" ] || exit 1
+	sleep 0.05
+done
+[ -e "$dir/$id.answer" ] || exit 1
+cat "$dir/$id.answer"
+rm -f "$dir/$id.answer"
+`
+
+// askPassPoll is how often the task looks for prompts
+const askPassPoll = 20 * time.Millisecond
+
+// SetAskPass makes ssh ask fn for passwords and key passphrases instead of a terminal, so they
+// don't need to be stored in a PasswordFile. The prompts are passed through SSH_ASKPASS, which
+// needs OpenSSH 8.4 or newer for SSH_ASKPASS_REQUIRE. The answers are handed to ssh through a
+// private temporary directory
+func (t *Task) SetAskPass(fn AskPassFunc) {
+	t.mutex.Lock()
+	t.askPass = fn
+	t.mutex.Unlock()
+}
+
+// startAskPass creates the directory of the prompts of the run if the task has an AskPassFunc
+// and answers them until the returned function is called
+func (t *Task) startAskPass() (stop func(), err error) {
+	t.mutex.Lock()
+	fn := t.askPass
+	t.mutex.Unlock()
+	if fn == nil {
+		return func() {}, nil
+	}
+	if runtime.GOOS == "windows" {
+		return nil, ErrAskPassUnsupported
+	}
+
+	dir, err := os.MkdirTemp("", "grsync-askpass-")
+	if err != nil {
+		return nil, err
+	}
+	script := filepath.Join(dir, "askpass")
+	if err = os.WriteFile(script, []byte(askPassScript), 0700); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	t.askPassEnv = []string{
+		"SSH_ASKPASS=" + script,
+		"SSH_ASKPASS_REQUIRE=force",
+		"GRSYNC_ASKPASS_DIR=" + dir,
+	}
+	if os.Getenv("DISPLAY") == "" {
+		// ssh before 8.4 only uses SSH_ASKPASS with a display
+		t.askPassEnv = append(t.askPassEnv, "DISPLAY=grsync:0")
+	}
+	t.mutex.Unlock()
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		answerPrompts(dir, fn, done)
+		close(stopped)
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		t.mutex.Lock()
+		t.askPassEnv = nil
+		t.mutex.Unlock()
+		_ = os.RemoveAll(dir)
+	}, nil
+}
+
+// answerPrompts answers the prompts written to dir by askPassScript until done is closed
+func answerPrompts(dir string, fn AskPassFunc, done chan struct{}) {
+	ticker := time.NewTicker(askPassPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		prompts, _ := filepath.Glob(filepath.Join(dir, "*.prompt"))
+		for _, path := range prompts {
+			prompt, err := os.ReadFile(path)
+			_ = os.Remove(path)
+			if err != nil {
+				continue
+			}
+			id := strings.TrimSuffix(path, ".prompt")
+			answer, err := fn(string(prompt))
+			if err != nil {
+				_ = os.WriteFile(id+".denied", nil, 0600)
+				continue
+			}
+			// the answer must appear complete, the script may check for it any time
+			if err = os.WriteFile(id+".part", []byte(answer+"\n"), 0600); err == nil {
+				err = os.Rename(id+".part", id+".answer")
+			}
+			if err != nil {
+				_ = os.WriteFile(id+".denied", nil, 0600)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package grsync
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAskPass(t *testing.T) {
+	t.Run("answers prompts", func(t *testing.T) {
+		script := `echo "answer: $("$SSH_ASKPASS" "user@host's password: ")"
+echo "require: $SSH_ASKPASS_REQUIRE"`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		var prompts []string
+		task.SetAskPass(func(prompt string) (string, error) {
+			prompts = append(prompts, prompt)
+			return "secret", nil
+		})
+
+		assert.Nil(t, task.Run())
+		assert.Equal(t, []string{"user@host's password: "}, prompts)
+		assert.Contains(t, task.Log().Stdout, "answer: secret\n")
+		assert.Contains(t, task.Log().Stdout, "require: force\n")
+	})
+
+	t.Run("denies prompts", func(t *testing.T) {
+		script := `"$SSH_ASKPASS" "Enter passphrase: " || echo "denied"`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetAskPass(func(string) (string, error) { return "", errors.New("no") })
+
+		assert.Nil(t, task.Run())
+		assert.Contains(t, task.Log().Stdout, "denied")
+	})
+
+	t.Run("removes the directory", func(t *testing.T) {
+		script := `echo "$GRSYNC_ASKPASS_DIR"`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetAskPass(func(string) (string, error) { return "", nil })
+
+		assert.Nil(t, task.Run())
+		dir := strings.TrimSpace(task.Log().Stdout)
+		assert.NotEmpty(t, dir)
+		_, err = os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
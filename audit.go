@@ -0,0 +1,256 @@
+package grsync
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AuditKind is the kind of an AuditDiscrepancy
+type AuditKind string
+
+const (
+	// AuditMissing is an entry of the source missing on the destination
+	AuditMissing AuditKind = "missing"
+	// AuditExtra is an entry of the destination missing in the source
+	AuditExtra AuditKind = "extra"
+	// AuditType is an entry which is of another type on the destination, e.g. a directory instead
+	// of a file, or a symlink with another target
+	AuditType AuditKind = "type"
+	// AuditSize is a file of another size on the destination
+	AuditSize AuditKind = "size"
+	// AuditModTime is a file with another modification time on the destination
+	AuditModTime AuditKind = "modTime"
+	// AuditChecksum is a file of the same size whose content differs on the destination
+	AuditChecksum AuditKind = "checksum"
+)
+
+// AuditOptions configure Audit
+type AuditOptions struct {
+	// Sample is the number of files of the same size on both sides whose checksums are compared,
+	// picked at random. Zero compares all of them
+	Sample int
+	// Secret signs the report with HMAC-SHA256, see AuditReport.Verify. Reports aren't signed
+	// without one
+	Secret string
+}
+
+// AuditEntry is an entry of a side of an AuditDiscrepancy
+type AuditEntry struct {
+	Mode       string    `json:"mode"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"modTime"`
+	LinkTarget string    `json:"linkTarget,omitempty"`
+}
+
+// AuditDiscrepancy is a difference between the source and the destination found by Audit.
+// Source or Dest is nil if the entry is missing on that side
+type AuditDiscrepancy struct {
+	Path   string      `json:"path"`
+	Kind   AuditKind   `json:"kind"`
+	Source *AuditEntry `json:"source,omitempty"`
+	Dest   *AuditEntry `json:"dest,omitempty"`
+}
+
+// AuditReport is the result of Audit. Discrepancies are sorted by path
+type AuditReport struct {
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	// SourceEntries and DestEntries are the numbers of entries listed on both sides
+	SourceEntries int `json:"sourceEntries"`
+	DestEntries   int `json:"destEntries"`
+	// Checksummed is the number of files whose checksums were compared
+	Checksummed   int                `json:"checksummed"`
+	Discrepancies []AuditDiscrepancy `json:"discrepancies"`
+	// Signature is the hex encoded HMAC-SHA256 of the report without it, empty if it isn't
+	// signed
+	Signature string `json:"signature,omitempty"`
+}
+
+// Clean reports whether the audit found no discrepancies
+func (r AuditReport) Clean() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// Verify reports whether the report is signed with secret and unchanged since, e.g. after it was
+// stored as JSON
+func (r AuditReport) Verify(secret string) bool {
+	if r.Signature == "" {
+		return false
+	}
+	signature, err := r.sign(secret)
+	return err == nil && hmac.Equal([]byte(signature), []byte(r.Signature))
+}
+
+// sign returns the signature of the report with secret
+func (r AuditReport) sign(secret string) (string, error) {
+	r.Signature = ""
+	body, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return Sign(secret, body), nil
+}
+
+// Audit verifies that destination still mirrors source without changing either: it lists both
+// sides recursively, compares their entries by type, size and modification time, and compares
+// the checksums of a sample of the files of the same size with a dry run of rsync reading them
+// with --files-from. Both sides may be remote, like for ParallelTask the contents of the
+// directories are compared. Modification times are compared to the second and within rsyncOptions.ModifyWindow,
+// the filters of rsyncOptions apply to both sides
+func Audit(source, destination string, options AuditOptions, rsyncOptions RsyncOptions) (AuditReport, error) {
+	if !strings.HasSuffix(source, "/") {
+		source += "/"
+	}
+	if !strings.HasSuffix(destination, "/") {
+		destination += "/"
+	}
+	report := AuditReport{Source: source, Destination: destination, StartedAt: time.Now(), Discrepancies: []AuditDiscrepancy{}}
+
+	var sourceEntries, destEntries map[string]ListEntry
+	var destErr error
+	listed := make(chan struct{})
+	go func() {
+		defer close(listed)
+		destEntries, destErr = listEntries(destination, rsyncOptions)
+	}()
+	sourceEntries, err := listEntries(source, rsyncOptions)
+	<-listed
+	if err != nil {
+		return AuditReport{}, err
+	}
+	if destErr != nil {
+		return AuditReport{}, destErr
+	}
+	report.SourceEntries, report.DestEntries = len(sourceEntries), len(destEntries)
+
+	var candidates []string
+	for path, s := range sourceEntries {
+		d, ok := destEntries[path]
+		switch {
+		case !ok:
+			report.add(path, AuditMissing, &s, nil)
+		case s.Mode[0] != d.Mode[0] || s.LinkTarget != d.LinkTarget:
+			report.add(path, AuditType, &s, &d)
+		case s.Mode[0] != '-':
+		case s.Size != d.Size:
+			report.add(path, AuditSize, &s, &d)
+		default:
+			if diff := s.ModTime.Sub(d.ModTime).Abs(); diff > time.Duration(rsyncOptions.ModifyWindow)*time.Second {
+				report.add(path, AuditModTime, &s, &d)
+			}
+			if !strings.ContainsAny(path, "\r\n") {
+				// such names can't be listed with --files-from
+				candidates = append(candidates, path)
+			}
+		}
+	}
+	for path, d := range destEntries {
+		if _, ok := sourceEntries[path]; !ok {
+			report.add(path, AuditExtra, nil, &d)
+		}
+	}
+
+	if options.Sample > 0 && options.Sample < len(candidates) {
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		candidates = candidates[:options.Sample]
+	}
+	sort.Strings(candidates)
+	differing, err := checksumDiffers(source, destination, candidates, rsyncOptions)
+	if err != nil {
+		return AuditReport{}, err
+	}
+	report.Checksummed = len(candidates)
+	for _, path := range differing {
+		s, d := sourceEntries[path], destEntries[path]
+		report.add(path, AuditChecksum, &s, &d)
+	}
+
+	sort.SliceStable(report.Discrepancies, func(i, j int) bool {
+		return report.Discrepancies[i].Path < report.Discrepancies[j].Path
+	})
+	report.FinishedAt = time.Now()
+	if options.Secret != "" {
+		if report.Signature, err = report.sign(options.Secret); err != nil {
+			return AuditReport{}, err
+		}
+	}
+	return report, nil
+}
+
+// add adds a discrepancy of the entries source and dest, either may be nil
+func (r *AuditReport) add(path string, kind AuditKind, source, dest *ListEntry) {
+	r.Discrepancies = append(r.Discrepancies, AuditDiscrepancy{
+		Path:   path,
+		Kind:   kind,
+		Source: auditEntry(source),
+		Dest:   auditEntry(dest),
+	})
+}
+
+func auditEntry(entry *ListEntry) *AuditEntry {
+	if entry == nil {
+		return nil
+	}
+	return &AuditEntry{Mode: entry.Mode, Size: entry.Size, ModTime: entry.ModTime, LinkTarget: entry.LinkTarget}
+}
+
+// listEntries lists path recursively by name, without the directory itself. Other than
+// ListRemote it lists without --human-readable, so sizes are exact
+func listEntries(path string, options RsyncOptions) (map[string]ListEntry, error) {
+	cmd, err := listCommand(path, options)
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	entries := make(map[string]ListEntry)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if entry, ok := parseListLine(scanner.Text(), 1000); ok && entry.Name != "." {
+			entries[entry.Name] = entry
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// checksumDiffers returns the names whose content differs between source and destination, as
+// found by a dry run of rsync comparing their checksums
+func checksumDiffers(source, destination string, names []string, options RsyncOptions) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	options.DryRun = true
+	options.Comparison = CompareChecksum
+	options.SizeOnly, options.IgnoreTimes = false, false
+	options.Delete = false
+	options.FilesFrom = "-"
+
+	task, err := NewTask(source, destination, false, false, options)
+	if err != nil {
+		return nil, err
+	}
+	task.DiscardLog()
+	task.SetStdin(strings.NewReader(strings.Join(names, "\n") + "\n"))
+	var diff Diff
+	task.OnFileEvent(func(event FileEvent) {
+		diff.add(event)
+	})
+	if err = task.Run(); err != nil {
+		return nil, err
+	}
+	return diff.ContentDiffers, nil
+}
@@ -0,0 +1,95 @@
+package grsync
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const auditScript = `case "$*" in
+*"--list-only"*"src/")
+	echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 ."
+	echo "-rw-r--r--             10 2023/01/02 10:11:12 same.txt"
+	echo "-rw-r--r--             20 2023/01/02 10:11:12 changed.txt"
+	echo "-rw-r--r--             30 2023/01/02 10:11:12 grown.txt"
+	echo "-rw-r--r--             40 2023/01/02 10:11:12 touched.txt"
+	echo "-rw-r--r--             50 2023/01/02 10:11:12 missing.txt"
+	echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 dir"
+	echo "lrwxrwxrwx              1 2023/01/02 10:11:12 link -> a";;
+*"--list-only"*)
+	echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 ."
+	echo "-rw-r--r--             10 2023/01/02 10:11:12 same.txt"
+	echo "-rw-r--r--             20 2023/01/02 10:11:12 changed.txt"
+	echo "-rw-r--r--             31 2023/01/02 10:11:12 grown.txt"
+	echo "-rw-r--r--             40 2023/01/02 10:11:20 touched.txt"
+	echo "-rw-r--r--              1 2023/01/02 10:11:12 dir"
+	echo "lrwxrwxrwx              1 2023/01/02 10:11:12 link -> b"
+	echo "-rw-r--r--             60 2023/01/02 10:11:12 extra.txt";;
+*)
+	for option in --dry-run --checksum --files-from=-; do
+		case " $* " in *" $option "*) ;; *) exit 1;; esac
+	done
+	names=$(cat)
+	case "$names" in *changed.txt*) echo "` + fileEventPrefix + `>fc........ 20 changed.txt";; esac;;
+esac`
+
+func TestAudit(t *testing.T) {
+	binary := fakeRsync(t, auditScript)
+	report, err := Audit("src", "dest", AuditOptions{Secret: "secret"}, RsyncOptions{RsyncBinaryPath: binary})
+	assert.Nil(t, err)
+	assert.Equal(t, "src/", report.Source)
+	assert.Equal(t, 7, report.SourceEntries)
+	assert.Equal(t, 7, report.DestEntries)
+	assert.Equal(t, 3, report.Checksummed)
+	assert.False(t, report.Clean())
+
+	type kindOf struct {
+		Path string
+		Kind AuditKind
+	}
+	var kinds []kindOf
+	for _, discrepancy := range report.Discrepancies {
+		kinds = append(kinds, kindOf{discrepancy.Path, discrepancy.Kind})
+	}
+	assert.Equal(t, []kindOf{
+		{"changed.txt", AuditChecksum},
+		{"dir", AuditType},
+		{"extra.txt", AuditExtra},
+		{"grown.txt", AuditSize},
+		{"link", AuditType},
+		{"missing.txt", AuditMissing},
+		{"touched.txt", AuditModTime},
+	}, kinds)
+	assert.Nil(t, report.Discrepancies[2].Source)
+	assert.Equal(t, int64(60), report.Discrepancies[2].Dest.Size)
+	assert.Nil(t, report.Discrepancies[5].Dest)
+	assert.Equal(t, time.Date(2023, 1, 2, 10, 11, 20, 0, time.Local), report.Discrepancies[6].Dest.ModTime)
+
+	data, err := json.Marshal(report)
+	assert.Nil(t, err)
+	var stored AuditReport
+	assert.Nil(t, json.Unmarshal(data, &stored))
+	assert.True(t, stored.Verify("secret"))
+	assert.False(t, stored.Verify("other"))
+	stored.Discrepancies = stored.Discrepancies[1:]
+	assert.False(t, stored.Verify("secret"))
+}
+
+func TestAuditSample(t *testing.T) {
+	binary := fakeRsync(t, auditScript)
+	report, err := Audit("src/", "dest/", AuditOptions{Sample: 1}, RsyncOptions{RsyncBinaryPath: binary, ModifyWindow: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Checksummed)
+	assert.Empty(t, report.Signature)
+	assert.False(t, report.Verify(""))
+	for _, discrepancy := range report.Discrepancies {
+		assert.NotEqual(t, AuditModTime, discrepancy.Kind)
+	}
+}
+
+func TestAuditListError(t *testing.T) {
+	_, err := Audit("src", "dest", AuditOptions{}, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "connection refused" >&2; exit 10`)})
+	assert.ErrorContains(t, err, "connection refused")
+}
@@ -0,0 +1,79 @@
+package grsync
+
+// SyncBackend transfers, lists and estimates files between a source and a destination, so
+// applications can reach destinations rsync can't, like object stores, through the same calls.
+// RsyncBackend runs tasks and is what the rest of the package uses, RcloneBackend shells out to
+// rclone
+type SyncBackend interface {
+	// Transfer syncs source to destination and returns the record of the run. Its error is the
+	// one of the failed run, the result is filled as far as the run got
+	Transfer(source, destination string) (Result, error)
+	// List lists path recursively and calls entry for every entry, names relative to path
+	List(path string, entry func(ListEntry)) error
+	// Estimate returns what Transfer would do without changing anything
+	Estimate(source, destination string) (Estimate, error)
+}
+
+// Estimate is what a transfer would do, see SyncBackend.Estimate
+type Estimate struct {
+	// Files is the number of files which would be transferred
+	Files int `json:"files"`
+	// Bytes is the size of these files
+	Bytes int64 `json:"bytes"`
+	// Deletions is the number of files which would be deleted on the destination
+	Deletions int `json:"deletions"`
+}
+
+// RsyncBackend is the SyncBackend running rsync tasks with its options
+type RsyncBackend struct {
+	Options RsyncOptions
+	Config  TaskConfig
+	// Setup is called with every task, e.g. to set a logger or a runner
+	Setup func(task *Task)
+}
+
+// Transfer runs a task syncing source to destination
+func (b RsyncBackend) Transfer(source, destination string) (Result, error) {
+	task, err := b.newTask(source, destination, b.Options)
+	if err != nil {
+		return Result{}, err
+	}
+	return task.RunResult()
+}
+
+// List lists path with ListRemote
+func (b RsyncBackend) List(path string, entry func(ListEntry)) error {
+	return ListRemote(path, b.Options, entry)
+}
+
+// Estimate runs the transfer as a dry run with --stats
+func (b RsyncBackend) Estimate(source, destination string) (Estimate, error) {
+	options := b.Options
+	options.DryRun = true
+	options.Stats = true
+	task, err := b.newTask(source, destination, options)
+	if err != nil {
+		return Estimate{}, err
+	}
+	task.DiscardLog()
+	if err = task.Run(); err != nil {
+		return Estimate{}, err
+	}
+	stats := task.Stats()
+	return Estimate{
+		Files:     stats.RegularFilesTransferred,
+		Bytes:     stats.TotalTransferredSize,
+		Deletions: stats.DeletedFiles,
+	}, nil
+}
+
+func (b RsyncBackend) newTask(source, destination string, options RsyncOptions) (*Task, error) {
+	task, err := NewTaskWithConfig(source, destination, false, false, options, b.Config)
+	if err != nil {
+		return nil, err
+	}
+	if b.Setup != nil {
+		b.Setup(task)
+	}
+	return task, nil
+}
@@ -0,0 +1,41 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRsyncBackend(t *testing.T) {
+	script := `case "$*" in
+*--dry-run*)
+	echo "Number of deleted files: 2"
+	echo "Number of regular files transferred: 3"
+	echo "Total transferred file size: 3,072 bytes"
+	;;
+*--list-only*)
+	echo "drwxr-xr-x          4,096 2024/01/02 03:04:05 ."
+	echo "-rw-r--r--          1,024 2024/01/02 03:04:05 a.txt"
+	;;
+*)
+	echo "sent 1,024 bytes  received 35 bytes  2,118.00 bytes/sec"
+	echo "total size is 1,024  speedup is 0.97"
+	;;
+esac`
+	var backend SyncBackend = RsyncBackend{
+		Options: RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Archive: true},
+		Setup:   func(task *Task) { task.SetLabels(map[string]string{"backend": "rsync"}) },
+	}
+
+	result, err := backend.Transfer("/data/", "/backup/")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), result.Summary.BytesSent)
+
+	estimate, err := backend.Estimate("/data/", "/backup/")
+	assert.Nil(t, err)
+	assert.Equal(t, Estimate{Files: 3, Bytes: 3072, Deletions: 2}, estimate)
+
+	var names []string
+	assert.Nil(t, backend.List("/backup/", func(entry ListEntry) { names = append(names, entry.Name) }))
+	assert.Equal(t, []string{".", "a.txt"}, names)
+}
@@ -0,0 +1,203 @@
+package grsync
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// BackpressurePolicy is what a task does when a consumer of its output falls behind, see
+// Backpressure
+type BackpressurePolicy string
+
+const (
+	// BackpressureBlock waits for the consumer once its buffer is full. rsync stalls as soon as
+	// the pipes fill up, so nothing is lost. It is the default
+	BackpressureBlock BackpressurePolicy = "block"
+	// BackpressureDropOldest discards the oldest buffered item to make room for a new one, so the
+	// consumer sees the latest output
+	BackpressureDropOldest BackpressurePolicy = "dropOldest"
+	// BackpressureDropNewest discards new items while the buffer is full
+	BackpressureDropNewest BackpressurePolicy = "dropNewest"
+)
+
+// defaultBackpressureBuffer is the buffer of the channels and writers without Backpressure.Buffer
+const defaultBackpressureBuffer = 64
+
+// Backpressure configures how the output of rsync is passed to slow consumers: the channels of
+// FileEvents and Errors and the writers of SetStdoutWriter and SetStderrWriter. Callbacks are
+// always invoked synchronously, they must return quickly
+type Backpressure struct {
+	Policy BackpressurePolicy `json:"policy,omitempty"`
+	// Buffer is the number of items buffered per consumer, file events or lines for the channels
+	// and writes for the writers, 64 if zero. Writers are only buffered by the drop policies
+	Buffer int `json:"buffer,omitempty"`
+}
+
+// buffer returns the buffer size of b
+func (b Backpressure) buffer() int {
+	if b.Buffer > 0 {
+		return b.Buffer
+	}
+	return defaultBackpressureBuffer
+}
+
+// drops reports whether b discards items instead of blocking
+func (b Backpressure) drops() bool {
+	return b.Policy == BackpressureDropOldest || b.Policy == BackpressureDropNewest
+}
+
+// ConsumerStats count what a consumer of the output received
+type ConsumerStats struct {
+	// Delivered is the number of items passed to the consumer
+	Delivered int64 `json:"delivered"`
+	// Dropped is the number of items discarded because the consumer fell behind
+	Dropped int64 `json:"dropped"`
+	// Blocked is the time the task waited for the consumer
+	Blocked time.Duration `json:"blocked"`
+}
+
+// BackpressureStats are the ConsumerStats of the consumers of a task since it was created
+type BackpressureStats struct {
+	FileEvents   ConsumerStats `json:"fileEvents"`
+	Errors       ConsumerStats `json:"errors"`
+	StdoutWriter ConsumerStats `json:"stdoutWriter"`
+	StderrWriter ConsumerStats `json:"stderrWriter"`
+}
+
+// consumerCounters are the ConsumerStats of a consumer, updated while the task runs
+type consumerCounters struct {
+	delivered atomic.Int64
+	dropped   atomic.Int64
+	blocked   atomic.Int64
+}
+
+func (c *consumerCounters) stats() ConsumerStats {
+	return ConsumerStats{
+		Delivered: c.delivered.Load(),
+		Dropped:   c.dropped.Load(),
+		Blocked:   time.Duration(c.blocked.Load()),
+	}
+}
+
+// backpressureCounters are the counters of the consumers of a task
+type backpressureCounters struct {
+	fileEvents   consumerCounters
+	errors       consumerCounters
+	stdoutWriter consumerCounters
+	stderrWriter consumerCounters
+}
+
+// SetBackpressure sets how slow consumers are handled. It applies to the channels returned by
+// FileEvents and Errors afterwards and to the writers from the next Run on
+func (t *Task) SetBackpressure(backpressure Backpressure) {
+	t.mutex.Lock()
+	t.backpressure = backpressure
+	t.mutex.Unlock()
+}
+
+// BackpressureStats returns what the consumers of the output received
+func (t *Task) BackpressureStats() BackpressureStats {
+	return BackpressureStats{
+		FileEvents:   t.backpressureCounters.fileEvents.stats(),
+		Errors:       t.backpressureCounters.errors.stats(),
+		StdoutWriter: t.backpressureCounters.stdoutWriter.stats(),
+		StderrWriter: t.backpressureCounters.stderrWriter.stats(),
+	}
+}
+
+// send sends item on ch according to policy. A channel may have several senders, e.g. the readers
+// of stdout and stderr both send errors and UsePty merges both into one reader, so the room a
+// dropped item makes may be taken by another sender. The send is retried until it succeeds or
+// the item itself is dropped, the dropping policies never block
+func send[T any](ch chan T, item T, policy BackpressurePolicy, counters *consumerCounters) {
+	for {
+		select {
+		case ch <- item:
+			counters.delivered.Add(1)
+			return
+		default:
+		}
+
+		switch policy {
+		case BackpressureDropNewest:
+			counters.dropped.Add(1)
+			return
+		case BackpressureDropOldest:
+			select {
+			case <-ch:
+				counters.delivered.Add(-1)
+				counters.dropped.Add(1)
+			default:
+			}
+		default:
+			start := time.Now()
+			ch <- item
+			counters.blocked.Add(int64(time.Since(start)))
+			counters.delivered.Add(1)
+			return
+		}
+	}
+}
+
+// consumerWriter returns w passing the writes on according to backpressure. The returned close
+// waits until the buffered writes are written
+func consumerWriter(w io.Writer, backpressure Backpressure, counters *consumerCounters) (io.Writer, func()) {
+	if w == nil {
+		return nil, func() {}
+	}
+	if !backpressure.drops() {
+		return &blockingWriter{w: w, counters: counters}, func() {}
+	}
+
+	q := &queuedWriter{
+		w:        &tolerantWriter{w: w},
+		policy:   backpressure.Policy,
+		counters: counters,
+		queue:    make(chan []byte, backpressure.buffer()),
+		done:     make(chan struct{}),
+	}
+	go q.writeLoop()
+	return q, q.close
+}
+
+// blockingWriter counts the writes to w and the time they take
+type blockingWriter struct {
+	w        io.Writer
+	counters *consumerCounters
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := w.w.Write(p)
+	w.counters.blocked.Add(int64(time.Since(start)))
+	w.counters.delivered.Add(1)
+	return n, err
+}
+
+// queuedWriter writes to w in the background, dropping writes while it falls behind
+type queuedWriter struct {
+	w        io.Writer
+	policy   BackpressurePolicy
+	counters *consumerCounters
+	queue    chan []byte
+	done     chan struct{}
+}
+
+func (w *queuedWriter) Write(p []byte) (int, error) {
+	// the reader reuses p
+	send(w.queue, append([]byte(nil), p...), w.policy, w.counters)
+	return len(p), nil
+}
+
+func (w *queuedWriter) writeLoop() {
+	defer close(w.done)
+	for p := range w.queue {
+		_, _ = w.w.Write(p)
+	}
+}
+
+func (w *queuedWriter) close() {
+	close(w.queue)
+	<-w.done
+}
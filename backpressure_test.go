@@ -0,0 +1,134 @@
+package grsync
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackpressureFileEvents(t *testing.T) {
+	script := `for i in 1 2 3 4 5; do echo "` + fileEventPrefix + `>f+++++++++ 10 file$i"; done
+for i in 1 2 3; do echo "warning $i" >&2; done`
+
+	for _, c := range []struct {
+		policy BackpressurePolicy
+		paths  []string
+	}{
+		{BackpressureDropNewest, []string{"file1", "file2"}},
+		{BackpressureDropOldest, []string{"file4", "file5"}},
+	} {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetBackpressure(Backpressure{Policy: c.policy, Buffer: 2})
+		events := task.FileEvents()
+		errors := task.Errors()
+		// nobody receives while the task runs
+		assert.Nil(t, task.Run())
+
+		var paths []string
+		for event := range events {
+			paths = append(paths, event.Path)
+		}
+		assert.Equal(t, c.paths, paths, c.policy)
+		assert.Len(t, errors, 2)
+
+		stats := task.BackpressureStats()
+		assert.Equal(t, int64(2), stats.FileEvents.Delivered, c.policy)
+		assert.Equal(t, int64(3), stats.FileEvents.Dropped, c.policy)
+		assert.Equal(t, int64(2), stats.Errors.Delivered, c.policy)
+		assert.Equal(t, int64(1), stats.Errors.Dropped, c.policy)
+	}
+}
+
+func TestBackpressureConcurrentSenders(t *testing.T) {
+	for _, policy := range []BackpressurePolicy{BackpressureDropNewest, BackpressureDropOldest} {
+		ch := make(chan int, 2)
+		var counters consumerCounters
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					send(ch, i, policy, &counters)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		stats := counters.stats()
+		assert.Equal(t, int64(2), stats.Delivered, policy)
+		assert.Equal(t, int64(798), stats.Dropped, policy)
+		assert.Len(t, ch, 2, policy)
+	}
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo out; echo err >&2")})
+	assert.Nil(t, err)
+	var stdout bytes.Buffer
+	task.SetStdoutWriter(&stdout)
+	errors := task.Errors()
+	done := make(chan []string)
+	go func() {
+		var lines []string
+		for line := range errors {
+			lines = append(lines, line)
+		}
+		done <- lines
+	}()
+	assert.Nil(t, task.Run())
+	assert.Equal(t, []string{"err"}, <-done)
+	assert.Equal(t, "out\n", stdout.String())
+
+	stats := task.BackpressureStats()
+	assert.Equal(t, ConsumerStats{Delivered: 1, Blocked: stats.Errors.Blocked}, stats.Errors)
+	assert.Equal(t, int64(1), stats.StdoutWriter.Delivered)
+	assert.Equal(t, int64(0), stats.StdoutWriter.Dropped)
+}
+
+// gatedWriter blocks every write until gate is closed
+type gatedWriter struct {
+	gate chan struct{}
+	buf  bytes.Buffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	return w.buf.Write(p)
+}
+
+func TestBackpressureWriter(t *testing.T) {
+	for _, c := range []struct {
+		policy  BackpressurePolicy
+		written string
+	}{
+		{BackpressureDropNewest, "ab"},
+		{BackpressureDropOldest, "ad"},
+	} {
+		w := &gatedWriter{gate: make(chan struct{})}
+		var counters consumerCounters
+		writer, closeWriter := consumerWriter(w, Backpressure{Policy: c.policy, Buffer: 1}, &counters)
+
+		_, _ = writer.Write([]byte("a"))
+		// wait for the writer to block on a
+		assert.Eventually(t, func() bool { return len(writer.(*queuedWriter).queue) == 0 }, time.Second, time.Millisecond)
+		for _, p := range []string{"b", "c", "d"} {
+			n, err := writer.Write([]byte(p))
+			assert.Nil(t, err)
+			assert.Equal(t, 1, n)
+		}
+		close(w.gate)
+		closeWriter()
+
+		assert.Equal(t, c.written, w.buf.String(), c.policy)
+		assert.Equal(t, ConsumerStats{Delivered: 2, Dropped: 2}, counters.stats(), c.policy)
+	}
+
+	writer, closeWriter := consumerWriter(nil, Backpressure{Policy: BackpressureDropOldest}, &consumerCounters{})
+	assert.Nil(t, writer)
+	closeWriter()
+}
@@ -0,0 +1,114 @@
+package grsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Bandwidth is a bandwidth limit in bytes per second, see RsyncOptions.Bandwidth. In JSON it is
+// a number of bytes per second or a string parsed by ParseBandwidth
+type Bandwidth int64
+
+// bandwidthUnits are the multiplier letters of --bwlimit and their power of the unit
+var bandwidthUnits = map[byte]float64{'k': 1, 'm': 2, 'g': 3, 't': 4, 'p': 5}
+
+// ParseBandwidth parses a limit in the syntax of --bwlimit, e.g. "2.5m" or "800K": a possibly
+// fractional number with a suffix in units of 1024 like "K", "M" or "GiB", or of 1000 like "KB"
+// or "MB", case-insensitively. A number without suffix is in units of 1024 bytes like for rsync,
+// "B" stands for bytes. rsync's offset suffixes "+1" and "-1" and a trailing "/s" are accepted
+func ParseBandwidth(s string) (Bandwidth, error) {
+	value := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "/s")
+	offset := 0.0
+	if strings.HasSuffix(value, "+1") {
+		value, offset = value[:len(value)-2], 1
+	} else if strings.HasSuffix(value, "-1") {
+		value, offset = value[:len(value)-2], -1
+	}
+
+	i := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(value)
+	}
+	number, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q", s)
+	}
+
+	multiplier := 1024.0
+	if suffix := value[i:]; suffix != "" && suffix != "b" {
+		power, ok := bandwidthUnits[suffix[0]]
+		switch {
+		case !ok:
+			return 0, fmt.Errorf("invalid bandwidth %q", s)
+		case suffix[1:] == "" || suffix[1:] == "ib":
+			multiplier = math.Pow(1024, power)
+		case suffix[1:] == "b":
+			multiplier = math.Pow(1000, power)
+		default:
+			return 0, fmt.Errorf("invalid bandwidth %q", s)
+		}
+	} else if suffix == "b" {
+		multiplier = 1
+	}
+
+	bandwidth := math.Round(number*multiplier) + offset
+	if bandwidth < 0 || bandwidth > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid bandwidth %q", s)
+	}
+	return Bandwidth(bandwidth), nil
+}
+
+// String returns the limit as the exact argument of --bwlimit, e.g. "5M" or "2441.40625K"
+func (b Bandwidth) String() string {
+	if b == 0 {
+		return "0"
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   Bandwidth
+	}{{"G", 1 << 30}, {"M", 1 << 20}} {
+		if b%unit.size == 0 {
+			return strconv.FormatInt(int64(b/unit.size), 10) + unit.suffix
+		}
+	}
+	// fractions of 1024 have a finite decimal representation
+	return strconv.FormatFloat(float64(b)/1024, 'f', -1, 64) + "K"
+}
+
+// KiB returns the limit in the unit of RsyncOptions.BandwidthLimit, rounded but at least 1 for
+// a limit
+func (b Bandwidth) KiB() int {
+	if b <= 0 {
+		return 0
+	}
+	return max(int(math.Round(float64(b)/1024)), 1)
+}
+
+// UnmarshalJSON reads a number of bytes per second or a string parsed by ParseBandwidth
+func (b *Bandwidth) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		bandwidth, err := ParseBandwidth(s)
+		if err != nil {
+			return err
+		}
+		*b = bandwidth
+		return nil
+	}
+	var bytesPerSecond int64
+	if err := json.Unmarshal(data, &bytesPerSecond); err != nil {
+		return err
+	}
+	if bytesPerSecond < 0 {
+		return fmt.Errorf("invalid bandwidth %d", bytesPerSecond)
+	}
+	*b = Bandwidth(bytesPerSecond)
+	return nil
+}
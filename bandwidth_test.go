@@ -0,0 +1,86 @@
+package grsync
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	for s, expected := range map[string]Bandwidth{
+		"800":      800 * 1024,
+		"800k":     800 * 1024,
+		"800K":     800 * 1024,
+		"2.5m":     2621440,
+		"2.5MiB":   2621440,
+		"2.5MB":    2500000,
+		"1g":       1 << 30,
+		"1kb":      1000,
+		"512b":     512,
+		"1m+1":     1<<20 + 1,
+		"1m-1":     1<<20 - 1,
+		" 10MB/s ": 10000000,
+		"0":        0,
+	} {
+		bandwidth, err := ParseBandwidth(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, bandwidth, s)
+	}
+
+	for _, s := range []string{"", "fast", "-1", "1x", "1kx", "1mibs", "k"} {
+		_, err := ParseBandwidth(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestBandwidthString(t *testing.T) {
+	assert.Equal(t, "5M", Bandwidth(5<<20).String())
+	assert.Equal(t, "2G", Bandwidth(2<<30).String())
+	assert.Equal(t, "2560K", Bandwidth(2621440).String())
+	assert.Equal(t, "2441.40625K", Bandwidth(2500000).String())
+	assert.Equal(t, "0", Bandwidth(0).String())
+
+	for _, bandwidth := range []Bandwidth{1, 1000, 2500000, 5 << 20, 123456789} {
+		parsed, err := ParseBandwidth(bandwidth.String())
+		assert.Nil(t, err)
+		assert.Equal(t, bandwidth, parsed)
+	}
+
+	assert.Equal(t, 0, Bandwidth(0).KiB())
+	assert.Equal(t, 1, Bandwidth(100).KiB())
+	assert.Equal(t, 2441, Bandwidth(2500000).KiB())
+}
+
+func TestBandwidthJSON(t *testing.T) {
+	var options RsyncOptions
+	assert.Nil(t, json.Unmarshal([]byte(`{"Bandwidth": "2.5m"}`), &options))
+	assert.Equal(t, Bandwidth(2621440), options.Bandwidth)
+	assert.Nil(t, json.Unmarshal([]byte(`{"Bandwidth": 1000000}`), &options))
+	assert.Equal(t, Bandwidth(1000000), options.Bandwidth)
+	assert.NotNil(t, json.Unmarshal([]byte(`{"Bandwidth": "fast"}`), &options))
+	assert.NotNil(t, json.Unmarshal([]byte(`{"Bandwidth": -1}`), &options))
+
+	data, err := json.Marshal(RsyncOptions{Bandwidth: 1000})
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"Bandwidth":1000`)
+}
+
+func TestBandwidthArguments(t *testing.T) {
+	assert.Contains(t, getArguments(RsyncOptions{Bandwidth: 2500000, BandwidthLimit: 100}), "2441.40625K")
+	assert.NotContains(t, getArguments(RsyncOptions{Bandwidth: 2500000, BandwidthLimit: 100}), "100")
+	assert.Equal(t, RsyncOptions{BandwidthLimit: 2441}, legacyOptions(RsyncOptions{Bandwidth: 2500000}))
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	options := task.scheduleBandwidth(RsyncOptions{Bandwidth: 2500000})
+	assert.Equal(t, Bandwidth(2500000), options.Bandwidth)
+	assert.Equal(t, 2441, task.BandwidthLimit())
+
+	// a window replaces the limit
+	task.SetBandwidthSchedule(BandwidthSchedule{{Start: 0, End: 24 * time.Hour, Limit: 100}})
+	options = task.scheduleBandwidth(RsyncOptions{Bandwidth: 2500000})
+	assert.Equal(t, Bandwidth(0), options.Bandwidth)
+	assert.Equal(t, 100, options.BandwidthLimit)
+}
@@ -0,0 +1,281 @@
+package grsync
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrBatchedCancelled is returned by BatchedTask.Run if it was cancelled before the batches started
+	ErrBatchedCancelled = errors.New("batched task was cancelled")
+	// ErrBatchSkipped is the error of the batches a BatchedTask didn't transfer, because it was
+	// cancelled or StopOnError applied
+	ErrBatchSkipped = errors.New("batch was skipped")
+)
+
+// BatchedOptions configure a BatchedTask
+type BatchedOptions struct {
+	// BatchSize is the number of entries per rsync process, 100000 if zero
+	BatchSize int
+	// Concurrency is the number of batches transferred at the same time, 1 if zero
+	Concurrency int
+	// StopOnError skips the batches not started yet once one failed
+	StopOnError bool
+}
+
+// BatchResult is the outcome of one batch of a BatchedTask
+type BatchResult struct {
+	// Entries is the number of files, directories and links of the batch
+	Entries int    `json:"entries"`
+	Done    bool   `json:"done"`
+	Result  Result `json:"result"`
+	// Skipped is set if the batch wasn't transferred, see ErrBatchSkipped
+	Skipped bool `json:"skipped,omitempty"`
+	// Error is empty if the batch succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// BatchedTask syncs enormous file trees, whose file lists would take rsync too much memory. It
+// lists the source first and transfers the entries in batches of BatchSize, each with its own
+// rsync process reading the batch with --files-from. Like ParallelTask it syncs the contents of
+// the source directory. rsync doesn't recurse into the listed directories, so deletions on the
+// destination aren't found. The batch lists are staged in os.TempDir
+type BatchedTask struct {
+	source      string
+	destination string
+	options     BatchedOptions
+	rsync       RsyncOptions
+
+	mutex     sync.Mutex
+	tasks     []*Task
+	results   []BatchResult
+	errs      []error
+	cancelled bool
+	// failed is set once a batch failed with StopOnError
+	failed bool
+}
+
+// NewBatchedTask returns a task syncing the contents of source into destination in batches
+func NewBatchedTask(source, destination string, options BatchedOptions, rsyncOptions RsyncOptions) (*BatchedTask, error) {
+	if rsyncOptions.FilesFrom != "" {
+		return nil, errors.New("batched tasks write their own files-from lists")
+	}
+	if options.BatchSize < 1 {
+		options.BatchSize = 100000
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+	if !strings.HasSuffix(source, "/") {
+		source += "/"
+	}
+	return &BatchedTask{source: source, destination: destination, options: options, rsync: rsyncOptions}, nil
+}
+
+// Tasks returns the tasks of the batches, in their order. They are created by Run once the source
+// is listed
+func (b *BatchedTask) Tasks() []*Task {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]*Task(nil), b.tasks...)
+}
+
+// State combines the states of the batches
+func (b *BatchedTask) State() State {
+	return combineStates(b.Tasks(), 0)
+}
+
+// Batches returns the outcome of every batch of the current or last run, in their order. Batches
+// not finished yet aren't Done
+func (b *BatchedTask) Batches() []BatchResult {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]BatchResult(nil), b.results...)
+}
+
+// Cancel stops the running batches and skips the others
+func (b *BatchedTask) Cancel() error {
+	b.mutex.Lock()
+	b.cancelled = true
+	tasks := b.tasks
+	b.mutex.Unlock()
+	return cancelTasks(tasks)
+}
+
+// Run lists the source, writes the batches and transfers them. The errors of all failed batches
+// are joined, each prefixed with its number
+func (b *BatchedTask) Run() error {
+	staging, err := os.MkdirTemp("", "grsync-batches-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	lists, entries, err := b.list(staging)
+	if err != nil {
+		return err
+	}
+
+	tasks := make([]*Task, len(lists))
+	for i, list := range lists {
+		options := b.rsync
+		options.FilesFrom = list
+		if tasks[i], err = NewTask(b.source, b.destination, false, false, options); err != nil {
+			return err
+		}
+	}
+
+	b.mutex.Lock()
+	if b.cancelled {
+		b.mutex.Unlock()
+		return ErrBatchedCancelled
+	}
+	b.tasks = tasks
+	b.results = make([]BatchResult, len(tasks))
+	b.errs = make([]error, len(tasks))
+	b.failed = false
+	for i := range b.results {
+		b.results[i].Entries = entries[i]
+	}
+	b.mutex.Unlock()
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < b.options.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if b.stopped() {
+					b.skip(i)
+				} else {
+					b.transfer(i)
+				}
+			}
+		}()
+	}
+	for i := range tasks {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return errors.Join(b.errs...)
+}
+
+// list lists the source into files-from lists of at most BatchSize entries in dir and returns
+// their paths and number of entries. The listing is streamed, the entries aren't kept in memory
+func (b *BatchedTask) list(dir string) (lists []string, entries []int, err error) {
+	cmd, err := listCommand(b.source, b.rsync)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	var batch *bufio.Writer
+	var file *os.File
+	closeBatch := func() error {
+		if file == nil {
+			return nil
+		}
+		err := batch.Flush()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		file = nil
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && err == nil {
+		match := listPattern.FindStringSubmatch(scanner.Text())
+		if match == nil || match[4] == "." {
+			continue
+		}
+		name := match[4]
+		if match[1][0] == 'l' {
+			name = strings.SplitN(name, " -> ", 2)[0]
+		}
+		if name = unescapeName(name); strings.ContainsAny(name, "\r\n") {
+			err = fmt.Errorf("%q can't be listed with --files-from", name)
+			break
+		}
+
+		if file == nil || entries[len(entries)-1] == b.options.BatchSize {
+			if err = closeBatch(); err != nil {
+				break
+			}
+			list := filepath.Join(dir, fmt.Sprintf("batch%06d", len(lists)))
+			if file, err = os.Create(list); err != nil {
+				break
+			}
+			batch = bufio.NewWriter(file)
+			lists, entries = append(lists, list), append(entries, 0)
+		}
+		_, err = batch.WriteString(name + "\n")
+		entries[len(entries)-1]++
+	}
+	if closeErr := closeBatch(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = scanner.Err()
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+	}
+	if waitErr := cmd.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("list %s: %w: %s", b.source, waitErr, strings.TrimSpace(stderr.String()))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return lists, entries, nil
+}
+
+// transfer runs the task of batch i and records its result
+func (b *BatchedTask) transfer(i int) {
+	result, err := b.tasks[i].RunResult()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.results[i].Result = result
+	b.results[i].Done = true
+	if err != nil {
+		b.results[i].Error = err.Error()
+		b.errs[i] = fmt.Errorf("batch %d: %w", i+1, err)
+		b.failed = b.options.StopOnError
+	}
+}
+
+// skip records that batch i wasn't transferred
+func (b *BatchedTask) skip(i int) {
+	b.mutex.Lock()
+	b.results[i].Skipped = true
+	b.results[i].Error = ErrBatchSkipped.Error()
+	b.errs[i] = fmt.Errorf("batch %d: %w", i+1, ErrBatchSkipped)
+	b.mutex.Unlock()
+}
+
+// stopped reports whether batches not started yet are skipped
+func (b *BatchedTask) stopped() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.cancelled || b.failed
+}
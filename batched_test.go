@@ -0,0 +1,80 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchedTask(t *testing.T) {
+	runs := t.TempDir()
+	script := `case "$*" in
+*--list-only*) echo "` + parallelListing + `" ;;
+*) for arg; do case "$arg" in --files-from=*) list="${arg#--files-from=}";; esac; done
+   cp "$list" "$(mktemp ` + runs + `/batch.XXXXXX)"
+   i=0
+   while read -r name; do
+     i=$((i+1))
+     echo "          10 100%    1.00kB/s    0:00:00 (xfr#$i, to-chk=0/1)"
+   done < "$list" ;;
+esac`
+
+	task, err := NewBatchedTask("src", "dst", BatchedOptions{BatchSize: 2, Concurrency: 2}, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Len(t, task.Tasks(), 3)
+	assert.Equal(t, 6, task.State().FilesTransferred)
+
+	var entries []int
+	for _, batch := range task.Batches() {
+		assert.True(t, batch.Done)
+		assert.Empty(t, batch.Error)
+		entries = append(entries, batch.Entries)
+	}
+	assert.Equal(t, []int{2, 2, 2}, entries)
+
+	files, err := filepath.Glob(filepath.Join(runs, "batch.*"))
+	assert.Nil(t, err)
+	var names []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		names = append(names, strings.Fields(string(content))...)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"big", "big/file", "link", "mid*", "small", "small/a"}, names)
+}
+
+func TestBatchedTaskStopOnError(t *testing.T) {
+	script := `case "$*" in
+*--list-only*) echo "` + parallelListing + `" ;;
+*) exit 23 ;;
+esac`
+	task, err := NewBatchedTask("src/", "dst", BatchedOptions{BatchSize: 4, StopOnError: true}, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+
+	err = task.Run()
+	assert.Equal(t, ExitPartial, ExitCodeOf(err))
+	assert.ErrorIs(t, err, ErrBatchSkipped)
+	batches := task.Batches()
+	assert.Len(t, batches, 2)
+	assert.True(t, batches[0].Done)
+	assert.Equal(t, ExitPartial, batches[0].Result.ExitCode)
+	assert.True(t, batches[1].Skipped)
+}
+
+func TestBatchedTaskListError(t *testing.T) {
+	task, err := NewBatchedTask("src", "dst", BatchedOptions{}, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo 'rsync: change_dir failed' >&2; exit 23")})
+	assert.Nil(t, err)
+	err = task.Run()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "change_dir failed")
+	assert.Empty(t, task.Tasks())
+
+	_, err = NewBatchedTask("src", "dst", BatchedOptions{}, RsyncOptions{FilesFrom: "list"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,453 @@
+package grsync
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrBisyncStateDir is returned by NewBisyncTask without BisyncOptions.StateDir
+	ErrBisyncStateDir = errors.New("bisync tasks need a state directory")
+	// ErrBisyncEmptySide is returned by BisyncTask.Run if an endpoint which had entries in the last
+	// run is empty, which usually means it isn't mounted. Syncing would empty the other endpoint
+	ErrBisyncEmptySide = errors.New("bisync endpoint is unexpectedly empty")
+	// ErrBisyncCancelled is returned by BisyncTask.Run if it was cancelled
+	ErrBisyncCancelled = errors.New("bisync task was cancelled")
+)
+
+// DefaultConflictSuffix is appended to the version of endpoint B which ResolveBoth keeps
+const DefaultConflictSuffix = ".conflict"
+
+// BisyncEntry is a file or directory in the listing of a bisync endpoint. Directories are compared
+// by existence only
+type BisyncEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Dir     bool      `json:"dir,omitempty"`
+}
+
+// Conflict is a path that changed on both endpoints since the last run
+type Conflict struct {
+	Path string
+	// A and B are the entries of the path on the endpoints, nil if it was deleted there
+	A, B *BisyncEntry
+}
+
+// Resolution is the outcome of a conflict
+type Resolution int
+
+const (
+	// ResolveA replaces the version of endpoint B with the one of A
+	ResolveA Resolution = iota
+	// ResolveB replaces the version of endpoint A with the one of B
+	ResolveB
+	// ResolveBoth keeps the version of A under the path and the version of B under the path with
+	// BisyncOptions.ConflictSuffix on both endpoints. Conflicts involving a deletion or a directory
+	// keep the existing entry, or the one of A if both exist
+	ResolveBoth
+	// ResolveSkip leaves both versions alone, the path is a conflict again in the next run
+	ResolveSkip
+)
+
+// ConflictPolicy decides how a conflict is resolved
+type ConflictPolicy func(Conflict) Resolution
+
+// NewerWins resolves a conflict in favor of the version modified last, A if both were modified at
+// the same time. A modified version always wins over a deletion
+func NewerWins(c Conflict) Resolution {
+	switch {
+	case c.A == nil:
+		return ResolveB
+	case c.B == nil:
+		return ResolveA
+	case c.B.ModTime.After(c.A.ModTime):
+		return ResolveB
+	default:
+		return ResolveA
+	}
+}
+
+// KeepBoth resolves every conflict with ResolveBoth
+func KeepBoth(Conflict) Resolution {
+	return ResolveBoth
+}
+
+// BisyncOptions configure a BisyncTask
+type BisyncOptions struct {
+	// StateDir stores the listings of both endpoints after each run, which tell deletions apart
+	// from new files. It is required and must be private to the pair of endpoints
+	StateDir string
+	// Conflicts resolves paths changed on both endpoints, by default NewerWins
+	Conflicts ConflictPolicy
+	// ConflictSuffix is appended to the version of B kept by ResolveBoth, by default DefaultConflictSuffix
+	ConflictSuffix string
+}
+
+// BisyncTask synchronizes two directories in both directions. Changes are detected by comparing
+// the listings of both endpoints with the ones saved by the last run: paths changed on one side
+// are copied to or deleted on the other one, paths changed on both sides are conflicts. The first
+// run has no saved listings, so differing paths existing on both endpoints are conflicts
+type BisyncTask struct {
+	a, b         string
+	options      BisyncOptions
+	rsyncOptions RsyncOptions
+	stateA       string
+	stateB       string
+
+	mutex     sync.Mutex
+	tasks     []*Task
+	cancelled bool
+}
+
+// NewBisyncTask returns a task synchronizing the contents of the directories a and b, either of
+// which can be remote. rsyncOptions apply to the transfers, which always preserve modification times
+func NewBisyncTask(a, b string, options BisyncOptions, rsyncOptions RsyncOptions) (*BisyncTask, error) {
+	if options.StateDir == "" {
+		return nil, ErrBisyncStateDir
+	}
+	if options.Conflicts == nil {
+		options.Conflicts = NewerWins
+	}
+	if options.ConflictSuffix == "" {
+		options.ConflictSuffix = DefaultConflictSuffix
+	}
+	if !strings.HasSuffix(a, "/") {
+		a += "/"
+	}
+	if !strings.HasSuffix(b, "/") {
+		b += "/"
+	}
+
+	sum := sha256.Sum256([]byte(a + "\x00" + b))
+	name := "bisync-" + hex.EncodeToString(sum[:8])
+	return &BisyncTask{
+		a:            a,
+		b:            b,
+		options:      options,
+		rsyncOptions: rsyncOptions,
+		stateA:       filepath.Join(options.StateDir, name+"-a.json"),
+		stateB:       filepath.Join(options.StateDir, name+"-b.json"),
+	}, nil
+}
+
+// Tasks returns the tasks of the rsync processes started by the current or last run
+func (s *BisyncTask) Tasks() []*Task {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Task(nil), s.tasks...)
+}
+
+// State combines the states of the rsync processes
+func (s *BisyncTask) State() State {
+	return combineStates(s.Tasks(), 0)
+}
+
+// Cancel stops the rsync processes and the run
+func (s *BisyncTask) Cancel() error {
+	s.mutex.Lock()
+	s.cancelled = true
+	tasks := s.tasks
+	s.mutex.Unlock()
+	return cancelTasks(tasks)
+}
+
+// Run lists both endpoints, propagates the changes since the last run and saves the new listings.
+// The listings are only saved if all transfers succeeded, a failed run is repeated by the next one
+func (s *BisyncTask) Run() error {
+	s.mutex.Lock()
+	s.tasks = nil
+	s.mutex.Unlock()
+
+	previousA, err := loadBisyncState(s.stateA)
+	if err != nil {
+		return err
+	}
+	previousB, err := loadBisyncState(s.stateB)
+	if err != nil {
+		return err
+	}
+	currentA, err := s.list(s.a)
+	if err != nil {
+		return err
+	}
+	currentB, err := s.list(s.b)
+	if err != nil {
+		return err
+	}
+	if (len(currentA) == 0 && len(previousA) > 0) || (len(currentB) == 0 && len(previousB) > 0) {
+		return ErrBisyncEmptySide
+	}
+
+	plan := planBisync(currentA, currentB, previousA, previousB, s.options.Conflicts)
+
+	for _, path := range plan.keepBoth {
+		if err = s.transfer(s.b+path, s.a+path+s.options.ConflictSuffix, RsyncOptions{}); err != nil {
+			return err
+		}
+		plan.toB = append(plan.toB, path+s.options.ConflictSuffix)
+	}
+	if err = s.transferList(s.b, s.a, plan.toA); err != nil {
+		return err
+	}
+	if err = s.transferList(s.a, s.b, plan.toB); err != nil {
+		return err
+	}
+	if s.rsyncOptions.DryRun {
+		return nil
+	}
+
+	if currentA, err = s.list(s.a); err != nil {
+		return err
+	}
+	if currentB, err = s.list(s.b); err != nil {
+		return err
+	}
+	// skipped conflicts keep their previous entries to be detected again
+	for _, path := range plan.skipped {
+		restoreEntry(currentA, previousA, path)
+		restoreEntry(currentB, previousB, path)
+	}
+	if err = saveBisyncState(s.stateA, currentA); err != nil {
+		return err
+	}
+	return saveBisyncState(s.stateB, currentB)
+}
+
+// list returns the entries below root by their path relative to it
+func (s *BisyncTask) list(root string) (map[string]BisyncEntry, error) {
+	out, err := listRecursive(root, s.rsyncOptions)
+	if err != nil {
+		return nil, err
+	}
+	return parseBisyncListing(out), nil
+}
+
+// transferList copies the paths from source to destination and deletes the ones missing in source
+func (s *BisyncTask) transferList(source, destination string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	f, err := os.CreateTemp("", "grsync-files-from-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(strings.Join(paths, "\n") + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.transfer(source, destination, RsyncOptions{FilesFrom: f.Name(), DeleteMissingArgs: true, Force: true})
+}
+
+// transfer runs a task copying source to destination with the options of the bisync task and the extra ones
+func (s *BisyncTask) transfer(source, destination string, extra RsyncOptions) error {
+	options := s.rsyncOptions
+	options.Times = true
+	options.NoTimes = false
+	options.FilesFrom = extra.FilesFrom
+	options.DeleteMissingArgs = extra.DeleteMissingArgs
+	options.Force = options.Force || extra.Force
+
+	task, err := NewTask(source, destination, false, false, options)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	if s.cancelled {
+		s.mutex.Unlock()
+		return ErrBisyncCancelled
+	}
+	s.tasks = append(s.tasks, task)
+	s.mutex.Unlock()
+	return task.Run()
+}
+
+// bisyncPlan are the paths to transfer in either direction. A path missing on the sending
+// endpoint is deleted on the receiving one
+type bisyncPlan struct {
+	toA, toB []string
+	// keepBoth are the paths whose version of B is copied to A with the conflict suffix
+	keepBoth []string
+	skipped  []string
+}
+
+// planBisync compares the current listings of both endpoints with their previous ones
+func planBisync(currentA, currentB, previousA, previousB map[string]BisyncEntry, policy ConflictPolicy) bisyncPlan {
+	var plan bisyncPlan
+	for _, path := range unionPaths(currentA, currentB, previousA, previousB) {
+		a, b := lookupEntry(currentA, path), lookupEntry(currentB, path)
+		if sameEntry(a, b) {
+			continue
+		}
+		changedA := !sameEntry(lookupEntry(previousA, path), a)
+		changedB := !sameEntry(lookupEntry(previousB, path), b)
+		switch {
+		case changedA && !changedB:
+			plan.toB = append(plan.toB, path)
+		case changedB && !changedA:
+			plan.toA = append(plan.toA, path)
+		default:
+			conflict := Conflict{Path: path, A: a, B: b}
+			plan.resolve(conflict, policy(conflict))
+		}
+	}
+	plan.toA, plan.toB = pruneDeletions(plan.toA, plan.toB, currentB), pruneDeletions(plan.toB, plan.toA, currentA)
+	return plan
+}
+
+func (p *bisyncPlan) resolve(c Conflict, resolution Resolution) {
+	if resolution == ResolveBoth {
+		switch {
+		case c.A == nil:
+			resolution = ResolveB
+		case c.B == nil || c.A.Dir || c.B.Dir:
+			resolution = ResolveA
+		}
+	}
+
+	switch resolution {
+	case ResolveA:
+		p.toB = append(p.toB, c.Path)
+	case ResolveB:
+		p.toA = append(p.toA, c.Path)
+	case ResolveBoth:
+		p.keepBoth = append(p.keepBoth, c.Path)
+		p.toB = append(p.toB, c.Path)
+	default:
+		p.skipped = append(p.skipped, c.Path)
+	}
+}
+
+// pruneDeletions drops the deletions from paths, which are missing in the sending listing, of
+// directories that receive new entries from the other direction
+func pruneDeletions(paths, other []string, sending map[string]BisyncEntry) []string {
+	var pruned []string
+	for _, path := range paths {
+		if _, ok := sending[path]; !ok && hasDescendant(other, path) {
+			continue
+		}
+		pruned = append(pruned, path)
+	}
+	return pruned
+}
+
+func hasDescendant(paths []string, dir string) bool {
+	for _, path := range paths {
+		if strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func unionPaths(listings ...map[string]BisyncEntry) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, listing := range listings {
+		for path := range listing {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func lookupEntry(listing map[string]BisyncEntry, path string) *BisyncEntry {
+	if entry, ok := listing[path]; ok {
+		return &entry
+	}
+	return nil
+}
+
+func sameEntry(a, b *BisyncEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Dir || b.Dir {
+		return a.Dir == b.Dir
+	}
+	return a.Size == b.Size && a.ModTime.Equal(b.ModTime)
+}
+
+func restoreEntry(current, previous map[string]BisyncEntry, path string) {
+	if entry, ok := previous[path]; ok {
+		current[path] = entry
+	} else {
+		delete(current, path)
+	}
+}
+
+// parseBisyncListing parses a recursive --list-only output into entries by their relative path
+func parseBisyncListing(out []byte) map[string]BisyncEntry {
+	entries := make(map[string]BisyncEntry)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		match := listPattern.FindStringSubmatch(scanner.Text())
+		if match == nil || match[4] == "." {
+			continue
+		}
+		name := match[4]
+		if match[1][0] == 'l' {
+			name = strings.SplitN(name, " -> ", 2)[0]
+		}
+		name = unescapeName(name)
+
+		if match[1][0] == 'd' {
+			entries[name] = BisyncEntry{Dir: true}
+			continue
+		}
+		size, _ := ParseSize(match[2])
+		modTime, _ := time.ParseInLocation(logTimeLayout, match[3], time.Local)
+		entries[name] = BisyncEntry{Size: size, ModTime: modTime}
+	}
+	return entries
+}
+
+// loadBisyncState reads the listing saved by the last run, nil if there was none
+func loadBisyncState(path string) (map[string]BisyncEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]BisyncEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// saveBisyncState replaces the saved listing atomically
+func saveBisyncState(path string, entries map[string]BisyncEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,145 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bisyncRsync lists and copies local directories like rsync does with --list-only and --files-from
+const bisyncRsync = `list=; from=; del=
+for arg; do
+	case "$arg" in
+	--list-only) list=1;;
+	--files-from=*) from=${arg#--files-from=};;
+	--delete-missing-args) del=1;;
+	esac
+	src=$dst; dst=$arg
+done
+if [ -n "$list" ]; then
+	cd "$dst" || exit 23
+	echo "drwxr-xr-x          4,096 2023/10/07 13:19:08 ."
+	find . -mindepth 1 -printf '%M %14s %TY/%Tm/%Td %TT %P\n' | sed -E 's/:([0-9]{2})\.[0-9]+ /:\1 /'
+	exit
+fi
+if [ -z "$from" ]; then
+	cp -p "$src" "$dst"
+	exit
+fi
+while IFS= read -r p; do
+	if [ ! -e "$src$p" ]; then
+		[ -n "$del" ] && rm -rf "$dst$p"
+	elif [ -d "$src$p" ]; then
+		mkdir -p "$dst$p"
+	else
+		mkdir -p "$(dirname "$dst$p")" && cp -p "$src$p" "$dst$p"
+	fi
+done < "$from"`
+
+func writeBisyncFile(t *testing.T, path, content string, modTime time.Time) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+}
+
+func readBisyncFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err, path)
+	return string(data)
+}
+
+func TestBisyncTask(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	task, err := NewBisyncTask(a, b, BisyncOptions{StateDir: filepath.Join(t.TempDir(), "state")}, RsyncOptions{RsyncBinaryPath: fakeRsync(t, bisyncRsync)})
+	assert.Nil(t, err)
+
+	writeBisyncFile(t, filepath.Join(a, "only-a.txt"), "a", base)
+	writeBisyncFile(t, filepath.Join(b, "dir", "only-b.txt"), "b", base)
+	writeBisyncFile(t, filepath.Join(a, "both.txt"), "old a", base)
+	writeBisyncFile(t, filepath.Join(b, "both.txt"), "new b", base.Add(time.Minute))
+
+	t.Run("first run", func(t *testing.T) {
+		assert.Nil(t, task.Run())
+		assert.Equal(t, "a", readBisyncFile(t, filepath.Join(b, "only-a.txt")))
+		assert.Equal(t, "b", readBisyncFile(t, filepath.Join(a, "dir", "only-b.txt")))
+		// the newer version wins the conflict of the first run
+		assert.Equal(t, "new b", readBisyncFile(t, filepath.Join(a, "both.txt")))
+	})
+
+	t.Run("deletion and modification", func(t *testing.T) {
+		assert.Nil(t, os.Remove(filepath.Join(a, "only-a.txt")))
+		writeBisyncFile(t, filepath.Join(b, "dir", "only-b.txt"), "b changed", base.Add(2*time.Minute))
+
+		assert.Nil(t, task.Run())
+		assert.NoFileExists(t, filepath.Join(b, "only-a.txt"))
+		assert.Equal(t, "b changed", readBisyncFile(t, filepath.Join(a, "dir", "only-b.txt")))
+	})
+
+	t.Run("keep both", func(t *testing.T) {
+		writeBisyncFile(t, filepath.Join(a, "both.txt"), "edit a", base.Add(3*time.Minute))
+		writeBisyncFile(t, filepath.Join(b, "both.txt"), "edit b", base.Add(4*time.Minute))
+		task.options.Conflicts = KeepBoth
+
+		assert.Nil(t, task.Run())
+		for _, dir := range []string{a, b} {
+			assert.Equal(t, "edit a", readBisyncFile(t, filepath.Join(dir, "both.txt")))
+			assert.Equal(t, "edit b", readBisyncFile(t, filepath.Join(dir, "both.txt.conflict")))
+		}
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		writeBisyncFile(t, filepath.Join(a, "both.txt"), "again a", base.Add(5*time.Minute))
+		writeBisyncFile(t, filepath.Join(b, "both.txt"), "again b", base.Add(6*time.Minute))
+		var conflicts []Conflict
+		task.options.Conflicts = func(c Conflict) Resolution {
+			conflicts = append(conflicts, c)
+			return ResolveSkip
+		}
+
+		assert.Nil(t, task.Run())
+		assert.Equal(t, "again a", readBisyncFile(t, filepath.Join(a, "both.txt")))
+		assert.Equal(t, "again b", readBisyncFile(t, filepath.Join(b, "both.txt")))
+		assert.Len(t, conflicts, 1)
+		assert.Equal(t, "both.txt", conflicts[0].Path)
+
+		// skipped conflicts are detected again
+		assert.Nil(t, task.Run())
+		assert.Len(t, conflicts, 2)
+	})
+
+	t.Run("empty endpoint", func(t *testing.T) {
+		assert.Nil(t, os.RemoveAll(a))
+		assert.Nil(t, os.Mkdir(a, 0755))
+		assert.Equal(t, ErrBisyncEmptySide, task.Run())
+		assert.FileExists(t, filepath.Join(b, "both.txt"))
+	})
+
+	_, err = NewBisyncTask(a, b, BisyncOptions{}, RsyncOptions{})
+	assert.Equal(t, ErrBisyncStateDir, err)
+}
+
+func TestPlanBisync(t *testing.T) {
+	file := BisyncEntry{Size: 1, ModTime: time.Unix(1000, 0)}
+	dir := BisyncEntry{Dir: true}
+
+	// deleting dir on A while a new file below it appeared on B keeps the directory
+	previous := map[string]BisyncEntry{"dir": dir, "dir/old": file}
+	plan := planBisync(
+		map[string]BisyncEntry{},
+		map[string]BisyncEntry{"dir": dir, "dir/old": file, "dir/new": file},
+		previous, previous, NewerWins)
+	assert.Equal(t, []string{"dir/new"}, plan.toA)
+	assert.Equal(t, []string{"dir/old"}, plan.toB)
+
+	// a modification wins over a deletion
+	plan = planBisync(
+		map[string]BisyncEntry{},
+		map[string]BisyncEntry{"file": {Size: 2, ModTime: time.Unix(2000, 0)}},
+		map[string]BisyncEntry{"file": file}, map[string]BisyncEntry{"file": file}, NewerWins)
+	assert.Equal(t, []string{"file"}, plan.toA)
+	assert.Empty(t, plan.toB)
+}
@@ -0,0 +1,174 @@
+package grsync
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BandwidthWindow is a bandwidth limit applying between two times of day
+type BandwidthWindow struct {
+	// Start and End are the offsets from midnight in local time, End is exclusive. A window
+	// whose End is before its Start spans midnight
+	Start time.Duration
+	End   time.Duration
+	// Limit is in the unit of RsyncOptions.BandwidthLimit, 0 is unlimited
+	Limit int
+}
+
+// contains reports whether the window covers the offset from midnight
+func (w BandwidthWindow) contains(offset time.Duration) bool {
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// BandwidthSchedule are bandwidth limits by time of day, e.g. 5 MB/s during office hours. The
+// first window covering a time applies, outside of all windows RsyncOptions.BandwidthLimit does
+type BandwidthSchedule []BandwidthWindow
+
+// ParseBandwidthSchedule parses comma separated windows like "08:00-18:00=5000,18:00-22:00=20000"
+func ParseBandwidthSchedule(s string) (BandwidthSchedule, error) {
+	var schedule BandwidthSchedule
+	for _, window := range strings.Split(s, ",") {
+		if window = strings.TrimSpace(window); window == "" {
+			continue
+		}
+		times, limit, ok := strings.Cut(window, "=")
+		start, end, ok2 := strings.Cut(times, "-")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid bandwidth window %q", window)
+		}
+
+		var w BandwidthWindow
+		var err error
+		if w.Start, err = parseTimeOfDay(start); err != nil {
+			return nil, err
+		}
+		if w.End, err = parseTimeOfDay(end); err != nil {
+			return nil, err
+		}
+		if w.Limit, err = strconv.Atoi(strings.TrimSpace(limit)); err != nil || w.Limit < 0 {
+			return nil, fmt.Errorf("invalid bandwidth limit %q", limit)
+		}
+		schedule = append(schedule, w)
+	}
+	return schedule, nil
+}
+
+// parseTimeOfDay parses "HH:MM" into the offset from midnight, "24:00" is accepted as the end of a day
+func parseTimeOfDay(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	hours, minutes, ok := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hours)
+	m, err2 := strconv.Atoi(minutes)
+	if !ok || err != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Limit returns the limit applying at the time, fallback outside of all windows
+func (s BandwidthSchedule) Limit(at time.Time, fallback int) int {
+	offset := at.Sub(midnight(at))
+	for _, w := range s {
+		if w.contains(offset) {
+			return w.Limit
+		}
+	}
+	return fallback
+}
+
+// nextChange returns the first time after at when the limit differs from the one at at, zero if
+// it never does
+func (s BandwidthSchedule) nextChange(at time.Time, fallback int) time.Time {
+	current := s.Limit(at, fallback)
+	var boundaries []time.Time
+	for day := 0; day < 2; day++ {
+		base := midnight(at).AddDate(0, 0, day)
+		for _, w := range s {
+			boundaries = append(boundaries, base.Add(w.Start), base.Add(w.End))
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+	for _, boundary := range boundaries {
+		if boundary.After(at) && s.Limit(boundary, fallback) != current {
+			return boundary
+		}
+	}
+	return time.Time{}
+}
+
+func midnight(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// SetBandwidthSchedule makes the task limit its bandwidth by time of day. Every attempt starts
+// with the limit applying at its start, RsyncOptions.BandwidthLimit outside of all windows. rsync
+// can't change its limit while running, so the task relaunches it at the boundaries of the
+// windows, continuing the partial files like a resumed retry. Relaunches don't count as attempts
+// of the retry policy
+func (t *Task) SetBandwidthSchedule(schedule BandwidthSchedule) {
+	t.mutex.Lock()
+	t.bandwidthSchedule = append(BandwidthSchedule(nil), schedule...)
+	t.mutex.Unlock()
+}
+
+// BandwidthLimit returns the bandwidth limit of the current or last attempt
+func (t *Task) BandwidthLimit() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.bandwidthLimit
+}
+
+// scheduleBandwidth applies the bandwidth schedule to the options of the next attempt and
+// remembers when its limit changes. The mutex must be held
+func (t *Task) scheduleBandwidth(options RsyncOptions) RsyncOptions {
+	t.bandwidthChange = time.Time{}
+	if options.Bandwidth > 0 {
+		options.BandwidthLimit = options.Bandwidth.KiB()
+	}
+	if len(t.bandwidthSchedule) > 0 {
+		now := time.Now()
+		fallback := options.BandwidthLimit
+		if options.BandwidthLimit = t.bandwidthSchedule.Limit(now, fallback); options.BandwidthLimit != fallback {
+			// a window applies
+			options.Bandwidth = 0
+		}
+		t.bandwidthChange = t.bandwidthSchedule.nextChange(now, fallback)
+	}
+	t.bandwidthLimit = options.BandwidthLimit
+	return options
+}
+
+// watchBandwidth kills rsync once the bandwidth limit changes, the returned function stops it
+func (t *Task) watchBandwidth() (stop func() bool) {
+	t.mutex.Lock()
+	change := t.bandwidthChange
+	t.mutex.Unlock()
+	if change.IsZero() {
+		return func() bool { return false }
+	}
+
+	timer := time.AfterFunc(time.Until(change), func() {
+		t.mutex.Lock()
+		t.bandwidthRelaunch = true
+		_ = t.rsync.Kill()
+		t.mutex.Unlock()
+	})
+	return timer.Stop
+}
+
+// relaunchForBandwidth reports whether the failed attempt was killed because the bandwidth
+// limit changed and clears the flag
+func (t *Task) relaunchForBandwidth() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	relaunch := t.bandwidthRelaunch && !t.cancelled
+	t.bandwidthRelaunch = false
+	return relaunch
+}
@@ -0,0 +1,78 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	schedule, err := ParseBandwidthSchedule("08:00-18:00=5000, 22:30-06:00=0")
+	assert.Nil(t, err)
+	assert.Equal(t, BandwidthSchedule{
+		{Start: 8 * time.Hour, End: 18 * time.Hour, Limit: 5000},
+		{Start: 22*time.Hour + 30*time.Minute, End: 6 * time.Hour, Limit: 0},
+	}, schedule)
+
+	for _, invalid := range []string{"08:00=5000", "08:00-18:00", "8-18=5000", "08:00-25:00=1", "08:00-18:00=fast", "08:00-18:00=-1"} {
+		_, err = ParseBandwidthSchedule(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestBandwidthScheduleLimit(t *testing.T) {
+	schedule := BandwidthSchedule{
+		{Start: 8 * time.Hour, End: 18 * time.Hour, Limit: 5000},
+		{Start: 22 * time.Hour, End: 6 * time.Hour, Limit: 20000},
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+
+	assert.Equal(t, 5000, schedule.Limit(day.Add(8*time.Hour), 100))
+	assert.Equal(t, 100, schedule.Limit(day.Add(18*time.Hour), 100))
+	assert.Equal(t, 20000, schedule.Limit(day.Add(23*time.Hour), 100))
+	assert.Equal(t, 20000, schedule.Limit(day.Add(time.Hour), 100))
+	assert.Equal(t, 100, schedule.Limit(day.Add(7*time.Hour), 100))
+
+	assert.Equal(t, day.Add(8*time.Hour), schedule.nextChange(day.Add(7*time.Hour), 100))
+	assert.Equal(t, day.Add(18*time.Hour), schedule.nextChange(day.Add(8*time.Hour), 100))
+	assert.Equal(t, day.Add(22*time.Hour), schedule.nextChange(day.Add(18*time.Hour), 100))
+	assert.Equal(t, day.Add(30*time.Hour), schedule.nextChange(day.Add(23*time.Hour), 100))
+
+	// adjacent windows with the same limit aren't a change
+	same := BandwidthSchedule{{Start: 0, End: 12 * time.Hour, Limit: 1}, {Start: 12 * time.Hour, End: 24 * time.Hour, Limit: 1}}
+	assert.True(t, same.nextChange(day.Add(time.Hour), 0).IsZero())
+}
+
+func TestBandwidthScheduleRelaunch(t *testing.T) {
+	now := time.Now()
+	offset := now.Sub(midnight(now))
+	if offset > 23*time.Hour {
+		t.Skip("the window would span midnight")
+	}
+
+	log := filepath.Join(t.TempDir(), "log")
+	// the limited attempt runs until it is killed
+	script := `echo "$@" >> ` + log + `
+case "$*" in *--bwlimit*) exec sleep 5 ;; esac`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetBandwidthSchedule(BandwidthSchedule{{Start: 0, End: offset + 300*time.Millisecond, Limit: 100}})
+
+	started := time.Now()
+	assert.Nil(t, task.Run())
+	assert.Less(t, time.Since(started), 3*time.Second)
+	assert.Equal(t, 0, task.BandwidthLimit())
+	assert.Equal(t, 2, task.Attempts())
+
+	data, err := ioutil.ReadFile(log)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "--bwlimit 100")
+	assert.NotContains(t, lines[1], "--bwlimit")
+	assert.Contains(t, lines[1], "--no-whole-file")
+}
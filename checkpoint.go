@@ -0,0 +1,197 @@
+package grsync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckpointInterval is the interval in which a running task saves its progress
+const CheckpointInterval = 5 * time.Second
+
+// ErrCheckpointNotFound is returned when a store holds no checkpoint for a key
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// Checkpoint is the persisted definition and progress of an unfinished task
+type Checkpoint struct {
+	Key        string     `json:"key"`
+	Definition Definition `json:"definition"`
+	State      State      `json:"state"`
+	Attempts   int        `json:"attempts"`
+	LastError  string     `json:"lastError,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// CheckpointStore persists checkpoints of running tasks
+type CheckpointStore interface {
+	Save(checkpoint Checkpoint) error
+	Load(key string) (Checkpoint, error)
+	Delete(key string) error
+	List() ([]Checkpoint, error)
+}
+
+// FileCheckpointStore stores every checkpoint as a JSON file in a directory
+type FileCheckpointStore struct {
+	Dir string
+}
+
+// NewFileCheckpointStore returns a store writing into dir, the directory is created if needed
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{Dir: dir}, nil
+}
+
+// Save writes the checkpoint, replacing a previous one with the same key
+func (s *FileCheckpointStore) Save(checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated checkpoint behind. Its
+	// name is unique, tasks and processes sharing the store don't write into each other's files
+	path := s.path(checkpoint.Key)
+	tmp, err := os.CreateTemp(s.Dir, filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Load reads the checkpoint stored under key
+func (s *FileCheckpointStore) Load(key string) (Checkpoint, error) {
+	return s.read(s.path(key))
+}
+
+// Delete removes the checkpoint stored under key
+func (s *FileCheckpointStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List returns all stored checkpoints
+func (s *FileCheckpointStore) List() ([]Checkpoint, error) {
+	entries, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoints []Checkpoint
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		checkpoint, err := s.read(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+func (s *FileCheckpointStore) read(path string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkpoint, ErrCheckpointNotFound
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+	err = json.Unmarshal(data, &checkpoint)
+	return checkpoint, err
+}
+
+func (s *FileCheckpointStore) path(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Resume rebuilds the task saved under key with --partial, so already transferred data is reused.
+// The returned task keeps checkpointing into store
+func Resume(store CheckpointStore, key string) (*Task, error) {
+	checkpoint, err := store.Load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	definition := checkpoint.Definition
+	definition.Options.Partial = true
+	task, err := definition.NewTask()
+	if err != nil {
+		return nil, err
+	}
+
+	task.attempts = checkpoint.Attempts
+	task.SetCheckpointStore(store, key)
+	return task, nil
+}
+
+// SetCheckpointStore makes the task save its definition and progress under key while running.
+// The checkpoint is deleted once the task finished successfully
+func (t *Task) SetCheckpointStore(store CheckpointStore, key string) {
+	t.checkpointStore = store
+	t.checkpointKey = key
+}
+
+// Attempts returns how often the task has been run, including runs before a Resume
+func (t *Task) Attempts() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.attempts
+}
+
+func (t *Task) checkpoint(runErr error) error {
+	t.mutex.Lock()
+	checkpoint := Checkpoint{
+		Key:        t.checkpointKey,
+		Definition: t.definition,
+		State:      *t.state,
+		Attempts:   t.attempts,
+		UpdatedAt:  time.Now(),
+	}
+	t.mutex.Unlock()
+
+	if runErr != nil {
+		checkpoint.LastError = runErr.Error()
+	}
+	return t.checkpointStore.Save(checkpoint)
+}
+
+// checkpointLoop saves the progress in CheckpointInterval until done is closed, then closes stopped
+func (t *Task) checkpointLoop(done <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(CheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			_ = t.checkpoint(nil)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	store, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints"))
+	assert.Nil(t, err)
+
+	_, err = store.Load("missing")
+	assert.Equal(t, ErrCheckpointNotFound, err)
+
+	checkpoint := Checkpoint{
+		Key:        "nightly/home",
+		Definition: Definition{Source: "a", Destination: "b"},
+		State:      State{Progress: 42},
+		Attempts:   2,
+	}
+	assert.Nil(t, store.Save(checkpoint))
+
+	loaded, err := store.Load("nightly/home")
+	assert.Nil(t, err)
+	assert.Equal(t, checkpoint, loaded)
+
+	list, err := store.List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+	assert.Nil(t, store.Delete("nightly/home"))
+	assert.Nil(t, store.Delete("nightly/home"))
+	list, err = store.List()
+	assert.Nil(t, err)
+	assert.Empty(t, list)
+}
+
+func TestFileCheckpointStoreConcurrentSave(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(attempts int) {
+			defer wg.Done()
+			// stores of several processes share the directory
+			store, err := NewFileCheckpointStore(dir)
+			assert.Nil(t, err)
+			assert.Nil(t, store.Save(Checkpoint{Key: "job", Attempts: attempts}))
+		}(i)
+	}
+	wg.Wait()
+
+	store, err := NewFileCheckpointStore(dir)
+	assert.Nil(t, err)
+	_, err = store.Load("job")
+	assert.Nil(t, err)
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1, "temporary files are left behind")
+}
+
+func TestTaskCheckpoint(t *testing.T) {
+	store, err := NewFileCheckpointStore(t.TempDir())
+	assert.Nil(t, err)
+
+	t.Run("failed run keeps checkpoint", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+		assert.Nil(t, err)
+		task.SetCheckpointStore(store, "job")
+		assert.NotNil(t, task.Run())
+
+		checkpoint, err := store.Load("job")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, checkpoint.Attempts)
+		assert.Equal(t, "a", checkpoint.Definition.Source)
+		assert.NotEmpty(t, checkpoint.LastError)
+	})
+
+	t.Run("resume continues attempts and deletes checkpoint on success", func(t *testing.T) {
+		checkpoint, err := store.Load("job")
+		assert.Nil(t, err)
+		checkpoint.Definition.Options.RsyncBinaryPath = fakeRsync(t, "exit 0")
+		assert.Nil(t, store.Save(checkpoint))
+
+		task, err := Resume(store, "job")
+		assert.Nil(t, err)
+		assert.True(t, task.Definition().Options.Partial)
+		assert.Nil(t, task.Run())
+		assert.Equal(t, 2, task.Attempts())
+
+		_, err = store.Load("job")
+		assert.Equal(t, ErrCheckpointNotFound, err)
+	})
+}
@@ -0,0 +1,318 @@
+package grsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrChunkedDestination is returned by NewChunkedTask for rsync daemon destinations, the chunks
+	// can only be reassembled on local and remote shell destinations
+	ErrChunkedDestination = errors.New("chunked transfers need a local or remote shell destination")
+	// ErrChunkChecksum is returned if the reassembled file doesn't match the source
+	ErrChunkChecksum = errors.New("reassembled file doesn't match the source")
+	// ErrChunkedCancelled is returned by ChunkedTask.Run if it was cancelled before the transfers started
+	ErrChunkedCancelled = errors.New("chunked transfer was cancelled")
+)
+
+// chunkedPartsSuffix is appended to the destination to name the directory receiving the chunks
+const chunkedPartsSuffix = ".grsync-parts"
+
+// ChunkedTask transfers a single large local file as several chunks with concurrent rsync
+// processes and reassembles them at the destination, verifying the SHA-256 of the result.
+// It helps when the throughput of a single stream is limited by latency rather than bandwidth.
+// The chunks are staged in os.TempDir, which needs room for a copy of the file
+type ChunkedTask struct {
+	source      string
+	destination string
+	chunks      int
+	options     RsyncOptions
+
+	mutex     sync.Mutex
+	tasks     []*Task
+	size      int64
+	cancelled bool
+}
+
+// NewChunkedTask returns a task copying the file source to the file destination in chunks parts.
+// Remote destinations like `user@host:/path/file` are reassembled through options.RemoteShell or options.Rsh, by default `ssh`
+func NewChunkedTask(source, destination string, chunks int, options RsyncOptions) (*ChunkedTask, error) {
+	if chunks < 1 {
+		return nil, fmt.Errorf("invalid number of chunks %d", chunks)
+	}
+	if strings.Contains(destination, "::") || strings.HasPrefix(destination, "rsync://") {
+		return nil, ErrChunkedDestination
+	}
+	return &ChunkedTask{source: source, destination: destination, chunks: chunks, options: options}, nil
+}
+
+// Tasks returns the tasks transferring the chunks, they are created by Run
+func (c *ChunkedTask) Tasks() []*Task {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*Task(nil), c.tasks...)
+}
+
+// State combines the states of the chunk transfers
+func (c *ChunkedTask) State() State {
+	c.mutex.Lock()
+	tasks, size := c.tasks, c.size
+	c.mutex.Unlock()
+	return combineStates(tasks, size)
+}
+
+// Cancel stops the transfers of all chunks
+func (c *ChunkedTask) Cancel() error {
+	c.mutex.Lock()
+	c.cancelled = true
+	tasks := c.tasks
+	c.mutex.Unlock()
+	return cancelTasks(tasks)
+}
+
+// Run splits the source, transfers the chunks concurrently and reassembles them
+func (c *ChunkedTask) Run() error {
+	staging, err := os.MkdirTemp("", "grsync-chunks-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	parts, sum, err := c.split(staging)
+	if err != nil {
+		return err
+	}
+
+	host, path, remote := splitRemote(c.destination)
+	partsDir := path + chunkedPartsSuffix
+	if remote {
+		err = c.remote(host, "mkdir -p "+remoteQuote(partsDir))
+	} else {
+		err = os.MkdirAll(partsDir, 0755)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = c.transfer(parts, c.destination+chunkedPartsSuffix+"/"); err == nil {
+		names := make([]string, len(parts))
+		for i, part := range parts {
+			names[i] = partsDir + "/" + filepath.Base(part)
+		}
+		if remote {
+			err = c.reassembleRemote(host, path, partsDir, names, sum)
+		} else {
+			err = reassemble(path, partsDir, names, sum)
+		}
+	}
+	if err != nil {
+		return c.removeParts(host, partsDir, remote, err)
+	}
+	return nil
+}
+
+// removeParts removes the directory of the chunks after the transfer failed with err. If it can't
+// be removed, its path is added to err
+func (c *ChunkedTask) removeParts(host, partsDir string, remote bool, err error) error {
+	var removeErr error
+	if remote {
+		removeErr = c.remote(host, "rm -rf "+remoteQuote(partsDir))
+	} else {
+		removeErr = os.RemoveAll(partsDir)
+	}
+	if removeErr != nil {
+		return fmt.Errorf("%w, the chunks are left in %s: %v", err, partsDir, removeErr)
+	}
+	return err
+}
+
+// split writes the chunks of the source into dir and returns their paths and the SHA-256 of the source
+func (c *ChunkedTask) split(dir string) ([]string, string, error) {
+	source, err := os.Open(c.source)
+	if err != nil {
+		return nil, "", err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return nil, "", err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, "", fmt.Errorf("%s is not a regular file", c.source)
+	}
+	c.mutex.Lock()
+	c.size = info.Size()
+	c.mutex.Unlock()
+
+	chunkSize := (info.Size() + int64(c.chunks) - 1) / int64(c.chunks)
+	sum := sha256.New()
+	var parts []string
+	for i := 0; i < c.chunks; i++ {
+		// small files have fewer chunks, but an empty file still has one
+		if i > 0 && int64(i)*chunkSize >= info.Size() {
+			break
+		}
+		part := filepath.Join(dir, fmt.Sprintf("%s.part%03d", filepath.Base(c.source), i))
+		if err = writeChunk(part, io.TeeReader(io.LimitReader(source, chunkSize), sum)); err != nil {
+			return nil, "", err
+		}
+		parts = append(parts, part)
+	}
+	return parts, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func writeChunk(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// transfer runs one task per part and returns the first error
+func (c *ChunkedTask) transfer(parts []string, destination string) error {
+	tasks := make([]*Task, len(parts))
+	for i, part := range parts {
+		task, err := NewTask(part, destination, false, false, c.options)
+		if err != nil {
+			return err
+		}
+		tasks[i] = task
+	}
+
+	c.mutex.Lock()
+	if c.cancelled {
+		c.mutex.Unlock()
+		return ErrChunkedCancelled
+	}
+	c.tasks = tasks
+	c.mutex.Unlock()
+
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task *Task) {
+			defer wg.Done()
+			if errs[i] = task.Run(); errs[i] != nil {
+				// the file is incomplete without this chunk
+				_ = c.Cancel()
+			}
+		}(i, task)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reassemble concatenates the parts into path on the local file system
+func reassemble(path, partsDir string, parts []string, sum string) error {
+	tmp := path + ".grsync-tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	hasher := sha256.New()
+	err = concatenate(io.MultiWriter(f, hasher), parts)
+	if syncErr := f.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && hex.EncodeToString(hasher.Sum(nil)) != sum {
+		err = ErrChunkChecksum
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return os.RemoveAll(partsDir)
+}
+
+func concatenate(w io.Writer, parts []string) error {
+	for _, part := range parts {
+		f, err := os.Open(part)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reassembleRemote concatenates the parts into path on host
+func (c *ChunkedTask) reassembleRemote(host, path, partsDir string, parts []string, sum string) error {
+	tmp := remoteQuote(path + ".grsync-tmp")
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		quoted[i] = remoteQuote(part)
+	}
+
+	out, err := remoteCommand(c.options.remoteShell(), host, "cat "+strings.Join(quoted, " ")+" > "+tmp+" && sha256sum "+tmp).Output()
+	if err != nil {
+		return fmt.Errorf("reassemble %s: %w", c.destination, err)
+	}
+	if fields := strings.Fields(string(out)); len(fields) == 0 || fields[0] != sum {
+		_ = c.remote(host, "rm -f "+tmp)
+		return ErrChunkChecksum
+	}
+	return c.remote(host, "mv "+tmp+" "+remoteQuote(path)+" && rm -rf "+remoteQuote(partsDir))
+}
+
+func (c *ChunkedTask) remote(host, command string) error {
+	out, err := remoteCommand(c.options.remoteShell(), host, command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", host, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// remoteQuote quotes the remote path for the remote shell like shellQuote, except for a leading
+// "~/", which the shell expands to the home directory like rsync does
+func remoteQuote(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return "~/" + shellQuote(rest)
+	}
+	return shellQuote(path)
+}
+
+// splitRemote splits a remote shell destination like `user@host:/path` into host and path. Windows
+// drive paths like `C:\data\file` are local, see ParseEndpoint
+func splitRemote(destination string) (host, path string, remote bool) {
+	if isDrivePath(destination) && (runtime.GOOS == "windows" || len(destination) > 2 && destination[2] == '\\') {
+		return "", destination, false
+	}
+	i := strings.IndexByte(destination, ':')
+	// like rsync, a colon after a slash is part of a local path
+	if i <= 0 || strings.ContainsRune(destination[:i], '/') {
+		return "", destination, false
+	}
+	return destination[:i], destination[i+1:], true
+}
@@ -0,0 +1,147 @@
+package grsync
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkRsync copies the source into the destination, stripping the host of remote destinations
+const chunkRsync = `for arg; do src=$dst; dst=$arg; done
+dst=${dst#*:}
+cp "$src" "$dst"
+echo "$(wc -c < "$src") 100%    1.00kB/s    0:00:00"`
+
+func chunkSource(t *testing.T, size int) (string, []byte) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	path := filepath.Join(t.TempDir(), "big.bin")
+	assert.Nil(t, os.WriteFile(path, data, 0644))
+	return path, data
+}
+
+func TestChunkedTask(t *testing.T) {
+	t.Run("local destination", func(t *testing.T) {
+		source, data := chunkSource(t, 10000)
+		destination := filepath.Join(t.TempDir(), "copy.bin")
+
+		task, err := NewChunkedTask(source, destination, 4, RsyncOptions{RsyncBinaryPath: fakeRsync(t, chunkRsync)})
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+
+		copied, err := os.ReadFile(destination)
+		assert.Nil(t, err)
+		assert.True(t, bytes.Equal(data, copied))
+		assert.NoDirExists(t, destination+chunkedPartsSuffix)
+		assert.Len(t, task.Tasks(), 4)
+
+		state := task.State()
+		assert.Equal(t, 100, state.Progress)
+		assert.Equal(t, int64(10000), state.BytesTransferred)
+	})
+
+	t.Run("fewer chunks than requested", func(t *testing.T) {
+		source, data := chunkSource(t, 3)
+		destination := filepath.Join(t.TempDir(), "copy.bin")
+
+		task, err := NewChunkedTask(source, destination, 8, RsyncOptions{RsyncBinaryPath: fakeRsync(t, chunkRsync)})
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+		assert.Len(t, task.Tasks(), 3)
+
+		copied, err := os.ReadFile(destination)
+		assert.Nil(t, err)
+		assert.Equal(t, data, copied)
+	})
+
+	t.Run("remote destination", func(t *testing.T) {
+		source, data := chunkSource(t, 10000)
+		destination := filepath.Join(t.TempDir(), "copy.bin")
+		// runs the remote command locally
+		rsh := fakeRsync(t, `shift; sh -c "$1"`)
+
+		task, err := NewChunkedTask(source, "host:"+destination, 3, RsyncOptions{RsyncBinaryPath: fakeRsync(t, chunkRsync), Rsh: rsh})
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+
+		copied, err := os.ReadFile(destination)
+		assert.Nil(t, err)
+		assert.True(t, bytes.Equal(data, copied))
+		assert.NoDirExists(t, destination+chunkedPartsSuffix)
+	})
+
+	t.Run("corrupted chunk", func(t *testing.T) {
+		source, _ := chunkSource(t, 10000)
+		destination := filepath.Join(t.TempDir(), "copy.bin")
+
+		task, err := NewChunkedTask(source, destination, 2, RsyncOptions{RsyncBinaryPath: fakeRsync(t, chunkRsync+`
+printf x >> "$dst/$(basename "$src")"`)})
+		assert.Nil(t, err)
+		assert.Equal(t, ErrChunkChecksum, task.Run())
+		assert.NoFileExists(t, destination)
+		assert.NoDirExists(t, destination+chunkedPartsSuffix)
+	})
+
+	t.Run("failed chunk", func(t *testing.T) {
+		source, _ := chunkSource(t, 10000)
+		destination := filepath.Join(t.TempDir(), "copy.bin")
+
+		task, err := NewChunkedTask(source, destination, 2, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+		assert.Nil(t, err)
+		assert.NotNil(t, task.Run())
+		assert.NoFileExists(t, destination)
+		assert.NoDirExists(t, destination+chunkedPartsSuffix)
+	})
+
+	t.Run("remote destination in the home directory", func(t *testing.T) {
+		source, data := chunkSource(t, 10000)
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		rsh := fakeRsync(t, `shift; sh -c "$1"`)
+		// like rsync, the fake expands ~ of the remote path
+		script := `for arg; do src=$dst; dst=$arg; done
+dst=${dst#*:}
+dst=$HOME/${dst#"~/"}
+cp "$src" "$dst"`
+
+		task, err := NewChunkedTask(source, "host:~/copy.bin", 2, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Rsh: rsh})
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+
+		copied, err := os.ReadFile(filepath.Join(home, "copy.bin"))
+		assert.Nil(t, err)
+		assert.True(t, bytes.Equal(data, copied))
+		assert.NoDirExists(t, "~")
+		assert.NoDirExists(t, filepath.Join(home, "copy.bin"+chunkedPartsSuffix))
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		_, err := NewChunkedTask("a", "host::module/b", 2, RsyncOptions{})
+		assert.Equal(t, ErrChunkedDestination, err)
+		_, err = NewChunkedTask("a", "b", 0, RsyncOptions{})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSplitRemote(t *testing.T) {
+	host, path, remote := splitRemote("user@host:/data/file")
+	assert.True(t, remote)
+	assert.Equal(t, "user@host", host)
+	assert.Equal(t, "/data/file", path)
+
+	for _, destination := range []string{"/data/file", "./a:b", "relative/a:b", ":file", `C:\data\file`} {
+		_, path, remote = splitRemote(destination)
+		assert.False(t, remote, destination)
+		assert.Equal(t, destination, path)
+	}
+}
+
+func TestRemoteQuote(t *testing.T) {
+	assert.Equal(t, `'/data/it'\''s'`, remoteQuote("/data/it's"))
+	assert.Equal(t, `~/'backup/file'`, remoteQuote("~/backup/file"))
+	assert.Equal(t, `'~user/file'`, remoteQuote("~user/file"))
+}
@@ -0,0 +1,140 @@
+package grsync
+
+import (
+	"bytes"
+	"strings"
+)
+
+// LineCategory classifies output lines of rsync. Categories can be combined into a set with |
+type LineCategory uint
+
+const (
+	// LineProgress are progress lines printed by --progress or --info=progress2
+	LineProgress LineCategory = 1 << iota
+	// LineFile are file names and per-file events printed by -v, --itemize-changes or --out-format
+	LineFile
+	// LineInfo are status and summary lines like "sending incremental file list" and the --stats block
+	LineInfo
+	// LineDebug are the diagnostic lines printed with -vv and above or --debug
+	LineDebug
+	// LineWarning are warnings and errors rsync reports on stderr
+	LineWarning
+
+	// AllLines contains every category
+	AllLines = LineProgress | LineFile | LineInfo | LineDebug | LineWarning
+)
+
+var infoPrefixes = byteStrings(
+	"sending incremental file list", "receiving incremental file list",
+	"sending file list", "receiving file list", "building file list",
+	"created directory", "sent ", "total size is", "Number of ", "Total ", "Literal data:",
+	"Matched data:", "File list ", "done", "skipping non-regular file", "skipping directory",
+)
+
+var debugPrefixes = byteStrings(
+	"[sender]", "[receiver]", "[generator]", "[Receiver]", "[Generator]", "[server]", "[client]",
+	"delta-transmission", "opening connection", "total: matches=", "hiding ", "showing ",
+	"excluding ", "including ", "recv_", "send_", "generate_", "server_", "client_", "match_report",
+	"deleting in ", "expand file_list", "uid ", "gid ", "set uid", "chunk[", "adding ", "get_local_name",
+	"seeding ", "protect ", "risk ", "gen mapped", "recv mapped", "send mapped", "renaming ",
+	"pushing ", "popping ", "[pid ", "msg checking", "executing ", "Client ", "Server ",
+	"(Client) ", "(Server) ",
+)
+
+// warningPrefixes start the warnings and errors of rsync, which stay on stderr with --msgs2stderr
+var warningPrefixes = []string{
+	"rsync:", "rsync error:", "rsync warning:", "file has vanished:", "@ERROR", "ERROR:", "WARNING:",
+	"IO error", "cannot delete", "Deletions stopped",
+}
+
+var (
+	fileEventMarker = []byte(fileEventPrefix)
+	uptodateSuffix  = []byte(" is uptodate")
+	skiplistMarker  = []byte(" is in a skiplist")
+	speedMarker     = []byte("/s")
+)
+
+func byteStrings(s ...string) [][]byte {
+	b := make([][]byte, len(s))
+	for i := range s {
+		b[i] = []byte(s[i])
+	}
+	return b
+}
+
+// ClassifyLine returns the category of a line rsync printed on stdout or, if stderr is true, on stderr
+func ClassifyLine(line string, stderr bool) LineCategory {
+	return classifyLine([]byte(line), stderr)
+}
+
+// classifyLine is ClassifyLine for lines read from rsync, it doesn't allocate
+func classifyLine(line []byte, stderr bool) LineCategory {
+	trimmed := bytes.TrimSpace(line)
+
+	for _, prefix := range debugPrefixes {
+		if bytes.HasPrefix(trimmed, prefix) {
+			return LineDebug
+		}
+	}
+	if bytes.HasSuffix(trimmed, uptodateSuffix) || bytes.Contains(trimmed, skiplistMarker) {
+		return LineDebug
+	}
+	if stderr {
+		return LineWarning
+	}
+
+	if bytes.HasPrefix(line, fileEventMarker) {
+		return LineFile
+	}
+	if len(trimmed) == 0 {
+		return LineInfo
+	}
+	if isProgressLine(trimmed) {
+		return LineProgress
+	}
+	if _, _, ok := parseScanCount(trimmed); ok {
+		return LineInfo
+	}
+	for _, prefix := range infoPrefixes {
+		if bytes.HasPrefix(trimmed, prefix) {
+			return LineInfo
+		}
+	}
+	return LineFile
+}
+
+// isProgressLine reports whether line looks like `15.17G  10%   92.23MB/s    0:23:54 (xfr#1, to-chk=1/2)`
+func isProgressLine(line []byte) bool {
+	i := bytes.IndexByte(line, '%')
+	if i <= 0 || line[i-1] < '0' || line[i-1] > '9' {
+		return false
+	}
+	return bytes.Contains(line, speedMarker) || bytes.Contains(line, checkField)
+}
+
+// SetLogCategories selects the categories of output lines stored in Log, by default AllLines
+func (t *Task) SetLogCategories(categories LineCategory) {
+	t.mutex.Lock()
+	t.logCategories = categories
+	t.mutex.Unlock()
+}
+
+// SetForwardCategories selects the categories of output lines passed on to the logger, the Errors
+// channel and OnStderrLine callbacks, by default AllLines. Progress lines are only logged as
+// progress samples
+func (t *Task) SetForwardCategories(categories LineCategory) {
+	t.mutex.Lock()
+	t.forwardCategories = categories
+	t.mutex.Unlock()
+}
+
+// isWarningLine reports whether a line rsync printed on stderr is a warning or an error rather than
+// one of the messages moved there by --msgs2stderr
+func isWarningLine(line string) bool {
+	for _, prefix := range warningPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
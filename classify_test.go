@@ -0,0 +1,96 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		stderr   bool
+		category LineCategory
+	}{
+		{"         15.17G  10%   92.23MB/s    0:23:54", false, LineProgress},
+		{"      1.05M 100%  659.30kB/s    0:00:01 (xfr#5, ir-chk=3641/3679)", false, LineProgress},
+		{"dir/file.txt", false, LineFile},
+		{"100% of files", false, LineFile},
+		{"deleting old/file", false, LineFile},
+		{fileEventPrefix + ">f+++++++++ 10 a", false, LineFile},
+		{"sending incremental file list", false, LineInfo},
+		{"sent 1.23K bytes  received 35 bytes  2.54K bytes/sec", false, LineInfo},
+		{"total size is 1.02M  speedup is 827.83", false, LineInfo},
+		{"Number of files: 3 (reg: 2, dir: 1)", false, LineInfo},
+		{"", false, LineInfo},
+		{"delta-transmission disabled for local transfer or --whole-file", false, LineDebug},
+		{"file.txt is uptodate", false, LineDebug},
+		{"[sender] make_file(dir,*,0)", false, LineDebug},
+		{"total: matches=0  hash_hits=0  false_alarms=0 data=1048576", false, LineDebug},
+		{`file has vanished: "/a"`, true, LineWarning},
+		{"rsync error: some files/attrs were not transferred (code 23)", true, LineWarning},
+		{"[sender] expand file_list pointer array to 524288 bytes, did move", true, LineDebug},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.category, ClassifyLine(test.line, test.stderr), test.line)
+	}
+}
+
+func TestTaskLogCategories(t *testing.T) {
+	script := `echo "sending incremental file list"
+echo "delta-transmission disabled for local transfer or --whole-file"
+echo "a.txt"
+printf "      1.05M 100%%  659.30kB/s    0:00:01 (xfr#1, to-chk=0/1)\n"
+echo "warning" >&2`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetLogCategories(LineFile | LineWarning)
+	task.SetForwardCategories(LineInfo)
+
+	var forwarded []string
+	task.OnStderrLine(func(line string) { forwarded = append(forwarded, line) })
+
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "a.txt\n", task.Log().Stdout)
+	assert.Equal(t, "warning\n", task.Log().Stderr)
+	assert.Empty(t, forwarded)
+	assert.Equal(t, 100, task.State().Progress)
+}
+
+func TestIsWarningLine(t *testing.T) {
+	assert.True(t, isWarningLine(`rsync: [sender] link_stat "/src/missing" failed: No such file or directory (2)`))
+	assert.True(t, isWarningLine("rsync error: some files/attrs were not transferred (code 23) at main.c(1338) [sender=3.2.7]"))
+	assert.True(t, isWarningLine(`file has vanished: "/src/tmp"`))
+	assert.False(t, isWarningLine("sending incremental file list"))
+	assert.False(t, isWarningLine(fileEventPrefix+">f+++++++++ 1024 file"))
+	assert.False(t, isWarningLine("      1.00M   50%    1.00MB/s    0:00:01 (xfr#1, to-chk=1/2)"))
+}
+
+func TestTaskMsgs2Stderr(t *testing.T) {
+	runner := &cannedRunner{
+		stderr: "sending incremental file list\n" +
+			fileEventPrefix + ">f+++++++++ 1024 file\n" +
+			"      1.00M   50%    1.00MB/s    0:00:01 (xfr#1, to-chk=1/2)\r      2.00M  100%    1.00MB/s    0:00:00 (xfr#2, to-chk=0/2)\n" +
+			"file has vanished: \"/src/tmp\"\n" +
+			"Number of files: 2 (reg: 2)\n" +
+			"sent 2,097,152 bytes  received 35 bytes  1,398,124.67 bytes/sec\n",
+	}
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{Msgs2Stderr: true, OutBuf: "L"})
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	var events []FileEvent
+	task.OnFileEvent(func(event FileEvent) { events = append(events, event) })
+	assert.Nil(t, task.Run())
+
+	assert.Contains(t, runner.args, "--msgs2stderr")
+	assert.Contains(t, runner.args, "--outbuf=L")
+	assert.Len(t, events, 1)
+	assert.Equal(t, "file", events[0].Path)
+	assert.Equal(t, 100, task.State().Progress)
+	assert.Equal(t, 2, task.Stats().Files)
+	assert.Equal(t, int64(2097152), task.Summary().BytesSent)
+	assert.Len(t, task.FileErrors(), 1)
+	assert.NotContains(t, task.Log().Stderr, "sending incremental file list")
+	assert.Contains(t, task.Log().Stdout, "sending incremental file list")
+}
@@ -0,0 +1,178 @@
+package grsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrCleanupNoPartialDir is the cleanup error of cancelled tasks without a partial dir. With
+	// only --partial the partial files replace the destination files and can't be told apart
+	ErrCleanupNoPartialDir = errors.New("cleaning up partial files needs RsyncOptions.PartialDir or DelayUpdates")
+	// ErrCleanupDaemon is the cleanup error of cancelled tasks with a rsync daemon destination,
+	// which can't run commands
+	ErrCleanupDaemon = errors.New("partial files can't be cleaned up on rsync daemons")
+)
+
+// delayUpdatesDir is the partial dir rsync uses for --delay-updates without --partial-dir
+const delayUpdatesDir = ".~tmp~"
+
+// CleanupResult is the outcome of the cleanup of a cancelled run, see SetCleanupOnCancel
+type CleanupResult struct {
+	// PartialDir is the partial dir the partial files were removed from
+	PartialDir string `json:"partialDir,omitempty"`
+	// Removed are the removed partial files of local destinations, remote ones aren't listed
+	Removed []string `json:"removed,omitempty"`
+	// Error is empty if the cleanup succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// SetCleanupOnCancel makes cancelled runs remove the partial files they left at the destination,
+// over the remote shell for remote destinations. Only the partial files of the files rsync named in
+// the run are removed, along with relative partial dirs left empty, so the files of other runs or
+// tasks sharing an absolute PartialDir are kept. The outcome is reported in Result.Cleanup
+func (t *Task) SetCleanupOnCancel(enabled bool) {
+	t.mutex.Lock()
+	t.cleanupOnCancel = enabled
+	t.mutex.Unlock()
+}
+
+// Cleanup returns the outcome of the cleanup of the last run, nil if there was none
+func (t *Task) Cleanup() *CleanupResult {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.cleanup == nil {
+		return nil
+	}
+	cleanup := *t.cleanup
+	cleanup.Removed = append([]string(nil), cleanup.Removed...)
+	return &cleanup
+}
+
+// cleanupCancelled removes the partial files of the cancelled run
+func (t *Task) cleanupCancelled() {
+	t.mutex.Lock()
+	options := t.definition.Options
+	destination := t.destination
+	files := t.partialFiles
+	t.mutex.Unlock()
+
+	partialDir := options.PartialDir
+	if partialDir == "" && options.DelayUpdates {
+		partialDir = delayUpdatesDir
+	}
+	cleanup := CleanupResult{PartialDir: partialDir}
+	var err error
+	switch {
+	case partialDir == "":
+		err = ErrCleanupNoPartialDir
+	case isDaemonPath(destination):
+		err = ErrCleanupDaemon
+	default:
+		if host, path, remote := splitRemote(destination); remote {
+			err = removeRemotePartialFiles(options.remoteShell(), host, path, partialDir, files)
+		} else {
+			cleanup.Removed, err = removePartialFiles(resolvePath(options.WorkDir, destination), partialDir, files)
+		}
+	}
+
+	if err != nil {
+		cleanup.Error = err.Error()
+		t.logEvent(slog.LevelWarn, "cleaning up partial files failed", slog.String("error", err.Error()))
+	} else {
+		t.logEvent(slog.LevelInfo, "partial files cleaned up", slog.String("partialDir", partialDir))
+	}
+	t.mutex.Lock()
+	t.cleanup = &cleanup
+	t.mutex.Unlock()
+}
+
+// trackPartialFile remembers the file a line of rsync names, which may leave a partial file if the
+// run is cancelled. The mutex must be held
+func (t *Task) trackPartialFile(line []byte, category LineCategory) {
+	if !t.cleanupOnCancel || category != LineFile {
+		return
+	}
+	switch {
+	case bytes.HasPrefix(line, fileEventMarker):
+		if event, ok := t.parseFileEvent(string(line)); ok && event.Op != FileDeleted && !strings.HasSuffix(event.Path, "/") {
+			t.partialFiles = append(t.partialFiles, event.Path)
+		}
+	case !bytes.HasPrefix(line, deletingPrefix) && !bytes.HasPrefix(line, itemizedDeletingPrefix) && !bytes.HasSuffix(line, []byte("/")):
+		t.partialFiles = append(t.partialFiles, unescapeName(string(line)))
+	}
+}
+
+// partialFilePaths returns the paths rsync keeps the partial files of files in, below destination
+// for a relative partialDir. An absolute partialDir holds the partial files by their name
+func partialFilePaths(destination, partialDir string, files []string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, file := range files {
+		dir, name := path.Split(file)
+		partial := path.Join(partialDir, name)
+		if !path.IsAbs(partialDir) && !filepath.IsAbs(partialDir) {
+			partial = path.Join(destination, dir, partialDir, name)
+		}
+		if !seen[partial] {
+			seen[partial] = true
+			paths = append(paths, partial)
+		}
+	}
+	return paths
+}
+
+// removePartialFiles removes the partial files of files from the local destination and returns
+// their paths. Relative partial dirs are removed once they are empty
+func removePartialFiles(destination, partialDir string, files []string) ([]string, error) {
+	relative := !filepath.IsAbs(partialDir)
+	var removed []string
+	for _, partial := range partialFilePaths(filepath.ToSlash(destination), filepath.ToSlash(partialDir), files) {
+		partial = filepath.FromSlash(partial)
+		info, err := os.Lstat(partial)
+		if os.IsNotExist(err) || err == nil && info.IsDir() {
+			continue
+		}
+		if err == nil {
+			err = os.Remove(partial)
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, partial)
+		if relative {
+			// fails if other partial files are left
+			_ = os.Remove(filepath.Dir(partial))
+		}
+	}
+	return removed, nil
+}
+
+// removeRemotePartialFiles removes the partial files of files from the remote shell destination
+// path on host. The paths are passed on the input of the remote shell, as there may be many
+func removeRemotePartialFiles(rsh, host, destination, partialDir string, files []string) error {
+	if destination == "" {
+		destination = "."
+	}
+	paths := partialFilePaths(destination, partialDir, files)
+	if len(paths) == 0 {
+		return nil
+	}
+	command := "xargs -0 rm -f --"
+	if !strings.HasPrefix(partialDir, "/") {
+		command = `xargs -0 sh -c 'for f; do rm -f -- "$f" || exit 1; rmdir -- "${f%/*}" 2>/dev/null; done; exit 0' sh`
+	}
+	cmd := remoteCommand(rsh, host, command)
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cleanup %s:%s: %w: %s", host, destination, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelRun runs the task until it is cancelled
+func cancelRun(t *testing.T, task *Task) {
+	done := make(chan error)
+	go func() { done <- task.Run() }()
+	assert.Eventually(t, func() bool { return task.Status() == TaskRunning }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, task.Cancel())
+	assert.NotNil(t, <-done)
+}
+
+func TestCleanupOnCancel(t *testing.T) {
+	t.Run("removes local partial files", func(t *testing.T) {
+		destination := t.TempDir()
+		for _, dir := range []string{"a/.rsync-partial", "b/.rsync-partial", "c/tmp/.rsync-partial"} {
+			assert.Nil(t, os.MkdirAll(filepath.Join(destination, dir), 0755))
+		}
+		for _, file := range []string{"a/.rsync-partial/file", "b/.rsync-partial/file", "b/.rsync-partial/other", "c/tmp/.rsync-partial/kept"} {
+			assert.Nil(t, os.WriteFile(filepath.Join(destination, file), []byte("half"), 0644))
+		}
+
+		script := `echo "a/file"; echo "b/file"; echo "c/tmp/"; exec sleep 10`
+		task, err := NewTask("a", destination, false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), PartialDir: ".rsync-partial"})
+		assert.Nil(t, err)
+		task.SetCleanupOnCancel(true)
+		cancelRun(t, task)
+
+		cleanup := task.result(nil).Cleanup
+		if assert.NotNil(t, cleanup) {
+			assert.Empty(t, cleanup.Error)
+			assert.Equal(t, []string{filepath.Join(destination, "a/.rsync-partial/file"), filepath.Join(destination, "b/.rsync-partial/file")}, cleanup.Removed)
+		}
+		assert.NoDirExists(t, filepath.Join(destination, "a/.rsync-partial"))
+		// the partial files of other runs are kept
+		assert.FileExists(t, filepath.Join(destination, "b/.rsync-partial/other"))
+		assert.FileExists(t, filepath.Join(destination, "c/tmp/.rsync-partial/kept"))
+	})
+
+	t.Run("keeps synced dirs named like the partial dir", func(t *testing.T) {
+		destination := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(destination, "a/tmp"), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(destination, "a/tmp/data"), []byte("synced"), 0644))
+
+		script := `echo "a/tmp/data"; exec sleep 10`
+		task, err := NewTask("a", destination, false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), PartialDir: "tmp"})
+		assert.Nil(t, err)
+		task.SetCleanupOnCancel(true)
+		cancelRun(t, task)
+
+		assert.Empty(t, task.Cleanup().Removed)
+		assert.FileExists(t, filepath.Join(destination, "a/tmp/data"))
+	})
+
+	t.Run("removes the own files of an absolute partial dir", func(t *testing.T) {
+		partialDir := t.TempDir()
+		for _, file := range []string{"file", "other"} {
+			assert.Nil(t, os.WriteFile(filepath.Join(partialDir, file), []byte("half"), 0644))
+		}
+
+		task, err := NewTask("a", t.TempDir(), false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "a/file"; exec sleep 10`), PartialDir: partialDir})
+		assert.Nil(t, err)
+		task.SetCleanupOnCancel(true)
+		cancelRun(t, task)
+
+		assert.Equal(t, []string{filepath.Join(partialDir, "file")}, task.Cleanup().Removed)
+		assert.DirExists(t, partialDir)
+		assert.NoFileExists(t, filepath.Join(partialDir, "file"))
+		assert.FileExists(t, filepath.Join(partialDir, "other"))
+	})
+
+	t.Run("removes remote partial files", func(t *testing.T) {
+		destination := t.TempDir()
+		assert.Nil(t, os.MkdirAll(filepath.Join(destination, "a/.~tmp~"), 0755))
+		assert.Nil(t, os.MkdirAll(filepath.Join(destination, "b/.~tmp~"), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(destination, "a/.~tmp~/it's"), []byte("half"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(destination, "b/.~tmp~/other"), []byte("half"), 0644))
+		// runs the remote command locally
+		rsh := fakeRsync(t, `shift; sh -c "$1"`)
+
+		script := `echo "a/it's"; exec sleep 10`
+		task, err := NewTask("a", "host:"+destination, false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Rsh: rsh, DelayUpdates: true})
+		assert.Nil(t, err)
+		task.SetCleanupOnCancel(true)
+		cancelRun(t, task)
+
+		assert.Equal(t, &CleanupResult{PartialDir: ".~tmp~"}, task.Cleanup())
+		assert.NoDirExists(t, filepath.Join(destination, "a/.~tmp~"))
+		assert.FileExists(t, filepath.Join(destination, "b/.~tmp~/other"))
+	})
+
+	t.Run("needs a partial dir", func(t *testing.T) {
+		task, err := NewTask("a", t.TempDir(), false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exec sleep 10")})
+		assert.Nil(t, err)
+		task.SetCleanupOnCancel(true)
+		cancelRun(t, task)
+
+		assert.Equal(t, ErrCleanupNoPartialDir.Error(), task.Cleanup().Error)
+	})
+
+	t.Run("only after cancel", func(t *testing.T) {
+		task, err := NewTask("a", t.TempDir(), false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23"), PartialDir: ".rsync-partial"})
+		assert.Nil(t, err)
+		task.SetCleanupOnCancel(true)
+		assert.NotNil(t, task.Run())
+		assert.Nil(t, task.Cleanup())
+	})
+}
@@ -0,0 +1,240 @@
+// Command grsync runs rsync through the grsync package and renders its progress
+//
+//	grsync [flags] SOURCE DESTINATION
+//	grsync -config tasks.yaml [-task NAME] [flags] [SOURCE DESTINATION]
+//
+// The exit code is 0 on success and rsync's exit code if rsync failed. The own codes of grsync are
+// above the ones of rsync: 64 (EX_USAGE) for invalid usage and invalid task definitions, 70
+// (EX_SOFTWARE) for any other error and 130 if grsync was interrupted
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/ByteSizedMarius/grsync/grsyncconfig"
+)
+
+const (
+	exitOK          = 0
+	exitUsage       = 64
+	exitError       = 70
+	exitInterrupted = 130
+)
+
+// progressInterval is the interval in which the progress bar is redrawn
+const progressInterval = 200 * time.Millisecond
+
+func main() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, signals))
+}
+
+// stringList is a flag which may be given multiple times
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// run executes the command and returns its exit code; receiving from signals cancels the task
+func run(args []string, stdout, stderr io.Writer, signals <-chan os.Signal) int {
+	flags := flag.NewFlagSet("grsync", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: grsync [flags] SOURCE DESTINATION")
+		fmt.Fprintln(stderr, "       grsync -config FILE [-task NAME] [flags] [SOURCE DESTINATION]")
+		flags.PrintDefaults()
+	}
+
+	var (
+		definition grsync.Definition
+		excludes   stringList
+		includes   stringList
+	)
+	config := flags.String("config", "", "read the task definition from a YAML, TOML or JSON `file`")
+	name := flags.String("task", "", "`name` of the task to run if the config file defines several")
+	archive := flags.Bool("a", false, "archive mode")
+	recursive := flags.Bool("r", false, "recurse into directories")
+	compress := flags.Bool("z", false, "compress file data during the transfer")
+	dryRun := flags.Bool("n", false, "perform a trial run with no changes made")
+	del := flags.Bool("delete", false, "delete extraneous files from the destination")
+	oneFileSystem := flags.Bool("x", false, "don't cross filesystem boundaries")
+	preset := flags.String("preset", "", "tune the delta-transfer algorithm for a `link`: lan or wan")
+	rsh := flags.String("e", "", "remote shell `command` to use")
+	binary := flags.String("rsync", "", "`path` of the rsync binary")
+	rsyncPath := flags.String("rsync-path", "", "`program` to run as rsync on the remote machine, e.g. \"sudo rsync\"")
+	sudo := flags.Bool("sudo", false, "run the remote rsync through sudo, which must not ask for a password")
+	bwlimit := flags.Int("bwlimit", 0, "limit the bandwidth to `KBPS`")
+	retries := flags.Int("retries", 0, "retry a failed transfer `n` times")
+	retryDelay := flags.Duration("retry-delay", 10*time.Second, "time to wait before retrying")
+	lock := flags.Bool("lock", false, "refuse to run if another grsync syncs to the same destination")
+	logFile := flags.String("log-file", "", "append the raw rsync output to `file`")
+	quiet := flags.Bool("q", false, "don't render the progress bar")
+	flags.Var(&excludes, "exclude", "exclude files matching `pattern`, may be repeated")
+	flags.Var(&includes, "include", "don't exclude files matching `pattern`, may be repeated")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	var policy grsync.RetryPolicy
+	if *config != "" {
+		task, err := loadTask(*config, *name)
+		if err != nil {
+			fmt.Fprintln(stderr, "grsync:", err)
+			return exitUsage
+		}
+		if definition, err = task.Definition(); err != nil {
+			fmt.Fprintln(stderr, "grsync:", err)
+			return exitUsage
+		}
+		policy = task.RetryPolicy()
+	}
+
+	switch flags.NArg() {
+	case 0:
+		if *config == "" {
+			flags.Usage()
+			return exitUsage
+		}
+	case 2:
+		definition.Source, definition.Destination = flags.Arg(0), flags.Arg(1)
+	default:
+		flags.Usage()
+		return exitUsage
+	}
+	if definition.Source == "" || definition.Destination == "" {
+		fmt.Fprintln(stderr, "grsync: source and destination are required")
+		return exitUsage
+	}
+
+	// flags only ever enable options, so they can't accidentally unset options of the config file
+	options := &definition.Options
+	options.Archive = options.Archive || *archive
+	options.Recursive = options.Recursive || *recursive
+	options.Compress = options.Compress || *compress
+	options.DryRun = options.DryRun || *dryRun
+	options.Delete = options.Delete || *del
+	options.OneFileSystem = options.OneFileSystem || *oneFileSystem
+	options.RemoteSudo = options.RemoteSudo || *sudo
+	options.Exclude = append(options.Exclude, excludes...)
+	options.Include = append(options.Include, includes...)
+	if *preset != "" {
+		options.Preset = grsync.Preset(*preset)
+	}
+	if *rsh != "" {
+		options.Rsh = *rsh
+	}
+	if *binary != "" {
+		options.RsyncBinaryPath = *binary
+	}
+	if *rsyncPath != "" {
+		options.RsyncPath = *rsyncPath
+	}
+	if *bwlimit > 0 {
+		options.BandwidthLimit = *bwlimit
+	}
+	definition.LockDestination = definition.LockDestination || *lock
+	definition.DiscardLog = true
+
+	task, err := definition.NewTask()
+	if err != nil {
+		fmt.Fprintln(stderr, "grsync:", err)
+		return exitUsage
+	}
+	task.SetStderrWriter(stderr)
+	if *retries > 0 {
+		policy = grsync.RetryPolicy{MaxAttempts: *retries + 1, Delay: *retryDelay, Backoff: 2}
+	}
+	task.SetRetryPolicy(policy)
+	if *logFile != "" {
+		task.LogToFile(grsync.LogFileOptions{Path: *logFile})
+	}
+
+	var bar *progressBar
+	if !*quiet {
+		bar = &progressBar{out: stdout}
+	}
+	return execute(task, bar, stderr, signals)
+}
+
+// loadTask returns the task called name from the config file, name may be empty if the file defines a single task
+func loadTask(path, name string) (grsyncconfig.Task, error) {
+	config, err := grsyncconfig.Load(path)
+	if err != nil {
+		return grsyncconfig.Task{}, err
+	}
+
+	if name == "" {
+		if len(config.Tasks) != 1 {
+			return grsyncconfig.Task{}, fmt.Errorf("%s defines %d tasks, select one with -task", path, len(config.Tasks))
+		}
+		return config.Tasks[0], nil
+	}
+	for _, task := range config.Tasks {
+		if task.Name == name {
+			return task, nil
+		}
+	}
+	return grsyncconfig.Task{}, fmt.Errorf("%s defines no task %q", path, name)
+}
+
+// execute runs the task, rendering its progress to bar if it is non-nil
+func execute(task *grsync.Task, bar *progressBar, stderr io.Writer, signals <-chan os.Signal) int {
+	result := make(chan error, 1)
+	go func() { result <- task.Run() }()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	interrupted := false
+	for {
+		select {
+		case <-signals:
+			interrupted = true
+			_ = task.Cancel()
+		case <-ticker.C:
+			if bar != nil {
+				bar.render(task.State())
+			}
+		case err := <-result:
+			if bar != nil {
+				bar.render(task.State())
+				bar.finish()
+			}
+			return exitCode(err, interrupted, stderr)
+		}
+	}
+}
+
+func exitCode(err error, interrupted bool, stderr io.Writer) int {
+	if err == nil {
+		return exitOK
+	}
+	if interrupted {
+		fmt.Fprintln(stderr, "grsync: interrupted")
+		return exitInterrupted
+	}
+
+	fmt.Fprintln(stderr, "grsync:", err)
+	if code := grsync.ExitCodeOf(err); code > 0 {
+		return int(code)
+	}
+	return exitError
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeRsync(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "rsync")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	return path
+}
+
+func runCommand(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr, nil)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		argsFile := filepath.Join(t.TempDir(), "args")
+		binary := fakeRsync(t, `echo "$@" > `+argsFile+`
+echo "      1.50M  100%   10.00MB/s    0:00:00"`)
+
+		code, stdout, _ := runCommand("-rsync", binary, "-rsync-path", "sudo rsync", "-a", "-delete", "-x", "-preset", "lan", "-exclude", "*.tmp", "-exclude", "cache/", "src/", "dst/")
+		assert.Equal(t, exitOK, code)
+		assert.Contains(t, stdout, "[##############################]  100%  1.50M  10.00MB/s  0:00:00")
+		assert.True(t, strings.HasSuffix(stdout, "\n"))
+
+		args, err := ioutil.ReadFile(argsFile)
+		assert.Nil(t, err)
+		assert.Contains(t, string(args), "--rsync-path sudo rsync")
+		assert.Contains(t, string(args), "--archive")
+		assert.Contains(t, string(args), "--delete")
+		assert.Contains(t, string(args), "--one-file-system")
+		assert.Contains(t, string(args), "--whole-file")
+		assert.Contains(t, string(args), "--exclude=*.tmp --exclude=cache/")
+		assert.Contains(t, string(args), "src/ dst/")
+	})
+
+	t.Run("sudo", func(t *testing.T) {
+		argsFile := filepath.Join(t.TempDir(), "args")
+		code, _, _ := runCommand("-q", "-rsync", fakeRsync(t, `echo "$@" > `+argsFile), "-sudo", "host:/etc/", "dst/")
+		assert.Equal(t, exitOK, code)
+
+		args, err := ioutil.ReadFile(argsFile)
+		assert.Nil(t, err)
+		assert.Contains(t, string(args), "--rsync-path sudo -n rsync")
+	})
+
+	t.Run("rsync exit code", func(t *testing.T) {
+		code, _, stderr := runCommand("-q", "-rsync", fakeRsync(t, "echo 'rsync error: some files could not be transferred' >&2; exit 23"), "a", "b")
+		assert.Equal(t, 23, code)
+		assert.Contains(t, stderr, "rsync error: some files could not be transferred")
+		assert.Contains(t, stderr, "grsync: exit status 23")
+
+		// rsync's own syntax and protocol errors are told apart from the ones of grsync
+		code, _, _ = runCommand("-q", "-rsync", fakeRsync(t, "exit 1"), "a", "b")
+		assert.Equal(t, 1, code)
+		code, _, _ = runCommand("-q", "-rsync", fakeRsync(t, "exit 2"), "a", "b")
+		assert.Equal(t, 2, code)
+		assert.Equal(t, 64, exitUsage)
+		assert.Equal(t, 70, exitError)
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		counter := filepath.Join(t.TempDir(), "counter")
+		binary := fakeRsync(t, `echo x >> `+counter+`
+[ "$(wc -l < `+counter+`)" -ge 2 ]`)
+
+		code, _, _ := runCommand("-q", "-retries", "2", "-retry-delay", "1ms", "-rsync", binary, "a", "b")
+		assert.Equal(t, exitOK, code)
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		dir := t.TempDir()
+		config := filepath.Join(dir, "tasks.yaml")
+		binary := fakeRsync(t, `case "$*" in *" from to") exit 0;; esac; exit 1`)
+		assert.Nil(t, ioutil.WriteFile(config, []byte(`tasks:
+  - name: first
+    source: from
+    destination: to
+    options:
+      rsyncBinaryPath: `+binary+`
+  - name: second
+    source: other
+    destination: to
+    options:
+      rsyncBinaryPath: `+binary+`
+`), 0644))
+
+		code, _, _ := runCommand("-q", "-config", config, "-task", "first")
+		assert.Equal(t, exitOK, code)
+		code, _, _ = runCommand("-q", "-config", config, "-task", "second")
+		assert.Equal(t, 1, code)
+
+		code, _, stderr := runCommand("-q", "-config", config)
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr, "select one with -task")
+		code, _, _ = runCommand("-q", "-config", config, "-task", "third")
+		assert.Equal(t, exitUsage, code)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		code, _, stderr := runCommand("only-source")
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr, "usage: grsync")
+
+		code, _, _ = runCommand("-unknown", "a", "b")
+		assert.Equal(t, exitUsage, code)
+
+		code, _, stderr = runCommand("-config", filepath.Join(t.TempDir(), "missing.json"))
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr, "missing.json")
+
+		code, _, _ = runCommand("-h")
+		assert.Equal(t, exitOK, code)
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		var stderr bytes.Buffer
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			signals <- os.Interrupt
+		}()
+
+		code := run([]string{"-q", "-rsync", fakeRsync(t, "exec sleep 5"), "a", "b"}, ioutil.Discard, &stderr, signals)
+		assert.Equal(t, exitInterrupted, code)
+		assert.Contains(t, stderr.String(), "interrupted")
+	})
+}
+
+func TestFormatProgress(t *testing.T) {
+	assert.Equal(t, "[------------------------------]    0%", formatProgress(grsync.State{}))
+	assert.Equal(t, "[#########---------------------]   30%  15.17G  92.23MB/s  0:23:54",
+		formatProgress(grsync.State{Progress: 30, DownloadedTotal: "15.17G", Speed: "92.23MB/s", TimeRemaining: "0:23:54"}))
+	assert.Equal(t, "[##############################]  100%", formatProgress(grsync.State{Progress: 120}))
+	assert.Equal(t, "[###---------------------------]   10%  1.00M  scanning 2000 files",
+		formatProgress(grsync.State{Progress: 10, DownloadedTotal: "1.00M", Scanning: true, FilesScanned: 2000}))
+}
+
+func TestProgressBar(t *testing.T) {
+	var out bytes.Buffer
+	bar := &progressBar{out: &out}
+	bar.render(grsync.State{Progress: 50, Speed: "100.00MB/s"})
+	bar.render(grsync.State{Progress: 50, Speed: "100.00MB/s"})
+	bar.render(grsync.State{Progress: 60, Speed: "1.00kB/s"})
+	bar.finish()
+
+	lines := strings.Split(out.String(), "\r")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, len(lines[1]), len(strings.TrimSuffix(lines[2], "\n")))
+	assert.True(t, strings.HasSuffix(out.String(), "\n"))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ByteSizedMarius/grsync"
+)
+
+// barWidth is the number of characters of the bar itself
+const barWidth = 30
+
+// progressBar redraws a single line showing the state of a task
+type progressBar struct {
+	out   io.Writer
+	last  string
+	drawn bool
+}
+
+func (b *progressBar) render(state grsync.State) {
+	line := formatProgress(state)
+	if line == b.last {
+		return
+	}
+
+	// pad with spaces to overwrite a longer previous line
+	padding := len(b.last) - len(line)
+	if padding < 0 {
+		padding = 0
+	}
+	fmt.Fprintf(b.out, "\r%s%s", line, strings.Repeat(" ", padding))
+	b.last = line
+	b.drawn = true
+}
+
+func (b *progressBar) finish() {
+	if b.drawn {
+		fmt.Fprintln(b.out)
+	}
+}
+
+// formatProgress renders e.g. "[#########---------------------]  30%  15.17G  92.23MB/s  0:23:54"
+func formatProgress(state grsync.State) string {
+	progress := state.Progress
+	if progress < 0 {
+		progress = 0
+	}
+	if progress > 100 {
+		progress = 100
+	}
+	filled := progress * barWidth / 100
+
+	parts := []string{
+		"[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]",
+		fmt.Sprintf("%3d%%", progress),
+	}
+	for _, part := range []string{state.DownloadedTotal, state.Speed, state.TimeRemaining} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if state.Scanning {
+		parts = append(parts, fmt.Sprintf("scanning %d files", state.FilesScanned))
+	}
+	return strings.Join(parts, "  ")
+}
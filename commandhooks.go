@@ -0,0 +1,200 @@
+package grsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// HookPhase tells whether a command hook runs before or after the transfer
+type HookPhase string
+
+const (
+	// HookPre runs before the transfer, once the lock of the task is acquired
+	HookPre HookPhase = "pre"
+	// HookPost runs after the transfer, also if it or a pre hook failed
+	HookPost HookPhase = "post"
+)
+
+// HookPolicy selects what a failing command hook does to the run
+type HookPolicy int
+
+const (
+	// HookAbort fails the run with a HookError. A failing pre hook skips the remaining pre hooks
+	// and the transfer, a failing post hook fails a transfer that succeeded
+	HookAbort HookPolicy = iota
+	// HookWarn adds the failure to the warnings of the run and carries on
+	HookWarn
+)
+
+// ErrEmptyHook is returned by AddPreHook and AddPostHook for a hook without Command and Func
+var ErrEmptyHook = errors.New("hook has neither a command nor a func")
+
+// CommandHook is a command or function run around the transfer of a task, e.g. to snapshot a
+// volume, stop a database or flip a maintenance page
+type CommandHook struct {
+	// Name identifies the hook in results, warnings and errors, by default the command
+	Name string
+	// Command is the program and its arguments, run without a shell. Its environment has
+	// GRSYNC_TASK_ID and GRSYNC_PHASE and, for post hooks, GRSYNC_STATUS ("succeeded" or "failed")
+	// and GRSYNC_EXIT_CODE
+	Command []string
+	// Func is called instead of Command if set. err is nil for pre hooks and the error of the
+	// run so far for post hooks
+	Func func(task *Task, err error) error
+	// Timeout kills Command once it ran for the duration, 0 waits for it
+	Timeout time.Duration
+	Policy  HookPolicy
+}
+
+// name returns Name or the program of Command
+func (h CommandHook) name() string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case len(h.Command) > 0:
+		return h.Command[0]
+	}
+	return "func"
+}
+
+// HookResult is the record of a command hook which ran
+type HookResult struct {
+	Name     string        `json:"name"`
+	Phase    HookPhase     `json:"phase"`
+	Duration time.Duration `json:"duration"`
+	// Output is the combined stdout and stderr of Command
+	Output string `json:"output,omitempty"`
+	// Error is empty if the hook succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// HookError is the error of a run failed by a command hook with HookAbort
+type HookError struct {
+	Name  string
+	Phase HookPhase
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook %s failed: %v", e.Phase, e.Name, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// AddPreHook registers a hook run before the transfer. Pre hooks run in registration order
+func (t *Task) AddPreHook(hook CommandHook) error {
+	return t.addCommandHook(&t.preHooks, hook)
+}
+
+// AddPostHook registers a hook run after the transfer, also if it or a pre hook failed, so it
+// can undo the pre hooks. Post hooks run in registration order, and all of them run
+func (t *Task) AddPostHook(hook CommandHook) error {
+	return t.addCommandHook(&t.postHooks, hook)
+}
+
+func (t *Task) addCommandHook(hooks *[]CommandHook, hook CommandHook) error {
+	if len(hook.Command) == 0 && hook.Func == nil {
+		return ErrEmptyHook
+	}
+	t.mutex.Lock()
+	*hooks = append(*hooks, hook)
+	t.mutex.Unlock()
+	return nil
+}
+
+// HookResults returns the records of the command hooks of the current or last run
+func (t *Task) HookResults() []HookResult {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]HookResult(nil), t.hookResults...)
+}
+
+// runPreHooks runs the pre hooks until one with HookAbort fails
+func (t *Task) runPreHooks() error {
+	t.mutex.Lock()
+	hooks := append([]CommandHook(nil), t.preHooks...)
+	t.mutex.Unlock()
+
+	for _, hook := range hooks {
+		if err := t.runCommandHook(hook, HookPre, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPostHooks runs every post hook and returns the error of the run, which is err or, if the
+// run succeeded so far, the error of the first post hook with HookAbort that failed
+func (t *Task) runPostHooks(err error) error {
+	t.mutex.Lock()
+	hooks := append([]CommandHook(nil), t.postHooks...)
+	t.mutex.Unlock()
+
+	for _, hook := range hooks {
+		if hookErr := t.runCommandHook(hook, HookPost, err); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+	return err
+}
+
+// runCommandHook runs hook and records its result. It returns a HookError if the hook failed
+// with HookAbort and adds a warning if it failed with HookWarn
+func (t *Task) runCommandHook(hook CommandHook, phase HookPhase, runErr error) error {
+	started := time.Now()
+	var output []byte
+	var err error
+	if hook.Func != nil {
+		err = hook.Func(t, runErr)
+	} else {
+		output, err = t.runHookCommand(hook, phase, runErr)
+	}
+
+	t.mutex.Lock()
+	result := HookResult{Name: hook.name(), Phase: phase, Duration: time.Since(started), Output: t.redact(string(output))}
+	if err != nil {
+		result.Error = t.redact(err.Error())
+	}
+	t.hookResults = append(t.hookResults, result)
+	if err != nil && hook.Policy == HookWarn {
+		t.warnings = append(t.warnings, Warning{Kind: WarningHook, Message: fmt.Sprintf("%s hook %s failed: %s", phase, result.Name, result.Error)})
+	}
+	t.mutex.Unlock()
+
+	if err != nil && hook.Policy != HookWarn {
+		return &HookError{Name: result.Name, Phase: phase, Err: err}
+	}
+	return nil
+}
+
+// runHookCommand runs the command of hook and returns its combined output
+func (t *Task) runHookCommand(hook CommandHook, phase HookPhase, runErr error) ([]byte, error) {
+	ctx := context.Background()
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), "GRSYNC_TASK_ID="+t.ID(), "GRSYNC_PHASE="+string(phase))
+	if phase == HookPost {
+		status := "succeeded"
+		if runErr != nil {
+			status = "failed"
+		}
+		cmd.Env = append(cmd.Env, "GRSYNC_STATUS="+status, "GRSYNC_EXIT_CODE="+strconv.Itoa(int(ExitCodeOf(runErr))))
+	}
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", hook.Timeout)
+	}
+	return output, err
+}
@@ -0,0 +1,94 @@
+package grsync
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandHooks(t *testing.T) {
+	newTask := func(t *testing.T, script string) *Task {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		return task
+	}
+
+	t.Run("order and environment", func(t *testing.T) {
+		task := newTask(t, "echo transfer")
+		var order []string
+		assert.Nil(t, task.AddPreHook(CommandHook{Name: "stop", Command: []string{"sh", "-c", "echo $GRSYNC_PHASE $GRSYNC_TASK_ID"}}))
+		assert.Nil(t, task.AddPreHook(CommandHook{Func: func(task *Task, err error) error {
+			order = append(order, "func")
+			return nil
+		}}))
+		assert.Nil(t, task.AddPostHook(CommandHook{Name: "start", Command: []string{"sh", "-c", "echo $GRSYNC_STATUS $GRSYNC_EXIT_CODE"}}))
+
+		result, err := task.RunResult()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"func"}, order)
+		assert.Len(t, result.Hooks, 3)
+		assert.Equal(t, HookResult{Name: "stop", Phase: HookPre, Output: "pre " + task.ID() + "\n"}, withoutDuration(result.Hooks[0]))
+		assert.Equal(t, HookResult{Name: "func", Phase: HookPre}, withoutDuration(result.Hooks[1]))
+		assert.Equal(t, HookResult{Name: "start", Phase: HookPost, Output: "succeeded 0\n"}, withoutDuration(result.Hooks[2]))
+	})
+
+	t.Run("failing pre hook aborts", func(t *testing.T) {
+		task := newTask(t, "echo transfer")
+		assert.Nil(t, task.AddPreHook(CommandHook{Name: "snapshot", Command: []string{"sh", "-c", "echo no space; exit 3"}}))
+		assert.Nil(t, task.AddPreHook(CommandHook{Name: "skipped", Command: []string{"true"}}))
+		assert.Nil(t, task.AddPostHook(CommandHook{Name: "cleanup", Command: []string{"sh", "-c", "echo $GRSYNC_STATUS"}}))
+
+		err := task.Run()
+		var hookErr *HookError
+		assert.True(t, errors.As(err, &hookErr))
+		assert.Equal(t, "snapshot", hookErr.Name)
+		assert.Equal(t, HookPre, hookErr.Phase)
+		assert.Equal(t, ExitCode(3), ExitCodeOf(err))
+		assert.Empty(t, task.Log().Stdout)
+
+		results := task.HookResults()
+		assert.Len(t, results, 2)
+		assert.Equal(t, "no space\n", results[0].Output)
+		assert.Equal(t, "exit status 3", results[0].Error)
+		assert.Equal(t, "cleanup", results[1].Name)
+		assert.Equal(t, "failed\n", results[1].Output)
+	})
+
+	t.Run("failing post hook", func(t *testing.T) {
+		task := newTask(t, "echo transfer")
+		assert.Nil(t, task.AddPostHook(CommandHook{Name: "notify", Command: []string{"false"}, Policy: HookWarn}))
+		assert.Nil(t, task.AddPostHook(CommandHook{Name: "start", Command: []string{"false"}}))
+
+		result, err := task.RunResult()
+		assert.NotNil(t, err)
+		assert.Equal(t, "post hook start failed: exit status 1", err.Error())
+		assert.Equal(t, []Warning{{Kind: WarningHook, Message: "post hook notify failed: exit status 1"}}, result.Warnings)
+	})
+
+	t.Run("post hook keeps the error of the transfer", func(t *testing.T) {
+		task := newTask(t, "exit 23")
+		assert.Nil(t, task.AddPostHook(CommandHook{Name: "start", Command: []string{"false"}}))
+		assert.Equal(t, ExitPartial, ExitCodeOf(task.Run()))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		task := newTask(t, "echo transfer")
+		assert.Nil(t, task.AddPreHook(CommandHook{Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond, Policy: HookWarn}))
+		result, err := task.RunResult()
+		assert.Nil(t, err)
+		assert.Len(t, result.Warnings, 1)
+		assert.True(t, strings.HasPrefix(result.Warnings[0].Message, "pre hook sleep failed: timed out after 50ms"))
+	})
+
+	t.Run("empty hook", func(t *testing.T) {
+		assert.Equal(t, ErrEmptyHook, newTask(t, "").AddPreHook(CommandHook{Name: "nothing"}))
+	})
+}
+
+func withoutDuration(result HookResult) HookResult {
+	result.Duration = 0
+	return result
+}
@@ -0,0 +1,61 @@
+package grsync
+
+import (
+	"strings"
+)
+
+// Diff is the difference between a source and a destination found by Compare. Paths are relative
+// to the source and destination, directories end with a slash
+type Diff struct {
+	OnlyInSource    []string `json:"onlyInSource"`
+	OnlyInDest      []string `json:"onlyInDest"`
+	ContentDiffers  []string `json:"contentDiffers"`
+	MetadataDiffers []string `json:"metadataDiffers"`
+}
+
+// Empty reports whether the source and the destination are identical
+func (d Diff) Empty() bool {
+	return len(d.OnlyInSource) == 0 && len(d.OnlyInDest) == 0 && len(d.ContentDiffers) == 0 && len(d.MetadataDiffers) == 0
+}
+
+// Compare reports how destination differs from source without transferring anything. rsync runs
+// recursively with --dry-run, --checksum and --delete, so contents are compared by checksum and
+// missing files by a single pass. Metadata is only compared for the attributes options preserve,
+// e.g. Archive compares permissions, times, owner and group
+func Compare(source, destination string, options RsyncOptions) (Diff, error) {
+	options.DryRun = true
+	options.Comparison = CompareChecksum
+	options.SizeOnly, options.IgnoreTimes = false, false
+	options.Recursive = true
+	options.Delete = true
+
+	task, err := NewTask(source, destination, false, false, options)
+	if err != nil {
+		return Diff{}, err
+	}
+
+	var diff Diff
+	task.OnFileEvent(func(event FileEvent) {
+		diff.add(event)
+	})
+	if err = task.Run(); err != nil {
+		return Diff{}, err
+	}
+	return diff, nil
+}
+
+// add classifies the itemized change of event
+func (d *Diff) add(event FileEvent) {
+	itemize := event.Itemize
+	switch {
+	case event.Op == FileDeleted:
+		d.OnlyInDest = append(d.OnlyInDest, event.Path)
+	case len(itemize) < 3:
+	case strings.Trim(itemize[2:], "+") == "":
+		d.OnlyInSource = append(d.OnlyInSource, event.Path)
+	case itemize[2] == 'c' || (len(itemize) > 3 && itemize[3] == 's'):
+		d.ContentDiffers = append(d.ContentDiffers, event.Path)
+	case strings.Trim(itemize[2:], ". ") != "":
+		d.MetadataDiffers = append(d.MetadataDiffers, event.Path)
+	}
+}
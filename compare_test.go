@@ -0,0 +1,36 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	script := `for option in --dry-run --checksum --delete; do
+	case " $* " in *" $option "*) ;; *) exit 1;; esac
+done
+echo "sending incremental file list"
+echo "` + fileEventPrefix + `*deleting   0 stale.txt"
+echo "` + fileEventPrefix + `cd+++++++++ 4,096 new/"
+echo "` + fileEventPrefix + `>f+++++++++ 10 new/file.txt"
+echo "` + fileEventPrefix + `>fc........ 20 changed.txt"
+echo "` + fileEventPrefix + `>f.s....... 30 grown.txt"
+echo "` + fileEventPrefix + `.f..t...... 40 touched.txt"
+echo "` + fileEventPrefix + `.d...p..... 4,096 dir/"
+echo "` + fileEventPrefix + `.d          4,096 ./"`
+
+	diff, err := Compare("a/", "b/", RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Archive: true})
+	assert.Nil(t, err)
+	assert.Equal(t, Diff{
+		OnlyInSource:    []string{"new/", "new/file.txt"},
+		OnlyInDest:      []string{"stale.txt"},
+		ContentDiffers:  []string{"changed.txt", "grown.txt"},
+		MetadataDiffers: []string{"touched.txt", "dir/"},
+	}, diff)
+	assert.False(t, diff.Empty())
+	assert.True(t, Diff{}.Empty())
+
+	_, err = Compare("a/", "b/", RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+	assert.Equal(t, ExitPartial, ExitCodeOf(err))
+}
@@ -0,0 +1,67 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrComparisonConflict is returned for options selecting more than one comparison, e.g. SizeOnly
+// together with Comparison set to CompareChecksum
+var ErrComparisonConflict = errors.New("only one of --size-only, --ignore-times and --checksum can be used")
+
+// Comparison selects how rsync decides whether a file changed. RsyncOptions.Update and
+// ModifyWindow refine each of them
+type Comparison string
+
+// FATModifyWindow is the RsyncOptions.ModifyWindow for FAT and exFAT file systems, which store
+// modification times with a resolution of 2 seconds. Without it every file looks changed
+const FATModifyWindow = 1
+
+const (
+	// CompareQuick is rsync's quick check, which transfers files whose size or modification time differ
+	CompareQuick Comparison = ""
+	// CompareSizeOnly transfers files whose size differs, e.g. for camera dumps or FAT file systems
+	// whose modification times aren't reliable
+	CompareSizeOnly Comparison = "size-only"
+	// CompareChecksum transfers files whose content differs. It reads every file on both sides, but
+	// skips build artifacts that were rebuilt with the same content and a new modification time
+	CompareChecksum Comparison = "checksum"
+	// CompareIgnoreTimes transfers every file, the delta-transfer algorithm still skips unchanged data
+	CompareIgnoreTimes Comparison = "ignore-times"
+)
+
+// comparisons returns the distinct comparisons selected by Comparison and the legacy flags
+func (r RsyncOptions) comparisons() []Comparison {
+	var comparisons []Comparison
+	for _, comparison := range []struct {
+		comparison Comparison
+		set        bool
+	}{
+		{CompareSizeOnly, r.SizeOnly},
+		{CompareChecksum, r.Checksum},
+		{CompareIgnoreTimes, r.IgnoreTimes},
+	} {
+		if comparison.set || r.Comparison == comparison.comparison {
+			comparisons = append(comparisons, comparison.comparison)
+		}
+	}
+	return comparisons
+}
+
+// comparesChecksums reports whether rsync compares the checksums of the files
+func (r RsyncOptions) comparesChecksums() bool {
+	return r.Checksum || r.Comparison == CompareChecksum
+}
+
+// checkComparison rejects unknown comparisons and the selection of more than one
+func checkComparison(options RsyncOptions) error {
+	switch options.Comparison {
+	case CompareQuick, CompareSizeOnly, CompareChecksum, CompareIgnoreTimes:
+	default:
+		return fmt.Errorf("unknown comparison %q", options.Comparison)
+	}
+	if len(options.comparisons()) > 1 {
+		return ErrComparisonConflict
+	}
+	return nil
+}
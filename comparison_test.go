@@ -0,0 +1,22 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComparison(t *testing.T) {
+	assert.Contains(t, getArguments(RsyncOptions{Comparison: CompareSizeOnly}), "--size-only")
+	assert.Contains(t, getArguments(RsyncOptions{Comparison: CompareChecksum, Update: true}), "--checksum")
+	assert.Contains(t, getArguments(RsyncOptions{Comparison: CompareIgnoreTimes}), "--ignore-times")
+	assert.Equal(t, []string{"--checksum"}, getArguments(RsyncOptions{Comparison: CompareChecksum, Checksum: true}))
+
+	assert.Nil(t, checkComparison(RsyncOptions{SizeOnly: true, Update: true}))
+	assert.Equal(t, ErrComparisonConflict, checkComparison(RsyncOptions{Comparison: CompareSizeOnly, Checksum: true}))
+	assert.Equal(t, ErrComparisonConflict, checkComparison(RsyncOptions{SizeOnly: true, IgnoreTimes: true}))
+	assert.EqualError(t, checkComparison(RsyncOptions{Comparison: "mtime"}), `unknown comparison "mtime"`)
+
+	_, err := NewTask("a", "b", false, false, RsyncOptions{Comparison: CompareChecksum, IgnoreTimes: true})
+	assert.ErrorIs(t, err, ErrComparisonConflict)
+}
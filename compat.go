@@ -0,0 +1,236 @@
+package grsync
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RsyncVersion is the version of a rsync binary as reported by `rsync --version`
+type RsyncVersion struct {
+	Major, Minor, Patch int
+	// Protocol is the protocol version, 0 if it wasn't reported
+	Protocol int
+	// Capabilities are the features rsync was built with, e.g. "xattrs", "ACLs" or "iconv". Missing
+	// ones are listed with a "no " prefix by rsync 3
+	Capabilities []string
+}
+
+// String returns the version like rsync prints it, e.g. "3.2.7"
+func (v RsyncVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Legacy reports whether the version predates rsync 3.0, like the 2.6.9 Apple ships with macOS
+// or openrsync, which claims compatibility with it
+func (v RsyncVersion) Legacy() bool {
+	return v.Major < 3
+}
+
+// Supports reports whether rsync was built with capability, compared case-insensitively
+func (v RsyncVersion) Supports(capability string) bool {
+	for _, c := range v.Capabilities {
+		if strings.EqualFold(c, capability) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	versionPattern  = regexp.MustCompile(`version (\d+)\.(\d+)\.(\d+)`)
+	protocolPattern = regexp.MustCompile(`protocol version (\d+)`)
+	// detectedVersions caches the versions detected with ExecRunner by their versionCommand
+	detectedVersions sync.Map
+)
+
+// DetectVersion runs `rsync --version` for the binary, by default `rsync`. Results are cached
+func DetectVersion(binaryPath string) (RsyncVersion, error) {
+	return detectVersion(RsyncOptions{RsyncBinaryPath: binaryPath}, ExecRunner{})
+}
+
+// detectVersion runs `rsync --version` like a task with options runs rsync through runner, nil for
+// ExecRunner, so the version is the one of the binary, flavor and environment the task uses. The
+// results of ExecRunner are cached
+func detectVersion(options RsyncOptions, runner CommandRunner) (RsyncVersion, error) {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	command := versionCommand(options)
+	_, cache := runner.(ExecRunner)
+	key := fmt.Sprintf("%q %q %q %q", command.Name, command.Args, command.Env, command.Dir)
+	if command.Credential != nil {
+		key += fmt.Sprintf(" %+v", *command.Credential)
+	}
+	if version, ok := detectedVersions.Load(key); ok && cache {
+		return version.(RsyncVersion), nil
+	}
+
+	process := runner.NewProcess(command)
+	stdout, err := process.StdoutPipe()
+	if err != nil {
+		return RsyncVersion{}, err
+	}
+	if err = process.Start(); err != nil {
+		return RsyncVersion{}, err
+	}
+	out, readErr := io.ReadAll(stdout)
+	if err = process.Wait(); err == nil {
+		err = readErr
+	}
+	if err != nil {
+		return RsyncVersion{}, err
+	}
+	version, err := parseVersion(string(out))
+	if err != nil {
+		return RsyncVersion{}, err
+	}
+	if cache {
+		detectedVersions.Store(key, version)
+	}
+	return version, nil
+}
+
+// versionCommand returns the `rsync --version` command of the binary, flavor, environment and
+// credential of options
+func versionCommand(options RsyncOptions) Command {
+	name, args := "rsync", []string{"--version"}
+	if options.RsyncBinaryPath != "" {
+		name = options.RsyncBinaryPath
+	} else if options.Flavor.resolve() == FlavorWSL {
+		name, args = "wsl", []string{"rsync", "--version"}
+	}
+	return Command{
+		Name:       name,
+		Args:       args,
+		Env:        processEnv(options),
+		Dir:        options.WorkDir,
+		Credential: options.Credential,
+	}
+}
+
+// parseVersion parses the output of `rsync --version`, e.g. "rsync  version 3.2.7  protocol version 31"
+// or, for openrsync, "openrsync: protocol version 29\nrsync version 2.6.9 compatible"
+func parseVersion(out string) (RsyncVersion, error) {
+	match := versionPattern.FindStringSubmatch(out)
+	if match == nil {
+		return RsyncVersion{}, fmt.Errorf("unknown rsync version %q", out)
+	}
+	var version RsyncVersion
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	version.Patch, _ = strconv.Atoi(match[3])
+	if match = protocolPattern.FindStringSubmatch(out); match != nil {
+		version.Protocol, _ = strconv.Atoi(match[1])
+	}
+	version.Capabilities = parseCapabilities(out)
+	return version, nil
+}
+
+// parseCapabilities returns the comma separated list following "Capabilities:", which continues
+// on the indented lines below it
+func parseCapabilities(out string) []string {
+	i := strings.Index(out, "Capabilities:")
+	if i < 0 {
+		return nil
+	}
+	lines := strings.Split(out[i+len("Capabilities:"):], "\n")
+	list := lines[0]
+	for _, line := range lines[1:] {
+		if line == "" || (line[0] != ' ' && line[0] != '\t') {
+			break
+		}
+		list += "," + line
+	}
+
+	var capabilities []string
+	for _, c := range strings.Split(list, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			capabilities = append(capabilities, c)
+		}
+	}
+	return capabilities
+}
+
+// Compatibility selects the options and output formats a task uses with its rsync binary
+type Compatibility int
+
+const (
+	// CompatAuto detects the version of rsync on macOS, where the stock rsync is 2.6.9, and
+	// assumes rsync 3 elsewhere
+	CompatAuto Compatibility = iota
+	// CompatModern assumes rsync 3.0 or newer
+	CompatModern
+	// CompatLegacy avoids the options rsync 2.6.9 doesn't support, see legacyOptions
+	CompatLegacy
+)
+
+// legacyEventFormat is fileEventFormat for rsync before 3.0, which only knows --log-format
+const legacyEventFormat = "--log-format=" + fileEventPrefix + "%i %l %n"
+
+// SetCompatibility selects the compatibility mode, by default CompatAuto
+func (t *Task) SetCompatibility(compatibility Compatibility) {
+	t.mutex.Lock()
+	t.compatibility = compatibility
+	t.mutex.Unlock()
+}
+
+// legacy reports whether the task runs a rsync older than 3.0
+func (t *Task) legacy() bool {
+	t.mutex.Lock()
+	compatibility := t.compatibility
+	t.mutex.Unlock()
+
+	switch compatibility {
+	case CompatModern:
+		return false
+	case CompatLegacy:
+		return true
+	}
+	if runtime.GOOS != "darwin" {
+		return false
+	}
+	t.mutex.Lock()
+	options, runner := t.definition.Options, t.runner
+	t.mutex.Unlock()
+	version, err := detectVersion(options, runner)
+	return err == nil && version.Legacy()
+}
+
+// legacyOptions drops the options rsync 2.6.9 rejects: --info, --debug, --msgs2stderr, --outbuf,
+// --iconv, --mkpath, --contimeout, --protect-args, --no-human-readable, whose plain numbers are
+// its default, --append-verify, which is replaced by --append, and --delete-delay, which is
+// replaced by --delete-after. Bandwidth is converted to BandwidthLimit, rsync 2.6.9 only takes
+// whole units of 1024 bytes
+func legacyOptions(options RsyncOptions) RsyncOptions {
+	options.Info = ""
+	options.InfoFlags = nil
+	options.Debug = ""
+	options.DebugFlags = nil
+	options.Msgs2Stderr = false
+	options.OutBuf = ""
+	options.Iconv = ""
+	options.MkPath = false
+	options.Contimeout = 0
+	options.NoHumanReadable = false
+	if options.Bandwidth > 0 {
+		options.BandwidthLimit = options.Bandwidth.KiB()
+		options.Bandwidth = 0
+	}
+	if options.ArgProtection == ArgsSecluded {
+		options.ArgProtection = ArgsDefault
+	}
+	if options.AppendVerify {
+		options.AppendVerify = false
+		options.Append = true
+	}
+	if options.DeleteDelay || options.DeleteTiming == DeleteDelay {
+		options.DeleteDelay = false
+		options.DeleteTiming = DeleteAfter
+	}
+	return options
+}
@@ -0,0 +1,84 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	version, err := parseVersion("rsync  version 3.2.7  protocol version 31\nCopyright (C) 1996-2022 by Andrew Tridgell, Wayne Davison, and others.\n")
+	assert.Nil(t, err)
+	assert.Equal(t, RsyncVersion{Major: 3, Minor: 2, Patch: 7, Protocol: 31}, version)
+	assert.False(t, version.Legacy())
+	assert.Equal(t, "3.2.7", version.String())
+
+	version, err = parseVersion("rsync  version 2.6.9  protocol version 29\n")
+	assert.Nil(t, err)
+	assert.True(t, version.Legacy())
+
+	version, err = parseVersion("openrsync: protocol version 29\nrsync version 2.6.9 compatible\n")
+	assert.Nil(t, err)
+	assert.Equal(t, RsyncVersion{Major: 2, Minor: 6, Patch: 9, Protocol: 29}, version)
+
+	_, err = parseVersion("usage: rsync")
+	assert.NotNil(t, err)
+}
+
+func TestDetectVersion(t *testing.T) {
+	binary := fakeRsync(t, `[ "$1" = --version ] && echo "rsync  version 2.6.9  protocol version 29"`)
+	version, err := DetectVersion(binary)
+	assert.Nil(t, err)
+	assert.True(t, version.Legacy())
+
+	_, err = DetectVersion(fakeRsync(t, "exit 1"))
+	assert.NotNil(t, err)
+}
+
+func TestDetectVersionOptions(t *testing.T) {
+	command := versionCommand(RsyncOptions{Flavor: FlavorWSL, ClearEnv: true, Env: []string{"RSYNC_X=1"}, WorkDir: "/work"})
+	assert.Equal(t, "wsl", command.Name)
+	assert.Equal(t, []string{"rsync", "--version"}, command.Args)
+	assert.Contains(t, command.Env, "RSYNC_X=1")
+	assert.Equal(t, "/work", command.Dir)
+	assert.Equal(t, "/bin/rsync", versionCommand(RsyncOptions{RsyncBinaryPath: "/bin/rsync", Flavor: FlavorWSL}).Name)
+
+	// the version depends on the environment of the task
+	binary := fakeRsync(t, `echo "rsync  version ${RSYNC_X:-3}.2.7  protocol version 31"`)
+	version, err := detectVersion(RsyncOptions{RsyncBinaryPath: binary}, ExecRunner{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, version.Major)
+	version, err = detectVersion(RsyncOptions{RsyncBinaryPath: binary, Env: []string{"RSYNC_X=2"}}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, version.Major)
+
+	runner := &cannedRunner{stdout: "rsync  version 2.6.9  protocol version 29\n"}
+	version, err = detectVersion(RsyncOptions{}, runner)
+	assert.Nil(t, err)
+	assert.True(t, version.Legacy())
+	assert.Equal(t, []string{"rsync", "--version"}, runner.args)
+}
+
+func TestTaskLegacyCompatibility(t *testing.T) {
+	script := `case "$*" in *--info*|*--out-format*) exit 1;; esac
+case "$*" in *--log-format=*) ;; *) exit 1;; esac
+echo "` + fileEventPrefix + `>f+++++++++ 1048576 file"
+echo "     1048576 100%   10.00MB/s    0:00:00 (xfer#1, to-check=0/2)"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Info: "progress2"})
+	assert.Nil(t, err)
+	task.SetCompatibility(CompatLegacy)
+	task.EnableManifest()
+	assert.Nil(t, task.Run())
+
+	state := task.State()
+	assert.Equal(t, 100, state.Progress)
+	assert.Equal(t, int64(1048576), state.BytesTransferred)
+	assert.Equal(t, 1, state.FilesTransferred)
+	assert.Equal(t, 2, state.FilesTotal)
+	assert.Equal(t, "file", task.Manifest()[0].Path)
+
+	assert.Equal(t, RsyncOptions{Append: true}, legacyOptions(RsyncOptions{Info: "progress2", InfoFlags: OutputFlags{"stats": 2}, Debug: "del", MkPath: true, Contimeout: 10, ArgProtection: ArgsSecluded, Iconv: "UTF-8-MAC,UTF-8", AppendVerify: true}))
+	assert.Equal(t, RsyncOptions{DeleteTiming: DeleteAfter}, legacyOptions(RsyncOptions{DeleteDelay: true}))
+	assert.Equal(t, RsyncOptions{DeleteTiming: DeleteAfter}, legacyOptions(RsyncOptions{DeleteTiming: DeleteDelay}))
+}
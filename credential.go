@@ -0,0 +1,61 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"runtime"
+	"strconv"
+)
+
+// ErrCredentialUnsupported is returned for a Credential on Windows
+var ErrCredentialUnsupported = errors.New("running rsync as another user is not supported on windows")
+
+// Credential is the local user and groups the rsync process runs as, e.g. for a backup agent
+// running as root which transfers the files of its tenants as unprivileged users. The current
+// process needs the privileges to switch to them, usually root or CAP_SETUID and CAP_SETGID
+type Credential struct {
+	UID uint32
+	GID uint32
+	// Groups are the supplementary groups, the process has none if empty
+	Groups []uint32
+}
+
+// LookupCredential returns the credential of the local user with the name or uid username, with
+// its primary group and supplementary groups
+func LookupCredential(username string) (*Credential, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		if u, err = user.LookupId(username); err != nil {
+			return nil, err
+		}
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid %q of %s", u.Uid, username)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q of %s", u.Gid, username)
+	}
+
+	credential := &Credential{UID: uint32(uid), GID: uint32(gid)}
+	groups, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if id, err := strconv.ParseUint(group, 10, 32); err == nil && uint32(id) != credential.GID {
+			credential.Groups = append(credential.Groups, uint32(id))
+		}
+	}
+	return credential, nil
+}
+
+// checkCredential returns an error if the options run rsync as another user where it isn't supported
+func checkCredential(options RsyncOptions) error {
+	if options.Credential != nil && runtime.GOOS == "windows" {
+		return ErrCredentialUnsupported
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package grsync
+
+import (
+	"syscall"
+)
+
+// sysProcAttr returns the attributes starting a process with the credential, nil for a nil credential
+func (c *Credential) sysProcAttr() *syscall.SysProcAttr {
+	if c == nil {
+		return nil
+	}
+	return &syscall.SysProcAttr{Credential: &syscall.Credential{
+		Uid:    c.UID,
+		Gid:    c.GID,
+		Groups: append([]uint32(nil), c.Groups...),
+	}}
+}
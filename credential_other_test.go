@@ -0,0 +1,17 @@
+//go:build !windows
+
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecRunnerCredential(t *testing.T) {
+	process := ExecRunner{}.NewProcess(Command{Name: "true", Credential: &Credential{UID: 1, GID: 2, Groups: []uint32{3}}}).(*execProcess)
+	assert.Equal(t, uint32(1), process.SysProcAttr.Credential.Uid)
+	assert.Equal(t, uint32(2), process.SysProcAttr.Credential.Gid)
+	assert.Equal(t, []uint32{3}, process.SysProcAttr.Credential.Groups)
+	assert.Nil(t, ExecRunner{}.NewProcess(Command{Name: "true"}).(*execProcess).SysProcAttr)
+}
@@ -0,0 +1,46 @@
+package grsync
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupCredential(t *testing.T) {
+	current, err := user.Current()
+	assert.Nil(t, err)
+	credential, err := LookupCredential(current.Username)
+	assert.Nil(t, err)
+	assert.Equal(t, current.Uid, strconv.Itoa(int(credential.UID)))
+	assert.Equal(t, current.Gid, strconv.Itoa(int(credential.GID)))
+	assert.NotContains(t, credential.Groups, credential.GID)
+
+	byID, err := LookupCredential(current.Uid)
+	assert.Nil(t, err)
+	assert.Equal(t, credential, byID)
+
+	_, err = LookupCredential("grsync-no-such-user")
+	assert.NotNil(t, err)
+}
+
+func TestTaskCredential(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users needs root")
+	}
+	// the script must be reachable by the user, unlike the directories of t.TempDir
+	dir, err := os.MkdirTemp("", "grsync-credential-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.Chmod(dir, 0755))
+	binary := filepath.Join(dir, "rsync")
+	assert.Nil(t, os.WriteFile(binary, []byte("#!/bin/sh\nid -u; id -g\n"), 0755))
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: binary, Credential: &Credential{UID: 65534, GID: 65534}})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "65534\n65534\n", task.Log().Stdout)
+}
@@ -0,0 +1,10 @@
+package grsync
+
+import (
+	"syscall"
+)
+
+// sysProcAttr returns nil, checkCredential rejects credentials on Windows
+func (c *Credential) sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
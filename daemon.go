@@ -0,0 +1,62 @@
+package grsync
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Module is a module a rsync daemon lists
+type Module struct {
+	Name    string `json:"name"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// modulePattern matches a line of a module listing, rsync pads the name to 15 characters
+var modulePattern = regexp.MustCompile(`^(\S+) *\t(.*)$`)
+
+// ListModules returns the modules the daemon at url lists, e.g. "rsync://mirror.example.org/" or
+// "mirror.example.org::". The daemon's message of the day is suppressed
+func ListModules(url string, options RsyncOptions) ([]Module, error) {
+	options.ListOnly = true
+	options.NoMotd = true
+
+	task, err := NewTask(url, "", false, false, options)
+	if err != nil {
+		return nil, err
+	}
+	task.DiscardLog()
+	var modules []Module
+	task.AddParser(LineParserFunc(func(line string, _ func(key, value string)) {
+		if match := modulePattern.FindStringSubmatch(line); match != nil {
+			modules = append(modules, Module{Name: match[1], Comment: strings.TrimSpace(match[2])})
+		}
+	}))
+	if err := task.Run(); err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// BrowseModule lists the entries of the directory path in the daemon module at url, e.g.
+// BrowseModule("rsync://mirror.example.org/debian", "dists/stable", RsyncOptions{}), without
+// descending into subdirectories. The Include, Exclude and Filter rules of options select the
+// entries on the daemon, e.g. Exclude: []string{"*.iso"}. The directory itself isn't returned
+func BrowseModule(url, path string, options RsyncOptions) ([]ListEntry, error) {
+	options.NoMotd = true
+
+	dir := strings.TrimSuffix(url, "/") + "/"
+	if path = strings.Trim(path, "/"); path != "" {
+		dir += path + "/"
+	}
+
+	var entries []ListEntry
+	err := list(dir, options, func(entry ListEntry) {
+		if entry.Name != "." {
+			entries = append(entries, entry)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
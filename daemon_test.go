@@ -0,0 +1,50 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListModules(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	script := `echo "$@" > ` + argsFile + `
+printf 'debian         \tDebian archive\n'
+printf 'ubuntu         \t\n'`
+
+	modules, err := ListModules("rsync://mirror.example.org/", RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	assert.Equal(t, []Module{{Name: "debian", Comment: "Debian archive"}, {Name: "ubuntu"}}, modules)
+
+	args, err := ioutil.ReadFile(argsFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(args), "--no-motd")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(args)), "--list-only rsync://mirror.example.org/"))
+
+	_, err = ListModules("rsync://mirror.example.org/", RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 5")})
+	assert.Equal(t, ExitStartClient, ExitCodeOf(err))
+}
+
+func TestBrowseModule(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	script := `echo "$@" > ` + argsFile + `
+echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 ."
+echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 bookworm"
+echo "-rw-r--r--          1.23K 2023/01/02 10:11:13 README"`
+
+	entries, err := BrowseModule("rsync://mirror.example.org/debian/", "/dists", RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Exclude: []string{"*.iso"}})
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "bookworm", entries[0].Name)
+	assert.True(t, entries[0].IsDir())
+	assert.Equal(t, int64(1230), entries[1].Size)
+
+	args, err := ioutil.ReadFile(argsFile)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(args), "--recursive")
+	assert.Contains(t, string(args), "--exclude=*.iso")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(args)), " rsync://mirror.example.org/debian/dists/"))
+}
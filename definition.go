@@ -0,0 +1,45 @@
+package grsync
+
+// Definition describes everything needed to build a Task. Unlike a Task, which
+// wraps a single rsync process, a Definition can be used to create any number of tasks
+type Definition struct {
+	// ID is used as the ID of the created task, a random ID is generated if empty
+	ID          string       `json:"id,omitempty"`
+	Source      string       `json:"source"`
+	Destination string       `json:"destination"`
+	UseSshPass  bool         `json:"useSshPass"`
+	CreateDir   bool         `json:"createDir"`
+	Options     RsyncOptions `json:"options"`
+	// Config relaxes the options forced by the task, see NewTaskWithConfig
+	Config TaskConfig `json:"config"`
+
+	// LockDestination guards the destination with a DestinationLock while the task runs
+	LockDestination bool `json:"lockDestination"`
+	// DiscardLog stops the task from accumulating its output in memory, see Task.DiscardLog
+	DiscardLog bool `json:"discardLog"`
+	// Labels are attached to the created task, see Task.SetLabels
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NewTask returns new rsync task built from the definition
+func (d Definition) NewTask() (*Task, error) {
+	task, err := NewTaskWithConfig(d.Source, d.Destination, d.UseSshPass, d.CreateDir, d.Options, d.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.ID != "" {
+		task.SetID(d.ID)
+	}
+	task.definition.ID = d.ID
+	task.definition.LockDestination = d.LockDestination
+	task.definition.DiscardLog = d.DiscardLog
+	task.SetLabels(d.Labels)
+	if d.DiscardLog {
+		task.DiscardLog()
+	}
+	if d.LockDestination {
+		task.SetLock(NewDestinationLock(d.Destination))
+	}
+	return task, nil
+}
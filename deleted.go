@@ -0,0 +1,65 @@
+package grsync
+
+import (
+	"bytes"
+)
+
+var (
+	deletingPrefix         = []byte("deleting ")
+	itemizedDeletingPrefix = []byte("*deleting ")
+)
+
+// Deleted returns the destination paths rsync deleted during the current or last run, e.g. because
+// of RsyncOptions.Delete. rsync only reports them with Verbose or FileEvents
+func (t *Task) Deleted() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string(nil), t.deleted...)
+}
+
+// recordDeleted adds the path of a line like `deleting path` or `*deleting   path` to the deleted
+// paths and reports whether line was one. The mutex must be held
+func (t *Task) recordDeleted(line []byte) bool {
+	switch {
+	case bytes.HasPrefix(line, deletingPrefix):
+		t.deleted = append(t.deleted, unescapeName(string(line[len(deletingPrefix):])))
+	case bytes.HasPrefix(line, itemizedDeletingPrefix):
+		// the itemized string is padded to the width of the others
+		t.deleted = append(t.deleted, unescapeName(string(bytes.TrimLeft(line[len(itemizedDeletingPrefix):], " "))))
+	case bytes.HasPrefix(line, fileEventMarker) && bytes.HasPrefix(line[len(fileEventMarker):], itemizedDeletingPrefix):
+		if event, ok := t.parseFileEvent(string(line)); ok {
+			t.deleted = append(t.deleted, event.Path)
+		}
+	case bytes.HasPrefix(line, fileEventMarker) && t.outFormat != nil:
+		// a custom format may print the itemized changes anywhere
+		event, ok := t.parseFileEvent(string(line))
+		if !ok || event.Op != FileDeleted {
+			return false
+		}
+		t.deleted = append(t.deleted, event.Path)
+	default:
+		return false
+	}
+	return true
+}
+
+// DeleteExtraneous removes the files and directories of destination that don't exist in source
+// without transferring anything: rsync runs recursively with --existing, --ignore-existing and
+// --delete. Source usually ends with a slash, like for any recursive transfer, and the filters and
+// delete timing of options apply. With DryRun nothing is removed. It returns the deleted paths
+// relative to destination, also those deleted before an error
+func DeleteExtraneous(source, destination string, options RsyncOptions) ([]string, error) {
+	options.Recursive = true
+	options.Existing = true
+	options.IgnoreExisting = true
+	options.Delete = true
+	// rsync reports deletions with -v
+	options.Verbose = true
+
+	task, err := NewTask(source, destination, false, false, options)
+	if err != nil {
+		return nil, err
+	}
+	err = task.Run()
+	return task.Deleted(), err
+}
@@ -0,0 +1,50 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskDeleted(t *testing.T) {
+	script := `echo "sending incremental file list"
+echo "deleting old/file.txt"
+echo "deleting old/"
+echo "*deleting   stale.txt"
+echo "::grsync-file:: *deleting   0 event.txt"
+echo "::grsync-file:: >f+++++++++ 5 new.txt"
+echo "deleting in ."
+echo "new.txt"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Delete: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, []string{"old/file.txt", "old/", "stale.txt", "event.txt"}, task.Deleted())
+
+	assert.Nil(t, task.Run())
+	assert.Len(t, task.Deleted(), 4)
+}
+
+func TestDeleteExtraneous(t *testing.T) {
+	script := `echo "$@" > "$(dirname "$0")/args"
+echo "sending incremental file list"
+echo "deleting old/file.txt"
+echo "deleting old/"
+echo "rsync: delete_file: unlink(locked.txt) failed: Permission denied (13)" >&2
+exit 23`
+	path := fakeRsync(t, script)
+
+	deleted, err := DeleteExtraneous("/src/", "/dst/", RsyncOptions{RsyncBinaryPath: path, DryRun: true})
+	assert.Equal(t, ExitPartial, ExitCodeOf(err))
+	assert.Equal(t, []string{"old/file.txt", "old/"}, deleted)
+
+	out, err := os.ReadFile(filepath.Join(filepath.Dir(path), "args"))
+	assert.Nil(t, err)
+	args := strings.Fields(string(out))
+	for _, arg := range []string{"--recursive", "--existing", "--ignore-existing", "--delete", "--verbose", "--dry-run"} {
+		assert.Contains(t, args, arg)
+	}
+}
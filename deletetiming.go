@@ -0,0 +1,61 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDeleteTimingConflict is returned for options selecting more than one delete timing, e.g.
+// DeleteBefore together with DeleteTiming set to DeleteAfter
+var ErrDeleteTimingConflict = errors.New("only one of --delete-before, --delete-during, --delete-delay and --delete-after can be used")
+
+// DeleteTiming selects when the receiver deletes extraneous files. Each timing implies --delete
+type DeleteTiming string
+
+const (
+	// DeleteDefault leaves the timing to rsync, which deletes during the transfer since 3.0
+	DeleteDefault DeleteTiming = ""
+	// DeleteBefore deletes before the transfer, which frees space on a full destination first but
+	// scans the whole tree before anything is copied
+	DeleteBefore DeleteTiming = "before"
+	// DeleteDuring deletes directory by directory while transferring
+	DeleteDuring DeleteTiming = "during"
+	// DeleteDelay finds the deletions during the transfer and deletes after it, so the destination
+	// keeps its old files until the new ones are in place
+	DeleteDelay DeleteTiming = "delay"
+	// DeleteAfter deletes after the transfer in an extra pass over the tree. Like DeleteDelay it
+	// keeps the old files until the transfer is done
+	DeleteAfter DeleteTiming = "after"
+)
+
+// deleteTimings returns the distinct timings selected by DeleteTiming and the legacy flags
+func (r RsyncOptions) deleteTimings() []DeleteTiming {
+	var timings []DeleteTiming
+	for _, timing := range []struct {
+		timing DeleteTiming
+		set    bool
+	}{
+		{DeleteBefore, r.DeleteBefore},
+		{DeleteDuring, r.DeleteDuring},
+		{DeleteDelay, r.DeleteDelay},
+		{DeleteAfter, r.DeleteAfter},
+	} {
+		if timing.set || r.DeleteTiming == timing.timing {
+			timings = append(timings, timing.timing)
+		}
+	}
+	return timings
+}
+
+// checkDeleteTiming rejects unknown timings and the selection of more than one
+func checkDeleteTiming(options RsyncOptions) error {
+	switch options.DeleteTiming {
+	case DeleteDefault, DeleteBefore, DeleteDuring, DeleteDelay, DeleteAfter:
+	default:
+		return fmt.Errorf("unknown delete timing %q", options.DeleteTiming)
+	}
+	if len(options.deleteTimings()) > 1 {
+		return ErrDeleteTimingConflict
+	}
+	return nil
+}
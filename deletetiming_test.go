@@ -0,0 +1,31 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteTiming(t *testing.T) {
+	assert.Contains(t, getArguments(RsyncOptions{DeleteTiming: DeleteDelay}), "--delete-delay")
+	assert.Contains(t, getArguments(RsyncOptions{DeleteTiming: DeleteBefore, DeleteExcluded: true}), "--delete-before")
+
+	// the same timing selected twice is passed once
+	args := getArguments(RsyncOptions{DeleteTiming: DeleteAfter, DeleteAfter: true})
+	count := 0
+	for _, arg := range args {
+		if arg == "--delete-after" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+
+	assert.Nil(t, checkDeleteTiming(RsyncOptions{DeleteTiming: DeleteAfter, DeleteAfter: true, DeleteExcluded: true}))
+	assert.Nil(t, checkDeleteTiming(RsyncOptions{DeleteDelay: true}))
+	assert.Equal(t, ErrDeleteTimingConflict, checkDeleteTiming(RsyncOptions{DeleteTiming: DeleteBefore, DeleteAfter: true}))
+	assert.Equal(t, ErrDeleteTimingConflict, checkDeleteTiming(RsyncOptions{DeleteDuring: true, DeleteDelay: true}))
+	assert.EqualError(t, checkDeleteTiming(RsyncOptions{DeleteTiming: "later"}), `unknown delete timing "later"`)
+
+	_, err := NewTask("a", "b", false, false, RsyncOptions{DeleteBefore: true, DeleteTiming: DeleteDelay})
+	assert.ErrorIs(t, err, ErrDeleteTimingConflict)
+}
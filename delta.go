@@ -0,0 +1,51 @@
+package grsync
+
+import "fmt"
+
+// MaxBlockSize is the largest BlockSize rsync 3 accepts
+const MaxBlockSize = 128 << 10
+
+// Preset tunes the delta-transfer algorithm of RsyncOptions for the link between source and
+// destination. Options set explicitly take precedence over the preset
+type Preset string
+
+const (
+	// PresetNone leaves the delta-transfer algorithm to rsync, which copies local files whole and
+	// sends deltas to remote destinations
+	PresetNone Preset = ""
+	// PresetLAN copies files whole, without the rolling checksum. On a fast network reading the
+	// destination file to find matching blocks costs more than sending the file again
+	PresetLAN Preset = "lan"
+	// PresetWAN sends deltas and compresses them, which saves bandwidth on slow links. rsync picks
+	// a block size around the square root of the file size; for large files changed in place, like
+	// database or VM images, a larger BlockSize up to MaxBlockSize reduces the checksum overhead,
+	// a smaller one finds more matches in files with scattered small changes
+	PresetWAN Preset = "wan"
+)
+
+// apply returns options with the settings of the preset
+func (p Preset) apply(options RsyncOptions) (RsyncOptions, error) {
+	switch p {
+	case PresetNone:
+	case PresetLAN:
+		if !options.NoWholeFile {
+			options.WholeFile = true
+		}
+	case PresetWAN:
+		if !options.WholeFile {
+			options.NoWholeFile = true
+		}
+		options.Compress = true
+	default:
+		return options, fmt.Errorf("unknown preset %q", p)
+	}
+	return options, nil
+}
+
+// checkBlockSize rejects block sizes rsync doesn't accept
+func checkBlockSize(options RsyncOptions) error {
+	if options.BlockSize < 0 || options.BlockSize > MaxBlockSize {
+		return fmt.Errorf("invalid block size %d, the maximum is %d", options.BlockSize, MaxBlockSize)
+	}
+	return nil
+}
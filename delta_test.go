@@ -0,0 +1,40 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreset(t *testing.T) {
+	options, err := PresetLAN.apply(RsyncOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, RsyncOptions{WholeFile: true}, options)
+
+	options, err = PresetWAN.apply(RsyncOptions{BlockSize: 65536})
+	assert.Nil(t, err)
+	assert.Equal(t, RsyncOptions{NoWholeFile: true, Compress: true, BlockSize: 65536}, options)
+
+	// explicit options take precedence
+	options, _ = PresetLAN.apply(RsyncOptions{NoWholeFile: true})
+	assert.False(t, options.WholeFile)
+	options, _ = PresetWAN.apply(RsyncOptions{WholeFile: true})
+	assert.False(t, options.NoWholeFile)
+
+	_, err = Preset("dialup").apply(RsyncOptions{})
+	assert.EqualError(t, err, `unknown preset "dialup"`)
+
+	runner := &cannedRunner{}
+	_, err = newRsync("a", "b", false, false, RsyncOptions{Preset: PresetLAN}, nil, runner)
+	assert.Nil(t, err)
+	assert.Contains(t, runner.args, "--whole-file")
+}
+
+func TestBlockSize(t *testing.T) {
+	assert.Contains(t, getArguments(RsyncOptions{NoWholeFile: true}), "--no-whole-file")
+	assert.Nil(t, checkBlockSize(RsyncOptions{BlockSize: MaxBlockSize}))
+	assert.EqualError(t, checkBlockSize(RsyncOptions{BlockSize: MaxBlockSize + 1}), "invalid block size 131073, the maximum is 131072")
+
+	_, err := NewRsync("a", "b", false, false, RsyncOptions{BlockSize: -1})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,80 @@
+package grsync
+
+import (
+	"os"
+)
+
+// DockerRunner is a CommandRunner running rsync inside a container with `docker exec -i`, so the
+// source and destination of a task are paths in the container. No TTY is allocated, it would
+// mangle the output. Variables that the task adds to the environment, like its locale and
+// RsyncOptions.Env, are passed with -e and the working directory with -w. Note that
+// Task.CreateDir still creates the destination on the host
+type DockerRunner struct {
+	// Container is the name or ID of a running container
+	Container string
+	// User runs rsync as another user of the container
+	User string
+	// Docker is the docker client, by default `docker`. Podman works the same way
+	Docker string
+	// Runner runs the docker client, by default ExecRunner
+	Runner CommandRunner
+}
+
+// NewProcess returns a process running cmd in the container
+func (r DockerRunner) NewProcess(cmd Command) Process {
+	args := []string{"exec", "-i"}
+	if r.User != "" {
+		args = append(args, "-u", r.User)
+	}
+	if cmd.Dir != "" {
+		args = append(args, "-w", cmd.Dir)
+	}
+	for _, variable := range addedEnv(cmd.Env) {
+		args = append(args, "-e", variable)
+	}
+	args = append(args, r.Container, cmd.Name)
+
+	runner := r.Runner
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	return runner.NewProcess(Command{Name: r.docker(), Args: append(args, cmd.Args...)})
+}
+
+func (r DockerRunner) docker() string {
+	if r.Docker == "" {
+		return "docker"
+	}
+	return r.Docker
+}
+
+// addedEnv returns the variables of env that aren't inherited from the current environment
+func addedEnv(env []string) []string {
+	inherited := make(map[string]bool)
+	for _, variable := range os.Environ() {
+		inherited[variable] = true
+	}
+	var added []string
+	for _, variable := range env {
+		if !inherited[variable] {
+			added = append(added, variable)
+		}
+	}
+	return added
+}
+
+// DockerRsh returns a remote shell for RsyncOptions.Rsh that reaches containers named as the host
+// of a path, e.g. "mycontainer:/data/", to copy between the host and a container running rsync.
+// docker is the client, by default `docker`, and user the user rsync runs as in the container.
+// Paths must not contain a user like "user@mycontainer:/data/", docker exec doesn't accept the -l
+// rsync passes for it
+func DockerRsh(docker, user string) string {
+	if docker == "" {
+		docker = "docker"
+	}
+	rsh := RemoteShell{Command: docker, Args: []string{"exec", "-i"}}
+	if user != "" {
+		rsh.Args = append(rsh.Args, "-u", user)
+	}
+	return rsh.String()
+}
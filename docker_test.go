@@ -0,0 +1,29 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerRunner(t *testing.T) {
+	t.Setenv("GRSYNC_INHERITED", "1")
+	runner := &cannedRunner{}
+	task, err := NewTask("/data/", "/backup/", false, false, RsyncOptions{
+		Env:     []string{"RSYNC_PASSWORD=secret"},
+		WorkDir: "/data",
+	})
+	assert.Nil(t, err)
+	task.SetRunner(DockerRunner{Container: "app", User: "root", Runner: runner})
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, []string{"docker", "exec", "-i", "-u", "root", "-w", "/data", "-e", "LC_ALL=C", "-e", "LANG=C",
+		"-e", "RSYNC_PASSWORD=secret", "app", "rsync"}, runner.args[:15])
+	assert.NotContains(t, runner.args, "GRSYNC_INHERITED=1")
+	assert.Equal(t, []string{"/data/", "/backup/"}, runner.args[len(runner.args)-2:])
+}
+
+func TestDockerRsh(t *testing.T) {
+	assert.Equal(t, "docker exec -i", DockerRsh("", ""))
+	assert.Equal(t, "podman exec -i -u www-data", DockerRsh("podman", "www-data"))
+}
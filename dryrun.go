@@ -0,0 +1,19 @@
+package grsync
+
+import (
+	"time"
+)
+
+// countDryRun adds a file a dry run would transfer to the state, like the progress lines of a
+// real run do. rsync prints no progress in dry runs, so tasks count the file events instead.
+// The mutex must be held
+func (t *Task) countDryRun(event FileEvent) {
+	if event.Op != FileSent && event.Op != FileReceived {
+		return
+	}
+	t.state.BytesTransferred += event.Size
+	t.state.CumulativeBytes = t.priorBytes + t.state.BytesTransferred
+	t.state.FilesTransferred++
+	t.state.DownloadedTotal = formatSize(float64(t.state.BytesTransferred), t.unitBase())
+	t.lastStateChange = time.Now()
+}
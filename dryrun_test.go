@@ -0,0 +1,41 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	script := `case "$*" in *--partial*) echo "unexpected --partial" >&2; exit 1 ;; esac
+echo "::grsync-file:: cd+++++++++ 4096 dir/"
+echo "::grsync-file:: >f+++++++++ 1000 dir/a.txt"
+echo "::grsync-file:: >f.st...... 2500 dir/b.txt"
+echo "::grsync-file:: .f...p..... 10 dir/c.txt"
+echo "::grsync-file:: *deleting   0 old.txt"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), DryRun: true})
+	assert.Nil(t, err)
+	var paths []string
+	task.OnFileEvent(func(event FileEvent) { paths = append(paths, event.Path) })
+	assert.Nil(t, task.Run())
+
+	state := task.State()
+	assert.True(t, state.DryRun)
+	assert.Equal(t, int64(3500), state.BytesTransferred)
+	assert.Equal(t, 2, state.FilesTransferred)
+	result := task.result(nil)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, int64(3500), result.BytesTransferred)
+	assert.Equal(t, 2, result.FilesTransferred)
+	assert.Equal(t, []string{"old.txt"}, task.Deleted())
+	assert.Equal(t, []string{"dir/", "dir/a.txt", "dir/b.txt", "dir/c.txt", "old.txt"}, paths)
+}
+
+func TestRealRunIsNotDry(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.False(t, task.State().DryRun)
+	assert.False(t, task.result(nil).DryRun)
+}
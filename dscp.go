@@ -0,0 +1,212 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrDSCPUnsupported is returned for tasks marking daemon connections with a DSCP on Windows
+var ErrDSCPUnsupported = errors.New("dscp marking is not supported on windows")
+
+// DSCP is a Differentiated Services code point from 1 to 63, which marks the packets of daemon
+// connections for the QoS policies of the network, see SocketOptions.DSCP. Zero is the default
+// best effort class
+type DSCP int
+
+const (
+	// DSCPLowerEffort is the class of traffic yielding to best effort traffic, LE of RFC 8622,
+	// suitable for backups
+	DSCPLowerEffort DSCP = 1
+	// DSCPScavenger is CS1, which older networks use for traffic below best effort
+	DSCPScavenger DSCP = 8
+	// DSCPHighThroughput is AF11, the high throughput class
+	DSCPHighThroughput DSCP = 10
+	// DSCPExpedited is EF, the low latency class
+	DSCPExpedited DSCP = 46
+)
+
+// tos returns the value of the TOS byte and of the IPv6 traffic class
+func (d DSCP) tos() int {
+	return int(d) << 2
+}
+
+// checkDSCP rejects code points outside of 0 to 63
+func checkDSCP(options RsyncOptions) error {
+	if dscp := options.SocketOptions.DSCP; dscp < 0 || dscp > 63 {
+		return fmt.Errorf("invalid dscp %d", dscp)
+	}
+	return nil
+}
+
+// startDSCPRelay starts the relay marking the daemon connection of the run if the options of the
+// task set a DSCP. --sockopts only knows the fixed IPTOS_ values, so rsync connects to the daemon
+// through a relay on the loopback interface, which dials the daemon with the DSCP set. Transfers
+// over a remote shell aren't marked, like for the other SocketOptions
+func (t *Task) startDSCPRelay() (stop func(), err error) {
+	t.mutex.Lock()
+	options := t.definition.Options
+	source, destination, err := t.expandPaths()
+	t.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if options.SocketOptions.DSCP == 0 || options.Rsh != "" || options.RemoteShell.Command != "" {
+		return func() {}, nil
+	}
+	endpoint, ok := daemonEndpoint(source, destination)
+	if !ok {
+		return func() {}, nil
+	}
+	if runtime.GOOS == "windows" {
+		return nil, ErrDSCPUnsupported
+	}
+
+	port := endpoint.Port
+	if port == 0 {
+		port = 873
+	}
+	network, loopback := "tcp", "127.0.0.1"
+	switch {
+	case options.IPv4:
+		network = "tcp4"
+	case options.IPv6:
+		network, loopback = "tcp6", "::1"
+	}
+	relay := &dscpRelay{
+		target: net.JoinHostPort(endpoint.Host, strconv.Itoa(port)),
+		dialer: net.Dialer{
+			Timeout: time.Duration(options.Contimeout) * time.Second,
+			Control: dscpControl(options.SocketOptions.DSCP),
+		},
+		network: network,
+		conns:   make(map[net.Conn]struct{}),
+	}
+	if relay.listener, err = net.Listen(network, net.JoinHostPort(loopback, "0")); err != nil {
+		return nil, err
+	}
+	go relay.serve()
+
+	t.mutex.Lock()
+	t.dscpRelay = relay.listener.Addr().(*net.TCPAddr)
+	t.mutex.Unlock()
+	return func() {
+		relay.close()
+		t.mutex.Lock()
+		t.dscpRelay = nil
+		t.mutex.Unlock()
+	}, nil
+}
+
+// daemonEndpoint returns the endpoint of source and destination which is a daemon
+func daemonEndpoint(source, destination string) (Endpoint, bool) {
+	for _, path := range []string{source, destination} {
+		if endpoint, err := ParseEndpoint(path); err == nil && endpoint.Kind == EndpointDaemon {
+			return endpoint, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+// relayed returns path connecting to the daemon through the DSCP relay of the run, if it is a
+// daemon endpoint and the run has a relay. The mutex must be held
+func (t *Task) relayed(path string) string {
+	if t.dscpRelay == nil {
+		return path
+	}
+	endpoint, err := ParseEndpoint(path)
+	if err != nil || endpoint.Kind != EndpointDaemon {
+		return path
+	}
+	endpoint.Host, endpoint.Port = t.dscpRelay.IP.String(), t.dscpRelay.Port
+	return endpoint.String()
+}
+
+// dscpRelay forwards the connections of rsync to the daemon, dialing it with the DSCP set
+type dscpRelay struct {
+	listener net.Listener
+	target   string
+	dialer   net.Dialer
+	network  string
+
+	mutex sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func (r *dscpRelay) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.forward(conn)
+		}()
+	}
+}
+
+// forward copies between conn and a new connection to the daemon until either side closes. rsync
+// reports a connection the relay can't establish as closed by the daemon
+func (r *dscpRelay) forward(conn net.Conn) {
+	defer conn.Close()
+	if !r.track(conn, true) {
+		return
+	}
+	defer r.track(conn, false)
+
+	daemon, err := r.dialer.Dial(r.network, r.target)
+	if err != nil {
+		return
+	}
+	defer daemon.Close()
+	if !r.track(daemon, true) {
+		return
+	}
+	defer r.track(daemon, false)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(daemon, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, daemon)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// track adds or removes conn from the open connections. It reports false if the relay is closed
+func (r *dscpRelay) track(conn net.Conn, open bool) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !open {
+		delete(r.conns, conn)
+		return true
+	}
+	if r.conns == nil {
+		return false
+	}
+	r.conns[conn] = struct{}{}
+	return true
+}
+
+// close stops accepting connections and closes the open ones
+func (r *dscpRelay) close() {
+	_ = r.listener.Close()
+	r.mutex.Lock()
+	for conn := range r.conns {
+		_ = conn.Close()
+	}
+	r.conns = nil
+	r.mutex.Unlock()
+	r.wg.Wait()
+}
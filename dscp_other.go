@@ -0,0 +1,26 @@
+//go:build !windows
+
+package grsync
+
+import (
+	"syscall"
+)
+
+// dscpControl returns the control function of a dialer setting the TOS byte, or the traffic class
+// of IPv6 connections, to dscp
+func dscpControl(dscp DSCP) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		controlErr := c.Control(func(fd uintptr) {
+			if network == "tcp6" {
+				err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, dscp.tos())
+			} else {
+				err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, dscp.tos())
+			}
+		})
+		if controlErr != nil {
+			return controlErr
+		}
+		return err
+	}
+}
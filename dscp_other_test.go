@@ -0,0 +1,129 @@
+//go:build !windows
+
+package grsync
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echoServer accepts connections on the loopback interface and echoes lines back
+func echoServer(t *testing.T) *net.TCPAddr {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				_, _ = conn.Write([]byte(line))
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr)
+}
+
+func TestDSCPControl(t *testing.T) {
+	addr := echoServer(t)
+	dialer := net.Dialer{Control: dscpControl(DSCPLowerEffort)}
+	conn, err := dialer.Dial("tcp4", addr.String())
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	assert.Nil(t, err)
+	var tos int
+	assert.Nil(t, raw.Control(func(fd uintptr) {
+		tos, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, tos)
+}
+
+func TestDSCPRelay(t *testing.T) {
+	addr := echoServer(t)
+	source := "rsync://user@127.0.0.1:" + strconv.Itoa(addr.Port) + "/module/data/"
+	task, err := NewTask(source, "/backup/", false, false, RsyncOptions{
+		SocketOptions: SocketOptions{DSCP: DSCPLowerEffort},
+	})
+	assert.Nil(t, err)
+
+	stop, err := task.startDSCPRelay()
+	if !assert.Nil(t, err) {
+		return
+	}
+	relayed := task.relayed(source)
+	assert.NotEqual(t, source, relayed)
+	assert.Equal(t, "/backup/", task.relayed("/backup/"))
+	endpoint, err := ParseEndpoint(relayed)
+	assert.Nil(t, err)
+	assert.Equal(t, Endpoint{Kind: EndpointDaemon, User: "user", Host: "127.0.0.1", Port: task.dscpRelay.Port, Module: "module", Path: "data/"}, endpoint)
+
+	conn, err := net.Dial("tcp4", net.JoinHostPort(endpoint.Host, strconv.Itoa(endpoint.Port)))
+	if assert.Nil(t, err) {
+		_, _ = conn.Write([]byte("@RSYNCD: 31.0\n"))
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		assert.Equal(t, "@RSYNCD: 31.0\n", line)
+		_ = conn.Close()
+	}
+
+	stop()
+	assert.Nil(t, task.dscpRelay)
+	assert.Equal(t, source, task.relayed(source))
+}
+
+func TestDSCPRelayCommand(t *testing.T) {
+	addr := echoServer(t)
+	script := `for arg in "$@"; do echo "$arg"; done`
+	source := "rsync://127.0.0.1:" + strconv.Itoa(addr.Port) + "/module/"
+	task, err := NewTask(source, "/backup/", false, false, RsyncOptions{
+		RsyncBinaryPath: fakeRsync(t, script),
+		SocketOptions:   SocketOptions{DSCP: DSCPScavenger},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+
+	log := task.Log().Stdout
+	assert.Contains(t, log, "rsync://127.0.0.1:")
+	assert.NotContains(t, log, source)
+}
+
+func TestDSCPOnlyForDaemons(t *testing.T) {
+	task, err := NewTask("host:/data/", "/backup/", false, false, RsyncOptions{
+		SocketOptions: SocketOptions{DSCP: DSCPLowerEffort},
+	})
+	assert.Nil(t, err)
+	stop, err := task.startDSCPRelay()
+	assert.Nil(t, err)
+	assert.Nil(t, task.dscpRelay)
+	stop()
+
+	task, err = NewTask("host::module/", "/backup/", false, false, RsyncOptions{
+		Rsh:           "ssh",
+		SocketOptions: SocketOptions{DSCP: DSCPLowerEffort},
+	})
+	assert.Nil(t, err)
+	stop, err = task.startDSCPRelay()
+	assert.Nil(t, err)
+	assert.Nil(t, task.dscpRelay)
+	stop()
+
+	_, err = NewTask("host::module/", "/backup/", false, false, RsyncOptions{
+		SocketOptions: SocketOptions{DSCP: 64},
+	})
+	assert.EqualError(t, err, "invalid dscp 64")
+}
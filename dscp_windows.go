@@ -0,0 +1,12 @@
+package grsync
+
+import (
+	"syscall"
+)
+
+// dscpControl fails, Windows ignores IP_TOS unless QoS policies allow it
+func dscpControl(DSCP) func(network, address string, c syscall.RawConn) error {
+	return func(string, string, syscall.RawConn) error {
+		return ErrDSCPUnsupported
+	}
+}
@@ -0,0 +1,153 @@
+package grsync
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// EndpointKind tells how rsync reaches an Endpoint
+type EndpointKind string
+
+const (
+	// EndpointLocal is a path on the local file system
+	EndpointLocal EndpointKind = "local"
+	// EndpointShell is a path on a host reached through the remote shell, e.g. "user@host:/data/"
+	EndpointShell EndpointKind = "shell"
+	// EndpointDaemon is a path in a module of a rsync daemon, e.g. "rsync://host/module/data/" or
+	// "host::module/data/"
+	EndpointDaemon EndpointKind = "daemon"
+)
+
+// Endpoint is a source or destination of rsync taken apart, see ParseEndpoint
+type Endpoint struct {
+	Kind EndpointKind `json:"kind"`
+	User string       `json:"user,omitempty"`
+	// Host is the host name or IP address, IPv6 addresses without brackets
+	Host string `json:"host,omitempty"`
+	// Port is the port of a daemon, 0 for the default one. Remote shells set their port in
+	// RsyncOptions.RemoteShell
+	Port int `json:"port,omitempty"`
+	// Module is the module of a daemon, empty to list the modules
+	Module string `json:"module,omitempty"`
+	// Path is the local path, the path on the host or the path in the module. A trailing slash
+	// is kept since it tells rsync to copy the contents of a directory
+	Path string `json:"path"`
+}
+
+// ParseEndpoint parses a source or destination the way rsync does: "rsync://" URLs and paths with
+// a double colon after the host are served by a daemon, paths with a single colon after the host
+// are reached through the remote shell and all others are local. Like rsync, a colon after a
+// slash is part of a local path. Windows drive paths like `C:\data` are local
+func ParseEndpoint(s string) (Endpoint, error) {
+	if s == "" {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q", s)
+	}
+	if url, ok := strings.CutPrefix(s, "rsync://"); ok {
+		return parseDaemonURL(s, url)
+	}
+	if isDrivePath(s) && (runtime.GOOS == "windows" || len(s) > 2 && s[2] == '\\') {
+		return Endpoint{Kind: EndpointLocal, Path: s}, nil
+	}
+
+	colon := strings.IndexByte(s, ':')
+	if colon <= 0 || strings.ContainsRune(s[:colon], '/') {
+		return Endpoint{Kind: EndpointLocal, Path: s}, nil
+	}
+
+	var e Endpoint
+	hostPart, rest := s[:colon], s[colon+1:]
+	if open := strings.IndexByte(s, '['); open >= 0 && open < colon {
+		// user@[2001:db8::1]:/data, the colons of the address don't count
+		end := strings.IndexByte(s, ']')
+		if end < open || !strings.HasPrefix(s[end+1:], ":") || strings.ContainsRune(s[:open], '/') {
+			return Endpoint{}, fmt.Errorf("invalid endpoint %q", s)
+		}
+		hostPart, rest = s[:end+1], s[end+2:]
+	}
+	if at := strings.LastIndexByte(hostPart, '@'); at >= 0 {
+		e.User, hostPart = hostPart[:at], hostPart[at+1:]
+	}
+	e.Host = strings.TrimSuffix(strings.TrimPrefix(hostPart, "["), "]")
+	if e.Host == "" {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: missing host", s)
+	}
+
+	if module, ok := strings.CutPrefix(rest, ":"); ok {
+		e.Kind = EndpointDaemon
+		e.Module, e.Path, _ = strings.Cut(module, "/")
+		return e, nil
+	}
+	e.Kind = EndpointShell
+	e.Path = rest
+	return e, nil
+}
+
+// parseDaemonURL parses the rest of a "rsync://" URL s after the scheme
+func parseDaemonURL(s, url string) (Endpoint, error) {
+	e := Endpoint{Kind: EndpointDaemon}
+	authority, path, _ := strings.Cut(url, "/")
+	if at := strings.LastIndexByte(authority, '@'); at >= 0 {
+		e.User, authority = authority[:at], authority[at+1:]
+	}
+
+	host, port := authority, ""
+	if strings.HasPrefix(authority, "[") {
+		end := strings.IndexByte(authority, ']')
+		if end < 0 {
+			return Endpoint{}, fmt.Errorf("invalid endpoint %q", s)
+		}
+		host, port = authority[1:end], authority[end+1:]
+		if port != "" && !strings.HasPrefix(port, ":") {
+			return Endpoint{}, fmt.Errorf("invalid endpoint %q", s)
+		}
+		port = strings.TrimPrefix(port, ":")
+	} else if i := strings.LastIndexByte(authority, ':'); i >= 0 {
+		host, port = authority[:i], authority[i+1:]
+	}
+	if host == "" {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: missing host", s)
+	}
+	e.Host = host
+	if port != "" {
+		var err error
+		if e.Port, err = strconv.Atoi(port); err != nil || e.Port < 1 || e.Port > 65535 {
+			return Endpoint{}, fmt.Errorf("invalid endpoint %q: invalid port %q", s, port)
+		}
+	}
+	e.Module, e.Path, _ = strings.Cut(path, "/")
+	return e, nil
+}
+
+// IsRemote reports whether the endpoint is on another host
+func (e Endpoint) IsRemote() bool {
+	return e.Kind == EndpointShell || e.Kind == EndpointDaemon
+}
+
+// String returns the endpoint as rsync expects it. Daemon endpoints are rendered as "rsync://"
+// URLs, which are the only form with a port
+func (e Endpoint) String() string {
+	host := e.Host
+	if strings.ContainsRune(host, ':') {
+		host = "[" + host + "]"
+	}
+	if e.User != "" {
+		host = e.User + "@" + host
+	}
+
+	switch e.Kind {
+	case EndpointShell:
+		return host + ":" + e.Path
+	case EndpointDaemon:
+		if e.Port != 0 {
+			host += ":" + strconv.Itoa(e.Port)
+		}
+		url := "rsync://" + host + "/" + e.Module
+		if e.Path != "" {
+			url += "/" + e.Path
+		}
+		return url
+	}
+	return e.Path
+}
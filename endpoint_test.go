@@ -0,0 +1,49 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for s, expected := range map[string]Endpoint{
+		"/data/":                             {Kind: EndpointLocal, Path: "/data/"},
+		"relative/dir":                       {Kind: EndpointLocal, Path: "relative/dir"},
+		"./a:b":                              {Kind: EndpointLocal, Path: "./a:b"},
+		`C:\data`:                            {Kind: EndpointLocal, Path: `C:\data`},
+		"host:":                              {Kind: EndpointShell, Host: "host"},
+		"user@host:/data/":                   {Kind: EndpointShell, User: "user", Host: "host", Path: "/data/"},
+		"user@[2001:db8::1]:backups":         {Kind: EndpointShell, User: "user", Host: "2001:db8::1", Path: "backups"},
+		"host::module/dir/":                  {Kind: EndpointDaemon, Host: "host", Module: "module", Path: "dir/"},
+		"[::1]::module":                      {Kind: EndpointDaemon, Host: "::1", Module: "module"},
+		"rsync://mirror.example.org/":        {Kind: EndpointDaemon, Host: "mirror.example.org"},
+		"rsync://user@host:8730/module/a/b":  {Kind: EndpointDaemon, User: "user", Host: "host", Port: 8730, Module: "module", Path: "a/b"},
+		"rsync://[2001:db8::1]:873/module/":  {Kind: EndpointDaemon, Host: "2001:db8::1", Port: 873, Module: "module"},
+		"rsync://[2001:db8::1]/module/dir/x": {Kind: EndpointDaemon, Host: "2001:db8::1", Module: "module", Path: "dir/x"},
+	} {
+		endpoint, err := ParseEndpoint(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, endpoint, s)
+	}
+
+	for _, s := range []string{"", "@:/data", "rsync://", "rsync:///module", "rsync://host:0/module", "rsync://host:port/", "rsync://[::1/module", "user@[::1]/data"} {
+		_, err := ParseEndpoint(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestEndpointString(t *testing.T) {
+	for _, s := range []string{"/data/", "user@host:/data/", "user@[2001:db8::1]:backups", "rsync://host/", "rsync://user@host:8730/module/a/b", "rsync://[::1]:873/module/dir/"} {
+		endpoint, err := ParseEndpoint(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, s, endpoint.String())
+	}
+
+	endpoint, err := ParseEndpoint("host::module/dir/")
+	assert.Nil(t, err)
+	assert.True(t, endpoint.IsRemote())
+	endpoint.Port = 8730
+	assert.Equal(t, "rsync://host:8730/module/dir/", endpoint.String())
+	assert.False(t, Endpoint{Kind: EndpointLocal, Path: "a"}.IsRemote())
+}
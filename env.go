@@ -0,0 +1,30 @@
+package grsync
+
+import (
+	"os"
+)
+
+// processEnv returns the environment of the rsync process, nil to inherit the current one
+func processEnv(options RsyncOptions) []string {
+	locale := options.Locale
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	oldArgs := options.ArgProtection == ArgsOld
+	if locale == InheritLocale && len(options.Env) == 0 && !options.ClearEnv && !oldArgs {
+		return nil
+	}
+
+	env := []string{}
+	if !options.ClearEnv {
+		env = os.Environ()
+	}
+	// later entries take precedence over earlier ones
+	if locale != InheritLocale {
+		env = append(env, "LC_ALL="+locale, "LANG="+locale)
+	}
+	if oldArgs {
+		env = append(env, "RSYNC_OLD_ARGS=1")
+	}
+	return append(env, options.Env...)
+}
@@ -0,0 +1,30 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRsyncEnv(t *testing.T) {
+	t.Setenv("GRSYNC_INHERITED", "inherited")
+	run := func(options RsyncOptions) string {
+		options.RsyncBinaryPath = fakeRsync(t, `echo "$GRSYNC_INHERITED|$RSYNC_PASSWORD|$LC_ALL"`)
+		task, err := NewTask("a", "b", false, false, options)
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+		// the log redacts the password, see Task.Redact
+		return task.Log().Stdout
+	}
+
+	assert.Equal(t, "inherited||C\n", run(RsyncOptions{}))
+	assert.Equal(t, "inherited|***|C\n", run(RsyncOptions{Env: []string{"RSYNC_PASSWORD=secret"}}))
+	assert.Equal(t, "inherited||C.UTF-8\n", run(RsyncOptions{Env: []string{"LC_ALL=C.UTF-8"}}))
+	assert.Equal(t, "|***|C\n", run(RsyncOptions{Env: []string{"RSYNC_PASSWORD=secret"}, ClearEnv: true}))
+}
+
+func TestProcessEnv(t *testing.T) {
+	assert.Nil(t, processEnv(RsyncOptions{Locale: InheritLocale}))
+	assert.Equal(t, []string{}, processEnv(RsyncOptions{Locale: InheritLocale, ClearEnv: true}))
+	assert.Equal(t, []string{"LC_ALL=C", "LANG=C", "A=b"}, processEnv(RsyncOptions{ClearEnv: true, Env: []string{"A=b"}}))
+}
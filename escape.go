@@ -0,0 +1,28 @@
+package grsync
+
+import (
+	"strings"
+)
+
+// unescapeName decodes the `\#ooo` octal escapes rsync prints for non-printable bytes in file
+// names, e.g. `caf\#303\#251` is "café" if rsync didn't consider the locale to be UTF-8
+func unescapeName(name string) string {
+	if !strings.Contains(name, `\#`) {
+		return name
+	}
+	var b strings.Builder
+	b.Grow(len(name))
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+4 < len(name) && name[i+1] == '#' && isOctal(name[i+2]) && isOctal(name[i+3]) && isOctal(name[i+4]) {
+			b.WriteByte((name[i+2]-'0')<<6 | (name[i+3]-'0')<<3 | (name[i+4] - '0'))
+			i += 4
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
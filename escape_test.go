@@ -0,0 +1,38 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnescapeName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"plain/file.txt":           "plain/file.txt",
+		`caf\#303\#251.txt`:        "café.txt",
+		`tab\#011name`:             "tab\tname",
+		`back\slash`:               `back\slash`,
+		`short\#30`:                `short\#30`,
+		`not\#899octal`:            `not\#899octal`,
+		`dir/\#342\#234\#223/file`: "dir/✓/file",
+	} {
+		assert.Equal(t, expected, unescapeName(name), name)
+	}
+}
+
+func TestTaskEscapedNames(t *testing.T) {
+	script := `printf '%s\n' '-rw-r--r--          1.02K 2023/10/07 13:19:08 caf\#303\#251.txt'
+printf '%s\n' 'deleting old-caf\#303\#251.txt'
+printf '%s\n' '` + fileEventPrefix + `>f+++++++++ 1024 new-caf\#303\#251.txt'`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	var events []FileEvent
+	task.OnFileEvent(func(event FileEvent) {
+		events = append(events, event)
+	})
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, "café.txt", task.GetFileList()[0][4])
+	assert.Equal(t, []string{"old-café.txt"}, task.Deleted())
+	assert.Equal(t, "new-café.txt", events[0].Path)
+}
@@ -0,0 +1,56 @@
+package grsync
+
+import (
+	"time"
+)
+
+// parseRemaining converts rsync's time remaining, e.g. "0:23:54", into a duration
+func parseRemaining(s string) (time.Duration, bool) {
+	var total, n time.Duration
+	colons := 0
+	digits := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ':' && digits && colons < 2:
+			total = (total + n) * 60
+			n, digits = 0, false
+			colons++
+		case isDigit(c):
+			n = n*10 + time.Duration(c-'0')
+			digits = true
+		default:
+			return 0, false
+		}
+	}
+	if colons != 2 || !digits {
+		return 0, false
+	}
+	return (total + n) * time.Second, true
+}
+
+// updateETA sets State.ETA from the time remaining reported on the current progress line or,
+// if the line had none, estimates it from the transferred bytes, the progress and the average
+// speed. The mutex must be held
+func (t *Task) updateETA(reported bool) {
+	state := t.state
+	if state.BytesProgress >= 100 {
+		// rsync prints the elapsed instead of the remaining time once a transfer is complete
+		state.ETA, state.ETAEstimated = 0, false
+		return
+	}
+	if reported {
+		if eta, ok := parseRemaining(state.TimeRemaining); ok {
+			state.ETA, state.ETAEstimated = eta, false
+			return
+		}
+	}
+
+	done, err := parseSize(state.DownloadedTotal, t.unitBase())
+	speed := t.avgSpeed.value
+	if err != nil || done <= 0 || state.BytesProgress <= 0 || speed <= 0 {
+		return
+	}
+	total := float64(done) * 100 / float64(state.BytesProgress)
+	state.ETA = time.Duration((total - float64(done)) / speed * float64(time.Second)).Round(time.Second)
+	state.ETAEstimated = true
+}
@@ -0,0 +1,46 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemaining(t *testing.T) {
+	d, ok := parseRemaining("0:23:54")
+	assert.True(t, ok)
+	assert.Equal(t, 23*time.Minute+54*time.Second, d)
+
+	d, ok = parseRemaining("12:00:01")
+	assert.True(t, ok)
+	assert.Equal(t, 12*time.Hour+time.Second, d)
+
+	for _, s := range []string{"", "0:23", "a:b:c", "??:??:??"} {
+		_, ok = parseRemaining(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestTaskETA(t *testing.T) {
+	run := func(script string) State {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetSpeedSmoothing(0)
+		assert.Nil(t, task.Run())
+		return task.State()
+	}
+
+	state := run(`echo "      5.00M   50%    1.00MB/s    0:01:40"`)
+	assert.Equal(t, 100*time.Second, state.ETA)
+	assert.False(t, state.ETAEstimated)
+
+	state = run(`echo "      5.00M   50%    1.00MB/s"`)
+	assert.Equal(t, 5*time.Second, state.ETA)
+	assert.True(t, state.ETAEstimated)
+
+	state = run(`echo "      5.00M   50%    1.00MB/s    0:01:40"
+echo "     10.00M  100%    1.00MB/s    0:00:10"`)
+	assert.Zero(t, state.ETA)
+	assert.False(t, state.ETAEstimated)
+}
@@ -0,0 +1,251 @@
+package grsync
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileEventPrefix marks the --out-format lines used to produce file events
+const fileEventPrefix = "::grsync-file:: "
+
+// fileEventFormat makes rsync print the itemized changes, the length and the name of every file
+const fileEventFormat = "--out-format=" + fileEventPrefix + "%i %l %n"
+
+// manifestEventFormat is fileEventFormat with the modification time and the checksum of every
+// file, which rsync pads with spaces if it doesn't know it
+const manifestEventFormat = "--out-format=" + fileEventPrefix + "%i %l %M [%C] %n"
+
+// FileOp is the kind of change rsync applied to a file
+type FileOp string
+
+const (
+	// FileSent is a file sent to the remote host
+	FileSent FileOp = "sent"
+	// FileReceived is a file received from the remote host
+	FileReceived FileOp = "received"
+	// FileChanged is a local change such as a created directory or symlink
+	FileChanged FileOp = "changed"
+	// FileHardLink is a hard link to another file
+	FileHardLink FileOp = "hardlink"
+	// FileAttributes is a file whose attributes changed but whose content was not transferred
+	FileAttributes FileOp = "attributes"
+	// FileDeleted is a file deleted from the destination
+	FileDeleted FileOp = "deleted"
+	// FileUnknown is a change whose itemized string couldn't be interpreted
+	FileUnknown FileOp = "unknown"
+)
+
+// FileEvent describes a file rsync finished processing
+type FileEvent struct {
+	Op FileOp `json:"op"`
+	// Itemize is the raw itemized change string, e.g. `>f+++++++++`
+	Itemize string `json:"itemize"`
+	// Size is the length of the file in bytes
+	Size int64  `json:"size"`
+	Path string `json:"path"`
+	// ModTime and Checksum are only reported while a manifest is recorded, see EnableManifest.
+	// Checksum is empty if rsync didn't compute one
+	ModTime  time.Time `json:"modTime,omitempty"`
+	Checksum string    `json:"checksum,omitempty"`
+	// Transferred is the number of bytes transferred for the file, only reported by a
+	// RsyncOptions.CustomOutFormat mapping a verb like %b to it
+	Transferred int64 `json:"transferred,omitempty"`
+}
+
+// FileEvents returns a channel receiving an event for every file rsync processed. Calling it makes the
+// task run rsync with its own --out-format, which replaces RsyncOptions.OutFormat, or with
+// RsyncOptions.CustomOutFormat prefixed by a marker if it is set. The channel must be
+// drained while the task runs unless SetBackpressure drops events, and is closed when Run returns
+func (t *Task) FileEvents() <-chan FileEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.fileEventCh == nil {
+		t.fileEventCh = make(chan FileEvent, t.backpressure.buffer())
+	}
+	return t.fileEventCh
+}
+
+// OnFileEvent registers a callback invoked for every file rsync processed, see FileEvents
+func (t *Task) OnFileEvent(callback func(FileEvent)) {
+	t.mutex.Lock()
+	t.fileEventCallbacks = append(t.fileEventCallbacks, callback)
+	t.mutex.Unlock()
+}
+
+// wantsFileEvents reports whether anybody listens for file events or a dry run counts them, t.mutex
+// must be held
+func (t *Task) wantsFileEvents() bool {
+	return t.fileEventCh != nil || len(t.fileEventCallbacks) > 0 || t.manifestEnabled || t.countsHardLinks() || t.dryRun
+}
+
+// countsHardLinks reports whether Stats.HardLinks is counted, which needs the itemized changes.
+// The mutex must be held
+func (t *Task) countsHardLinks() bool {
+	return t.definition.Options.HardLinks && t.definition.Options.Stats
+}
+
+func (t *Task) emitFileEvent(event FileEvent) {
+	t.mutex.Lock()
+	ch := t.fileEventCh
+	callbacks := t.fileEventCallbacks
+	policy := t.backpressure.Policy
+	t.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(event)
+	}
+	if ch != nil {
+		send(ch, event, policy, &t.backpressureCounters.fileEvents)
+	}
+}
+
+// closeFileEvents closes the event channel at the end of Run
+func (t *Task) closeFileEvents() {
+	t.mutex.Lock()
+	if t.fileEventCh != nil {
+		close(t.fileEventCh)
+		t.fileEventCh = nil
+	}
+	t.mutex.Unlock()
+}
+
+// parseFileEvent parses a line printed with the format of the current command, which is
+// RsyncOptions.CustomOutFormat, fileEventFormat or manifestEventFormat. The mutex must be held
+func (t *Task) parseFileEvent(line string) (FileEvent, bool) {
+	if t.outFormat == nil {
+		return parseFileEvent(line, t.manifestFormat)
+	}
+	if !strings.HasPrefix(line, fileEventPrefix) {
+		return FileEvent{}, false
+	}
+	return t.outFormat.parse(line[len(fileEventPrefix):])
+}
+
+// parseFileEvent parses a line printed with fileEventFormat, or with manifestEventFormat if manifest
+// is set. Only the latter has a modification time and checksum ahead of the path, the path of
+// fileEventFormat is kept even if it starts like them
+func parseFileEvent(line string, manifest bool) (FileEvent, bool) {
+	if !strings.HasPrefix(line, fileEventPrefix) {
+		return FileEvent{}, false
+	}
+
+	// the itemized string is padded, e.g. for `*deleting`, so skip all spaces following it
+	rest := strings.TrimPrefix(line, fileEventPrefix)
+	i := strings.IndexByte(rest, ' ')
+	if i < 0 {
+		return FileEvent{}, false
+	}
+	itemize := rest[:i]
+	rest = strings.TrimLeft(rest[i:], " ")
+
+	i = strings.IndexByte(rest, ' ')
+	if i < 0 {
+		return FileEvent{}, false
+	}
+	size, _ := strconv.ParseInt(strings.Replace(rest[:i], ",", "", -1), 10, 64)
+
+	event := FileEvent{
+		Op:      fileOp(itemize),
+		Itemize: itemize,
+		Size:    size,
+		Path:    rest[i+1:],
+	}
+	if manifest {
+		parseManifestFields(&event)
+	}
+	event.Path = unescapeName(event.Path)
+	return event, true
+}
+
+// parseManifestFields moves the modification time and checksum printed with manifestEventFormat
+// from the path of event into their fields
+func parseManifestFields(event *FileEvent) {
+	rest := event.Path
+	n := len(logMTimeLayout)
+	if len(rest) < n+3 || rest[n] != ' ' || rest[n+1] != '[' {
+		return
+	}
+	mtime, err := time.ParseInLocation(logMTimeLayout, rest[:n], time.Local)
+	if err != nil {
+		return
+	}
+	end := strings.Index(rest[n+2:], "] ")
+	if end < 0 {
+		return
+	}
+	event.ModTime = mtime
+	event.Checksum = strings.TrimSpace(rest[n+2 : n+2+end])
+	event.Path = rest[n+2+end+2:]
+}
+
+// fileOp interprets the update type of an itemized change string
+func fileOp(itemize string) FileOp {
+	if strings.HasPrefix(itemize, "*deleting") {
+		return FileDeleted
+	}
+	if itemize == "" {
+		return FileUnknown
+	}
+
+	switch itemize[0] {
+	case '<':
+		return FileSent
+	case '>':
+		return FileReceived
+	case 'c':
+		return FileChanged
+	case 'h':
+		return FileHardLink
+	case '.':
+		return FileAttributes
+	default:
+		return FileUnknown
+	}
+}
+
+// Errors returns a channel receiving every line rsync writes to stderr as it arrives, e.g. warnings
+// like "file has vanished". The channel must be drained while the task runs unless SetBackpressure drops
+// lines, and is closed when Run returns
+func (t *Task) Errors() <-chan string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stderrCh == nil {
+		t.stderrCh = make(chan string, t.backpressure.buffer())
+	}
+	return t.stderrCh
+}
+
+// OnStderrLine registers a callback invoked for every line rsync writes to stderr, see Errors
+func (t *Task) OnStderrLine(callback func(line string)) {
+	t.mutex.Lock()
+	t.stderrCallbacks = append(t.stderrCallbacks, callback)
+	t.mutex.Unlock()
+}
+
+func (t *Task) emitStderrLine(line string) {
+	t.mutex.Lock()
+	ch := t.stderrCh
+	callbacks := t.stderrCallbacks
+	policy := t.backpressure.Policy
+	t.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(line)
+	}
+	if ch != nil {
+		send(ch, line, policy, &t.backpressureCounters.errors)
+	}
+}
+
+// closeErrors closes the stderr channel at the end of Run
+func (t *Task) closeErrors() {
+	t.mutex.Lock()
+	if t.stderrCh != nil {
+		close(t.stderrCh)
+		t.stderrCh = nil
+	}
+	t.mutex.Unlock()
+}
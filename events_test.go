@@ -0,0 +1,132 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFileEvent(t *testing.T) {
+	tests := []struct {
+		line     string
+		manifest bool
+		event    FileEvent
+		ok       bool
+	}{
+		{
+			line:  fileEventPrefix + ">f+++++++++ 1024 dir/file name",
+			event: FileEvent{Op: FileReceived, Itemize: ">f+++++++++", Size: 1024, Path: "dir/file name"},
+			ok:    true,
+		},
+		{
+			line:  fileEventPrefix + "<f.st...... 1,234,567 file",
+			event: FileEvent{Op: FileSent, Itemize: "<f.st......", Size: 1234567, Path: "file"},
+			ok:    true,
+		},
+		{
+			line:  fileEventPrefix + "*deleting   0 old/file",
+			event: FileEvent{Op: FileDeleted, Itemize: "*deleting", Size: 0, Path: "old/file"},
+			ok:    true,
+		},
+		{
+			line:  fileEventPrefix + "cd+++++++++ 4096 dir/",
+			event: FileEvent{Op: FileChanged, Itemize: "cd+++++++++", Size: 4096, Path: "dir/"},
+			ok:    true,
+		},
+		{
+			line:     fileEventPrefix + ">f+++++++++ 1024 2023/10/07-13:19:08 [d41d8cd98f00b204e9800998ecf8427e] dir/file",
+			manifest: true,
+			event: FileEvent{Op: FileReceived, Itemize: ">f+++++++++", Size: 1024, Path: "dir/file",
+				ModTime: time.Date(2023, 10, 7, 13, 19, 8, 0, time.Local), Checksum: "d41d8cd98f00b204e9800998ecf8427e"},
+			ok: true,
+		},
+		{
+			line:     fileEventPrefix + "cd+++++++++ 4096 2023/10/07-13:19:08 [                                ] dir/",
+			manifest: true,
+			event:    FileEvent{Op: FileChanged, Itemize: "cd+++++++++", Size: 4096, Path: "dir/", ModTime: time.Date(2023, 10, 7, 13, 19, 8, 0, time.Local)},
+			ok:       true,
+		},
+		{
+			line:  fileEventPrefix + "hf+++++++++ 0 link => target",
+			event: FileEvent{Op: FileHardLink, Itemize: "hf+++++++++", Size: 0, Path: "link => target"},
+			ok:    true,
+		},
+		{
+			// a file name looking like the manifest fields
+			line:  fileEventPrefix + ">f+++++++++ 1024 2023/10/07-13:19:08 [x] dir/file",
+			event: FileEvent{Op: FileReceived, Itemize: ">f+++++++++", Size: 1024, Path: "2023/10/07-13:19:08 [x] dir/file"},
+			ok:    true,
+		},
+		{line: "        1.05M 100%  659.30kB/s    0:00:01 (xfr#5, ir-chk=3641/3679)"},
+		{line: fileEventPrefix + ">f+++++++++"},
+	}
+
+	for _, test := range tests {
+		event, ok := parseFileEvent(test.line, test.manifest)
+		assert.Equal(t, test.ok, ok, test.line)
+		assert.Equal(t, test.event, event, test.line)
+	}
+}
+
+func TestTaskFileEvents(t *testing.T) {
+	script := `case "$*" in *--out-format=*) ;; *) exit 1;; esac
+echo "` + fileEventPrefix + `>f+++++++++ 10 a"
+echo "` + fileEventPrefix + `>f+++++++++ 20 b"`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+
+	var callbackEvents []FileEvent
+	task.OnFileEvent(func(event FileEvent) {
+		callbackEvents = append(callbackEvents, event)
+	})
+	events := task.FileEvents()
+
+	var channelEvents []FileEvent
+	done := make(chan struct{})
+	go func() {
+		for event := range events {
+			channelEvents = append(channelEvents, event)
+		}
+		close(done)
+	}()
+
+	assert.Nil(t, task.Run())
+	<-done
+	assert.Equal(t, []FileEvent{
+		{Op: FileReceived, Itemize: ">f+++++++++", Size: 10, Path: "a"},
+		{Op: FileReceived, Itemize: ">f+++++++++", Size: 20, Path: "b"},
+	}, channelEvents)
+	assert.Equal(t, channelEvents, callbackEvents)
+}
+
+func TestTaskErrors(t *testing.T) {
+	script := `echo "file has vanished: \"/a\"" >&2
+printf "rsync error: some files vanished before they could be transferred (code 24)" >&2
+exit 24`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+
+	var callbackLines []string
+	task.OnStderrLine(func(line string) {
+		callbackLines = append(callbackLines, line)
+	})
+	errors := task.Errors()
+
+	var lines []string
+	done := make(chan struct{})
+	go func() {
+		for line := range errors {
+			lines = append(lines, line)
+		}
+		close(done)
+	}()
+
+	assert.NotNil(t, task.Run())
+	<-done
+	assert.Equal(t, []string{
+		`file has vanished: "/a"`,
+		"rsync error: some files vanished before they could be transferred (code 24)",
+	}, lines)
+	assert.Equal(t, lines, callbackLines)
+}
@@ -0,0 +1,92 @@
+package grsync
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ExitCode is an exit code of rsync as documented in its man page
+type ExitCode int
+
+// The exit codes of rsync, named after the RERR_ constants of its source
+const (
+	ExitOK              ExitCode = 0   // RERR_OK: success
+	ExitSyntax          ExitCode = 1   // RERR_SYNTAX: syntax or usage error
+	ExitProtocol        ExitCode = 2   // RERR_PROTOCOL: protocol incompatibility
+	ExitFileSelect      ExitCode = 3   // RERR_FILESELECT: errors selecting input/output files, dirs
+	ExitUnsupported     ExitCode = 4   // RERR_UNSUPPORTED: requested action not supported
+	ExitStartClient     ExitCode = 5   // RERR_STARTCLIENT: error starting client-server protocol
+	ExitLogFailure      ExitCode = 6   // RERR_LOG_FAILURE: daemon unable to append to log-file
+	ExitSocketIO        ExitCode = 10  // RERR_SOCKETIO: error in socket I/O
+	ExitFileIO          ExitCode = 11  // RERR_FILEIO: error in file I/O
+	ExitStreamIO        ExitCode = 12  // RERR_STREAMIO: error in rsync protocol data stream
+	ExitMessageIO       ExitCode = 13  // RERR_MESSAGEIO: errors with program diagnostics
+	ExitIPC             ExitCode = 14  // RERR_IPC: error in IPC code
+	ExitCrashed         ExitCode = 15  // RERR_CRASHED: sibling crashed
+	ExitTerminated      ExitCode = 16  // RERR_TERMINATED: sibling terminated abnormally
+	ExitSignal1         ExitCode = 19  // RERR_SIGNAL1: status returned when sent SIGUSR1
+	ExitSignal          ExitCode = 20  // RERR_SIGNAL: received SIGINT, SIGTERM or SIGHUP
+	ExitWaitChild       ExitCode = 21  // RERR_WAITCHILD: some error returned by waitpid()
+	ExitMalloc          ExitCode = 22  // RERR_MALLOC: error allocating core memory buffers
+	ExitPartial         ExitCode = 23  // RERR_PARTIAL: partial transfer due to error
+	ExitVanished        ExitCode = 24  // RERR_VANISHED: partial transfer due to vanished source files
+	ExitDeleteLimit     ExitCode = 25  // RERR_DEL_LIMIT: the --max-delete limit stopped deletions
+	ExitTimeout         ExitCode = 30  // RERR_TIMEOUT: timeout in data send/receive
+	ExitConnectTimeout  ExitCode = 35  // RERR_CONTIMEOUT: timeout waiting for daemon connection
+	ExitCommandFailed   ExitCode = 124 // RERR_CMD_FAILED: remote shell failed
+	ExitCommandKilled   ExitCode = 125 // RERR_CMD_KILLED: remote shell killed
+	ExitCommandRun      ExitCode = 126 // RERR_CMD_RUN: remote command could not be run
+	ExitCommandNotFound ExitCode = 127 // RERR_CMD_NOTFOUND: remote command not found
+)
+
+var exitCodeNames = map[ExitCode]string{
+	ExitOK:              "success",
+	ExitSyntax:          "syntax or usage error",
+	ExitProtocol:        "protocol incompatibility",
+	ExitFileSelect:      "errors selecting input/output files, dirs",
+	ExitUnsupported:     "requested action not supported",
+	ExitStartClient:     "error starting client-server protocol",
+	ExitLogFailure:      "daemon unable to append to log-file",
+	ExitSocketIO:        "error in socket I/O",
+	ExitFileIO:          "error in file I/O",
+	ExitStreamIO:        "error in rsync protocol data stream",
+	ExitMessageIO:       "errors with program diagnostics",
+	ExitIPC:             "error in IPC code",
+	ExitCrashed:         "sibling crashed",
+	ExitTerminated:      "sibling terminated abnormally",
+	ExitSignal1:         "received SIGUSR1",
+	ExitSignal:          "received SIGINT, SIGTERM, or SIGHUP",
+	ExitWaitChild:       "some error returned by waitpid()",
+	ExitMalloc:          "error allocating core memory buffers",
+	ExitPartial:         "partial transfer due to error",
+	ExitVanished:        "partial transfer due to vanished source files",
+	ExitDeleteLimit:     "the --max-delete limit stopped deletions",
+	ExitTimeout:         "timeout in data send/receive",
+	ExitConnectTimeout:  "timeout waiting for daemon connection",
+	ExitCommandFailed:   "remote shell failed",
+	ExitCommandKilled:   "remote shell killed",
+	ExitCommandRun:      "remote command could not be run",
+	ExitCommandNotFound: "remote command not found",
+}
+
+// String returns the description rsync uses for the exit code
+func (c ExitCode) String() string {
+	if name, ok := exitCodeNames[c]; ok {
+		return name
+	}
+	return "exit code " + strconv.Itoa(int(c))
+}
+
+// ExitCodeOf returns the exit code of the rsync process that failed with err, ExitOK for nil and
+// -1 if rsync didn't exit on its own, e.g. because it was killed or couldn't be started. Besides
+// *exec.ExitError, it accepts errors of fake processes with an ExitCode method
+func ExitCodeOf(err error) ExitCode {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return ExitCode(exitErr.ExitCode())
+	}
+	return -1
+}
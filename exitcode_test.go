@@ -0,0 +1,24 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, "partial transfer due to error", ExitPartial.String())
+	assert.Equal(t, "partial transfer due to vanished source files", ExitVanished.String())
+	assert.Equal(t, "exit code 99", ExitCode(99).String())
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 24")})
+	assert.Nil(t, err)
+	err = task.Run()
+	assert.Equal(t, ExitVanished, ExitCodeOf(err))
+	assert.Equal(t, ExitVanished, ExitCodeOf(fmt.Errorf("sync: %w", err)))
+
+	assert.Equal(t, ExitOK, ExitCodeOf(nil))
+	assert.Equal(t, ExitCode(-1), ExitCodeOf(errors.New("not started")))
+}
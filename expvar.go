@@ -0,0 +1,51 @@
+package grsync
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+)
+
+// expvarName is the name the counters are published under in /debug/vars
+const expvarName = "grsync"
+
+var (
+	expvarOnce    sync.Once
+	expvarEnabled atomic.Bool
+	expvarStats   = struct {
+		active, runs, errors, bytes expvar.Int
+	}{}
+)
+
+// PublishExpvar publishes counters of all tasks run by this process through expvar under
+// "grsync": active_tasks, runs_total, errors_total and bytes_total. Counting starts with the
+// first call, further calls have no effect
+func PublishExpvar() {
+	expvarOnce.Do(func() {
+		m := new(expvar.Map)
+		m.Set("active_tasks", &expvarStats.active)
+		m.Set("runs_total", &expvarStats.runs)
+		m.Set("errors_total", &expvarStats.errors)
+		m.Set("bytes_total", &expvarStats.bytes)
+		expvar.Publish(expvarName, m)
+		expvarEnabled.Store(true)
+	})
+}
+
+// expvarStart counts a starting run; it returns whether expvarFinish has to be called
+func expvarStart() bool {
+	if !expvarEnabled.Load() {
+		return false
+	}
+	expvarStats.active.Add(1)
+	return true
+}
+
+func expvarFinish(err error, state State) {
+	expvarStats.active.Add(-1)
+	expvarStats.runs.Add(1)
+	if err != nil {
+		expvarStats.errors.Add(1)
+	}
+	expvarStats.bytes.Add(state.CumulativeBytes)
+}
@@ -0,0 +1,35 @@
+package grsync
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	PublishExpvar()
+	PublishExpvar()
+
+	stats := func() map[string]int64 {
+		var values map[string]int64
+		assert.Nil(t, json.Unmarshal([]byte(expvar.Get(expvarName).String()), &values))
+		return values
+	}
+	before := stats()
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "      2.00K  100%    1.00kB/s    0:00:00"`)})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+	assert.Nil(t, err)
+	assert.NotNil(t, task.Run())
+
+	after := stats()
+	assert.Equal(t, before["active_tasks"], after["active_tasks"])
+	assert.Equal(t, before["runs_total"]+2, after["runs_total"])
+	assert.Equal(t, before["errors_total"]+1, after["errors_total"])
+	assert.Equal(t, before["bytes_total"]+2000, after["bytes_total"])
+}
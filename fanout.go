@@ -0,0 +1,219 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	// ErrFanoutDestinations is returned by NewFanoutTask without destinations
+	ErrFanoutDestinations = errors.New("fan-out tasks need at least one destination")
+	// ErrFanoutCancelled is returned by FanoutTask.Run if it was cancelled before the transfers started
+	ErrFanoutCancelled = errors.New("fan-out task was cancelled")
+	// ErrFanoutSkipped is the error of the destinations a FanoutTask didn't sync, because it was
+	// cancelled, StopOnError applied or the batch couldn't be recorded
+	ErrFanoutSkipped = errors.New("destination was skipped")
+)
+
+// FanoutOptions configure a FanoutTask
+type FanoutOptions struct {
+	// Concurrency is the number of destinations synced at the same time, 1 if zero
+	Concurrency int
+	// Batch syncs the first destination with --write-batch and replays the recorded batch to the
+	// others with --read-batch, so the source is only scanned and read once. It requires
+	// destinations with identical contents, e.g. mirrors which are only ever updated together;
+	// rsync fails a replay to a diverged destination
+	Batch bool
+	// StopOnError skips the destinations not started yet once one failed
+	StopOnError bool
+}
+
+// FanoutResult is the outcome of the sync of one destination of a FanoutTask
+type FanoutResult struct {
+	Destination string `json:"destination"`
+	Result      Result `json:"result"`
+	// Skipped is set if the destination wasn't synced, see ErrFanoutSkipped
+	Skipped bool `json:"skipped,omitempty"`
+	// Error is empty if the sync succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// FanoutTask syncs one source to several destinations, e.g. to replicate artifacts to mirrors
+type FanoutTask struct {
+	source       string
+	destinations []string
+	options      FanoutOptions
+	rsyncOptions RsyncOptions
+
+	mutex     sync.Mutex
+	tasks     []*Task
+	results   []FanoutResult
+	errs      []error
+	cancelled bool
+	// failed is set once a destination failed with StopOnError
+	failed bool
+}
+
+// NewFanoutTask returns a task syncing source to every destination with rsyncOptions
+func NewFanoutTask(source string, destinations []string, options FanoutOptions, rsyncOptions RsyncOptions) (*FanoutTask, error) {
+	if len(destinations) == 0 {
+		return nil, ErrFanoutDestinations
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+	return &FanoutTask{
+		source:       source,
+		destinations: append([]string(nil), destinations...),
+		options:      options,
+		rsyncOptions: rsyncOptions,
+	}, nil
+}
+
+// Tasks returns the tasks of the destinations, in their order. They are created by Run
+func (f *FanoutTask) Tasks() []*Task {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*Task(nil), f.tasks...)
+}
+
+// State combines the states of the transfers
+func (f *FanoutTask) State() State {
+	return combineStates(f.Tasks(), 0)
+}
+
+// Results returns the outcome of every destination of the current or last run, in their order
+func (f *FanoutTask) Results() []FanoutResult {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]FanoutResult(nil), f.results...)
+}
+
+// Cancel stops the running transfers and skips the others
+func (f *FanoutTask) Cancel() error {
+	f.mutex.Lock()
+	f.cancelled = true
+	tasks := f.tasks
+	f.mutex.Unlock()
+	return cancelTasks(tasks)
+}
+
+// Run syncs the destinations. The errors of all failed destinations are joined, each prefixed
+// with the destination
+func (f *FanoutTask) Run() error {
+	batch := ""
+	if f.options.Batch {
+		dir, err := os.MkdirTemp("", "grsync-batch-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		batch = filepath.Join(dir, "batch")
+	}
+
+	tasks := make([]*Task, len(f.destinations))
+	for i, destination := range f.destinations {
+		source, options := f.source, f.rsyncOptions
+		switch {
+		case batch != "" && i == 0:
+			options.WriteBatch = batch
+		case batch != "":
+			source = ""
+			options.ReadBatch = batch
+		}
+		var err error
+		if tasks[i], err = NewTask(source, destination, false, false, options); err != nil {
+			return err
+		}
+	}
+
+	f.mutex.Lock()
+	if f.cancelled {
+		f.mutex.Unlock()
+		return ErrFanoutCancelled
+	}
+	f.tasks = tasks
+	f.results = make([]FanoutResult, len(tasks))
+	f.errs = make([]error, len(tasks))
+	f.failed = false
+	for i, destination := range f.destinations {
+		f.results[i].Destination = destination
+	}
+	f.mutex.Unlock()
+
+	first := 0
+	if batch != "" {
+		// the replays need the recorded batch
+		if !f.sync(0) {
+			for i := 1; i < len(tasks); i++ {
+				f.skip(i)
+			}
+			return f.err()
+		}
+		first = 1
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < f.options.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if f.stopped() {
+					f.skip(i)
+				} else {
+					f.sync(i)
+				}
+			}
+		}()
+	}
+	for i := first; i < len(tasks); i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return f.err()
+}
+
+// sync runs the task of destination i and records its result. It reports whether it succeeded
+func (f *FanoutTask) sync(i int) bool {
+	task := f.tasks[i]
+	result, err := task.RunResult()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.results[i].Result = result
+	if err != nil {
+		f.results[i].Error = err.Error()
+		f.errs[i] = fmt.Errorf("%s: %w", f.destinations[i], err)
+		f.failed = f.options.StopOnError
+	}
+	return err == nil
+}
+
+// skip records that destination i wasn't synced
+func (f *FanoutTask) skip(i int) {
+	f.mutex.Lock()
+	f.results[i].Skipped = true
+	f.results[i].Error = ErrFanoutSkipped.Error()
+	f.errs[i] = fmt.Errorf("%s: %w", f.destinations[i], ErrFanoutSkipped)
+	f.mutex.Unlock()
+}
+
+// stopped reports whether destinations not started yet are skipped
+func (f *FanoutTask) stopped() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.cancelled || f.failed
+}
+
+// err joins the errors of the destinations
+func (f *FanoutTask) err() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return errors.Join(f.errs...)
+}
@@ -0,0 +1,97 @@
+package grsync
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanoutTask(t *testing.T) {
+	// the fake rsync logs its last two arguments and fails for destinations containing "fail"
+	newFanout := func(t *testing.T, destinations []string, options FanoutOptions) (*FanoutTask, func() []string) {
+		log := filepath.Join(t.TempDir(), "log")
+		script := `for last; do :; done
+case "$*" in *--read-batch=*) echo "read $last" >> ` + log + ` ;; *--write-batch=*) echo "write $last" >> ` + log + ` ;; *) echo "sync $last" >> ` + log + ` ;; esac
+case "$last" in *fail*) exit 23 ;; esac`
+		task, err := NewFanoutTask("src/", destinations, options, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		return task, func() []string {
+			data, _ := ioutil.ReadFile(log)
+			return strings.Split(strings.TrimSpace(string(data)), "\n")
+		}
+	}
+
+	t.Run("sequential", func(t *testing.T) {
+		task, log := newFanout(t, []string{"a/", "fail/", "b/"}, FanoutOptions{})
+		err := task.Run()
+		assert.Equal(t, ExitPartial, ExitCodeOf(err))
+		assert.Equal(t, "fail/: exit status 23", err.Error())
+		assert.Equal(t, []string{"sync a/", "sync fail/", "sync b/"}, log())
+
+		results := task.Results()
+		assert.Len(t, results, 3)
+		assert.Equal(t, "a/", results[0].Destination)
+		assert.Empty(t, results[0].Error)
+		assert.Equal(t, ExitPartial, results[1].Result.ExitCode)
+		assert.Empty(t, results[2].Error)
+	})
+
+	t.Run("stop on error", func(t *testing.T) {
+		task, log := newFanout(t, []string{"fail/", "a/"}, FanoutOptions{StopOnError: true})
+		err := task.Run()
+		assert.True(t, errors.Is(err, ErrFanoutSkipped))
+		assert.Equal(t, []string{"sync fail/"}, log())
+		assert.True(t, task.Results()[1].Skipped)
+
+		// the next run starts over
+		assert.NotNil(t, task.Run())
+		assert.Equal(t, []string{"sync fail/", "sync fail/"}, log())
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		task, log := newFanout(t, []string{"a/", "b/", "c/", "d/"}, FanoutOptions{Concurrency: 3})
+		assert.Nil(t, task.Run())
+		lines := log()
+		sort.Strings(lines)
+		assert.Equal(t, []string{"sync a/", "sync b/", "sync c/", "sync d/"}, lines)
+		assert.Len(t, task.Tasks(), 4)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		task, log := newFanout(t, []string{"a/", "b/", "c/"}, FanoutOptions{Batch: true})
+		assert.Nil(t, task.Run())
+		assert.Equal(t, []string{"write a/", "read b/", "read c/"}, log())
+	})
+
+	t.Run("batch recording fails", func(t *testing.T) {
+		task, log := newFanout(t, []string{"fail/", "a/"}, FanoutOptions{Batch: true})
+		err := task.Run()
+		assert.True(t, errors.Is(err, ErrFanoutSkipped))
+		assert.Equal(t, []string{"write fail/"}, log())
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		task, _ := newFanout(t, []string{"a/"}, FanoutOptions{})
+		assert.Nil(t, task.Cancel())
+		assert.Equal(t, ErrFanoutCancelled, task.Run())
+	})
+
+	_, err := NewFanoutTask("src/", nil, FanoutOptions{}, RsyncOptions{})
+	assert.Equal(t, ErrFanoutDestinations, err)
+}
+
+func TestBatchOptions(t *testing.T) {
+	assert.Equal(t, []string{"--write-batch=/tmp/b"}, getArguments(RsyncOptions{WriteBatch: "/tmp/b"}))
+	assert.Equal(t, []string{"--only-write-batch=/tmp/b"}, getArguments(RsyncOptions{OnlyWriteBatch: "/tmp/b"}))
+	assert.Equal(t, []string{"--read-batch=/tmp/b"}, getArguments(RsyncOptions{ReadBatch: "/tmp/b"}))
+
+	runner := &cannedRunner{}
+	_, err := newRsync("", "b/", false, false, RsyncOptions{ReadBatch: "/tmp/b"}, nil, runner)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"rsync", "--read-batch=/tmp/b", "b/"}, runner.args)
+}
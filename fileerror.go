@@ -0,0 +1,96 @@
+package grsync
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// FileError is a file rsync failed to transfer, delete or stat, as reported on stderr
+type FileError struct {
+	Path string `json:"path"`
+	// Reason is the error rsync reported, e.g. "Permission denied (13)" or "file has vanished"
+	Reason string `json:"reason"`
+	// Line is the stderr line the error was parsed from
+	Line string `json:"line"`
+}
+
+var (
+	// fileErrorPattern matches e.g. `rsync: [sender] send_files failed to open "/src/file": Permission denied (13)`
+	// and `rsync: mkstemp "/dst/.file.x1b2" (in module) failed: No space left on device (28)`
+	fileErrorPattern = regexp.MustCompile(`^rsync: .*?"([^"]+)"\)?(?: \(in [^)]*\))?(?: failed)?: (.+)$`)
+	vanishedPattern  = regexp.MustCompile(`^(?:rsync: (?:\[\w+\] )?)?file has vanished: "(.+)"$`)
+)
+
+const (
+	// vanishedReason is the FileError.Reason of a source file deleted during the transfer
+	vanishedReason = "file has vanished"
+	// nonEmptyDirPrefix starts the warning for a directory --delete kept without --force
+	nonEmptyDirPrefix = "cannot delete non-empty directory: "
+	// ioErrorDeletion is printed instead of deleting after an I/O error without --ignore-errors
+	ioErrorDeletion = "IO error encountered -- skipping file deletion"
+)
+
+// deleteWarningPrefixes start the warnings about skipped deletions, which rsync prints on stdout
+var deleteWarningPrefixes = byteStrings(nonEmptyDirPrefix, ioErrorDeletion)
+
+// FileErrors returns the files rsync reported errors for during the current or last run
+func (t *Task) FileErrors() []FileError {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]FileError(nil), t.fileErrors...)
+}
+
+// Vanished returns the source paths that were deleted while rsync transferred them during the
+// current or last run, e.g. when backing up a live file system. rsync exits with ExitVanished if
+// nothing else failed
+func (t *Task) Vanished() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.vanished()
+}
+
+// vanished returns the paths of the file errors for vanished files. The mutex must be held
+func (t *Task) vanished() []string {
+	var paths []string
+	for _, fileErr := range t.fileErrors {
+		if fileErr.Reason == vanishedReason {
+			paths = append(paths, fileErr.Path)
+		}
+	}
+	return paths
+}
+
+// parseFileError parses a stderr line reporting an error for a single file
+func parseFileError(line string) (FileError, bool) {
+	if strings.HasPrefix(line, nonEmptyDirPrefix) {
+		return FileError{Path: line[len(nonEmptyDirPrefix):], Reason: "cannot delete non-empty directory", Line: line}, true
+	}
+	if !strings.Contains(line, `"`) {
+		return FileError{}, false
+	}
+	if match := vanishedPattern.FindStringSubmatch(line); match != nil {
+		return FileError{Path: match[1], Reason: vanishedReason, Line: line}, true
+	}
+	if match := fileErrorPattern.FindStringSubmatch(line); match != nil {
+		return FileError{Path: match[1], Reason: match[2], Line: line}, true
+	}
+	return FileError{}, false
+}
+
+// recordDeleteWarning adds a warning about skipped deletions printed on stdout to the warnings and
+// the file errors and reports whether line was one. The mutex must be held
+func (t *Task) recordDeleteWarning(line []byte) bool {
+	for _, prefix := range deleteWarningPrefixes {
+		if bytes.HasPrefix(line, prefix) {
+			warning := t.redact(string(line))
+			fileErr, ok := t.errorPatterns.parseFileError(warning)
+			t.warnings = append(t.warnings, classifyWarning(warning, fileErr, ok))
+			if ok {
+				t.fileErrors = append(t.fileErrors, fileErr)
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFileError(t *testing.T) {
+	for line, expected := range map[string]FileError{
+		`rsync: send_files failed to open "/src/secret": Permission denied (13)`:             {Path: "/src/secret", Reason: "Permission denied (13)"},
+		`rsync: [sender] send_files failed to open "/src/a b": Permission denied (13)`:       {Path: "/src/a b", Reason: "Permission denied (13)"},
+		`rsync: opendir "/src/dir" failed: Permission denied (13)`:                           {Path: "/src/dir", Reason: "Permission denied (13)"},
+		`rsync: link_stat "/src/missing" failed: No such file or directory (2)`:              {Path: "/src/missing", Reason: "No such file or directory (2)"},
+		`rsync: readlink_stat("/src/link") failed: Input/output error (5)`:                   {Path: "/src/link", Reason: "Input/output error (5)"},
+		`rsync: mkstemp "/dst/.file.x1b2" (in module) failed: No space left on device (28)`:  {Path: "/dst/.file.x1b2", Reason: "No space left on device (28)"},
+		`rsync: [generator] recv_generator: mkdir "/dst/dir" failed: Permission denied (13)`: {Path: "/dst/dir", Reason: "Permission denied (13)"},
+		`file has vanished: "/src/tmp/file"`:                                                 {Path: "/src/tmp/file", Reason: "file has vanished"},
+	} {
+		fileErr, ok := parseFileError(line)
+		assert.True(t, ok, line)
+		expected.Line = line
+		assert.Equal(t, expected, fileErr)
+	}
+
+	for _, line := range []string{
+		"rsync error: some files/attrs were not transferred (see previous errors) (code 23) at main.c(1338) [sender=3.2.7]",
+		"IO error encountered -- skipping file deletion",
+		`warning: "quoted" but not a file error`,
+		"",
+	} {
+		_, ok := parseFileError(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestTaskFileErrors(t *testing.T) {
+	script := `echo 'rsync: send_files failed to open "/src/secret": Permission denied (13)' >&2
+echo 'file has vanished: "/src/tmp"' >&2
+echo 'rsync error: some files/attrs were not transferred (see previous errors) (code 23)' >&2
+exit 23`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+
+	result, err := task.RunResult()
+	assert.Equal(t, ExitPartial, ExitCodeOf(err))
+	assert.Len(t, result.Warnings, 3)
+	assert.Equal(t, result.FileErrors, task.FileErrors())
+
+	fileErrors := task.FileErrors()
+	assert.Len(t, fileErrors, 2)
+	assert.Equal(t, "/src/secret", fileErrors[0].Path)
+	assert.Equal(t, "Permission denied (13)", fileErrors[0].Reason)
+	assert.Equal(t, "/src/tmp", fileErrors[1].Path)
+	assert.Equal(t, []string{"/src/tmp"}, task.Vanished())
+	assert.Equal(t, []string{"/src/tmp"}, result.Vanished)
+}
+
+func TestTaskDeleteWarnings(t *testing.T) {
+	script := `echo "sending incremental file list"
+echo "cannot delete non-empty directory: old/dir"
+echo "deleting old/file"
+echo "IO error encountered -- skipping file deletion"
+echo 'rsync: [sender] opendir "/src/private" failed: Permission denied (13)' >&2
+exit 23`
+
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Delete: true, Verbose: true})
+	assert.Nil(t, err)
+	result, err := task.RunResult()
+	assert.Equal(t, ExitPartial, ExitCodeOf(err))
+
+	assert.Equal(t, []string{"old/file"}, task.Deleted())
+	assert.Contains(t, result.Warnings, Warning{Kind: WarningDeletion, Path: "old/dir", Message: "cannot delete non-empty directory: old/dir"})
+	assert.Contains(t, result.Warnings, Warning{Kind: WarningDeletion, Message: "IO error encountered -- skipping file deletion"})
+	errors := task.FileErrors()
+	assert.Len(t, errors, 2)
+	assert.Contains(t, errors, FileError{Path: "old/dir", Reason: "cannot delete non-empty directory", Line: "cannot delete non-empty directory: old/dir"})
+}
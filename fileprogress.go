@@ -0,0 +1,86 @@
+package grsync
+
+import (
+	"bytes"
+	"strings"
+)
+
+// FileProgress is the progress of the file rsync is transferring. rsync reports it with
+// --progress, which tasks pass by default, but not with --info=progress2, whose progress covers
+// the whole transfer
+type FileProgress struct {
+	Path    string `json:"path"`
+	Bytes   int64  `json:"bytes"`
+	Percent int    `json:"percent"`
+	// Done is set for the last update of the file
+	Done bool `json:"done"`
+}
+
+// OnFileProgress registers a callback invoked with every progress update of a file, e.g. for a
+// nested progress bar of large files
+func (t *Task) OnFileProgress(callback func(FileProgress)) {
+	t.mutex.Lock()
+	t.fileProgressCallbacks = append(t.fileProgressCallbacks, callback)
+	t.mutex.Unlock()
+}
+
+// CurrentFile returns the latest progress of the file being or last transferred. ok is false
+// before rsync named a file or if it only reports the progress of the whole transfer
+func (t *Task) CurrentFile() (progress FileProgress, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.fileProgress, t.fileProgress.Path != ""
+}
+
+// perFileProgress reports whether the options make rsync print the progress of every file
+func perFileProgress(options RsyncOptions) bool {
+	if level, ok := options.InfoFlags.Level("progress"); ok && level > 1 {
+		return false
+	}
+	for _, flag := range strings.Split(options.Info, ",") {
+		if strings.EqualFold(strings.TrimSpace(flag), "progress2") {
+			return false
+		}
+	}
+	return true
+}
+
+// trackFileProgress remembers the file named on a stdout line of category and returns the update
+// of its progress on a progress line. The mutex must be held
+func (t *Task) trackFileProgress(line []byte, category LineCategory, progress progressFields, isProgress bool) (FileProgress, bool) {
+	if !t.perFileProgress {
+		return FileProgress{}, false
+	}
+	switch {
+	case category == LineFile && bytes.HasPrefix(line, fileEventMarker):
+		if bytes.HasPrefix(line[len(fileEventMarker):], itemizedDeletingPrefix) {
+			return FileProgress{}, false
+		}
+		if event, ok := t.parseFileEvent(string(line)); ok && event.Op != FileDeleted {
+			t.fileProgress = FileProgress{Path: event.Path}
+		}
+	case category == LineFile:
+		if !bytes.HasPrefix(line, deletingPrefix) && !bytes.HasPrefix(line, itemizedDeletingPrefix) && !bytes.HasSuffix(line, []byte("/")) {
+			t.fileProgress = FileProgress{Path: unescapeName(string(line))}
+		}
+	case category == LineProgress && isProgress && t.fileProgress.Path != "" && !t.fileProgress.Done:
+		if size, err := parseSize(string(progress.total), t.unitBase()); err == nil {
+			t.fileProgress.Bytes = size
+		}
+		t.fileProgress.Percent = progress.percent
+		t.fileProgress.Done = progress.transferred > 0
+		return t.fileProgress, len(t.fileProgressCallbacks) > 0
+	}
+	return FileProgress{}, false
+}
+
+// emitFileProgress passes progress to the OnFileProgress callbacks
+func (t *Task) emitFileProgress(progress FileProgress) {
+	t.mutex.Lock()
+	callbacks := t.fileProgressCallbacks
+	t.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(progress)
+	}
+}
@@ -0,0 +1,53 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileProgress(t *testing.T) {
+	script := `echo "sending incremental file list"
+echo "dir/"
+echo "dir/big.iso"
+printf "    32,768   0%%    0.00kB/s    0:00:00\r"
+printf "  5,000,000  50%%   10.00MB/s    0:00:01\r"
+echo " 10,000,000 100%   10.00MB/s    0:00:01 (xfr#1, to-chk=1/3)"
+echo "small.txt"
+echo "        100 100%    0.10kB/s    0:00:00 (xfr#2, to-chk=0/3)"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	var updates []FileProgress
+	task.OnFileProgress(func(progress FileProgress) { updates = append(updates, progress) })
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, []FileProgress{
+		{Path: "dir/big.iso", Bytes: 32768, Percent: 0},
+		{Path: "dir/big.iso", Bytes: 5000000, Percent: 50},
+		{Path: "dir/big.iso", Bytes: 10000000, Percent: 100, Done: true},
+		{Path: "small.txt", Bytes: 100, Percent: 100, Done: true},
+	}, updates)
+	current, ok := task.CurrentFile()
+	assert.True(t, ok)
+	assert.Equal(t, "small.txt", current.Path)
+}
+
+func TestFileProgressWholeTransfer(t *testing.T) {
+	script := `echo "dir/big.iso"
+echo " 10,000,000 100%   10.00MB/s    0:00:01 (xfr#1, to-chk=0/1)"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Info: "progress2"})
+	assert.Nil(t, err)
+	task.OnFileProgress(func(progress FileProgress) { t.Errorf("unexpected update %v", progress) })
+	assert.Nil(t, task.Run())
+	_, ok := task.CurrentFile()
+	assert.False(t, ok)
+}
+
+func TestPerFileProgress(t *testing.T) {
+	assert.True(t, perFileProgress(RsyncOptions{}))
+	assert.True(t, perFileProgress(RsyncOptions{Info: "name1,progress1"}))
+	assert.False(t, perFileProgress(RsyncOptions{Info: "name0, progress2"}))
+	assert.False(t, perFileProgress(RsyncOptions{InfoFlags: OutputFlags{"progress": 2}}))
+}
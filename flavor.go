@@ -0,0 +1,86 @@
+package grsync
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+)
+
+// ErrSshPassUnsupported is returned for tasks using sshpass on Windows, where it isn't available.
+// Use key based authentication or a PasswordFile for rsync daemons instead
+var ErrSshPassUnsupported = errors.New("sshpass is not available on windows")
+
+// RsyncFlavor is the kind of rsync build, which decides how local paths are passed to it
+type RsyncFlavor string
+
+const (
+	// FlavorAuto is FlavorCygwin on Windows and FlavorNative elsewhere
+	FlavorAuto RsyncFlavor = ""
+	// FlavorNative passes paths unchanged
+	FlavorNative RsyncFlavor = "native"
+	// FlavorCygwin translates Windows paths for Cygwin builds like cwRsync, e.g. `C:\data` to `/cygdrive/c/data`
+	FlavorCygwin RsyncFlavor = "cygwin"
+	// FlavorWSL translates Windows paths for rsync in the Windows Subsystem for Linux, e.g. `C:\data` to
+	// `/mnt/c/data`, and runs `wsl rsync` unless RsyncOptions.RsyncBinaryPath is set
+	FlavorWSL RsyncFlavor = "wsl"
+)
+
+// resolve returns the flavor FlavorAuto stands for on the current platform
+func (f RsyncFlavor) resolve() RsyncFlavor {
+	if f != FlavorAuto {
+		return f
+	}
+	if runtime.GOOS == "windows" {
+		return FlavorCygwin
+	}
+	return FlavorNative
+}
+
+// TranslatePath converts a local Windows path into the form the rsync flavor expects. Remote paths
+// like `host:path` and paths of FlavorNative are returned unchanged
+func TranslatePath(path string, flavor RsyncFlavor) string {
+	flavor = flavor.resolve()
+	if flavor != FlavorCygwin && flavor != FlavorWSL {
+		return path
+	}
+
+	if isDrivePath(path) {
+		prefix := "/cygdrive/"
+		if flavor == FlavorWSL {
+			prefix = "/mnt/"
+		}
+		rest := strings.ReplaceAll(path[2:], `\`, "/")
+		if rest == "" {
+			rest = "/"
+		}
+		return prefix + strings.ToLower(path[:1]) + rest
+	}
+	if strings.Contains(path, ":") {
+		return path
+	}
+	return strings.ReplaceAll(path, `\`, "/")
+}
+
+// isDrivePath reports whether path starts with a drive letter like `C:\` or `C:/`
+func isDrivePath(path string) bool {
+	if len(path) < 2 || path[1] != ':' {
+		return false
+	}
+	if c := path[0] | 0x20; c < 'a' || c > 'z' {
+		return false
+	}
+	return len(path) == 2 || path[2] == '\\' || path[2] == '/'
+}
+
+// translateOptions translates the local paths of options for the flavor
+func translateOptions(options RsyncOptions, flavor RsyncFlavor) RsyncOptions {
+	for _, path := range []*string{
+		&options.PasswordFile, &options.LogFile, &options.FilesFrom, &options.ExcludeFrom, &options.IncludeFrom,
+		&options.TempDir, &options.CompareDest, &options.CopyDest, &options.LinkDest,
+	} {
+		if *path != "" {
+			*path = TranslatePath(*path, flavor)
+		}
+	}
+	return options
+}
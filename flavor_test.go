@@ -0,0 +1,44 @@
+package grsync
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslatePath(t *testing.T) {
+	for path, expected := range map[string]string{
+		`C:\Users\me\data\`: "/cygdrive/c/Users/me/data/",
+		`d:/backup`:         "/cygdrive/d/backup",
+		`E:`:                "/cygdrive/e/",
+		`relative\dir`:      "relative/dir",
+		`host:C:\data`:      `host:C:\data`,
+		`user@host:/data`:   "user@host:/data",
+		`rsync://host/mod`:  "rsync://host/mod",
+		"/already/posix":    "/already/posix",
+	} {
+		assert.Equal(t, expected, TranslatePath(path, FlavorCygwin), path)
+	}
+
+	assert.Equal(t, "/mnt/c/Users/me", TranslatePath(`C:\Users\me`, FlavorWSL))
+	assert.Equal(t, `C:\Users\me`, TranslatePath(`C:\Users\me`, FlavorNative))
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, `C:\Users\me`, TranslatePath(`C:\Users\me`, FlavorAuto))
+	}
+}
+
+func TestRsyncFlavor(t *testing.T) {
+	rsync, err := NewRsync(`C:\source\`, "host:/destination", false, false, RsyncOptions{Flavor: FlavorCygwin, LogFile: `C:\logs\rsync.log`})
+	assert.Nil(t, err)
+	assert.Equal(t, "rsync", rsync.process.(*execProcess).Args[0])
+	assert.Contains(t, rsync.process.(*execProcess).Args, "--log-file=/cygdrive/c/logs/rsync.log")
+	assert.Equal(t, []string{"/cygdrive/c/source/", "host:/destination"}, rsync.process.(*execProcess).Args[len(rsync.process.(*execProcess).Args)-2:])
+	// the Go side still uses the original paths
+	assert.Equal(t, `C:\source\`, rsync.Source)
+
+	rsync, err = NewRsync(`C:\source`, `D:\destination`, false, false, RsyncOptions{Flavor: FlavorWSL})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"wsl", "rsync"}, rsync.process.(*execProcess).Args[:2])
+	assert.Equal(t, []string{"/mnt/c/source", "/mnt/d/destination"}, rsync.process.(*execProcess).Args[len(rsync.process.(*execProcess).Args)-2:])
+}
@@ -0,0 +1,144 @@
+package grsync
+
+import (
+	"time"
+)
+
+// GroupState combines the states of a group of tasks for a single progress bar
+type GroupState struct {
+	Tasks     int `json:"tasks"`
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+
+	// BytesTransferred sums State.CumulativeBytes and EstimatedBytes the estimated sizes, see
+	// SetSizeEstimate. Tasks without an estimate don't count towards EstimatedBytes
+	BytesTransferred int64 `json:"bytes"`
+	EstimatedBytes   int64 `json:"estimatedBytes"`
+	// Progress is the percentage of the group, the progress of the tasks weighted by their
+	// estimated sizes. Tasks without an estimate weigh as much as the average estimate, all tasks
+	// weigh the same if none has one. Finished tasks count as complete, also failed ones
+	Progress       int     `json:"progress"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	Speed          string  `json:"speed"`
+
+	// Slowest is the ID of the running task expected to finish last and ETA its time remaining,
+	// which is the time remaining of the running tasks
+	Slowest string        `json:"slowest,omitempty"`
+	ETA     time.Duration `json:"eta"`
+}
+
+// SetSizeEstimate sets the expected number of bytes the task transfers, e.g. from a dry run. It
+// weighs the task in a GroupState, which otherwise derives the size from the progress of a
+// running task
+func (t *Task) SetSizeEstimate(bytes int64) {
+	t.mutex.Lock()
+	t.sizeEstimate = bytes
+	t.mutex.Unlock()
+}
+
+// estimatedSize returns the size set with SetSizeEstimate or, if the task reported progress, the
+// size extrapolated from the bytes transferred. ok is false if neither is known
+func (t *Task) estimatedSize(state State) (size int64, ok bool) {
+	t.mutex.Lock()
+	size = t.sizeEstimate
+	t.mutex.Unlock()
+	if size > 0 {
+		return size, true
+	}
+	if state.BytesProgress > 0 {
+		return state.BytesTransferred * 100 / int64(state.BytesProgress), true
+	}
+	return 0, false
+}
+
+// GroupState combines the states of the registered tasks carrying all labels, e.g.
+// {"batch": "nightly"}. With no labels all tasks are combined
+func (m *Manager) GroupState(labels map[string]string) GroupState {
+	return combineGroup(m.group(labels))
+}
+
+// group returns the registered tasks carrying all labels
+func (m *Manager) group(labels map[string]string) []*Task {
+	var tasks []*Task
+tasks:
+	for _, task := range m.List() {
+		for key, value := range labels {
+			if task.Label(key) != value {
+				continue tasks
+			}
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// groupMember is a task of a group with its state and weight
+type groupMember struct {
+	status   TaskStatus
+	state    State
+	size     int64
+	hasSize  bool
+	progress int
+}
+
+func combineGroup(tasks []*Task) GroupState {
+	group := GroupState{Tasks: len(tasks)}
+	members := make([]groupMember, len(tasks))
+	var known int
+	var knownBytes int64
+	for i, task := range tasks {
+		member := groupMember{status: task.Status(), state: task.State()}
+		member.size, member.hasSize = task.estimatedSize(member.state)
+		member.progress = member.state.Progress
+		switch member.status {
+		case TaskPending:
+			group.Pending++
+			member.progress = 0
+		case TaskRunning:
+			group.Running++
+			group.BytesPerSecond += member.state.BytesPerSecond
+			if eta := member.state.ETA; group.Slowest == "" || eta > group.ETA {
+				group.Slowest, group.ETA = task.ID(), eta
+			}
+		case TaskSucceeded:
+			group.Succeeded++
+			member.progress = 100
+		case TaskFailed:
+			group.Failed++
+			member.progress = 100
+		case TaskCancelled:
+			group.Cancelled++
+			member.progress = 100
+		}
+		group.BytesTransferred += member.state.CumulativeBytes
+		if member.hasSize {
+			known++
+			knownBytes += member.size
+		}
+		members[i] = member
+	}
+	group.EstimatedBytes = knownBytes
+	group.Speed = formatSpeed(group.BytesPerSecond, 1000)
+
+	// tasks without an estimate weigh as much as the average one
+	average := 1.0
+	if known > 0 && knownBytes > 0 {
+		average = float64(knownBytes) / float64(known)
+	}
+	var weighted, total float64
+	for _, member := range members {
+		weight := average
+		if member.hasSize && knownBytes > 0 {
+			weight = float64(member.size)
+		}
+		weighted += weight * float64(member.progress)
+		total += weight
+	}
+	if total > 0 {
+		group.Progress = int(weighted / total)
+	}
+	return group
+}
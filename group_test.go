@@ -0,0 +1,60 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupState(t *testing.T) {
+	m := NewManager()
+	newTask := func(id string, status TaskStatus, state State, labels map[string]string) *Task {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{})
+		assert.Nil(t, err)
+		task.SetID(id)
+		task.SetLabels(labels)
+		task.status = status
+		*task.state = state
+		assert.Nil(t, m.Add(task))
+		return task
+	}
+
+	nightly := map[string]string{"batch": "nightly"}
+	// 600 of 1000 bytes
+	newTask("running", TaskRunning, State{BytesTransferred: 600, CumulativeBytes: 600, BytesProgress: 60, Progress: 60, BytesPerSecond: 100, ETA: 4 * time.Second}, nightly)
+	// 3000 bytes estimated, nothing transferred yet
+	newTask("pending", TaskPending, State{}, nightly).SetSizeEstimate(3000)
+	newTask("done", TaskSucceeded, State{CumulativeBytes: 1000, Progress: 100}, nightly).SetSizeEstimate(1000)
+	newTask("slow", TaskRunning, State{BytesTransferred: 100, CumulativeBytes: 100, BytesProgress: 10, Progress: 10, BytesPerSecond: 10, ETA: 90 * time.Second}, nightly)
+	newTask("other", TaskRunning, State{Progress: 50}, map[string]string{"batch": "hourly"})
+
+	group := m.GroupState(nightly)
+	assert.Equal(t, 4, group.Tasks)
+	assert.Equal(t, 1, group.Pending)
+	assert.Equal(t, 2, group.Running)
+	assert.Equal(t, 1, group.Succeeded)
+	assert.Equal(t, int64(1700), group.BytesTransferred)
+	assert.Equal(t, int64(6000), group.EstimatedBytes)
+	// (600 + 0 + 1000 + 100) of 6000
+	assert.Equal(t, 28, group.Progress)
+	assert.Equal(t, float64(110), group.BytesPerSecond)
+	assert.Equal(t, "slow", group.Slowest)
+	assert.Equal(t, 90*time.Second, group.ETA)
+
+	assert.Equal(t, 5, m.GroupState(nil).Tasks)
+	assert.Equal(t, "other", m.GroupState(map[string]string{"batch": "hourly"}).Slowest)
+	assert.Equal(t, GroupState{Speed: formatSpeed(0, 1000)}, m.GroupState(map[string]string{"batch": "weekly"}))
+}
+
+func TestGroupStateWithoutEstimates(t *testing.T) {
+	var tasks []*Task
+	for _, progress := range []int{0, 50} {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{})
+		assert.Nil(t, err)
+		task.status = TaskRunning
+		task.state.Progress = progress
+		tasks = append(tasks, task)
+	}
+	assert.Equal(t, 25, combineGroup(tasks).Progress)
+}
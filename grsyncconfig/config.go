@@ -0,0 +1,422 @@
+// Package grsyncconfig loads declarative task definitions from YAML, TOML or JSON files
+//
+//	tasks:
+//	  - name: nightly
+//	    source: /data/
+//	    destination: backup::data
+//	    lockDestination: true
+//	    options:
+//	      archive: true
+//	      delete: true
+//	      exclude: ["*.tmp"]
+//	    schedule: "30 2 * * *"
+//	    overlap: skip
+//	    retry:
+//	      maxAttempts: 3
+//	      delay: 30s
+//	      backoff: 2
+//
+// Option keys are the field names of grsync.RsyncOptions, matched case-insensitively and
+// ignoring dashes and underscores, so "bandwidthLimit" and "bandwidth-limit" both work
+package grsyncconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ByteSizedMarius/grsync"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a configuration
+type Format string
+
+const (
+	YAML Format = "yaml"
+	TOML Format = "toml"
+	JSON Format = "json"
+)
+
+// Config is a set of task definitions
+type Config struct {
+	Tasks []Task `yaml:"tasks" toml:"tasks" json:"tasks"`
+}
+
+// Task is the declarative definition of a task and, optionally, its schedule
+type Task struct {
+	Name            string                 `yaml:"name" toml:"name" json:"name"`
+	Source          string                 `yaml:"source" toml:"source" json:"source"`
+	Destination     string                 `yaml:"destination" toml:"destination" json:"destination"`
+	UseSshPass      bool                   `yaml:"useSshPass" toml:"useSshPass" json:"useSshPass"`
+	CreateDir       bool                   `yaml:"createDir" toml:"createDir" json:"createDir"`
+	LockDestination bool                   `yaml:"lockDestination" toml:"lockDestination" json:"lockDestination"`
+	DiscardLog      bool                   `yaml:"discardLog" toml:"discardLog" json:"discardLog"`
+	Options         map[string]interface{} `yaml:"options" toml:"options" json:"options"`
+	Labels          map[string]string      `yaml:"labels" toml:"labels" json:"labels"`
+	// Vars are the custom variables of templated paths, see grsync.PathVars
+	Vars map[string]string `yaml:"vars" toml:"vars" json:"vars"`
+	// BandwidthSchedule are limits by time of day like "08:00-18:00=5000", see
+	// grsync.ParseBandwidthSchedule
+	BandwidthSchedule string `yaml:"bandwidthSchedule" toml:"bandwidthSchedule" json:"bandwidthSchedule"`
+	// CleanupOnCancel removes the partial files of cancelled runs, see grsync.Task.SetCleanupOnCancel
+	CleanupOnCancel bool `yaml:"cleanupOnCancel" toml:"cleanupOnCancel" json:"cleanupOnCancel"`
+
+	// Schedule is a cron expression as understood by grsync.ParseCron, e.g. "@every 1h"
+	Schedule string `yaml:"schedule" toml:"schedule" json:"schedule"`
+	// Overlap is one of skip (default), queue and kill, see grsync.OverlapPolicy
+	Overlap string `yaml:"overlap" toml:"overlap" json:"overlap"`
+	Retry   *Retry `yaml:"retry" toml:"retry" json:"retry"`
+
+	// Pre and Post are the commands run before and after the transfer, see grsync.CommandHook
+	Pre  []Hook `yaml:"pre" toml:"pre" json:"pre"`
+	Post []Hook `yaml:"post" toml:"post" json:"post"`
+}
+
+// Hook configures a grsync.CommandHook
+type Hook struct {
+	Name    string   `yaml:"name" toml:"name" json:"name"`
+	Command []string `yaml:"command" toml:"command" json:"command"`
+	Timeout Duration `yaml:"timeout" toml:"timeout" json:"timeout"`
+	// OnFailure is one of abort (default) and warn, see grsync.HookPolicy
+	OnFailure string `yaml:"onFailure" toml:"onFailure" json:"onFailure"`
+}
+
+// Retry configures the grsync.RetryPolicy of a task
+type Retry struct {
+	MaxAttempts int      `yaml:"maxAttempts" toml:"maxAttempts" json:"maxAttempts"`
+	Delay       Duration `yaml:"delay" toml:"delay" json:"delay"`
+	Backoff     float64  `yaml:"backoff" toml:"backoff" json:"backoff"`
+	MaxDelay    Duration `yaml:"maxDelay" toml:"maxDelay" json:"maxDelay"`
+	Resume      bool     `yaml:"resume" toml:"resume" json:"resume"`
+}
+
+// Duration is a time.Duration written as a string like "1m30s"
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load reads the configuration at path, the format is derived from the extension
+func Load(path string) (*Config, error) {
+	var format Format
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = YAML
+	case ".toml":
+		format = TOML
+	case ".json":
+		format = JSON
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration format", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Parse decodes and validates a configuration
+func Parse(data []byte, format Format) (*Config, error) {
+	var config Config
+	var err error
+	switch format {
+	case YAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&config)
+	case TOML:
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), &config)
+		if undecoded := meta.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown key %s", undecoded[0])
+		}
+	case JSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&config)
+	default:
+		err = fmt.Errorf("unknown configuration format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i, task := range config.Tasks {
+		if _, err := task.Definition(); err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		if _, err := task.setup(); err != nil {
+			return nil, fmt.Errorf("task %d: %w", i+1, err)
+		}
+		if task.Schedule != "" {
+			if _, err := task.Job(); err != nil {
+				return nil, fmt.Errorf("task %d: %w", i+1, err)
+			}
+		}
+		if task.Name != "" && names[task.Name] {
+			return nil, fmt.Errorf("task %d: duplicate name %q", i+1, task.Name)
+		}
+		names[task.Name] = true
+	}
+	return &config, nil
+}
+
+// Definition converts the task into a grsync.Definition
+func (t Task) Definition() (grsync.Definition, error) {
+	definition := grsync.Definition{
+		ID:              t.Name,
+		Source:          t.Source,
+		Destination:     t.Destination,
+		UseSshPass:      t.UseSshPass,
+		CreateDir:       t.CreateDir,
+		LockDestination: t.LockDestination,
+		DiscardLog:      t.DiscardLog,
+		Labels:          t.Labels,
+	}
+	if t.Source == "" || t.Destination == "" {
+		return definition, fmt.Errorf("source and destination are required")
+	}
+
+	options, err := decodeOptions(t.Options)
+	if err != nil {
+		return definition, err
+	}
+	definition.Options = options
+	return definition, nil
+}
+
+// RetryPolicy returns the configured retry policy, the zero policy disables retries
+func (t Task) RetryPolicy() grsync.RetryPolicy {
+	if t.Retry == nil {
+		return grsync.RetryPolicy{}
+	}
+	return grsync.RetryPolicy{
+		MaxAttempts: t.Retry.MaxAttempts,
+		Delay:       time.Duration(t.Retry.Delay),
+		Backoff:     t.Retry.Backoff,
+		MaxDelay:    time.Duration(t.Retry.MaxDelay),
+		Resume:      t.Retry.Resume,
+	}
+}
+
+// CommandHooks returns the configured pre and post hooks
+func (t Task) CommandHooks() (pre, post []grsync.CommandHook, err error) {
+	if pre, err = commandHooks(t.Pre, "pre"); err != nil {
+		return nil, nil, err
+	}
+	if post, err = commandHooks(t.Post, "post"); err != nil {
+		return nil, nil, err
+	}
+	return pre, post, nil
+}
+
+func commandHooks(hooks []Hook, phase string) ([]grsync.CommandHook, error) {
+	var commandHooks []grsync.CommandHook
+	for i, h := range hooks {
+		if len(h.Command) == 0 {
+			return nil, fmt.Errorf("%s hook %d has no command", phase, i+1)
+		}
+		policy, err := parseHookPolicy(h.OnFailure)
+		if err != nil {
+			return nil, err
+		}
+		commandHooks = append(commandHooks, grsync.CommandHook{
+			Name:    h.Name,
+			Command: h.Command,
+			Timeout: time.Duration(h.Timeout),
+			Policy:  policy,
+		})
+	}
+	return commandHooks, nil
+}
+
+// NewTask creates a task from the definition and applies the retry policy, the path variables,
+// the bandwidth schedule and the hooks
+func (t Task) NewTask() (*grsync.Task, error) {
+	definition, err := t.Definition()
+	if err != nil {
+		return nil, err
+	}
+	setup, err := t.setup()
+	if err != nil {
+		return nil, err
+	}
+	task, err := definition.NewTask()
+	if err != nil {
+		return nil, err
+	}
+	setup(task)
+	return task, nil
+}
+
+// setup returns the function applying the retry policy, the path variables, the bandwidth
+// schedule and the hooks to a task created from the definition
+func (t Task) setup() (func(task *grsync.Task), error) {
+	policy := t.RetryPolicy()
+	pre, post, err := t.CommandHooks()
+	if err != nil {
+		return nil, err
+	}
+	bandwidth, err := grsync.ParseBandwidthSchedule(t.BandwidthSchedule)
+	if err != nil {
+		return nil, err
+	}
+	vars, cleanup := t.Vars, t.CleanupOnCancel
+	return func(task *grsync.Task) {
+		task.SetRetryPolicy(policy)
+		task.SetPathVars(vars)
+		task.SetBandwidthSchedule(bandwidth)
+		task.SetCleanupOnCancel(cleanup)
+		// the hooks have a command, so they can't be rejected
+		for _, hook := range pre {
+			_ = task.AddPreHook(hook)
+		}
+		for _, hook := range post {
+			_ = task.AddPostHook(hook)
+		}
+	}, nil
+}
+
+// Job converts a scheduled task into a grsync.Job
+func (t Task) Job() (grsync.Job, error) {
+	var job grsync.Job
+	if t.Name == "" {
+		return job, fmt.Errorf("scheduled tasks need a name")
+	}
+	if t.Schedule == "" {
+		return job, fmt.Errorf("task %q has no schedule", t.Name)
+	}
+
+	definition, err := t.Definition()
+	if err != nil {
+		return job, err
+	}
+	schedule, err := grsync.ParseCron(t.Schedule)
+	if err != nil {
+		return job, err
+	}
+	overlap, err := parseOverlap(t.Overlap)
+	if err != nil {
+		return job, err
+	}
+
+	setup, err := t.setup()
+	if err != nil {
+		return job, err
+	}
+	// every run of a job creates a new task, so the ID of the definition would collide in a Manager
+	definition.ID = ""
+	return grsync.Job{
+		Name:       t.Name,
+		Schedule:   schedule,
+		Overlap:    overlap,
+		Definition: definition,
+		Setup:      setup,
+	}, nil
+}
+
+// NewTasks creates the tasks without a schedule
+func (c *Config) NewTasks() ([]*grsync.Task, error) {
+	var tasks []*grsync.Task
+	for _, t := range c.Tasks {
+		if t.Schedule != "" {
+			continue
+		}
+		task, err := t.NewTask()
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// NewScheduler returns a scheduler with a job for every task with a schedule. It is not started
+func (c *Config) NewScheduler() (*grsync.Scheduler, error) {
+	scheduler := grsync.NewScheduler()
+	for _, t := range c.Tasks {
+		if t.Schedule == "" {
+			continue
+		}
+		job, err := t.Job()
+		if err != nil {
+			return nil, err
+		}
+		if err = scheduler.Add(job); err != nil {
+			return nil, err
+		}
+	}
+	return scheduler, nil
+}
+
+func parseOverlap(s string) (grsync.OverlapPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "skip":
+		return grsync.OverlapSkip, nil
+	case "queue":
+		return grsync.OverlapQueue, nil
+	case "kill":
+		return grsync.OverlapKill, nil
+	}
+	return grsync.OverlapSkip, fmt.Errorf("unknown overlap policy %q", s)
+}
+
+func parseHookPolicy(s string) (grsync.HookPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "abort":
+		return grsync.HookAbort, nil
+	case "warn":
+		return grsync.HookWarn, nil
+	}
+	return grsync.HookAbort, fmt.Errorf("unknown hook policy %q", s)
+}
+
+// decodeOptions maps the option keys onto the fields of grsync.RsyncOptions
+func decodeOptions(options map[string]interface{}) (grsync.RsyncOptions, error) {
+	var rsyncOptions grsync.RsyncOptions
+	if len(options) == 0 {
+		return rsyncOptions, nil
+	}
+
+	// encoding/json matches field names case-insensitively, so only separators have to go
+	normalized := make(map[string]interface{}, len(options))
+	for key, value := range options {
+		normalized[strings.NewReplacer("-", "", "_", "").Replace(key)] = value
+	}
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return rsyncOptions, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&rsyncOptions); err != nil {
+		return rsyncOptions, fmt.Errorf("options: %w", err)
+	}
+	return rsyncOptions, nil
+}
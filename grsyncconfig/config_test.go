@@ -0,0 +1,184 @@
+package grsyncconfig
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfig = `
+tasks:
+  - name: nightly
+    source: /data/
+    destination: backup::data
+    lockDestination: true
+    labels:
+      tenant: acme
+    options:
+      archive: true
+      delete: true
+      bandwidth-limit: 1000
+      exclude: ["*.tmp", "cache/"]
+    schedule: "30 2 * * *"
+    overlap: queue
+    retry:
+      maxAttempts: 3
+      delay: 30s
+      backoff: 2
+      maxDelay: 5m
+      resume: true
+  - name: once
+    source: /a/
+    destination: /b/
+`
+
+const tomlConfig = `
+[[tasks]]
+name = "nightly"
+source = "/data/"
+destination = "backup::data"
+lockDestination = true
+schedule = "30 2 * * *"
+overlap = "queue"
+
+[tasks.labels]
+tenant = "acme"
+
+[tasks.options]
+archive = true
+delete = true
+bandwidthLimit = 1000
+exclude = ["*.tmp", "cache/"]
+
+[tasks.retry]
+maxAttempts = 3
+delay = "30s"
+backoff = 2.0
+maxDelay = "5m"
+resume = true
+
+[[tasks]]
+name = "once"
+source = "/a/"
+destination = "/b/"
+`
+
+const jsonConfig = `{"tasks": [
+	{"name": "nightly", "source": "/data/", "destination": "backup::data", "lockDestination": true, "labels": {"tenant": "acme"},
+	 "options": {"Archive": true, "delete": true, "bandwidth_limit": 1000, "exclude": ["*.tmp", "cache/"]},
+	 "schedule": "30 2 * * *", "overlap": "queue",
+	 "retry": {"maxAttempts": 3, "delay": "30s", "backoff": 2, "maxDelay": "5m", "resume": true}},
+	{"name": "once", "source": "/a/", "destination": "/b/"}
+]}`
+
+func TestParse(t *testing.T) {
+	for format, data := range map[Format]string{YAML: yamlConfig, TOML: tomlConfig, JSON: jsonConfig} {
+		t.Run(string(format), func(t *testing.T) {
+			config, err := Parse([]byte(data), format)
+			assert.Nil(t, err)
+			assert.Len(t, config.Tasks, 2)
+
+			nightly := config.Tasks[0]
+			definition, err := nightly.Definition()
+			assert.Nil(t, err)
+			assert.Equal(t, grsync.Definition{
+				ID:              "nightly",
+				Source:          "/data/",
+				Destination:     "backup::data",
+				LockDestination: true,
+				Labels:          map[string]string{"tenant": "acme"},
+				Options: grsync.RsyncOptions{
+					Archive:        true,
+					Delete:         true,
+					BandwidthLimit: 1000,
+					Exclude:        []string{"*.tmp", "cache/"},
+				},
+			}, definition)
+			assert.Equal(t, grsync.RetryPolicy{MaxAttempts: 3, Delay: 30 * time.Second, Backoff: 2, MaxDelay: 5 * time.Minute, Resume: true}, nightly.RetryPolicy())
+
+			job, err := nightly.Job()
+			assert.Nil(t, err)
+			assert.Equal(t, "nightly", job.Name)
+			assert.Equal(t, grsync.OverlapQueue, job.Overlap)
+			assert.Empty(t, job.Definition.ID)
+			assert.NotNil(t, job.Setup)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown key":          "tasks:\n  - name: a\n    source: a\n    destination: b\n    unknown: 1\n",
+		"unknown option":       "tasks:\n  - source: a\n    destination: b\n    options:\n      turbo: true\n",
+		"missing destination":  "tasks:\n  - source: a\n",
+		"invalid schedule":     "tasks:\n  - name: a\n    source: a\n    destination: b\n    schedule: sometimes\n",
+		"unnamed schedule":     "tasks:\n  - source: a\n    destination: b\n    schedule: '@daily'\n",
+		"invalid overlap":      "tasks:\n  - name: a\n    source: a\n    destination: b\n    schedule: '@daily'\n    overlap: maybe\n",
+		"invalid duration":     "tasks:\n  - source: a\n    destination: b\n    retry:\n      delay: soon\n",
+		"hook without command": "tasks:\n  - source: a\n    destination: b\n    pre:\n      - name: snapshot\n",
+		"invalid bandwidth":    "tasks:\n  - source: a\n    destination: b\n    bandwidthSchedule: office hours\n",
+		"invalid hook policy":  "tasks:\n  - source: a\n    destination: b\n    post:\n      - command: [true]\n        onFailure: retry\n",
+		"duplicate name":       "tasks:\n  - name: a\n    source: a\n    destination: b\n  - name: a\n    source: a\n    destination: b\n",
+	} {
+		_, err := Parse([]byte(data), YAML)
+		assert.NotNil(t, err, name)
+	}
+
+	_, err := Parse([]byte("[[tasks]]\nsource = 'a'\ndestination = 'b'\nturbo = true\n"), TOML)
+	assert.NotNil(t, err)
+	_, err = Parse(nil, "ini")
+	assert.NotNil(t, err)
+}
+
+func TestHooks(t *testing.T) {
+	const config = `
+tasks:
+  - source: a
+    destination: b
+    pre:
+      - name: snapshot
+        command: [lvcreate, -s, -n, snap, vg/data]
+        timeout: 1m
+    post:
+      - command: [lvremove, -f, vg/snap]
+        onFailure: warn
+`
+	parsed, err := Parse([]byte(config), YAML)
+	assert.Nil(t, err)
+	pre, post, err := parsed.Tasks[0].CommandHooks()
+	assert.Nil(t, err)
+	assert.Equal(t, []grsync.CommandHook{{Name: "snapshot", Command: []string{"lvcreate", "-s", "-n", "snap", "vg/data"}, Timeout: time.Minute}}, pre)
+	assert.Equal(t, []grsync.CommandHook{{Command: []string{"lvremove", "-f", "vg/snap"}, Policy: grsync.HookWarn}}, post)
+
+	_, err = parsed.Tasks[0].NewTask()
+	assert.Nil(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.yml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(yamlConfig), 0644))
+
+	config, err := Load(path)
+	assert.Nil(t, err)
+
+	tasks, err := config.NewTasks()
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, "once", tasks[0].ID())
+
+	scheduler, err := config.NewScheduler()
+	assert.Nil(t, err)
+	status, ok := scheduler.Status("nightly")
+	assert.True(t, ok)
+	assert.Equal(t, "nightly", status.Name)
+
+	_, err = Load(filepath.Join(dir, "tasks.ini"))
+	assert.NotNil(t, err)
+	_, err = Load(filepath.Join(dir, "missing.toml"))
+	assert.NotNil(t, err)
+}
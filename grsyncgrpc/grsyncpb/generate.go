@@ -0,0 +1,4 @@
+// Package grsyncpb contains the protobuf messages and gRPC stubs of the grsync task service
+package grsyncpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative grsync.proto
@@ -0,0 +1,254 @@
+// Package grsyncgrpc serves grsync tasks over gRPC, see grsyncpb for the service definition and client
+package grsyncgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/ByteSizedMarius/grsync/grsyncgrpc/grsyncpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultStreamInterval is the interval in which StreamProgress checks the state of a task for changes
+const DefaultStreamInterval = 500 * time.Millisecond
+
+// fileEventBuffer is the number of file events buffered per stream, further events are dropped
+// for slow clients
+const fileEventBuffer = 64
+
+// Server implements grsyncpb.TaskServiceServer on top of a manager. The server doesn't
+// authenticate clients, configure the grpc.Server with credentials before exposing it
+type Server struct {
+	grsyncpb.UnimplementedTaskServiceServer
+
+	manager *grsync.Manager
+	mutex   sync.Mutex
+	streams map[string]map[chan grsync.FileEvent]struct{}
+
+	// Validate checks submitted definitions before a task is created, DefaultValidate by default
+	Validate func(definition grsync.Definition) error
+	// StreamInterval is the interval in which progress streams poll the state, DefaultStreamInterval by default
+	StreamInterval time.Duration
+}
+
+// NewServer returns a server controlling the tasks of manager. Register it with
+// grsyncpb.RegisterTaskServiceServer
+func NewServer(manager *grsync.Manager) *Server {
+	return &Server{
+		manager:  manager,
+		streams:  make(map[string]map[chan grsync.FileEvent]struct{}),
+		Validate: DefaultValidate,
+	}
+}
+
+// DefaultValidate rejects definitions which execute other programs than rsync, read or write
+// other local files than the source and the destination or escalate privileges, see
+// grsync.ValidateUntrusted
+func DefaultValidate(definition grsync.Definition) error {
+	return grsync.ValidateUntrusted(definition)
+}
+
+// SubmitTask creates a task from the definition and starts it
+func (s *Server) SubmitTask(ctx context.Context, req *grsyncpb.SubmitTaskRequest) (*grsyncpb.Task, error) {
+	var definition grsync.Definition
+	if err := json.Unmarshal([]byte(req.GetDefinition()), &definition); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if s.Validate != nil {
+		if err := s.Validate(definition); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	task, err := definition.NewTask()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if _, ok := s.manager.Get(task.ID()); ok {
+		return nil, status.Errorf(codes.AlreadyExists, "task %q already registered", task.ID())
+	}
+	s.track(task)
+	if err = s.manager.Submit(task); err != nil {
+		s.untrack(task.ID())
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	return toTask(task), nil
+}
+
+// Cancel stops a running task
+func (s *Server) Cancel(ctx context.Context, req *grsyncpb.CancelRequest) (*grsyncpb.Task, error) {
+	task, err := s.task(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err = task.Cancel(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return toTask(task), nil
+}
+
+// ListTasks returns all tasks of the manager
+func (s *Server) ListTasks(ctx context.Context, req *grsyncpb.ListTasksRequest) (*grsyncpb.ListTasksResponse, error) {
+	resp := &grsyncpb.ListTasksResponse{}
+	for _, task := range s.manager.List() {
+		resp.Tasks = append(resp.Tasks, toTask(task))
+	}
+	return resp, nil
+}
+
+// StreamProgress sends the state of the task whenever it changed, the file events of tasks submitted
+// through the server and a final done event once the task finished
+func (s *Server) StreamProgress(req *grsyncpb.StreamProgressRequest, stream grsyncpb.TaskService_StreamProgressServer) error {
+	task, err := s.task(req.GetId())
+	if err != nil {
+		return err
+	}
+
+	fileEvents := s.subscribe(task.ID())
+	defer s.unsubscribe(task.ID(), fileEvents)
+
+	interval := s.StreamInterval
+	if interval <= 0 {
+		interval = DefaultStreamInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *grsync.State
+	sendState := func() error {
+		state := task.State()
+		if last != nil && reflect.DeepEqual(*last, state) {
+			return nil
+		}
+		last = &state
+		return stream.Send(&grsyncpb.ProgressEvent{Event: &grsyncpb.ProgressEvent_State{State: toState(state)}})
+	}
+	sendFile := func(event grsync.FileEvent) error {
+		return stream.Send(&grsyncpb.ProgressEvent{Event: &grsyncpb.ProgressEvent_File{File: toFileEvent(event)}})
+	}
+
+	done := task.Done()
+	if err = sendState(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case event := <-fileEvents:
+			err = sendFile(event)
+		case <-ticker.C:
+			err = sendState()
+		case <-done:
+			for drained := false; !drained && err == nil; {
+				select {
+				case event := <-fileEvents:
+					err = sendFile(event)
+				default:
+					drained = true
+				}
+			}
+			if err == nil {
+				err = sendState()
+			}
+			if err == nil {
+				err = stream.Send(&grsyncpb.ProgressEvent{Event: &grsyncpb.ProgressEvent_Done{Done: toTask(task)}})
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) task(id string) (*grsync.Task, error) {
+	task, ok := s.manager.Get(id)
+	if !ok {
+		return nil, status.Error(codes.NotFound, grsync.ErrTaskNotFound.Error())
+	}
+	return task, nil
+}
+
+// track makes the file events of task available to progress streams
+func (s *Server) track(task *grsync.Task) {
+	id := task.ID()
+	s.mutex.Lock()
+	s.streams[id] = make(map[chan grsync.FileEvent]struct{})
+	s.mutex.Unlock()
+
+	task.OnFileEvent(func(event grsync.FileEvent) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		for ch := range s.streams[id] {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	})
+}
+
+func (s *Server) untrack(id string) {
+	s.mutex.Lock()
+	delete(s.streams, id)
+	s.mutex.Unlock()
+}
+
+// subscribe returns a channel receiving the file events of the task, it never receives anything
+// for tasks not submitted through the server
+func (s *Server) subscribe(id string) chan grsync.FileEvent {
+	ch := make(chan grsync.FileEvent, fileEventBuffer)
+	s.mutex.Lock()
+	if subscribers, ok := s.streams[id]; ok {
+		subscribers[ch] = struct{}{}
+	}
+	s.mutex.Unlock()
+	return ch
+}
+
+func (s *Server) unsubscribe(id string, ch chan grsync.FileEvent) {
+	s.mutex.Lock()
+	delete(s.streams[id], ch)
+	s.mutex.Unlock()
+}
+
+func toTask(task *grsync.Task) *grsyncpb.Task {
+	definition := task.Definition()
+	t := &grsyncpb.Task{
+		Id:          task.ID(),
+		Status:      string(task.Status()),
+		Source:      definition.Source,
+		Destination: definition.Destination,
+		Attempts:    int32(task.Attempts()),
+		State:       toState(task.State()),
+	}
+	if err := task.Err(); err != nil {
+		t.Error = task.Redact(err.Error())
+	}
+	return t
+}
+
+func toState(state grsync.State) *grsyncpb.State {
+	return &grsyncpb.State{
+		TimeRemaining:   state.TimeRemaining,
+		DownloadedTotal: state.DownloadedTotal,
+		Speed:           state.Speed,
+		Progress:        int32(state.Progress),
+		Custom:          state.Custom,
+	}
+}
+
+func toFileEvent(event grsync.FileEvent) *grsyncpb.FileEvent {
+	return &grsyncpb.FileEvent{
+		Op:      string(event.Op),
+		Itemize: event.Itemize,
+		Size:    event.Size,
+		Path:    event.Path,
+	}
+}
@@ -0,0 +1,173 @@
+package grsyncgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/ByteSizedMarius/grsync/grsyncgrpc/grsyncpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func fakeRsync(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "rsync")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	return path
+}
+
+// rejected are definitions DefaultValidate rejects, by the option they set
+var rejected = map[string]grsync.Definition{
+	"Source":                 {Source: "--rsh=sh -c 'id > /tmp/x' #", Destination: "x:y"},
+	"Destination":            {Source: "a", Destination: "-e.sh"},
+	"RsyncBinaryPath":        {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RsyncBinaryPath: "/tmp/rsync"}},
+	"Rsh":                    {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Rsh: "sh -c id"}},
+	"RemoteShell":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteShell: grsync.RemoteShell{Command: "/tmp/evil"}}},
+	"RsyncPath":              {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RsyncPath: "touch /tmp/x; rsync"}},
+	"RemoteSudo":             {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteSudo: true}},
+	"RemoteSudoUser":         {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteSudoUser: "root"}},
+	"RemoteSudoPasswordFile": {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteSudoPasswordFile: "/etc/shadow"}},
+	"ClearEnv":               {Source: "a", Destination: "b", Options: grsync.RsyncOptions{ClearEnv: true}},
+	"Env":                    {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Env: []string{"LD_PRELOAD=/tmp/evil.so"}}},
+	"Credential":             {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Credential: &grsync.Credential{}}},
+	"WorkDir":                {Source: "a", Destination: "b", Options: grsync.RsyncOptions{WorkDir: "/etc"}},
+	"Limits.Cgroup":          {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Limits: grsync.ResourceLimits{Cgroup: "/sys/fs/cgroup"}}},
+	"Priority":               {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Priority: grsync.Priority{Nice: -10}}},
+	"CopyAs":                 {Source: "a", Destination: "b", Options: grsync.RsyncOptions{CopyAs: "root"}},
+	"PasswordFile":           {Source: "a", Destination: "b", Options: grsync.RsyncOptions{PasswordFile: "/etc/shadow"}},
+	"ExcludeFrom":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{ExcludeFrom: "/etc/shadow"}},
+	"IncludeFrom":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{IncludeFrom: "/etc/shadow"}},
+	"FilesFrom":              {Source: "a", Destination: "b", Options: grsync.RsyncOptions{FilesFrom: "/etc/shadow"}},
+	"IgnoreFiles":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{IgnoreFiles: []string{"/etc/shadow"}}},
+	"Filter":                 {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Filter: "merge /etc/shadow"}},
+	"ReadBatch":              {Source: "a", Destination: "b", Options: grsync.RsyncOptions{ReadBatch: "/tmp/batch"}},
+	"WriteBatch":             {Source: "a", Destination: "b", Options: grsync.RsyncOptions{WriteBatch: "/tmp/batch"}},
+	"OnlyWriteBatch":         {Source: "a", Destination: "b", Options: grsync.RsyncOptions{OnlyWriteBatch: "/tmp/batch"}},
+	"LogFile":                {Source: "a", Destination: "b", Options: grsync.RsyncOptions{LogFile: "/etc/cron.d/x"}},
+}
+
+func definition(t *testing.T, id, script string) string {
+	data, err := json.Marshal(grsync.Definition{
+		ID:          id,
+		Source:      "a",
+		Destination: "b",
+		Options:     grsync.RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)},
+	})
+	assert.Nil(t, err)
+	return string(data)
+}
+
+// serve starts the server on an in-memory listener and returns a client connected to it
+func serve(t *testing.T, server *Server) grsyncpb.TaskServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	grsyncpb.RegisterTaskServiceServer(s, server)
+	go func() { _ = s.Serve(listener) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return grsyncpb.NewTaskServiceClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	manager := grsync.NewManager()
+	server := NewServer(manager)
+	server.Validate = nil
+	server.StreamInterval = 10 * time.Millisecond
+	client := serve(t, server)
+	ctx := context.Background()
+
+	script := `sleep 0.2
+echo "::grsync-file:: >f+++++++++ 5 file.txt"
+echo "         10 100%    1.00kB/s    0:00:00"`
+	task, err := client.SubmitTask(ctx, &grsyncpb.SubmitTaskRequest{Definition: definition(t, "ok", script)})
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", task.GetId())
+
+	stream, err := client.StreamProgress(ctx, &grsyncpb.StreamProgressRequest{Id: "ok"})
+	assert.Nil(t, err)
+	var events []*grsyncpb.ProgressEvent
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		events = append(events, event)
+	}
+
+	var file *grsyncpb.FileEvent
+	var progress int32
+	for _, event := range events {
+		if event.GetFile() != nil {
+			file = event.GetFile()
+		}
+		if event.GetState() != nil {
+			progress = event.GetState().GetProgress()
+		}
+	}
+	assert.Equal(t, "file.txt", file.GetPath())
+	assert.Equal(t, int32(100), progress)
+	done := events[len(events)-1].GetDone()
+	assert.Equal(t, "succeeded", done.GetStatus())
+	assert.Equal(t, int32(1), done.GetAttempts())
+
+	_, err = client.SubmitTask(ctx, &grsyncpb.SubmitTaskRequest{Definition: definition(t, "slow", "exec sleep 5")})
+	assert.Nil(t, err)
+	slow, _ := manager.Get("slow")
+	assert.Eventually(t, func() bool { return slow.Status() == grsync.TaskRunning }, time.Second, 10*time.Millisecond)
+
+	list, err := client.ListTasks(ctx, &grsyncpb.ListTasksRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, list.GetTasks(), 2)
+
+	_, err = client.Cancel(ctx, &grsyncpb.CancelRequest{Id: "slow"})
+	assert.Nil(t, err)
+	<-slow.Done()
+	assert.Equal(t, grsync.TaskCancelled, slow.Status())
+}
+
+func TestServerRejectsUntrusted(t *testing.T) {
+	client := serve(t, NewServer(grsync.NewManager()))
+
+	for option, d := range rejected {
+		data, err := json.Marshal(d)
+		assert.Nil(t, err)
+		_, err = client.SubmitTask(context.Background(), &grsyncpb.SubmitTaskRequest{Definition: string(data)})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), option)
+		assert.Contains(t, status.Convert(err).Message(), "may not be set", option)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	client := serve(t, NewServer(grsync.NewManager()))
+	ctx := context.Background()
+
+	_, err := client.SubmitTask(ctx, &grsyncpb.SubmitTaskRequest{Definition: "{"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.SubmitTask(ctx, &grsyncpb.SubmitTaskRequest{Definition: definition(t, "", "exit 0")})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Cancel(ctx, &grsyncpb.CancelRequest{Id: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.StreamProgress(ctx, &grsyncpb.StreamProgressRequest{Id: "unknown"})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,238 @@
+// Package grsynchttp provides an http.Handler to submit, inspect and cancel grsync tasks
+package grsynchttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+)
+
+// maxBodySize limits the size of submitted definitions
+const maxBodySize = 1 << 20
+
+// TaskInfo is the JSON representation of a task
+type TaskInfo struct {
+	ID         string            `json:"id"`
+	Status     grsync.TaskStatus `json:"status"`
+	Definition grsync.Definition `json:"definition"`
+	State      grsync.State      `json:"state"`
+	Attempts   int               `json:"attempts"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// NewTaskInfo describes task
+func NewTaskInfo(task *grsync.Task) TaskInfo {
+	info := TaskInfo{
+		ID:         task.ID(),
+		Status:     task.Status(),
+		Definition: task.Definition().Redacted(),
+		State:      task.State(),
+		Attempts:   task.Attempts(),
+	}
+	if err := task.Err(); err != nil {
+		info.Error = task.Redact(err.Error())
+	}
+	return info
+}
+
+// Handler serves the tasks of a manager:
+//
+//	POST   /tasks              submit a grsync.Definition, responds with the TaskInfo of the started task
+//	GET    /tasks              list all tasks
+//	GET    /tasks/{id}         get a task
+//	GET    /tasks/{id}/log     get the accumulated output of a task
+//	GET    /tasks/{id}/events  stream the progress of a task as server-sent events
+//	POST   /tasks/{id}/cancel  cancel a task
+//	DELETE /tasks/{id}         cancel a task and remove it from the manager
+//	GET    /group              get the grsync.GroupState of the tasks carrying the labels of the query, e.g. ?batch=nightly
+//
+// Mount it with http.StripPrefix to serve it below a path. The handler doesn't authenticate
+// clients, wrap it in a handler that does before exposing it
+type Handler struct {
+	manager *grsync.Manager
+	mutex   sync.Mutex
+	hubs    map[string]*hub
+
+	// Validate checks submitted definitions before a task is created, DefaultValidate by default
+	Validate func(definition grsync.Definition) error
+	// StreamInterval is the interval in which event streams poll the state, DefaultStreamInterval by default
+	StreamInterval time.Duration
+}
+
+// NewHandler returns a handler serving the tasks of manager
+func NewHandler(manager *grsync.Manager) *Handler {
+	return &Handler{
+		manager:  manager,
+		hubs:     make(map[string]*hub),
+		Validate: DefaultValidate,
+	}
+}
+
+// DefaultValidate rejects definitions which execute other programs than rsync, read or write
+// other local files than the source and the destination or escalate privileges, see
+// grsync.ValidateUntrusted
+func DefaultValidate(definition grsync.Definition) error {
+	return grsync.ValidateUntrusted(definition)
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "group" {
+		h.group(w, r)
+		return
+	}
+	if parts[0] != "tasks" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			h.list(w)
+		case http.MethodPost:
+			h.submit(w, r)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	task, ok := h.manager.Get(parts[1])
+	if !ok {
+		writeError(w, http.StatusNotFound, grsync.ErrTaskNotFound)
+		return
+	}
+	if len(parts) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, NewTaskInfo(task))
+		case http.MethodDelete:
+			h.remove(w, task)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+		return
+	}
+	h.serveTask(w, r, task, parts[2])
+}
+
+// serveTask serves the sub-resources of a task
+func (h *Handler) serveTask(w http.ResponseWriter, r *http.Request, task *grsync.Task, resource string) {
+	switch resource {
+	case "log":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeJSON(w, http.StatusOK, task.Log())
+	case "events":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		h.stream(w, r, task)
+	case "cancel":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		h.cancel(w, task)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *Handler) list(w http.ResponseWriter) {
+	infos := []TaskInfo{}
+	for _, task := range h.manager.List() {
+		infos = append(infos, NewTaskInfo(task))
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (h *Handler) group(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	labels := make(map[string]string)
+	for key, values := range r.URL.Query() {
+		labels[key] = values[0]
+	}
+	writeJSON(w, http.StatusOK, h.manager.GroupState(labels))
+}
+
+func (h *Handler) submit(w http.ResponseWriter, r *http.Request) {
+	var definition grsync.Definition
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definition); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if h.Validate != nil {
+		if err := h.Validate(definition); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+
+	task, err := definition.NewTask()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, ok := h.manager.Get(task.ID()); ok {
+		writeError(w, http.StatusConflict, fmt.Errorf("task %q already registered", task.ID()))
+		return
+	}
+	h.track(task)
+	if err = h.manager.Submit(task); err != nil {
+		h.untrack(task.ID())
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, NewTaskInfo(task))
+}
+
+func (h *Handler) cancel(w http.ResponseWriter, task *grsync.Task) {
+	if err := task.Cancel(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, NewTaskInfo(task))
+}
+
+func (h *Handler) remove(w http.ResponseWriter, task *grsync.Task) {
+	if err := task.Cancel(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.manager.Remove(task.ID())
+	h.untrack(task.ID())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,158 @@
+package grsynchttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeRsync(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "rsync")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	return path
+}
+
+func request(t *testing.T, handler http.Handler, method, path, body string, v interface{}) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil {
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), v), rec.Body.String())
+	}
+	return rec.Code
+}
+
+// rejected are definitions DefaultValidate rejects, by the option they set
+var rejected = map[string]grsync.Definition{
+	"Source":                 {Source: "--rsh=sh -c 'id > /tmp/x' #", Destination: "x:y"},
+	"Destination":            {Source: "a", Destination: "-e.sh"},
+	"RsyncBinaryPath":        {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RsyncBinaryPath: "/tmp/rsync"}},
+	"Rsh":                    {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Rsh: "sh -c id"}},
+	"RemoteShell":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteShell: grsync.RemoteShell{Command: "/tmp/evil"}}},
+	"RsyncPath":              {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RsyncPath: "touch /tmp/x; rsync"}},
+	"RemoteSudo":             {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteSudo: true}},
+	"RemoteSudoUser":         {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteSudoUser: "root"}},
+	"RemoteSudoPasswordFile": {Source: "a", Destination: "b", Options: grsync.RsyncOptions{RemoteSudoPasswordFile: "/etc/shadow"}},
+	"ClearEnv":               {Source: "a", Destination: "b", Options: grsync.RsyncOptions{ClearEnv: true}},
+	"Env":                    {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Env: []string{"LD_PRELOAD=/tmp/evil.so"}}},
+	"Credential":             {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Credential: &grsync.Credential{}}},
+	"WorkDir":                {Source: "a", Destination: "b", Options: grsync.RsyncOptions{WorkDir: "/etc"}},
+	"Limits.Cgroup":          {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Limits: grsync.ResourceLimits{Cgroup: "/sys/fs/cgroup"}}},
+	"Priority":               {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Priority: grsync.Priority{Nice: -10}}},
+	"CopyAs":                 {Source: "a", Destination: "b", Options: grsync.RsyncOptions{CopyAs: "root"}},
+	"PasswordFile":           {Source: "a", Destination: "b", Options: grsync.RsyncOptions{PasswordFile: "/etc/shadow"}},
+	"ExcludeFrom":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{ExcludeFrom: "/etc/shadow"}},
+	"IncludeFrom":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{IncludeFrom: "/etc/shadow"}},
+	"FilesFrom":              {Source: "a", Destination: "b", Options: grsync.RsyncOptions{FilesFrom: "/etc/shadow"}},
+	"IgnoreFiles":            {Source: "a", Destination: "b", Options: grsync.RsyncOptions{IgnoreFiles: []string{"/etc/shadow"}}},
+	"Filter":                 {Source: "a", Destination: "b", Options: grsync.RsyncOptions{Filter: "merge /etc/shadow"}},
+	"ReadBatch":              {Source: "a", Destination: "b", Options: grsync.RsyncOptions{ReadBatch: "/tmp/batch"}},
+	"WriteBatch":             {Source: "a", Destination: "b", Options: grsync.RsyncOptions{WriteBatch: "/tmp/batch"}},
+	"OnlyWriteBatch":         {Source: "a", Destination: "b", Options: grsync.RsyncOptions{OnlyWriteBatch: "/tmp/batch"}},
+	"LogFile":                {Source: "a", Destination: "b", Options: grsync.RsyncOptions{LogFile: "/etc/cron.d/x"}},
+}
+
+func definition(t *testing.T, id, script string) string {
+	data, err := json.Marshal(grsync.Definition{
+		ID:          id,
+		Source:      "a",
+		Destination: "b",
+		Options:     grsync.RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)},
+	})
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func TestHandler(t *testing.T) {
+	manager := grsync.NewManager()
+	handler := NewHandler(manager)
+	handler.Validate = nil
+
+	var info TaskInfo
+	assert.Equal(t, http.StatusCreated, request(t, handler, http.MethodPost, "/tasks", definition(t, "ok", "echo hello"), &info))
+	assert.Equal(t, "ok", info.ID)
+	assert.Nil(t, manager.Wait("ok"))
+
+	assert.Equal(t, http.StatusOK, request(t, handler, http.MethodGet, "/tasks/ok", "", &info))
+	assert.Equal(t, grsync.TaskSucceeded, info.Status)
+	assert.Equal(t, "a", info.Definition.Source)
+	assert.Equal(t, 1, info.Attempts)
+
+	var log grsync.Log
+	assert.Equal(t, http.StatusOK, request(t, handler, http.MethodGet, "/tasks/ok/log", "", &log))
+	assert.Equal(t, "hello\n", log.Stdout)
+
+	var errBody map[string]string
+	assert.Equal(t, http.StatusConflict, request(t, handler, http.MethodPost, "/tasks", definition(t, "ok", "exit 0"), &errBody))
+	assert.NotEmpty(t, errBody["error"])
+
+	assert.Equal(t, http.StatusCreated, request(t, handler, http.MethodPost, "/tasks", definition(t, "slow", "exec sleep 5"), nil))
+	task, _ := manager.Get("slow")
+	assert.Eventually(t, func() bool { return task.Status() == grsync.TaskRunning }, time.Second, 10*time.Millisecond)
+
+	var infos []TaskInfo
+	assert.Equal(t, http.StatusOK, request(t, handler, http.MethodGet, "/tasks", "", &infos))
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "ok", infos[0].ID)
+	assert.Equal(t, "slow", infos[1].ID)
+
+	assert.Equal(t, http.StatusOK, request(t, handler, http.MethodPost, "/tasks/slow/cancel", "", nil))
+	select {
+	case <-task.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("task not cancelled")
+	}
+	assert.Equal(t, grsync.TaskCancelled, task.Status())
+
+	var group grsync.GroupState
+	assert.Equal(t, http.StatusOK, request(t, handler, http.MethodGet, "/group", "", &group))
+	assert.Equal(t, 2, group.Tasks)
+	assert.Equal(t, 1, group.Succeeded)
+	assert.Equal(t, 1, group.Cancelled)
+	assert.Equal(t, http.StatusOK, request(t, handler, http.MethodGet, "/group?batch=nightly", "", &group))
+	assert.Equal(t, 0, group.Tasks)
+
+	assert.Equal(t, http.StatusNoContent, request(t, handler, http.MethodDelete, "/tasks/slow", "", nil))
+	assert.Equal(t, http.StatusNotFound, request(t, handler, http.MethodGet, "/tasks/slow", "", nil))
+}
+
+func TestHandlerRejectsUntrusted(t *testing.T) {
+	handler := NewHandler(grsync.NewManager())
+
+	for option, d := range rejected {
+		data, err := json.Marshal(d)
+		assert.Nil(t, err)
+		var errBody map[string]string
+		assert.Equal(t, http.StatusUnprocessableEntity, request(t, handler, http.MethodPost, "/tasks", string(data), &errBody), option)
+		assert.Contains(t, errBody["error"], "may not be set", option)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	handler := NewHandler(grsync.NewManager())
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{http.MethodGet, "/other", "", http.StatusNotFound},
+		{http.MethodGet, "/tasks/unknown", "", http.StatusNotFound},
+		{http.MethodPut, "/tasks", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/tasks", "{", http.StatusBadRequest},
+		{http.MethodPost, "/tasks", `{"unknown": true}`, http.StatusBadRequest},
+		{http.MethodPost, "/tasks", definition(t, "", "exit 0"), http.StatusUnprocessableEntity},
+		{http.MethodPost, "/tasks", `{"source": "a", "destination": "b", "options": {"Rsh": "sh -c id"}}`, http.StatusUnprocessableEntity},
+	} {
+		var errBody map[string]string
+		assert.Equal(t, c.status, request(t, handler, c.method, c.path, c.body, &errBody), c.method+" "+c.path)
+		assert.NotEmpty(t, errBody["error"], c.method+" "+c.path)
+	}
+}
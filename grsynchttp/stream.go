@@ -0,0 +1,159 @@
+package grsynchttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+)
+
+// DefaultStreamInterval is the interval in which event streams check the state of a task for changes
+const DefaultStreamInterval = 500 * time.Millisecond
+
+// fileEventBuffer is the number of file events buffered per stream, further events are dropped
+// for slow clients
+const fileEventBuffer = 64
+
+// hub fans the file events of a task out to its streams
+type hub struct {
+	mutex       sync.Mutex
+	subscribers map[chan grsync.FileEvent]struct{}
+}
+
+func (h *hub) subscribe() chan grsync.FileEvent {
+	ch := make(chan grsync.FileEvent, fileEventBuffer)
+	h.mutex.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mutex.Unlock()
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan grsync.FileEvent) {
+	h.mutex.Lock()
+	delete(h.subscribers, ch)
+	h.mutex.Unlock()
+}
+
+func (h *hub) publish(event grsync.FileEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// track makes the file events of task available to streams. Only tasks submitted through the
+// handler are tracked, streams of other tasks carry state updates only
+func (h *Handler) track(task *grsync.Task) {
+	events := &hub{subscribers: make(map[chan grsync.FileEvent]struct{})}
+	task.OnFileEvent(events.publish)
+
+	h.mutex.Lock()
+	h.hubs[task.ID()] = events
+	h.mutex.Unlock()
+}
+
+func (h *Handler) untrack(id string) {
+	h.mutex.Lock()
+	delete(h.hubs, id)
+	h.mutex.Unlock()
+}
+
+// stream sends the state of the task as server-sent events until it finished or the client went away:
+//
+//	event: state  data: grsync.State, whenever it changed
+//	event: file   data: grsync.FileEvent, for every transferred file
+//	event: done   data: TaskInfo, once the task finished
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, task *grsync.Task) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+
+	var fileEvents chan grsync.FileEvent
+	h.mutex.Lock()
+	events := h.hubs[task.ID()]
+	h.mutex.Unlock()
+	if events != nil {
+		fileEvents = events.subscribe()
+		defer events.unsubscribe(fileEvents)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	interval := h.StreamInterval
+	if interval <= 0 {
+		interval = DefaultStreamInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *grsync.State
+	sendState := func() error {
+		state := task.State()
+		if last != nil && reflect.DeepEqual(*last, state) {
+			return nil
+		}
+		last = &state
+		return writeEvent(w, flusher, "state", state)
+	}
+
+	done := task.Done()
+	if err := sendState(); err != nil {
+		return
+	}
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-fileEvents:
+			err = writeEvent(w, flusher, "file", event)
+		case <-ticker.C:
+			err = sendState()
+		case <-done:
+			for drained := false; !drained && err == nil; {
+				select {
+				case event := <-fileEvents:
+					err = writeEvent(w, flusher, "file", event)
+				default:
+					drained = true
+				}
+			}
+			if err == nil {
+				err = sendState()
+			}
+			if err == nil {
+				_ = writeEvent(w, flusher, "done", NewTaskInfo(task))
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
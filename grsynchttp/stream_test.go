@@ -0,0 +1,89 @@
+package grsynchttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+)
+
+type event struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, url string) []event {
+	resp, err := http.Get(url)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events []event
+	var current event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, current)
+			current = event{}
+		}
+	}
+	return events
+}
+
+func TestStream(t *testing.T) {
+	manager := grsync.NewManager()
+	handler := NewHandler(manager)
+	handler.Validate = nil
+	handler.StreamInterval = 10 * time.Millisecond
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	script := `sleep 0.2
+echo "::grsync-file:: >f+++++++++ 5 file.txt"
+echo "          5  50%    1.00kB/s    0:00:01"
+sleep 0.2
+echo "         10 100%    1.00kB/s    0:00:00"`
+	assert.Equal(t, http.StatusCreated, request(t, handler, http.MethodPost, "/tasks", definition(t, "stream", script), nil))
+
+	events := readEvents(t, server.URL+"/tasks/stream/events")
+	assert.True(t, len(events) >= 4, events)
+	assert.Equal(t, "state", events[0].name)
+
+	var file grsync.FileEvent
+	var progress []int
+	for _, e := range events {
+		switch e.name {
+		case "file":
+			assert.Nil(t, json.Unmarshal([]byte(e.data), &file))
+		case "state":
+			var state grsync.State
+			assert.Nil(t, json.Unmarshal([]byte(e.data), &state))
+			progress = append(progress, state.Progress)
+		}
+	}
+	assert.Equal(t, "file.txt", file.Path)
+	assert.Equal(t, 100, progress[len(progress)-1])
+	assert.Contains(t, progress, 50)
+
+	last := events[len(events)-1]
+	assert.Equal(t, "done", last.name)
+	var info TaskInfo
+	assert.Nil(t, json.Unmarshal([]byte(last.data), &info))
+	assert.Equal(t, grsync.TaskSucceeded, info.Status)
+
+	// streams of finished tasks end right away
+	events = readEvents(t, server.URL+"/tasks/stream/events")
+	assert.Equal(t, "done", events[len(events)-1].name)
+}
@@ -0,0 +1,111 @@
+// Package grsyncotel records grsync task runs as OpenTelemetry spans
+package grsyncotel
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ByteSizedMarius/grsync"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ByteSizedMarius/grsync/grsyncotel"
+
+// Instrument registers hooks on the task which record every run as a span with a child span per
+// attempt. Spans are children of the span in ctx, if any. A nil provider leaves the task untouched
+func Instrument(ctx context.Context, task *grsync.Task, provider trace.TracerProvider) {
+	if provider == nil {
+		return
+	}
+	tracer := provider.Tracer(instrumentationName)
+
+	var (
+		mutex   sync.Mutex
+		run     trace.Span
+		attempt trace.Span
+		runCtx  context.Context
+	)
+
+	startAttempt := func(number int) {
+		_, attempt = tracer.Start(runCtx, "rsync attempt", trace.WithAttributes(attribute.Int("grsync.attempt", number)))
+	}
+	finish := func(task *grsync.Task, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if run == nil {
+			return
+		}
+
+		attributes := resultAttributes(task, err)
+		attempt.SetAttributes(attributes...)
+		end(attempt, err)
+		run.SetAttributes(attributes...)
+		run.SetAttributes(attribute.Int("grsync.attempts", task.Attempts()))
+		end(run, err)
+		run, attempt = nil, nil
+	}
+
+	task.AddHooks(grsync.Hooks{
+		OnStart: func(task *grsync.Task) {
+			definition := task.Definition()
+			attributes := []attribute.KeyValue{
+				attribute.String("grsync.task", task.ID()),
+				attribute.String("grsync.source", definition.Source),
+				attribute.String("grsync.destination", definition.Destination),
+			}
+			attributes = append(attributes, labelAttributes(definition.Labels)...)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			runCtx, run = tracer.Start(ctx, "rsync", trace.WithAttributes(attributes...))
+			startAttempt(1)
+		},
+		OnRetry: func(task *grsync.Task, number int, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if run == nil {
+				return
+			}
+			attempt.SetAttributes(resultAttributes(task, err)...)
+			end(attempt, err)
+			startAttempt(number)
+		},
+		OnComplete: func(task *grsync.Task) { finish(task, nil) },
+		OnError:    finish,
+	})
+}
+
+// resultAttributes describes the outcome of a run or attempt
+func resultAttributes(task *grsync.Task, err error) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{attribute.Int("grsync.exit_code", int(grsync.ExitCodeOf(err)))}
+	if state := task.State(); state.DownloadedTotal != "" {
+		attributes = append(attributes, attribute.Int64("grsync.bytes", state.BytesTransferred))
+	}
+	return attributes
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// labelAttributes returns the task labels as "grsync.label.<key>" attributes, sorted by key
+func labelAttributes(labels map[string]string) []attribute.KeyValue {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]attribute.KeyValue, len(keys))
+	for i, key := range keys {
+		attributes[i] = attribute.String("grsync.label."+key, labels[key])
+	}
+	return attributes
+}
@@ -0,0 +1,104 @@
+package grsyncotel
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func fakeTask(t *testing.T, script string) *grsync.Task {
+	path := filepath.Join(t.TempDir(), "rsync")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+
+	task, err := grsync.NewTask("/src", "/dst", false, false, grsync.RsyncOptions{RsyncBinaryPath: path})
+	assert.Nil(t, err)
+	return task
+}
+
+func attributeValue(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestInstrument(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		task := fakeTask(t, `echo "      2.00K  100%    1.00kB/s    0:00:00"`)
+		Instrument(context.Background(), task, provider)
+		assert.Nil(t, task.Run())
+
+		spans := recorder.Ended()
+		assert.Len(t, spans, 2)
+		attempt, run := spans[0], spans[1]
+		assert.Equal(t, "rsync attempt", attempt.Name())
+		assert.Equal(t, "rsync", run.Name())
+		assert.Equal(t, run.SpanContext().SpanID(), attempt.Parent().SpanID())
+		assert.Equal(t, codes.Unset, run.Status().Code)
+
+		source, _ := attributeValue(run, "grsync.source")
+		assert.Equal(t, "/src", source.AsString())
+		bytes, _ := attributeValue(run, "grsync.bytes")
+		assert.Equal(t, int64(2000), bytes.AsInt64())
+		exitCode, _ := attributeValue(run, "grsync.exit_code")
+		assert.Equal(t, int64(0), exitCode.AsInt64())
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		task := fakeTask(t, "exit 0")
+		task.SetLabels(map[string]string{"tenant": "acme"})
+		Instrument(context.Background(), task, provider)
+		assert.Nil(t, task.Run())
+
+		tenant, _ := attributeValue(recorder.Ended()[1], "grsync.label.tenant")
+		assert.Equal(t, "acme", tenant.AsString())
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		task := fakeTask(t, "exit 23")
+		task.SetRetryPolicy(grsync.RetryPolicy{MaxAttempts: 3})
+		Instrument(context.Background(), task, provider)
+		assert.NotNil(t, task.Run())
+
+		spans := recorder.Ended()
+		assert.Len(t, spans, 4)
+		for i, span := range spans[:3] {
+			assert.Equal(t, "rsync attempt", span.Name())
+			number, _ := attributeValue(span, "grsync.attempt")
+			assert.Equal(t, int64(i+1), number.AsInt64())
+			assert.Equal(t, codes.Error, span.Status().Code)
+		}
+
+		run := spans[3]
+		assert.Equal(t, codes.Error, run.Status().Code)
+		exitCode, _ := attributeValue(run, "grsync.exit_code")
+		assert.Equal(t, int64(23), exitCode.AsInt64())
+		attempts, _ := attributeValue(run, "grsync.attempts")
+		assert.Equal(t, int64(3), attempts.AsInt64())
+	})
+
+	t.Run("no provider", func(t *testing.T) {
+		task := fakeTask(t, "exit 0")
+		Instrument(context.Background(), task, nil)
+		assert.Nil(t, task.Run())
+	})
+}
@@ -0,0 +1,180 @@
+// Package grsyncprom exposes metrics of grsync tasks as Prometheus collectors
+package grsyncprom
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "grsync"
+
+// DefaultDurationBuckets are the buckets of the run duration histogram, from 1s up to about 9 hours
+var DefaultDurationBuckets = prometheus.ExponentialBuckets(1, 3, 10)
+
+// Collector collects the metrics of tracked tasks. Every metric is labelled with the task ID
+// and the label names passed to NewCollector. Progress, speed and transferred bytes are reported
+// while a task is running; counters and the duration histogram accumulate over all runs
+type Collector struct {
+	labelNames []string
+
+	mutex   sync.Mutex
+	running map[*grsync.Task]run
+
+	active   *prometheus.Desc
+	progress *prometheus.Desc
+	speed    *prometheus.Desc
+	runSpeed *prometheus.Desc
+	current  *prometheus.Desc
+
+	runs        *prometheus.CounterVec
+	failures    *prometheus.CounterVec
+	retries     *prometheus.CounterVec
+	transferred *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+}
+
+type run struct {
+	labels []string
+	start  time.Time
+}
+
+// NewCollector returns a collector whose metrics carry a "task" label plus labelNames
+func NewCollector(labelNames ...string) *Collector {
+	labels := append([]string{"task"}, labelNames...)
+
+	return &Collector{
+		labelNames: labels,
+		running:    make(map[*grsync.Task]run),
+
+		active: prometheus.NewDesc(namespace+"_tasks_running",
+			"Number of tracked tasks currently running.", nil, nil),
+		progress: prometheus.NewDesc(namespace+"_task_progress_percent",
+			"Progress of the running task in percent.", labels, nil),
+		speed: prometheus.NewDesc(namespace+"_task_speed_bytes_per_second",
+			"Current transfer speed of the running task.", labels, nil),
+		runSpeed: prometheus.NewDesc(namespace+"_task_run_speed_bytes_per_second",
+			"Average transfer speed of the current run of the task.", labels, nil),
+		current: prometheus.NewDesc(namespace+"_task_transferred_bytes",
+			"Bytes transferred by the current run of the task.", labels, nil),
+
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "task_runs_total", Help: "Number of finished runs.",
+		}, labels),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "task_failures_total", Help: "Number of runs that failed after all attempts.",
+		}, labels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "task_retries_total", Help: "Number of retried attempts.",
+		}, labels),
+		transferred: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "task_transferred_bytes_total", Help: "Bytes transferred by finished runs.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "task_run_duration_seconds", Help: "Duration of finished runs.",
+			Buckets: DefaultDurationBuckets,
+		}, labels),
+	}
+}
+
+// Track registers hooks on the task which feed its runs into the collector. labelValues are the
+// values of the label names passed to NewCollector
+func (c *Collector) Track(task *grsync.Task, labelValues ...string) error {
+	if len(labelValues) != len(c.labelNames)-1 {
+		return fmt.Errorf("grsyncprom: expected %d label values, got %d", len(c.labelNames)-1, len(labelValues))
+	}
+	c.track(task, append([]string{task.ID()}, labelValues...))
+	return nil
+}
+
+// TrackLabelled is Track with the values of the task labels named like the label names passed to
+// NewCollector, see grsync.Task.SetLabels. Missing labels are empty
+func (c *Collector) TrackLabelled(task *grsync.Task) {
+	labels := []string{task.ID()}
+	for _, name := range c.labelNames[1:] {
+		labels = append(labels, task.Label(name))
+	}
+	c.track(task, labels)
+}
+
+func (c *Collector) track(task *grsync.Task, labels []string) {
+	task.AddHooks(grsync.Hooks{
+		OnStart: func(task *grsync.Task) {
+			c.mutex.Lock()
+			c.running[task] = run{labels: labels, start: time.Now()}
+			c.mutex.Unlock()
+		},
+		OnComplete: func(task *grsync.Task) { c.finish(task, nil) },
+		OnError:    func(task *grsync.Task, err error) { c.finish(task, err) },
+		OnRetry: func(task *grsync.Task, attempt int, err error) {
+			c.retries.WithLabelValues(labels...).Inc()
+		},
+	})
+}
+
+func (c *Collector) finish(task *grsync.Task, err error) {
+	c.mutex.Lock()
+	r, ok := c.running[task]
+	delete(c.running, task)
+	c.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	c.runs.WithLabelValues(r.labels...).Inc()
+	if err != nil {
+		c.failures.WithLabelValues(r.labels...).Inc()
+	}
+	if state := task.State(); state.DownloadedTotal != "" {
+		c.transferred.WithLabelValues(r.labels...).Add(float64(state.CumulativeBytes))
+	}
+	c.duration.WithLabelValues(r.labels...).Observe(time.Since(r.start).Seconds())
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.progress
+	ch <- c.speed
+	ch <- c.runSpeed
+	ch <- c.current
+	c.runs.Describe(ch)
+	c.failures.Describe(ch)
+	c.retries.Describe(ch)
+	c.transferred.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	running := make(map[*grsync.Task]run, len(c.running))
+	for task, r := range c.running {
+		running[task] = r
+	}
+	c.mutex.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(len(running)))
+	for task, r := range running {
+		state := task.State()
+		ch <- prometheus.MustNewConstMetric(c.progress, prometheus.GaugeValue, float64(state.Progress), r.labels...)
+		if state.Speed != "" {
+			ch <- prometheus.MustNewConstMetric(c.speed, prometheus.GaugeValue, state.BytesPerSecond, r.labels...)
+		}
+		if state.RunSpeed != "" {
+			ch <- prometheus.MustNewConstMetric(c.runSpeed, prometheus.GaugeValue, state.RunBytesPerSecond, r.labels...)
+		}
+		if state.DownloadedTotal != "" {
+			ch <- prometheus.MustNewConstMetric(c.current, prometheus.GaugeValue, float64(state.BytesTransferred), r.labels...)
+		}
+	}
+
+	c.runs.Collect(ch)
+	c.failures.Collect(ch)
+	c.retries.Collect(ch)
+	c.transferred.Collect(ch)
+	c.duration.Collect(ch)
+}
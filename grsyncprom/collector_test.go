@@ -0,0 +1,122 @@
+package grsyncprom
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeTask(t *testing.T, script string) *grsync.Task {
+	path := filepath.Join(t.TempDir(), "rsync")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+
+	task, err := grsync.NewTask("a", "b", false, false, grsync.RsyncOptions{RsyncBinaryPath: path})
+	assert.Nil(t, err)
+	return task
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("job")
+	registry := prometheus.NewPedanticRegistry()
+	assert.Nil(t, registry.Register(c))
+
+	ok := fakeTask(t, `echo "      1.50M  100%   10.00MB/s    0:00:00"`)
+	ok.SetID("ok")
+	assert.Nil(t, c.Track(ok, "backup"))
+	assert.Nil(t, ok.Run())
+
+	failed := fakeTask(t, "exit 23")
+	failed.SetID("failed")
+	failed.SetRetryPolicy(grsync.RetryPolicy{MaxAttempts: 2})
+	assert.Nil(t, c.Track(failed, "backup"))
+	assert.NotNil(t, failed.Run())
+
+	expected := `
+# HELP grsync_task_failures_total Number of runs that failed after all attempts.
+# TYPE grsync_task_failures_total counter
+grsync_task_failures_total{job="backup",task="failed"} 1
+# HELP grsync_task_retries_total Number of retried attempts.
+# TYPE grsync_task_retries_total counter
+grsync_task_retries_total{job="backup",task="failed"} 1
+# HELP grsync_task_runs_total Number of finished runs.
+# TYPE grsync_task_runs_total counter
+grsync_task_runs_total{job="backup",task="failed"} 1
+grsync_task_runs_total{job="backup",task="ok"} 1
+# HELP grsync_task_transferred_bytes_total Bytes transferred by finished runs.
+# TYPE grsync_task_transferred_bytes_total counter
+grsync_task_transferred_bytes_total{job="backup",task="ok"} 1.5e+06
+# HELP grsync_tasks_running Number of tracked tasks currently running.
+# TYPE grsync_tasks_running gauge
+grsync_tasks_running 0
+`
+	assert.Nil(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"grsync_task_failures_total", "grsync_task_retries_total", "grsync_task_runs_total",
+		"grsync_task_transferred_bytes_total", "grsync_tasks_running"))
+	assert.Equal(t, 2, testutil.CollectAndCount(c, "grsync_task_run_duration_seconds"))
+}
+
+func TestCollectorRunning(t *testing.T) {
+	c := NewCollector()
+	task := fakeTask(t, `echo "    512.00K   42%    1.00MB/s    0:00:10"; exec sleep 5`)
+	task.SetID("running")
+	assert.Nil(t, c.Track(task))
+
+	go func() { _ = task.Run() }()
+	defer func() {
+		_ = task.Cancel()
+		<-task.Done()
+	}()
+
+	assert.Eventually(t, func() bool { return task.State().Progress == 42 }, 3*time.Second, 10*time.Millisecond)
+
+	expected := `
+# HELP grsync_task_progress_percent Progress of the running task in percent.
+# TYPE grsync_task_progress_percent gauge
+grsync_task_progress_percent{task="running"} 42
+# HELP grsync_task_speed_bytes_per_second Current transfer speed of the running task.
+# TYPE grsync_task_speed_bytes_per_second gauge
+grsync_task_speed_bytes_per_second{task="running"} 1e+06
+# HELP grsync_task_transferred_bytes Bytes transferred by the current run of the task.
+# TYPE grsync_task_transferred_bytes gauge
+grsync_task_transferred_bytes{task="running"} 512000
+# HELP grsync_tasks_running Number of tracked tasks currently running.
+# TYPE grsync_tasks_running gauge
+grsync_tasks_running 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"grsync_task_progress_percent", "grsync_task_speed_bytes_per_second",
+		"grsync_task_transferred_bytes", "grsync_tasks_running"))
+	// the average depends on the time since the start
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "grsync_task_run_speed_bytes_per_second"))
+}
+
+func TestTrackLabelMismatch(t *testing.T) {
+	c := NewCollector("job", "host")
+	assert.NotNil(t, c.Track(fakeTask(t, "exit 0"), "backup"))
+}
+
+func TestCollectorTrackLabelled(t *testing.T) {
+	c := NewCollector("tenant", "tier")
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	task := fakeTask(t, "exit 0")
+	task.SetID("labelled")
+	task.SetLabels(map[string]string{"tenant": "acme"})
+	c.TrackLabelled(task)
+	assert.Nil(t, task.Run())
+
+	expected := `
+# HELP grsync_task_runs_total Number of finished runs.
+# TYPE grsync_task_runs_total counter
+grsync_task_runs_total{task="labelled",tenant="acme",tier=""} 1
+`
+	assert.Nil(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "grsync_task_runs_total"))
+}
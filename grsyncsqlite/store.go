@@ -0,0 +1,159 @@
+// Package grsyncsqlite keeps the records of grsync runs in a SQLite database
+package grsyncsqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	// registers the pure Go driver "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// schema has columns for the common fields of a record, so dashboards can query the database
+// directly, and the whole record as JSON in the record column
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	key         TEXT NOT NULL,
+	task_id     TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	started_at  INTEGER NOT NULL,
+	finished_at INTEGER NOT NULL,
+	duration    INTEGER NOT NULL,
+	exit_code   INTEGER NOT NULL,
+	bytes       INTEGER NOT NULL,
+	files       INTEGER NOT NULL,
+	error       TEXT NOT NULL,
+	record      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_key_started_at ON runs (key, started_at);
+`
+
+// Store is a grsync.RunStore backed by the table runs of a SQLite database. Times are stored as
+// Unix nanoseconds
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite serializes writers, a single connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+	store, err := New(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// New returns a store using db, which must be a SQLite database. The table is created if needed
+func New(db *sql.DB) (*Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save inserts the record
+func (s *Store) Save(record grsync.RunRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO runs (key, task_id, status, started_at, finished_at, duration, exit_code, bytes, files, error, record)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.Key, record.TaskID, string(record.Status), unixNano(record.Result.StartedAt), unixNano(record.FinishedAt),
+		int64(record.Result.Duration), int(record.Result.ExitCode), record.Result.BytesTransferred,
+		record.Result.FilesTransferred, record.Error, string(data))
+	return err
+}
+
+// Runs returns the records matching the query, the latest start first
+func (s *Store) Runs(query grsync.RunQuery) ([]grsync.RunRecord, error) {
+	where, args := conditions(query)
+	return s.query("SELECT record FROM runs"+where+" ORDER BY started_at DESC, id DESC LIMIT ?", append(args, limit(query))...)
+}
+
+// Latest returns the latest record matching the query of every key, ordered by key
+func (s *Store) Latest(query grsync.RunQuery) ([]grsync.RunRecord, error) {
+	where, args := conditions(query)
+	return s.query(`SELECT record FROM (
+		SELECT key, record, ROW_NUMBER() OVER (PARTITION BY key ORDER BY started_at DESC, id DESC) AS n FROM runs`+where+`
+	) WHERE n = 1 ORDER BY key LIMIT ?`, append(args, limit(query))...)
+}
+
+func (s *Store) query(query string, args ...interface{}) ([]grsync.RunRecord, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []grsync.RunRecord
+	for rows.Next() {
+		var data string
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var record grsync.RunRecord
+		if err = json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// conditions returns the WHERE clause selecting the records of the query and its arguments
+func conditions(query grsync.RunQuery) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+	if query.Key != "" {
+		clauses = append(clauses, "key = ?")
+		args = append(args, query.Key)
+	}
+	if query.Status != "" {
+		clauses = append(clauses, "status = ?")
+		args = append(args, string(query.Status))
+	}
+	if !query.Since.IsZero() {
+		clauses = append(clauses, "started_at >= ?")
+		args = append(args, unixNano(query.Since))
+	}
+	if !query.Until.IsZero() {
+		clauses = append(clauses, "started_at < ?")
+		args = append(args, unixNano(query.Until))
+	}
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// limit returns the LIMIT of the query, -1 for no limit
+func limit(query grsync.RunQuery) int {
+	if query.Limit <= 0 {
+		return -1
+	}
+	return query.Limit
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,61 @@
+package grsyncsqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.db")
+	store, err := Open(path)
+	assert.Nil(t, err)
+
+	start := time.Date(2024, 3, 1, 2, 30, 0, 0, time.UTC)
+	for _, record := range []grsync.RunRecord{
+		{Key: "nightly", TaskID: "1", Status: grsync.TaskSucceeded, Result: grsync.Result{StartedAt: start, BytesTransferred: 1024}},
+		{Key: "nightly", TaskID: "2", Status: grsync.TaskFailed, Error: "exit status 23", Result: grsync.Result{StartedAt: start.Add(24 * time.Hour), ExitCode: grsync.ExitPartial}},
+		{Key: "hourly", TaskID: "3", Status: grsync.TaskSucceeded, Result: grsync.Result{StartedAt: start.Add(time.Hour)}},
+		{Key: "hourly", TaskID: "4", Status: grsync.TaskSucceeded, Result: grsync.Result{StartedAt: start.Add(2 * time.Hour)}},
+	} {
+		assert.Nil(t, store.Save(record))
+	}
+	assert.Nil(t, store.Close())
+
+	// the records survive reopening the database
+	store, err = Open(path)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	runs, err := store.Runs(grsync.RunQuery{Key: "nightly"})
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "2", runs[0].TaskID)
+	assert.Equal(t, grsync.ExitPartial, runs[0].Result.ExitCode)
+	assert.Equal(t, "exit status 23", runs[0].Error)
+	assert.Equal(t, int64(1024), runs[1].Result.BytesTransferred)
+	assert.True(t, start.Equal(runs[1].Result.StartedAt))
+
+	runs, err = store.Runs(grsync.RunQuery{Status: grsync.TaskSucceeded, Since: start.Add(time.Hour), Until: start.Add(24 * time.Hour), Limit: 1})
+	assert.Nil(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "4", runs[0].TaskID)
+
+	runs, err = grsync.LastSuccessfulRuns(store)
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "4", runs[0].TaskID)
+	assert.Equal(t, "1", runs[1].TaskID)
+
+	record, ok, err := grsync.LastRun(store, "nightly", "")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2", record.TaskID)
+
+	var failures int
+	assert.Nil(t, store.db.QueryRow("SELECT count(*) FROM runs WHERE status = 'failed'").Scan(&failures))
+	assert.Equal(t, 1, failures)
+}
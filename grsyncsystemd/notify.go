@@ -0,0 +1,144 @@
+// Package grsyncsystemd reports the progress of grsync tasks to systemd through sd_notify
+package grsyncsystemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+)
+
+// StatusInterval is the interval in which Attach updates the status if no watchdog is configured
+const StatusInterval = 10 * time.Second
+
+// Notify sends state, e.g. "READY=1", to the socket in $NOTIFY_SOCKET. It returns false without
+// error if the process is not run by systemd with notify support
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// abstract sockets are announced with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout systemd expects this process to honour,
+// or 0 if the watchdog is disabled
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// Status describes the state of a task for `systemctl status`
+func Status(task *grsync.Task) string {
+	definition := task.Definition()
+	state := task.State()
+
+	parts := []string{fmt.Sprintf("%s %s -> %s", task.Status(), definition.Source, definition.Destination)}
+	if task.Status() == grsync.TaskRunning {
+		parts = append(parts, fmt.Sprintf("%d%%", state.Progress))
+		if state.Speed != "" {
+			parts = append(parts, state.Speed)
+		}
+		if state.TimeRemaining != "" {
+			parts = append(parts, state.TimeRemaining+" remaining")
+		}
+	}
+	if err := task.Err(); err != nil && task.Status() != grsync.TaskRunning {
+		parts = append(parts, err.Error())
+	}
+	return strings.Join(parts, " ")
+}
+
+// Attach registers hooks on the task which send READY=1 when it starts and keep STATUS up to date
+// while it runs. If the watchdog is enabled, WATCHDOG=1 is sent at half the watchdog interval for
+// as long as the task is running. Notification errors are ignored
+func Attach(task *grsync.Task) error {
+	watchdog, err := WatchdogInterval()
+	if err != nil {
+		return err
+	}
+	interval := StatusInterval
+	if watchdog > 0 {
+		interval = watchdog / 2
+	}
+
+	var (
+		mutex sync.Mutex
+		stop  chan struct{}
+		done  chan struct{}
+	)
+
+	finish := func(task *grsync.Task) {
+		mutex.Lock()
+		if stop != nil {
+			close(stop)
+			<-done
+			stop = nil
+		}
+		mutex.Unlock()
+		_, _ = Notify("STATUS=" + Status(task))
+	}
+
+	task.AddHooks(grsync.Hooks{
+		OnStart: func(task *grsync.Task) {
+			_, _ = Notify("READY=1\nSTATUS=" + Status(task))
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			stop, done = make(chan struct{}), make(chan struct{})
+			go report(task, interval, watchdog > 0, stop, done)
+		},
+		OnComplete: finish,
+		OnError:    func(task *grsync.Task, err error) { finish(task) },
+	})
+	return nil
+}
+
+func report(task *grsync.Task, interval time.Duration, watchdog bool, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			state := "STATUS=" + Status(task)
+			if watchdog {
+				state = "WATCHDOG=1\n" + state
+			}
+			_, _ = Notify(state)
+		}
+	}
+}
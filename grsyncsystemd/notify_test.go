@@ -0,0 +1,101 @@
+package grsyncsystemd
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeTask(t *testing.T, script string) *grsync.Task {
+	path := filepath.Join(t.TempDir(), "rsync")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+
+	task, err := grsync.NewTask("/src", "/dst", false, false, grsync.RsyncOptions{RsyncBinaryPath: path})
+	assert.Nil(t, err)
+	return task
+}
+
+// listen creates a notify socket and returns a function reading the messages sent so far
+func listen(t *testing.T) func() []string {
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	return func() []string {
+		var messages []string
+		buf := make([]byte, 4096)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			n, err := conn.Read(buf)
+			if err != nil {
+				return messages
+			}
+			messages = append(messages, string(buf[:n]))
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	sent, err := Notify("READY=1")
+	assert.False(t, sent)
+	assert.Nil(t, err)
+
+	read := listen(t)
+	sent, err = Notify("READY=1")
+	assert.True(t, sent)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"READY=1"}, read())
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	interval, err := WatchdogInterval()
+	assert.Nil(t, err)
+	assert.Zero(t, interval)
+
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	interval, err = WatchdogInterval()
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, interval)
+
+	t.Setenv("WATCHDOG_PID", "1")
+	interval, err = WatchdogInterval()
+	assert.Nil(t, err)
+	assert.Zero(t, interval)
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "soon")
+	_, err = WatchdogInterval()
+	assert.NotNil(t, err)
+}
+
+func TestAttach(t *testing.T) {
+	read := listen(t)
+	t.Setenv("WATCHDOG_USEC", "100000")
+
+	task := fakeTask(t, `echo "    512.00K   42%    1.00MB/s    0:00:10"; sleep 0.3`)
+	assert.Nil(t, Attach(task))
+	assert.Nil(t, task.Run())
+
+	messages := read()
+	assert.True(t, len(messages) >= 3, messages)
+	assert.Equal(t, "READY=1\nSTATUS=running /src -> /dst 0%", messages[0])
+	assert.Equal(t, "STATUS=succeeded /src -> /dst", messages[len(messages)-1])
+
+	var watchdog string
+	for _, message := range messages {
+		if strings.HasPrefix(message, "WATCHDOG=1\n") {
+			watchdog = message
+		}
+	}
+	assert.Equal(t, "WATCHDOG=1\nSTATUS=running /src -> /dst 42% 1.00MB/s 0:00:10 remaining", watchdog)
+}
@@ -0,0 +1,305 @@
+// Package grsynctest provides a fake rsync that plays canned scenarios, so the parsing and state
+// machinery of grsync tasks can be tested hermetically without a rsync binary
+package grsynctest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+)
+
+// Scenario is the output and exit code of a fake rsync process
+type Scenario struct {
+	Stdout string
+	Stderr string
+	// ExitCode is the code the process exits with, see grsync.ExitCode
+	ExitCode int
+	// Delay is waited before every line of the output, lines end with "\n" or with the "\r" rsync
+	// uses for progress updates. Scripts ignore it
+	Delay time.Duration
+}
+
+// Combine concatenates the output of the scenarios, the last non-zero exit code wins
+func Combine(scenarios ...Scenario) Scenario {
+	var combined Scenario
+	for _, s := range scenarios {
+		combined.Stdout += s.Stdout
+		combined.Stderr += s.Stderr
+		if s.ExitCode != 0 {
+			combined.ExitCode = s.ExitCode
+		}
+		if s.Delay > combined.Delay {
+			combined.Delay = s.Delay
+		}
+	}
+	return combined
+}
+
+// Progress2 transfers files with a total size of total bytes, reported in steps progress updates
+// like --info=progress2 does. Sizes are printed in bytes, so the state of the task is exact
+func Progress2(total int64, files, steps int) Scenario {
+	if steps < 1 {
+		steps = 1
+	}
+	var b strings.Builder
+	for i := 1; i <= steps; i++ {
+		transferred := total * int64(i) / int64(steps)
+		percent := 100
+		if total > 0 {
+			percent = int(transferred * 100 / total)
+		}
+		done := files * i / steps
+		remaining := steps - i
+		end := "\r"
+		if i == steps {
+			end = "\n"
+		}
+		fmt.Fprintf(&b, "%15d %3d%%    1.00MB/s    0:%02d:%02d (xfr#%d, to-chk=%d/%d)%s",
+			transferred, percent, remaining/60, remaining%60, done, files-done, files, end)
+	}
+	fmt.Fprintf(&b, "\nsent %d bytes  received %d bytes  1000000.00 bytes/sec\ntotal size is %d  speedup is 1.00\n",
+		total, 35*files, total)
+	return Scenario{Stdout: b.String()}
+}
+
+// Vanished reports the paths as vanished during the transfer and exits with grsync.ExitVanished
+func Vanished(paths ...string) Scenario {
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "file has vanished: %q\n", path)
+	}
+	b.WriteString("rsync warning: some files vanished before they could be transferred (code 24) at main.c(1338) [sender=3.2.7]\n")
+	return Scenario{Stderr: b.String(), ExitCode: int(grsync.ExitVanished)}
+}
+
+// AuthFailure is rejected by the rsync daemon serving module and exits with grsync.ExitStartClient
+func AuthFailure(module string) Scenario {
+	return Scenario{
+		Stderr: "@ERROR: auth failed on module " + module + "\n" +
+			"rsync error: error starting client-server protocol (code 5) at main.c(1863) [Receiver=3.2.7]\n",
+		ExitCode: int(grsync.ExitStartClient),
+	}
+}
+
+// Listing prints the --list-only listing of a directory with n files of 1024 bytes
+func Listing(n int) Scenario {
+	var b strings.Builder
+	b.Grow(64 * (n + 1))
+	b.WriteString("drwxr-xr-x           4096 2023/10/07 13:19:08 .\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "-rw-r--r--           1024 2023/10/07 13:19:08 file%07d\n", i)
+	}
+	return Scenario{Stdout: b.String()}
+}
+
+// Script returns a shell script printing the output of the scenario, for code running the rsync
+// binary itself instead of a grsync.CommandRunner
+func (s Scenario) Script() string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	if s.Stdout != "" {
+		b.WriteString("printf '%s' " + quote(s.Stdout) + "\n")
+	}
+	if s.Stderr != "" {
+		b.WriteString("printf '%s' " + quote(s.Stderr) + " >&2\n")
+	}
+	fmt.Fprintf(&b, "exit %d\n", s.ExitCode)
+	return b.String()
+}
+
+// WriteScript writes the script of the scenario as an executable named rsync into dir, e.g.
+// t.TempDir(), and returns its path for grsync.RsyncOptions.RsyncBinaryPath
+func (s Scenario) WriteScript(dir string) (string, error) {
+	path := filepath.Join(dir, "rsync")
+	if err := os.WriteFile(path, []byte(s.Script()), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// quote quotes s for the shell
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Runner is a grsync.CommandRunner whose processes play its scenarios in order, the last one is
+// repeated. Processes of a Runner without scenarios succeed without output
+type Runner struct {
+	mutex     sync.Mutex
+	scenarios []Scenario
+	calls     []grsync.Command
+}
+
+// NewRunner returns a runner playing the scenarios
+func NewRunner(scenarios ...Scenario) *Runner {
+	return &Runner{scenarios: scenarios}
+}
+
+// NewProcess returns a process playing the next scenario
+func (r *Runner) NewProcess(cmd grsync.Command) grsync.Process {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var scenario Scenario
+	if n := len(r.scenarios); n > 0 {
+		scenario = r.scenarios[min(len(r.calls), n-1)]
+	}
+	r.calls = append(r.calls, cmd)
+	return &Process{scenario: scenario, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Calls returns the processes created so far
+func (r *Runner) Calls() []grsync.Command {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]grsync.Command(nil), r.calls...)
+}
+
+// ExitError is returned by Process.Wait if the scenario exits with a non-zero code or the process
+// was stopped by a signal. grsync.ExitCodeOf accepts it like an *exec.ExitError
+type ExitError struct {
+	Code int
+	// Signal is the signal that stopped the process, the code is -1 then
+	Signal os.Signal
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != nil {
+		return "signal: " + e.Signal.String()
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the exit code of the process
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Process plays a scenario. Any signal stops it
+type Process struct {
+	scenario       Scenario
+	stdout, stderr *io.PipeWriter
+	wg             sync.WaitGroup
+	stop           chan struct{}
+	done           chan struct{}
+
+	mutex   sync.Mutex
+	started bool
+	signal  os.Signal
+}
+
+// StdoutPipe returns the stdout of the process
+func (p *Process) StdoutPipe() (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	p.stdout = w
+	return r, nil
+}
+
+// StderrPipe returns the stderr of the process
+func (p *Process) StderrPipe() (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	p.stderr = w
+	return r, nil
+}
+
+// Start plays the output of the scenario
+func (p *Process) Start() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.started {
+		return errors.New("grsynctest: already started")
+	}
+	p.started = true
+	p.wg.Add(2)
+	go p.play(p.stdout, p.scenario.Stdout)
+	go p.play(p.stderr, p.scenario.Stderr)
+	go func() {
+		p.wg.Wait()
+		close(p.done)
+	}()
+	return nil
+}
+
+// play writes output to w line by line and closes w
+func (p *Process) play(w *io.PipeWriter, output string) {
+	defer p.wg.Done()
+	if w == nil {
+		return
+	}
+	defer w.Close()
+
+	if p.scenario.Delay <= 0 {
+		_, _ = io.WriteString(w, output)
+		return
+	}
+	for output != "" {
+		end := strings.IndexAny(output, "\r\n") + 1
+		if end == 0 {
+			end = len(output)
+		}
+		select {
+		case <-time.After(p.scenario.Delay):
+		case <-p.stop:
+			return
+		}
+		if _, err := io.WriteString(w, output[:end]); err != nil {
+			return
+		}
+		output = output[end:]
+	}
+}
+
+// Wait waits for the output to be read and returns an *ExitError unless the scenario exits with 0
+func (p *Process) Wait() error {
+	p.mutex.Lock()
+	started := p.started
+	p.mutex.Unlock()
+	if !started {
+		return grsync.ErrNotStarted
+	}
+	<-p.done
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.signal != nil {
+		return &ExitError{Code: -1, Signal: p.signal}
+	}
+	if p.scenario.ExitCode != 0 {
+		return &ExitError{Code: p.scenario.ExitCode}
+	}
+	return nil
+}
+
+// Signal stops the process, its output ends early. It returns os.ErrProcessDone once the output
+// was played
+func (p *Process) Signal(sig os.Signal) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.started {
+		return grsync.ErrNotStarted
+	}
+	select {
+	case <-p.done:
+		return os.ErrProcessDone
+	default:
+	}
+	if p.signal != nil {
+		return nil
+	}
+	p.signal = sig
+	close(p.stop)
+	// unblock pending writes
+	for _, w := range []*io.PipeWriter{p.stdout, p.stderr} {
+		if w != nil {
+			_ = w.Close()
+		}
+	}
+	return nil
+}
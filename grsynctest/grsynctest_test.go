@@ -0,0 +1,114 @@
+package grsynctest
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ByteSizedMarius/grsync"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTask(t *testing.T, runner grsync.CommandRunner, options grsync.RsyncOptions) *grsync.Task {
+	task, err := grsync.NewTask("/src/", "/dst/", false, false, options)
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	return task
+}
+
+func TestProgress2(t *testing.T) {
+	runner := NewRunner(Progress2(5000000, 10, 5))
+	task := newTask(t, runner, grsync.RsyncOptions{Info: "progress2"})
+	assert.Nil(t, task.Run())
+
+	state := task.State()
+	assert.Equal(t, 100, state.Progress)
+	assert.Equal(t, int64(5000000), state.BytesTransferred)
+	assert.Equal(t, 10, state.FilesTransferred)
+	assert.Equal(t, 0, state.FilesRemaining)
+	assert.Equal(t, 10, state.FilesTotal)
+
+	calls := runner.Calls()
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "rsync", calls[0].Name)
+	assert.Contains(t, calls[0].Args, "progress2")
+}
+
+func TestVanished(t *testing.T) {
+	task := newTask(t, NewRunner(Vanished("/src/a", "/src/b")), grsync.RsyncOptions{})
+	result, err := task.RunResult()
+	assert.Equal(t, grsync.ExitVanished, grsync.ExitCodeOf(err))
+	assert.Equal(t, []string{"/src/a", "/src/b"}, result.Vanished)
+
+	errors := task.FileErrors()
+	assert.Len(t, errors, 2)
+	assert.Equal(t, "/src/b", errors[1].Path)
+}
+
+func TestAuthFailure(t *testing.T) {
+	task := newTask(t, NewRunner(AuthFailure("backup")), grsync.RsyncOptions{})
+	err := task.Run()
+	assert.Equal(t, grsync.ExitStartClient, grsync.ExitCodeOf(err))
+	assert.Contains(t, task.Log().Stderr, "auth failed on module backup")
+}
+
+func TestListing(t *testing.T) {
+	task := newTask(t, NewRunner(Listing(10000)), grsync.RsyncOptions{ListOnly: true})
+	assert.Nil(t, task.Run())
+
+	files := task.GetFileList()
+	assert.Len(t, files, 10001)
+	assert.Equal(t, "file0010000", files[10000][4])
+}
+
+func TestRunnerScenarios(t *testing.T) {
+	runner := NewRunner(Vanished("/src/a"), Scenario{})
+	task := newTask(t, runner, grsync.RsyncOptions{})
+	task.SetRetryPolicy(grsync.RetryPolicy{MaxAttempts: 3})
+	assert.Nil(t, task.Run())
+	assert.Len(t, runner.Calls(), 2)
+}
+
+func TestCombine(t *testing.T) {
+	s := Combine(Progress2(100, 1, 1), Vanished("/src/a"), AuthFailure("m"))
+	assert.True(t, strings.HasPrefix(s.Stdout, "            100 100%"))
+	assert.Contains(t, s.Stderr, "file has vanished")
+	assert.Contains(t, s.Stderr, "auth failed")
+	assert.Equal(t, int(grsync.ExitStartClient), s.ExitCode)
+}
+
+func TestSignal(t *testing.T) {
+	runner := NewRunner(Scenario{Stdout: strings.Repeat("line\n", 100), Delay: 50 * time.Millisecond})
+	process := runner.NewProcess(grsync.Command{Name: "rsync"})
+	stdout, _ := process.StdoutPipe()
+	assert.Equal(t, grsync.ErrNotStarted, process.Signal(nil))
+	assert.Nil(t, process.Start())
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := stdout.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	assert.Nil(t, process.Signal(os.Kill))
+	err := process.Wait()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, grsync.ExitCode(-1), grsync.ExitCodeOf(err))
+	assert.Equal(t, "signal: killed", err.Error())
+}
+
+func TestScript(t *testing.T) {
+	path, err := Combine(Listing(2), Scenario{Stderr: "it's\\odd\n", ExitCode: 23}).WriteScript(t.TempDir())
+	assert.Nil(t, err)
+
+	task, err := grsync.NewTask("/src/", "/dst/", false, false, grsync.RsyncOptions{RsyncBinaryPath: path, ListOnly: true})
+	assert.Nil(t, err)
+	err = task.Run()
+	assert.Equal(t, grsync.ExitPartial, grsync.ExitCodeOf(err))
+	assert.Len(t, task.GetFileList(), 3)
+	assert.Equal(t, "it's\\odd\n", task.Log().Stderr)
+}
@@ -0,0 +1,45 @@
+package grsync
+
+import (
+	"time"
+)
+
+// Activity is when a task last showed signs of life, see Task.LastActivity
+type Activity struct {
+	// LastOutput is the time rsync last printed anything on stdout or stderr during the run
+	LastOutput time.Time `json:"lastOutput,omitempty"`
+	// LastStateChange is the time the State last changed, by a progress line, a file of a dry run
+	// or a LineParser
+	LastStateChange time.Time `json:"lastStateChange,omitempty"`
+}
+
+// LastActivity returns when the current or last run last printed output and changed its state.
+// The times are zero until they happened during the run
+func (t *Task) LastActivity() Activity {
+	var activity Activity
+	if last := t.lastOutput.Load(); last != 0 {
+		activity.LastOutput = time.Unix(0, last)
+	}
+	t.mutex.Lock()
+	activity.LastStateChange = t.lastStateChange
+	t.mutex.Unlock()
+	return activity
+}
+
+// Healthy reports whether the rsync process of a running task printed anything within maxIdle,
+// counted from its start, for supervisors with their own liveness policy. Unlike the watchdog of
+// SetStallTimeout it only reports, the task keeps running. Tasks without a running process, e.g.
+// between attempts or before and after a run, are healthy
+func (t *Task) Healthy(maxIdle time.Duration) bool {
+	t.mutex.Lock()
+	process := t.process
+	t.mutex.Unlock()
+	if process == nil {
+		return true
+	}
+	last := process.StartedAt
+	if output := t.LastActivity().LastOutput; output.After(last) {
+		last = output
+	}
+	return time.Since(last) < maxIdle
+}
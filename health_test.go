@@ -0,0 +1,39 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskLastActivity(t *testing.T) {
+	script := `echo "sending incremental file list"
+echo "      1.00M   50%    1.00MB/s    0:00:01 (xfr#1, to-chk=1/2)"`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	assert.Equal(t, Activity{}, task.LastActivity())
+	assert.True(t, task.Healthy(time.Nanosecond))
+
+	before := time.Now()
+	assert.Nil(t, task.Run())
+	activity := task.LastActivity()
+	assert.True(t, activity.LastOutput.After(before))
+	assert.True(t, activity.LastStateChange.After(before))
+	assert.True(t, task.Healthy(time.Nanosecond))
+}
+
+func TestTaskHealthy(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo started; exec sleep 10")})
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	task.OnProcessStart(func(ProcessInfo) { close(started) })
+	go func() { _ = task.Run() }()
+	defer func() { _ = task.Cancel() }()
+
+	<-started
+	assert.Eventually(t, func() bool { return !task.LastActivity().LastOutput.IsZero() }, time.Second, 5*time.Millisecond)
+	assert.True(t, task.Healthy(time.Minute))
+	assert.Eventually(t, func() bool { return !task.Healthy(50 * time.Millisecond) }, time.Second, 10*time.Millisecond)
+	assert.Zero(t, task.LastActivity().LastStateChange)
+}
@@ -0,0 +1,81 @@
+package grsync
+
+import (
+	"time"
+)
+
+// StateSample is the state of a task at a point in time
+type StateSample struct {
+	Time  time.Time `json:"time"`
+	State State     `json:"state"`
+}
+
+// SetHistory makes Run record the state every interval, keeping the latest limit samples.
+// A zero interval disables the history, which is the default
+func (t *Task) SetHistory(interval time.Duration, limit int) {
+	t.mutex.Lock()
+	t.historyInterval = interval
+	t.historyLimit = limit
+	t.mutex.Unlock()
+}
+
+// History returns the samples recorded during the current or last run, oldest first
+func (t *Task) History() []StateSample {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]StateSample(nil), t.history...)
+}
+
+// startHistory starts recording the history if enabled, the returned function stops it after
+// taking a final sample
+func (t *Task) startHistory() func() {
+	t.mutex.Lock()
+	interval := t.historyInterval
+	t.history = nil
+	t.mutex.Unlock()
+
+	if interval <= 0 {
+		return func() {}
+	}
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		t.recordHistory(interval, done)
+		close(stopped)
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// recordHistory samples the state until done is closed, a final sample is taken on return
+func (t *Task) recordHistory(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.sample()
+	for {
+		select {
+		case <-done:
+			t.sample()
+			return
+		case <-ticker.C:
+			t.sample()
+		}
+	}
+}
+
+func (t *Task) sample() {
+	state := t.State()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	sample := StateSample{Time: time.Now(), State: state}
+	if t.historyLimit > 0 && len(t.history) >= t.historyLimit {
+		copy(t.history, t.history[len(t.history)-t.historyLimit+1:])
+		t.history = t.history[:t.historyLimit]
+		t.history[t.historyLimit-1] = sample
+		return
+	}
+	t.history = append(t.history, sample)
+}
@@ -0,0 +1,51 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+		assert.Empty(t, task.History())
+	})
+
+	t.Run("records samples", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `
+echo "      1.00K   10%    1.00kB/s    0:00:09"
+sleep 0.2
+echo "     10.00K  100%    1.00kB/s    0:00:00"`)})
+		assert.Nil(t, err)
+		task.SetHistory(10*time.Millisecond, 0)
+		assert.Nil(t, task.Run())
+
+		history := task.History()
+		assert.True(t, len(history) > 5, len(history))
+		assert.Equal(t, 100, history[len(history)-1].State.Progress)
+		for i := 1; i < len(history); i++ {
+			assert.False(t, history[i].Time.Before(history[i-1].Time))
+		}
+
+		var sawProgress bool
+		for _, sample := range history {
+			sawProgress = sawProgress || sample.State.Progress == 10
+		}
+		assert.True(t, sawProgress)
+	})
+
+	t.Run("is bounded", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `sleep 0.2; echo "     10.00K  100%    1.00kB/s    0:00:00"`)})
+		assert.Nil(t, err)
+		task.SetHistory(5*time.Millisecond, 3)
+		assert.Nil(t, task.Run())
+
+		history := task.History()
+		assert.Len(t, history, 3)
+		assert.Equal(t, 100, history[2].State.Progress)
+	})
+}
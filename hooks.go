@@ -0,0 +1,54 @@
+package grsync
+
+// Hooks are functions called at well-defined points of a task run. Unset hooks are skipped.
+// Hooks are called synchronously from the goroutine calling Run, before Done is closed
+type Hooks struct {
+	// OnStart is called when Run starts, before the first attempt
+	OnStart func(task *Task)
+	// OnComplete is called when Run finished successfully
+	OnComplete func(task *Task)
+	// OnError is called when Run failed after all attempts
+	OnError func(task *Task, err error)
+	// OnRetry is called before a failed attempt is retried; attempt is the number of the upcoming attempt
+	OnRetry func(task *Task, attempt int, err error)
+}
+
+// AddHooks registers hooks for the task. Hooks of multiple calls are all invoked in registration order
+func (t *Task) AddHooks(hooks Hooks) {
+	t.mutex.Lock()
+	t.hooks = append(t.hooks, hooks)
+	t.mutex.Unlock()
+}
+
+func (t *Task) registeredHooks() []Hooks {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]Hooks(nil), t.hooks...)
+}
+
+func (t *Task) fireStart() {
+	for _, h := range t.registeredHooks() {
+		if h.OnStart != nil {
+			h.OnStart(t)
+		}
+	}
+}
+
+func (t *Task) fireFinish(err error) {
+	for _, h := range t.registeredHooks() {
+		if err == nil && h.OnComplete != nil {
+			h.OnComplete(t)
+		}
+		if err != nil && h.OnError != nil {
+			h.OnError(t, err)
+		}
+	}
+}
+
+func (t *Task) fireRetry(attempt int, err error) {
+	for _, h := range t.registeredHooks() {
+		if h.OnRetry != nil {
+			h.OnRetry(t, attempt, err)
+		}
+	}
+}
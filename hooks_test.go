@@ -0,0 +1,45 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+		assert.Nil(t, err)
+
+		var calls []string
+		task.AddHooks(Hooks{
+			OnStart:    func(task *Task) { calls = append(calls, "start") },
+			OnComplete: func(task *Task) { calls = append(calls, "complete") },
+			OnError:    func(task *Task, err error) { calls = append(calls, "error") },
+		})
+		task.AddHooks(Hooks{
+			OnComplete: func(task *Task) { calls = append(calls, "complete2") },
+		})
+
+		assert.Nil(t, task.Run())
+		assert.Equal(t, []string{"start", "complete", "complete2"}, calls)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+		assert.Nil(t, err)
+
+		var hookErr error
+		task.AddHooks(Hooks{
+			OnComplete: func(task *Task) { t.Error("OnComplete called for failed task") },
+			OnError: func(task *Task, err error) {
+				hookErr = err
+				assert.Equal(t, TaskFailed, task.Status())
+			},
+		})
+
+		runErr := task.Run()
+		assert.NotNil(t, runErr)
+		assert.Equal(t, runErr, hookErr)
+	})
+}
@@ -0,0 +1,86 @@
+package grsync
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// gitIgnoreFilter merges the .gitignore of every directory, its patterns are interpreted by rsync
+const gitIgnoreFilter = ":- .gitignore"
+
+// GitIgnoreRules translates a .gitignore file into rsync filter rules like "- /build/" or
+// "+ keep.log", for a file at the root of the transfer. git applies the last matching pattern
+// and rsync the first, so the rules are returned in reverse order. Patterns containing a slash
+// other than a trailing one are anchored to the root, as in git, unless they start with **/
+func GitIgnoreRules(r io.Reader) ([]string, error) {
+	var rules []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if rule, ok := gitIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(rules)-1; i < j; i, j = i+1, j-1 {
+		rules[i], rules[j] = rules[j], rules[i]
+	}
+	return rules, nil
+}
+
+// gitIgnoreRule translates a line of a .gitignore file, ok is false for blank lines and comments
+func gitIgnoreRule(line string) (rule string, ok bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return "", false
+	}
+
+	action := "- "
+	switch {
+	case line[0] == '!':
+		action, line = "+ ", line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	line = strings.Replace(line, `\ `, " ", -1)
+	if line == "" || line == "/" {
+		return "", false
+	}
+
+	switch {
+	case strings.HasPrefix(line, "**/"):
+		// matches in all directories, like a rsync pattern that isn't anchored
+		line = line[len("**/"):]
+	case strings.Contains(strings.TrimSuffix(line, "/"), "/") && line[0] != '/':
+		line = "/" + line
+	}
+	return action + line, true
+}
+
+// ignoreFileRules returns the filter rules of the ignore files, relative paths are resolved
+// against workDir
+func ignoreFileRules(files []string, workDir string) ([]string, error) {
+	var rules []string
+	for _, path := range files {
+		f, err := os.Open(resolvePath(workDir, path))
+		if err != nil {
+			return nil, err
+		}
+		fileRules, err := GitIgnoreRules(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		rules = append(rules, fileRules...)
+	}
+	return rules, nil
+}
@@ -0,0 +1,62 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitIgnoreRules(t *testing.T) {
+	gitignore := `# build output
+/build/
+*.log
+!keep.log
+docs/generated
+node_modules/
+**/cache/tmp
+\#notes
+\!bang
+trailing   
+escaped\ 
+
+`
+	rules, err := GitIgnoreRules(strings.NewReader(gitignore))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"- escaped ",
+		"- trailing",
+		"- !bang",
+		"- #notes",
+		"- cache/tmp",
+		"- node_modules/",
+		"- /docs/generated",
+		"+ keep.log",
+		"- *.log",
+		"- /build/",
+	}, rules)
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.tmp\n!important.tmp\n"), 0644))
+
+	runner := &cannedRunner{}
+	_, err := newRsync("src/", "dst/", false, false, RsyncOptions{
+		WorkDir:     dir,
+		Exclude:     []string{".git/"},
+		IgnoreFiles: []string{".gitignore"},
+		GitIgnore:   true,
+		CVSExclude:  true,
+	}, nil, runner)
+	assert.Nil(t, err)
+
+	args := strings.Join(runner.args, " ")
+	assert.Contains(t, args, "--cvs-exclude")
+	assert.Contains(t, args, "--exclude=.git/ --filter=+ important.tmp --filter=- *.tmp --filter=:- .gitignore")
+
+	_, err = newRsync("src/", "dst/", false, false, RsyncOptions{IgnoreFiles: []string{filepath.Join(dir, "missing")}}, nil, runner)
+	assert.NotNil(t, err)
+}
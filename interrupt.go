@@ -0,0 +1,121 @@
+package grsync
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// InterruptResult is how a task ended that was interrupted, see InterruptTasks
+type InterruptResult struct {
+	TaskID string     `json:"taskId"`
+	Status TaskStatus `json:"status"`
+	// Killed is set if rsync didn't exit within the grace period and was killed
+	Killed bool `json:"killed,omitempty"`
+	// Error is the error of the run, empty if it succeeded before it saw the signal
+	Error string `json:"error,omitempty"`
+}
+
+// Interrupt stops a running task like Cancel, but sends sig to rsync instead of killing it, so it
+// shuts down on its own: it keeps the partial files with RsyncOptions.Partial or PartialDir and
+// removes its temporary files otherwise. A pending retry is abandoned. The signal reaches the
+// command wrapping rsync for UseSshPass, Priority, Limits and UsePty. Windows only supports
+// os.Kill
+func (t *Task) Interrupt(sig os.Signal) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.cancelled {
+		t.cancelled = true
+		close(t.cancel)
+	}
+	if !t.started || t.status != TaskRunning {
+		return nil
+	}
+	if err := t.rsync.Signal(sig); !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// InterruptTasks interrupts the running tasks with sig and waits up to grace for them to finish.
+// The tasks still running then or which couldn't be signalled are cancelled. It returns the
+// results of the interrupted tasks once all of them finished
+func InterruptTasks(tasks []*Task, sig os.Signal, grace time.Duration) []InterruptResult {
+	deadline := time.After(grace)
+	var results []InterruptResult
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, task := range tasks {
+		if task.Status() != TaskRunning {
+			continue
+		}
+		done := task.Done()
+		killed := task.Interrupt(sig) != nil
+		if killed {
+			_ = task.Cancel()
+		}
+
+		wg.Add(1)
+		go func(task *Task) {
+			defer wg.Done()
+			select {
+			case <-done:
+			case <-deadline:
+				killed = true
+				_ = task.Cancel()
+				<-done
+			}
+
+			result := InterruptResult{TaskID: task.ID(), Status: task.Status(), Killed: killed}
+			if err := task.Err(); err != nil {
+				result.Error = err.Error()
+			}
+			mutex.Lock()
+			results = append(results, result)
+			mutex.Unlock()
+		}(task)
+	}
+	wg.Wait()
+	return results
+}
+
+// Interrupt interrupts the running tasks of the manager, see InterruptTasks
+func (m *Manager) Interrupt(sig os.Signal, grace time.Duration) []InterruptResult {
+	return InterruptTasks(m.List(), sig, grace)
+}
+
+// ForwardSignals makes the program forward the first of signals it receives to the running tasks
+// of the manager, by default SIGINT and SIGTERM. It waits up to grace for them to shut down and
+// passes the results to report, the program usually exits then. Further signals are delivered as
+// usual again. stop ends the forwarding if no signal arrived yet
+func (m *Manager) ForwardSignals(grace time.Duration, report func([]InterruptResult), signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	received := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	signal.Notify(received, signals...)
+	go func() {
+		select {
+		case sig := <-received:
+			signal.Stop(received)
+			results := m.Interrupt(sig, grace)
+			if report != nil {
+				report(results)
+			}
+		case <-stopped:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(stopped)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package grsync
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startTask runs a task with script until its process started
+func startTask(t *testing.T, m *Manager, script string) *Task {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	task.OnProcessStart(func(ProcessInfo) { close(started) })
+	assert.Nil(t, m.Submit(task))
+	<-started
+	return task
+}
+
+func TestManagerInterrupt(t *testing.T) {
+	m := NewManager()
+	clean := startTask(t, m, `trap 'echo "rsync error: received SIGINT, SIGTERM, or SIGHUP (code 20)" >&2; exit 20' INT
+echo ready
+sleep 10 >/dev/null 2>&1 & wait`)
+	stubborn := startTask(t, m, `trap '' INT
+echo ready
+exec sleep 10`)
+	// the shells installed their traps once they printed
+	assert.Eventually(t, func() bool {
+		return clean.Log().Stdout != "" && stubborn.Log().Stdout != ""
+	}, time.Second, 5*time.Millisecond)
+	idle, err := NewTask("a", "b", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, m.Add(idle))
+
+	started := time.Now()
+	results := m.Interrupt(os.Interrupt, 200*time.Millisecond)
+	assert.True(t, time.Since(started) < 5*time.Second)
+	sort.Slice(results, func(i, j int) bool { return results[i].Killed != results[j].Killed && !results[i].Killed })
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, clean.ID(), results[0].TaskID)
+	assert.Equal(t, TaskCancelled, results[0].Status)
+	assert.False(t, results[0].Killed)
+	assert.Equal(t, stubborn.ID(), results[1].TaskID)
+	assert.Equal(t, TaskCancelled, results[1].Status)
+	assert.True(t, results[1].Killed)
+	assert.Contains(t, clean.Log().Stderr, "received SIGINT")
+	assert.Equal(t, TaskPending, idle.Status())
+}
+
+func TestManagerForwardSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows can't signal the own process")
+	}
+	m := NewManager()
+	task := startTask(t, m, "exec sleep 10")
+
+	reported := make(chan []InterruptResult, 1)
+	stop := m.ForwardSignals(time.Second, func(results []InterruptResult) { reported <- results }, syscall.SIGHUP)
+	defer stop()
+	process, err := os.FindProcess(os.Getpid())
+	assert.Nil(t, err)
+	assert.Nil(t, process.Signal(syscall.SIGHUP))
+
+	select {
+	case results := <-reported:
+		assert.Len(t, results, 1)
+		assert.Equal(t, task.ID(), results[0].TaskID)
+		assert.Equal(t, TaskCancelled, results[0].Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the signal wasn't forwarded")
+	}
+}
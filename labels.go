@@ -0,0 +1,36 @@
+package grsync
+
+// SetLabels replaces the labels of the task, arbitrary key/value pairs for routing and filtering
+// tasks. They are carried through its Definition, Snapshot and webhook Notification and can be
+// attached to Prometheus metrics and traces
+func (t *Task) SetLabels(labels map[string]string) {
+	t.mutex.Lock()
+	t.definition.Labels = cloneLabels(labels)
+	t.mutex.Unlock()
+}
+
+// Labels returns the labels of the task, see SetLabels
+func (t *Task) Labels() map[string]string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return cloneLabels(t.definition.Labels)
+}
+
+// Label returns the value of the label key, empty if it isn't set
+func (t *Task) Label(key string) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.definition.Labels[key]
+}
+
+// cloneLabels copies labels, nil if there are none
+func cloneLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	clone := make(map[string]string, len(labels))
+	for key, value := range labels {
+		clone[key] = value
+	}
+	return clone
+}
@@ -0,0 +1,23 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabels(t *testing.T) {
+	task, err := Definition{Source: "a", Destination: "b", Labels: map[string]string{"tenant": "acme"}}.NewTask()
+	assert.Nil(t, err)
+	assert.Equal(t, "acme", task.Label("tenant"))
+	assert.Equal(t, "", task.Label("tier"))
+
+	labels := task.Labels()
+	labels["tenant"] = "changed"
+	assert.Equal(t, map[string]string{"tenant": "acme"}, task.Definition().Labels)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, task.Snapshot().Definition.Labels)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, NewNotification(task, nil).Labels)
+
+	task.SetLabels(nil)
+	assert.Nil(t, task.Labels())
+}
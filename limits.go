@@ -0,0 +1,54 @@
+package grsync
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrResourceLimitsUnsupported is returned for ResourceLimits on Windows
+var ErrResourceLimitsUnsupported = errors.New("resource limits are not supported on windows")
+
+// ResourceLimits restrict the resources of the rsync process. Zero values leave a resource
+// unlimited. They are applied by a sh wrapper that sets them with ulimit and execs rsync
+type ResourceLimits struct {
+	// OpenFiles is the maximum number of open file descriptors, RLIMIT_NOFILE
+	OpenFiles int
+	// Memory is the maximum size of the virtual memory in bytes, RLIMIT_AS
+	Memory int64
+	// CPUTime is the maximum CPU time, RLIMIT_CPU, rounded up to seconds
+	CPUTime time.Duration
+	// Cgroup is the directory of a cgroup v2 the process is moved into before rsync starts, e.g.
+	// "/sys/fs/cgroup/backup.slice". Its cgroup.procs must be writable by the current user
+	Cgroup string
+}
+
+// wrapLimits returns the command running name with args within the limits l
+func wrapLimits(l ResourceLimits, name string, args []string) (string, []string, error) {
+	if l == (ResourceLimits{}) {
+		return name, args, nil
+	}
+	if runtime.GOOS == "windows" {
+		return "", nil, ErrResourceLimitsUnsupported
+	}
+
+	var script []string
+	if l.Cgroup != "" {
+		script = append(script, "echo $$ > "+shellQuote(strings.TrimSuffix(l.Cgroup, "/")+"/cgroup.procs"))
+	}
+	if l.OpenFiles > 0 {
+		script = append(script, "ulimit -n "+strconv.Itoa(l.OpenFiles))
+	}
+	if l.Memory > 0 {
+		// ulimit -v takes KiB
+		script = append(script, "ulimit -v "+strconv.FormatInt((l.Memory+1023)/1024, 10))
+	}
+	if l.CPUTime > 0 {
+		script = append(script, "ulimit -t "+strconv.FormatInt(int64((l.CPUTime+time.Second-1)/time.Second), 10))
+	}
+	script = append(script, `exec "$@"`)
+
+	return "sh", append([]string{"-c", strings.Join(script, " && "), name, name}, args...), nil
+}
@@ -0,0 +1,48 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapLimits(t *testing.T) {
+	name, args, err := wrapLimits(ResourceLimits{}, "rsync", []string{"a"})
+	assert.Nil(t, err)
+	assert.Equal(t, "rsync", name)
+	assert.Equal(t, []string{"a"}, args)
+
+	if runtime.GOOS == "windows" {
+		_, _, err = wrapLimits(ResourceLimits{OpenFiles: 64}, "rsync", nil)
+		assert.Equal(t, ErrResourceLimitsUnsupported, err)
+		return
+	}
+
+	name, args, err = wrapLimits(ResourceLimits{OpenFiles: 64, Memory: 1 << 30, CPUTime: 1500 * time.Millisecond, Cgroup: "/sys/fs/cgroup/backup/"}, "rsync", []string{"a"})
+	assert.Nil(t, err)
+	assert.Equal(t, "sh", name)
+	assert.Equal(t, []string{"-c", "echo $$ > '/sys/fs/cgroup/backup/cgroup.procs' && ulimit -n 64 && ulimit -v 1048576 && ulimit -t 2 && exec \"$@\"", "rsync", "rsync", "a"}, args)
+}
+
+func TestTaskLimits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("resource limits are not supported")
+	}
+	// a stand-in for the cgroup, which only needs a writable cgroup.procs
+	cgroup := t.TempDir()
+	task, err := NewTask("a", "b", false, false, RsyncOptions{
+		RsyncBinaryPath: fakeRsync(t, `ulimit -n; echo "$@" | tail -c 4`),
+		Limits:          ResourceLimits{OpenFiles: 64, Cgroup: cgroup},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "64\na b\n", task.Log().Stdout)
+
+	pid, err := os.ReadFile(filepath.Join(cgroup, "cgroup.procs"))
+	assert.Nil(t, err)
+	assert.NotEmpty(t, pid)
+}
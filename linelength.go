@@ -0,0 +1,32 @@
+package grsync
+
+import "log/slog"
+
+// DefaultMaxLineLength is the default limit of the output lines read from rsync. Lines of
+// listings and file events only exceed it with pathological file names
+const DefaultMaxLineLength = 1 << 20
+
+// SetMaxLineLength limits the length of the lines read from the stdout of rsync, by default
+// DefaultMaxLineLength. Longer lines are truncated to n bytes, the rest of the line is dropped
+// and counted by TruncatedLines, so a single huge line can't stop the processing of the output
+func (t *Task) SetMaxLineLength(n int) {
+	t.mutex.Lock()
+	t.maxLineLength = n
+	t.mutex.Unlock()
+}
+
+// TruncatedLines returns the number of output lines of the current or last run that were longer
+// than the maximum line length and truncated
+func (t *Task) TruncatedLines() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.truncatedLines
+}
+
+// reportTruncated counts a line truncated to length bytes and logs a warning
+func (t *Task) reportTruncated(length int) {
+	t.mutex.Lock()
+	t.truncatedLines++
+	t.mutex.Unlock()
+	t.logEvent(slog.LevelWarn, "rsync output line truncated", slog.Int("length", length))
+}
@@ -0,0 +1,50 @@
+package grsync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineSplitterTruncate(t *testing.T) {
+	input := "short\n" + strings.Repeat("x", 25) + "\r\n" + strings.Repeat("y", 10) + "\r" + strings.Repeat("z", 30)
+	for _, n := range []int{1, 3, 8, len(input)} {
+		splitter := &lineSplitter{maxLength: 10}
+		scanner := splitter.scanner(&chunkReader{data: input, n: n})
+
+		var lines []string
+		var truncated []bool
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+			truncated = append(truncated, splitter.truncated)
+		}
+		assert.Nil(t, scanner.Err(), n)
+		assert.Equal(t, []string{"short", strings.Repeat("x", 10), strings.Repeat("y", 10), strings.Repeat("z", 10)}, lines, n)
+		assert.Equal(t, []bool{false, true, false, true}, truncated, n)
+	}
+}
+
+func TestTaskMaxLineLength(t *testing.T) {
+	long := strings.Repeat("a", 100000)
+	runner := &cannedRunner{stdout: "-rw-r--r--           1024 2023/10/07 13:19:08 " + long + "\n" +
+		"-rw-r--r--           1024 2023/10/07 13:19:08 file\n"}
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{ListOnly: true})
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	task.SetMaxLineLength(1000)
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, 1, task.TruncatedLines())
+	files := task.GetFileList()
+	assert.Len(t, files, 2)
+	assert.Len(t, files[0][4], 1000-len("-rw-r--r--           1024 2023/10/07 13:19:08 "))
+	assert.Equal(t, "file", files[1][4])
+
+	// the default limit is far above the line, the log keeps the output of the previous run
+	task.SetMaxLineLength(0)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, 0, task.TruncatedLines())
+	files = task.GetFileList()
+	assert.Equal(t, long, files[len(files)-2][4])
+}
@@ -0,0 +1,104 @@
+package grsync
+
+import (
+	"strings"
+	"time"
+)
+
+// ListEntry is an entry of a --list-only listing
+type ListEntry struct {
+	// Mode are the permissions as printed by rsync, e.g. "drwxr-xr-x"
+	Mode string
+	// Size is the size in bytes. Sizes printed with --human-readable, which tasks always pass, are
+	// rounded by rsync
+	Size    int64
+	ModTime time.Time
+	Name    string
+	// LinkTarget is the target of a symlink
+	LinkTarget string
+}
+
+// IsDir reports whether the entry is a directory
+func (e ListEntry) IsDir() bool {
+	return strings.HasPrefix(e.Mode, "d")
+}
+
+// IsSymlink reports whether the entry is a symlink
+func (e ListEntry) IsSymlink() bool {
+	return strings.HasPrefix(e.Mode, "l")
+}
+
+// ListEntries is GetFileList with the sizes in bytes, whether rsync printed them like "1,234,567"
+// or "1.23M", and the modification times parsed in the local time zone
+func (t *Task) ListEntries() []ListEntry {
+	t.mutex.Lock()
+	base := t.unitBase()
+	t.mutex.Unlock()
+
+	files := t.GetFileList()
+	entries := make([]ListEntry, 0, len(files))
+	for _, file := range files {
+		if entry, ok := parseListEntry(file, base); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// parseListEntry converts an entry of GetFileList, sizes with a suffix are in units of base
+func parseListEntry(file []string, base float64) (ListEntry, bool) {
+	size, err := parseSize(file[1], base)
+	if err != nil {
+		return ListEntry{}, false
+	}
+	modTime, err := time.ParseInLocation(logTimeLayout, file[2]+" "+file[3], time.Local)
+	if err != nil {
+		return ListEntry{}, false
+	}
+
+	entry := ListEntry{Mode: file[0], Size: size, ModTime: modTime, Name: file[4]}
+	if entry.IsSymlink() {
+		if i := strings.Index(entry.Name, " -> "); i >= 0 {
+			entry.Name, entry.LinkTarget = entry.Name[:i], entry.Name[i+len(" -> "):]
+		}
+	}
+	return entry, true
+}
+
+// ListRemote lists path recursively with rsync --list-only -r and calls entry for every entry as
+// rsync prints it, so large trees are never held in memory. path is typically remote, e.g.
+// "user@host:/data/" over ssh or "rsync://host/module/dir/" from a daemon, and names are relative
+// to it. entry is called in order from the goroutine reading the output of rsync
+func ListRemote(path string, options RsyncOptions, entry func(ListEntry)) error {
+	options.Recursive = true
+	return list(path, options, entry)
+}
+
+// list calls entry for every entry of the listing of path
+func list(path string, options RsyncOptions, entry func(ListEntry)) error {
+	options.ListOnly = true
+
+	task, err := NewTask(path, "", false, false, options)
+	if err != nil {
+		return err
+	}
+	task.DiscardLog()
+	base := task.unitBase()
+	task.AddParser(LineParserFunc(func(line string, _ func(key, value string)) {
+		if e, ok := parseListLine(line, base); ok {
+			entry(e)
+		}
+	}))
+	return task.Run()
+}
+
+// parseListLine parses a line of a listing
+func parseListLine(line string, base float64) (ListEntry, bool) {
+	match := fileListPattern.FindStringSubmatch(line)
+	if match == nil {
+		return ListEntry{}, false
+	}
+	file := match[1:]
+	file[4] = unescapeName(file[4])
+	return parseListEntry(file, base)
+}
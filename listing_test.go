@@ -0,0 +1,72 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListEntries(t *testing.T) {
+	script := `echo "receiving incremental file list"
+echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 ."
+echo "-rw-r--r--      1,234,567 2023/01/02 10:11:13 plain"
+echo "-rw-r--r--          1.23K 2023/01/02 10:11:14 human"
+echo "-rw-r--r--          4.00G 2023/01/02 10:11:15 big"
+echo "lrwxrwxrwx             11 2023/01/02 10:11:16 link -> dir/target"
+echo "drwxrwxrwt          4.10K 2023/01/02 10:11:17 tmp"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), ListOnly: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+
+	entries := task.ListEntries()
+	assert.Len(t, entries, 6)
+	assert.Equal(t, ListEntry{Mode: "drwxr-xr-x", Size: 4096, ModTime: time.Date(2023, 1, 2, 10, 11, 12, 0, time.Local), Name: "."}, entries[0])
+	assert.True(t, entries[0].IsDir())
+	assert.Equal(t, int64(1234567), entries[1].Size)
+	assert.Equal(t, int64(1230), entries[2].Size)
+	assert.Equal(t, int64(4000000000), entries[3].Size)
+	assert.True(t, entries[4].IsSymlink())
+	assert.Equal(t, "link", entries[4].Name)
+	assert.Equal(t, "dir/target", entries[4].LinkTarget)
+	assert.Equal(t, "tmp", entries[5].Name)
+}
+
+func TestParseListEntryIEC(t *testing.T) {
+	entry, ok := parseListEntry([]string{"-rw-r--r--", "1.50K", "2023/01/02", "10:11:12", "f"}, 1024)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1536), entry.Size)
+
+	_, ok = parseListEntry([]string{"-rw-r--r--", "1.5.0", "2023/01/02", "10:11:12", "f"}, 1000)
+	assert.False(t, ok)
+}
+
+func TestListRemote(t *testing.T) {
+	script := `echo "$@" > "$(dirname "$0")/args"
+echo "receiving incremental file list"
+echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 ."
+echo "-rw-r--r--      1,234,567 2023/01/02 10:11:13 dir/file\#012name"
+echo "rsync: [sender] opendir \"/data/private\" failed: Permission denied (13)" >&2
+exit 23`
+	path := fakeRsync(t, script)
+
+	var entries []ListEntry
+	err := ListRemote("host:/data/", RsyncOptions{RsyncBinaryPath: path}, func(entry ListEntry) {
+		entries = append(entries, entry)
+	})
+	assert.Equal(t, ExitPartial, ExitCodeOf(err))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, ".", entries[0].Name)
+	assert.Equal(t, "dir/file\nname", entries[1].Name)
+	assert.Equal(t, int64(1234567), entries[1].Size)
+
+	args, err := os.ReadFile(filepath.Join(filepath.Dir(path), "args"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(args), "--list-only")
+	assert.Contains(t, string(args), "--recursive")
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(args)), " host:/data/"))
+}
@@ -0,0 +1,62 @@
+package grsync
+
+import (
+	"regexp"
+)
+
+const (
+	// DefaultLocale is the locale rsync runs with unless RsyncOptions.Locale is set. Numbers and
+	// system error messages are printed untranslated, which the parsers of Task rely on
+	DefaultLocale = "C"
+	// InheritLocale makes rsync run with the locale of the environment
+	InheritLocale = "-"
+)
+
+// ErrorPatterns match stderr lines of rsync in addition to the built-in English messages, e.g.
+// the translated system errors of a remote rsync or a local one with InheritLocale
+type ErrorPatterns struct {
+	// NetworkDrop match lines indicating a dropped connection, see SetReconnects
+	NetworkDrop []*regexp.Regexp
+	// FileError match lines reporting an error for a file, see FileErrors. The first submatch is
+	// the path and the second, if any, the reason
+	FileError []*regexp.Regexp
+}
+
+// SetErrorPatterns sets additional patterns used to classify stderr lines
+func (t *Task) SetErrorPatterns(patterns ErrorPatterns) {
+	t.mutex.Lock()
+	t.errorPatterns = patterns
+	t.mutex.Unlock()
+}
+
+// isNetworkDrop is IsNetworkDrop including the additional patterns
+func (p ErrorPatterns) isNetworkDrop(line string) bool {
+	if IsNetworkDrop(line) {
+		return true
+	}
+	for _, pattern := range p.NetworkDrop {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFileError is parseFileError including the additional patterns
+func (p ErrorPatterns) parseFileError(line string) (FileError, bool) {
+	if fileErr, ok := parseFileError(line); ok {
+		return fileErr, true
+	}
+	for _, pattern := range p.FileError {
+		match := pattern.FindStringSubmatch(line)
+		if len(match) < 2 {
+			continue
+		}
+		fileErr := FileError{Path: match[1], Line: line}
+		if len(match) > 2 {
+			fileErr.Reason = match[2]
+		}
+		return fileErr, true
+	}
+	return FileError{}, false
+}
@@ -0,0 +1,42 @@
+package grsync
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRsyncLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	run := func(locale string) string {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "$LC_ALL $LANG"`), Locale: locale})
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+		return task.Log().Stdout
+	}
+
+	assert.Equal(t, "C C\n", run(""))
+	assert.Equal(t, "C.UTF-8 C.UTF-8\n", run("C.UTF-8"))
+	assert.Equal(t, "de_DE.UTF-8 de_DE.UTF-8\n", run(InheritLocale))
+}
+
+func TestTaskErrorPatterns(t *testing.T) {
+	script := `echo 'rsync: send_files konnte "/src/geheim" nicht öffnen: Keine Berechtigung (13)' >&2
+echo 'ssh: connect to host example.com: Verbindung abgelehnt' >&2
+exit 12`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Locale: InheritLocale})
+	assert.Nil(t, err)
+	task.SetReconnects(1)
+	task.SetErrorPatterns(ErrorPatterns{
+		NetworkDrop: []*regexp.Regexp{regexp.MustCompile(`Verbindung abgelehnt`)},
+		FileError:   []*regexp.Regexp{regexp.MustCompile(`^rsync: \w+ konnte "(.+)" nicht öffnen: (.+)$`)},
+	})
+
+	assert.NotNil(t, task.Run())
+	assert.Equal(t, 1, task.State().Reconnects)
+	fileErrors := task.FileErrors()
+	assert.Len(t, fileErrors, 2)
+	assert.Equal(t, FileError{Path: "/src/geheim", Reason: "Keine Berechtigung (13)", Line: fileErrors[0].Line}, fileErrors[0])
+}
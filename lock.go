@@ -0,0 +1,194 @@
+package grsync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrLocked is returned when the lock of a destination is held by another process
+var ErrLocked = errors.New("destination is locked by another sync")
+
+// ErrNotLocked is returned when a lock is unlocked which isn't held
+var ErrNotLocked = errors.New("lock is not held")
+
+// Locker guards a destination against overlapping syncs
+type Locker interface {
+	Lock() error
+	Unlock() error
+}
+
+// FileLock is a lock file on the local file system, held with an advisory lock of the operating
+// system (flock, LockFileEx), which is released when the holder exits, even if it crashes. The
+// file contains the pid of the holder for humans, it isn't used to detect stale locks
+type FileLock struct {
+	Path string
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileLock returns a lock backed by the file at path
+func NewFileLock(path string) *FileLock {
+	return &FileLock{Path: path}
+}
+
+// NewDestinationLock returns a local lock keyed on destination, shared by all processes on this host.
+// Local destinations are made absolute and remote paths cleaned, so "/a/b" and "/a/b/" share a lock
+func NewDestinationLock(destination string) *FileLock {
+	sum := sha1.Sum([]byte(destinationLockKey(destination)))
+	return NewFileLock(filepath.Join(os.TempDir(), "grsync-"+hex.EncodeToString(sum[:])+".lock"))
+}
+
+// destinationLockKey returns destination in a canonical form
+func destinationLockKey(destination string) string {
+	endpoint, err := ParseEndpoint(destination)
+	if err != nil {
+		return destination
+	}
+	if !endpoint.IsRemote() {
+		if abs, err := filepath.Abs(destination); err == nil {
+			return abs
+		}
+		return filepath.Clean(destination)
+	}
+	if endpoint.Path != "" {
+		endpoint.Path = path.Clean(endpoint.Path)
+	}
+	return endpoint.String()
+}
+
+// Lock creates and locks the lock file or returns ErrLocked if it is held by another lock
+func (l *FileLock) Lock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file != nil {
+		return ErrLocked
+	}
+
+	for {
+		f, err := lockFile(l.Path)
+		if err != nil {
+			return err
+		}
+
+		// the previous holder removes the file when it unlocks, after which another lock may
+		// have created a new one at the path
+		if !l.owns(f) {
+			_ = unlockFile(f)
+			continue
+		}
+		if err = writePid(f); err != nil {
+			_ = unlockFile(f)
+			return err
+		}
+		l.file = f
+		return nil
+	}
+}
+
+// Unlock removes and unlocks the lock file. The file is only removed while it is the one locked by
+// l, a lock file which replaced it is kept
+func (l *FileLock) Unlock() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return ErrNotLocked
+	}
+
+	f := l.file
+	l.file = nil
+	return releaseFile(l.Path, f, l.owns(f))
+}
+
+// owns reports whether the file at the path of the lock is f
+func (l *FileLock) owns(f *os.File) bool {
+	locked, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(l.Path)
+	return err == nil && os.SameFile(locked, current)
+}
+
+// writePid replaces the content of the lock file f with the pid of this process
+func writePid(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	return err
+}
+
+// RemoteLock is a lock directory created on a remote host over ssh. mkdir is atomic,
+// so only one process can hold the lock at a time
+type RemoteLock struct {
+	// Rsh is the remote shell used to reach the host, by default `ssh`
+	Rsh string
+	// Host is the remote host, e.g. `user@host`
+	Host string
+	// Path is the path of the lock directory on the remote host
+	Path string
+}
+
+// NewRemoteLock returns a lock directory at path on host, reached through rsh
+func NewRemoteLock(rsh, host, path string) *RemoteLock {
+	return &RemoteLock{Rsh: rsh, Host: host, Path: path}
+}
+
+// remoteLockHeld is the exit status of the remote lock command if the lock directory exists,
+// EX_TEMPFAIL. ssh exits with 255 when it fails itself
+const remoteLockHeld = 75
+
+// Lock creates the lock directory or returns ErrLocked if it already exists. The exit status of
+// mkdir tells them apart, its messages depend on the locale
+func (l *RemoteLock) Lock() error {
+	quoted := shellQuote(l.Path)
+	command := fmt.Sprintf("mkdir %s || { test -d %s && exit %d; exit 1; }", quoted, quoted, remoteLockHeld)
+	out, err := l.command(command).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == remoteLockHeld {
+		return ErrLocked
+	}
+	if err != nil {
+		return fmt.Errorf("remote lock %s:%s: %w: %s", l.Host, l.Path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Unlock removes the lock directory
+func (l *RemoteLock) Unlock() error {
+	out, err := l.command("rmdir " + shellQuote(l.Path)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("remote unlock %s:%s: %w: %s", l.Host, l.Path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (l *RemoteLock) command(command string) *exec.Cmd {
+	return remoteCommand(l.Rsh, l.Host, command)
+}
+
+// remoteCommand runs command on host through the remote shell rsh, by default `ssh`
+func remoteCommand(rsh, host, command string) *exec.Cmd {
+	// an invalid rsh is reported by rsync, the lock and chunked transfers run what could be parsed
+	shell, _ := ParseRemoteShell(rsh)
+	if shell.Command == "" {
+		shell.Command = "ssh"
+	}
+	args := append(append([]string(nil), shell.Args...), host, command)
+	return exec.Command(shell.Command, args...)
+}
+
+// shellQuote quotes s for use in a POSIX shell command line
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
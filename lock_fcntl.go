@@ -0,0 +1,65 @@
+//go:build aix || solaris
+
+package grsync
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+var (
+	// fcntlMutex guards fcntlLocked
+	fcntlMutex sync.Mutex
+	// fcntlLocked are the paths locked by this process. fcntl locks belong to the process and are
+	// released when any of its files of the lock is closed, so the paths are never opened twice
+	fcntlLocked = map[string]bool{}
+)
+
+// lockFile opens the file at path and locks it with fcntl, ErrLocked if another process or
+// another lock of this process holds the lock
+func lockFile(path string) (*os.File, error) {
+	fcntlMutex.Lock()
+	defer fcntlMutex.Unlock()
+	if fcntlLocked[path] {
+		return nil, ErrLocked
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK}
+	if err = syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, &lock); err != nil {
+		_ = f.Close()
+		if err == syscall.EAGAIN || err == syscall.EACCES {
+			err = ErrLocked
+		}
+		return nil, err
+	}
+	fcntlLocked[path] = true
+	return f, nil
+}
+
+// unlockFile unlocks and closes f, closing it releases the fcntl lock
+func unlockFile(f *os.File) error {
+	fcntlMutex.Lock()
+	defer fcntlMutex.Unlock()
+	delete(fcntlLocked, f.Name())
+	return f.Close()
+}
+
+// releaseFile removes the file at path if owned, before unlocking f. A lock waiting for the removed
+// file notices that it no longer is the one at path
+func releaseFile(path string, f *os.File, owned bool) error {
+	var err error
+	if owned {
+		if err = os.Remove(path); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if unlockErr := unlockFile(f); err == nil {
+		err = unlockErr
+	}
+	return err
+}
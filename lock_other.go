@@ -0,0 +1,49 @@
+//go:build !windows && !aix && !solaris
+
+package grsync
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile opens the file at path and locks it with flock, ErrLocked if another open file holds the
+// lock. flock locks belong to the open file, so two locks of one process exclude each other too
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if err == syscall.EWOULDBLOCK {
+			err = ErrLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile unlocks and closes f
+func unlockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// releaseFile removes the file at path if owned, before unlocking f. A lock waiting for the removed
+// file notices that it no longer is the one at path
+func releaseFile(path string, f *os.File, owned bool) error {
+	var err error
+	if owned {
+		if err = os.Remove(path); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if unlockErr := unlockFile(f); err == nil {
+		err = unlockErr
+	}
+	return err
+}
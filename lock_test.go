@@ -0,0 +1,134 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileLock(t *testing.T) {
+	t.Run("lock and unlock", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lock")
+		first := NewFileLock(path)
+		second := NewFileLock(path)
+
+		assert.Nil(t, first.Lock())
+		assert.Equal(t, ErrLocked, second.Lock())
+		assert.Nil(t, first.Unlock())
+		assert.Nil(t, second.Lock())
+		assert.Nil(t, second.Unlock())
+	})
+
+	t.Run("takes over stale lock", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lock")
+		// pid far above the default pid_max
+		assert.Nil(t, ioutil.WriteFile(path, []byte("99999999"), 0644))
+
+		lock := NewFileLock(path)
+		assert.Nil(t, lock.Lock())
+		assert.Nil(t, lock.Unlock())
+	})
+
+	t.Run("takes over empty lock file", func(t *testing.T) {
+		// left behind by a crash of versions which wrote the pid after creating the file
+		path := filepath.Join(t.TempDir(), "lock")
+		assert.Nil(t, ioutil.WriteFile(path, nil, 0644))
+
+		lock := NewFileLock(path)
+		assert.Nil(t, lock.Lock())
+		content, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, strconv.Itoa(os.Getpid()), string(content))
+		assert.Nil(t, lock.Unlock())
+	})
+
+	t.Run("only one of concurrent locks holds it", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lock")
+		assert.Nil(t, ioutil.WriteFile(path, []byte("99999999"), 0644))
+
+		var held int32
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if NewFileLock(path).Lock() == nil {
+					atomic.AddInt32(&held, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), held)
+	})
+
+	t.Run("unlock keeps the lock file of another lock", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "lock")
+		lock := NewFileLock(path)
+		assert.Nil(t, lock.Lock())
+		assert.Nil(t, os.Remove(path))
+		other := NewFileLock(path)
+		assert.Nil(t, other.Lock())
+
+		assert.Nil(t, lock.Unlock())
+		assert.FileExists(t, path)
+		assert.Equal(t, ErrLocked, NewFileLock(path).Lock())
+		assert.Nil(t, other.Unlock())
+		assert.NoFileExists(t, path)
+		assert.Equal(t, ErrNotLocked, other.Unlock())
+	})
+
+	t.Run("destination lock is keyed on destination", func(t *testing.T) {
+		assert.Equal(t, NewDestinationLock("host:/a").Path, NewDestinationLock("host:/a").Path)
+		assert.NotEqual(t, NewDestinationLock("host:/a").Path, NewDestinationLock("host:/b").Path)
+		assert.Equal(t, NewDestinationLock("host:/a/b").Path, NewDestinationLock("host:/a/b/").Path)
+		assert.Equal(t, NewDestinationLock("/a/b").Path, NewDestinationLock("/a/b/").Path)
+		assert.Equal(t, NewDestinationLock("/a/b").Path, NewDestinationLock("/a/./c/../b").Path)
+
+		wd, err := os.Getwd()
+		assert.Nil(t, err)
+		assert.Equal(t, NewDestinationLock(filepath.Join(wd, "b")).Path, NewDestinationLock("b").Path)
+	})
+}
+
+func TestRemoteLock(t *testing.T) {
+	// the fake remote shell runs the remote command locally
+	rsh := fakeRsync(t, `shift; sh -c "$1"`)
+	path := filepath.Join(t.TempDir(), "lock")
+
+	first := NewRemoteLock(rsh, "host", path)
+	second := NewRemoteLock(rsh, "host", path)
+	assert.Nil(t, first.Lock())
+	assert.Equal(t, ErrLocked, second.Lock())
+	assert.Nil(t, first.Unlock())
+	assert.Nil(t, second.Lock())
+	assert.Nil(t, second.Unlock())
+
+	// mkdir fails for other reasons than an existing directory
+	err := NewRemoteLock(rsh, "host", filepath.Join(path, "missing", "lock")).Lock()
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrLocked, err)
+}
+
+func TestTaskLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	held := NewFileLock(path)
+	assert.Nil(t, held.Lock())
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+	assert.Nil(t, err)
+	task.SetLock(NewFileLock(path))
+	assert.Equal(t, ErrLocked, task.Run())
+
+	assert.Nil(t, held.Unlock())
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+	assert.Nil(t, err)
+	task.SetLock(NewFileLock(path))
+	assert.Nil(t, task.Run())
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,56 @@
+package grsync
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile opens the file at path and locks its first byte with LockFileEx, ErrLocked if another
+// open file holds the lock. The lock belongs to the handle, so two locks of one process exclude
+// each other too
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err = windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &windows.Overlapped{}); err != nil {
+		_ = f.Close()
+		if err == windows.ERROR_LOCK_VIOLATION {
+			err = ErrLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile unlocks and closes f
+func unlockFile(f *os.File) error {
+	err := windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// releaseFile unlocks f before removing the file at path if owned, open files can't be removed on
+// Windows. If another lock opened the file in between, it stays
+func releaseFile(path string, f *os.File, owned bool) error {
+	err := unlockFile(f)
+	if !owned {
+		return err
+	}
+	if removeErr := os.Remove(path); err == nil && !os.IsNotExist(removeErr) && !isOpenElsewhere(removeErr) {
+		err = removeErr
+	}
+	return err
+}
+
+// isOpenElsewhere reports whether a file couldn't be removed because another process opened it
+func isOpenElsewhere(err error) bool {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	return err == windows.ERROR_SHARING_VIOLATION || err == windows.ERROR_ACCESS_DENIED
+}
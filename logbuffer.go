@@ -0,0 +1,222 @@
+package grsync
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// logBuffer accumulates output. Without limits everything is kept, otherwise the first headLimit
+// bytes and the last tailLimit bytes are kept and the bytes in between are dropped
+type logBuffer struct {
+	headLimit int
+	tailLimit int
+	// discard drops all output without counting it
+	discard bool
+
+	head []byte
+	// tail is a ring buffer of tailLimit bytes starting at tailStart once it is full
+	tail      []byte
+	tailStart int
+	dropped   int64
+}
+
+// setLimits changes the limits, previously accumulated output is discarded
+func (b *logBuffer) setLimits(headLimit, tailLimit int) {
+	*b = logBuffer{headLimit: headLimit, tailLimit: tailLimit}
+}
+
+// reset discards the accumulated output, keeping the limits
+func (b *logBuffer) reset() {
+	*b = logBuffer{headLimit: b.headLimit, tailLimit: b.tailLimit, discard: b.discard}
+}
+
+func (b *logBuffer) limited() bool {
+	return b.headLimit > 0 || b.tailLimit > 0
+}
+
+// WriteString appends s to the buffer
+func (b *logBuffer) WriteString(s string) {
+	appendLog(b, s)
+}
+
+// Write appends p to the buffer
+func (b *logBuffer) Write(p []byte) {
+	appendLog(b, p)
+}
+
+func appendLog[T string | []byte](b *logBuffer, s T) {
+	if b.discard {
+		return
+	}
+	if !b.limited() {
+		b.head = append(b.head, s...)
+		return
+	}
+
+	if free := b.headLimit - len(b.head); free > 0 {
+		if free > len(s) {
+			free = len(s)
+		}
+		b.head = append(b.head, s[:free]...)
+		s = s[free:]
+	}
+	if len(s) == 0 {
+		return
+	}
+
+	if b.tailLimit <= 0 {
+		b.dropped += int64(len(s))
+		return
+	}
+	if len(s) >= b.tailLimit {
+		b.dropped += int64(len(b.tail) + len(s) - b.tailLimit)
+		b.tail = append(b.tail[:0], s[len(s)-b.tailLimit:]...)
+		b.tailStart = 0
+		return
+	}
+
+	// fill the ring until it is full, then overwrite the oldest bytes
+	if free := b.tailLimit - len(b.tail); free > 0 {
+		if free > len(s) {
+			free = len(s)
+		}
+		b.tail = append(b.tail, s[:free]...)
+		s = s[free:]
+	}
+	for len(s) > 0 {
+		n := copy(b.tail[b.tailStart:], s)
+		b.dropped += int64(n)
+		b.tailStart = (b.tailStart + n) % b.tailLimit
+		s = s[n:]
+	}
+}
+
+// WriteLine appends s followed by a newline without concatenating them first
+func (b *logBuffer) WriteLine(s string) {
+	appendLine(b, s)
+}
+
+// WriteLineBytes is WriteLine for a line read from rsync
+func (b *logBuffer) WriteLineBytes(p []byte) {
+	appendLine(b, p)
+}
+
+func appendLine[T string | []byte](b *logBuffer, s T) {
+	if !b.discard && !b.limited() {
+		b.head = append(append(b.head, s...), '\n')
+		return
+	}
+	appendLog(b, s)
+	appendLog(b, "\n")
+}
+
+// view returns the kept output. Without limits it isn't copied: appending never modifies the
+// bytes already written, so the result can be read after the mutex is released
+func (b *logBuffer) view() []byte {
+	if b.limited() {
+		return []byte(b.String())
+	}
+	return b.head[:len(b.head):len(b.head)]
+}
+
+// eachLine calls fn for every line of data, the lines point into data
+func eachLine(data []byte, fn func(line []byte)) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			fn(data)
+			return
+		}
+		fn(data[:i])
+		data = data[i+1:]
+	}
+}
+
+// Len returns the number of bytes held by the buffer
+func (b *logBuffer) Len() int {
+	return len(b.head) + len(b.tail)
+}
+
+// Dropped returns the number of bytes dropped because of the limits
+func (b *logBuffer) Dropped() int64 {
+	return b.dropped
+}
+
+// String returns the kept output. If bytes were dropped, a marker line stating how many is inserted
+// between head and tail
+func (b *logBuffer) String() string {
+	if b.dropped == 0 {
+		return string(b.head) + string(b.tail[b.tailStart:]) + string(b.tail[:b.tailStart])
+	}
+
+	out := make([]byte, 0, b.Len()+64)
+	for _, segment := range b.segments() {
+		out = append(out, segment...)
+	}
+	return string(out)
+}
+
+// Tail returns at most the last n bytes of the kept output, starting at a line boundary if possible
+func (b *logBuffer) Tail(n int) string {
+	s := b.String()
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 && i < len(s)-1 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// segments returns the parts of the kept output in order without copying them, the marker of
+// String included
+func (b *logBuffer) segments() [][]byte {
+	segments := [][]byte{b.head}
+	if b.dropped > 0 {
+		var marker []byte
+		if len(b.head) > 0 && b.head[len(b.head)-1] != '\n' {
+			marker = append(marker, '\n')
+		}
+		marker = append(marker, "[... "+strconv.FormatInt(b.dropped, 10)+" bytes truncated ...]\n"...)
+		segments = append(segments, marker)
+	}
+	return append(segments, b.tail[b.tailStart:], b.tail[:b.tailStart])
+}
+
+// lastLines returns the last n lines of the kept output, copying only them. A final newline
+// doesn't start another line
+func (b *logBuffer) lastLines(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	segments := b.segments()
+	// walk backwards to the newline ending the line before the n lines
+	lines, size, skipFinal := 0, 0, true
+	start, startSegment := 0, 0
+search:
+	for i := len(segments) - 1; i >= 0; i-- {
+		segment := segments[i]
+		for j := len(segment) - 1; j >= 0; j-- {
+			if segment[j] == '\n' {
+				if skipFinal {
+					skipFinal = false
+				} else if lines++; lines == n {
+					start, startSegment = j+1, i
+					break search
+				}
+			} else {
+				skipFinal = false
+			}
+			size++
+		}
+	}
+
+	out := make([]byte, 0, size)
+	out = append(out, segments[startSegment][start:]...)
+	for _, segment := range segments[startSegment+1:] {
+		out = append(out, segment...)
+	}
+	return string(out)
+}
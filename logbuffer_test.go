@@ -0,0 +1,189 @@
+package grsync
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogBuffer(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		var b logBuffer
+		b.WriteString("a\n")
+		b.WriteString("b\n")
+		assert.Equal(t, "a\nb\n", b.String())
+		assert.Equal(t, int64(0), b.Dropped())
+	})
+
+	t.Run("head and tail", func(t *testing.T) {
+		var b logBuffer
+		b.setLimits(4, 4)
+		for _, line := range []string{"1\n", "2\n", "3\n", "4\n", "5\n"} {
+			b.WriteString(line)
+		}
+		assert.Equal(t, "1\n2\n[... 2 bytes truncated ...]\n4\n5\n", b.String())
+		assert.Equal(t, int64(2), b.Dropped())
+		assert.Equal(t, 8, b.Len())
+	})
+
+	t.Run("tail only", func(t *testing.T) {
+		var b logBuffer
+		b.setLimits(0, 3)
+		b.WriteString("abcdef")
+		b.WriteString("gh")
+		assert.Equal(t, "[... 5 bytes truncated ...]\nfgh", b.String())
+	})
+
+	t.Run("head only", func(t *testing.T) {
+		var b logBuffer
+		b.setLimits(3, 0)
+		b.WriteString("abcdef")
+		assert.Equal(t, "abc\n[... 3 bytes truncated ...]\n", b.String())
+	})
+
+	t.Run("fits", func(t *testing.T) {
+		var b logBuffer
+		b.setLimits(4, 4)
+		b.WriteString("abcdef")
+		assert.Equal(t, "abcdef", b.String())
+	})
+
+	t.Run("bounded memory", func(t *testing.T) {
+		var b logBuffer
+		b.setLimits(16, 64)
+		line := strings.Repeat("x", 99) + "\n"
+		for i := 0; i < 10000; i++ {
+			b.WriteString(line)
+		}
+		assert.Equal(t, 80, b.Len())
+		assert.Equal(t, int64(10000*100-80), b.Dropped())
+	})
+}
+
+func TestTaskLogLimits(t *testing.T) {
+	script := `for i in 1 2 3 4 5 6 7 8 9; do echo "line $i"; done`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetLogLimits(7, 14)
+
+	assert.Nil(t, task.Run())
+	log := task.Log()
+	assert.Equal(t, "line 1\n[... 42 bytes truncated ...]\nline 8\nline 9\n", log.Stdout)
+	assert.Equal(t, int64(42), log.StdoutTruncated)
+}
+
+func TestTaskDiscardLog(t *testing.T) {
+	script := `printf "      1.05M 100%%  659.30kB/s    0:00:01\n"; echo err >&2`
+	task, err := Definition{
+		Source:      "a",
+		Destination: "b",
+		Options:     RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)},
+		DiscardLog:  true,
+	}.NewTask()
+	assert.Nil(t, err)
+
+	var stderr []string
+	task.OnStderrLine(func(line string) { stderr = append(stderr, line) })
+
+	assert.Nil(t, task.Run())
+	assert.Empty(t, task.Log())
+	assert.Equal(t, 100, task.State().Progress)
+	assert.Equal(t, []string{"err"}, stderr)
+}
+
+func TestLogBufferTail(t *testing.T) {
+	var b logBuffer
+	b.WriteString("first\nsecond\nthird\n")
+	assert.Equal(t, "first\nsecond\nthird\n", b.Tail(100))
+	assert.Equal(t, "third\n", b.Tail(10))
+	assert.Equal(t, "ird\n", b.Tail(4))
+}
+
+func TestLogBufferLastLines(t *testing.T) {
+	var b logBuffer
+	assert.Equal(t, "", b.lastLines(3))
+	b.WriteString("first\nsecond\nthird\n")
+	assert.Equal(t, "", b.lastLines(0))
+	assert.Equal(t, "third\n", b.lastLines(1))
+	assert.Equal(t, "second\nthird\n", b.lastLines(2))
+	assert.Equal(t, "first\nsecond\nthird\n", b.lastLines(10))
+	b.WriteString("partial")
+	assert.Equal(t, "third\npartial", b.lastLines(2))
+
+	// the lines span the marker and the wrapped ring
+	b.setLimits(6, 10)
+	for i := 0; i < 5; i++ {
+		b.WriteLine("line " + strconv.Itoa(i))
+	}
+	assert.Equal(t, b.String(), b.lastLines(10))
+	assert.Equal(t, "[... 19 bytes truncated ...]\n 3\nline 4\n", b.lastLines(3))
+	assert.Equal(t, " 3\nline 4\n", b.lastLines(2))
+}
+
+func TestLogBufferWriteLine(t *testing.T) {
+	var b logBuffer
+	b.WriteLine("a")
+	b.WriteLine("b")
+	assert.Equal(t, "a\nb\n", b.String())
+
+	b.setLimits(2, 2)
+	b.WriteLine("first")
+	b.WriteLine("last")
+	assert.Equal(t, "fi\n[... 7 bytes truncated ...]\nt\n", b.String())
+}
+
+const benchmarkLogLines = 1000000
+
+func benchmarkLogBuffer(b *testing.B, head, tail int) {
+	line := "     15.17G  10%   92.23MB/s    0:23:54 (xfr#1234, to-chk=999/9999)"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var buffer logBuffer
+		buffer.setLimits(head, tail)
+		for j := 0; j < benchmarkLogLines; j++ {
+			buffer.WriteLine(line)
+		}
+		_ = buffer.String()
+	}
+}
+
+func BenchmarkLogBuffer1M(b *testing.B) {
+	benchmarkLogBuffer(b, 0, 0)
+}
+
+func BenchmarkLogBuffer1MLimited(b *testing.B) {
+	benchmarkLogBuffer(b, 64<<10, 64<<10)
+}
+
+// BenchmarkProcessStdout1M measures parsing and accumulating the output of a verbose transfer
+func BenchmarkProcessStdout1M(b *testing.B) {
+	var output strings.Builder
+	for _, line := range benchmarkLines() {
+		output.WriteString(line + "\n")
+	}
+	input := strings.Repeat(output.String(), benchmarkLogLines/len(benchmarkLines()))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		processStdout(&wg, task, strings.NewReader(input))
+		assert.Equal(b, benchmarkLogLines, strings.Count(task.Log().Stdout, "\n"))
+	}
+}
+
+func TestEachLine(t *testing.T) {
+	var lines []string
+	eachLine([]byte("a\n\nb\nc"), func(line []byte) {
+		lines = append(lines, string(line))
+	})
+	assert.Equal(t, []string{"a", "", "b", "c"}, lines)
+}
@@ -0,0 +1,197 @@
+package grsync
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LogFileOptions configure the log file a task writes its raw output to
+type LogFileOptions struct {
+	// Path of the current log file, rotated files are named Path.1, Path.2, ... (newest first)
+	Path string
+	// MaxSize is the size in bytes after which the file is rotated; 0 disables rotation
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept; 0 keeps all of them
+	MaxBackups int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// RotatingFile is an io.WriteCloser appending to a file that is rotated once it exceeds a size
+type RotatingFile struct {
+	options LogFileOptions
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// OpenRotatingFile opens the file at options.Path for appending, creating it if needed
+func OpenRotatingFile(options LogFileOptions) (*RotatingFile, error) {
+	f := &RotatingFile{options: options}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.options.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = stat.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating the file first if p would exceed MaxSize
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.options.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.options.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file aside and starts a new one
+func (f *RotatingFile) Rotate() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.rotate()
+}
+
+// Close closes the current file
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+
+	suffix := ""
+	if f.options.Compress {
+		suffix = ".gz"
+	}
+
+	// shift Path.N to Path.N+1, dropping the backups beyond MaxBackups
+	last := f.options.MaxBackups
+	if last <= 0 {
+		for last = 1; exists(f.backupName(last, suffix)); last++ {
+		}
+	} else {
+		_ = os.Remove(f.backupName(last, suffix))
+	}
+	for i := last - 1; i >= 1; i-- {
+		if err := os.Rename(f.backupName(i, suffix), f.backupName(i+1, suffix)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if f.options.Compress {
+		if err := gzipFile(f.options.Path, f.backupName(1, suffix)); err != nil {
+			return err
+		}
+	} else if err := os.Rename(f.options.Path, f.backupName(1, suffix)); err != nil {
+		return err
+	}
+
+	return f.open()
+}
+
+func (f *RotatingFile) backupName(i int, suffix string) string {
+	return f.options.Path + "." + strconv.Itoa(i) + suffix
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gzipFile compresses src into dst and removes src
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// LogToFile makes every run of the task append its raw stdout and stderr to a rotating log file,
+// in addition to writers set with SetStdoutWriter and SetStderrWriter. The file is opened when a run
+// starts and closed when it finishes
+func (t *Task) LogToFile(options LogFileOptions) {
+	t.mutex.Lock()
+	t.logFileOptions = &options
+	t.mutex.Unlock()
+}
+
+// openLogFile opens the log file configured with LogToFile and writes a header for the attempt
+func (t *Task) openLogFile(attempt int) (*RotatingFile, error) {
+	t.mutex.Lock()
+	options := t.logFileOptions
+	id := t.id
+	t.mutex.Unlock()
+
+	if options == nil {
+		return nil, nil
+	}
+	file, err := OpenRotatingFile(*options)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = fmt.Fprintf(file, "# grsync task %s attempt %d started at %s\n", id, attempt, time.Now().Format(time.RFC3339))
+	return file, nil
+}
@@ -0,0 +1,96 @@
+package grsync
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotatingFile(t *testing.T) {
+	t.Run("rotates and limits backups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rsync.log")
+		f, err := OpenRotatingFile(LogFileOptions{Path: path, MaxSize: 10, MaxBackups: 2})
+		assert.Nil(t, err)
+
+		for _, line := range []string{"first---\n", "second--\n", "third---\n", "fourth--\n"} {
+			_, err = f.Write([]byte(line))
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, f.Close())
+
+		assertFile(t, path, "fourth--\n")
+		assertFile(t, path+".1", "third---\n")
+		assertFile(t, path+".2", "second--\n")
+		assert.NoFileExists(t, path+".3")
+	})
+
+	t.Run("compresses backups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rsync.log")
+		f, err := OpenRotatingFile(LogFileOptions{Path: path, Compress: true})
+		assert.Nil(t, err)
+
+		_, err = f.Write([]byte("old\n"))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Rotate())
+		_, err = f.Write([]byte("older\n"))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Rotate())
+		assert.Nil(t, f.Close())
+
+		assertGzipFile(t, path+".1.gz", "older\n")
+		assertGzipFile(t, path+".2.gz", "old\n")
+		assertFile(t, path, "")
+	})
+
+	t.Run("appends to existing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rsync.log")
+		assert.Nil(t, ioutil.WriteFile(path, []byte("a\n"), 0644))
+
+		f, err := OpenRotatingFile(LogFileOptions{Path: path})
+		assert.Nil(t, err)
+		_, err = f.Write([]byte("b\n"))
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+
+		_, err = f.Write([]byte("c\n"))
+		assert.Equal(t, os.ErrClosed, err)
+		assertFile(t, path, "a\nb\n")
+	})
+}
+
+func TestTaskLogToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rsync.log")
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo out; echo err >&2")})
+	assert.Nil(t, err)
+	task.LogToFile(LogFileOptions{Path: path})
+
+	assert.Nil(t, task.Run())
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "# grsync task "+task.ID()+" attempt 1 started at "))
+	assert.Contains(t, string(content), "out\n")
+	assert.Contains(t, string(content), "err\n")
+}
+
+func assertFile(t *testing.T, path, expected string) {
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(content))
+}
+
+func assertGzipFile(t *testing.T, path, expected string) {
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	content, err := ioutil.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(content))
+}
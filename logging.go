@@ -0,0 +1,64 @@
+package grsync
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SetLogger makes the task emit structured events to logger: start and end of every attempt
+// and retries at info level, progress samples at debug level and stderr lines as warnings.
+// Without a logger the task stays silent
+func (t *Task) SetLogger(logger *slog.Logger) {
+	t.mutex.Lock()
+	t.logger = logger
+	t.mutex.Unlock()
+}
+
+// logAttrs returns the attributes identifying the task in log records, t.mutex must be held
+func (t *Task) logAttrs() []any {
+	return []any{
+		slog.String("task", t.id),
+		slog.String("source", t.source),
+		slog.String("destination", t.destination),
+	}
+}
+
+// logEvent emits a record if the task has a logger, t.mutex must not be held
+func (t *Task) logEvent(level slog.Level, msg string, args ...any) {
+	t.mutex.Lock()
+	logger := t.logger
+	attrs := t.logAttrs()
+	if len(t.secrets) > 0 {
+		for i, arg := range args {
+			attr, ok := arg.(slog.Attr)
+			if !ok {
+				continue
+			}
+			// errors of rsync and sshpass may quote the command line
+			if err, isErr := attr.Value.Any().(error); isErr && attr.Value.Kind() == slog.KindAny {
+				args[i] = slog.String(attr.Key, t.redact(err.Error()))
+			} else if attr.Value.Kind() == slog.KindString {
+				args[i] = slog.String(attr.Key, t.redact(attr.Value.String()))
+			}
+		}
+	}
+	t.mutex.Unlock()
+
+	if logger == nil {
+		return
+	}
+	logger.Log(context.Background(), level, msg, append(attrs, args...)...)
+}
+
+// logProgress emits a progress sample whenever the progress percentage changed, t.mutex must be held
+func (t *Task) logProgress(previous int) {
+	if t.logger == nil || t.state.Progress == previous {
+		return
+	}
+	t.logger.Debug("rsync progress", append(t.logAttrs(),
+		slog.Int("progress", t.state.Progress),
+		slog.String("total", t.state.DownloadedTotal),
+		slog.String("speed", t.state.Speed),
+		slog.String("remaining", t.state.TimeRemaining),
+	)...)
+}
@@ -0,0 +1,41 @@
+package grsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskLogger(t *testing.T) {
+	script := `printf "        1.05M  10%%  659.30kB/s    0:00:01\r"
+printf "        2.10M  20%%  659.30kB/s    0:00:01\n"
+echo "file has vanished: \"/a\"" >&2
+exit 24`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	task.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	assert.NotNil(t, task.Run())
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, task.ID(), record["task"])
+		records = append(records, record)
+	}
+
+	var messages []string
+	for _, record := range records {
+		messages = append(messages, record["level"].(string)+" "+record["msg"].(string))
+	}
+	assert.Equal(t, "INFO rsync started", messages[0])
+	assert.Contains(t, messages, "DEBUG rsync progress")
+	assert.Contains(t, messages, "WARN rsync stderr")
+	assert.Equal(t, "ERROR rsync failed", messages[len(messages)-1])
+}
@@ -0,0 +1,64 @@
+package grsync
+
+import (
+	"bytes"
+	"io"
+)
+
+// LogTail returns the last n lines of stdout and stderr, e.g. for a UI showing the latest output.
+// Other than Log it only copies these lines
+func (t *Task) LogTail(n int) Log {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return Log{
+		Stderr:          t.redact(t.stderrLog.lastLines(n)),
+		Stdout:          t.redact(t.stdoutLog.lastLines(n)),
+		StderrTruncated: t.stderrLog.Dropped(),
+		StdoutTruncated: t.stdoutLog.Dropped(),
+	}
+}
+
+// LogReader returns readers of the output accumulated so far, e.g. to download the full log.
+// Without SetLogLimits the output isn't copied, the secrets are redacted line by line while it is
+// read
+func (t *Task) LogReader() (stdout, stderr io.Reader) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.logReader(&t.stdoutLog), t.logReader(&t.stderrLog)
+}
+
+// logReader returns a reader of the output kept by b. The mutex must be held
+func (t *Task) logReader(b *logBuffer) io.Reader {
+	data := b.view()
+	if len(t.secrets) == 0 {
+		return bytes.NewReader(data)
+	}
+	return &redactingReader{data: data, secrets: t.secrets}
+}
+
+// redactingReader reads data with the secrets replaced, one line at a time
+type redactingReader struct {
+	data    []byte
+	line    []byte
+	secrets []string
+}
+
+func (r *redactingReader) Read(p []byte) (int, error) {
+	if len(r.line) == 0 {
+		if len(r.data) == 0 {
+			return 0, io.EOF
+		}
+		i := bytes.IndexByte(r.data, '\n') + 1
+		if i == 0 {
+			i = len(r.data)
+		}
+		r.line = r.data[:i]
+		for _, secret := range r.secrets {
+			r.line = bytes.ReplaceAll(r.line, []byte(secret), []byte(redacted))
+		}
+		r.data = r.data[i:]
+	}
+	n := copy(p, r.line)
+	r.line = r.line[n:]
+	return n, nil
+}
@@ -0,0 +1,56 @@
+package grsync
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskLogTail(t *testing.T) {
+	script := `for i in 1 2 3 4 5; do echo "line $i $RSYNC_PASSWORD"; done
+echo "rsync: warning" >&2`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Env: []string{"RSYNC_PASSWORD=hunter2"}})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, Log{Stdout: "line 4 ***\nline 5 ***\n", Stderr: "rsync: warning\n"}, task.LogTail(2))
+	assert.Equal(t, task.Log(), task.LogTail(100))
+}
+
+func TestTaskLogReader(t *testing.T) {
+	script := `for i in 1 2 3; do echo "line $i $RSYNC_PASSWORD"; done
+echo "rsync: $RSYNC_PASSWORD" >&2`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Env: []string{"RSYNC_PASSWORD=hunter2"}})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+
+	stdout, stderr := task.LogReader()
+	// reading a byte at a time keeps the redaction
+	data, err := io.ReadAll(io.LimitReader(oneByteReader{stdout}, 1000))
+	assert.Nil(t, err)
+	assert.Equal(t, task.Log().Stdout, string(data))
+	data, err = io.ReadAll(stderr)
+	assert.Nil(t, err)
+	assert.Equal(t, "rsync: ***\n", string(data))
+
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo out")})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	stdout, _ = task.LogReader()
+	data, err = io.ReadAll(stdout)
+	assert.Nil(t, err)
+	assert.Equal(t, "out\n", string(data))
+}
+
+// oneByteReader reads at most a byte at a time
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (r oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,168 @@
+package grsync
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StructuredLogFileFormat is the --log-file-format understood by ParseLogRecords: operation,
+// itemized changes, length, modification time and name of every file
+const StructuredLogFileFormat = "%o %i %l %M %n"
+
+// ChecksumLogFileFormat is StructuredLogFileFormat with the full-file checksum of every file in
+// brackets, which tasks comparing checksums use. rsync 3.1 and newer know the checksum of the
+// files they transfer and pad it with spaces otherwise
+const ChecksumLogFileFormat = "%o %i %l %M [%C] %n"
+
+const (
+	logTimeLayout  = "2006/01/02 15:04:05"
+	logMTimeLayout = "2006/01/02-15:04:05"
+)
+
+// LogRecord is a per-file entry of a rsync --log-file
+type LogRecord struct {
+	Time time.Time `json:"time"`
+	PID  int       `json:"pid"`
+	// Operation is rsync's %o: send, recv or del.
+	Operation string    `json:"operation,omitempty"`
+	Op        FileOp    `json:"op"`
+	Itemize   string    `json:"itemize"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime,omitempty"`
+	// Checksum is rsync's %C of ChecksumLogFileFormat, empty if rsync didn't know it
+	Checksum string `json:"checksum,omitempty"`
+	Path     string `json:"path"`
+	Deleted  bool   `json:"deleted"`
+}
+
+// ParseLogFile reads the per-file records of a rsync log file, see ParseLogRecords
+func ParseLogFile(path string) ([]LogRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseLogRecords(f)
+}
+
+// ParseLogRecords reads the per-file records of a rsync log written with StructuredLogFileFormat,
+// ChecksumLogFileFormat or rsync's default format (`%i %n%L`). Lines which are not per-file records, e.g. the
+// transfer summary, are skipped
+func ParseLogRecords(r io.Reader) ([]LogRecord, error) {
+	var records []LogRecord
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if record, ok := parseLogRecord(scanner.Text()); ok {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func parseLogRecord(line string) (LogRecord, bool) {
+	var record LogRecord
+
+	// 2023/10/07 13:19:08 [1234] <format>
+	if len(line) < len(logTimeLayout)+4 {
+		return record, false
+	}
+	t, err := time.ParseInLocation(logTimeLayout, line[:len(logTimeLayout)], time.Local)
+	if err != nil {
+		return record, false
+	}
+	record.Time = t
+
+	rest := line[len(logTimeLayout)+1:]
+	if !strings.HasPrefix(rest, "[") {
+		return record, false
+	}
+	end := strings.Index(rest, "] ")
+	if end < 0 {
+		return record, false
+	}
+	record.PID, _ = strconv.Atoi(rest[1:end])
+	rest = rest[end+2:]
+
+	operation, afterOperation := nextField(rest)
+	switch operation {
+	case "send", "recv", "del.":
+		record.Operation = operation
+		var size, mtime string
+		record.Itemize, rest = nextField(afterOperation)
+		size, rest = nextField(rest)
+		mtime, rest = nextField(rest)
+		if rest == "" {
+			return record, false
+		}
+		record.Size, _ = strconv.ParseInt(strings.Replace(size, ",", "", -1), 10, 64)
+		record.ModTime, _ = time.ParseInLocation(logMTimeLayout, mtime, time.Local)
+		record.Checksum, rest = logChecksum(rest)
+		record.Path = rest
+	default:
+		// default format, the itemized string is always 11 characters wide
+		if !looksItemized(operation) {
+			return record, false
+		}
+		record.Itemize, record.Path = nextField(rest)
+		if i := strings.Index(record.Path, " -> "); i >= 0 && len(record.Itemize) > 1 && record.Itemize[1] == 'L' {
+			record.Path = record.Path[:i]
+		}
+		if record.Path == "" {
+			return record, false
+		}
+	}
+
+	record.Path = unescapeName(record.Path)
+	record.Op = fileOp(record.Itemize)
+	record.Deleted = record.Op == FileDeleted
+	return record, true
+}
+
+// logChecksum splits the bracketed checksum of ChecksumLogFileFormat from the name following it.
+// Without one, e.g. for StructuredLogFileFormat, it returns s as the name, names starting with
+// a bracketed hex string can't be told apart
+func logChecksum(s string) (string, string) {
+	if !strings.HasPrefix(s, "[") {
+		return "", s
+	}
+	end := strings.Index(s, "] ")
+	if end < 0 {
+		return "", s
+	}
+	checksum := strings.TrimSpace(s[1:end])
+	if strings.Trim(checksum, "0123456789abcdef") != "" || end+2 == len(s) {
+		return "", s
+	}
+	return checksum, s[end+2:]
+}
+
+// nextField splits s at the first space and skips the padding following it
+func nextField(s string) (string, string) {
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], strings.TrimLeft(s[i:], " ")
+}
+
+func looksItemized(s string) bool {
+	if s == "*deleting" {
+		return true
+	}
+	return len(s) == 11 && strings.IndexByte("<>ch.*", s[0]) >= 0 && strings.IndexByte("fdLDS", s[1]) >= 0
+}
+
+// LogRecords parses the log file configured with RsyncOptions.LogFile after the task ran.
+// Unlike FileEvents this doesn't depend on the interactive output of rsync and includes deletions
+func (t *Task) LogRecords() ([]LogRecord, error) {
+	if t.definition.Options.LogFile == "" {
+		return nil, os.ErrNotExist
+	}
+	return ParseLogFile(resolvePath(t.definition.Options.WorkDir, t.definition.Options.LogFile))
+}
@@ -0,0 +1,80 @@
+package grsync
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleLogFile = `2023/10/07 13:19:08 [4242] building file list
+2023/10/07 13:19:08 [4242] recv >f+++++++++ 1024 2023/10/01-08:00:00 dir/new file.txt
+2023/10/07 13:19:08 [4242] recv cd+++++++++ 4096 2023/10/01-08:00:00 dir/
+2023/10/07 13:19:09 [4242] del. *deleting   0 2023/09/01-10:30:00 old.txt
+2023/10/07 13:19:09 [4242] >f.st...... changed.txt
+2023/10/07 13:19:09 [4242] cL+++++++++ link -> target
+2023/10/07 13:19:09 [4242] sent 1.23K bytes  received 35 bytes  2.54K bytes/sec
+2023/10/07 13:19:09 [4242] total size is 1.02M  speedup is 827.83
+`
+
+func TestParseLogRecords(t *testing.T) {
+	records, err := ParseLogRecords(strings.NewReader(sampleLogFile))
+	assert.Nil(t, err)
+	assert.Len(t, records, 5)
+
+	assert.Equal(t, LogRecord{
+		Time:      time.Date(2023, 10, 7, 13, 19, 8, 0, time.Local),
+		PID:       4242,
+		Operation: "recv",
+		Op:        FileReceived,
+		Itemize:   ">f+++++++++",
+		Size:      1024,
+		ModTime:   time.Date(2023, 10, 1, 8, 0, 0, 0, time.Local),
+		Path:      "dir/new file.txt",
+	}, records[0])
+
+	assert.Equal(t, FileChanged, records[1].Op)
+	assert.Equal(t, "dir/", records[1].Path)
+
+	assert.True(t, records[2].Deleted)
+	assert.Equal(t, "del.", records[2].Operation)
+	assert.Equal(t, "old.txt", records[2].Path)
+
+	assert.Equal(t, ">f.st......", records[3].Itemize)
+	assert.Equal(t, "changed.txt", records[3].Path)
+	assert.Equal(t, "link", records[4].Path)
+}
+
+func TestTaskLogRecords(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "rsync.log")
+	script := `for arg; do case "$arg" in --log-file-format=*) fmt="${arg#*=}";; esac; done
+[ "$fmt" = "` + StructuredLogFileFormat + `" ] || exit 1
+echo "2023/10/07 13:19:08 [1] recv >f+++++++++ 3 2023/10/01-08:00:00 a" > ` + logFile
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), LogFile: logFile})
+	assert.Nil(t, err)
+
+	assert.Nil(t, task.Run())
+	records, err := task.LogRecords()
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "a", records[0].Path)
+}
+
+func TestParseLogRecordsChecksum(t *testing.T) {
+	log := `2023/10/07 13:19:08 [1] recv >f+++++++++ 3 2023/10/01-08:00:00 [d41d8cd98f00b204e9800998ecf8427e] dir/a
+2023/10/07 13:19:08 [1] recv cd+++++++++ 4096 2023/10/01-08:00:00 [                                ] dir/
+2023/10/07 13:19:08 [1] recv >f+++++++++ 3 2023/10/01-08:00:00 [draft] notes.txt
+`
+	records, err := ParseLogRecords(strings.NewReader(log))
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e", records[0].Checksum)
+	assert.Equal(t, "dir/a", records[0].Path)
+	assert.Equal(t, "", records[1].Checksum)
+	assert.Equal(t, "dir/", records[1].Path)
+	// not a checksum, so part of the name
+	assert.Equal(t, "", records[2].Checksum)
+	assert.Equal(t, "[draft] notes.txt", records[2].Path)
+}
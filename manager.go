@@ -0,0 +1,173 @@
+package grsync
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrTaskNotFound is returned when a manager has no task with the requested ID
+var ErrTaskNotFound = errors.New("task not found")
+
+// Manager is a registry of tasks addressable by their ID
+type Manager struct {
+	mutex sync.Mutex
+	tasks map[string]*Task
+	order []string
+	// submitted are the IDs of the tasks started by Submit or SubmitUnique
+	submitted map[string]bool
+	// readOnly refuses tasks deleting files, see SetReadOnly
+	readOnly bool
+}
+
+// NewManager returns an empty manager
+func NewManager() *Manager {
+	return &Manager{
+		tasks:     make(map[string]*Task),
+		submitted: make(map[string]bool),
+	}
+}
+
+// Add registers a task without starting it
+func (m *Manager) Add(task *Task) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.add(task)
+}
+
+// add registers a task, the mutex must be held
+func (m *Manager) add(task *Task) error {
+	if m.readOnly {
+		if err := checkReadOnly(task.Definition()); err != nil {
+			return err
+		}
+	}
+	id := task.ID()
+	if _, ok := m.tasks[id]; ok {
+		return fmt.Errorf("task %q already registered", id)
+	}
+	m.tasks[id] = task
+	m.order = append(m.order, id)
+	return nil
+}
+
+// Submit registers a task and runs it in the background. The outcome is available through
+// Task.Status, Task.Err and Wait
+func (m *Manager) Submit(task *Task) error {
+	m.mutex.Lock()
+	err := m.add(task)
+	if err == nil {
+		m.submitted[task.ID()] = true
+	}
+	m.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	go func() { _ = task.Run() }()
+	return nil
+}
+
+// SubmitUnique coalesces duplicate submissions, e.g. from a watcher firing repeatedly: if a
+// submitted task with the same definition, apart from the ID, is about to run or running, that
+// task is returned and task is discarded. Tasks registered with Add aren't coalesced onto, as
+// nothing runs them. Otherwise task is submitted and returned
+func (m *Manager) SubmitUnique(task *Task) (*Task, error) {
+	m.mutex.Lock()
+	for _, id := range m.order {
+		existing := m.tasks[id]
+		if status := existing.Status(); !m.submitted[id] || status != TaskPending && status != TaskRunning {
+			continue
+		}
+		if sameDefinition(existing.Definition(), task.Definition()) {
+			m.mutex.Unlock()
+			return existing, nil
+		}
+	}
+	err := m.add(task)
+	if err == nil {
+		m.submitted[task.ID()] = true
+	}
+	m.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() { _ = task.Run() }()
+	return task, nil
+}
+
+func sameDefinition(a, b Definition) bool {
+	a.ID, b.ID = "", ""
+	return reflect.DeepEqual(a, b)
+}
+
+// Get returns the task registered under id
+func (m *Manager) Get(id string) (*Task, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	task, ok := m.tasks[id]
+	return task, ok
+}
+
+// List returns all registered tasks in the order they were added
+func (m *Manager) List() []*Task {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tasks := make([]*Task, 0, len(m.order))
+	for _, id := range m.order {
+		tasks = append(tasks, m.tasks[id])
+	}
+	return tasks
+}
+
+// Remove unregisters a task. A running task is not cancelled
+func (m *Manager) Remove(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.tasks[id]; !ok {
+		return false
+	}
+	delete(m.tasks, id)
+	delete(m.submitted, id)
+	for i, orderID := range m.order {
+		if orderID == id {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// CancelByID cancels the task registered under id
+func (m *Manager) CancelByID(id string) error {
+	task, ok := m.Get(id)
+	if !ok {
+		return ErrTaskNotFound
+	}
+	return task.Cancel()
+}
+
+// Wait blocks until the task registered under id finished and returns its error
+func (m *Manager) Wait(id string) error {
+	task, ok := m.Get(id)
+	if !ok {
+		return ErrTaskNotFound
+	}
+	<-task.Done()
+	return task.Err()
+}
+
+func newTaskID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
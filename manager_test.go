@@ -0,0 +1,119 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	t.Run("registers and looks up tasks", func(t *testing.T) {
+		m := NewManager()
+		first, err := NewTask("a", "b", false, false, RsyncOptions{})
+		assert.Nil(t, err)
+		second, err := Definition{ID: "second", Source: "a", Destination: "b"}.NewTask()
+		assert.Nil(t, err)
+
+		assert.NotEmpty(t, first.ID())
+		assert.Equal(t, "second", second.ID())
+		assert.Nil(t, m.Add(first))
+		assert.Nil(t, m.Add(second))
+		assert.NotNil(t, m.Add(second))
+
+		task, ok := m.Get("second")
+		assert.True(t, ok)
+		assert.Equal(t, second, task)
+		assert.Equal(t, []*Task{first, second}, m.List())
+
+		assert.True(t, m.Remove(first.ID()))
+		assert.False(t, m.Remove(first.ID()))
+		assert.Equal(t, []*Task{second}, m.List())
+		assert.Equal(t, ErrTaskNotFound, m.CancelByID(first.ID()))
+	})
+
+	t.Run("submits tasks", func(t *testing.T) {
+		m := NewManager()
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+		assert.Nil(t, err)
+		assert.Equal(t, TaskPending, task.Status())
+
+		assert.Nil(t, m.Submit(task))
+		assert.Nil(t, m.Wait(task.ID()))
+		assert.Equal(t, TaskSucceeded, task.Status())
+	})
+
+	t.Run("coalesces duplicate submissions", func(t *testing.T) {
+		m := NewManager()
+		options := RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exec sleep 10"), Exclude: []string{"*.tmp"}}
+		first, err := NewTask("a", "b", false, false, options)
+		assert.Nil(t, err)
+		duplicate, err := Definition{ID: "duplicate", Source: "a", Destination: "b", Options: options}.NewTask()
+		assert.Nil(t, err)
+		other, err := NewTask("a", "c", false, false, options)
+		assert.Nil(t, err)
+
+		task, err := m.SubmitUnique(first)
+		assert.Nil(t, err)
+		assert.Equal(t, first, task)
+		task, err = m.SubmitUnique(duplicate)
+		assert.Nil(t, err)
+		assert.Equal(t, first, task)
+		task, err = m.SubmitUnique(other)
+		assert.Nil(t, err)
+		assert.Equal(t, other, task)
+		assert.Equal(t, []*Task{first, other}, m.List())
+		assert.Equal(t, TaskPending, duplicate.Status())
+
+		// finished tasks are not joined
+		assert.Nil(t, first.Cancel())
+		assert.NotNil(t, m.Wait(first.ID()))
+		again, err := NewTask("a", "b", false, false, options)
+		assert.Nil(t, err)
+		task, err = m.SubmitUnique(again)
+		assert.Nil(t, err)
+		assert.Equal(t, again, task)
+
+		assert.Nil(t, other.Cancel())
+		assert.Nil(t, again.Cancel())
+		<-other.Done()
+		<-again.Done()
+	})
+
+	t.Run("doesn't coalesce onto added tasks", func(t *testing.T) {
+		m := NewManager()
+		options := RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")}
+		added, err := NewTask("a", "b", false, false, options)
+		assert.Nil(t, err)
+		assert.Nil(t, m.Add(added))
+
+		submitted, err := NewTask("a", "b", false, false, options)
+		assert.Nil(t, err)
+		task, err := m.SubmitUnique(submitted)
+		assert.Nil(t, err)
+		assert.Equal(t, submitted, task)
+		assert.Nil(t, m.Wait(submitted.ID()))
+		assert.Equal(t, TaskPending, added.Status())
+	})
+
+	t.Run("cancels tasks", func(t *testing.T) {
+		m := NewManager()
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exec sleep 10")})
+		assert.Nil(t, err)
+
+		assert.Nil(t, m.Submit(task))
+		assert.Nil(t, m.CancelByID(task.ID()))
+		assert.NotNil(t, m.Wait(task.ID()))
+		assert.Equal(t, TaskCancelled, task.Status())
+	})
+
+	t.Run("cancelling finished tasks is a no-op", func(t *testing.T) {
+		m := NewManager()
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+		assert.Nil(t, err)
+
+		assert.Nil(t, m.Submit(task))
+		assert.Nil(t, m.Wait(task.ID()))
+		assert.Nil(t, m.CancelByID(task.ID()))
+		assert.Equal(t, TaskSucceeded, task.Status())
+	})
+}
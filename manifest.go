@@ -0,0 +1,119 @@
+package grsync
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// ManifestEntry is a file whose content rsync transferred
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// Checksum is the full-file checksum computed by rsync, which is known for transferred files
+	// since rsync 3.1 and empty otherwise. The algorithm is the negotiated one, e.g. MD5 or XXH128.
+	// Tasks comparing checksums with a RsyncOptions.LogFile also take it from the log file
+	Checksum string `json:"checksum,omitempty"`
+	Op       FileOp `json:"op"`
+}
+
+// Manifest lists the files transferred by a run
+type Manifest []ManifestEntry
+
+// EnableManifest makes the task record a manifest of the files it transfers, see Manifest.
+// Like FileEvents, it replaces RsyncOptions.OutFormat with an own --out-format
+func (t *Task) EnableManifest() {
+	t.mutex.Lock()
+	t.manifestEnabled = true
+	t.mutex.Unlock()
+}
+
+// Manifest returns the files transferred during the current or last run, see EnableManifest
+func (t *Task) Manifest() Manifest {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append(Manifest(nil), t.manifest...)
+}
+
+// recordManifest adds the file of event to the manifest if its content was transferred
+func (t *Task) recordManifest(event FileEvent) {
+	if event.Op != FileSent && event.Op != FileReceived {
+		return
+	}
+	t.mutex.Lock()
+	if t.manifestEnabled {
+		t.manifest = append(t.manifest, ManifestEntry{
+			Path:     event.Path,
+			Size:     event.Size,
+			ModTime:  event.ModTime,
+			Checksum: event.Checksum,
+			Op:       event.Op,
+		})
+	}
+	t.mutex.Unlock()
+}
+
+// completeManifest adds the checksums rsync wrote to RsyncOptions.LogFile to the entries of the
+// manifest missing one. The log file is appended to by every run, so the last record of a path
+// counts
+func (t *Task) completeManifest() {
+	t.mutex.Lock()
+	complete := t.manifestEnabled && t.logChecksums && len(t.manifest) > 0
+	t.mutex.Unlock()
+	if !complete {
+		return
+	}
+	records, err := t.LogRecords()
+	if err != nil {
+		t.logEvent(slog.LevelWarn, "reading checksums from the log file failed", slog.Any("error", err))
+		return
+	}
+
+	checksums := make(map[string]string)
+	for _, record := range records {
+		if record.Checksum != "" && (record.Op == FileSent || record.Op == FileReceived) {
+			checksums[record.Path] = record.Checksum
+		}
+	}
+	t.mutex.Lock()
+	for i, entry := range t.manifest {
+		if entry.Checksum == "" {
+			t.manifest[i].Checksum = checksums[entry.Path]
+		}
+	}
+	t.mutex.Unlock()
+}
+
+// WriteJSON writes the manifest as a JSON array
+func (m Manifest) WriteJSON(w io.Writer) error {
+	if m == nil {
+		m = Manifest{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// WriteCSV writes the manifest as CSV with a header row, times are formatted as RFC 3339
+func (m Manifest) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"path", "size", "modTime", "checksum", "op"}); err != nil {
+		return err
+	}
+	for _, entry := range m {
+		modTime := ""
+		if !entry.ModTime.IsZero() {
+			modTime = entry.ModTime.Format(time.RFC3339)
+		}
+		record := []string{entry.Path, strconv.FormatInt(entry.Size, 10), modTime, entry.Checksum, string(entry.Op)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,67 @@
+package grsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskManifest(t *testing.T) {
+	script := `case "$*" in *"--out-format=` + fileEventPrefix + `%i %l %M [%C] %n"*) ;; *) exit 1;; esac
+echo "` + fileEventPrefix + `>f+++++++++ 1024 2023/10/07-13:19:08 [d41d8cd98f00b204e9800998ecf8427e] dir/file"
+echo "` + fileEventPrefix + `cd+++++++++ 4096 2023/10/07-13:19:08 [                                ] dir/"
+echo "` + fileEventPrefix + `*deleting   0 2023/10/07-13:19:08 [                                ] old"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.EnableManifest()
+	assert.Nil(t, task.Run())
+
+	modTime := time.Date(2023, 10, 7, 13, 19, 8, 0, time.Local)
+	manifest := task.Manifest()
+	assert.Equal(t, Manifest{{Path: "dir/file", Size: 1024, ModTime: modTime, Checksum: "d41d8cd98f00b204e9800998ecf8427e", Op: FileReceived}}, manifest)
+
+	var buf bytes.Buffer
+	assert.Nil(t, manifest.WriteCSV(&buf))
+	assert.Equal(t, "path,size,modTime,checksum,op\ndir/file,1024,"+modTime.Format(time.RFC3339)+",d41d8cd98f00b204e9800998ecf8427e,received\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, manifest.WriteJSON(&buf))
+	var decoded Manifest
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "dir/file", decoded[0].Path)
+	assert.True(t, modTime.Equal(decoded[0].ModTime))
+
+	buf.Reset()
+	assert.Nil(t, Manifest(nil).WriteJSON(&buf))
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestTaskManifestLogChecksums(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "rsync.log")
+	script := `for arg; do case "$arg" in --log-file-format=*) fmt="${arg#*=}";; esac; done
+[ "$fmt" = "` + ChecksumLogFileFormat + `" ] || exit 1
+echo "2023/10/06 13:19:08 [1] recv >f+++++++++ 3 2023/10/01-08:00:00 [00000000000000000000000000000000] a" >> ` + logFile + `
+echo "2023/10/07 13:19:08 [2] recv >f+++++++++ 3 2023/10/01-08:00:00 [d41d8cd98f00b204e9800998ecf8427e] a" >> ` + logFile + `
+echo "` + fileEventPrefix + `>f+++++++++ 3 2023/10/01-08:00:00 [                                ] a"
+echo "` + fileEventPrefix + `>f+++++++++ 3 2023/10/01-08:00:00 [                                ] b"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), LogFile: logFile, Comparison: CompareChecksum})
+	assert.Nil(t, err)
+	task.EnableManifest()
+	assert.Nil(t, task.Run())
+
+	manifest := task.Manifest()
+	assert.Len(t, manifest, 2)
+	assert.Equal(t, "d41d8cd98f00b204e9800998ecf8427e", manifest[0].Checksum)
+	assert.Equal(t, "", manifest[1].Checksum)
+
+	// without checksums rsync logs the structured format
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), LogFile: logFile})
+	assert.Nil(t, err)
+	assert.NotNil(t, task.Run())
+}
@@ -0,0 +1,93 @@
+package grsync
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// ManifestChange is a file listed by both manifests of a ManifestDiff with another size, modification
+// time or checksum
+type ManifestChange struct {
+	Path     string        `json:"path"`
+	Previous ManifestEntry `json:"previous"`
+	Current  ManifestEntry `json:"current"`
+}
+
+// ManifestDiff is what changed between the manifests of two runs, see DiffManifests. The lists are
+// sorted by path, the sizes are the totals in bytes of the current files for Added and Modified
+// and of the previous ones for Deleted
+type ManifestDiff struct {
+	Added        []ManifestEntry  `json:"added"`
+	Modified     []ManifestChange `json:"modified"`
+	Deleted      []ManifestEntry  `json:"deleted"`
+	AddedSize    int64            `json:"addedSize"`
+	ModifiedSize int64            `json:"modifiedSize"`
+	DeletedSize  int64            `json:"deletedSize"`
+}
+
+// Empty reports whether nothing changed
+func (d ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Deleted) == 0
+}
+
+// DiffManifests compares the manifest of a run to the one of a previous run: files only current
+// lists are added, files only previous lists are deleted and files both list are modified if their
+// size, modification time or checksum differ. Checksums are only compared if both manifests have
+// one. A manifest only lists the files its run transferred, so Deleted are the files missing from
+// current, which were deleted if both runs transferred every file, e.g. into empty destinations.
+// If a manifest lists a path more than once, its last entry counts
+func DiffManifests(previous, current Manifest) ManifestDiff {
+	before := manifestIndex(previous)
+	after := manifestIndex(current)
+
+	var diff ManifestDiff
+	for path, entry := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, entry)
+			diff.AddedSize += entry.Size
+		case manifestEntryChanged(old, entry):
+			diff.Modified = append(diff.Modified, ManifestChange{Path: path, Previous: old, Current: entry})
+			diff.ModifiedSize += entry.Size
+		}
+	}
+	for path, entry := range before {
+		if _, ok := after[path]; !ok {
+			diff.Deleted = append(diff.Deleted, entry)
+			diff.DeletedSize += entry.Size
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Path < diff.Added[j].Path })
+	sort.Slice(diff.Modified, func(i, j int) bool { return diff.Modified[i].Path < diff.Modified[j].Path })
+	sort.Slice(diff.Deleted, func(i, j int) bool { return diff.Deleted[i].Path < diff.Deleted[j].Path })
+	return diff
+}
+
+// ReadManifestJSON reads a manifest written by Manifest.WriteJSON, e.g. of a previous run
+func ReadManifestJSON(r io.Reader) (Manifest, error) {
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// manifestIndex returns the entries of manifest by their path
+func manifestIndex(manifest Manifest) map[string]ManifestEntry {
+	index := make(map[string]ManifestEntry, len(manifest))
+	for _, entry := range manifest {
+		index[entry.Path] = entry
+	}
+	return index
+}
+
+// manifestEntryChanged reports whether the file of previous and current differs
+func manifestEntryChanged(previous, current ManifestEntry) bool {
+	if previous.Size != current.Size || !previous.ModTime.Equal(current.ModTime) {
+		return true
+	}
+	return previous.Checksum != "" && current.Checksum != "" && previous.Checksum != current.Checksum
+}
@@ -0,0 +1,62 @@
+package grsync
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffManifests(t *testing.T) {
+	monday := time.Date(2023, 10, 9, 2, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(24 * time.Hour)
+
+	previous := Manifest{
+		{Path: "kept", Size: 10, ModTime: monday, Checksum: "aa", Op: FileReceived},
+		{Path: "grown", Size: 10, ModTime: monday, Op: FileReceived},
+		{Path: "touched", Size: 10, ModTime: monday, Op: FileReceived},
+		{Path: "rewritten", Size: 10, ModTime: monday, Checksum: "aa", Op: FileReceived},
+		{Path: "unchecked", Size: 10, ModTime: monday, Op: FileReceived},
+		{Path: "removed", Size: 30, ModTime: monday, Op: FileReceived},
+	}
+	current := Manifest{
+		{Path: "new", Size: 5, ModTime: tuesday, Op: FileReceived},
+		{Path: "kept", Size: 10, ModTime: monday.In(time.Local), Checksum: "aa", Op: FileReceived},
+		{Path: "rewritten", Size: 10, ModTime: monday, Checksum: "bb", Op: FileReceived},
+		{Path: "unchecked", Size: 10, ModTime: monday, Checksum: "cc", Op: FileReceived},
+		{Path: "touched", Size: 10, ModTime: tuesday, Op: FileReceived},
+		{Path: "grown", Size: 15, ModTime: monday, Op: FileReceived},
+		{Path: "grown", Size: 20, ModTime: monday, Op: FileReceived},
+	}
+
+	diff := DiffManifests(previous, current)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []ManifestEntry{current[0]}, diff.Added)
+	assert.Equal(t, int64(5), diff.AddedSize)
+	assert.Equal(t, []ManifestChange{
+		{Path: "grown", Previous: previous[1], Current: current[6]},
+		{Path: "rewritten", Previous: previous[3], Current: current[2]},
+		{Path: "touched", Previous: previous[2], Current: current[4]},
+	}, diff.Modified)
+	assert.Equal(t, int64(40), diff.ModifiedSize)
+	assert.Equal(t, []ManifestEntry{previous[5]}, diff.Deleted)
+	assert.Equal(t, int64(30), diff.DeletedSize)
+
+	assert.True(t, DiffManifests(previous, previous).Empty())
+	assert.True(t, DiffManifests(nil, nil).Empty())
+}
+
+func TestReadManifestJSON(t *testing.T) {
+	manifest := Manifest{{Path: "dir/file", Size: 1024, ModTime: time.Date(2023, 10, 7, 13, 19, 8, 0, time.UTC), Op: FileReceived}}
+	var buf bytes.Buffer
+	assert.Nil(t, manifest.WriteJSON(&buf))
+
+	read, err := ReadManifestJSON(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, manifest, read)
+	assert.True(t, DiffManifests(manifest, read).Empty())
+
+	_, err = ReadManifestJSON(bytes.NewBufferString("{"))
+	assert.NotNil(t, err)
+}
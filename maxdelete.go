@@ -0,0 +1,37 @@
+package grsync
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// DeleteLimit reports that rsync stopped deleting at RsyncOptions.MaxDelete, so the destination
+// still holds files that are gone from the source. rsync exits with ExitDeleteLimit then
+type DeleteLimit struct {
+	// Skipped is the number of deletions rsync skipped
+	Skipped int `json:"skipped"`
+	// Line is the warning rsync printed
+	Line string `json:"line"`
+}
+
+var deleteLimitPattern = regexp.MustCompile(`^Deletions stopped due to --max-delete limit \((\d+) skipped\)`)
+
+// DeleteLimit returns whether the current or last run hit the --max-delete limit
+func (t *Task) DeleteLimit() (DeleteLimit, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.deleteLimit == nil {
+		return DeleteLimit{}, false
+	}
+	return *t.deleteLimit, true
+}
+
+// parseDeleteLimit parses the warning rsync prints when it stopped deleting at the limit
+func parseDeleteLimit(line string) (DeleteLimit, bool) {
+	match := deleteLimitPattern.FindStringSubmatch(line)
+	if match == nil {
+		return DeleteLimit{}, false
+	}
+	skipped, _ := strconv.Atoi(match[1])
+	return DeleteLimit{Skipped: skipped, Line: line}, true
+}
@@ -0,0 +1,44 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeleteLimit(t *testing.T) {
+	limit, ok := parseDeleteLimit("Deletions stopped due to --max-delete limit (1342 skipped)")
+	assert.True(t, ok)
+	assert.Equal(t, 1342, limit.Skipped)
+
+	_, ok = parseDeleteLimit("deleting old/file")
+	assert.False(t, ok)
+}
+
+func TestTaskDeleteLimit(t *testing.T) {
+	script := `echo "deleting a"
+echo "deleting b"
+echo "Deletions stopped due to --max-delete limit (5 skipped)" >&2
+echo "rsync warning: some files were not deleted, the delete limit was reached (code 25) at main.c(1385) [generator=3.2.7]" >&2
+exit 25`
+
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Delete: true, MaxDelete: 2})
+	assert.Nil(t, err)
+	result, err := task.RunResult()
+	assert.Equal(t, ExitDeleteLimit, ExitCodeOf(err))
+
+	limit, ok := task.DeleteLimit()
+	assert.True(t, ok)
+	assert.Equal(t, DeleteLimit{Skipped: 5, Line: "Deletions stopped due to --max-delete limit (5 skipped)"}, limit)
+	assert.Equal(t, &limit, result.DeleteLimit)
+	assert.Len(t, result.Warnings, 2)
+	assert.Empty(t, result.FileErrors)
+
+	task, err = NewTask("/src/", "/dst/", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0"), MaxDelete: 2})
+	assert.Nil(t, err)
+	result, err = task.RunResult()
+	assert.Nil(t, err)
+	_, ok = task.DeleteLimit()
+	assert.False(t, ok)
+	assert.Nil(t, result.DeleteLimit)
+}
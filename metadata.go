@@ -0,0 +1,83 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrFakeSuperUnsupported is returned for RsyncOptions.FakeSuper if the local rsync lacks xattr
+	// support, which --fake-super stores the privileged attributes in
+	ErrFakeSuperUnsupported = errors.New("--fake-super needs a rsync built with xattr support")
+	// ErrMetadataUnsupported is wrapped by the error returned for the metadata options ACLs,
+	// XAttrs, Atimes, Crtimes, OpenNoatime, CopyDevices, WriteDevices, Preallocate and Fsync if the
+	// local rsync is too old or built without them
+	ErrMetadataUnsupported = errors.New("metadata not supported by rsync")
+)
+
+// metadataRequirement is the capability and the version a metadata option needs
+type metadataRequirement struct {
+	option     string
+	capability string
+	major      int
+	minor      int
+	patch      int
+}
+
+func (r metadataRequirement) supported(version RsyncVersion) bool {
+	if version.Major != r.major {
+		if version.Major < r.major {
+			return false
+		}
+	} else if version.Minor != r.minor {
+		if version.Minor < r.minor {
+			return false
+		}
+	} else if version.Patch < r.patch {
+		return false
+	}
+	return r.capability == "" || version.Supports(r.capability)
+}
+
+// metadataRequirements returns the requirements of the metadata options set in options
+func metadataRequirements(options RsyncOptions) []metadataRequirement {
+	var requirements []metadataRequirement
+	add := func(set bool, requirement metadataRequirement) {
+		if set {
+			requirements = append(requirements, requirement)
+		}
+	}
+	add(options.ACLs, metadataRequirement{option: "--acls", capability: "ACLs"})
+	add(options.XAttrs, metadataRequirement{option: "--xattrs", capability: "xattrs"})
+	add(options.Atimes, metadataRequirement{option: "--atimes", capability: "atimes", major: 3, minor: 2})
+	add(options.Crtimes, metadataRequirement{option: "--crtimes", capability: "crtimes", major: 3, minor: 2})
+	add(options.OpenNoatime, metadataRequirement{option: "--open-noatime", major: 3, minor: 2})
+	add(options.CopyDevices, metadataRequirement{option: "--copy-devices", major: 3, minor: 2})
+	add(options.WriteDevices, metadataRequirement{option: "--write-devices", major: 3, minor: 2})
+	add(options.Preallocate, metadataRequirement{option: "--preallocate", capability: "prealloc", major: 3, minor: 1})
+	add(options.Fsync, metadataRequirement{option: "--fsync", major: 3, minor: 2, patch: 4})
+	return requirements
+}
+
+// checkMetadata detects the local rsync if options ask for metadata it may not support and returns
+// whether it can't. A binary whose version can't be detected is left to rsync to reject
+func checkMetadata(options RsyncOptions, runner CommandRunner) error {
+	requirements := metadataRequirements(options)
+	if len(requirements) == 0 && !options.FakeSuper {
+		return nil
+	}
+	version, err := detectVersion(options, runner)
+	if err != nil {
+		return nil
+	}
+
+	if options.FakeSuper && !version.Supports("xattrs") {
+		return ErrFakeSuperUnsupported
+	}
+	for _, requirement := range requirements {
+		if !requirement.supported(version) {
+			return fmt.Errorf("%s with rsync %s: %w", requirement.option, version, ErrMetadataUnsupported)
+		}
+	}
+	return nil
+}
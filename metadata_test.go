@@ -0,0 +1,92 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	version, err := parseVersion(`rsync  version 3.2.7  protocol version 31
+Copyright (C) 1996-2022 by Andrew Tridgell, Wayne Davison, and others.
+Capabilities:
+    64-bit files, 64-bit inums, 64-bit timestamps, 64-bit long ints,
+    hardlink-symlinks, IPv6, atimes, batchfiles, inplace, append, ACLs,
+    xattrs, optional secluded-args, iconv, prealloc, stop-at, no crtimes
+Optimizations:
+    SIMD-roll, no asm-roll
+`)
+	assert.Nil(t, err)
+	assert.Len(t, version.Capabilities, 17)
+	assert.True(t, version.Supports("xattrs"))
+	assert.True(t, version.Supports("acls"))
+	assert.False(t, version.Supports("crtimes"))
+	assert.False(t, version.Supports("SIMD-roll"))
+
+	version, err = parseVersion(`rsync  version 2.6.9  protocol version 29
+Capabilities: 64-bit files, socketpairs, hard links, symlinks, batchfiles,
+              inplace, IPv6, 64-bit system inums, 64-bit internal inums
+
+rsync comes with ABSOLUTELY NO WARRANTY.`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"64-bit files", "socketpairs", "hard links", "symlinks", "batchfiles",
+		"inplace", "IPv6", "64-bit system inums", "64-bit internal inums"}, version.Capabilities)
+	assert.False(t, version.Supports("xattrs"))
+}
+
+func TestTaskFakeSuper(t *testing.T) {
+	noXattrs := fakeRsync(t, `[ "$1" = --version ] && printf 'rsync  version 3.2.7  protocol version 31\nCapabilities:\n    ACLs, no xattrs\n'`)
+	_, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{RsyncBinaryPath: noXattrs, FakeSuper: true})
+	assert.Equal(t, ErrFakeSuperUnsupported, err)
+
+	xattrs := fakeRsync(t, `[ "$1" = --version ] && printf 'rsync  version 3.2.7  protocol version 31\nCapabilities:\n    ACLs, xattrs\n'; exit 0`)
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{RsyncBinaryPath: xattrs, FakeSuper: true, NumericIDs: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+}
+
+func TestCheckMetadata(t *testing.T) {
+	v327 := fakeRsync(t, `printf 'rsync  version 3.2.7  protocol version 31\nCapabilities:\n    ACLs, xattrs, atimes, no crtimes\n'`)
+	v316 := fakeRsync(t, `printf 'rsync  version 3.1.6  protocol version 31\nCapabilities:\n    ACLs, xattrs\n'`)
+
+	assert.Nil(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v327, ACLs: true, XAttrs: true, Atimes: true, OpenNoatime: true}, ExecRunner{}))
+	err := checkMetadata(RsyncOptions{RsyncBinaryPath: v327, Crtimes: true}, ExecRunner{})
+	assert.ErrorIs(t, err, ErrMetadataUnsupported)
+	assert.Equal(t, "--crtimes with rsync 3.2.7: metadata not supported by rsync", err.Error())
+
+	assert.Nil(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v316, ACLs: true, XAttrs: true}, ExecRunner{}))
+	assert.ErrorIs(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v316, OpenNoatime: true}, ExecRunner{}), ErrMetadataUnsupported)
+	assert.ErrorIs(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v316, Atimes: true}, ExecRunner{}), ErrMetadataUnsupported)
+
+	// nothing to check
+	assert.Nil(t, checkMetadata(RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 1"), Archive: true}, ExecRunner{}))
+}
+
+func TestMetadataArguments(t *testing.T) {
+	args := getArguments(RsyncOptions{ACLs: true, XAttrs: true, Atimes: true, Crtimes: true, OpenNoatime: true})
+	assert.Equal(t, []string{"--acls", "--xattrs", "--atimes", "--crtimes", "--open-noatime"}, args)
+}
+
+func TestDeviceOptions(t *testing.T) {
+	v327 := fakeRsync(t, `printf 'rsync  version 3.2.7  protocol version 31\n'`)
+	v316 := fakeRsync(t, `printf 'rsync  version 3.1.6  protocol version 31\n'`)
+
+	assert.Nil(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v327, CopyDevices: true, WriteDevices: true}, ExecRunner{}))
+	err := checkMetadata(RsyncOptions{RsyncBinaryPath: v316, WriteDevices: true}, ExecRunner{})
+	assert.Equal(t, "--write-devices with rsync 3.1.6: metadata not supported by rsync", err.Error())
+	assert.ErrorIs(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v316, CopyDevices: true}, ExecRunner{}), ErrMetadataUnsupported)
+
+	args := getArguments(RsyncOptions{Archive: true, NoDevices: true, NoSpecials: true, CopyDevices: true, WriteDevices: true})
+	assert.Equal(t, []string{"--archive", "--no-devices", "--no-specials", "--copy-devices", "--write-devices"}, args)
+}
+
+func TestDurabilityOptions(t *testing.T) {
+	v327 := fakeRsync(t, `printf 'rsync  version 3.2.7  protocol version 31\nCapabilities:\n    prealloc, stop-at\n'`)
+	v323 := fakeRsync(t, `printf 'rsync  version 3.2.3  protocol version 31\nCapabilities:\n    no prealloc\n'`)
+
+	assert.Nil(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v327, Preallocate: true, Fsync: true}, ExecRunner{}))
+	assert.Equal(t, "--fsync with rsync 3.2.3: metadata not supported by rsync", checkMetadata(RsyncOptions{RsyncBinaryPath: v323, Fsync: true}, ExecRunner{}).Error())
+	assert.ErrorIs(t, checkMetadata(RsyncOptions{RsyncBinaryPath: v323, Preallocate: true}, ExecRunner{}), ErrMetadataUnsupported)
+
+	assert.Equal(t, []string{"--sparse", "--preallocate", "--fsync"}, getArguments(RsyncOptions{Sparse: true, Preallocate: true, Fsync: true}))
+}
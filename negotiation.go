@@ -0,0 +1,71 @@
+package grsync
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Negotiation is what rsync negotiated with the remote side, reported with DebugFlags
+// {"NSTR": 1, "PROTO": 1} or -vv and above. Unreported fields are zero, rsync before 3.2 doesn't
+// negotiate checksums and compression
+type Negotiation struct {
+	// Protocol is the protocol version both sides use and RemoteProtocol the newest the remote side supports
+	Protocol       int `json:"protocol,omitempty"`
+	RemoteProtocol int `json:"remoteProtocol,omitempty"`
+	// Checksum is the checksum algorithm, e.g. "xxh128" or "md5"
+	Checksum string `json:"checksum,omitempty"`
+	// Compression is the compression algorithm with Compress, e.g. "zstd" or "zlibx"
+	Compression string `json:"compression,omitempty"`
+}
+
+var (
+	// e.g. "(Client) Protocol versions: remote=31, negotiated=31"
+	protocolMarker = []byte(") Protocol versions: ")
+	// e.g. "Client negotiated checksum: xxh128" and "Client negotiated compress: zstd"
+	negotiatedPrefix = []byte("Client negotiated ")
+)
+
+// Negotiation returns what rsync negotiated during the current or last attempt
+func (t *Task) Negotiation() Negotiation {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.negotiation
+}
+
+// parseNegotiationLine stores what a debug line reports rsync negotiated and reports whether line
+// was one. The mutex must be held
+func (t *Task) parseNegotiationLine(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if _, versions, ok := bytes.Cut(line, protocolMarker); ok && line[0] == '(' {
+		for _, field := range bytes.Split(versions, []byte(", ")) {
+			key, value, _ := bytes.Cut(field, []byte("="))
+			version, err := strconv.Atoi(string(value))
+			if err != nil {
+				continue
+			}
+			switch string(key) {
+			case "remote":
+				t.negotiation.RemoteProtocol = version
+			case "negotiated":
+				t.negotiation.Protocol = version
+			}
+		}
+		return true
+	}
+	if !bytes.HasPrefix(line, negotiatedPrefix) {
+		return false
+	}
+	kind, name, ok := bytes.Cut(line[len(negotiatedPrefix):], []byte(": "))
+	if !ok {
+		return false
+	}
+	switch string(kind) {
+	case "checksum":
+		t.negotiation.Checksum = string(name)
+	case "compress":
+		t.negotiation.Compression = string(name)
+	default:
+		return false
+	}
+	return true
+}
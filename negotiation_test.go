@@ -0,0 +1,33 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNegotiationLine(t *testing.T) {
+	task := &Task{}
+	assert.True(t, task.parseNegotiationLine([]byte("(Client) Protocol versions: remote=31, negotiated=30")))
+	assert.True(t, task.parseNegotiationLine([]byte("Client negotiated checksum: xxh128")))
+	assert.True(t, task.parseNegotiationLine([]byte("Client negotiated compress: zstd")))
+	assert.False(t, task.parseNegotiationLine([]byte("Client checksum list (on client): xxh128 xxh3 xxh64 md5 md4")))
+	assert.False(t, task.parseNegotiationLine([]byte("Client negotiated something: else")))
+	assert.False(t, task.parseNegotiationLine([]byte("sending incremental file list")))
+	assert.Equal(t, Negotiation{Protocol: 30, RemoteProtocol: 31, Checksum: "xxh128", Compression: "zstd"}, task.negotiation)
+}
+
+func TestTaskNegotiation(t *testing.T) {
+	script := `echo "(Client) Protocol versions: remote=31, negotiated=31"
+echo "Client negotiated checksum: xxh128"
+echo "sending incremental file list"`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), DebugFlags: OutputFlags{"NSTR": 1, "PROTO": 1}})
+	assert.Nil(t, err)
+
+	result, err := task.RunResult()
+	assert.Nil(t, err)
+	expected := Negotiation{Protocol: 31, RemoteProtocol: 31, Checksum: "xxh128"}
+	assert.Equal(t, expected, task.Negotiation())
+	assert.Equal(t, expected, result.Negotiation)
+	assert.Zero(t, result.UnparsedLines)
+}
@@ -0,0 +1,213 @@
+package grsync
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutFormatField is the FileEvent field a verb of RsyncOptions.CustomOutFormat fills
+type OutFormatField string
+
+const (
+	// FieldSkip ignores the value of the verb
+	FieldSkip OutFormatField = "skip"
+	// FieldPath is FileEvent.Path, e.g. of %n or %f
+	FieldPath OutFormatField = "path"
+	// FieldItemize is FileEvent.Itemize and Op, e.g. of %i
+	FieldItemize OutFormatField = "itemize"
+	// FieldOperation is FileEvent.Op from rsync's %o: send, recv or del.
+	FieldOperation OutFormatField = "operation"
+	// FieldSize is FileEvent.Size, e.g. of %l
+	FieldSize OutFormatField = "size"
+	// FieldTransferred is FileEvent.Transferred, e.g. of %b
+	FieldTransferred OutFormatField = "transferred"
+	// FieldModTime is FileEvent.ModTime, e.g. of %M
+	FieldModTime OutFormatField = "modTime"
+	// FieldChecksum is FileEvent.Checksum, e.g. of %C
+	FieldChecksum OutFormatField = "checksum"
+)
+
+// defaultOutFormatFields are the fields of the verbs RsyncOptions.OutFormatFields doesn't map
+var defaultOutFormatFields = map[byte]OutFormatField{
+	'n': FieldPath,
+	'f': FieldPath,
+	'i': FieldItemize,
+	'o': FieldOperation,
+	'l': FieldSize,
+	'b': FieldTransferred,
+	'M': FieldModTime,
+	'C': FieldChecksum,
+}
+
+// outFormatWidths are the widths of the verbs whose values contain spaces
+var outFormatWidths = map[byte]int{'t': len(logTimeLayout)}
+
+// linkArrow starts the value of %L, the target of a symlink
+const linkArrow = " -> "
+
+// outFormatPart is a literal or a verb of a custom --out-format
+type outFormatPart struct {
+	literal string
+	verb    byte
+	field   OutFormatField
+}
+
+// outFormat is a compiled custom --out-format, which parses the lines rsync prints with it
+type outFormat struct {
+	parts []outFormatPart
+}
+
+// parseOutFormat compiles format with fields mapping verbs to the FileEvent fields they fill. A
+// verb needs a literal separating it from the next one, and one of them the path
+func parseOutFormat(format string, fields map[string]OutFormatField) (*outFormat, error) {
+	for verb, field := range fields {
+		switch field {
+		case FieldSkip, FieldPath, FieldItemize, FieldOperation, FieldSize, FieldTransferred, FieldModTime, FieldChecksum:
+		default:
+			return nil, fmt.Errorf("unknown out-format field %q for %%%s", field, verb)
+		}
+	}
+
+	var f outFormat
+	var literal strings.Builder
+	hasPath := false
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal.WriteByte(format[i])
+			continue
+		}
+		// skip the flags and the width, e.g. of %'l or %-10n
+		i++
+		for i < len(format) && (format[i] == '\'' || format[i] == '-' || isDigit(format[i])) {
+			i++
+		}
+		if i == len(format) {
+			return nil, fmt.Errorf("out-format %q ends with an incomplete verb", format)
+		}
+		if format[i] == '%' {
+			literal.WriteByte('%')
+			continue
+		}
+
+		if literal.Len() > 0 {
+			f.parts = append(f.parts, outFormatPart{literal: literal.String()})
+			literal.Reset()
+		} else if len(f.parts) > 0 && format[i] != 'L' {
+			// %L is empty or starts with " -> ", like after the default "%i %n%L"
+			return nil, fmt.Errorf("out-format %q needs a separator before %%%c", format, format[i])
+		}
+		field, ok := fields[string(format[i])]
+		if !ok {
+			if field, ok = defaultOutFormatFields[format[i]]; !ok {
+				field = FieldSkip
+			}
+		}
+		hasPath = hasPath || field == FieldPath
+		f.parts = append(f.parts, outFormatPart{verb: format[i], field: field})
+	}
+	if literal.Len() > 0 {
+		f.parts = append(f.parts, outFormatPart{literal: literal.String()})
+	}
+	if !hasPath {
+		return nil, fmt.Errorf("out-format %q has no verb for the path", format)
+	}
+	return &f, nil
+}
+
+// parse parses a line printed with the format, without fileEventPrefix. Values are trimmed of the
+// padding rsync adds, the path only of the leading one
+func (f *outFormat) parse(line string) (FileEvent, bool) {
+	var event FileEvent
+	itemized := false
+	for i, part := range f.parts {
+		if part.literal != "" {
+			if !strings.HasPrefix(line, part.literal) {
+				return FileEvent{}, false
+			}
+			line = line[len(part.literal):]
+			continue
+		}
+
+		line = strings.TrimLeft(line, " ")
+		end := len(line)
+		if width, ok := outFormatWidths[part.verb]; ok {
+			end = min(width, end)
+		} else if i+1 < len(f.parts) && f.parts[i+1].verb == 'L' {
+			if end = strings.Index(line, linkArrow); end < 0 {
+				end = f.literalIndex(i+2, line)
+			}
+		} else if end = f.literalIndex(i+1, line); end < 0 {
+			return FileEvent{}, false
+		}
+		value := line[:end]
+		line = line[end:]
+		if part.field != FieldPath {
+			value = strings.TrimRight(value, " ")
+		}
+
+		var err error
+		switch part.field {
+		case FieldPath:
+			event.Path = unescapeName(value)
+		case FieldItemize:
+			event.Itemize, event.Op = value, fileOp(value)
+			itemized = true
+		case FieldOperation:
+			if !itemized {
+				event.Op = operationOp(value)
+			}
+		case FieldSize:
+			event.Size, err = strconv.ParseInt(strings.ReplaceAll(value, ",", ""), 10, 64)
+		case FieldTransferred:
+			event.Transferred, err = strconv.ParseInt(strings.ReplaceAll(value, ",", ""), 10, 64)
+		case FieldModTime:
+			event.ModTime, err = time.ParseInLocation(logMTimeLayout, value, time.Local)
+		case FieldChecksum:
+			event.Checksum = value
+		}
+		if err != nil {
+			return FileEvent{}, false
+		}
+	}
+	if line != "" || event.Path == "" {
+		return FileEvent{}, false
+	}
+	if event.Op == "" {
+		event.Op = FileUnknown
+	}
+	return event, true
+}
+
+// literalIndex returns the index of the literal part i in line, the end of line if there is no
+// part i
+func (f *outFormat) literalIndex(i int, line string) int {
+	if i >= len(f.parts) {
+		return len(line)
+	}
+	return strings.Index(line, f.parts[i].literal)
+}
+
+// operationOp returns the FileOp of rsync's %o
+func operationOp(operation string) FileOp {
+	switch operation {
+	case "send":
+		return FileSent
+	case "recv":
+		return FileReceived
+	case "del.":
+		return FileDeleted
+	default:
+		return FileUnknown
+	}
+}
+
+// checkOutFormat rejects a CustomOutFormat the task can't parse
+func checkOutFormat(options RsyncOptions) error {
+	if options.CustomOutFormat == "" {
+		return nil
+	}
+	_, err := parseOutFormat(options.CustomOutFormat, options.OutFormatFields)
+	return err
+}
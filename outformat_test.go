@@ -0,0 +1,84 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutFormat(t *testing.T) {
+	format, err := parseOutFormat("%t %o %i %'l %b %M %n%L", nil)
+	assert.Nil(t, err)
+
+	event, ok := format.parse("2023/10/07 13:19:08 recv >f+++++++++ 1,024 512 2023/10/01-08:00:00 dir/new file.txt")
+	assert.True(t, ok)
+	assert.Equal(t, FileEvent{
+		Op:          FileReceived,
+		Itemize:     ">f+++++++++",
+		Size:        1024,
+		Transferred: 512,
+		ModTime:     time.Date(2023, 10, 1, 8, 0, 0, 0, time.Local),
+		Path:        "dir/new file.txt",
+	}, event)
+
+	event, ok = format.parse("2023/10/07 13:19:08 del. *deleting          0 0 2023/09/01-10:30:00 old.txt")
+	assert.True(t, ok)
+	assert.Equal(t, FileDeleted, event.Op)
+	assert.Equal(t, "old.txt", event.Path)
+
+	event, ok = format.parse("2023/10/07 13:19:08 recv cL+++++++++ 6 0 2023/10/01-08:00:00 link -> target")
+	assert.True(t, ok)
+	assert.Equal(t, "link", event.Path)
+
+	for _, line := range []string{"", "2023/10/07 13:19:08 recv >f+++++++++ big 0 2023/10/01-08:00:00 a", "2023/10/07 13:19:08 recv"} {
+		_, ok = format.parse(line)
+		assert.False(t, ok, line)
+	}
+
+	// mapped verbs, skipped ones and literals
+	format, err = parseOutFormat("[%C] %%%p|%f", map[string]OutFormatField{"C": FieldSkip, "p": FieldChecksum})
+	assert.Nil(t, err)
+	event, ok = format.parse("[d41d8cd98f00b204e9800998ecf8427e] %4242|/srv/a")
+	assert.True(t, ok)
+	assert.Equal(t, FileEvent{Op: FileUnknown, Checksum: "4242", Path: "/srv/a"}, event)
+
+	for _, c := range []struct {
+		format string
+		fields map[string]OutFormatField
+	}{
+		{"%i %l", nil},
+		{"%i%n", nil},
+		{"%n %", nil},
+		{"%n", map[string]OutFormatField{"l": "length"}},
+	} {
+		_, err = parseOutFormat(c.format, c.fields)
+		assert.NotNil(t, err, c.format)
+	}
+}
+
+func TestTaskCustomOutFormat(t *testing.T) {
+	script := `case "$*" in *"--out-format=` + fileEventPrefix + `%o %i %b %n"*) ;; *) exit 1;; esac
+echo "` + fileEventPrefix + `recv >f+++++++++ 512 a.txt"
+echo "` + fileEventPrefix + `del. *deleting   0 b.txt"`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), CustomOutFormat: "%o %i %b %n"})
+	assert.Nil(t, err)
+	var events []FileEvent
+	task.OnFileEvent(func(event FileEvent) { events = append(events, event) })
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, []FileEvent{
+		{Op: FileReceived, Itemize: ">f+++++++++", Transferred: 512, Path: "a.txt"},
+		{Op: FileDeleted, Itemize: "*deleting", Path: "b.txt"},
+	}, events)
+	assert.Equal(t, []string{"b.txt"}, task.Deleted())
+	assert.Zero(t, task.UnparsedLines())
+
+	// without listeners the format is passed as is
+	rsync, err := NewRsync("a", "b", false, false, RsyncOptions{CustomOutFormat: "%n", OutFormat: true})
+	assert.Nil(t, err)
+	assert.Contains(t, rsync.Command(), "--out-format=%n")
+
+	_, err = NewTask("a", "b", false, false, RsyncOptions{CustomOutFormat: "%l"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,60 @@
+package grsync
+
+import (
+	"io"
+	"sync"
+)
+
+// SetStdoutWriter makes the task copy the raw stdout of rsync to w while it is parsed.
+// Write errors stop the copying but don't interrupt the transfer
+func (t *Task) SetStdoutWriter(w io.Writer) {
+	t.mutex.Lock()
+	t.stdoutWriter = w
+	t.mutex.Unlock()
+}
+
+// SetStderrWriter makes the task copy the raw stderr of rsync to w, see SetStdoutWriter
+func (t *Task) SetStderrWriter(w io.Writer) {
+	t.mutex.Lock()
+	t.stderrWriter = w
+	t.mutex.Unlock()
+}
+
+// tee returns r copying everything read to the non-nil writers. A failing writer
+// doesn't affect the other writers
+func tee(r io.Reader, writers ...io.Writer) io.Reader {
+	var tolerant []io.Writer
+	for _, w := range writers {
+		if w != nil {
+			tolerant = append(tolerant, &tolerantWriter{w: w})
+		}
+	}
+
+	switch len(tolerant) {
+	case 0:
+		return r
+	case 1:
+		return io.TeeReader(r, tolerant[0])
+	default:
+		return io.TeeReader(r, io.MultiWriter(tolerant...))
+	}
+}
+
+// tolerantWriter stops writing to w after the first error instead of failing the reader
+type tolerantWriter struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	failed bool
+}
+
+func (w *tolerantWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.failed {
+		if _, err := w.w.Write(p); err != nil {
+			w.failed = true
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,42 @@
+package grsync
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestTaskOutputWriters(t *testing.T) {
+	t.Run("copies raw output", func(t *testing.T) {
+		script := `printf "a\rb\n"; echo err >&2`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+
+		var stdout, stderr bytes.Buffer
+		task.SetStdoutWriter(&stdout)
+		task.SetStderrWriter(&stderr)
+		assert.Nil(t, task.Run())
+
+		assert.Equal(t, "a\rb\n", stdout.String())
+		assert.Equal(t, "err\n", stderr.String())
+		assert.Equal(t, "a\nb\n", task.Log().Stdout)
+	})
+
+	t.Run("failing writer does not break parsing", func(t *testing.T) {
+		script := `echo one; echo two`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+
+		task.SetStdoutWriter(failingWriter{})
+		assert.Nil(t, task.Run())
+		assert.Equal(t, "one\ntwo\n", task.Log().Stdout)
+	})
+}
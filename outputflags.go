@@ -0,0 +1,83 @@
+package grsync
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OutputFlags are the levels of rsync's --info or --debug categories, e.g.
+// OutputFlags{"progress": 2, "stats": 3, "name": 1} for --info=name1,progress2,stats3.
+// rsync --info=help and --debug=help list the categories
+type OutputFlags map[string]int
+
+// String returns the flags in the form rsync expects, sorted by category
+func (f OutputFlags) String() string {
+	categories := make([]string, 0, len(f))
+	for category := range f {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for i, category := range categories {
+		categories[i] = strings.ToLower(category) + strconv.Itoa(f[category])
+	}
+	return strings.Join(categories, ",")
+}
+
+// Level returns the level of category, ok is false if it isn't set
+func (f OutputFlags) Level(category string) (level int, ok bool) {
+	for c, l := range f {
+		if strings.EqualFold(c, category) {
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+// outputFlagsArgument joins the raw flags and the structured ones into the value of --info or --debug
+func outputFlagsArgument(raw string, flags OutputFlags) string {
+	structured := flags.String()
+	switch {
+	case raw == "":
+		return structured
+	case structured == "":
+		return raw
+	}
+	return raw + "," + structured
+}
+
+// requiredInfoFlags raises the info categories the parser of a task depends on: progress lines
+// update the state unless progress is off and with file events the name lines carry them. Lower
+// levels set by the caller would silence them, so they are raised to 1. flags isn't modified
+func requiredInfoFlags(flags OutputFlags, progress, fileEvents bool) OutputFlags {
+	var required []string
+	if progress {
+		required = append(required, "progress")
+	}
+	if fileEvents {
+		required = append(required, "name")
+	}
+
+	var raised OutputFlags
+	for _, category := range required {
+		if level, ok := flags.Level(category); !ok || level > 0 {
+			continue
+		}
+		if raised == nil {
+			raised = make(OutputFlags, len(flags))
+			for c, l := range flags {
+				raised[c] = l
+			}
+		}
+		for c := range raised {
+			if strings.EqualFold(c, category) {
+				delete(raised, c)
+			}
+		}
+		raised[category] = 1
+	}
+	if raised == nil {
+		return flags
+	}
+	return raised
+}
@@ -0,0 +1,44 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputFlagsString(t *testing.T) {
+	assert.Equal(t, "", OutputFlags(nil).String())
+	assert.Equal(t, "name1,progress2,stats3", OutputFlags{"progress": 2, "stats": 3, "name": 1}.String())
+	assert.Equal(t, "del0", OutputFlags{"DEL": 0}.String())
+}
+
+func TestOutputFlagsArgument(t *testing.T) {
+	assert.Equal(t, "", outputFlagsArgument("", nil))
+	assert.Equal(t, "progress2", outputFlagsArgument("progress2", nil))
+	assert.Equal(t, "stats2", outputFlagsArgument("", OutputFlags{"stats": 2}))
+	assert.Equal(t, "progress2,stats2", outputFlagsArgument("progress2", OutputFlags{"stats": 2}))
+}
+
+func TestRequiredInfoFlags(t *testing.T) {
+	flags := OutputFlags{"Progress": 0, "name": 0, "stats": 2}
+	assert.Equal(t, OutputFlags{"progress": 1, "name": 0, "stats": 2}, requiredInfoFlags(flags, true, false))
+	assert.Equal(t, OutputFlags{"progress": 1, "name": 1, "stats": 2}, requiredInfoFlags(flags, true, true))
+	assert.Equal(t, OutputFlags{"Progress": 0, "name": 0, "stats": 2}, flags)
+
+	unchanged := OutputFlags{"progress": 2}
+	assert.Equal(t, unchanged, requiredInfoFlags(unchanged, true, true))
+	assert.Nil(t, requiredInfoFlags(nil, true, true))
+	assert.Equal(t, flags, requiredInfoFlags(flags, false, false))
+}
+
+func TestTaskInfoFlags(t *testing.T) {
+	runner := &cannedRunner{}
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{
+		InfoFlags: OutputFlags{"progress": 0, "stats": 2},
+	})
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	assert.Nil(t, task.Run())
+	assert.Contains(t, runner.args, "progress1,stats2")
+	assert.Equal(t, 0, task.definition.Options.InfoFlags["progress"])
+}
@@ -0,0 +1,173 @@
+package grsync
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPacedFilesFrom is returned by NewPacedTask for options without a local FilesFrom
+	ErrPacedFilesFrom = errors.New("paced tasks need a local files-from list")
+	// ErrPacedCancelled is returned by PacedTask.Run if it was cancelled
+	ErrPacedCancelled = errors.New("paced task was cancelled")
+)
+
+// PacedOptions configure a PacedTask
+type PacedOptions struct {
+	// FilesPerSecond is the maximum average rate of transferred files
+	FilesPerSecond float64
+	// ChunkSize is the number of names of the files-from list per rsync process, FilesPerSecond
+	// rounded up if zero. Smaller chunks pace more evenly but start more processes
+	ChunkSize int
+}
+
+// PacedTask limits how fast files are transferred, for destinations throttling metadata
+// operations like object store gateways or NFS servers. rsync can't pace files itself, so the
+// files-from list of the options is split into chunks transferred by sequential rsync processes.
+// After each chunk the task waits until the files transferred so far don't exceed the rate
+type PacedTask struct {
+	source      string
+	destination string
+	options     PacedOptions
+	rsync       RsyncOptions
+
+	mutex     sync.Mutex
+	tasks     []*Task
+	cancelled bool
+	cancel    chan struct{}
+}
+
+// NewPacedTask returns a task transferring the files listed in rsyncOptions.FilesFrom from source
+// to destination at no more than options.FilesPerSecond
+func NewPacedTask(source, destination string, options PacedOptions, rsyncOptions RsyncOptions) (*PacedTask, error) {
+	if options.FilesPerSecond <= 0 {
+		return nil, fmt.Errorf("invalid files per second %v", options.FilesPerSecond)
+	}
+	if _, _, remote := splitRemote(rsyncOptions.FilesFrom); rsyncOptions.FilesFrom == "" || rsyncOptions.FilesFrom == "-" || remote {
+		return nil, ErrPacedFilesFrom
+	}
+	if options.ChunkSize < 1 {
+		options.ChunkSize = int(math.Ceil(options.FilesPerSecond))
+	}
+	return &PacedTask{
+		source:      source,
+		destination: destination,
+		options:     options,
+		rsync:       rsyncOptions,
+		cancel:      make(chan struct{}),
+	}, nil
+}
+
+// Tasks returns the tasks of the chunks started so far, in their order
+func (p *PacedTask) Tasks() []*Task {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]*Task(nil), p.tasks...)
+}
+
+// State combines the states of the chunks started so far
+func (p *PacedTask) State() State {
+	return combineStates(p.Tasks(), 0)
+}
+
+// Cancel stops the running chunk and skips the others
+func (p *PacedTask) Cancel() error {
+	p.mutex.Lock()
+	if !p.cancelled {
+		p.cancelled = true
+		close(p.cancel)
+	}
+	tasks := p.tasks
+	p.mutex.Unlock()
+	return cancelTasks(tasks)
+}
+
+// Run transfers the chunks one after the other and stops at the first failed one
+func (p *PacedTask) Run() error {
+	names, err := readFilesFrom(resolvePath(p.rsync.WorkDir, p.rsync.FilesFrom))
+	if err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp("", "grsync-paced-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	started := time.Now()
+	var files int
+	for i := 0; i < len(names); i += p.options.ChunkSize {
+		chunk := names[i:min(i+p.options.ChunkSize, len(names))]
+		list := filepath.Join(staging, fmt.Sprintf("chunk%05d", i/p.options.ChunkSize))
+		if err = os.WriteFile(list, []byte(strings.Join(chunk, "\n")+"\n"), 0600); err != nil {
+			return err
+		}
+
+		options := p.rsync
+		options.FilesFrom = list
+		task, err := NewTask(p.source, p.destination, false, false, options)
+		if err != nil {
+			return err
+		}
+		p.mutex.Lock()
+		if p.cancelled {
+			p.mutex.Unlock()
+			return ErrPacedCancelled
+		}
+		p.tasks = append(p.tasks, task)
+		p.mutex.Unlock()
+
+		result, err := task.RunResult()
+		if err != nil {
+			return err
+		}
+		files += result.FilesTransferred
+		if i+p.options.ChunkSize >= len(names) {
+			break
+		}
+		if err = p.wait(started.Add(time.Duration(float64(files) / p.options.FilesPerSecond * float64(time.Second)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait blocks until until, ErrPacedCancelled is returned if the task is cancelled meanwhile
+func (p *PacedTask) wait(until time.Time) error {
+	timer := time.NewTimer(time.Until(until))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.cancel:
+		return ErrPacedCancelled
+	}
+}
+
+// readFilesFrom returns the names of a files-from list, which rsync reads skipping blank lines and
+// comments starting with '#' or ';'
+func readFilesFrom(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name := strings.TrimSuffix(scanner.Text(), "\r")
+		if name == "" || name[0] == '#' || name[0] == ';' {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, scanner.Err()
+}
@@ -0,0 +1,65 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pacedScript prints a progress line for every name of the files-from list
+const pacedScript = `for arg; do case "$arg" in --files-from=*) list="${arg#--files-from=}";; esac; done
+i=0
+while read -r name; do
+	i=$((i+1))
+	echo "          10 100%    1.00kB/s    0:00:00 (xfr#$i, to-chk=0/1)"
+done < "$list"`
+
+func TestNewPacedTask(t *testing.T) {
+	_, err := NewPacedTask("a", "b", PacedOptions{FilesPerSecond: 1}, RsyncOptions{})
+	assert.Equal(t, ErrPacedFilesFrom, err)
+	_, err = NewPacedTask("a", "b", PacedOptions{FilesPerSecond: 1}, RsyncOptions{FilesFrom: "host:list"})
+	assert.Equal(t, ErrPacedFilesFrom, err)
+	_, err = NewPacedTask("a", "b", PacedOptions{}, RsyncOptions{FilesFrom: "list"})
+	assert.NotNil(t, err)
+
+	task, err := NewPacedTask("a", "b", PacedOptions{FilesPerSecond: 2.5}, RsyncOptions{FilesFrom: "list"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, task.options.ChunkSize)
+}
+
+func TestReadFilesFrom(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "list")
+	assert.Nil(t, os.WriteFile(list, []byte("a\n\n# comment\n; comment\nb c\r\n"), 0600))
+	names, err := readFilesFrom(list)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b c"}, names)
+}
+
+func TestPacedTask(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "list")
+	assert.Nil(t, os.WriteFile(list, []byte("a\nb\nc\nd\ne\n"), 0600))
+
+	task, err := NewPacedTask("src/", "dst/", PacedOptions{FilesPerSecond: 20, ChunkSize: 2}, RsyncOptions{RsyncBinaryPath: fakeRsync(t, pacedScript), FilesFrom: list})
+	assert.Nil(t, err)
+	started := time.Now()
+	assert.Nil(t, task.Run())
+
+	// 4 files were transferred before the last chunk started
+	assert.True(t, time.Since(started) >= 200*time.Millisecond)
+	assert.Len(t, task.Tasks(), 3)
+	assert.Equal(t, 5, task.State().FilesTransferred)
+}
+
+func TestPacedTaskCancel(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "list")
+	assert.Nil(t, os.WriteFile(list, []byte("a\nb\n"), 0600))
+
+	task, err := NewPacedTask("src/", "dst/", PacedOptions{FilesPerSecond: 0.1, ChunkSize: 1}, RsyncOptions{RsyncBinaryPath: fakeRsync(t, pacedScript), FilesFrom: list})
+	assert.Nil(t, err)
+	time.AfterFunc(100*time.Millisecond, func() { _ = task.Cancel() })
+	assert.Equal(t, ErrPacedCancelled, task.Run())
+	assert.Len(t, task.Tasks(), 1)
+}
@@ -0,0 +1,330 @@
+package grsync
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrParallelDeleteExcluded is returned by NewParallelTask for options with DeleteExcluded: every
+	// process excludes the entries of the others and would delete them
+	ErrParallelDeleteExcluded = errors.New("parallel tasks can't delete excluded files")
+	// ErrParallelCancelled is returned by ParallelTask.Run if it was cancelled before the processes started
+	ErrParallelCancelled = errors.New("parallel task was cancelled")
+)
+
+// ParallelTask syncs the contents of a directory with several concurrent rsync processes. The
+// top-level entries of the source are listed with their total size and balanced across the
+// processes, each of which excludes the entries of the others. It helps with fast links and many
+// small files, which a single rsync process underutilizes
+type ParallelTask struct {
+	source      string
+	destination string
+	workers     int
+	options     RsyncOptions
+
+	mutex     sync.Mutex
+	tasks     []*Task
+	size      int64
+	cancelled bool
+}
+
+// NewParallelTask returns a task syncing the contents of source into destination, like rsync
+// does for a source with a trailing slash, with at most workers concurrent rsync processes
+func NewParallelTask(source, destination string, workers int, options RsyncOptions) (*ParallelTask, error) {
+	if workers < 1 {
+		return nil, errors.New("parallel tasks need at least one worker")
+	}
+	if options.DeleteExcluded {
+		return nil, ErrParallelDeleteExcluded
+	}
+	if !strings.HasSuffix(source, "/") {
+		source += "/"
+	}
+	return &ParallelTask{source: source, destination: destination, workers: workers, options: options}, nil
+}
+
+// Tasks returns the tasks of the rsync processes, they are created by Run
+func (p *ParallelTask) Tasks() []*Task {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]*Task(nil), p.tasks...)
+}
+
+// State combines the states of the rsync processes. Progress is relative to the listed size of the
+// source, which needs Info "progress2" for rsync to report the bytes of the whole transfer
+func (p *ParallelTask) State() State {
+	p.mutex.Lock()
+	tasks, size := p.tasks, p.size
+	p.mutex.Unlock()
+	return combineStates(tasks, size)
+}
+
+// Cancel stops all rsync processes
+func (p *ParallelTask) Cancel() error {
+	p.mutex.Lock()
+	p.cancelled = true
+	tasks := p.tasks
+	p.mutex.Unlock()
+	return cancelTasks(tasks)
+}
+
+// Run lists the source, partitions it and runs the rsync processes. The errors of all failed
+// processes are joined
+func (p *ParallelTask) Run() error {
+	entries, err := p.list()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.size
+	}
+	buckets := partitionEntries(entries, p.workers)
+
+	tasks := make([]*Task, len(buckets))
+	for i := range buckets {
+		// the entries of the others are excluded ahead of the filters of the options, which would
+		// otherwise include them first, e.g. Include "*/"
+		options := p.options
+		options.partitionRules = nil
+		for j, other := range buckets {
+			if j == i {
+				continue
+			}
+			for _, entry := range other {
+				options.partitionRules = append(options.partitionRules, "- /"+escapePattern(entry.name))
+			}
+		}
+		if tasks[i], err = NewTask(p.source, p.destination, false, false, options); err != nil {
+			return err
+		}
+	}
+
+	p.mutex.Lock()
+	if p.cancelled {
+		p.mutex.Unlock()
+		return ErrParallelCancelled
+	}
+	p.tasks, p.size = tasks, size
+	p.mutex.Unlock()
+
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task *Task) {
+			defer wg.Done()
+			errs[i] = task.Run()
+		}(i, task)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// sourceEntry is a top-level entry of the source and the total size of the files below it
+type sourceEntry struct {
+	name string
+	size int64
+}
+
+var listPattern = regexp.MustCompile(`^([-a-zA-Z]{10}) +([\d,.]+[A-Za-z]?) (\d+/\d+/\d+ \d+:\d+:\d+) (.+)$`)
+
+// list returns the top-level entries of the source using rsync --list-only
+func (p *ParallelTask) list() ([]sourceEntry, error) {
+	out, err := listRecursive(p.source, p.options)
+	if err != nil {
+		return nil, err
+	}
+	return parseListing(out), nil
+}
+
+// listRecursive returns the output of rsync --list-only -r for source, filtered like options
+func listRecursive(source string, options RsyncOptions) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd, err := listCommand(source, options)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w: %s", source, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// listCommand returns the rsync --list-only -r command for source, filtered and run through sudo
+// like options, so the listing sees the tree the transfer does
+func listCommand(source string, options RsyncOptions) (*exec.Cmd, error) {
+	listOptions := RsyncOptions{
+		WorkDir:                options.WorkDir,
+		RsyncPath:              options.RsyncPath,
+		ArgProtection:          options.ArgProtection,
+		RemoteSudo:             options.RemoteSudo,
+		RemoteSudoUser:         options.RemoteSudoUser,
+		RemoteSudoPasswordFile: options.RemoteSudoPasswordFile,
+		Rsh:                    options.Rsh,
+		RemoteShell:            options.RemoteShell,
+		PasswordFile:           options.PasswordFile,
+		IPv4:                   options.IPv4,
+		IPv6:                   options.IPv6,
+		SocketOptions:          options.SocketOptions,
+		Include:                options.Include,
+		Exclude:                options.Exclude,
+		IncludeFrom:            options.IncludeFrom,
+		ExcludeFrom:            options.ExcludeFrom,
+		Filter:                 options.Filter,
+		IgnoreFiles:            options.IgnoreFiles,
+		GitIgnore:              options.GitIgnore,
+		CVSExclude:             options.CVSExclude,
+		Recursive:              true,
+		ListOnly:               true,
+	}
+	var err error
+	if listOptions.ignoreRules, err = ignoreFileRules(options.IgnoreFiles, options.WorkDir); err != nil {
+		return nil, err
+	}
+	if listOptions, err = sudoOptions(listOptions, source, ""); err != nil {
+		return nil, err
+	}
+	binaryPath := "rsync"
+	if options.RsyncBinaryPath != "" {
+		binaryPath = options.RsyncBinaryPath
+	}
+
+	cmd := exec.Command(binaryPath, append(getArguments(listOptions), source)...)
+	cmd.Env = processEnv(options)
+	cmd.Dir = options.WorkDir
+	cmd.SysProcAttr = options.Credential.sysProcAttr()
+	return cmd, nil
+}
+
+// parseListing sums the sizes of the files in a recursive --list-only output per top-level entry
+func parseListing(out []byte) []sourceEntry {
+	sizes := make(map[string]int64)
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		match := listPattern.FindStringSubmatch(scanner.Text())
+		if match == nil || match[4] == "." {
+			continue
+		}
+		name := match[4]
+		if match[1][0] == 'l' {
+			name = strings.SplitN(name, " -> ", 2)[0]
+		}
+		name = unescapeName(name)
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[:i]
+		}
+
+		if _, ok := sizes[name]; !ok {
+			names = append(names, name)
+		}
+		size, _ := ParseSize(match[2])
+		sizes[name] += size
+	}
+
+	entries := make([]sourceEntry, len(names))
+	for i, name := range names {
+		entries[i] = sourceEntry{name: name, size: sizes[name]}
+	}
+	return entries
+}
+
+// partitionEntries distributes entries across at most n buckets of similar total size by assigning
+// the largest remaining entry to the smallest bucket. There is always at least one bucket
+func partitionEntries(entries []sourceEntry, n int) [][]sourceEntry {
+	if n > len(entries) {
+		n = len(entries)
+	}
+	if n < 1 {
+		return [][]sourceEntry{nil}
+	}
+
+	sorted := append([]sourceEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].size > sorted[j].size })
+
+	buckets := make([][]sourceEntry, n)
+	totals := make([]int64, n)
+	for _, entry := range sorted {
+		smallest := 0
+		for i := range totals {
+			if totals[i] < totals[smallest] {
+				smallest = i
+			}
+		}
+		buckets[smallest] = append(buckets[smallest], entry)
+		totals[smallest] += entry.size
+	}
+	return buckets
+}
+
+// escapePattern escapes the wildcard characters of rsync filter patterns in name
+func escapePattern(name string) string {
+	if !strings.ContainsAny(name, `*?[\`) {
+		return name
+	}
+	var b strings.Builder
+	for _, c := range name {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// combineStates sums the states of tasks transferring size bytes in total
+func combineStates(tasks []*Task, size int64) State {
+	var state State
+	for _, task := range tasks {
+		s := task.State()
+		state.BytesTransferred += s.BytesTransferred
+		state.BytesPerSecond += s.BytesPerSecond
+		state.RunBytesPerSecond += s.RunBytesPerSecond
+		state.FilesRemaining += s.FilesRemaining
+		state.FilesTotal += s.FilesTotal
+		state.FilesTransferred += s.FilesTransferred
+	}
+	if size > 0 {
+		state.BytesProgress = int(state.BytesTransferred * 100 / size)
+		if state.BytesProgress > 100 {
+			state.BytesProgress = 100
+		}
+	}
+	if state.FilesTotal > 0 {
+		state.FileProgress = (state.FilesTotal - state.FilesRemaining) * 100 / state.FilesTotal
+	}
+	state.Progress = state.BytesProgress
+	state.DownloadedTotal = formatSize(float64(state.BytesTransferred), 1000)
+	state.Speed = formatSpeed(state.BytesPerSecond, 1000)
+	state.AvgSpeed = state.Speed
+	state.RunSpeed = formatSpeed(state.RunBytesPerSecond, 1000)
+	if state.BytesPerSecond > 0 && size > state.BytesTransferred {
+		state.ETA = time.Duration(float64(size-state.BytesTransferred) / state.BytesPerSecond * float64(time.Second)).Round(time.Second)
+		state.ETAEstimated = true
+	}
+	return state
+}
+
+// cancelTasks cancels all tasks and returns the first error
+func cancelTasks(tasks []*Task) error {
+	var err error
+	for _, task := range tasks {
+		if cancelErr := task.Cancel(); err == nil {
+			err = cancelErr
+		}
+	}
+	return err
+}
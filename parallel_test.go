@@ -0,0 +1,112 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const parallelListing = `drwxr-xr-x          4,096 2023/01/02 10:11:12 .
+drwxr-xr-x          4,096 2023/01/02 10:11:12 big
+-rw-r--r--      9,000,000 2023/01/02 10:11:12 big/file
+drwxr-xr-x          4,096 2023/01/02 10:11:12 small
+-rw-r--r--      1,000,000 2023/01/02 10:11:12 small/a
+-rw-r--r--      2,000,000 2023/01/02 10:11:12 mid*
+lrwxrwxrwx             10 2023/01/02 10:11:12 link -> big/file`
+
+func TestParseListing(t *testing.T) {
+	assert.Equal(t, []sourceEntry{
+		{name: "big", size: 9004096},
+		{name: "small", size: 1004096},
+		{name: "mid*", size: 2000000},
+		{name: "link", size: 10},
+	}, parseListing([]byte(parallelListing)))
+}
+
+func TestPartitionEntries(t *testing.T) {
+	entries := []sourceEntry{{"a", 1}, {"b", 5}, {"c", 3}, {"d", 3}}
+	assert.Equal(t, [][]sourceEntry{{{"b", 5}}, {{"c", 3}, {"a", 1}}, {{"d", 3}}}, partitionEntries(entries, 3))
+	assert.Len(t, partitionEntries(entries, 10), 4)
+	assert.Equal(t, [][]sourceEntry{nil}, partitionEntries(nil, 4))
+}
+
+func TestEscapePattern(t *testing.T) {
+	assert.Equal(t, "plain name", escapePattern("plain name"))
+	assert.Equal(t, `a\*b\?c\[d\\e`, escapePattern(`a*b?c[d\e`))
+}
+
+func TestParallelTask(t *testing.T) {
+	runs := t.TempDir()
+	script := `case "$*" in
+*--list-only*) echo "` + parallelListing + `" ;;
+*) echo "$*" > "$(mktemp ` + runs + `/run.XXXXXX)"
+   echo "  5,500,000  50%    1.00MB/s    0:00:05" ;;
+esac`
+
+	options := RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Include: []string{"*/", "*.jpg"}, Exclude: []string{"*"}, Info: "progress2"}
+	task, err := NewParallelTask("src", "dst", 2, options)
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Len(t, task.Tasks(), 2)
+
+	files, err := filepath.Glob(filepath.Join(runs, "run.*"))
+	assert.Nil(t, err)
+	var arguments []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(string(content)), "src/ dst"), string(content))
+		// the entries of the other process are excluded before the filters of the options
+		var filters []string
+		for _, argument := range strings.Split(strings.TrimSuffix(strings.TrimSpace(string(content)), " src/ dst"), " --") {
+			if strings.HasPrefix(argument, "filter=") || strings.HasPrefix(argument, "include=") || strings.HasPrefix(argument, "exclude=") {
+				filters = append(filters, argument)
+			}
+		}
+		arguments = append(arguments, strings.Join(filters, " "))
+	}
+	sort.Strings(arguments)
+	assert.Equal(t, []string{
+		`filter=- /big include=*/ include=*.jpg exclude=*`,
+		`filter=- /mid\* filter=- /small filter=- /link include=*/ include=*.jpg exclude=*`,
+	}, arguments)
+
+	state := task.State()
+	assert.Equal(t, int64(11000000), state.BytesTransferred)
+	assert.Equal(t, 91, state.Progress)
+	assert.Equal(t, 2e6, state.BytesPerSecond)
+}
+
+func TestListCommand(t *testing.T) {
+	ignore := filepath.Join(t.TempDir(), ".gitignore")
+	assert.Nil(t, os.WriteFile(ignore, []byte("*.log\n"), 0644))
+
+	cmd, err := listCommand("host:/src/", RsyncOptions{
+		RemoteSudo:  true,
+		Include:     []string{"*/"},
+		IncludeFrom: "include.txt",
+		ExcludeFrom: "exclude.txt",
+		IgnoreFiles: []string{ignore},
+		Archive:     true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"rsync", "--rsync-path", "sudo -n rsync", "--recursive", "--include=*/", "--include-from=include.txt",
+		"--exclude-from=exclude.txt", "--filter=- *.log", "--list-only", "host:/src/"}, cmd.Args)
+}
+
+func TestParallelTaskErrors(t *testing.T) {
+	_, err := NewParallelTask("src", "dst", 2, RsyncOptions{DeleteExcluded: true})
+	assert.Equal(t, ErrParallelDeleteExcluded, err)
+	_, err = NewParallelTask("src", "dst", 0, RsyncOptions{})
+	assert.NotNil(t, err)
+
+	task, err := NewParallelTask("src", "dst", 2, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "no such directory" >&2; exit 23`)})
+	assert.Nil(t, err)
+	err = task.Run()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no such directory")
+}
@@ -0,0 +1,64 @@
+package grsync
+
+import (
+	"regexp"
+	"time"
+)
+
+// LineParser extracts additional information from the stdout lines of rsync. Values passed to set
+// are stored in State.Custom under their key. ParseLine is called while the task state is locked,
+// so it must not call methods of the task
+type LineParser interface {
+	ParseLine(line string, set func(key, value string))
+}
+
+// LineParserFunc adapts a function to the LineParser interface
+type LineParserFunc func(line string, set func(key, value string))
+
+// ParseLine calls f(line, set)
+func (f LineParserFunc) ParseLine(line string, set func(key, value string)) {
+	f(line, set)
+}
+
+// NewRegexpParser returns a parser storing the first submatch of expr (or the whole match,
+// if expr has no groups) under key whenever a line matches
+func NewRegexpParser(key, expr string) (LineParser, error) {
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	m := matcher{regExp: r}
+	return LineParserFunc(func(line string, set func(key, value string)) {
+		if !m.Match(line) {
+			return
+		}
+		if r.NumSubexp() == 0 {
+			set(key, r.FindString(line))
+			return
+		}
+		set(key, m.Extract(line))
+	}), nil
+}
+
+// AddParser registers a parser that is fed every stdout line in addition to the built-in matchers
+func (t *Task) AddParser(parser LineParser) {
+	t.mutex.Lock()
+	t.parsers = append(t.parsers, parser)
+	t.mutex.Unlock()
+}
+
+// runParsers feeds line to the registered parsers, t.mutex must be held
+func (t *Task) runParsers(line string) {
+	for _, parser := range t.parsers {
+		parser.ParseLine(line, t.setCustom)
+	}
+}
+
+func (t *Task) setCustom(key, value string) {
+	if t.state.Custom == nil {
+		t.state.Custom = make(map[string]string)
+	}
+	t.state.Custom[key] = value
+	t.lastStateChange = time.Now()
+}
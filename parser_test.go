@@ -0,0 +1,51 @@
+package grsync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexpParser(t *testing.T) {
+	_, err := NewRegexpParser("x", "(")
+	assert.NotNil(t, err)
+
+	values := map[string]string{}
+	set := func(key, value string) { values[key] = value }
+
+	p, err := NewRegexpParser("files", `Number of files: ([\d,]+)`)
+	assert.Nil(t, err)
+	p.ParseLine("Number of files: 1,234 (reg: 1,000, dir: 234)", set)
+	p.ParseLine("unrelated", set)
+	assert.Equal(t, map[string]string{"files": "1,234"}, values)
+
+	p, err = NewRegexpParser("speedup", `speedup is [\d.]+`)
+	assert.Nil(t, err)
+	p.ParseLine("total size is 100  speedup is 1.50", set)
+	assert.Equal(t, "speedup is 1.50", values["speedup"])
+}
+
+func TestTaskParsers(t *testing.T) {
+	script := `echo "sent 100 bytes  received 35 bytes  270.00 bytes/sec"
+echo "total size is 1,024  speedup is 7.59"`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+
+	p, err := NewRegexpParser("speedup", `speedup is ([\d.]+)`)
+	assert.Nil(t, err)
+	task.AddParser(p)
+	task.AddParser(LineParserFunc(func(line string, set func(key, value string)) {
+		if strings.HasPrefix(line, "sent ") {
+			set("sent", strings.Fields(line)[1])
+		}
+	}))
+
+	assert.Nil(t, task.Run())
+	state := task.State()
+	assert.Equal(t, map[string]string{"speedup": "7.59", "sent": "100"}, state.Custom)
+
+	// the returned state is a copy
+	state.Custom["sent"] = "0"
+	assert.Equal(t, "100", task.State().Custom["sent"])
+}
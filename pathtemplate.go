@@ -0,0 +1,89 @@
+package grsync
+
+import (
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PathVars are the variables of templated source and destination paths, which contain text/template
+// actions like "/backups/{{.Date}}/". They are expanded whenever a run starts, so all attempts
+// of a run use the same paths
+type PathVars struct {
+	// Date is the start of the run formatted as 2006-01-02 and Time as 150405, both local time
+	Date string
+	Time string
+	// Now is the start of the run, e.g. for {{.Now.Format "2006/01"}}
+	Now      time.Time
+	Hostname string
+	TaskID   string
+	// Labels are the labels of the task, see SetLabels
+	Labels map[string]string
+	// Vars are the variables set with SetPathVars, e.g. {{.Vars.tenant}}
+	Vars map[string]string
+}
+
+// SetPathVars sets the custom variables of templated paths, see PathVars
+func (t *Task) SetPathVars(vars map[string]string) {
+	t.mutex.Lock()
+	t.pathVars = cloneLabels(vars)
+	t.mutex.Unlock()
+}
+
+// Paths returns the source and destination of the current or last run with their templates
+// expanded, see PathVars
+func (t *Task) Paths() (source, destination string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.source, t.destination
+}
+
+// expandPaths expands the templates of the source and destination of the definition for the
+// current run. The mutex must be held
+func (t *Task) expandPaths() (source, destination string, err error) {
+	now := t.startedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+	hostname, _ := os.Hostname()
+	vars := PathVars{
+		Date:     now.Format("2006-01-02"),
+		Time:     now.Format("150405"),
+		Now:      now,
+		Hostname: hostname,
+		TaskID:   t.id,
+		Labels:   t.definition.Labels,
+		Vars:     t.pathVars,
+	}
+
+	// NewTask only checks the syntax, the variables may be set after it
+	strict := !t.startedAt.IsZero()
+	if source, err = expandPath(t.definition.Source, vars, strict); err != nil {
+		return "", "", err
+	}
+	if destination, err = expandPath(t.definition.Destination, vars, strict); err != nil {
+		return "", "", err
+	}
+	return source, destination, nil
+}
+
+// expandPath executes path as a template if it contains an action. Missing variables are errors
+// rather than empty path components, unless strict is false, which returns path unexpanded then
+func expandPath(path string, vars PathVars, strict bool) (string, error) {
+	if !strings.Contains(path, "{{") {
+		return path, nil
+	}
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(path)
+	if err != nil {
+		return "", err
+	}
+	var expanded strings.Builder
+	if err = tmpl.Execute(&expanded, vars); err != nil {
+		if !strict {
+			return path, nil
+		}
+		return "", err
+	}
+	return expanded.String(), nil
+}
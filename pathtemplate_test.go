@@ -0,0 +1,61 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandPath(t *testing.T) {
+	vars := PathVars{
+		Date:     "2024-03-01",
+		Time:     "023000",
+		Now:      time.Date(2024, 3, 1, 2, 30, 0, 0, time.Local),
+		Hostname: "web1",
+		TaskID:   "nightly",
+		Vars:     map[string]string{"tenant": "acme"},
+	}
+
+	for path, expected := range map[string]string{
+		"/data/":              "/data/",
+		"/backups/{{.Date}}/": "/backups/2024-03-01/",
+		"host:/b/{{.Hostname}}/{{.Date}}T{{.Time}}": "host:/b/web1/2024-03-01T023000",
+		`/b/{{.Now.Format "2006/01"}}/`:             "/b/2024/03/",
+		"/b/{{.Vars.tenant}}/{{.TaskID}}":           "/b/acme/nightly",
+	} {
+		expanded, err := expandPath(path, vars, true)
+		assert.Nil(t, err, path)
+		assert.Equal(t, expected, expanded, path)
+	}
+
+	_, err := expandPath("/b/{{.Vars.missing}}", vars, true)
+	assert.NotNil(t, err)
+	expanded, err := expandPath("/b/{{.Vars.missing}}", vars, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "/b/{{.Vars.missing}}", expanded)
+	_, err = expandPath("/b/{{.Date", vars, false)
+	assert.NotNil(t, err)
+}
+
+func TestTemplatedPaths(t *testing.T) {
+	task, err := NewTask("/data/", "/backups/{{.Vars.tenant}}/{{.Date}}/", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	runner := &cannedRunner{}
+	task.SetRunner(runner)
+	task.SetPathVars(map[string]string{"tenant": "acme"})
+
+	assert.Nil(t, task.Run())
+	source, destination := task.Paths()
+	assert.Equal(t, "/data/", source)
+	assert.Equal(t, "/backups/acme/"+time.Now().Format("2006-01-02")+"/", destination)
+	assert.Equal(t, destination, runner.args[len(runner.args)-1])
+
+	_, err = NewTask("/data/", "/backups/{{.Date", false, false, RsyncOptions{})
+	assert.NotNil(t, err)
+
+	task, err = NewTask("/data/", "/backups/{{.Vars.tenant}}/", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	task.SetRunner(&cannedRunner{})
+	assert.NotNil(t, task.Run())
+}
@@ -0,0 +1,154 @@
+package grsync
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pipelineCorpus is a captured-like output of rsync replayed through processStdout. The corpora
+// are generated deterministically so results compare across runs and machines
+type pipelineCorpus struct {
+	name   string
+	output string
+	// lines is the number of lines the pipeline processes, counting carriage returns
+	lines int
+	// events makes the task parse file events
+	events bool
+}
+
+// verboseCorpus is the output of `rsync -av --delete` over files in nested directories,
+// ending with the summary
+func verboseCorpus(files int) pipelineCorpus {
+	var b strings.Builder
+	b.WriteString("sending incremental file list\n")
+	lines := 1
+	for i := 0; i < files; i++ {
+		if i%50 == 0 {
+			fmt.Fprintf(&b, "photos/%04d/\n", i/50)
+			lines++
+		}
+		if i%97 == 0 {
+			fmt.Fprintf(&b, "deleting photos/%04d/stale-%d.tmp\n", i/50, i)
+		} else {
+			fmt.Fprintf(&b, "photos/%04d/IMG_%06d.jpg\n", i/50, i)
+		}
+		lines++
+	}
+	b.WriteString("\nsent 1,234,567,890 bytes  received 123,456 bytes  12,345,678.90 bytes/sec\n")
+	b.WriteString("total size is 9,876,543,210  speedup is 8.00\n")
+	return pipelineCorpus{name: "verbose", output: b.String(), lines: lines + 3}
+}
+
+// progress2Corpus is the output of --info=progress2: one line rewritten with carriage returns,
+// first while the file list is built incrementally
+func progress2Corpus(updates int) pipelineCorpus {
+	var b strings.Builder
+	for i := 0; i < updates; i++ {
+		check := "ir-chk"
+		if i > updates/2 {
+			check = "to-chk"
+		}
+		fmt.Fprintf(&b, "  %d,%03d,%03d  %2d%%   %d.%02dMB/s    0:%02d:%02d (xfr#%d, %s=%d/%d)\r",
+			i/1000, i%1000, i%997, i*100/updates, 10+i%90, i%100, i%60, i%60, i, check, updates-i, updates)
+	}
+	b.WriteString("\n")
+	return pipelineCorpus{name: "progress2", output: b.String(), lines: updates + 1}
+}
+
+// mixedCorpus is the per-file --progress of rsync: the name of every file followed by its
+// progress, updated with carriage returns and finished with a newline
+func mixedCorpus(files int) pipelineCorpus {
+	var b strings.Builder
+	lines := 0
+	for i := 0; i < files; i++ {
+		fmt.Fprintf(&b, "data/chunk-%06d.bin\n", i)
+		b.WriteString("         32,768   0%    0.00kB/s    0:00:00\r")
+		b.WriteString("      5,242,880  50%   50.00MB/s    0:00:01\r")
+		fmt.Fprintf(&b, "     10,485,760 100%%   50.00MB/s    0:00:00 (xfr#%d, to-chk=%d/%d)\n", i+1, files-i-1, files)
+		lines += 4
+	}
+	return pipelineCorpus{name: "mixed", output: b.String(), lines: lines}
+}
+
+// eventsCorpus is the output of a task listening for file events, see fileEventFormat
+func eventsCorpus(files int) pipelineCorpus {
+	var b strings.Builder
+	for i := 0; i < files; i++ {
+		fmt.Fprintf(&b, "%s>f+++++++++ %d src/pkg%03d/file%d.go\n", fileEventPrefix, 1000+i, i%100, i)
+	}
+	return pipelineCorpus{name: "events", output: b.String(), lines: files, events: true}
+}
+
+func pipelineCorpora(size int) []pipelineCorpus {
+	return []pipelineCorpus{verboseCorpus(size), progress2Corpus(size), mixedCorpus(size / 4), eventsCorpus(size)}
+}
+
+// replay runs corpus through processStdout of a new task and returns the task
+func (c pipelineCorpus) replay(tb testing.TB) *Task {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if c.events {
+		task.OnFileEvent(func(FileEvent) {})
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	processStdout(&wg, task, strings.NewReader(c.output))
+	return task
+}
+
+// BenchmarkPipeline replays the corpora through the stdout pipeline and reports lines/s besides
+// the usual metrics, e.g. to compare parser changes with benchstat
+func BenchmarkPipeline(b *testing.B) {
+	for _, corpus := range pipelineCorpora(100000) {
+		b.Run(corpus.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(corpus.output)))
+			for i := 0; i < b.N; i++ {
+				corpus.replay(b)
+			}
+			b.ReportMetric(float64(corpus.lines)*float64(b.N)/b.Elapsed().Seconds(), "lines/s")
+		})
+	}
+}
+
+func TestPipelineCorpora(t *testing.T) {
+	task := verboseCorpus(1000).replay(t)
+	assert.Equal(t, int64(1234567890), task.Summary().BytesSent)
+	assert.Len(t, task.Deleted(), 11)
+
+	task = progress2Corpus(1000).replay(t)
+	state := task.State()
+	assert.Equal(t, 999, state.FilesTransferred)
+	assert.False(t, state.Scanning)
+
+	task = mixedCorpus(100).replay(t)
+	assert.Equal(t, 100, task.State().Progress)
+
+	var events int
+	task, err := NewTask("a", "b", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	task.OnFileEvent(func(FileEvent) { events++ })
+	var wg sync.WaitGroup
+	wg.Add(1)
+	processStdout(&wg, task, strings.NewReader(eventsCorpus(1000).output))
+	assert.Equal(t, 1000, events)
+}
+
+// TestPipelineAllocations gates the allocations per line, which grow if the pipeline starts to
+// copy lines or the accumulated log. The limits are the current allocations with some headroom,
+// lower them when the pipeline improves: the names of transferred files, the changing progress
+// fields and the events are allocated once per line
+func TestPipelineAllocations(t *testing.T) {
+	limits := map[string]float64{"verbose": 1.25, "progress2": 5, "mixed": 6, "events": 4}
+	for _, corpus := range pipelineCorpora(5000) {
+		allocs := testing.AllocsPerRun(3, func() { corpus.replay(t) })
+		perLine := allocs / float64(corpus.lines)
+		assert.LessOrEqual(t, perLine, limits[corpus.name], "%s: %.2f allocations per line", corpus.name, perLine)
+	}
+}
@@ -0,0 +1,144 @@
+package grsync
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPollInterval is the interval a polling Watcher scans its source in without
+// Polling.Interval
+const DefaultPollInterval = 10 * time.Second
+
+// Polling makes a Watcher scan its source for changes instead of relying on file system events,
+// which don't fire for changes made by other clients of network file systems like NFS or SMB.
+// Changes found by a scan are debounced like events
+type Polling struct {
+	// Interval is the time between the scans, DefaultPollInterval if zero
+	Interval time.Duration
+	// Hash detects changes by the contents of the files in addition to their size and
+	// modification time, for file systems with coarse or unreliable timestamps. Every scan reads
+	// all files then
+	Hash bool
+}
+
+// fileSignature is what a scan compares of a file
+type fileSignature struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+	hash    [sha256.Size]byte
+}
+
+// SetPolling makes the watcher poll its source, see Polling. It must be called before Start
+func (w *Watcher) SetPolling(polling Polling) {
+	if polling.Interval <= 0 {
+		polling.Interval = DefaultPollInterval
+	}
+	w.mutex.Lock()
+	w.polling = &polling
+	w.mutex.Unlock()
+}
+
+// startPolling takes the first snapshot of the source and scans it in the background
+func (w *Watcher) startPolling(root string, polling Polling) error {
+	snapshot, err := scanSource(root, polling.Hash)
+	if err != nil {
+		return err
+	}
+	w.wg.Add(1)
+	go w.poll(root, polling, snapshot)
+	return nil
+}
+
+// poll scans root every interval and notifies the changes since the previous scan. A failed scan,
+// e.g. while a network file system is unreachable, is skipped instead of reporting all files as
+// deleted
+func (w *Watcher) poll(root string, polling Polling, snapshot map[string]fileSignature) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(polling.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := scanSource(root, polling.Hash)
+		if err != nil {
+			continue
+		}
+		for path, signature := range current {
+			if previous, ok := snapshot[path]; !ok || previous != signature {
+				w.notify(path)
+			}
+		}
+		for path := range snapshot {
+			if _, ok := current[path]; !ok {
+				w.notify(path)
+			}
+		}
+		snapshot = current
+	}
+}
+
+// scanSource returns the signatures of root and the files below it
+func scanSource(root string, hash bool) (map[string]fileSignature, error) {
+	snapshot := make(map[string]fileSignature)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil {
+			var info fs.FileInfo
+			if info, err = entry.Info(); err == nil {
+				err = addSignature(snapshot, path, info, hash)
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		// files deleted while scanning show up as deleted by the next scan
+		if errors.Is(err, fs.ErrNotExist) && path != root {
+			return nil
+		}
+		return err
+	})
+	return snapshot, err
+}
+
+// addSignature adds the signature of the file at path to snapshot
+func addSignature(snapshot map[string]fileSignature, path string, info fs.FileInfo, hash bool) error {
+	signature := fileSignature{mode: info.Mode()}
+	// the times and sizes of directories change with their entries, which are compared anyway
+	if !info.IsDir() {
+		signature.size, signature.modTime = info.Size(), info.ModTime()
+	}
+	if hash && info.Mode().IsRegular() {
+		var err error
+		if signature.hash, err = hashFile(path); err != nil {
+			return err
+		}
+	}
+	snapshot[path] = signature
+	return nil
+}
+
+// hashFile returns the SHA-256 of the contents of path
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
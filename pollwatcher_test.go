@@ -0,0 +1,60 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollingWatcher(t *testing.T) {
+	source := t.TempDir()
+	w, err := NewWatcher(Definition{
+		Source:      source + "/",
+		Destination: t.TempDir(),
+		Options:     RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")},
+	}, 20*time.Millisecond)
+	assert.Nil(t, err)
+	w.SetPolling(Polling{Interval: 20 * time.Millisecond})
+	assert.Nil(t, w.Start())
+	defer w.Stop()
+	assert.Nil(t, w.fsWatcher)
+
+	waitForWatcher(t, w, 1)
+	assert.Nil(t, os.MkdirAll(filepath.Join(source, "sub"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(source, "sub", "a"), []byte("a"), 0644))
+	waitForWatcher(t, w, 2)
+	assert.Nil(t, os.Remove(filepath.Join(source, "sub", "a")))
+	status := waitForWatcher(t, w, 3)
+	assert.GreaterOrEqual(t, status.Changes, 3)
+}
+
+func TestScanSource(t *testing.T) {
+	source := t.TempDir()
+	path := filepath.Join(source, "a")
+	assert.Nil(t, os.WriteFile(path, []byte("aaaa"), 0644))
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+
+	before, err := scanSource(source, false)
+	assert.Nil(t, err)
+	assert.Len(t, before, 2)
+	hashedBefore, err := scanSource(source, true)
+	assert.Nil(t, err)
+
+	// same size and modification time, like after a change on a file system with coarse timestamps
+	assert.Nil(t, os.WriteFile(path, []byte("bbbb"), 0644))
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+	after, err := scanSource(source, false)
+	assert.Nil(t, err)
+	assert.Equal(t, before, after)
+	hashedAfter, err := scanSource(source, true)
+	assert.Nil(t, err)
+	assert.NotEqual(t, hashedBefore[path], hashedAfter[path])
+	assert.Equal(t, hashedBefore[source], hashedAfter[source])
+
+	_, err = scanSource(filepath.Join(source, "missing"), false)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,60 @@
+package grsync
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+)
+
+// ErrPriorityUnsupported is returned for a Priority the platform can't apply: I/O classes and
+// SCHED_IDLE are only supported on Linux and nice isn't available on Windows
+var ErrPriorityUnsupported = errors.New("process priority is not supported on this platform")
+
+// IOClass is an I/O scheduling class as set by ionice
+type IOClass int
+
+const (
+	// IOClassNone keeps the I/O class of the current process
+	IOClassNone IOClass = 0
+	// IOClassBestEffort schedules I/O by Priority.IOLevel
+	IOClassBestEffort IOClass = 2
+	// IOClassIdle only gets disk time when no other process needs it
+	IOClassIdle IOClass = 3
+)
+
+// Priority lowers the CPU and I/O priority of the rsync process, so background transfers don't
+// slow down interactive workloads. The zero value keeps the priority of the current process. It is
+// applied by running rsync through nice, ionice and chrt, which must be on the PATH
+type Priority struct {
+	// Nice is the niceness added by nice, 1 to 19
+	Nice int
+	// IOClass is the I/O scheduling class set by ionice
+	IOClass IOClass
+	// IOLevel is the level within IOClassBestEffort, from 0 (highest) to 7 (lowest)
+	IOLevel int
+	// Idle runs rsync with the SCHED_IDLE CPU scheduling policy through chrt
+	Idle bool
+}
+
+// wrapPriority returns the command running name with args at priority p
+func wrapPriority(p Priority, name string, args []string) (string, []string, error) {
+	if (p.IOClass != IOClassNone || p.Idle) && runtime.GOOS != "linux" || p.Nice != 0 && runtime.GOOS == "windows" {
+		return "", nil, ErrPriorityUnsupported
+	}
+
+	command := append([]string{name}, args...)
+	if p.Nice != 0 {
+		command = append([]string{"nice", "-n", strconv.Itoa(p.Nice)}, command...)
+	}
+	if p.IOClass != IOClassNone {
+		ionice := []string{"ionice", "-c", strconv.Itoa(int(p.IOClass))}
+		if p.IOClass == IOClassBestEffort {
+			ionice = append(ionice, "-n", strconv.Itoa(p.IOLevel))
+		}
+		command = append(ionice, command...)
+	}
+	if p.Idle {
+		command = append([]string{"chrt", "--idle", "0"}, command...)
+	}
+	return command[0], command[1:], nil
+}
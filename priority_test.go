@@ -0,0 +1,43 @@
+package grsync
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapPriority(t *testing.T) {
+	name, args, err := wrapPriority(Priority{}, "rsync", []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, "rsync", name)
+	assert.Equal(t, []string{"a", "b"}, args)
+
+	if runtime.GOOS != "linux" {
+		_, _, err = wrapPriority(Priority{IOClass: IOClassIdle}, "rsync", nil)
+		assert.Equal(t, ErrPriorityUnsupported, err)
+		return
+	}
+
+	name, args, err = wrapPriority(Priority{Nice: 19, IOClass: IOClassBestEffort, IOLevel: 7, Idle: true}, "rsync", []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, "chrt", name)
+	assert.Equal(t, []string{"--idle", "0", "ionice", "-c", "2", "-n", "7", "nice", "-n", "19", "rsync", "a", "b"}, args)
+
+	name, args, err = wrapPriority(Priority{IOClass: IOClassIdle}, "rsync", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "ionice", name)
+	assert.Equal(t, []string{"-c", "3", "rsync"}, args)
+}
+
+func TestTaskPriority(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("nice is not available")
+	}
+	runner := &cannedRunner{}
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{Priority: Priority{Nice: 10}})
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, []string{"nice", "-n", "10", "rsync"}, runner.args[:4])
+}
@@ -0,0 +1,98 @@
+package grsync
+
+import (
+	"os/exec"
+	"time"
+)
+
+// ProcessInfo describes a started rsync process, e.g. for supervisors monitoring its resources or
+// adjusting its oom score
+type ProcessInfo struct {
+	// PID is 0 for processes of a CommandRunner without process IDs
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"startedAt"`
+	Attempt   int       `json:"attempt"`
+	// Path and Args are the command line, which wraps rsync in sshpass, nice, script or prlimit
+	// depending on the options. Secrets are redacted, see Rsync.Command
+	Path string   `json:"path"`
+	Args []string `json:"args"`
+}
+
+// pider is implemented by processes with a process ID
+type pider interface {
+	Pid() int
+}
+
+// Pid returns the process ID of the started rsync command, 0 before Start or if its CommandRunner
+// doesn't provide one
+func (r Rsync) Pid() int {
+	if p, ok := r.process.(pider); ok {
+		return p.Pid()
+	}
+	return 0
+}
+
+// Cmd returns the underlying command of processes created by ExecRunner, nil for other runners.
+// It is meant to be inspected, e.g. its ProcessState once the command exited, not modified
+func (r Rsync) Cmd() *exec.Cmd {
+	if p, ok := r.process.(*execProcess); ok {
+		return p.Cmd
+	}
+	return nil
+}
+
+// Pid returns the process ID of the started process, 0 before Start
+func (p *execProcess) Pid() int {
+	if p.Process == nil {
+		return 0
+	}
+	return p.Process.Pid
+}
+
+// Process returns the rsync process of the current attempt. ok is false while none runs
+func (t *Task) Process() (info ProcessInfo, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.process == nil {
+		return ProcessInfo{}, false
+	}
+	info = *t.process
+	info.Args = append([]string(nil), info.Args...)
+	return info, true
+}
+
+// OnProcessStart registers a callback invoked whenever a rsync process started, before its output
+// is processed further
+func (t *Task) OnProcessStart(callback func(ProcessInfo)) {
+	t.mutex.Lock()
+	t.processCallbacks = append(t.processCallbacks, callback)
+	t.mutex.Unlock()
+}
+
+// startProcess records the started process of the attempt and calls the callbacks
+func (t *Task) startProcess(attempt int, startedAt time.Time) {
+	t.mutex.Lock()
+	info := ProcessInfo{
+		PID:       t.rsync.Pid(),
+		StartedAt: startedAt,
+		Attempt:   attempt,
+		Path:      t.rsync.command.Name,
+		Args:      t.rsync.Command()[1:],
+	}
+	t.process = &info
+	callbacks := t.processCallbacks
+	t.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		info := info
+		info.Args = append([]string(nil), info.Args...)
+		callback(info)
+	}
+}
+
+// stopProcess forgets the exited process of the attempt
+func (t *Task) stopProcess() {
+	t.mutex.Lock()
+	t.process = nil
+	t.mutex.Unlock()
+}
@@ -0,0 +1,61 @@
+package grsync
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcess(t *testing.T) {
+	t.Run("running process", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo $$; exec sleep 10")})
+		assert.Nil(t, err)
+		_, ok := task.Process()
+		assert.False(t, ok)
+
+		started := make(chan ProcessInfo, 1)
+		task.OnProcessStart(func(info ProcessInfo) { started <- info })
+		done := make(chan error)
+		go func() { done <- task.Run() }()
+
+		info := <-started
+		assert.NotZero(t, info.PID)
+		assert.Equal(t, 1, info.Attempt)
+		assert.False(t, info.StartedAt.IsZero())
+		assert.True(t, strings.HasSuffix(info.Path, "rsync"))
+		assert.Contains(t, info.Args, "b")
+
+		current, ok := task.Process()
+		assert.True(t, ok)
+		assert.Equal(t, info, current)
+		assert.Eventually(t, func() bool {
+			return strings.TrimSpace(task.Log().Stdout) == strconv.Itoa(info.PID)
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Nil(t, task.Cancel())
+		<-done
+		_, ok = task.Process()
+		assert.False(t, ok)
+	})
+
+	t.Run("exec command", func(t *testing.T) {
+		rsync, err := NewRsync("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+		assert.Nil(t, err)
+		assert.Zero(t, rsync.Pid())
+		assert.NotNil(t, rsync.Cmd())
+
+		assert.Nil(t, rsync.Run())
+		assert.NotZero(t, rsync.Pid())
+		assert.True(t, rsync.Cmd().ProcessState.Success())
+	})
+
+	t.Run("runner without PIDs", func(t *testing.T) {
+		rsync, err := newRsync("a", "b", false, false, RsyncOptions{}, nil, &cannedRunner{})
+		assert.Nil(t, err)
+		assert.Zero(t, rsync.Pid())
+		assert.Nil(t, rsync.Cmd())
+	})
+}
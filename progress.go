@@ -0,0 +1,64 @@
+package grsync
+
+import "bytes"
+
+// ProgressBasis selects what State.Progress measures
+type ProgressBasis int
+
+const (
+	// ProgressBytes makes Progress the percentage printed by rsync, which is the completion of the
+	// transferred bytes with --info=progress2. This is the default
+	ProgressBytes ProgressBasis = iota
+	// ProgressFiles makes Progress the percentage of files checked according to to-chk or ir-chk.
+	// With incremental recursion (ir-chk) the total grows while rsync scans the source
+	ProgressFiles
+)
+
+// SetProgressBasis selects what State.Progress measures. BytesProgress and FileProgress are
+// always set regardless of the basis
+func (t *Task) SetProgressBasis(basis ProgressBasis) {
+	t.mutex.Lock()
+	t.progressBasis = basis
+	t.mutex.Unlock()
+}
+
+// updateFileProgress sets the file counts from the "rem/total" part of a to-chk or ir-chk field,
+// the mutex must be held
+func (t *Task) updateFileProgress(remTotal []byte) {
+	remain, total, ok := parseCheck(remTotal)
+	if !ok || total <= 0 || remain > total {
+		return
+	}
+	t.state.FilesRemaining = remain
+	t.state.FilesTotal = total
+	t.state.FileProgress = (total - remain) * 100 / total
+}
+
+// updateProgress sets Progress according to the progress basis, the mutex must be held
+func (t *Task) updateProgress() {
+	if t.progressBasis == ProgressFiles {
+		t.state.Progress = t.state.FileProgress
+	} else {
+		t.state.Progress = t.state.BytesProgress
+	}
+}
+
+// parseCheck parses "rem/total" without allocating
+func parseCheck(s []byte) (remain, total int, ok bool) {
+	slash := bytes.IndexByte(s, '/')
+	if slash <= 0 || slash == len(s)-1 {
+		return 0, 0, false
+	}
+	for i, c := range s {
+		switch {
+		case i == slash:
+		case !isDigit(c):
+			return 0, 0, false
+		case i < slash:
+			remain = remain*10 + int(c-'0')
+		default:
+			total = total*10 + int(c-'0')
+		}
+	}
+	return remain, total, true
+}
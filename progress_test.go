@@ -0,0 +1,46 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBasis(t *testing.T) {
+	script := `echo "     15.17G   80%   92.23MB/s    0:23:54 (xfr#5, to-chk=75/100)"`
+	run := func(basis ProgressBasis) State {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetProgressBasis(basis)
+		assert.Nil(t, task.Run())
+		return task.State()
+	}
+
+	state := run(ProgressBytes)
+	assert.Equal(t, 80, state.Progress)
+	assert.Equal(t, 80, state.BytesProgress)
+	assert.Equal(t, 25, state.FileProgress)
+	assert.Equal(t, 75, state.FilesRemaining)
+	assert.Equal(t, 100, state.FilesTotal)
+
+	state = run(ProgressFiles)
+	assert.Equal(t, 25, state.Progress)
+	assert.Equal(t, 80, state.BytesProgress)
+}
+
+func TestFileProgressIncrementalRecursion(t *testing.T) {
+	script := `echo "      1.00M   10%    1.00MB/s    0:00:09 (xfr#1, ir-chk=9/10)"
+echo "      2.00M   20%    1.00MB/s    0:00:08 (xfr#2, ir-chk=10/20)"
+echo "      2.00M   20%    1.00MB/s    0:00:08"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetProgressBasis(ProgressFiles)
+	assert.Nil(t, task.Run())
+
+	// lines without a check field keep the last file counts
+	state := task.State()
+	assert.Equal(t, 50, state.Progress)
+	assert.Equal(t, 10, state.FilesRemaining)
+	assert.Equal(t, 20, state.FilesTotal)
+}
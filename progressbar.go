@@ -0,0 +1,122 @@
+package grsync
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultProgressInterval is the interval in which a ProgressSource polls the state of its task
+const DefaultProgressInterval = 100 * time.Millisecond
+
+// ProgressSource adapts the byte progress of a task to progress-bar libraries: Progress and
+// Watch report the transferred and total bytes for bars like mpb or cheggaaa/pb, Fraction the
+// percentage for bubbles/progress and Read makes the task an io.Reader for proxy readers and
+// writers like progressbar.NewOptions64. The total is estimated from the percentage rsync prints,
+// which covers the whole transfer with RsyncOptions.Info set to "progress2"
+type ProgressSource struct {
+	task     *Task
+	interval time.Duration
+	done     <-chan struct{}
+
+	mutex sync.Mutex
+	read  int64
+}
+
+// ProgressSource returns a source reporting the current or next run of the task, polling its
+// state every interval, by default DefaultProgressInterval
+func (t *Task) ProgressSource(interval time.Duration) *ProgressSource {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	return &ProgressSource{task: t, interval: interval, done: t.Done()}
+}
+
+// Progress returns the bytes transferred by the current attempt and the estimated total, which is
+// 0 until rsync reported a percentage. Once the run succeeded, the total is the transferred bytes
+func (s *ProgressSource) Progress() (current, total int64) {
+	state := s.task.State()
+	current = state.BytesTransferred
+	if state.BytesProgress > 0 {
+		total = current * 100 / int64(state.BytesProgress)
+	}
+	if s.finished() && s.task.Status() == TaskSucceeded {
+		total = current
+	}
+	return current, total
+}
+
+// Fraction returns the progress between 0 and 1
+func (s *ProgressSource) Fraction() float64 {
+	current, total := s.Progress()
+	if total <= 0 {
+		return 0
+	}
+	return float64(current) / float64(total)
+}
+
+// Watch calls report whenever the progress changed until the run finished or ctx is done
+func (s *ProgressSource) Watch(ctx context.Context, report func(current, total int64)) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var lastCurrent, lastTotal int64 = -1, -1
+	update := func() {
+		if current, total := s.Progress(); current != lastCurrent || total != lastTotal {
+			lastCurrent, lastTotal = current, total
+			report(current, total)
+		}
+	}
+	for {
+		update()
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			update()
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Read blocks until more bytes were transferred and fills p with as many zero bytes, so copying
+// the source into a progress bar advances it like the transfer. Bytes transferred again by a
+// retry aren't counted twice. It returns io.EOF once the run finished
+func (s *ProgressSource) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		finished := s.finished()
+		if current, _ := s.Progress(); current > s.read {
+			n := int(min(current-s.read, int64(len(p))))
+			clear(p[:n])
+			s.read += int64(n)
+			return n, nil
+		}
+		if finished {
+			return 0, io.EOF
+		}
+		select {
+		case <-ticker.C:
+		case <-s.done:
+		}
+	}
+}
+
+// finished reports whether the run finished
+func (s *ProgressSource) finished() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
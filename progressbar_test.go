@@ -0,0 +1,45 @@
+package grsync
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const progress2Script = `printf '      1,000,000  25%%    1.00MB/s    0:00:03 (xfr#1, to-chk=3/4)\r'
+sleep 0.2
+printf '      4,000,000 100%%    1.00MB/s    0:00:00 (xfr#4, to-chk=0/4)\n'`
+
+func TestProgressSourceWatch(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, progress2Script), Info: "progress2"})
+	assert.Nil(t, err)
+	source := task.ProgressSource(10 * time.Millisecond)
+
+	var reports [][2]int64
+	go func() { _ = task.Run() }()
+	source.Watch(context.Background(), func(current, total int64) {
+		reports = append(reports, [2]int64{current, total})
+	})
+
+	assert.Contains(t, reports, [2]int64{1000000, 4000000})
+	assert.Equal(t, [2]int64{4000000, 4000000}, reports[len(reports)-1])
+	assert.Equal(t, 1.0, source.Fraction())
+}
+
+func TestProgressSourceRead(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, progress2Script), Info: "progress2"})
+	assert.Nil(t, err)
+	source := task.ProgressSource(0)
+
+	go func() { _ = task.Run() }()
+	n, err := io.Copy(io.Discard, source)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4000000), n)
+
+	n, err = io.Copy(io.Discard, source)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
@@ -0,0 +1,172 @@
+package grsync
+
+import (
+	"bytes"
+	"time"
+)
+
+// progressFields are the fields of a progress line like
+// `15.17G  10%   92.23MB/s    0:23:54 (xfr#1, to-chk=1/2)`. They point into the parsed line
+type progressFields struct {
+	total     []byte
+	percent   int
+	speed     []byte // nil if the line has no speed
+	remaining []byte // nil if the line has no time remaining
+	check     []byte // "rem/total" of to-chk or ir-chk, nil if the line has none
+	// scanning is set for ir-chk, which rsync reports while it still builds the file list
+	scanning bool
+	// transferred is the number of files transferred so far as counted by xfr#, 0 if the line has none
+	transferred int
+}
+
+// rsync before 3.0 prints "xfer#" and "to-check=" instead of "xfr#" and "to-chk="
+var (
+	checkField          = []byte("-chk=")
+	scanCheckField      = []byte("ir-chk=")
+	transferField       = []byte("xfr#")
+	legacyCheckField    = []byte("-check=")
+	legacyTransferField = []byte("xfer#")
+)
+
+// parseProgress parses a progress line in a single pass over line without allocating. Only total
+// and percent are required, rsync omits the other fields in some phases of a transfer
+func parseProgress(line []byte) (p progressFields, ok bool) {
+	field, i := progressField(line, 0)
+	if !isSize(field) {
+		return p, false
+	}
+	p.total = field
+
+	field, i = progressField(line, i)
+	if len(field) < 2 || field[len(field)-1] != '%' {
+		return p, false
+	}
+	for j := 0; j < len(field)-1; j++ {
+		if !isDigit(field[j]) {
+			return p, false
+		}
+		p.percent = p.percent*10 + int(field[j]-'0')
+	}
+
+	field, next := progressField(line, i)
+	if len(field) > 2 && isDigit(field[0]) && field[len(field)-2] == '/' && field[len(field)-1] == 's' {
+		p.speed = field
+		field, next = progressField(line, next)
+	}
+	if isRemaining(field) {
+		p.remaining = field
+		i = next
+	}
+
+	if transferred := fieldValue(line[i:], transferField, legacyTransferField); transferred != nil {
+		for _, c := range transferred {
+			if !isDigit(c) {
+				break
+			}
+			p.transferred = p.transferred*10 + int(c-'0')
+		}
+	}
+	if check := fieldValue(line[i:], checkField, legacyCheckField); check != nil {
+		if end := bytes.IndexByte(check, ')'); end >= 0 {
+			p.check = check[:end]
+			p.scanning = bytes.Contains(line[i:], scanCheckField)
+		}
+	}
+	return p, true
+}
+
+// fieldValue returns the rest of s after the first of the field names found in s, nil if neither is
+func fieldValue(s, name, legacyName []byte) []byte {
+	if j := bytes.Index(s, name); j >= 0 {
+		return s[j+len(name):]
+	}
+	if j := bytes.Index(s, legacyName); j >= 0 {
+		return s[j+len(legacyName):]
+	}
+	return nil
+}
+
+// progressField returns the next whitespace separated field of s starting at i and the index after it
+func progressField(s []byte, i int) ([]byte, int) {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	start := i
+	for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+		i++
+	}
+	return s[start:i], i
+}
+
+// isSize reports whether s looks like a size printed by rsync, e.g. "15.17G" or "1,234,567"
+func isSize(s []byte) bool {
+	i := 0
+	for i < len(s) && (isDigit(s[i]) || (i > 0 && (s[i] == '.' || s[i] == ','))) {
+		i++
+	}
+	if i == 0 || !isDigit(s[i-1]) {
+		return false
+	}
+	for ; i < len(s); i++ {
+		if c := s[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isRemaining reports whether s looks like a time remaining, e.g. "0:23:54"
+func isRemaining(s []byte) bool {
+	colons := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == ':' && i > 0 && s[i-1] != ':':
+			colons++
+		case !isDigit(s[i]):
+			return false
+		}
+	}
+	return colons == 2 && s[len(s)-1] != ':'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// assign sets *dst to b, converting b only if the value changed
+func assign(dst *string, b []byte) {
+	if string(b) != *dst {
+		*dst = string(b)
+	}
+}
+
+// applyProgress updates the state from a parsed progress line, the mutex must be held
+func (t *Task) applyProgress(p progressFields, now time.Time) {
+	assign(&t.state.DownloadedTotal, p.total)
+	if size, err := parseSize(t.state.DownloadedTotal, t.unitBase()); err == nil {
+		t.state.BytesTransferred = size
+	}
+	t.state.CumulativeBytes = t.priorBytes + t.state.BytesTransferred
+	t.updateRunSpeed(now)
+	t.lastStateChange = now
+
+	t.state.BytesProgress = p.percent
+	if p.transferred > 0 {
+		t.state.FilesTransferred = p.transferred
+	}
+	if p.check != nil {
+		t.updateFileProgress(p.check)
+		t.state.Scanning = p.scanning
+		t.state.FilesScanned = t.state.FilesTotal
+	}
+	t.updateProgress()
+
+	if p.remaining != nil {
+		assign(&t.state.TimeRemaining, p.remaining)
+	}
+	if p.speed != nil {
+		assign(&t.state.Speed, p.speed)
+		t.updateSpeed(now)
+	}
+	t.updateETA(p.remaining != nil)
+}
@@ -0,0 +1,119 @@
+package grsync
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProgress(t *testing.T) {
+	p, ok := parseProgress([]byte("     15.17G  10%   92.23MB/s    0:23:54 (xfr#1234, to-chk=999/9999)"))
+	assert.True(t, ok)
+	assert.Equal(t, progressFields{total: []byte("15.17G"), percent: 10, speed: []byte("92.23MB/s"), remaining: []byte("0:23:54"), check: []byte("999/9999"), transferred: 1234}, p)
+
+	p, ok = parseProgress([]byte("999,999 99%  999.99kB/s    0:00:59 (xfr#9, ir-chk=999/9999)"))
+	assert.True(t, ok)
+	assert.Equal(t, progressFields{total: []byte("999,999"), percent: 99, speed: []byte("999.99kB/s"), remaining: []byte("0:00:59"), check: []byte("999/9999"), transferred: 9, scanning: true}, p)
+
+	p, ok = parseProgress([]byte("      5.00M   50%    1.00MB/s"))
+	assert.True(t, ok)
+	assert.Equal(t, progressFields{total: []byte("5.00M"), percent: 50, speed: []byte("1.00MB/s")}, p)
+
+	p, ok = parseProgress([]byte("          0   0%    0.00kB/s    ??:??:??"))
+	assert.True(t, ok)
+	assert.Equal(t, progressFields{total: []byte("0"), percent: 0, speed: []byte("0.00kB/s")}, p)
+
+	for _, line := range []string{
+		"",
+		"sending incremental file list",
+		"1.txt",
+		"2023/photo.jpg",
+		"      5.00M   fifty%",
+		"sent 1,234 bytes  received 35 bytes  2,538.00 bytes/sec",
+		"total size is 1,234  speedup is 0.97",
+	} {
+		_, ok = parseProgress([]byte(line))
+		assert.False(t, ok, line)
+	}
+}
+
+func TestIsRemaining(t *testing.T) {
+	assert.True(t, isRemaining([]byte("0:23:54")))
+	assert.True(t, isRemaining([]byte("123:00:01")))
+	for _, s := range []string{"", ":23:54", "0:23:", "0::54", "0:23", "??:??:??", "0:2a:54"} {
+		assert.False(t, isRemaining([]byte(s)), s)
+	}
+}
+
+func TestParseProgressAllocations(t *testing.T) {
+	line := []byte("     15.17G  10%   92.23MB/s    0:23:54 (xfr#1234, to-chk=999/9999)")
+	var state State
+	allocs := testing.AllocsPerRun(100, func() {
+		p, _ := parseProgress(line)
+		assign(&state.DownloadedTotal, p.total)
+		_ = classifyLine(line, false)
+	})
+	assert.Zero(t, allocs)
+}
+
+// benchmarkLines are a mix of progress and file lines like the output of a verbose transfer
+func benchmarkLines() []string {
+	lines := make([]string, 0, 1000)
+	for i := 0; i < cap(lines); i++ {
+		if i%2 == 0 {
+			lines = append(lines, "dir/subdir/file-"+strconv.Itoa(i)+".dat")
+		} else {
+			lines = append(lines, "     15.17G  "+strconv.Itoa(i%100)+"%   92.23MB/s    0:23:54 (xfr#"+strconv.Itoa(i)+", to-chk=999/9999)")
+		}
+	}
+	return lines
+}
+
+// BenchmarkProgressRegex measures the regular expressions processStdout used before parseProgress
+func BenchmarkProgressRegex(b *testing.B) {
+	progressMatcher := newMatcher(`(\d+%)`)
+	speedMatcher := newMatcher(`(\d+\.\d+.{2}\/s)`)
+	totalMatcher := newMatcher(`^\s*(\d+.\d+[A-Za-z]*)`)
+	timeRemainingMatcher := newMatcher(`(\d+:){2}\d+`)
+	lines := benchmarkLines()
+	var state State
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		line := lines[i%len(lines)]
+		if totalMatcher.Match(line) {
+			state.DownloadedTotal = totalMatcher.Extract(line)
+		}
+		if progressMatcher.Match(line) {
+			state.Progress, _ = strconv.Atoi(strings.TrimRight(progressMatcher.Extract(line), "%"))
+		}
+		if timeRemainingMatcher.Match(line) {
+			state.TimeRemaining = timeRemainingMatcher.All(line)[0]
+		}
+		if speedMatcher.Match(line) {
+			state.Speed = getTaskSpeed(speedMatcher.ExtractAllStringSubmatch(line, 2))
+		}
+	}
+}
+
+func BenchmarkParseProgress(b *testing.B) {
+	var lines [][]byte
+	for _, line := range benchmarkLines() {
+		lines = append(lines, []byte(line))
+	}
+	var state State
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if p, ok := parseProgress(lines[i%len(lines)]); ok {
+			assign(&state.DownloadedTotal, p.total)
+			assign(&state.TimeRemaining, p.remaining)
+			assign(&state.Speed, p.speed)
+			state.Progress = p.percent
+		}
+	}
+}
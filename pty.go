@@ -0,0 +1,31 @@
+package grsync
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+)
+
+// ErrPtyUnsupported is returned for RsyncOptions.UsePty on Windows
+var ErrPtyUnsupported = errors.New("pseudo-terminals are not supported on windows")
+
+// wrapPty returns the command running name with args on a pseudo-terminal allocated by script(1),
+// which prints the output of the terminal on its stdout. util-linux script on Linux is told to
+// flush every write and to exit with the code of rsync, BSD script on macOS does both by default
+func wrapPty(usePty bool, name string, args []string) (string, []string, error) {
+	if !usePty {
+		return name, args, nil
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return "", nil, ErrPtyUnsupported
+	case "linux":
+		command := make([]string, 0, len(args)+1)
+		for _, arg := range append([]string{name}, args...) {
+			command = append(command, shellQuote(arg))
+		}
+		return "script", []string{"-q", "-e", "-f", "-c", strings.Join(command, " "), "/dev/null"}, nil
+	}
+	return "script", append([]string{"-q", "/dev/null", name}, args...), nil
+}
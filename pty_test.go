@@ -0,0 +1,53 @@
+package grsync
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapPty(t *testing.T) {
+	name, args, err := wrapPty(false, "rsync", []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, "rsync", name)
+	assert.Equal(t, []string{"a", "b"}, args)
+
+	name, args, err = wrapPty(true, "rsync", []string{"-a", "it's here/"})
+	switch runtime.GOOS {
+	case "windows":
+		assert.Equal(t, ErrPtyUnsupported, err)
+	case "linux":
+		assert.Nil(t, err)
+		assert.Equal(t, "script", name)
+		assert.Equal(t, []string{"-q", "-e", "-f", "-c", `'rsync' '-a' 'it'\''s here/'`, "/dev/null"}, args)
+	default:
+		assert.Nil(t, err)
+		assert.Equal(t, "script", name)
+		assert.Equal(t, []string{"-q", "/dev/null", "rsync", "-a", "it's here/"}, args)
+	}
+}
+
+func TestTaskUsePty(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("script(1) arguments differ")
+	}
+	if _, err := exec.LookPath("script"); err != nil {
+		t.Skip("script(1) isn't installed")
+	}
+
+	path := fakeRsync(t, `[ -t 1 ] || exit 99
+printf '      1.00M   50%%    1.00MB/s    0:00:01 (xfr#1, to-chk=1/2)\r      2.00M  100%%    1.00MB/s    0:00:00 (xfr#2, to-chk=0/2)\n'
+echo 'file has vanished: "/src/tmp"' >&2
+exit 24`)
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{RsyncBinaryPath: path, UsePty: true})
+	assert.Nil(t, err)
+	err = task.Run()
+	assert.Equal(t, ExitVanished, ExitCodeOf(err))
+
+	assert.Equal(t, 100, task.State().Progress)
+	assert.Len(t, task.FileErrors(), 1)
+	assert.Contains(t, task.Log().Stderr, "file has vanished")
+	assert.NotContains(t, task.Log().Stdout, "file has vanished")
+}
@@ -0,0 +1,204 @@
+package grsync
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RcloneBackend is the SyncBackend running rclone, for destinations rsync can't reach like
+// "s3:bucket/backups" or "gcs:bucket/backups". The remotes are configured in rclone, e.g. in its
+// configuration file or with RCLONE_CONFIG_ variables in Env. rclone lists no permissions, the
+// Mode of the entries is "d" for directories and "-" for files. The ExitCode of results is the
+// one of rclone
+type RcloneBackend struct {
+	// BinaryPath is the rclone binary, by default `rclone`
+	BinaryPath string
+	// Mirror deletes the destination files missing in the source with `rclone sync`, like
+	// RsyncOptions.Delete. Files are only copied with `rclone copy` otherwise
+	Mirror bool
+	// Flags are further flags of rclone, e.g. "--transfers=8" or "--s3-storage-class=GLACIER"
+	Flags []string
+	// Env is the environment of rclone, nil for the current one
+	Env []string
+	// Runner creates the rclone processes, ExecRunner if nil
+	Runner CommandRunner
+}
+
+// rcloneLogLine is a line of rclone's --use-json-log
+type rcloneLogLine struct {
+	Level  string       `json:"level"`
+	Msg    string       `json:"msg"`
+	Object string       `json:"object"`
+	Stats  *rcloneStats `json:"stats"`
+}
+
+// rcloneStats are the accounting stats rclone logs periodically and when it finishes
+type rcloneStats struct {
+	Bytes     int64 `json:"bytes"`
+	Transfers int   `json:"transfers"`
+	Deletes   int   `json:"deletes"`
+}
+
+// rcloneEntry is an entry of `rclone lsjson`
+type rcloneEntry struct {
+	Path    string    `json:"Path"`
+	Size    int64     `json:"Size"`
+	ModTime time.Time `json:"ModTime"`
+	IsDir   bool      `json:"IsDir"`
+}
+
+// Transfer runs `rclone copy` or `rclone sync` from source to destination
+func (b RcloneBackend) Transfer(source, destination string) (Result, error) {
+	started := time.Now()
+	r, stats, err := b.transfer(source, destination, false)
+	r.StartedAt, r.Duration = started, time.Since(started)
+	r.BytesTransferred = stats.Bytes
+	r.FilesTransferred = stats.Transfers
+	r.Stats = Stats{
+		RegularFilesTransferred: stats.Transfers,
+		TotalTransferredSize:    stats.Bytes,
+		DeletedFiles:            stats.Deletes,
+	}
+	return r, err
+}
+
+// Estimate runs the transfer with --dry-run, whose stats count what rclone would do
+func (b RcloneBackend) Estimate(source, destination string) (Estimate, error) {
+	_, stats, err := b.transfer(source, destination, true)
+	if err != nil {
+		return Estimate{}, err
+	}
+	return Estimate{Files: stats.Transfers, Bytes: stats.Bytes, Deletions: stats.Deletes}, nil
+}
+
+// List runs `rclone lsjson -R`, decoding the entries as rclone prints them
+func (b RcloneBackend) List(path string, entry func(ListEntry)) error {
+	args := append([]string{"lsjson", "-R", path}, b.Flags...)
+	return b.run(args, func(stdout io.Reader) error {
+		decoder := json.NewDecoder(stdout)
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("rclone lsjson: %w", err)
+		}
+		for decoder.More() {
+			var e rcloneEntry
+			if err := decoder.Decode(&e); err != nil {
+				return fmt.Errorf("rclone lsjson: %w", err)
+			}
+			listed := ListEntry{Mode: "-", Size: e.Size, ModTime: e.ModTime, Name: e.Path}
+			if e.IsDir {
+				listed.Mode, listed.Size = "d", 0
+			}
+			entry(listed)
+		}
+		return nil
+	}, func(string) {})
+}
+
+// transfer runs rclone copying source to destination and returns the warnings of the run and the
+// last stats rclone logged
+func (b RcloneBackend) transfer(source, destination string, dryRun bool) (Result, rcloneStats, error) {
+	command := "copy"
+	if b.Mirror {
+		command = "sync"
+	}
+	args := []string{command, source, destination, "--use-json-log", "--verbose"}
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+	args = append(args, b.Flags...)
+
+	r := Result{DryRun: dryRun}
+	var stats rcloneStats
+	err := b.run(args, func(io.Reader) error { return nil }, func(line string) {
+		var log rcloneLogLine
+		if json.Unmarshal([]byte(line), &log) != nil {
+			return
+		}
+		if log.Stats != nil {
+			stats = *log.Stats
+		}
+		if log.Level == "error" || log.Level == "critical" {
+			warning := Warning{Kind: WarningOther, Message: log.Msg}
+			if log.Object != "" {
+				warning.Kind, warning.Path = WarningFile, log.Object
+			}
+			r.Warnings = append(r.Warnings, warning)
+		}
+	})
+	r.ExitCode = ExitCodeOf(err)
+	return r, stats, err
+}
+
+// run runs rclone with args, passing its output to stdout and the lines of its log on stderr to
+// line. A failure of rclone is returned with the last lines it logged
+func (b RcloneBackend) run(args []string, stdout func(io.Reader) error, line func(string)) error {
+	name := "rclone"
+	if b.BinaryPath != "" {
+		name = b.BinaryPath
+	}
+	runner := b.Runner
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	process := runner.NewProcess(Command{Name: name, Args: args, Env: b.Env})
+	stdoutPipe, err := process.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderrPipe, err := process.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = process.Start(); err != nil {
+		return err
+	}
+
+	var last []string
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderrPipe)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			text := scanner.Text()
+			line(text)
+			if message := rcloneMessage(text); message != "" {
+				last = append(last[max(len(last)-2, 0):], message)
+			}
+		}
+		_, _ = io.Copy(io.Discard, stderrPipe)
+	}()
+	outputErr := stdout(stdoutPipe)
+	// rclone blocks on a full pipe if the output wasn't read to the end
+	_, _ = io.Copy(io.Discard, stdoutPipe)
+	wg.Wait()
+	if err = process.Wait(); err != nil {
+		if len(last) > 0 {
+			return fmt.Errorf("rclone: %w: %s", err, strings.Join(last, "; "))
+		}
+		return fmt.Errorf("rclone: %w", err)
+	}
+	return outputErr
+}
+
+// rcloneMessage returns the message of an error logged by rclone, or line if it isn't JSON, e.g.
+// the usage errors of rclone
+func rcloneMessage(line string) string {
+	var log rcloneLogLine
+	if json.Unmarshal([]byte(line), &log) != nil {
+		return strings.TrimSpace(line)
+	}
+	if log.Level != "error" && log.Level != "critical" {
+		return ""
+	}
+	if log.Object != "" {
+		return log.Object + ": " + log.Msg
+	}
+	return log.Msg
+}
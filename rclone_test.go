@@ -0,0 +1,74 @@
+package grsync
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRcloneTransfer(t *testing.T) {
+	script := `echo "$@" > "$0.args"
+echo '{"level":"info","msg":"Copied (new)","object":"a.txt","objectType":"*local.Object"}' >&2
+echo '{"level":"error","msg":"Failed to copy: permission denied","object":"b.txt"}' >&2
+echo '{"level":"info","msg":"Transferred: 1 KiB","stats":{"bytes":1024,"transfers":1,"deletes":2,"errors":1}}' >&2`
+	path := fakeRsync(t, script)
+	backend := RcloneBackend{BinaryPath: path, Mirror: true, Flags: []string{"--transfers=8"}}
+
+	result, err := backend.Transfer("/data/", "s3:bucket/backup")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), result.BytesTransferred)
+	assert.Equal(t, 1, result.FilesTransferred)
+	assert.Equal(t, 2, result.Stats.DeletedFiles)
+	assert.Equal(t, []Warning{{Kind: WarningFile, Path: "b.txt", Message: "Failed to copy: permission denied"}}, result.Warnings)
+	assert.False(t, result.StartedAt.IsZero())
+	assert.Equal(t, "sync /data/ s3:bucket/backup --use-json-log --verbose --transfers=8\n", readFile(t, path+".args"))
+
+	estimate, err := backend.Estimate("/data/", "s3:bucket/backup")
+	assert.Nil(t, err)
+	assert.Equal(t, Estimate{Files: 1, Bytes: 1024, Deletions: 2}, estimate)
+	assert.Contains(t, readFile(t, path+".args"), "--dry-run")
+
+	backend.Mirror = false
+	_, _ = backend.Transfer("/data/", "s3:bucket/backup")
+	assert.Contains(t, readFile(t, path+".args"), "copy /data/")
+}
+
+func TestRcloneFailure(t *testing.T) {
+	script := `echo '{"level":"error","msg":"Failed to create file system: section not found in config file"}' >&2
+echo 'Usage: rclone copy source:path dest:path [flags]' >&2
+exit 1`
+	backend := RcloneBackend{BinaryPath: fakeRsync(t, script)}
+	result, err := backend.Transfer("/data/", "s3:bucket/backup")
+	assert.EqualError(t, err, "rclone: exit status 1: Failed to create file system: section not found in config file; Usage: rclone copy source:path dest:path [flags]")
+	assert.Equal(t, ExitCode(1), result.ExitCode)
+
+	_, err = backend.Estimate("/data/", "s3:bucket/backup")
+	assert.NotNil(t, err)
+}
+
+func TestRcloneList(t *testing.T) {
+	script := `echo '['
+echo '{"Path":"photos","Name":"photos","Size":-1,"ModTime":"2024-01-02T03:04:05Z","IsDir":true},'
+echo '{"Path":"photos/a.jpg","Name":"a.jpg","Size":2048,"ModTime":"2024-01-02T03:04:05Z","IsDir":false}'
+echo ']'`
+	backend := RcloneBackend{BinaryPath: fakeRsync(t, script)}
+	var entries []ListEntry
+	assert.Nil(t, backend.List("s3:bucket/backup", func(entry ListEntry) { entries = append(entries, entry) }))
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, []ListEntry{
+		{Mode: "d", ModTime: modTime, Name: "photos"},
+		{Mode: "-", Size: 2048, ModTime: modTime, Name: "photos/a.jpg"},
+	}, entries)
+	assert.True(t, entries[0].IsDir())
+
+	backend = RcloneBackend{BinaryPath: fakeRsync(t, `echo 'not json'`)}
+	assert.NotNil(t, backend.List("s3:bucket/backup", func(ListEntry) {}))
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	return string(data)
+}
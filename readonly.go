@@ -0,0 +1,68 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrReadOnly is returned by Task.Run and Manager.Add for tasks deleting files in read-only mode,
+// see SetReadOnly
+var ErrReadOnly = errors.New("destructive options are refused in read-only mode")
+
+var readOnly atomic.Bool
+
+// SetReadOnly switches the read-only mode of the process: tasks whose options delete files on
+// either side, e.g. Delete or RemoveSourceFiles, fail with ErrReadOnly instead of running. Dry
+// runs and tasks with TaskConfig.AllowDestructive still run. See Manager.SetReadOnly for a
+// single manager
+func SetReadOnly(enabled bool) {
+	readOnly.Store(enabled)
+}
+
+// ReadOnly reports whether the process is in read-only mode, see SetReadOnly
+func ReadOnly() bool {
+	return readOnly.Load()
+}
+
+// destructiveFlags returns the flags of options deleting files
+func destructiveFlags(options RsyncOptions) []string {
+	var flags []string
+	if options.RemoveSourceFiles {
+		flags = append(flags, "--remove-source-files")
+	}
+	if options.Delete {
+		flags = append(flags, "--delete")
+	}
+	for _, timing := range options.deleteTimings() {
+		flags = append(flags, "--delete-"+string(timing))
+	}
+	if options.DeleteExcluded {
+		flags = append(flags, "--delete-excluded")
+	}
+	if options.DeleteMissingArgs {
+		flags = append(flags, "--delete-missing-args")
+	}
+	return flags
+}
+
+// checkReadOnly returns ErrReadOnly naming the destructive flags of definition if it can't run in
+// read-only mode
+func checkReadOnly(definition Definition) error {
+	if definition.Options.DryRun || definition.Config.AllowDestructive {
+		return nil
+	}
+	if flags := destructiveFlags(definition.Options); len(flags) > 0 {
+		return fmt.Errorf("%w: %s", ErrReadOnly, strings.Join(flags, " "))
+	}
+	return nil
+}
+
+// SetReadOnly switches the read-only mode of the manager, which refuses to add tasks SetReadOnly
+// would refuse to run. Tasks added before aren't affected
+func (m *Manager) SetReadOnly(enabled bool) {
+	m.mutex.Lock()
+	m.readOnly = enabled
+	m.mutex.Unlock()
+}
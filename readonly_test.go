@@ -0,0 +1,58 @@
+package grsync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	SetReadOnly(true)
+	t.Cleanup(func() { SetReadOnly(false) })
+	assert.True(t, ReadOnly())
+	binary := fakeRsync(t, "exit 0")
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: binary, Delete: true, DeleteTiming: DeleteAfter, RemoveSourceFiles: true})
+	assert.Nil(t, err)
+	err = task.Run()
+	assert.True(t, errors.Is(err, ErrReadOnly))
+	assert.Equal(t, "destructive options are refused in read-only mode: --remove-source-files --delete --delete-after", err.Error())
+	assert.Equal(t, TaskFailed, task.Status())
+
+	for _, definition := range []Definition{
+		{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: binary}},
+		{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: binary, Delete: true, DryRun: true}},
+		{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: binary, Delete: true}, Config: TaskConfig{AllowDestructive: true}},
+	} {
+		task, err = definition.NewTask()
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+	}
+
+	SetReadOnly(false)
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: binary, DeleteExcluded: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+}
+
+func TestManagerReadOnly(t *testing.T) {
+	m := NewManager()
+	deleting, err := NewTask("a", "b", false, false, RsyncOptions{DeleteMissingArgs: true})
+	assert.Nil(t, err)
+	assert.Nil(t, m.Add(deleting))
+
+	m.SetReadOnly(true)
+	deleting, err = NewTask("a", "c", false, false, RsyncOptions{DeleteMissingArgs: true})
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(m.Add(deleting), ErrReadOnly))
+	assert.True(t, errors.Is(m.Submit(deleting), ErrReadOnly))
+	_, err = m.SubmitUnique(deleting)
+	assert.True(t, errors.Is(err, ErrReadOnly))
+	assert.Len(t, m.List(), 1)
+
+	copying, err := NewTask("a", "b", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, m.Add(copying))
+	assert.Len(t, m.List(), 2)
+}
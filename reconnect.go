@@ -0,0 +1,52 @@
+package grsync
+
+import (
+	"strings"
+)
+
+// networkSignatures are the stderr messages of rsync and ssh when the connection dropped
+// mid-transfer, as opposed to errors that fail the same way when tried again
+var networkSignatures = []string{
+	"connection reset by peer",
+	"broken pipe",
+	"connection unexpectedly closed",
+	"connection timed out",
+	"network is unreachable",
+	"no route to host",
+	"connection closed by remote host",
+	"timeout waiting for daemon connection",
+	"io timeout after",
+	"error in socket io (code 10)",
+	"error in rsync protocol data stream (code 12)",
+}
+
+// IsNetworkDrop reports whether a line rsync printed on stderr indicates a dropped connection
+func IsNetworkDrop(line string) bool {
+	line = strings.ToLower(line)
+	for _, signature := range networkSignatures {
+		if strings.Contains(line, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetReconnects enables relaunching rsync immediately when an attempt failed because the
+// connection dropped, up to max times per run. Reconnects don't count as attempts of the
+// retry policy and are reported in State.Reconnects
+func (t *Task) SetReconnects(max int) {
+	t.mutex.Lock()
+	t.maxReconnects = max
+	t.mutex.Unlock()
+}
+
+// reconnect reports whether the failed attempt is relaunched and counts the reconnect
+func (t *Task) reconnect() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.networkDropped || t.cancelled || t.state.Reconnects >= t.maxReconnects {
+		return false
+	}
+	t.state.Reconnects++
+	return true
+}
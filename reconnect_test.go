@@ -0,0 +1,87 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsNetworkDrop(t *testing.T) {
+	for _, line := range []string{
+		"rsync: connection unexpectedly closed (1234 bytes received so far) [receiver]",
+		"rsync error: error in rsync protocol data stream (code 12) at io.c(228) [receiver=3.2.7]",
+		"client_loop: send disconnect: Broken pipe",
+		"packet_write_wait: Connection to 10.0.0.1 port 22: Broken pipe",
+		"Read from remote host example.com: Connection reset by peer",
+		"ssh: connect to host example.com port 22: Connection timed out",
+		"[sender] io timeout after 60 seconds -- exiting",
+	} {
+		assert.True(t, IsNetworkDrop(line), line)
+	}
+
+	for _, line := range []string{
+		`rsync: [sender] link_stat "/missing" failed: No such file or directory (2)`,
+		"rsync error: syntax or usage error (code 1) at main.c(1795) [client=3.2.7]",
+		"Permission denied (publickey).",
+	} {
+		assert.False(t, IsNetworkDrop(line), line)
+	}
+}
+
+func TestTaskReconnect(t *testing.T) {
+	// drops the connection on the first two invocations
+	counter := filepath.Join(t.TempDir(), "counter")
+	script := `echo x >> ` + counter + `
+if [ $(wc -l < ` + counter + `) -le 2 ]; then
+	echo "      1,000   10%    1.00kB/s    0:00:09"
+	echo "Read from remote host example.com: Connection reset by peer" >&2
+	exit 12
+fi
+echo "      9,000  100%    1.00kB/s    0:00:00"`
+
+	t.Run("relaunches without retry attempts", func(t *testing.T) {
+		assert.Nil(t, ioutil.WriteFile(counter, nil, 0644))
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetReconnects(2)
+
+		var retries int
+		task.AddHooks(Hooks{OnRetry: func(*Task, int, error) { retries++ }})
+		assert.Nil(t, task.Run())
+
+		state := task.State()
+		assert.Equal(t, 2, state.Reconnects)
+		assert.Equal(t, int64(11000), state.CumulativeBytes)
+		assert.Equal(t, 3, task.Attempts())
+		assert.Zero(t, retries)
+	})
+
+	t.Run("falls back to the retry policy", func(t *testing.T) {
+		assert.Nil(t, ioutil.WriteFile(counter, nil, 0644))
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetReconnects(1)
+		task.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, Delay: time.Millisecond})
+		assert.Nil(t, task.Run())
+		assert.Equal(t, 1, task.State().Reconnects)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		assert.Nil(t, ioutil.WriteFile(counter, nil, 0644))
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		assert.NotNil(t, task.Run())
+		assert.Zero(t, task.State().Reconnects)
+	})
+
+	t.Run("ignores other errors", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "rsync error: some files could not be transferred (code 23)" >&2; exit 23`)})
+		assert.Nil(t, err)
+		task.SetReconnects(5)
+		assert.NotNil(t, task.Run())
+		assert.Equal(t, 1, task.Attempts())
+	})
+}
@@ -0,0 +1,314 @@
+package grsync
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReplayExhausted is returned by replayed tasks starting more attempts than were recorded
+var ErrReplayExhausted = errors.New("recording has no further attempts")
+
+// recordingVersion is the version of the format of recordings
+const recordingVersion = 1
+
+// Recording is the record of a run for debugging, e.g. to attach to a bug report about parsing or
+// state: the command lines of the attempts and the raw output of rsync. Replay feeds the output
+// through the parser again. Secrets of the command lines, the environment and the output are
+// redacted, see Redact
+type Recording struct {
+	Version    int        `json:"version"`
+	RecordedAt time.Time  `json:"recordedAt"`
+	Definition Definition `json:"definition"`
+	// Manifest is set if the task recorded a manifest, which changes the output of rsync
+	Manifest bool              `json:"manifest,omitempty"`
+	Attempts []RecordedAttempt `json:"attempts"`
+}
+
+// RecordedAttempt is an rsync process of a recorded run
+type RecordedAttempt struct {
+	Command []string `json:"command"`
+	// Env are the variables set for rsync on top of the inherited environment, which isn't recorded
+	Env       []string        `json:"env,omitempty"`
+	StartedAt time.Time       `json:"startedAt"`
+	Chunks    []RecordedChunk `json:"chunks"`
+	ExitCode  ExitCode        `json:"exitCode"`
+	// Error is the error the process failed with, empty if it succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// RecordedChunk is a read from the output of rsync
+type RecordedChunk struct {
+	// Stream is "stdout" or "stderr"
+	Stream string `json:"stream"`
+	// At is the time since the start of the attempt
+	At   time.Duration `json:"at"`
+	Data []byte        `json:"data"`
+}
+
+// SetRecording makes every Run of the task record a new Recording, see Task.Recording
+func (t *Task) SetRecording(enabled bool) {
+	t.mutex.Lock()
+	t.recordingEnabled = enabled
+	t.mutex.Unlock()
+}
+
+// Recording returns the recording of the current or last run, nil if the task doesn't record
+func (t *Task) Recording() *Recording {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.recording == nil {
+		return nil
+	}
+	r := *t.recording
+	r.Attempts = append([]RecordedAttempt(nil), r.Attempts...)
+	return &r
+}
+
+// startRecording starts the recording of a run if it is enabled. The mutex must be held
+func (t *Task) startRecording() {
+	t.recording = nil
+	if !t.recordingEnabled {
+		return
+	}
+	definition := t.definition
+	definition.Options.Env = redactEnv(definition.Options.Env)
+	definition.Labels = cloneLabels(definition.Labels)
+	t.recording = &Recording{
+		Version:    recordingVersion,
+		RecordedAt: time.Now(),
+		Definition: definition,
+		Manifest:   t.manifestEnabled,
+	}
+}
+
+// attemptRecorder records the output of an attempt
+type attemptRecorder struct {
+	mutex   sync.Mutex
+	attempt RecordedAttempt
+	secrets []string
+}
+
+// recordAttempt returns the recorder of the attempt about to start, nil if the run isn't recorded.
+// The mutex must be held
+func (t *Task) recordAttempt() *attemptRecorder {
+	if t.recording == nil {
+		return nil
+	}
+	inherited := make(map[string]bool)
+	for _, variable := range os.Environ() {
+		inherited[variable] = true
+	}
+	var env []string
+	for _, variable := range redactEnv(t.rsync.command.Env) {
+		if !inherited[variable] {
+			env = append(env, variable)
+		}
+	}
+	return &attemptRecorder{
+		attempt: RecordedAttempt{Command: t.rsync.Command(), Env: env, StartedAt: time.Now()},
+		secrets: t.secrets,
+	}
+}
+
+// writer returns the writer recording stream, nil for a nil recorder
+func (r *attemptRecorder) writer(stream string) io.Writer {
+	if r == nil {
+		return nil
+	}
+	return &recordWriter{recorder: r, stream: stream}
+}
+
+// finish adds the attempt which ended with err to the recording of t
+func (r *attemptRecorder) finish(t *Task, err error) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	attempt := r.attempt
+	r.mutex.Unlock()
+	attempt.ExitCode = ExitCodeOf(err)
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	t.mutex.Lock()
+	if t.recording != nil {
+		t.recording.Attempts = append(t.recording.Attempts, attempt)
+	}
+	t.mutex.Unlock()
+}
+
+type recordWriter struct {
+	recorder *attemptRecorder
+	stream   string
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	data := string(p)
+	for _, secret := range w.recorder.secrets {
+		data = strings.ReplaceAll(data, secret, redacted)
+	}
+	r := w.recorder
+	r.mutex.Lock()
+	r.attempt.Chunks = append(r.attempt.Chunks, RecordedChunk{
+		Stream: w.stream,
+		At:     time.Since(r.attempt.StartedAt),
+		Data:   []byte(data),
+	})
+	r.mutex.Unlock()
+	return len(p), nil
+}
+
+// WriteFile writes the recording to path as JSON
+func (r *Recording) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ReadRecording reads a recording written by Recording.WriteFile
+func ReadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Recording
+	if err = json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Replay returns a pending task whose Run passes the recorded output through the parser again,
+// attempt by attempt, instead of running rsync. Callbacks and channels can be registered on it
+// like on the recorded task. Nothing is transferred. The options touching the system outside of
+// rsync, like LockDestination, LogFile, Credential or the DSCP relay, are dropped
+func (r *Recording) Replay() (*Task, error) {
+	definition := r.Definition
+	definition.ID = ""
+	definition.UseSshPass = false
+	definition.CreateDir = false
+	definition.LockDestination = false
+	definition.Config.AllowDestructive = true
+	options := cloneOptions(definition.Options)
+	options.LogFile = ""
+	options.Credential = nil
+	options.Priority = Priority{}
+	options.Limits = ResourceLimits{}
+	options.IgnoreFiles = nil
+	options.UsePty = false
+	options.RemoteSudoPasswordFile = ""
+	options.SocketOptions.DSCP = 0
+	definition.Options = options
+
+	task, err := definition.NewTask()
+	if err != nil {
+		return nil, err
+	}
+	task.SetRunner(&replayRunner{attempts: append([]RecordedAttempt(nil), r.Attempts...)})
+	task.SetRetryPolicy(RetryPolicy{MaxAttempts: len(r.Attempts)})
+	if r.Manifest {
+		task.EnableManifest()
+	}
+	return task, nil
+}
+
+// replayRunner plays the recorded attempts, one per process
+type replayRunner struct {
+	mutex    sync.Mutex
+	attempts []RecordedAttempt
+}
+
+func (r *replayRunner) NewProcess(Command) Process {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	p := &replayProcess{done: make(chan struct{}), killed: make(chan struct{})}
+	if len(r.attempts) > 0 {
+		p.attempt = &r.attempts[0]
+		r.attempts = r.attempts[1:]
+	}
+	p.stdout, p.stdoutWriter = io.Pipe()
+	p.stderr, p.stderrWriter = io.Pipe()
+	return p
+}
+
+// replayProcess writes the chunks of a recorded attempt to its pipes in their order
+type replayProcess struct {
+	attempt                    *RecordedAttempt
+	stdout, stderr             *io.PipeReader
+	stdoutWriter, stderrWriter *io.PipeWriter
+	done                       chan struct{}
+	killed                     chan struct{}
+	killOnce                   sync.Once
+	started                    bool
+}
+
+func (p *replayProcess) StdoutPipe() (io.ReadCloser, error) { return p.stdout, nil }
+func (p *replayProcess) StderrPipe() (io.ReadCloser, error) { return p.stderr, nil }
+
+func (p *replayProcess) Start() error {
+	if p.attempt == nil {
+		return ErrReplayExhausted
+	}
+	p.started = true
+	go func() {
+		defer close(p.done)
+		for _, chunk := range p.attempt.Chunks {
+			w := p.stdoutWriter
+			if chunk.Stream == "stderr" {
+				w = p.stderrWriter
+			}
+			if _, err := w.Write(chunk.Data); err != nil {
+				break
+			}
+		}
+		_ = p.stdoutWriter.Close()
+		_ = p.stderrWriter.Close()
+	}()
+	return nil
+}
+
+func (p *replayProcess) Wait() error {
+	if !p.started {
+		return ErrNotStarted
+	}
+	<-p.done
+	select {
+	case <-p.killed:
+		return replayExitError{code: -1, message: "signal: killed"}
+	default:
+	}
+	if p.attempt.ExitCode == ExitOK {
+		return nil
+	}
+	return replayExitError{code: int(p.attempt.ExitCode), message: p.attempt.Error}
+}
+
+func (p *replayProcess) Signal(sig os.Signal) error {
+	if !p.started {
+		return ErrNotStarted
+	}
+	if sig == os.Kill {
+		p.killOnce.Do(func() {
+			close(p.killed)
+			_ = p.stdoutWriter.CloseWithError(io.ErrClosedPipe)
+			_ = p.stderrWriter.CloseWithError(io.ErrClosedPipe)
+		})
+	}
+	return nil
+}
+
+// replayExitError is the recorded failure of an attempt
+type replayExitError struct {
+	code    int
+	message string
+}
+
+func (e replayExitError) Error() string { return e.message }
+func (e replayExitError) ExitCode() int { return e.code }
@@ -0,0 +1,78 @@
+package grsync
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecording(t *testing.T) {
+	script := `echo "sending incremental file list"
+echo "photos/a.jpg"
+printf '      1,024 100%%    1.00MB/s    0:00:00 (xfr#1, to-chk=0/2)\r'
+echo 'rsync: send_files failed to open "/data/b.jpg": Permission denied (13)' >&2
+echo "password $RSYNC_PASSWORD"
+echo ""
+echo "sent 1,234 bytes  received 56 bytes  2,580.00 bytes/sec"
+echo "total size is 2,048  speedup is 1.59"
+exit 23`
+	task, err := NewTask("/data/", "/backup/", false, false, RsyncOptions{
+		RsyncBinaryPath: fakeRsync(t, script),
+		Env:             []string{"RSYNC_PASSWORD=hunter2"},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Recording())
+	task.SetRecording(true)
+	runErr := task.Run()
+	assert.Equal(t, ExitPartial, ExitCodeOf(runErr))
+
+	recording := task.Recording()
+	if !assert.NotNil(t, recording) {
+		return
+	}
+	assert.Equal(t, recordingVersion, recording.Version)
+	assert.Equal(t, []string{"RSYNC_PASSWORD=" + redacted}, recording.Definition.Options.Env)
+	assert.Len(t, recording.Attempts, 1)
+	attempt := recording.Attempts[0]
+	assert.Equal(t, task.Command(), attempt.Command)
+	assert.Contains(t, attempt.Env, "RSYNC_PASSWORD="+redacted)
+	assert.Equal(t, ExitPartial, attempt.ExitCode)
+	var stdout, stderr string
+	for _, chunk := range attempt.Chunks {
+		if chunk.Stream == "stderr" {
+			stderr += string(chunk.Data)
+		} else {
+			stdout += string(chunk.Data)
+		}
+	}
+	assert.Contains(t, stdout, "\r")
+	assert.Contains(t, stdout, "password "+redacted)
+	assert.NotContains(t, stdout, "hunter2")
+	assert.Contains(t, stderr, "Permission denied")
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+	assert.Nil(t, recording.WriteFile(path))
+	read, err := ReadRecording(path)
+	assert.Nil(t, err)
+	assert.Len(t, read.Attempts, 1)
+	assert.Equal(t, attempt.Chunks, read.Attempts[0].Chunks)
+	assert.Equal(t, attempt.Command, read.Attempts[0].Command)
+	assert.True(t, attempt.StartedAt.Equal(read.Attempts[0].StartedAt))
+
+	replay, err := read.Replay()
+	assert.Nil(t, err)
+	replayErr := replay.Run()
+	assert.Equal(t, ExitPartial, ExitCodeOf(replayErr))
+	assert.Equal(t, task.Summary(), replay.Summary())
+	assert.Equal(t, int64(1234), replay.Summary().BytesSent)
+	assert.Equal(t, task.State().FilesTransferred, replay.State().FilesTransferred)
+	assert.Equal(t, task.FileErrors(), replay.FileErrors())
+	assert.NotEmpty(t, replay.FileErrors())
+
+	// replays don't start more processes than were recorded
+	replay, err = read.Replay()
+	assert.Nil(t, err)
+	replay.SetRetryPolicy(RetryPolicy{MaxAttempts: 2})
+	assert.ErrorIs(t, replay.Run(), ErrReplayExhausted)
+}
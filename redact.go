@@ -0,0 +1,116 @@
+package grsync
+
+import (
+	"strings"
+)
+
+// secretEnv are the variables of RsyncOptions.Env whose values are secrets
+var secretEnv = []string{"RSYNC_PASSWORD", "SSHPASS"}
+
+// Redacted returns a copy of the definition with the secrets redacted, the values of RSYNC_PASSWORD
+// and SSHPASS in Env and the sshpass passwords of Rsh and RemoteShell. It is meant to be shown,
+// e.g. in an API, a redacted definition creates tasks which can't authenticate
+func (d Definition) Redacted() Definition {
+	d.Labels = cloneLabels(d.Labels)
+	d.Options = redactOptions(d.Options)
+	return d
+}
+
+// redactOptions returns options with the secrets of Env, Rsh and RemoteShell redacted
+func redactOptions(options RsyncOptions) RsyncOptions {
+	options.Env = redactEnv(options.Env)
+	if options.Rsh != "" {
+		options.Rsh = redactArgv([]string{options.Rsh})[0]
+	}
+	if options.RemoteShell.Command != "" {
+		shell := redactArgv(append([]string{options.RemoteShell.Command}, options.RemoteShell.Args...))
+		options.RemoteShell = RemoteShell{Command: shell[0], Args: shell[1:]}
+	}
+	return options
+}
+
+// hasRedactedSecrets reports whether options hold secrets replaced by redactOptions
+func hasRedactedSecrets(options RsyncOptions) bool {
+	for _, variable := range options.Env {
+		if name, value, _ := strings.Cut(variable, "="); value == redacted && isSecretEnv(name) {
+			return true
+		}
+	}
+	shell := append([]string{options.Rsh, options.RemoteShell.Command}, options.RemoteShell.Args...)
+	for _, arg := range shell {
+		if strings.Contains(arg, redacted) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces the secrets of the task in s, the passwords of its rsync command and
+// environment, see Definition.Redacted. The task applies it to its Log, snapshots, notifications,
+// command lines and log records, callers exposing other output of rsync can apply it as well
+func (t *Task) Redact(s string) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.redact(s)
+}
+
+// redact replaces the secrets of the task in s. The mutex must be held
+func (t *Task) redact(s string) string {
+	for _, secret := range t.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// redactCommand returns the command line of command with its secrets redacted
+func redactCommand(command Command) []string {
+	argv := redactArgv(append([]string{command.Name}, command.Args...))
+	secrets := commandSecrets(command)
+	for i := range argv {
+		for _, secret := range secrets {
+			argv[i] = strings.ReplaceAll(argv[i], secret, redacted)
+		}
+	}
+	return argv
+}
+
+// redactEnv returns a copy of env with the values of the secret variables redacted
+func redactEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	env = append([]string(nil), env...)
+	for i, variable := range env {
+		if name, _, ok := strings.Cut(variable, "="); ok && isSecretEnv(name) {
+			env[i] = name + "=" + redacted
+		}
+	}
+	return env
+}
+
+func isSecretEnv(name string) bool {
+	for _, secret := range secretEnv {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// commandSecrets returns the secrets of a rsync command, the values of the secret variables of its
+// environment and the passwords its arguments pass to sshpass
+func commandSecrets(command Command) []string {
+	var secrets []string
+	for _, variable := range command.Env {
+		if name, value, ok := strings.Cut(variable, "="); ok && value != "" && isSecretEnv(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	_, passwords := sshpassPasswords(append([]string{command.Name}, command.Args...))
+	for _, password := range passwords {
+		if password != "" {
+			secrets = append(secrets, password)
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,91 @@
+package grsync
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactEnv(t *testing.T) {
+	assert.Nil(t, redactEnv(nil))
+	env := []string{"RSYNC_PASSWORD=hunter2", "SSH_AUTH_SOCK=/tmp/agent", "SSHPASS=x"}
+	assert.Equal(t, []string{"RSYNC_PASSWORD=***", "SSH_AUTH_SOCK=/tmp/agent", "SSHPASS=***"}, redactEnv(env))
+	assert.Equal(t, "RSYNC_PASSWORD=hunter2", env[0])
+}
+
+func TestDefinitionRedacted(t *testing.T) {
+	d := Definition{Options: RsyncOptions{
+		Env:         []string{"RSYNC_PASSWORD=hunter2"},
+		Rsh:         "sshpass -p hunter2 ssh",
+		RemoteShell: RemoteShell{Command: "sshpass", Args: []string{"-p", "hunter2", "ssh"}},
+	}}
+	redacted := d.Redacted()
+	assert.Equal(t, []string{"RSYNC_PASSWORD=***"}, redacted.Options.Env)
+	assert.Equal(t, "sshpass -p *** ssh", redacted.Options.Rsh)
+	assert.Equal(t, RemoteShell{Command: "sshpass", Args: []string{"-p", "***", "ssh"}}, redacted.Options.RemoteShell)
+	assert.Equal(t, "RSYNC_PASSWORD=hunter2", d.Options.Env[0])
+}
+
+func TestTaskRedact(t *testing.T) {
+	script := `echo "password $RSYNC_PASSWORD"
+echo "rsync: password $RSYNC_PASSWORD" >&2
+exit 5`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), Env: []string{"RSYNC_PASSWORD=hunter2"}})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	task.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	store := &MemoryRunStore{}
+	task.SetRunStore(store, "")
+
+	var messages []string
+	task.OnProcessStart(func(info ProcessInfo) {
+		messages = append(messages, strings.Join(info.Args, " "))
+	})
+	task.OnStderrLine(func(line string) { messages = append(messages, line) })
+	task.OnUnparsedLine(func(line string) { messages = append(messages, line) })
+	assert.NotNil(t, task.Run())
+	task.logEvent(slog.LevelWarn, "failed", slog.Any("error", errors.New("sshpass -p hunter2: exit status 5")))
+
+	log := task.Log()
+	assert.Equal(t, "password ***\n", log.Stdout)
+	assert.Equal(t, "rsync: password ***\n", log.Stderr)
+	snapshot := task.Snapshot()
+	assert.Equal(t, log, snapshot.LogTail)
+	assert.Equal(t, []string{"RSYNC_PASSWORD=***"}, snapshot.Definition.Options.Env)
+	assert.Equal(t, "a *** b", task.Redact("a hunter2 b"))
+	assert.Contains(t, buf.String(), "rsync: password ***")
+	assert.Contains(t, buf.String(), "sshpass -p ***")
+	assert.Contains(t, messages, "rsync: password ***")
+
+	records, err := store.Runs(RunQuery{})
+	assert.Nil(t, err)
+	if assert.Len(t, records, 1) {
+		messages = append(messages, records[0].Error)
+		for _, warning := range records[0].Result.Warnings {
+			messages = append(messages, warning.Message)
+		}
+		assert.NotEmpty(t, records[0].Result.Warnings)
+	}
+
+	for _, message := range append(messages, buf.String(), strings.Join(task.Command(), " ")) {
+		assert.NotContains(t, message, "hunter2")
+	}
+	assert.Equal(t, []string{"RSYNC_PASSWORD=hunter2"}, task.Definition().Options.Env)
+}
+
+func TestTaskRedactSshPass(t *testing.T) {
+	password := filepath.Join(t.TempDir(), "password")
+	assert.Nil(t, os.WriteFile(password, []byte("hunter2\n"), 0o600))
+
+	task, err := NewTask("a", "host:b", true, false, RsyncOptions{PasswordFile: password})
+	assert.Nil(t, err)
+	assert.Equal(t, "wrong password ***", task.Redact("wrong password hunter2"))
+	assert.NotContains(t, strings.Join(task.Command(), " "), "hunter2")
+}
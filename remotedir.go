@@ -0,0 +1,74 @@
+package grsync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnsureRemoteDir creates the directory remote and its parents, like CreateDir does for local
+// destinations. Remote shell paths like "user@host:/backup/a/b" are created with mkdir -p through
+// the remote shell of options, daemon paths like "host::module/a/b" or "rsync://host/module/a/b"
+// by transferring an empty directory with --mkpath, which needs rsync 3.2.3 on both sides.
+// Local paths are created directly. Only the connection settings of options are used
+func EnsureRemoteDir(remote string, options RsyncOptions) error {
+	if isDaemonPath(remote) {
+		return mkpathDaemon(remote, options)
+	}
+	host, path, ok := splitRemote(remote)
+	if !ok {
+		return createDir(resolvePath(options.WorkDir, remote))
+	}
+	if path == "" {
+		// the home directory
+		return nil
+	}
+
+	out, err := remoteCommand(options.remoteShell(), host, "mkdir -p -- "+shellQuote(path)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mkdir %s: %w: %s", remote, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// isDaemonPath reports whether path is served by a rsync daemon
+func isDaemonPath(path string) bool {
+	if strings.HasPrefix(path, "rsync://") {
+		return true
+	}
+	host, rest, ok := splitRemote(path)
+	return ok && host != "" && strings.HasPrefix(rest, ":")
+}
+
+// mkpathDaemon creates the daemon path remote by transferring an empty directory into it
+func mkpathDaemon(remote string, options RsyncOptions) error {
+	empty, err := os.MkdirTemp("", "grsync-mkpath")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(empty)
+
+	task, err := NewTask(empty+"/", strings.TrimSuffix(remote, "/")+"/", false, false, RsyncOptions{
+		RsyncBinaryPath: options.RsyncBinaryPath,
+		Flavor:          options.Flavor,
+		Locale:          options.Locale,
+		Env:             options.Env,
+		ClearEnv:        options.ClearEnv,
+		RsyncPath:       options.RsyncPath,
+		Rsh:             options.Rsh,
+		RemoteShell:     options.RemoteShell,
+		PasswordFile:    options.PasswordFile,
+		IPv4:            options.IPv4,
+		IPv6:            options.IPv6,
+		SocketOptions:   options.SocketOptions,
+		Recursive:       true,
+		MkPath:          true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := task.Run(); err != nil {
+		return fmt.Errorf("mkpath %s: %w: %s", remote, err, strings.TrimSpace(task.Log().Stderr))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDaemonPath(t *testing.T) {
+	assert.True(t, isDaemonPath("rsync://host/module/dir"))
+	assert.True(t, isDaemonPath("host::module/dir"))
+	assert.True(t, isDaemonPath("user@host::module"))
+	assert.False(t, isDaemonPath("user@host:/dir"))
+	assert.False(t, isDaemonPath("/local/a::b"))
+	assert.False(t, isDaemonPath("dir"))
+}
+
+func TestEnsureRemoteDir(t *testing.T) {
+	t.Run("local", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, EnsureRemoteDir("a/b", RsyncOptions{WorkDir: dir}))
+		info, err := os.Stat(filepath.Join(dir, "a", "b"))
+		assert.Nil(t, err)
+		assert.True(t, info.IsDir())
+	})
+
+	t.Run("remote shell", func(t *testing.T) {
+		ssh := fakeRsync(t, `echo "$@" > "$(dirname "$0")/args"`)
+		err := EnsureRemoteDir("user@host:/backup/it's/b", RsyncOptions{RemoteShell: RemoteShell{Command: ssh, Args: []string{"-p", "2222"}}})
+		assert.Nil(t, err)
+		args, err := os.ReadFile(filepath.Join(filepath.Dir(ssh), "args"))
+		assert.Nil(t, err)
+		assert.Equal(t, `-p 2222 user@host mkdir -p -- '/backup/it'\''s/b'`+"\n", string(args))
+	})
+
+	t.Run("remote shell failure", func(t *testing.T) {
+		ssh := fakeRsync(t, `echo "mkdir: cannot create directory: Permission denied" >&2; exit 1`)
+		err := EnsureRemoteDir("host:/root/dir", RsyncOptions{Rsh: ssh})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Permission denied")
+	})
+
+	t.Run("daemon", func(t *testing.T) {
+		path := fakeRsync(t, `echo "$@" > "$(dirname "$0")/args"`)
+		assert.Nil(t, EnsureRemoteDir("host::module/a/b", RsyncOptions{RsyncBinaryPath: path, Delete: true}))
+		out, err := os.ReadFile(filepath.Join(filepath.Dir(path), "args"))
+		assert.Nil(t, err)
+		args := string(out)
+		assert.Contains(t, args, "--mkpath")
+		assert.Contains(t, args, "--recursive")
+		assert.NotContains(t, args, "--delete")
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(args), "/ host::module/a/b/"))
+	})
+}
@@ -0,0 +1,101 @@
+package grsync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RemoteShell is the remote shell rsync uses to reach remote hosts, the structured alternative
+// to RsyncOptions.Rsh. Its arguments may contain spaces and quotes, they are quoted the way rsync
+// splits --rsh. Instead of UseSshPass, which passes the password on the command line, it can run
+// e.g. `sshpass -f /path/to/password ssh -o "StrictHostKeyChecking accept-new"`
+type RemoteShell struct {
+	Command string
+	Args    []string
+}
+
+// String returns the remote shell as a --rsh value
+func (s RemoteShell) String() string {
+	quoted := []string{rsyncQuote(s.Command)}
+	for _, arg := range s.Args {
+		quoted = append(quoted, rsyncQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// rsyncQuote single quotes s if rsync would split it
+func rsyncQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, ` '"`) {
+		return s
+	}
+	// a single quote is literal between double quotes
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// ParseRemoteShell splits a --rsh value like rsync does: on spaces outside of single and double
+// quotes, where two double quotes stand for a literal one. Outside of quotes \' is a literal
+// single quote, for values copied from a POSIX shell which close a single quoted word, add \' and
+// reopen it to quote a quote. Other backslashes are kept, e.g. in Windows paths. On error, the
+// arguments up to the unterminated quote are returned
+func ParseRemoteShell(rsh string) (RemoteShell, error) {
+	var (
+		fields         []string
+		field          strings.Builder
+		inField        bool
+		squote, dquote bool
+	)
+	for i := 0; i < len(rsh); i++ {
+		c := rsh[i]
+		switch {
+		case c == ' ' && !squote && !dquote:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+			continue
+		case c == '\\' && !squote && !dquote && i+1 < len(rsh) && rsh[i+1] == '\'':
+			field.WriteByte('\'')
+			i++
+		case c == '\'' && !dquote:
+			squote = !squote
+		case c == '"' && !squote:
+			if dquote && i+1 < len(rsh) && rsh[i+1] == '"' {
+				field.WriteByte('"')
+				i++
+			} else {
+				dquote = !dquote
+			}
+		default:
+			field.WriteByte(c)
+		}
+		inField = true
+	}
+
+	var err error
+	if squote || dquote {
+		err = fmt.Errorf("missing trailing quote in remote shell %q", rsh)
+	} else if inField {
+		fields = append(fields, field.String())
+	}
+
+	var shell RemoteShell
+	if len(fields) > 0 {
+		shell.Command, shell.Args = fields[0], fields[1:]
+	}
+	return shell, err
+}
+
+// remoteShell returns the --rsh value of options, RemoteShell takes precedence over Rsh. rsync
+// doesn't know \', an Rsh using it is quoted again the way rsync splits it
+func (o RsyncOptions) remoteShell() string {
+	if o.RemoteShell.Command != "" {
+		return o.RemoteShell.String()
+	}
+	if strings.Contains(o.Rsh, `\'`) {
+		if shell, err := ParseRemoteShell(o.Rsh); err == nil && shell.Command != "" {
+			return shell.String()
+		}
+	}
+	return o.Rsh
+}
@@ -0,0 +1,42 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteShell(t *testing.T) {
+	shell := RemoteShell{Command: "sshpass", Args: []string{"-f", "/run/secrets/pass word", "ssh", "-o", `ProxyCommand "it's" nc`, ""}}
+	rsh := shell.String()
+	assert.Equal(t, `sshpass -f '/run/secrets/pass word' ssh -o 'ProxyCommand "it'"'"'s" nc' ''`, rsh)
+
+	parsed, err := ParseRemoteShell(rsh)
+	assert.Nil(t, err)
+	assert.Equal(t, shell, parsed)
+
+	parsed, err = ParseRemoteShell(`  ssh  -p 2222 "a ""quoted"" arg"`)
+	assert.Nil(t, err)
+	assert.Equal(t, RemoteShell{Command: "ssh", Args: []string{"-p", "2222", `a "quoted" arg`}}, parsed)
+
+	parsed, err = ParseRemoteShell(`ssh -o 'ProxyCommand=echo '\''it'\''s'\'' | nc %h %p' -i C:\keys\id`)
+	assert.Nil(t, err)
+	assert.Equal(t, RemoteShell{Command: "ssh", Args: []string{"-o", "ProxyCommand=echo 'it's' | nc %h %p", "-i", `C:\keys\id`}}, parsed)
+
+	parsed, err = ParseRemoteShell(`ssh -o 'unterminated`)
+	assert.NotNil(t, err)
+	assert.Equal(t, RemoteShell{Command: "ssh", Args: []string{"-o"}}, parsed)
+}
+
+func TestRemoteShellOption(t *testing.T) {
+	args := getArguments(RsyncOptions{Rsh: "rsh", RemoteShell: RemoteShell{Command: "ssh", Args: []string{"-i", "/keys/my key"}}})
+	assert.Contains(t, args, "ssh -i '/keys/my key'")
+	assert.NotContains(t, args, "rsh")
+
+	// rsync gets the quote of '\'' quoted in a way it understands
+	args = getArguments(RsyncOptions{Rsh: `ssh -o 'User=o'\''brien'`})
+	assert.Contains(t, args, `ssh -o 'User=o'"'"'brien'`)
+
+	cmd := remoteCommand(RemoteShell{Command: "ssh", Args: []string{"-i", "/keys/my key"}}.String(), "host", "true")
+	assert.Equal(t, []string{"ssh", "-i", "/keys/my key", "host", "true"}, cmd.Args)
+}
@@ -0,0 +1,83 @@
+package grsync
+
+import (
+	"time"
+)
+
+// Result is the record of a finished run
+type Result struct {
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	// DryRun is set if the run only simulated the transfer, see State.DryRun
+	DryRun bool `json:"dryRun,omitempty"`
+	// ExitCode is the exit code of the last rsync process, see ExitCodeOf
+	ExitCode ExitCode `json:"exitCode"`
+	// BytesTransferred are the bytes transferred by all attempts of the run
+	BytesTransferred int64 `json:"bytes"`
+	// FilesTransferred is taken from Stats if rsync reported them, otherwise from State.FilesTransferred
+	FilesTransferred int `json:"filesTransferred"`
+	// Stats are only reported with RsyncOptions.Stats
+	Stats Stats `json:"stats"`
+	// Summary is the closing summary rsync prints unless it is quiet
+	Summary Summary `json:"summary"`
+	// Negotiation is what the last attempt negotiated, see Task.Negotiation
+	Negotiation Negotiation `json:"negotiation"`
+	// Warnings are the warnings and errors rsync printed on stderr during the run, the warnings
+	// about skipped deletions it prints on stdout and the failures of HookWarn hooks, classified
+	// by kind. They are separate from the error the run failed with
+	Warnings []Warning `json:"warnings,omitempty"`
+	// FileErrors are the files rsync reported errors for, see Task.FileErrors
+	FileErrors []FileError `json:"fileErrors,omitempty"`
+	// Vanished are the source paths deleted during the transfer, see Task.Vanished
+	Vanished []string `json:"vanished,omitempty"`
+	// DeleteLimit is set if the run hit RsyncOptions.MaxDelete
+	DeleteLimit *DeleteLimit `json:"deleteLimit,omitempty"`
+	// Hooks are the command hooks which ran, see Task.HookResults
+	Hooks []HookResult `json:"hooks,omitempty"`
+	// UnparsedLines is the number of output lines the matchers didn't understand, see
+	// Task.OnUnparsedLine
+	UnparsedLines int `json:"unparsedLines,omitempty"`
+	// Cleanup is set if a cancelled run cleaned up its partial files, see Task.SetCleanupOnCancel
+	Cleanup *CleanupResult `json:"cleanup,omitempty"`
+}
+
+// RunResult is Run returning the record of the run in addition to its error
+func (t *Task) RunResult() (Result, error) {
+	err := t.Run()
+	return t.result(err), err
+}
+
+// result returns the record of the finished run which failed with err
+func (t *Task) result(err error) Result {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	r := Result{
+		StartedAt:        t.startedAt,
+		Duration:         t.finishedAt.Sub(t.startedAt),
+		DryRun:           t.state.DryRun,
+		ExitCode:         ExitCodeOf(err),
+		BytesTransferred: t.state.CumulativeBytes,
+		FilesTransferred: t.state.FilesTransferred,
+		Stats:            t.stats,
+		Summary:          t.summary,
+		Negotiation:      t.negotiation,
+		Warnings:         append([]Warning(nil), t.warnings...),
+		FileErrors:       append([]FileError(nil), t.fileErrors...),
+		Vanished:         t.vanished(),
+		Hooks:            append([]HookResult(nil), t.hookResults...),
+		UnparsedLines:    t.unparsedLines,
+	}
+	if t.cleanup != nil {
+		cleanup := *t.cleanup
+		r.Cleanup = &cleanup
+	}
+	if t.deleteLimit != nil {
+		limit := *t.deleteLimit
+		r.DeleteLimit = &limit
+	}
+	if t.hasStats {
+		r.FilesTransferred = t.stats.RegularFilesTransferred
+	}
+	return r
+}
@@ -0,0 +1,38 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRunResult(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		script := `echo "      1.00M   50%    1.00MB/s    0:00:01 (xfr#1, to-chk=1/2)"
+echo "      2.00M  100%    1.00MB/s    0:00:00 (xfr#2, to-chk=0/2)"
+echo "rsync: some file vanished" >&2`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+
+		result, err := task.RunResult()
+		assert.Nil(t, err)
+		assert.Equal(t, ExitOK, result.ExitCode)
+		assert.Equal(t, int64(2000000), result.BytesTransferred)
+		assert.Equal(t, 2, result.FilesTransferred)
+		assert.Equal(t, []Warning{{Kind: WarningOther, Message: "rsync: some file vanished"}}, result.Warnings)
+		assert.False(t, result.StartedAt.IsZero())
+		assert.True(t, result.Duration > 0)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "cat <<'EOF'\n"+statsOutput+"\nEOF\nexit 23"), Stats: true})
+		assert.Nil(t, err)
+
+		result, err := task.RunResult()
+		assert.NotNil(t, err)
+		assert.Equal(t, ExitPartial, result.ExitCode)
+		assert.Equal(t, 2, result.FilesTransferred)
+		assert.Equal(t, int64(57), result.Stats.BytesReceived)
+		assert.Empty(t, result.Warnings)
+	})
+}
@@ -0,0 +1,64 @@
+package grsync
+
+import (
+	"time"
+)
+
+// ResumeToken is what a new task needs to continue an interrupted run, even in another process:
+// it is meant to be persisted, e.g. as JSON, and passed to ResumeToken.NewTask. Unlike a
+// Checkpoint it needs no store. The definition isn't redacted, see Definition.Redacted
+type ResumeToken struct {
+	Definition Definition `json:"definition"`
+	// PartialDir is RsyncOptions.PartialDir, the directory with the partial files, relative to the
+	// destination unless absolute. It is empty if they are kept in place
+	PartialDir string `json:"partialDir,omitempty"`
+	// BytesCompleted are the bytes the interrupted run transferred over all its attempts
+	BytesCompleted int64 `json:"bytesCompleted"`
+	// Attempts is how often the task has been run, including the interrupted run
+	Attempts      int        `json:"attempts"`
+	Status        TaskStatus `json:"status"`
+	LastError     string     `json:"lastError,omitempty"`
+	InterruptedAt time.Time  `json:"interruptedAt"`
+}
+
+// ResumeToken returns the token continuing the last run if it failed or was cancelled, ok is
+// false while the task is pending or running and after it succeeded
+func (t *Task) ResumeToken() (token ResumeToken, ok bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.status != TaskFailed && t.status != TaskCancelled {
+		return ResumeToken{}, false
+	}
+	token = ResumeToken{
+		Definition:     t.definition,
+		PartialDir:     t.definition.Options.PartialDir,
+		BytesCompleted: t.state.CumulativeBytes,
+		Attempts:       t.attempts,
+		Status:         t.status,
+		InterruptedAt:  t.finishedAt,
+	}
+	token.Definition.Labels = cloneLabels(token.Definition.Labels)
+	if t.err != nil {
+		token.LastError = t.err.Error()
+	}
+	return token, true
+}
+
+// NewTask returns a task continuing the run of the token. Its first attempt keeps and continues
+// the partial files like a retry with RetryPolicy.Resume, see resumeOptions. Attempts continues
+// counting from the token
+func (r ResumeToken) NewTask() (*Task, error) {
+	definition := r.Definition
+	if options := definition.Options; options.PartialDir == "" && !options.Inplace && !options.Append && !options.AppendVerify {
+		definition.Options.Partial = true
+	}
+
+	task, err := definition.NewTask()
+	if err != nil {
+		return nil, err
+	}
+	task.attempts = r.Attempts
+	task.resumeNext = true
+	return task, nil
+}
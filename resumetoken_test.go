@@ -0,0 +1,60 @@
+package grsync
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumeToken(t *testing.T) {
+	script := `echo "      1,000,000  50%  100.00kB/s    0:00:05 (xfr#1, to-chk=1/2)"
+exit 12`
+	task, err := NewTask("a", t.TempDir(), false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetLabels(map[string]string{"job": "nightly"})
+
+	_, ok := task.ResumeToken()
+	assert.False(t, ok)
+	assert.NotNil(t, task.Run())
+
+	token, ok := task.ResumeToken()
+	assert.True(t, ok)
+	assert.Equal(t, TaskFailed, token.Status)
+	assert.Equal(t, int64(1000000), token.BytesCompleted)
+	assert.Equal(t, 1, token.Attempts)
+	assert.NotEmpty(t, token.LastError)
+	assert.False(t, token.InterruptedAt.IsZero())
+	assert.Equal(t, "nightly", token.Definition.Labels["job"])
+
+	data, err := json.Marshal(token)
+	assert.Nil(t, err)
+	var restored ResumeToken
+	assert.Nil(t, json.Unmarshal(data, &restored))
+
+	restored.Definition.Options.RsyncBinaryPath = fakeRsync(t, `case "$*" in *"--no-whole-file "*) ;; *) exit 1;; esac
+case "$*" in *"--partial "*) ;; *) exit 1;; esac`)
+	resumed, err := restored.NewTask()
+	assert.Nil(t, err)
+	assert.True(t, resumed.Definition().Options.Partial)
+	assert.Nil(t, resumed.Run())
+	assert.Equal(t, 2, resumed.Attempts())
+	assert.Equal(t, "nightly", resumed.Labels()["job"])
+
+	_, ok = resumed.ResumeToken()
+	assert.False(t, ok)
+}
+
+func TestResumeTokenPartialDir(t *testing.T) {
+	destination := t.TempDir()
+	token := ResumeToken{
+		Definition: Definition{Source: "a", Destination: destination, Options: RsyncOptions{PartialDir: destination}},
+		PartialDir: destination,
+	}
+	// rsync picks the partial files of the partial dir up, --append-verify would skip changed files
+	token.Definition.Options.RsyncBinaryPath = fakeRsync(t, `case "$*" in *"--append-verify"*) exit 1;; esac`)
+	task, err := token.NewTask()
+	assert.Nil(t, err)
+	assert.False(t, task.Definition().Options.Partial)
+	assert.Nil(t, task.Run())
+}
@@ -0,0 +1,66 @@
+package grsync
+
+import "time"
+
+// RetryPolicy decides whether and when a failed rsync attempt is started again
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one; values below 2 disable retries
+	MaxAttempts int
+	// Delay is the time waited before the first retry
+	Delay time.Duration
+	// Backoff multiplies the delay after every retry; values below 1 keep the delay constant
+	Backoff float64
+	// MaxDelay caps the delay grown by Backoff if set
+	MaxDelay time.Duration
+	// Retryable decides whether an error is worth retrying; by default every error is retried
+	Retryable func(err error) bool
+	// Resume makes retries continue the files a failed attempt transferred partially, see resumeOptions
+	Resume bool
+}
+
+// SetRetryPolicy sets the policy used by Run to retry failed attempts. Cancelled tasks are never retried
+func (t *Task) SetRetryPolicy(policy RetryPolicy) {
+	t.mutex.Lock()
+	t.retryPolicy = policy
+	t.mutex.Unlock()
+}
+
+// shouldRetry reports whether another attempt follows the failed attempt
+func (p RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// delay returns the time to wait before the given attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.Delay
+	for i := 2; i < attempt && p.Backoff > 1; i++ {
+		delay = time.Duration(float64(delay) * p.Backoff)
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// resumeOptions adapts options for a retry continuing partial files. --partial keeps what the
+// failed attempt transferred, in PartialDir if set, and rsync uses it as the basis of the delta
+// transfer, so only the missing and changed blocks are sent again. Local copies skip the delta
+// transfer unless --no-whole-file is set. --append-verify isn't used, it takes every destination
+// file as long as its source for complete and would skip files changed in place. Inplace and
+// Append are left alone
+func resumeOptions(options RsyncOptions) RsyncOptions {
+	if options.Inplace || options.Append || options.AppendVerify {
+		return options
+	}
+	options.Partial = true
+	if !options.WholeFile {
+		options.NoWholeFile = true
+	}
+	return options
+}
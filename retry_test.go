@@ -0,0 +1,120 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Delay: time.Second, Backoff: 2, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, p.delay(2))
+	assert.Equal(t, 2*time.Second, p.delay(3))
+	assert.Equal(t, 4*time.Second, p.delay(4))
+	assert.Equal(t, 5*time.Second, p.delay(5))
+
+	assert.Equal(t, time.Second, RetryPolicy{Delay: time.Second}.delay(4))
+}
+
+func TestTaskRetry(t *testing.T) {
+	t.Run("retries until success", func(t *testing.T) {
+		// fails on the first two invocations
+		counter := filepath.Join(t.TempDir(), "counter")
+		script := `echo x >> ` + counter + `; [ $(wc -l < ` + counter + `) -ge 3 ]`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, Delay: time.Millisecond})
+
+		var retries []int
+		task.AddHooks(Hooks{OnRetry: func(task *Task, attempt int, err error) {
+			assert.NotNil(t, err)
+			retries = append(retries, attempt)
+		}})
+
+		assert.Nil(t, task.Run())
+		assert.Equal(t, []int{2, 3}, retries)
+		assert.Equal(t, 3, task.Attempts())
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+		assert.Nil(t, err)
+		task.SetRetryPolicy(RetryPolicy{MaxAttempts: 3})
+
+		assert.NotNil(t, task.Run())
+		assert.Equal(t, 3, task.Attempts())
+	})
+
+	t.Run("respects retryable", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+		assert.Nil(t, err)
+		task.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool { return false }})
+
+		assert.NotNil(t, task.Run())
+		assert.Equal(t, 1, task.Attempts())
+	})
+
+	t.Run("cancel stops retrying", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+		assert.Nil(t, err)
+		task.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, Delay: time.Hour})
+		task.AddHooks(Hooks{OnRetry: func(task *Task, attempt int, err error) {
+			go task.Cancel()
+		}})
+
+		err = task.Run()
+		assert.NotNil(t, err)
+		assert.Equal(t, TaskCancelled, task.Status())
+		assert.Equal(t, 1, task.Attempts())
+	})
+}
+
+func TestTaskResume(t *testing.T) {
+	// records the arguments of each invocation, fails the first one after transferring 3000 bytes
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := `echo "$*" >> ` + calls + `
+if [ $(wc -l < ` + calls + `) -lt 2 ]; then
+	echo "      3,000   30%    1.00kB/s    0:00:07"
+	exit 30
+fi
+echo "      7,000  100%    1.00kB/s    0:00:00"`
+
+	task, err := NewTask("a", dir, false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, Resume: true})
+	assert.Nil(t, task.Run())
+
+	content, err := ioutil.ReadFile(calls)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.NotContains(t, lines[0], "--append-verify")
+	assert.NotContains(t, lines[1], "--append-verify")
+	assert.Contains(t, lines[1], "--partial")
+	assert.Contains(t, lines[1], "--no-whole-file")
+
+	state := task.State()
+	assert.Equal(t, int64(7000), state.BytesTransferred)
+	assert.Equal(t, int64(10000), state.CumulativeBytes)
+
+	// a second run starts counting from zero
+	assert.Nil(t, ioutil.WriteFile(calls, nil, 0644))
+	assert.Nil(t, task.Run())
+	assert.Equal(t, int64(10000), task.State().CumulativeBytes)
+}
+
+func TestResumeOptions(t *testing.T) {
+	options := resumeOptions(RsyncOptions{PartialDir: ".partial"})
+	assert.True(t, options.Partial)
+	assert.True(t, options.NoWholeFile)
+	// files changed in place must not be taken for complete
+	assert.False(t, options.AppendVerify)
+
+	assert.False(t, resumeOptions(RsyncOptions{WholeFile: true}).NoWholeFile)
+	assert.Equal(t, RsyncOptions{Inplace: true}, resumeOptions(RsyncOptions{Inplace: true}))
+}
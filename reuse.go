@@ -0,0 +1,107 @@
+package grsync
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTaskRunning is returned by Run if the task is already running
+var ErrTaskRunning = errors.New("task is already running")
+
+// Totals are the counters of all runs of a task, e.g. for the reports of long-lived daemons
+type Totals struct {
+	Runs      int `json:"runs"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	// Bytes sums State.CumulativeBytes and Files State.FilesTransferred of the runs
+	Bytes int64 `json:"bytes"`
+	Files int   `json:"files"`
+	// Duration sums the durations of the runs, AverageDuration is their average
+	Duration        time.Duration `json:"duration"`
+	AverageDuration time.Duration `json:"averageDuration"`
+	LastStart       time.Time     `json:"lastStart"`
+}
+
+// Totals returns the counters of the finished runs of the task
+func (t *Task) Totals() Totals {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.totals
+}
+
+// merge adds the counters of other
+func (t *Totals) merge(other Totals) {
+	t.Runs += other.Runs
+	t.Succeeded += other.Succeeded
+	t.Failed += other.Failed
+	t.Cancelled += other.Cancelled
+	t.Bytes += other.Bytes
+	t.Files += other.Files
+	t.Duration += other.Duration
+	if other.LastStart.After(t.LastStart) {
+		t.LastStart = other.LastStart
+	}
+	if t.Runs > 0 {
+		t.AverageDuration = t.Duration / time.Duration(t.Runs)
+	}
+}
+
+// resetRun clears the state of the previous run. The mutex must be held
+func (t *Task) resetRun() {
+	t.startedAt = time.Now()
+	t.finishedAt = time.Time{}
+	t.err = nil
+	*t.state = State{}
+	t.stdoutLog.reset()
+	t.stderrLog.reset()
+	t.avgSpeed = speedAverage{}
+	t.priorBytes = 0
+	t.stats, t.hasStats = Stats{}, false
+	t.summary = Summary{}
+	t.negotiation = Negotiation{}
+	t.warnings = nil
+	t.truncatedLines = 0
+	t.deleteLimit = nil
+	t.deleted = nil
+	t.fileErrors = nil
+	t.manifest = nil
+	t.hookResults = nil
+	t.cleanup = nil
+	t.partialFiles = nil
+	t.unparsedLines = 0
+	t.fileProgress = FileProgress{}
+	t.lastOutput.Store(0)
+	t.lastStateChange = time.Time{}
+	select {
+	case <-t.done:
+		t.done = make(chan struct{})
+	default:
+	}
+}
+
+// countRun adds the finished run to the totals. A cancellation applied to this run, the next
+// run starts uncancelled. The mutex must be held
+func (t *Task) countRun() {
+	run := Totals{
+		Runs:      1,
+		Bytes:     t.state.CumulativeBytes,
+		Files:     t.state.FilesTransferred,
+		Duration:  t.finishedAt.Sub(t.startedAt),
+		LastStart: t.startedAt,
+	}
+	switch t.status {
+	case TaskSucceeded:
+		run.Succeeded = 1
+	case TaskFailed:
+		run.Failed = 1
+	case TaskCancelled:
+		run.Cancelled = 1
+	}
+	t.totals.merge(run)
+
+	if t.cancelled {
+		t.cancelled = false
+		t.cancel = make(chan struct{})
+	}
+}
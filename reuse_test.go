@@ -0,0 +1,97 @@
+package grsync
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskReuse(t *testing.T) {
+	t.Run("resets state and log between runs", func(t *testing.T) {
+		counter := filepath.Join(t.TempDir(), "runs")
+		script := `echo x >> ` + counter + `
+echo "run $(wc -l < ` + counter + `)"
+echo "      1,000 100%    1.00kB/s    0:00:00 (xfr#1, to-chk=0/1)"`
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+
+		assert.Nil(t, task.Run())
+		assert.Contains(t, task.Log().Stdout, "run 1")
+		assert.Equal(t, int64(1000), task.State().CumulativeBytes)
+
+		assert.Nil(t, task.Run())
+		assert.Contains(t, task.Log().Stdout, "run 2")
+		assert.NotContains(t, task.Log().Stdout, "run 1")
+		assert.Equal(t, int64(1000), task.State().CumulativeBytes)
+		totals := task.Totals()
+		assert.Equal(t, 2, totals.Runs)
+		assert.Equal(t, 2, totals.Succeeded)
+		assert.Equal(t, int64(2000), totals.Bytes)
+		assert.Equal(t, 2, totals.Files)
+		assert.Equal(t, task.result(nil).StartedAt, totals.LastStart)
+	})
+
+	t.Run("concurrent run fails", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exec sleep 10")})
+		assert.Nil(t, err)
+
+		done := make(chan error)
+		go func() { done <- task.Run() }()
+		assert.Eventually(t, func() bool { return task.Status() == TaskRunning }, time.Second, 10*time.Millisecond)
+		assert.ErrorIs(t, task.Run(), ErrTaskRunning)
+
+		assert.Nil(t, task.Cancel())
+		assert.NotNil(t, <-done)
+		assert.Equal(t, TaskCancelled, task.Status())
+	})
+
+	t.Run("run fails until the previous one finished", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+		assert.Nil(t, err)
+		var finishErr error
+		task.AddHooks(Hooks{OnComplete: func(task *Task) {
+			// the status is final while the run closes its channels
+			finishErr = task.Run()
+		}})
+
+		assert.Nil(t, task.Run())
+		assert.ErrorIs(t, finishErr, ErrTaskRunning)
+		<-task.Done()
+	})
+
+	t.Run("runs again after cancel", func(t *testing.T) {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+		assert.Nil(t, err)
+
+		assert.Nil(t, task.Cancel())
+		_ = task.Run()
+		assert.Equal(t, TaskCancelled, task.Status())
+
+		assert.Nil(t, task.Run())
+		assert.Equal(t, TaskSucceeded, task.Status())
+		totals := task.Totals()
+		assert.Equal(t, 2, totals.Runs)
+		assert.Equal(t, 1, totals.Succeeded)
+		assert.Equal(t, 1, totals.Cancelled)
+	})
+}
+
+func TestTotalsMerge(t *testing.T) {
+	start := time.Now()
+	var totals Totals
+	totals.merge(Totals{Runs: 1, Succeeded: 1, Bytes: 100, Files: 1, Duration: time.Second, LastStart: start})
+	totals.merge(Totals{Runs: 1, Failed: 1, Bytes: 50, Duration: 3 * time.Second, LastStart: start.Add(-time.Hour)})
+
+	assert.Equal(t, Totals{
+		Runs:            2,
+		Succeeded:       1,
+		Failed:          1,
+		Bytes:           150,
+		Files:           1,
+		Duration:        4 * time.Second,
+		AverageDuration: 2 * time.Second,
+		LastStart:       start,
+	}, totals)
+}
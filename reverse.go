@@ -0,0 +1,117 @@
+package grsync
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Reverse returns a pending task restoring what t transferred: from its destination back to its
+// source, or to target if not empty. The restored files are placed into target like they were
+// placed into the destination, a source without trailing slash is restored into its parent.
+// The options deleting files and the directories compared on the old destination are removed,
+// the other settings are copied like by Clone except for the hooks, the lock and the key of the
+// run store, which belong to the original direction. A task with a lock gets a DestinationLock on
+// the new destination, runs are recorded under the ID of the new task. The paths of the last run
+// are used, with path templates expanded
+func (t *Task) Reverse(target string) (*Task, error) {
+	t.mutex.Lock()
+	definition := t.definition
+	source, destination := t.source, t.destination
+	var err error
+	if source == "" {
+		source, destination, err = t.expandPaths()
+	}
+	t.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	definition.Source, definition.Destination, err = reversePaths(source, destination)
+	if err != nil {
+		return nil, err
+	}
+	if target != "" {
+		definition.Destination = target
+	}
+	definition.Options = reverseOptions(definition.Options)
+
+	reversed, err := t.cloneWith(definition)
+	if err != nil {
+		return nil, err
+	}
+	t.mutex.Lock()
+	locked := t.lock != nil
+	t.mutex.Unlock()
+	reversed.mutex.Lock()
+	reversed.hooks, reversed.preHooks, reversed.postHooks = nil, nil, nil
+	if locked && !definition.LockDestination {
+		reversed.lock = NewDestinationLock(definition.Destination)
+	}
+	reversed.runStoreKey = ""
+	reversed.mutex.Unlock()
+	return reversed, nil
+}
+
+// reversePaths returns the source and destination restoring the transfer from source to
+// destination. The contents of a source with trailing slash are in destination, any other source
+// is in destination under its base name and is restored into its parent
+func reversePaths(source, destination string) (string, string, error) {
+	if hasTrailingSeparator(source) {
+		return destination, source, nil
+	}
+
+	from, err := ParseEndpoint(source)
+	if err != nil {
+		return "", "", err
+	}
+	to, err := ParseEndpoint(destination)
+	if err != nil {
+		return "", "", err
+	}
+	i := strings.LastIndexAny(from.Path, separators(from))
+	parent, name := from.Path[:i+1], from.Path[i+1:]
+	if parent == "" && from.Kind == EndpointLocal {
+		parent = "." + string(separators(from)[0])
+	}
+	if to.Path != "" && !hasTrailingSeparator(to.Path) {
+		to.Path += string(separators(to)[0])
+	}
+	to.Path += name
+	from.Path = parent
+	return endpointString(to), endpointString(from), nil
+}
+
+// separators returns the path separators of endpoint, the first one being the preferred one
+func separators(endpoint Endpoint) string {
+	if endpoint.Kind == EndpointLocal && filepath.Separator != '/' {
+		return string(filepath.Separator) + "/"
+	}
+	return "/"
+}
+
+// hasTrailingSeparator reports whether path ends with a separator
+func hasTrailingSeparator(path string) bool {
+	return strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator))
+}
+
+// endpointString returns the endpoint as rsync expects it, local paths unchanged
+func endpointString(endpoint Endpoint) string {
+	if endpoint.Kind == EndpointLocal {
+		return endpoint.Path
+	}
+	return endpoint.String()
+}
+
+// reverseOptions returns options without the options deleting files or comparing against
+// directories next to the old destination
+func reverseOptions(options RsyncOptions) RsyncOptions {
+	options.Delete = false
+	options.DeleteTiming = ""
+	options.DeleteBefore, options.DeleteDuring, options.DeleteDelay, options.DeleteAfter = false, false, false, false
+	options.DeleteExcluded = false
+	options.DeleteMissingArgs = false
+	options.MaxDelete = 0
+	options.RemoveSourceFiles = false
+	options.CompareDest, options.CopyDest, options.LinkDest = "", "", ""
+	return options
+}
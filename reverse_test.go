@@ -0,0 +1,85 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReversePaths(t *testing.T) {
+	for _, test := range []struct {
+		source, destination  string
+		restored, restoredTo string
+	}{
+		{"/data/", "/backup/", "/backup/", "/data/"},
+		{"/data/photos", "/backup/", "/backup/photos", "/data/"},
+		{"/data/photos", "/backup", "/backup/photos", "/data/"},
+		{"photos", "/backup/", "/backup/photos", "./"},
+		{"/etc/hosts", "user@host:backup/", "user@host:backup/hosts", "/etc/"},
+		{"/data/photos", "rsync://host/backups", "rsync://host/backups/photos", "/data/"},
+		{"host::module/photos", "/backup/", "/backup/photos", "rsync://host/module"},
+	} {
+		restored, restoredTo, err := reversePaths(test.source, test.destination)
+		assert.Nil(t, err)
+		assert.Equal(t, test.restored, restored, test.source)
+		assert.Equal(t, test.restoredTo, restoredTo, test.source)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	task, err := NewTask("/data/photos", "/backup/", false, true, RsyncOptions{
+		Archive:           true,
+		Delete:            true,
+		DeleteTiming:      DeleteAfter,
+		DeleteExcluded:    true,
+		MaxDelete:         100,
+		RemoveSourceFiles: true,
+		LinkDest:          "../previous",
+		Exclude:           []string{"*.tmp"},
+	})
+	assert.Nil(t, err)
+	task.SetLabels(map[string]string{"job": "photos"})
+	assert.Nil(t, task.AddPreHook(CommandHook{Command: []string{"snapshot"}}))
+	task.SetRetryPolicy(RetryPolicy{MaxAttempts: 3})
+	task.SetLock(NewDestinationLock("/backup/"))
+	task.SetRunStore(&MemoryRunStore{}, "photos-backup")
+
+	restore, err := task.Reverse("")
+	assert.Nil(t, err)
+	definition := restore.Definition()
+	assert.NotEqual(t, task.ID(), restore.ID())
+	assert.Equal(t, "/backup/photos", definition.Source)
+	assert.Equal(t, "/data/", definition.Destination)
+	assert.True(t, definition.CreateDir)
+	assert.Equal(t, map[string]string{"job": "photos"}, definition.Labels)
+	assert.Empty(t, destructiveFlags(definition.Options))
+	assert.Equal(t, 0, definition.Options.MaxDelete)
+	assert.Empty(t, definition.Options.LinkDest)
+	assert.True(t, definition.Options.Archive)
+	assert.Equal(t, []string{"*.tmp"}, definition.Options.Exclude)
+	assert.Empty(t, restore.preHooks)
+	assert.Equal(t, 3, restore.retryPolicy.MaxAttempts)
+	assert.Equal(t, NewDestinationLock("/data/"), restore.lock)
+	assert.Equal(t, task.runStore, restore.runStore)
+	assert.Empty(t, restore.runStoreKey)
+
+	restore, err = task.Reverse("/restore/")
+	assert.Nil(t, err)
+	assert.Equal(t, "/backup/photos", restore.Definition().Source)
+	assert.Equal(t, "/restore/", restore.Definition().Destination)
+
+	// the original is unchanged
+	assert.True(t, task.Definition().Options.Delete)
+	assert.Len(t, task.preHooks, 1)
+}
+
+func TestReverseExpandedPaths(t *testing.T) {
+	task, err := NewTask("/data/", "/backup/{{.Date}}/", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "")})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+
+	restore, err := task.Reverse("")
+	assert.Nil(t, err)
+	assert.Equal(t, "/backup/"+task.startedAt.Format("2006-01-02")+"/", restore.Definition().Source)
+	assert.Equal(t, "/data/", restore.Definition().Destination)
+}
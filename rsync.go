@@ -1,33 +1,80 @@
 package grsync
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 )
 
+// ErrNotStarted is returned when trying to stop a rsync command that has not been started
+var ErrNotStarted = errors.New("rsync command has not been started")
+
 // Rsync is wrapper under rsync
 type Rsync struct {
 	Source      string
 	Destination string
 	CreateDir   bool
+	// WorkDir is the working directory of rsync, see RsyncOptions.WorkDir
+	WorkDir string
 
-	cmd *exec.Cmd
+	command Command
+	process Process
+	runner  CommandRunner
 }
 
 // RsyncOptions for rsync
 type RsyncOptions struct {
 	// RsyncBinaryPath is a path to the rsync binary; by default just `rsync`
 	RsyncBinaryPath string
+	// Flavor is the kind of rsync build at RsyncBinaryPath, which decides how local paths are passed to it
+	Flavor RsyncFlavor
+	// Locale is set as LC_ALL and LANG of the rsync process, by default DefaultLocale so its output
+	// can be parsed. InheritLocale keeps the locale of the environment
+	Locale string
+	// Env are additional "KEY=value" variables of the rsync process and the remote shell it starts,
+	// e.g. RSYNC_PASSWORD or SSH_AUTH_SOCK. They take precedence over the inherited ones and Locale
+	Env []string
+	// ClearEnv starts rsync with only Env and Locale instead of inheriting the current environment.
+	// Note that ssh may need HOME and PATH then
+	ClearEnv bool
+	// WorkDir is the working directory of the rsync process, against which relative local paths
+	// like the source, the destination, FilesFrom and LogFile are resolved. By default the current one
+	WorkDir string
+	// Priority lowers the CPU and I/O priority of the rsync process
+	Priority Priority
+	// Limits restrict the resources of the rsync process
+	Limits ResourceLimits
+	// Credential runs the rsync process as another local user, see LookupCredential. ssh reads
+	// the keys and known hosts of HOME, which Env may need to point to the home of the user
+	Credential *Credential
 	// RsyncPath specify the rsync to run on remote machine, e.g `--rsync-path="cd /a/b && rsync"`
+	// or `--rsync-path="sudo rsync"` to read files only root may access on the remote host, see RemoteSudo
 	RsyncPath string
+	// ArgProtection selects how remote file names are passed to the remote rsync, see ArgsOld
+	ArgProtection ArgProtection
+	// RemoteSudo runs the remote rsync through sudo, so a normal ssh user can pull files only root
+	// may read or, with Super, push files owned by other users. RsyncPath, if set, is the rsync
+	// sudo runs. Without RemoteSudoPasswordFile sudo must not ask for a password, e.g. because of a
+	// NOPASSWD rule for rsync in sudoers. Daemon paths are rejected with ErrRemoteSudoDaemon
+	RemoteSudo bool
+	// RemoteSudoUser is the user sudo runs rsync as, by default root
+	RemoteSudoUser string
+	// RemoteSudoPasswordFile is a local file with the sudo password of the ssh user on its first
+	// line. It is written to sudo through the remote shell before rsync starts; sudo then must ask
+	// for the password, a NOPASSWD rule would leave it to rsync and break the transfer
+	RemoteSudoPasswordFile string
 	// Verbose increase verbosity
 	Verbose bool
 	// Quet suppress non-error messages
 	Quiet bool
+	// Comparison selects how changed files are detected, e.g. CompareSizeOnly. Only one comparison
+	// can be selected, either by Comparison or by Checksum, IgnoreTimes and SizeOnly
+	Comparison Comparison
 	// Checksum skip based on checksum, not mod-time & size
 	Checksum bool
 	// Archve is archive mode; equals -rlptgoD (no -H,-A,-X)
@@ -60,7 +107,10 @@ type RsyncOptions struct {
 	CopyDirLinks bool
 	// KeepDirLinks treat symlinked dir on receiver as dir
 	KeepDirLinks bool
-	// HardLinks preserve hard links
+	// Symlinks is the symlink handling as a whole, merged with the options above
+	Symlinks SymlinkPolicy
+	// HardLinks preserve hard links, e.g. of maildirs and package mirrors. With Stats the preserved
+	// links are counted in Stats.HardLinks
 	HardLinks bool
 	// Perms preserve permissions
 	Perms bool
@@ -74,6 +124,14 @@ type RsyncOptions struct {
 	ACLs bool
 	// XAttrs preserve extended attributes
 	XAttrs bool
+	// Atimes --atimes, preserve access times, rsync 3.2.0 and later
+	Atimes bool
+	// Crtimes --crtimes, preserve creation times where the file systems support them, e.g. on
+	// macOS. rsync must be built with crtimes
+	Crtimes bool
+	// OpenNoatime --open-noatime, open the source files without updating their access times, rsync
+	// 3.2.0 and later
+	OpenNoatime bool
 	// Owner preserve owner (super-user only)
 	Owner bool
 	// NoOwner prevent copying owner information to destination
@@ -84,30 +142,63 @@ type RsyncOptions struct {
 	NoGroup bool
 	// Devices preserve device files (super-user only)
 	Devices bool
+	// NoDevices --no-devices, don't preserve the device files Archive includes
+	NoDevices bool
 	// Specials preserve special files
 	Specials bool
+	// NoSpecials --no-specials, don't preserve the special files like sockets and FIFOs Archive includes
+	NoSpecials bool
+	// CopyDevices --copy-devices, copy the contents of devices as regular files, e.g. to back up a
+	// block device into an image file. rsync 3.2.0 and later
+	CopyDevices bool
+	// WriteDevices --write-devices, write the contents of files into the existing devices of the
+	// receiver, e.g. to restore an image onto a block device. It implies Inplace. rsync 3.2.0 and later
+	WriteDevices bool
 	// Times preserve modification times
 	Times bool
 	// NoTimes prevent copying modification times
 	NoTimes bool
 	// omit directories from --times
 	OmitDirTimes bool
-	// Super receiver attempts super-user activities
+	// Super receiver attempts super-user activities, like setting the owner of files. Pushing to a
+	// remote host with RemoteSudo usually needs it
 	Super bool
-	// FakeSuper store/recover privileged attrs using xattrs
+	// FakeSuper store/recover privileged attrs using xattrs, so backups to a destination written
+	// by an unprivileged user keep the ownership, devices and special files. The receiving rsync
+	// needs xattr support, a local rsync built without it is rejected with ErrFakeSuperUnsupported
 	FakeSuper bool
 	// Sparce handle sparse files efficiently
 	Sparse bool
+	// Preallocate --preallocate, allocate the destination files before writing them, which avoids
+	// fragmentation and fails early on a full disk. rsync 3.1.0 and later, built with prealloc
+	Preallocate bool
+	// Fsync --fsync, flush every file to disk before it counts as transferred, e.g. for removable
+	// media or network file systems. rsync 3.2.4 and later
+	Fsync bool
 	// DryRun perform a trial run with no changes made
 	DryRun bool
-	// WholeFile copy files whole (w/o delta-xfer algorithm)
+	// WholeFile copy files whole (w/o delta-xfer algorithm), which is faster on a LAN and the
+	// default for local copies
 	WholeFile bool
-	// OneFileSystem don't cross filesystem boundaries
+	// NoWholeFile --no-whole-file, use the delta-xfer algorithm also for local copies, e.g. for a
+	// destination on a slow network file system
+	NoWholeFile bool
+	// Preset tunes the delta-xfer options for the link to the destination, see PresetWAN
+	Preset Preset
+	// OneFileSystem don't cross filesystem boundaries, so a backup of / skips /proc, /sys and
+	// mounted network shares. The mount points are still created as empty directories
 	OneFileSystem bool
-	// BlockSize block-size=SIZE force a fixed checksum block-size
+	// SkipMountPoints passes --one-file-system twice to skip the mount points themselves as well
+	SkipMountPoints bool
+	// BlockSize block-size=SIZE force a fixed checksum block-size, at most MaxBlockSize
 	BlockSize int
 	// Rsh -rsh=COMMAND specify the remote shell to use
 	Rsh string
+	// RemoteShell is Rsh with separate arguments, which takes precedence if set
+	RemoteShell RemoteShell
+	// BlockingIO --blocking-io, use blocking I/O for the remote shell, e.g. for remote shells like
+	// rsh which don't handle non-blocking I/O. It doesn't apply to daemon connections
+	BlockingIO bool
 	// Existing skip creating new files on receiver
 	Existing bool
 	// IgnoreExisting skip updating files that exist on receiver
@@ -116,6 +207,9 @@ type RsyncOptions struct {
 	RemoveSourceFiles bool
 	// Delete delete extraneous files from dest dirs
 	Delete bool
+	// DeleteTiming selects when extraneous files are deleted, e.g. DeleteAfter. Only one timing can be
+	// selected, either by DeleteTiming or the flags below
+	DeleteTiming DeleteTiming
 	// DeleteBefore receiver deletes before transfer, not during
 	DeleteBefore bool
 	// DeleteDuring receiver deletes during the transfer
@@ -126,11 +220,16 @@ type RsyncOptions struct {
 	DeleteAfter bool
 	// DeleteExcluded also delete excluded files from dest dirs
 	DeleteExcluded bool
-	// IgnoreErrors delete even if there are I/O errors
+	// IgnoreErrors delete even if there are I/O errors. Without it rsync skips the deletions after
+	// an I/O error, which is reported as a warning, see Result.Warnings
 	IgnoreErrors bool
-	// Force deletion of dirs even if not empty
+	// Force deletion of dirs even if not empty. Without it every kept directory is reported as a
+	// FileError with the reason "cannot delete non-empty directory"
 	Force bool
-	// MaxDelete max-delete=NUM don't delete more than NUM files
+	// DeleteMissingArgs --delete-missing-args, delete the names given with FilesFrom which are missing on the sender
+	DeleteMissingArgs bool
+	// MaxDelete max-delete=NUM don't delete more than NUM files, which protects a mirror from mass
+	// deletion when the source temporarily looks empty. See Task.DeleteLimit
 	MaxDelete int
 	// MaxSize max-size=SIZE don't transfer any file larger than SIZE
 	MaxSize int
@@ -144,18 +243,22 @@ type RsyncOptions struct {
 	DelayUpdates bool
 	// PruneEmptyDirs prune empty directory chains from file-list
 	PruneEmptyDirs bool
-	// NumericIDs don't map uid/gid values by user/group name
+	// NumericIDs don't map uid/gid values by user/group name, which keeps the ownership of backups
+	// intact on a destination that lacks the users of the source
 	NumericIDs bool
-	// Timeout: timeout=SECONDS set I/O timeout in seconds
+	// Timeout: timeout=SECONDS set I/O timeout in seconds. rsync exits with ExitTimeout when no data
+	// was transferred for this long, see Task.SetStallTimeout for a watchdog on the output
 	Timeout int
-	// Contimeout: contimeout=SECONDS set daemon connection timeout in seconds
+	// Contimeout: contimeout=SECONDS set daemon connection timeout in seconds, rsync exits with
+	// ExitConnectTimeout if the daemon doesn't accept the connection in time
 	Contimeout int
 	// IgnoreTimes don't skip files that match size and time
 	IgnoreTimes bool
 	// SizeOnly skip files that match in size
 	SizeOnly bool
-	// ModifyWindow modify-window=NUM compare mod-times with reduced accuracy
-	ModifyWindow bool
+	// ModifyWindow --modify-window=NUM, treat mod-times differing by up to NUM seconds as equal,
+	// e.g. FATModifyWindow for FAT and exFAT drives
+	ModifyWindow int
 	// TempDir temp-dir=DIR create temporary files in directory DIR
 	TempDir string
 	// Fuzzy find similar file for basis if no dest file
@@ -172,66 +275,168 @@ type RsyncOptions struct {
 	CompressLevel int
 	// SkipCompress skip-compress=LIST skip compressing files with suffix in LIST
 	SkipCompress []string
-	// CVSExclude auto-ignore files in the same way CVS does
+	// CVSExclude auto-ignore files in the same way CVS does, e.g. *.o, core and the files listed
+	// in .cvsignore
 	CVSExclude bool
+	// GitIgnore excludes the files matched by the .gitignore of each directory. rsync reads them
+	// with its own pattern rules, which differ from git for negations and patterns containing a
+	// slash; IgnoreFiles translates them exactly. .git itself is transferred unless excluded
+	GitIgnore bool
+	// IgnoreFiles are local .gitignore-style files translated into filter rules, see GitIgnoreRules.
+	// They apply after Include, Exclude and Filter
+	IgnoreFiles []string
 	// Stats give some file-transfer stats
 	Stats bool
 	// HumanReadable output numbers in a human-readable format
 	HumanReadable bool
+	// HumanReadableIEC passes --human-readable twice, rsync then outputs numbers in units of 1024 instead of 1000
+	HumanReadableIEC bool
+	// NoHumanReadable --no-human-readable, output plain numbers without digit grouping
+	NoHumanReadable bool
 	// Progress show progress during transfer
 	Progress bool
 	// Read daemon-access password from FILE
 	PasswordFile string
 	// limit socket I/O bandwidth
 	BandwidthLimit int
+	// Bandwidth --bwlimit in bytes per second, e.g. parsed from "2.5m" by ParseBandwidth. It
+	// replaces BandwidthLimit, which is in units of 1024 bytes, if set
+	Bandwidth Bandwidth
 	// Info
 	Info string
+	// InfoFlags --info, fine-grained info categories added to Info. Tasks raise progress and, with
+	// file events, name to level 1 since their parser relies on those lines
+	InfoFlags OutputFlags
+	// Debug --debug, debug categories like "del2,filter"
+	Debug string
+	// DebugFlags --debug, fine-grained debug categories added to Debug. Their output is mostly
+	// classified as LineDebug, see SetLogCategories to keep it out of the log
+	DebugFlags OutputFlags
+	// Msgs2Stderr --msgs2stderr, print the messages like file names, progress and statistics on
+	// stderr instead of stdout. Tasks parse them on stderr then
+	Msgs2Stderr bool
+	// OutBuf --outbuf, set the output buffering to "N" (none), "L" (line) or "B" (block)
+	OutBuf string
+	// UsePty runs rsync on a pseudo-terminal, so it prints its output like to an interactive
+	// terminal even if the program runs as a service or in a pipe: unbuffered, with progress updates
+	// rewriting the line. The terminal merges stderr into stdout, tasks tell the warnings apart by
+	// their prefix. script(1) must be on the PATH, it isn't supported on Windows
+	UsePty bool
 	// Exclude --exclude="", exclude remote paths.
 	Exclude []string
 	// Include --include="", include remote paths.
 	Include []string
+	// ExcludeFrom --exclude-from=FILE, read exclude patterns from FILE, "-" reads them from the
+	// input set with Task.SetStdin
+	ExcludeFrom string
+	// IncludeFrom --include-from=FILE, read include patterns from FILE, "-" reads them like ExcludeFrom
+	IncludeFrom string
 	// Filter --filter="", include filter rule.
 	Filter string
+	// DirsOnly transfers the directory tree without any files by adding --include="*/" and
+	// --exclude="*" after Include, Exclude and Filter, e.g. to create the tree before sharded
+	// transfers fill it in parallel. Needs Recursive or Archive, PruneEmptyDirs would prune the
+	// whole tree
+	DirsOnly bool
 	// Chown --chown="", chown on receipt.
 	Chown string
+	// CopyAs --copy-as=USER[:GROUP], write the files on the receiving side as USER and GROUP, e.g.
+	// when rsync runs as root for a destination owned by another user. rsync 3.2.0 and later
+	CopyAs string
+	// Iconv --iconv=LOCAL,REMOTE, convert file names between the charsets of the local and the remote
+	// side, e.g. "UTF-8-MAC,UTF-8" from macOS to Linux. "." stands for the charset of the locale
+	Iconv string
 	// ListOnly --list-only, list the files instead of copying them.
 	ListOnly bool
-
-	// ipv4
+	// NoMotd --no-motd, suppress the message of the day of daemons
+	NoMotd bool
+	// MkPath --mkpath, create the missing directories of the destination path, rsync 3.2.3 and later
+	MkPath bool
+	// FilesFrom --files-from=FILE, read the names of the files to transfer from FILE. "-" reads them
+	// from the input set with Task.SetStdin, without a temporary file
+	FilesFrom string
+	// WriteBatch --write-batch=FILE, also record the transfer in FILE, which ReadBatch replays to
+	// other destinations with the same contents. rsync writes a script replaying it to FILE.sh
+	WriteBatch string
+	// OnlyWriteBatch --only-write-batch=FILE, like WriteBatch without updating the destination
+	OnlyWriteBatch string
+	// ReadBatch --read-batch=FILE, apply the transfer recorded in FILE instead of reading a source,
+	// so the source of the task must be empty
+	ReadBatch string
+	// LogFile --log-file=FILE, log what rsync is doing to the specified FILE.
+	LogFile string
+	// LogFileFormat --log-file-format=FMT, log updates using the specified format. Tasks use
+	// StructuredLogFileFormat if LogFile is set and LogFileFormat is empty, see Task.LogRecords
+	LogFileFormat string
+
+	// IPv4 --ipv4, connect to daemons and remote shells over IPv4 only
 	IPv4 bool
-	// ipv6
+	// IPv6 --ipv6, connect over IPv6 only. It can't be combined with IPv4
 	IPv6 bool
+	// SocketOptions tune the socket of daemon connections
+	SocketOptions SocketOptions
 
 	//out-format
 	OutFormat bool
+	// CustomOutFormat --out-format=FORMAT, e.g. "%t %o %i %'l %b %n". It replaces OutFormat. Tasks
+	// listening for file events keep it and parse its lines into the events, see OutFormatFields
+	CustomOutFormat string
+	// OutFormatFields maps the verbs of CustomOutFormat without "%", e.g. "l", to the FileEvent fields
+	// they fill. By default n and f are the path, i the itemized changes, o the operation, l the size,
+	// b the bytes transferred, M the modification time and C the checksum, the others are skipped
+	OutFormatFields map[string]OutFormatField
+
+	// partitionRules are the filter rules of a ParallelTask excluding the entries of the other
+	// processes, they precede all other filter rules
+	partitionRules []string
+	// ignoreRules are the filter rules translated from IgnoreFiles
+	ignoreRules []string
+	// stdin is the input of rsync set with Task.SetStdin
+	stdin io.Reader
+	// stdinRules are the filter rules passed on stdin since the command line is too long, see
+	// fitArguments
+	stdinRules []string
+	// argvHook is the hook set with Task.SetArgvHook
+	argvHook ArgvHook
 }
 
 // StdoutPipe returns a pipe that will be connected to the command's
 // standard output when the command starts.
 func (r Rsync) StdoutPipe() (io.ReadCloser, error) {
-	return r.cmd.StdoutPipe()
+	return r.process.StdoutPipe()
 }
 
 // StderrPipe returns a pipe that will be connected to the command's
 // standard error when the command starts.
 func (r Rsync) StderrPipe() (io.ReadCloser, error) {
-	return r.cmd.StderrPipe()
+	return r.process.StderrPipe()
 }
 
 // Start starts a rsync command
 func (r Rsync) Start() error {
-	if !isExist(r.Destination) && r.CreateDir {
-		if err := createDir(r.Destination); err != nil {
+	destination := resolvePath(r.WorkDir, r.Destination)
+	if !isExist(destination) && r.CreateDir {
+		if err := createDir(destination); err != nil {
 			return err
 		}
 	}
 
-	return r.cmd.Start()
+	return r.process.Start()
 }
 
 // Wait waits for rsync command to finnish
 func (r Rsync) Wait() error {
-	return r.cmd.Wait()
+	return r.process.Wait()
+}
+
+// Kill stops a started rsync command
+func (r Rsync) Kill() error {
+	return r.process.Signal(os.Kill)
+}
+
+// Signal sends sig to a started rsync command
+func (r Rsync) Signal(sig os.Signal) error {
+	return r.process.Signal(sig)
 }
 
 // Run start rsync task. The method is kept here for backward compatibility
@@ -248,16 +453,72 @@ func (r Rsync) Run() error {
 // and passed to the rsync command using sshpass. sshpass needs to be available.
 // If createDir is set to true, the destination will be created if it does not exist.
 func NewRsync(source, destination string, useSshPass, createDir bool, options RsyncOptions) (*Rsync, error) {
-	arguments := append(getArguments(options), source, destination)
+	return newRsync(source, destination, useSshPass, createDir, options, nil, ExecRunner{})
+}
+
+// newRsync is NewRsync with additional arguments placed after the ones derived from options,
+// whose process is created by runner
+func newRsync(source, destination string, useSshPass, createDir bool, options RsyncOptions, extraArguments []string, runner CommandRunner) (*Rsync, error) {
+	if err := checkDeleteTiming(options); err != nil {
+		return nil, err
+	}
+	if err := checkComparison(options); err != nil {
+		return nil, err
+	}
+	if err := checkBlockSize(options); err != nil {
+		return nil, err
+	}
+	if err := checkArgProtection(options); err != nil {
+		return nil, err
+	}
+	if err := checkAddressFamily(options); err != nil {
+		return nil, err
+	}
+	if err := checkDSCP(options); err != nil {
+		return nil, err
+	}
+	if err := checkCredential(options); err != nil {
+		return nil, err
+	}
+	if err := checkOutFormat(options); err != nil {
+		return nil, err
+	}
+	if _, err := options.symlinkPolicy(); err != nil {
+		return nil, err
+	}
+	options, err := options.Preset.apply(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.ignoreRules, err = ignoreFileRules(options.IgnoreFiles, options.WorkDir); err != nil {
+		return nil, err
+	}
+	if options, err = sudoOptions(options, source, destination); err != nil {
+		return nil, err
+	}
+
+	flavor := options.Flavor.resolve()
+	arguments := commandArguments(source, destination, options, extraArguments, flavor)
+	if options, err = fitArguments(options, arguments); err != nil {
+		return nil, err
+	}
+	if len(options.stdinRules) > 0 {
+		arguments = commandArguments(source, destination, options, extraArguments, flavor)
+	}
 
 	binaryPath := "rsync"
 	if options.RsyncBinaryPath != "" {
 		binaryPath = options.RsyncBinaryPath
+	} else if flavor == FlavorWSL {
+		binaryPath = "wsl"
+		arguments = append([]string{"rsync"}, arguments...)
 	}
 
 	if useSshPass {
-		cmd := exec.Command("/usr/bin/cat", options.PasswordFile)
-		out, err := cmd.Output()
+		if runtime.GOOS == "windows" {
+			return nil, ErrSshPassUnsupported
+		}
+		out, err := os.ReadFile(resolvePath(options.WorkDir, options.PasswordFile))
 		if err != nil {
 			return nil, err
 		}
@@ -282,12 +543,63 @@ func NewRsync(source, destination string, useSshPass, createDir bool, options Rs
 		arguments = newArgs
 	}
 
-	return &Rsync{
+	if err := checkMetadata(options, runner); err != nil {
+		return nil, err
+	}
+
+	if options.stdin != nil && options.UsePty {
+		return nil, ErrStdinPty
+	}
+	binaryPath, arguments, err = wrapPty(options.UsePty, binaryPath, arguments)
+	if err != nil {
+		return nil, err
+	}
+	binaryPath, arguments, err = wrapPriority(options.Priority, binaryPath, arguments)
+	if err != nil {
+		return nil, err
+	}
+	binaryPath, arguments, err = wrapLimits(options.Limits, binaryPath, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	rsync := &Rsync{
 		Source:      source,
 		Destination: destination,
 		CreateDir:   createDir,
-		cmd:         exec.Command(binaryPath, arguments...),
-	}, nil
+		WorkDir:     options.WorkDir,
+		command: Command{
+			Name:       binaryPath,
+			Args:       arguments,
+			Env:        processEnv(options),
+			Dir:        options.WorkDir,
+			Credential: options.Credential,
+			Stdin:      options.stdin,
+		},
+		runner: runner,
+	}
+	if options.argvHook != nil {
+		if err := rsync.SetArgvHook(options.argvHook); err != nil {
+			return nil, err
+		}
+		return rsync, nil
+	}
+	rsync.process = runner.NewProcess(rsync.command)
+	return rsync, nil
+}
+
+// commandArguments returns the arguments of rsync transferring source to destination
+func commandArguments(source, destination string, options RsyncOptions, extraArguments []string, flavor RsyncFlavor) []string {
+	arguments := append(getArguments(translateOptions(options, flavor)), extraArguments...)
+	if options.ReadBatch == "" {
+		// a replayed batch has no source
+		arguments = append(arguments, TranslatePath(source, flavor))
+	}
+	if destination != "" || !options.ListOnly {
+		// a listing doesn't need a destination
+		arguments = append(arguments, TranslatePath(destination, flavor))
+	}
+	return arguments
 }
 
 func getArguments(options RsyncOptions) []string {
@@ -297,12 +609,12 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--rsync-path", options.RsyncPath)
 	}
 
-	if options.Verbose {
-		arguments = append(arguments, "--verbose")
+	if options.ArgProtection == ArgsSecluded {
+		arguments = append(arguments, "--protect-args")
 	}
 
-	if options.Checksum {
-		arguments = append(arguments, "--checksum")
+	if options.Verbose {
+		arguments = append(arguments, "--verbose")
 	}
 
 	if options.Quiet {
@@ -349,25 +661,9 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--links")
 	}
 
-	if options.CopyLinks {
-		arguments = append(arguments, "--copy-links")
-	}
-
-	if options.CopyUnsafeLinks {
-		arguments = append(arguments, "--copy-unsafe-links")
-	}
-
-	if options.SafeLinks {
-		arguments = append(arguments, "--safe-links")
-	}
-
-	if options.CopyDirLinks {
-		arguments = append(arguments, "--copy-dir-links")
-	}
-
-	if options.KeepDirLinks {
-		arguments = append(arguments, "--keep-dir-links")
-	}
+	// conflicts are rejected by newRsync
+	symlinks, _ := options.symlinkPolicy()
+	arguments = append(arguments, symlinks.arguments(options.Links)...)
 
 	if options.HardLinks {
 		arguments = append(arguments, "--hard-links")
@@ -393,6 +689,18 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--xattrs")
 	}
 
+	if options.Atimes {
+		arguments = append(arguments, "--atimes")
+	}
+
+	if options.Crtimes {
+		arguments = append(arguments, "--crtimes")
+	}
+
+	if options.OpenNoatime {
+		arguments = append(arguments, "--open-noatime")
+	}
+
 	if options.Owner {
 		arguments = append(arguments, "--owner")
 	}
@@ -413,10 +721,26 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--devices")
 	}
 
+	if options.NoDevices {
+		arguments = append(arguments, "--no-devices")
+	}
+
 	if options.Specials {
 		arguments = append(arguments, "--specials")
 	}
 
+	if options.NoSpecials {
+		arguments = append(arguments, "--no-specials")
+	}
+
+	if options.CopyDevices {
+		arguments = append(arguments, "--copy-devices")
+	}
+
+	if options.WriteDevices {
+		arguments = append(arguments, "--write-devices")
+	}
+
 	if options.Times {
 		arguments = append(arguments, "--times")
 	}
@@ -441,6 +765,14 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--sparse")
 	}
 
+	if options.Preallocate {
+		arguments = append(arguments, "--preallocate")
+	}
+
+	if options.Fsync {
+		arguments = append(arguments, "--fsync")
+	}
+
 	if options.DryRun {
 		arguments = append(arguments, "--dry-run")
 	}
@@ -449,7 +781,15 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--whole-file")
 	}
 
-	if options.OneFileSystem {
+	if options.NoWholeFile {
+		arguments = append(arguments, "--no-whole-file")
+	}
+
+	if options.OneFileSystem || options.SkipMountPoints {
+		arguments = append(arguments, "--one-file-system")
+	}
+
+	if options.SkipMountPoints {
 		arguments = append(arguments, "--one-file-system")
 	}
 
@@ -457,8 +797,12 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--block-size", strconv.Itoa(options.BlockSize))
 	}
 
-	if options.Rsh != "" {
-		arguments = append(arguments, "--rsh", options.Rsh)
+	if rsh := options.remoteShell(); rsh != "" {
+		arguments = append(arguments, "--rsh", rsh)
+	}
+
+	if options.BlockingIO {
+		arguments = append(arguments, "--blocking-io")
 	}
 
 	if options.Existing {
@@ -477,20 +821,8 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--delete")
 	}
 
-	if options.DeleteBefore {
-		arguments = append(arguments, "--delete-before")
-	}
-
-	if options.DeleteDuring {
-		arguments = append(arguments, "--delete-during")
-	}
-
-	if options.DeleteDelay {
-		arguments = append(arguments, "--delete-delay")
-	}
-
-	if options.DeleteAfter {
-		arguments = append(arguments, "--delete-after")
+	for _, timing := range options.deleteTimings() {
+		arguments = append(arguments, "--delete-"+string(timing))
 	}
 
 	if options.DeleteExcluded {
@@ -505,6 +837,10 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--force")
 	}
 
+	if options.DeleteMissingArgs {
+		arguments = append(arguments, "--delete-missing-args")
+	}
+
 	if options.MaxDelete > 0 {
 		arguments = append(arguments, "--max-delete", strconv.Itoa(options.MaxDelete))
 	}
@@ -545,16 +881,12 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--contimeout", strconv.Itoa(options.Contimeout))
 	}
 
-	if options.IgnoreTimes {
-		arguments = append(arguments, "--ignore-times")
-	}
-
-	if options.SizeOnly {
-		arguments = append(arguments, "--size-only")
+	for _, comparison := range options.comparisons() {
+		arguments = append(arguments, "--"+string(comparison))
 	}
 
-	if options.ModifyWindow {
-		arguments = append(arguments, "--modify-window")
+	if options.ModifyWindow > 0 {
+		arguments = append(arguments, "--modify-window="+strconv.Itoa(options.ModifyWindow))
 	}
 
 	if options.TempDir != "" {
@@ -597,10 +929,19 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--stats")
 	}
 
-	if options.HumanReadable {
+	if options.HumanReadable || options.HumanReadableIEC {
 		arguments = append(arguments, "--human-readable")
 	}
 
+	// a single --human-readable prints units of 1000, also without HumanReadable
+	if options.HumanReadableIEC {
+		arguments = append(arguments, "--human-readable")
+	}
+
+	if options.NoHumanReadable {
+		arguments = append(arguments, "--no-human-readable")
+	}
+
 	if options.Progress {
 		arguments = append(arguments, "--progress")
 	}
@@ -609,7 +950,9 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--password-file", options.PasswordFile)
 	}
 
-	if options.BandwidthLimit > 0 {
+	if options.Bandwidth > 0 {
+		arguments = append(arguments, "--bwlimit", options.Bandwidth.String())
+	} else if options.BandwidthLimit > 0 {
 		arguments = append(arguments, "--bwlimit", strconv.Itoa(options.BandwidthLimit))
 	}
 
@@ -621,14 +964,40 @@ func getArguments(options RsyncOptions) []string {
 		arguments = append(arguments, "--ipv6")
 	}
 
-	if options.Info != "" {
-		arguments = append(arguments, "--info", options.Info)
+	if sockopts := options.SocketOptions.String(); sockopts != "" {
+		arguments = append(arguments, "--sockopts="+sockopts)
 	}
 
-	if options.OutFormat {
+	if info := outputFlagsArgument(options.Info, options.InfoFlags); info != "" {
+		arguments = append(arguments, "--info", info)
+	}
+
+	if debug := outputFlagsArgument(options.Debug, options.DebugFlags); debug != "" {
+		arguments = append(arguments, "--debug", debug)
+	}
+
+	if options.Msgs2Stderr {
+		arguments = append(arguments, "--msgs2stderr")
+	}
+
+	if options.OutBuf != "" {
+		arguments = append(arguments, "--outbuf="+options.OutBuf)
+	}
+
+	if options.CustomOutFormat != "" {
+		arguments = append(arguments, "--out-format="+options.CustomOutFormat)
+	} else if options.OutFormat {
 		arguments = append(arguments, "--out-format=\"%n\"")
 	}
 
+	for _, rule := range options.partitionRules {
+		arguments = append(arguments, "--filter="+rule)
+	}
+
+	if len(options.stdinRules) > 0 {
+		arguments = append(arguments, "--filter=merge -")
+	}
+
 	if len(options.Include) > 0 {
 		for _, pattern := range options.Include {
 			arguments = append(arguments, fmt.Sprintf("--include=%s", pattern))
@@ -641,27 +1010,94 @@ func getArguments(options RsyncOptions) []string {
 		}
 	}
 
+	if options.IncludeFrom != "" {
+		arguments = append(arguments, fmt.Sprintf("--include-from=%s", options.IncludeFrom))
+	}
+
+	if options.ExcludeFrom != "" {
+		arguments = append(arguments, fmt.Sprintf("--exclude-from=%s", options.ExcludeFrom))
+	}
+
 	if options.Filter != "" {
 		arguments = append(arguments, fmt.Sprintf("--filter=%s", options.Filter))
 	}
 
+	for _, rule := range options.ignoreRules {
+		arguments = append(arguments, "--filter="+rule)
+	}
+
+	if options.GitIgnore {
+		arguments = append(arguments, "--filter="+gitIgnoreFilter)
+	}
+
+	if options.DirsOnly {
+		arguments = append(arguments, "--include=*/", "--exclude=*")
+	}
+
 	if options.Chown != "" {
 		arguments = append(arguments, fmt.Sprintf("--chown=%s", options.Chown))
 	}
 
+	if options.CopyAs != "" {
+		arguments = append(arguments, fmt.Sprintf("--copy-as=%s", options.CopyAs))
+	}
+
+	if options.Iconv != "" {
+		arguments = append(arguments, fmt.Sprintf("--iconv=%s", options.Iconv))
+	}
+
+	if options.NoMotd {
+		arguments = append(arguments, "--no-motd")
+	}
+
+	if options.MkPath {
+		arguments = append(arguments, "--mkpath")
+	}
+
 	if options.ListOnly {
 		arguments = append(arguments, "--list-only")
 	}
 
+	if options.FilesFrom != "" {
+		arguments = append(arguments, fmt.Sprintf("--files-from=%s", options.FilesFrom))
+	}
+
+	if options.WriteBatch != "" {
+		arguments = append(arguments, fmt.Sprintf("--write-batch=%s", options.WriteBatch))
+	}
+
+	if options.OnlyWriteBatch != "" {
+		arguments = append(arguments, fmt.Sprintf("--only-write-batch=%s", options.OnlyWriteBatch))
+	}
+
+	if options.ReadBatch != "" {
+		arguments = append(arguments, fmt.Sprintf("--read-batch=%s", options.ReadBatch))
+	}
+
+	if options.LogFile != "" {
+		arguments = append(arguments, fmt.Sprintf("--log-file=%s", options.LogFile))
+	}
+
+	if options.LogFileFormat != "" {
+		arguments = append(arguments, fmt.Sprintf("--log-file-format=%s", options.LogFileFormat))
+	}
+
 	return arguments
 }
 
-func createDir(dir string) error {
-	cmd := exec.Command("mkdir", "-p", dir)
-	if err := cmd.Start(); err != nil {
-		return err
+// resolvePath resolves a relative local path against the working directory dir, if set
+func resolvePath(dir, path string) string {
+	if dir == "" || path == "" || filepath.IsAbs(path) {
+		return path
 	}
-	return cmd.Wait()
+	if _, _, remote := splitRemote(path); remote {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func createDir(dir string) error {
+	return os.MkdirAll(dir, 0777)
 }
 
 func isExist(p string) bool {
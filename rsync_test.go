@@ -1,6 +1,8 @@
 package grsync
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -119,18 +121,18 @@ func TestParseArguments(t *testing.T) {
 		assert.Contains(t, args, "--safe-links")
 	})
 
-	t.Run("--copy-dir-links", func(t *testing.T) {
+	t.Run("--copy-dirlinks", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			CopyDirLinks: true,
 		})
-		assert.Contains(t, args, "--copy-dir-links")
+		assert.Contains(t, args, "--copy-dirlinks")
 	})
 
-	t.Run("--keep-dir-links", func(t *testing.T) {
+	t.Run("--keep-dirlinks", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			KeepDirLinks: true,
 		})
-		assert.Contains(t, args, "--keep-dir-links")
+		assert.Contains(t, args, "--keep-dirlinks")
 	})
 
 	t.Run("--hard-links", func(t *testing.T) {
@@ -273,6 +275,14 @@ func TestParseArguments(t *testing.T) {
 		assert.Contains(t, args, "--whole-file")
 	})
 
+	t.Run("skip mount points", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			OneFileSystem:   true,
+			SkipMountPoints: true,
+		})
+		assert.Equal(t, []string{"--one-file-system", "--one-file-system"}, args)
+	})
+
 	t.Run("--one-file-system", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			OneFileSystem: true,
@@ -294,6 +304,13 @@ func TestParseArguments(t *testing.T) {
 		assert.Contains(t, args, "--rsh", "test")
 	})
 
+	t.Run("--blocking-io", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			BlockingIO: true,
+		})
+		assert.Contains(t, args, "--blocking-io")
+	})
+
 	t.Run("--rsync-path", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			RsyncPath: "test",
@@ -378,6 +395,20 @@ func TestParseArguments(t *testing.T) {
 		assert.Contains(t, args, "--force")
 	})
 
+	t.Run("--delete-missing-args", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			DeleteMissingArgs: true,
+		})
+		assert.Contains(t, args, "--delete-missing-args")
+	})
+
+	t.Run("--files-from", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			FilesFrom: "/tmp/files",
+		})
+		assert.Contains(t, args, "--files-from=/tmp/files")
+	})
+
 	t.Run("--max-delete", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			MaxDelete: 1,
@@ -464,9 +495,9 @@ func TestParseArguments(t *testing.T) {
 
 	t.Run("--modify-window", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
-			ModifyWindow: true,
+			ModifyWindow: FATModifyWindow,
 		})
-		assert.Contains(t, args, "--modify-window")
+		assert.Equal(t, []string{"--modify-window=1"}, args)
 	})
 
 	t.Run("--temp-dir", func(t *testing.T) {
@@ -546,6 +577,17 @@ func TestParseArguments(t *testing.T) {
 		assert.Contains(t, args, "--human-readable")
 	})
 
+	t.Run("--human-readable twice", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			HumanReadable:    true,
+			HumanReadableIEC: true,
+		})
+		assert.Equal(t, []string{"--human-readable", "--human-readable"}, args)
+
+		args = getArguments(RsyncOptions{HumanReadableIEC: true})
+		assert.Equal(t, []string{"--human-readable", "--human-readable"}, args)
+	})
+
 	t.Run("--progress", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			Progress: true,
@@ -561,6 +603,39 @@ func TestParseArguments(t *testing.T) {
 		assert.Contains(t, args, "progress2")
 	})
 
+	t.Run("--info flags", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			Info:      "progress2",
+			InfoFlags: OutputFlags{"stats": 3, "name": 1},
+		})
+		assert.Contains(t, args, "progress2,name1,stats3")
+	})
+
+	t.Run("dirs only", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			Exclude:  []string{"cache/"},
+			Filter:   "- tmp/",
+			DirsOnly: true,
+		})
+		assert.Equal(t, []string{"--exclude=cache/", "--filter=- tmp/", "--include=*/", "--exclude=*"}, args)
+	})
+
+	t.Run("--mkpath", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			MkPath: true,
+		})
+		assert.Contains(t, args, "--mkpath")
+	})
+
+	t.Run("--debug", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			DebugFlags: OutputFlags{"filter": 2, "del": 1},
+		})
+		assert.Contains(t, args, "--debug")
+		assert.Contains(t, args, "del1,filter2")
+		assert.NotContains(t, args, "--info")
+	})
+
 	t.Run("--exclude", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			Exclude: []string{"foo", "bar", "\"baz\""},
@@ -593,6 +668,20 @@ func TestParseArguments(t *testing.T) {
 		assert.Contains(t, args, "--chown=nobody:nobody")
 	})
 
+	t.Run("--copy-as", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			CopyAs: "backup:staff",
+		})
+		assert.Contains(t, args, "--copy-as=backup:staff")
+	})
+
+	t.Run("--iconv", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			Iconv: "UTF-8-MAC,UTF-8",
+		})
+		assert.Contains(t, args, "--iconv=UTF-8-MAC,UTF-8")
+	})
+
 	t.Run("--ipv4", func(t *testing.T) {
 		args := getArguments(RsyncOptions{
 			IPv4: true,
@@ -606,4 +695,40 @@ func TestParseArguments(t *testing.T) {
 		})
 		assert.Contains(t, args, "--ipv6")
 	})
+
+	t.Run("--log-file", func(t *testing.T) {
+		args := getArguments(RsyncOptions{
+			LogFile:       "/var/log/rsync.log",
+			LogFileFormat: "%i %n",
+		})
+		assert.Contains(t, args, "--log-file=/var/log/rsync.log")
+		assert.Contains(t, args, "--log-file-format=%i %n")
+	})
+}
+
+func TestRsyncWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "list"), []byte("a\n"), 0644))
+
+	task, err := NewTask("src/", "dst/", false, true, RsyncOptions{
+		RsyncBinaryPath: fakeRsync(t, `pwd; cat list`),
+		FilesFrom:       "list",
+		WorkDir:         dir,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	realDir, _ := filepath.EvalSymlinks(dir)
+	assert.Equal(t, realDir+"\na\n", task.Log().Stdout)
+	stat, err := os.Stat(filepath.Join(dir, "dst"))
+	assert.Nil(t, err)
+	assert.True(t, stat.IsDir())
+}
+
+func TestResolvePath(t *testing.T) {
+	assert.Equal(t, "dst", resolvePath("", "dst"))
+	assert.Equal(t, filepath.Join("/work", "dst"), resolvePath("/work", "dst"))
+	assert.Equal(t, "/abs", resolvePath("/work", "/abs"))
+	assert.Equal(t, "host:dst", resolvePath("/work", "host:dst"))
+	assert.Equal(t, "host::module", resolvePath("/work", "host::module"))
+	assert.Equal(t, "", resolvePath("/work", ""))
 }
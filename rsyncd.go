@@ -0,0 +1,286 @@
+package grsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrDaemonStopped is returned by Daemon.Reload after the daemon stopped
+var ErrDaemonStopped = errors.New("daemon stopped")
+
+// DaemonModule is a module served by a Daemon
+type DaemonModule struct {
+	Name    string
+	Path    string
+	Comment string
+	// Writable lets clients upload into the module, which is read only otherwise
+	Writable bool
+	// Users are the users and passwords clients authenticate with, anybody may connect without
+	Users map[string]string
+	// HostsAllow are the addresses, networks or host names allowed to connect, all if empty
+	HostsAllow []string
+	// Parameters are further module parameters of rsyncd.conf, e.g. "uid" or "max connections".
+	// The ones set by the other fields win
+	Parameters map[string]string
+}
+
+// DaemonConfig configures a Daemon
+type DaemonConfig struct {
+	// Address is the address the daemon listens on, all addresses if empty
+	Address string
+	// Port is the port the daemon listens on, 873 if zero
+	Port int
+	// Parameters are further global parameters of rsyncd.conf, e.g. "use chroot"
+	Parameters map[string]string
+	Modules    []DaemonModule
+	// RsyncBinaryPath is the rsync binary, by default `rsync`
+	RsyncBinaryPath string
+}
+
+// Daemon is a rsync daemon run by the program, serving modules whose definitions can be changed
+// while it runs, see Reload. Its configuration and secrets files are kept in a private temporary
+// directory
+type Daemon struct {
+	mutex   sync.Mutex
+	config  DaemonConfig
+	dir     string
+	cmd     *exec.Cmd
+	stderr  bytes.Buffer
+	done    chan struct{}
+	err     error
+	stopped bool
+}
+
+// StartDaemon starts `rsync --daemon --no-detach` serving the modules of config
+func StartDaemon(config DaemonConfig) (*Daemon, error) {
+	config.Modules = append([]DaemonModule(nil), config.Modules...)
+	if err := checkDaemonConfig(config); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "grsync-daemon-")
+	if err != nil {
+		return nil, err
+	}
+	d := &Daemon{config: config, dir: dir, done: make(chan struct{})}
+	if err = d.writeConfig(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	binaryPath := "rsync"
+	if config.RsyncBinaryPath != "" {
+		binaryPath = config.RsyncBinaryPath
+	}
+	d.cmd = exec.Command(binaryPath, "--daemon", "--no-detach", "--config="+d.ConfigPath())
+	d.cmd.Stderr = &d.stderr
+	if err = d.cmd.Start(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	go func() {
+		err := d.cmd.Wait()
+		d.mutex.Lock()
+		if err != nil && !d.stopped {
+			d.err = fmt.Errorf("rsync daemon: %w: %s", err, strings.TrimSpace(d.stderr.String()))
+		}
+		d.mutex.Unlock()
+		close(d.done)
+	}()
+	return d, nil
+}
+
+// ConfigPath returns the path of the generated rsyncd.conf
+func (d *Daemon) ConfigPath() string {
+	return filepath.Join(d.dir, "rsyncd.conf")
+}
+
+// Modules returns the modules served
+func (d *Daemon) Modules() []DaemonModule {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]DaemonModule(nil), d.config.Modules...)
+}
+
+// Reload replaces the modules served, e.g. to add or remove modules or to change their users.
+// rsync reads its configuration for every connection, so the new modules apply to the next
+// connections while the running transfers continue with the old ones. The daemon isn't
+// signalled, rsync mustn't be sent SIGHUP to reload
+func (d *Daemon) Reload(modules []DaemonModule) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	select {
+	case <-d.done:
+		return ErrDaemonStopped
+	default:
+	}
+	config := d.config
+	config.Modules = append([]DaemonModule(nil), modules...)
+	if err := checkDaemonConfig(config); err != nil {
+		return err
+	}
+	previous := d.config
+	d.config = config
+	if err := d.writeConfig(); err != nil {
+		d.config = previous
+		return err
+	}
+	return nil
+}
+
+// Stop kills the daemon and removes its configuration. The transfers already running finish on
+// their own, their processes are forked by rsync
+func (d *Daemon) Stop() error {
+	d.mutex.Lock()
+	d.stopped = true
+	d.mutex.Unlock()
+
+	if err := d.cmd.Process.Signal(os.Kill); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	<-d.done
+	return os.RemoveAll(d.dir)
+}
+
+// Wait waits for the daemon to exit. It returns nil after Stop and the error of rsync otherwise
+func (d *Daemon) Wait() error {
+	<-d.done
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.err
+}
+
+// writeConfig writes the secrets files and the configuration, each replacing the previous one at
+// once so connections never read half of it. Secrets files of removed modules are left until
+// Stop, the running transfers may still use them. The mutex must be held
+func (d *Daemon) writeConfig() error {
+	secrets := make(map[string]string)
+	for _, module := range d.config.Modules {
+		if len(module.Users) == 0 {
+			continue
+		}
+		var lines []string
+		for user, password := range module.Users {
+			lines = append(lines, user+":"+password+"\n")
+		}
+		sort.Strings(lines)
+		path := filepath.Join(d.dir, module.Name+".secrets")
+		if err := writeDaemonFile(path, []byte(strings.Join(lines, ""))); err != nil {
+			return err
+		}
+		secrets[module.Name] = path
+	}
+	return writeDaemonFile(d.ConfigPath(), []byte(renderDaemonConfig(d.config, secrets)))
+}
+
+// renderDaemonConfig returns the rsyncd.conf of config, secrets are the secrets files by module
+func renderDaemonConfig(config DaemonConfig, secrets map[string]string) string {
+	var b strings.Builder
+	b.WriteString("# generated by grsync\n")
+	global := map[string]string{}
+	if config.Address != "" {
+		global["address"] = config.Address
+	}
+	if config.Port != 0 {
+		global["port"] = strconv.Itoa(config.Port)
+	}
+	writeDaemonParameters(&b, "", global, config.Parameters)
+
+	for _, module := range config.Modules {
+		parameters := map[string]string{"path": module.Path, "read only": "yes"}
+		if module.Comment != "" {
+			parameters["comment"] = module.Comment
+		}
+		if module.Writable {
+			parameters["read only"] = "no"
+		}
+		if len(module.Users) > 0 {
+			users := make([]string, 0, len(module.Users))
+			for user := range module.Users {
+				users = append(users, user)
+			}
+			sort.Strings(users)
+			parameters["auth users"] = strings.Join(users, ", ")
+			parameters["secrets file"] = secrets[module.Name]
+		}
+		if len(module.HostsAllow) > 0 {
+			parameters["hosts allow"] = strings.Join(module.HostsAllow, " ")
+		}
+		fmt.Fprintf(&b, "\n[%s]\n", module.Name)
+		writeDaemonParameters(&b, "\t", parameters, module.Parameters)
+	}
+	return b.String()
+}
+
+// writeDaemonParameters writes parameters and extra sorted by name, parameters win over extra
+func writeDaemonParameters(b *strings.Builder, indent string, parameters, extra map[string]string) {
+	merged := make(map[string]string, len(parameters)+len(extra))
+	for name, value := range extra {
+		merged[name] = value
+	}
+	for name, value := range parameters {
+		merged[name] = value
+	}
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s%s = %s\n", indent, name, merged[name])
+	}
+}
+
+// writeDaemonFile replaces path with data at once. rsync refuses secrets files other users can
+// read, so the files are private
+func writeDaemonFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// checkDaemonConfig rejects modules rsyncd.conf can't express: names which are empty, repeated or
+// contain "]" or "/", and values spanning lines
+func checkDaemonConfig(config DaemonConfig) error {
+	values := []string{config.Address}
+	for name, value := range config.Parameters {
+		values = append(values, name, value)
+	}
+	names := make(map[string]bool)
+	for _, module := range config.Modules {
+		if module.Name == "" || strings.ContainsAny(module.Name, "]/") || names[module.Name] {
+			return fmt.Errorf("invalid module name %q", module.Name)
+		}
+		names[module.Name] = true
+		if module.Path == "" {
+			return fmt.Errorf("module %s has no path", module.Name)
+		}
+		values = append(values, module.Name, module.Path, module.Comment)
+		values = append(values, module.HostsAllow...)
+		for user, password := range module.Users {
+			if user == "" || strings.ContainsAny(user, ":, ") {
+				return fmt.Errorf("module %s: invalid user %q", module.Name, user)
+			}
+			values = append(values, password)
+		}
+		for name, value := range module.Parameters {
+			values = append(values, name, value)
+		}
+	}
+	for _, value := range values {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid daemon parameter %q", value)
+		}
+	}
+	return nil
+}
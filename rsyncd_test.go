@@ -0,0 +1,122 @@
+package grsync
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderDaemonConfig(t *testing.T) {
+	config := DaemonConfig{
+		Port:       8730,
+		Parameters: map[string]string{"use chroot": "no", "port": "1"},
+		Modules: []DaemonModule{
+			{Name: "public", Path: "/srv/public", Comment: "mirror"},
+			{
+				Name:       "backup",
+				Path:       "/srv/backup",
+				Writable:   true,
+				Users:      map[string]string{"nightly": "secret", "admin": "other"},
+				HostsAllow: []string{"10.0.0.0/8", "backup.example.org"},
+				Parameters: map[string]string{"max connections": "2", "read only": "yes"},
+			},
+		},
+	}
+	assert.Equal(t, `# generated by grsync
+port = 8730
+use chroot = no
+
+[public]
+	comment = mirror
+	path = /srv/public
+	read only = yes
+
+[backup]
+	auth users = admin, nightly
+	hosts allow = 10.0.0.0/8 backup.example.org
+	max connections = 2
+	path = /srv/backup
+	read only = no
+	secrets file = /run/backup.secrets
+`, renderDaemonConfig(config, map[string]string{"backup": "/run/backup.secrets"}))
+}
+
+func TestCheckDaemonConfig(t *testing.T) {
+	assert.Nil(t, checkDaemonConfig(DaemonConfig{Modules: []DaemonModule{{Name: "data", Path: "/data"}}}))
+	for _, module := range []DaemonModule{
+		{Path: "/data"},
+		{Name: "a]b", Path: "/data"},
+		{Name: "a/b", Path: "/data"},
+		{Name: "data"},
+		{Name: "data", Path: "/data", Comment: "two\nlines"},
+		{Name: "data", Path: "/data", Users: map[string]string{"a:b": "secret"}},
+		{Name: "data", Path: "/data", Parameters: map[string]string{"uid": "0\n[root]"}},
+	} {
+		assert.NotNil(t, checkDaemonConfig(DaemonConfig{Modules: []DaemonModule{module}}), module)
+	}
+	assert.NotNil(t, checkDaemonConfig(DaemonConfig{Modules: []DaemonModule{{Name: "data", Path: "/a"}, {Name: "data", Path: "/b"}}}))
+}
+
+func TestDaemon(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rsync is a shell script")
+	}
+	args := filepath.Join(t.TempDir(), "args")
+	binary := fakeRsync(t, `echo "$@" > `+args+`
+exec sleep 10`)
+
+	daemon, err := StartDaemon(DaemonConfig{
+		Port:            8730,
+		Modules:         []DaemonModule{{Name: "public", Path: "/srv/public"}},
+		RsyncBinaryPath: binary,
+	})
+	assert.Nil(t, err)
+	config, err := os.ReadFile(daemon.ConfigPath())
+	assert.Nil(t, err)
+	assert.Contains(t, string(config), "[public]")
+
+	// the configuration is rewritten, the daemon keeps running
+	modules := []DaemonModule{{Name: "backup", Path: "/srv/backup", Users: map[string]string{"nightly": "secret"}}}
+	assert.Nil(t, daemon.Reload(modules))
+	assert.Equal(t, modules, daemon.Modules())
+	config, err = os.ReadFile(daemon.ConfigPath())
+	assert.Nil(t, err)
+	assert.NotContains(t, string(config), "[public]")
+	assert.Contains(t, string(config), "[backup]")
+	secrets, err := os.ReadFile(filepath.Join(filepath.Dir(daemon.ConfigPath()), "backup.secrets"))
+	assert.Nil(t, err)
+	assert.Equal(t, "nightly:secret\n", string(secrets))
+
+	// an invalid reload keeps the modules
+	assert.NotNil(t, daemon.Reload([]DaemonModule{{Name: "broken"}}))
+	assert.Equal(t, modules, daemon.Modules())
+
+	assert.Eventually(t, func() bool {
+		started, _ := os.ReadFile(args)
+		return string(started) == "--daemon --no-detach --config="+daemon.ConfigPath()+"\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, daemon.Stop())
+	assert.Nil(t, daemon.Wait())
+	assert.Equal(t, ErrDaemonStopped, daemon.Reload(modules))
+	_, err = os.Stat(daemon.ConfigPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDaemonExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake rsync is a shell script")
+	}
+	binary := fakeRsync(t, `echo "bind() failed: Address already in use" >&2; exit 10`)
+	daemon, err := StartDaemon(DaemonConfig{RsyncBinaryPath: binary})
+	assert.Nil(t, err)
+	err = daemon.Wait()
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "Address already in use"))
+	assert.Nil(t, daemon.Stop())
+}
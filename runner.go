@@ -0,0 +1,72 @@
+package grsync
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Process is a rsync process created by a CommandRunner
+type Process interface {
+	// StdoutPipe and StderrPipe return the pipes connected to the outputs of the process once it started
+	StdoutPipe() (io.ReadCloser, error)
+	StderrPipe() (io.ReadCloser, error)
+	Start() error
+	// Wait waits for the started process to exit, an *exec.ExitError or another error with an
+	// ExitCode method reports its exit code, see ExitCodeOf
+	Wait() error
+	// Signal sends sig to the started process, os.Kill stops it. It returns ErrNotStarted before Start
+	Signal(sig os.Signal) error
+}
+
+// Command describes a process to run
+type Command struct {
+	Name string
+	Args []string
+	// Env is the environment of the process, nil for the current one
+	Env []string
+	// Dir is the working directory of the process, empty for the current one
+	Dir string
+	// Credential is the user the process runs as, nil for the current one
+	Credential *Credential
+	// Stdin is the input of the process, nil for none
+	Stdin io.Reader
+}
+
+// CommandRunner creates the rsync processes of tasks, which use ExecRunner unless another one is
+// set with Task.SetRunner. A fake runner lets tests exercise tasks without a rsync binary
+type CommandRunner interface {
+	// NewProcess returns a process running cmd
+	NewProcess(cmd Command) Process
+}
+
+// ExecRunner runs processes with os/exec
+type ExecRunner struct{}
+
+// NewProcess returns an *exec.Cmd running cmd
+func (ExecRunner) NewProcess(command Command) Process {
+	cmd := exec.Command(command.Name, command.Args...)
+	cmd.Env = command.Env
+	cmd.Dir = command.Dir
+	cmd.SysProcAttr = command.Credential.sysProcAttr()
+	cmd.Stdin = command.Stdin
+	return &execProcess{Cmd: cmd}
+}
+
+type execProcess struct {
+	*exec.Cmd
+}
+
+func (p *execProcess) Signal(sig os.Signal) error {
+	if p.Process == nil {
+		return ErrNotStarted
+	}
+	return p.Process.Signal(sig)
+}
+
+// SetRunner replaces the CommandRunner creating the rsync processes, nil restores ExecRunner
+func (t *Task) SetRunner(runner CommandRunner) {
+	t.mutex.Lock()
+	t.runner = runner
+	t.mutex.Unlock()
+}
@@ -0,0 +1,80 @@
+package grsync
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cannedRunner creates processes printing canned output and exiting with err
+type cannedRunner struct {
+	stdout, stderr string
+	err            error
+	args           []string
+}
+
+func (r *cannedRunner) NewProcess(cmd Command) Process {
+	r.args = append([]string{cmd.Name}, cmd.Args...)
+	return &cannedProcess{runner: r, done: make(chan struct{})}
+}
+
+type cannedProcess struct {
+	runner         *cannedRunner
+	stdout, stderr *io.PipeWriter
+	done           chan struct{}
+}
+
+func (p *cannedProcess) StdoutPipe() (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	p.stdout = w
+	return r, nil
+}
+
+func (p *cannedProcess) StderrPipe() (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	p.stderr = w
+	return r, nil
+}
+
+func (p *cannedProcess) Start() error {
+	go func() {
+		_, _ = io.Copy(p.stdout, strings.NewReader(p.runner.stdout))
+		_ = p.stdout.Close()
+		if p.stderr != nil {
+			_, _ = io.Copy(p.stderr, strings.NewReader(p.runner.stderr))
+			_ = p.stderr.Close()
+		}
+		close(p.done)
+	}()
+	return nil
+}
+
+func (p *cannedProcess) Wait() error {
+	<-p.done
+	return p.runner.err
+}
+
+func (p *cannedProcess) Signal(os.Signal) error {
+	return nil
+}
+
+func TestTaskRunner(t *testing.T) {
+	runner := &cannedRunner{
+		stdout: "      1.00M   50%    1.00MB/s    0:00:01 (xfr#1, to-chk=1/2)\r      2.00M  100%    1.00MB/s    0:00:00 (xfr#2, to-chk=0/2)\n",
+		stderr: "file has vanished: \"/src/tmp\"\n",
+	}
+	task, err := NewTask("/src/", "/dst/", false, false, RsyncOptions{Archive: true})
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, "rsync", runner.args[0])
+	assert.Equal(t, []string{"/src/", "/dst/"}, runner.args[len(runner.args)-2:])
+	state := task.State()
+	assert.Equal(t, 100, state.Progress)
+	assert.Equal(t, int64(2000000), state.BytesTransferred)
+	assert.Equal(t, "/src/tmp", task.FileErrors()[0].Path)
+}
@@ -0,0 +1,172 @@
+package grsync
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RunRecord is the record of a finished run kept by a RunStore
+type RunRecord struct {
+	// Key groups the runs of a task, see SetRunStore
+	Key         string            `json:"key"`
+	TaskID      string            `json:"taskId"`
+	Source      string            `json:"source"`
+	Destination string            `json:"destination"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Status      TaskStatus        `json:"status"`
+	FinishedAt  time.Time         `json:"finishedAt"`
+	// Error is empty if the run succeeded
+	Error  string `json:"error,omitempty"`
+	Result Result `json:"result"`
+}
+
+// RunQuery selects run records. Unset fields match every record
+type RunQuery struct {
+	Key    string
+	Status TaskStatus
+	// Since and Until bound the start of the runs, Until is exclusive
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of records, 0 returns all of them
+	Limit int
+}
+
+// matches reports whether record is selected by the query, Limit aside
+func (q RunQuery) matches(record RunRecord) bool {
+	started := record.Result.StartedAt
+	return (q.Key == "" || record.Key == q.Key) &&
+		(q.Status == "" || record.Status == q.Status) &&
+		(q.Since.IsZero() || !started.Before(q.Since)) &&
+		(q.Until.IsZero() || started.Before(q.Until))
+}
+
+// RunStore keeps the records of finished runs, e.g. for dashboards of backup jobs. grsyncsqlite
+// provides a SQLite store
+type RunStore interface {
+	Save(record RunRecord) error
+	// Runs returns the records matching the query, the latest start first
+	Runs(query RunQuery) ([]RunRecord, error)
+	// Latest returns the latest record matching the query of every key, ordered by key. Limit
+	// bounds the number of keys
+	Latest(query RunQuery) ([]RunRecord, error)
+}
+
+// LastRun returns the latest run of key with status, any status if it is empty. ok is false if
+// there is none
+func LastRun(store RunStore, key string, status TaskStatus) (record RunRecord, ok bool, err error) {
+	records, err := store.Runs(RunQuery{Key: key, Status: status, Limit: 1})
+	if err != nil || len(records) == 0 {
+		return RunRecord{}, false, err
+	}
+	return records[0], true, nil
+}
+
+// LastSuccessfulRuns returns the latest successful run of every key
+func LastSuccessfulRuns(store RunStore) ([]RunRecord, error) {
+	return store.Latest(RunQuery{Status: TaskSucceeded})
+}
+
+// SetRunStore makes the task save the record of every finished run to store under key, the ID
+// of the task if empty. Tasks created by a Scheduler get new IDs, their Job.Setup usually
+// passes the name of the job. Errors of the store are logged, see SetLogger
+func (t *Task) SetRunStore(store RunStore, key string) {
+	t.mutex.Lock()
+	t.runStore = store
+	t.runStoreKey = key
+	t.mutex.Unlock()
+}
+
+// saveRun saves the record of the finished run to the store of the task, if any
+func (t *Task) saveRun(err error) {
+	t.mutex.Lock()
+	store := t.runStore
+	record := RunRecord{
+		Key:         t.runStoreKey,
+		TaskID:      t.id,
+		Source:      t.source,
+		Destination: t.destination,
+		Labels:      cloneLabels(t.definition.Labels),
+		Status:      t.status,
+		FinishedAt:  t.finishedAt,
+	}
+	t.mutex.Unlock()
+	if store == nil {
+		return
+	}
+
+	if record.Key == "" {
+		record.Key = record.TaskID
+	}
+	if err != nil {
+		record.Error = t.Redact(err.Error())
+	}
+	record.Result = t.result(err)
+	if err = store.Save(record); err != nil {
+		t.logEvent(slog.LevelWarn, "saving run record failed", slog.String("error", err.Error()))
+	}
+}
+
+// MemoryRunStore keeps run records in memory, e.g. for tests or short-lived processes
+type MemoryRunStore struct {
+	mutex   sync.Mutex
+	records []RunRecord
+}
+
+// Save appends the record
+func (s *MemoryRunStore) Save(record RunRecord) error {
+	s.mutex.Lock()
+	s.records = append(s.records, record)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Runs returns the records matching the query, the latest start first
+func (s *MemoryRunStore) Runs(query RunQuery) ([]RunRecord, error) {
+	var records []RunRecord
+	for _, record := range s.sorted() {
+		if !query.matches(record) {
+			continue
+		}
+		records = append(records, record)
+		if len(records) == query.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// Latest returns the latest record matching the query of every key, ordered by key
+func (s *MemoryRunStore) Latest(query RunQuery) ([]RunRecord, error) {
+	seen := make(map[string]bool)
+	var records []RunRecord
+	for _, record := range s.sorted() {
+		if seen[record.Key] || !query.matches(record) {
+			continue
+		}
+		seen[record.Key] = true
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+	if query.Limit > 0 && len(records) > query.Limit {
+		records = records[:query.Limit]
+	}
+	return records, nil
+}
+
+// sorted returns a copy of the records, the latest start first
+func (s *MemoryRunStore) sorted() []RunRecord {
+	s.mutex.Lock()
+	records := append([]RunRecord(nil), s.records...)
+	s.mutex.Unlock()
+
+	// the stable sort keeps the later saved of two runs started at the same time first
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Result.StartedAt.After(records[j].Result.StartedAt)
+	})
+	return records
+}
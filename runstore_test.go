@@ -0,0 +1,71 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRunStore(t *testing.T) {
+	start := time.Date(2024, 3, 1, 2, 30, 0, 0, time.UTC)
+	store := &MemoryRunStore{}
+	for _, record := range []RunRecord{
+		{Key: "nightly", Status: TaskSucceeded, Result: Result{StartedAt: start}},
+		{Key: "nightly", Status: TaskFailed, Result: Result{StartedAt: start.Add(24 * time.Hour)}},
+		{Key: "hourly", Status: TaskSucceeded, Result: Result{StartedAt: start.Add(time.Hour)}},
+		{Key: "hourly", Status: TaskSucceeded, Result: Result{StartedAt: start.Add(2 * time.Hour)}},
+	} {
+		assert.Nil(t, store.Save(record))
+	}
+
+	runs, err := store.Runs(RunQuery{Key: "nightly"})
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, TaskFailed, runs[0].Status)
+
+	runs, err = store.Runs(RunQuery{Since: start.Add(time.Hour), Until: start.Add(24 * time.Hour), Limit: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []RunRecord{{Key: "hourly", Status: TaskSucceeded, Result: Result{StartedAt: start.Add(2 * time.Hour)}}}, runs)
+
+	record, ok, err := LastRun(store, "nightly", TaskSucceeded)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, start, record.Result.StartedAt)
+	_, ok, err = LastRun(store, "weekly", "")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	runs, err = LastSuccessfulRuns(store)
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "hourly", runs[0].Key)
+	assert.Equal(t, start.Add(2*time.Hour), runs[0].Result.StartedAt)
+	assert.Equal(t, "nightly", runs[1].Key)
+	assert.Equal(t, start, runs[1].Result.StartedAt)
+}
+
+func TestSetRunStore(t *testing.T) {
+	store := &MemoryRunStore{}
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo transfer")})
+	assert.Nil(t, err)
+	task.SetRunStore(store, "")
+	assert.Nil(t, task.Run())
+
+	failing, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+	assert.Nil(t, err)
+	failing.SetRunStore(store, "backup")
+	assert.NotNil(t, failing.Run())
+
+	runs, err := store.Runs(RunQuery{})
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "backup", runs[0].Key)
+	assert.Equal(t, TaskFailed, runs[0].Status)
+	assert.Equal(t, ExitPartial, runs[0].Result.ExitCode)
+	assert.NotEmpty(t, runs[0].Error)
+	assert.Equal(t, task.ID(), runs[1].Key)
+	assert.Equal(t, TaskSucceeded, runs[1].Status)
+	assert.Equal(t, "a", runs[1].Source)
+	assert.Equal(t, "b", runs[1].Destination)
+}
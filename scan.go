@@ -0,0 +1,60 @@
+package grsync
+
+import (
+	"bytes"
+	"time"
+)
+
+var (
+	// scanPrefixes start the first count, which rsync prints on the line announcing the file list
+	scanPrefixes = byteStrings("building file list ...", "receiving file list ...", "sending file list ...")
+	scanSuffix   = []byte(" files...")
+	// scannedSuffixes end the final count
+	scannedSuffixes = byteStrings(" files to consider", " file to consider")
+)
+
+// parseScanCount parses a count of the files rsync found while building a complete file list
+// without incremental recursion, e.g. "1000 files..." or the final "1234 files to consider", which
+// sets done. It doesn't allocate
+func parseScanCount(line []byte) (count int, done, ok bool) {
+	line = bytes.TrimSpace(line)
+	for _, prefix := range scanPrefixes {
+		if bytes.HasPrefix(line, prefix) {
+			line = bytes.TrimSpace(line[len(prefix):])
+			break
+		}
+	}
+
+	i := 0
+	for ; i < len(line) && (isDigit(line[i]) || (i > 0 && line[i] == ',')); i++ {
+		if isDigit(line[i]) {
+			count = count*10 + int(line[i]-'0')
+		}
+	}
+	if i == 0 {
+		return 0, false, false
+	}
+	rest := line[i:]
+	if bytes.Equal(rest, scanSuffix) {
+		return count, false, true
+	}
+	for _, suffix := range scannedSuffixes {
+		if bytes.Equal(rest, suffix) {
+			return count, true, true
+		}
+	}
+	return 0, false, false
+}
+
+// parseScanLine updates the scanning state from a file count and reports whether line was one.
+// The mutex must be held
+func (t *Task) parseScanLine(line []byte) bool {
+	count, done, ok := parseScanCount(line)
+	if !ok {
+		return false
+	}
+	t.state.Scanning = !done
+	t.state.FilesScanned = count
+	t.lastStateChange = time.Now()
+	return true
+}
@@ -0,0 +1,61 @@
+package grsync
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScanCount(t *testing.T) {
+	for _, c := range []struct {
+		line  string
+		count int
+		done  bool
+	}{
+		{"1000 files...", 1000, false},
+		{"building file list ... 100 files...", 100, false},
+		{"receiving file list ... 2,000 files...", 2000, false},
+		{"1,234 files to consider", 1234, true},
+		{"1 file to consider", 1, true},
+	} {
+		count, done, ok := parseScanCount([]byte(c.line))
+		assert.True(t, ok, c.line)
+		assert.Equal(t, c.count, count, c.line)
+		assert.Equal(t, c.done, done, c.line)
+	}
+
+	for _, line := range []string{"", "building file list ... done", "files...", "1000 files", "dir/1000 files..."} {
+		_, _, ok := parseScanCount([]byte(line))
+		assert.False(t, ok, line)
+	}
+	assert.Equal(t, LineInfo, classifyLine([]byte("500 files..."), false))
+}
+
+func TestTaskScanning(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	stdout := func(output string) State {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		processStdout(&wg, task, strings.NewReader(output))
+		return task.State()
+	}
+
+	state := stdout("building file list ... 100 files...\n")
+	assert.True(t, state.Scanning)
+	assert.Equal(t, 100, state.FilesScanned)
+
+	state = stdout("1,234 files to consider\n")
+	assert.False(t, state.Scanning)
+	assert.Equal(t, 1234, state.FilesScanned)
+
+	state = stdout("      1.00M   50%    1.00MB/s    0:00:01 (xfr#1, ir-chk=1000/2000)\n")
+	assert.True(t, state.Scanning)
+	assert.Equal(t, 2000, state.FilesScanned)
+
+	state = stdout("      2.00M  100%    1.00MB/s    0:00:00 (xfr#2, to-chk=0/3000)\n")
+	assert.False(t, state.Scanning)
+	assert.Equal(t, 3000, state.FilesScanned)
+}
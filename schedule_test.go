@@ -0,0 +1,68 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2023, time.October, 7, 13, 19, 8, 0, time.UTC)
+
+	t.Run("every minute", func(t *testing.T) {
+		s, err := ParseCron("* * * * *")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2023, time.October, 7, 13, 20, 0, 0, time.UTC), s.Next(base))
+	})
+
+	t.Run("step", func(t *testing.T) {
+		s, err := ParseCron("*/15 * * * *")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2023, time.October, 7, 13, 30, 0, 0, time.UTC), s.Next(base))
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		s, err := ParseCron("@daily")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2023, time.October, 8, 0, 0, 0, 0, time.UTC), s.Next(base))
+	})
+
+	t.Run("weekday names and ranges", func(t *testing.T) {
+		// 2023-10-07 is a saturday
+		s, err := ParseCron("30 2 * * mon-fri")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2023, time.October, 9, 2, 30, 0, 0, time.UTC), s.Next(base))
+	})
+
+	t.Run("day of month or day of week", func(t *testing.T) {
+		s, err := ParseCron("0 0 20 * sun")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2023, time.October, 8, 0, 0, 0, 0, time.UTC), s.Next(base))
+	})
+
+	t.Run("month list", func(t *testing.T) {
+		s, err := ParseCron("0 12 1 jan,jul *")
+		assert.Nil(t, err)
+		assert.Equal(t, time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC), s.Next(base))
+	})
+
+	t.Run("never matching", func(t *testing.T) {
+		s, err := ParseCron("0 0 30 2 *")
+		assert.Nil(t, err)
+		assert.True(t, s.Next(base).IsZero())
+	})
+
+	t.Run("every", func(t *testing.T) {
+		s, err := ParseCron("@every 90s")
+		assert.Nil(t, err)
+		assert.Equal(t, base.Add(90*time.Second), s.Next(base))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "@every x"} {
+			_, err := ParseCron(expr)
+			assert.NotNil(t, err, expr)
+		}
+	})
+}
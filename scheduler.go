@@ -0,0 +1,290 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OverlapPolicy decides what happens when a job is due while its previous run is still in progress
+type OverlapPolicy int
+
+const (
+	// OverlapSkip drops the activation if the previous run is still in progress
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs the job again as soon as the previous run finished. At most one run is queued
+	OverlapQueue
+	// OverlapKill cancels the previous run and starts a new one
+	OverlapKill
+)
+
+// ErrSchedulerStopped is returned when a job is triggered on a stopped scheduler
+var ErrSchedulerStopped = errors.New("scheduler is stopped")
+
+// Job is a task definition the Scheduler runs on a schedule
+type Job struct {
+	// Name identifies the job inside the scheduler
+	Name string
+	// Schedule decides when the job runs, see Every and ParseCron
+	Schedule Schedule
+	// Overlap decides what happens when the job is due while it is still running
+	Overlap OverlapPolicy
+	// Definition is used to create a new task for every run
+	Definition Definition
+	// Setup is called with every task created for the job before it runs, e.g. to set a retry policy
+	Setup func(task *Task)
+}
+
+// JobStatus contains information about the runs of a scheduled job
+type JobStatus struct {
+	Name         string        `json:"name"`
+	Running      bool          `json:"running"`
+	Runs         int           `json:"runs"`
+	Failures     int           `json:"failures"`
+	Skipped      int           `json:"skipped"`
+	LastStart    time.Time     `json:"lastStart"`
+	LastDuration time.Duration `json:"lastDuration"`
+	LastError    error         `json:"-"`
+	// Totals are the counters of the tasks of the runs
+	Totals  Totals    `json:"totals"`
+	NextRun time.Time `json:"nextRun"`
+}
+
+// Scheduler runs jobs on cron expressions or fixed intervals
+type Scheduler struct {
+	mutex   sync.Mutex
+	jobs    map[string]*scheduledJob
+	started bool
+	stopped bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+type scheduledJob struct {
+	job     Job
+	status  JobStatus
+	current *Task
+	pending bool
+	done    chan struct{}
+}
+
+// NewScheduler returns a new scheduler without jobs
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs: make(map[string]*scheduledJob),
+		stop: make(chan struct{}),
+	}
+}
+
+// Add registers a job. Jobs added to a started scheduler are scheduled immediately
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" {
+		return errors.New("job name must not be empty")
+	}
+	if job.Schedule == nil {
+		return fmt.Errorf("job %q has no schedule", job.Name)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopped {
+		return ErrSchedulerStopped
+	}
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("job %q already exists", job.Name)
+	}
+
+	j := &scheduledJob{
+		job:    job,
+		status: JobStatus{Name: job.Name},
+		done:   make(chan struct{}),
+	}
+	s.jobs[job.Name] = j
+	if s.started {
+		s.schedule(j)
+	}
+	return nil
+}
+
+// Remove unschedules a job. A run in progress is not interrupted
+func (s *Scheduler) Remove(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j, ok := s.jobs[name]
+	if ok {
+		close(j.done)
+		delete(s.jobs, name)
+	}
+	return ok
+}
+
+// Start starts scheduling all registered jobs
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.started || s.stopped {
+		return
+	}
+	s.started = true
+	for _, j := range s.jobs {
+		s.schedule(j)
+	}
+}
+
+// Stop stops scheduling new runs and waits for the runs in progress to finish.
+// A stopped scheduler can't be started again
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+}
+
+// RunNow triggers a run of the job ignoring its schedule. The overlap policy still applies
+func (s *Scheduler) RunNow(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopped {
+		return ErrSchedulerStopped
+	}
+	j, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("job %q does not exist", name)
+	}
+	s.trigger(j)
+	return nil
+}
+
+// Status returns information about the runs of a job
+func (s *Scheduler) Status(name string) (JobStatus, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return j.status, true
+}
+
+// Statuses returns information about the runs of all jobs
+func (s *Scheduler) Statuses() []JobStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	return statuses
+}
+
+// schedule starts the timer loop of a job, s.mutex must be held
+func (s *Scheduler) schedule(j *scheduledJob) {
+	s.wg.Add(1)
+	go s.loop(j)
+}
+
+func (s *Scheduler) loop(j *scheduledJob) {
+	defer s.wg.Done()
+
+	for {
+		next := j.job.Schedule.Next(time.Now())
+		s.mutex.Lock()
+		j.status.NextRun = next
+		s.mutex.Unlock()
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-j.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.mutex.Lock()
+		if !s.stopped {
+			s.trigger(j)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// trigger starts a run of the job according to its overlap policy, s.mutex must be held
+func (s *Scheduler) trigger(j *scheduledJob) {
+	if !j.status.Running {
+		j.status.Running = true
+		s.wg.Add(1)
+		go s.run(j)
+		return
+	}
+
+	switch j.job.Overlap {
+	case OverlapQueue:
+		j.pending = true
+	case OverlapKill:
+		j.pending = true
+		if j.current != nil {
+			_ = j.current.Cancel()
+		}
+	default:
+		j.status.Skipped++
+	}
+}
+
+func (s *Scheduler) run(j *scheduledJob) {
+	defer s.wg.Done()
+
+	for {
+		start := time.Now()
+		task, err := j.job.Definition.NewTask()
+		if err == nil && j.job.Setup != nil {
+			j.job.Setup(task)
+		}
+
+		s.mutex.Lock()
+		j.current = task
+		j.status.LastStart = start
+		s.mutex.Unlock()
+
+		if err == nil {
+			err = task.Run()
+		}
+
+		s.mutex.Lock()
+		j.current = nil
+		j.status.Runs++
+		j.status.LastDuration = time.Since(start)
+		j.status.LastError = err
+		if task != nil {
+			j.status.Totals.merge(task.Totals())
+		}
+		if err != nil {
+			j.status.Failures++
+		}
+
+		if !j.pending || s.stopped {
+			j.pending = false
+			j.status.Running = false
+			s.mutex.Unlock()
+			return
+		}
+		j.pending = false
+		s.mutex.Unlock()
+	}
+}
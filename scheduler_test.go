@@ -0,0 +1,149 @@
+package grsync
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForRuns(t *testing.T, s *Scheduler, name string, runs int) JobStatus {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, _ := s.Status(name)
+		if status.Runs >= runs && !status.Running {
+			return status
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not reach %d runs", name, runs)
+	return JobStatus{}
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("runs job", func(t *testing.T) {
+		s := NewScheduler()
+		err := s.Add(Job{
+			Name:       "backup",
+			Schedule:   Every(time.Hour),
+			Definition: Definition{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")}},
+		})
+		assert.Nil(t, err)
+		s.Start()
+		defer s.Stop()
+
+		assert.Nil(t, s.RunNow("backup"))
+		status := waitForRuns(t, s, "backup", 1)
+		assert.Equal(t, 0, status.Failures)
+		assert.Nil(t, status.LastError)
+		assert.False(t, status.NextRun.IsZero())
+	})
+
+	t.Run("sets up tasks", func(t *testing.T) {
+		var (
+			mutex sync.Mutex
+			tasks []*Task
+		)
+		s := NewScheduler()
+		assert.Nil(t, s.Add(Job{
+			Name:       "backup",
+			Schedule:   Every(time.Hour),
+			Definition: Definition{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")}},
+			Setup: func(task *Task) {
+				task.SetRetryPolicy(RetryPolicy{MaxAttempts: 2})
+				mutex.Lock()
+				tasks = append(tasks, task)
+				mutex.Unlock()
+			},
+		}))
+		defer s.Stop()
+
+		assert.Nil(t, s.RunNow("backup"))
+		waitForRuns(t, s, "backup", 1)
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.Len(t, tasks, 1)
+		assert.Equal(t, 2, tasks[0].Attempts())
+	})
+
+	t.Run("records failures", func(t *testing.T) {
+		s := NewScheduler()
+		assert.Nil(t, s.Add(Job{
+			Name:       "backup",
+			Schedule:   Every(time.Hour),
+			Definition: Definition{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")}},
+		}))
+		defer s.Stop()
+
+		assert.Nil(t, s.RunNow("backup"))
+		status := waitForRuns(t, s, "backup", 1)
+		assert.Equal(t, 1, status.Failures)
+		assert.NotNil(t, status.LastError)
+	})
+
+	t.Run("skips overlapping runs", func(t *testing.T) {
+		s := NewScheduler()
+		assert.Nil(t, s.Add(Job{
+			Name:       "backup",
+			Schedule:   Every(time.Hour),
+			Overlap:    OverlapSkip,
+			Definition: Definition{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: fakeRsync(t, "sleep 0.2")}},
+		}))
+		defer s.Stop()
+
+		assert.Nil(t, s.RunNow("backup"))
+		assert.Nil(t, s.RunNow("backup"))
+		status := waitForRuns(t, s, "backup", 1)
+		assert.Equal(t, 1, status.Runs)
+		assert.Equal(t, 1, status.Skipped)
+	})
+
+	t.Run("queues overlapping runs", func(t *testing.T) {
+		s := NewScheduler()
+		assert.Nil(t, s.Add(Job{
+			Name:       "backup",
+			Schedule:   Every(time.Hour),
+			Overlap:    OverlapQueue,
+			Definition: Definition{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: fakeRsync(t, "sleep 0.2")}},
+		}))
+		defer s.Stop()
+
+		assert.Nil(t, s.RunNow("backup"))
+		assert.Nil(t, s.RunNow("backup"))
+		assert.Nil(t, s.RunNow("backup"))
+		status := waitForRuns(t, s, "backup", 2)
+		assert.Equal(t, 2, status.Runs)
+		assert.Equal(t, 0, status.Skipped)
+		assert.Equal(t, 2, status.Totals.Succeeded)
+		assert.GreaterOrEqual(t, status.Totals.AverageDuration, 200*time.Millisecond)
+	})
+
+	t.Run("kills previous run", func(t *testing.T) {
+		s := NewScheduler()
+		assert.Nil(t, s.Add(Job{
+			Name:       "backup",
+			Schedule:   Every(time.Hour),
+			Overlap:    OverlapKill,
+			Definition: Definition{Source: "a", Destination: "b", Options: RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exec sleep 1")}},
+		}))
+		defer s.Stop()
+
+		assert.Nil(t, s.RunNow("backup"))
+		<-time.After(100 * time.Millisecond)
+		assert.Nil(t, s.RunNow("backup"))
+		status := waitForRuns(t, s, "backup", 2)
+		assert.Equal(t, 2, status.Runs)
+		assert.Equal(t, 1, status.Failures)
+		assert.Nil(t, status.LastError)
+	})
+
+	t.Run("rejects duplicate jobs", func(t *testing.T) {
+		s := NewScheduler()
+		assert.Nil(t, s.Add(Job{Name: "backup", Schedule: Every(time.Minute)}))
+		assert.NotNil(t, s.Add(Job{Name: "backup", Schedule: Every(time.Minute)}))
+		assert.NotNil(t, s.Add(Job{Name: "noschedule"}))
+		s.Stop()
+		assert.Equal(t, ErrSchedulerStopped, s.Add(Job{Name: "late", Schedule: Every(time.Minute)}))
+	})
+}
@@ -0,0 +1,60 @@
+package grsync
+
+import (
+	"time"
+)
+
+// SnapshotLogTail is the number of bytes of stdout and stderr each included in a Snapshot
+const SnapshotLogTail = 4096
+
+// Snapshot is a JSON-marshalable view of everything known about a task at one point in time
+type Snapshot struct {
+	ID         string     `json:"id"`
+	Status     TaskStatus `json:"status"`
+	Definition Definition `json:"definition"`
+	State      State      `json:"state"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+
+	// StartedAt and FinishedAt are the bounds of the current or last run, FinishedAt is zero while running
+	StartedAt  time.Time     `json:"startedAt,omitempty"`
+	FinishedAt time.Time     `json:"finishedAt,omitempty"`
+	Duration   time.Duration `json:"duration"`
+
+	// LogTail holds the last SnapshotLogTail bytes of the accumulated output
+	LogTail Log `json:"logTail"`
+}
+
+// Snapshot returns the current snapshot of the task
+func (t *Task) Snapshot() Snapshot {
+	state := t.State()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s := Snapshot{
+		ID:         t.id,
+		Status:     t.status,
+		Definition: t.definition.Redacted(),
+		State:      state,
+		Attempts:   t.attempts,
+		StartedAt:  t.startedAt,
+		FinishedAt: t.finishedAt,
+		LogTail: Log{
+			Stdout:          t.redact(t.stdoutLog.Tail(SnapshotLogTail)),
+			Stderr:          t.redact(t.stderrLog.Tail(SnapshotLogTail)),
+			StdoutTruncated: t.stdoutLog.Dropped(),
+			StderrTruncated: t.stderrLog.Dropped(),
+		},
+	}
+	if t.err != nil {
+		s.Error = t.redact(t.err.Error())
+	}
+	switch {
+	case !s.FinishedAt.IsZero():
+		s.Duration = s.FinishedAt.Sub(s.StartedAt)
+	case !s.StartedAt.IsZero():
+		s.Duration = time.Since(s.StartedAt)
+	}
+	return s
+}
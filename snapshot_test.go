@@ -0,0 +1,49 @@
+package grsync
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `
+i=0
+while [ $i -lt 1000 ]; do echo "line $i"; i=$((i+1)); done
+echo "      2.00K  100%    1.00kB/s    0:00:00"
+echo "some error" >&2
+exit 23`)})
+	assert.Nil(t, err)
+	task.SetID("backup")
+
+	s := task.Snapshot()
+	assert.Equal(t, TaskPending, s.Status)
+	assert.True(t, s.StartedAt.IsZero())
+	assert.Zero(t, s.Duration)
+
+	assert.NotNil(t, task.Run())
+	s = task.Snapshot()
+	assert.Equal(t, "backup", s.ID)
+	assert.Equal(t, TaskFailed, s.Status)
+	assert.Equal(t, "a", s.Definition.Source)
+	assert.Equal(t, 100, s.State.Progress)
+	assert.Equal(t, 1, s.Attempts)
+	assert.Equal(t, "exit status 23", s.Error)
+	assert.False(t, s.FinishedAt.Before(s.StartedAt))
+	assert.Equal(t, s.FinishedAt.Sub(s.StartedAt), s.Duration)
+
+	assert.True(t, len(s.LogTail.Stdout) <= SnapshotLogTail)
+	assert.True(t, strings.HasPrefix(s.LogTail.Stdout, "line "))
+	assert.True(t, strings.HasSuffix(s.LogTail.Stdout, "0:00:00\n"))
+	assert.Equal(t, "some error\n", s.LogTail.Stderr)
+
+	data, err := json.Marshal(s)
+	assert.Nil(t, err)
+	var decoded Snapshot
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, s.ID, decoded.ID)
+	assert.Equal(t, s.LogTail, decoded.LogTail)
+	assert.True(t, s.StartedAt.Equal(decoded.StartedAt))
+}
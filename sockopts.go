@@ -0,0 +1,57 @@
+package grsync
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrAddressFamilyConflict is returned for options setting both IPv4 and IPv6
+var ErrAddressFamilyConflict = errors.New("only one of --ipv4 and --ipv6 can be used")
+
+// SocketOptions are the options of the socket to a rsync daemon, --sockopts. They don't apply to
+// transfers over a remote shell, whose connection is configured in ssh, e.g. with
+// "-o ServerAliveInterval=30" in RemoteShell. Larger buffers help on links with a high latency,
+// whose bandwidth-delay product exceeds the default buffer sizes of the system
+type SocketOptions struct {
+	// SendBuffer and ReceiveBuffer are the sizes of the socket buffers in bytes, SO_SNDBUF and SO_RCVBUF
+	SendBuffer    int
+	ReceiveBuffer int
+	// KeepAlive detects dead connections during long pauses of the transfer, SO_KEEPALIVE
+	KeepAlive bool
+	// NoDelay disables Nagle's algorithm, TCP_NODELAY
+	NoDelay bool
+	// DSCP marks the packets of the connection, e.g. DSCPLowerEffort to deprioritize backups. It
+	// isn't a --sockopts value, tasks connect through a local relay setting it. Not supported on
+	// Windows
+	DSCP DSCP
+	// Raw are socket options in the syntax of --sockopts, e.g. "IPTOS_THROUGHPUT"
+	Raw []string
+}
+
+// String returns the options as a --sockopts value, e.g. "SO_SNDBUF=4194304,SO_KEEPALIVE"
+func (s SocketOptions) String() string {
+	var options []string
+	if s.SendBuffer > 0 {
+		options = append(options, "SO_SNDBUF="+strconv.Itoa(s.SendBuffer))
+	}
+	if s.ReceiveBuffer > 0 {
+		options = append(options, "SO_RCVBUF="+strconv.Itoa(s.ReceiveBuffer))
+	}
+	if s.KeepAlive {
+		options = append(options, "SO_KEEPALIVE")
+	}
+	if s.NoDelay {
+		options = append(options, "TCP_NODELAY")
+	}
+	options = append(options, s.Raw...)
+	return strings.Join(options, ",")
+}
+
+// checkAddressFamily rejects IPv4 together with IPv6
+func checkAddressFamily(options RsyncOptions) error {
+	if options.IPv4 && options.IPv6 {
+		return ErrAddressFamilyConflict
+	}
+	return nil
+}
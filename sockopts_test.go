@@ -0,0 +1,19 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSocketOptions(t *testing.T) {
+	assert.Equal(t, "", SocketOptions{}.String())
+	options := SocketOptions{SendBuffer: 4 << 20, ReceiveBuffer: 4 << 20, KeepAlive: true, NoDelay: true, Raw: []string{"IPTOS_THROUGHPUT"}}
+	assert.Equal(t, "SO_SNDBUF=4194304,SO_RCVBUF=4194304,SO_KEEPALIVE,TCP_NODELAY,IPTOS_THROUGHPUT", options.String())
+
+	args := getArguments(RsyncOptions{IPv6: true, SocketOptions: SocketOptions{KeepAlive: true}})
+	assert.Equal(t, []string{"--ipv6", "--sockopts=SO_KEEPALIVE"}, args)
+
+	_, err := NewTask("host::module", "b", false, false, RsyncOptions{IPv4: true, IPv6: true})
+	assert.Equal(t, ErrAddressFamilyConflict, err)
+}
@@ -0,0 +1,142 @@
+package grsync
+
+import "errors"
+
+// ErrSpecRedacted is returned by Spec.NewTask for specs whose redacted secrets weren't set again
+var ErrSpecRedacted = errors.New("spec holds redacted secrets, set them on its definition first")
+
+// Transport is how rsync reaches the remote side of a task
+type Transport string
+
+const (
+	// TransportLocal copies between local paths
+	TransportLocal Transport = "local"
+	// TransportShell runs rsync on the remote host through the remote shell, e.g. ssh
+	TransportShell Transport = "shell"
+	// TransportDaemon connects to a rsync daemon over TCP
+	TransportDaemon Transport = "daemon"
+	// TransportDaemonShell starts a single-use daemon through the remote shell, for daemon paths
+	// with RsyncOptions.Rsh or RemoteShell
+	TransportDaemonShell Transport = "daemonShell"
+)
+
+// Spec is a snapshot of the rsync command of a task, which can be stored as JSON and turned into
+// an equal task again with NewTask. It shares no memory with the task. The secrets of Definition,
+// Options and Command are redacted like by Definition.Redacted, so a spec can be stored like
+// other configuration
+type Spec struct {
+	// Definition is what the task was created from
+	Definition Definition `json:"definition"`
+	// Options are the options rsync runs with: the ones of the definition with those forced by
+	// the task and the preset applied
+	Options RsyncOptions `json:"options"`
+	// Source and Destination are the paths of the last run, or of the next one before the first
+	Source      Endpoint  `json:"source"`
+	Destination Endpoint  `json:"destination"`
+	Transport   Transport `json:"transport"`
+	// Command is the redacted command line, see Task.Command
+	Command []string `json:"command"`
+}
+
+// Spec returns a snapshot of the rsync command of the task. It can be taken at any time, also
+// while the task runs
+func (t *Task) Spec() (Spec, error) {
+	t.mutex.Lock()
+	definition := t.definition
+	source, destination := t.source, t.destination
+	var err error
+	if source == "" {
+		source, destination, err = t.expandPaths()
+	}
+	var command []string
+	if t.rsync != nil {
+		command = t.rsync.Command()
+	}
+	t.mutex.Unlock()
+	if err != nil {
+		return Spec{}, err
+	}
+
+	options, err := definition.Options.Preset.apply(forceOptions(definition.Options, definition.Config))
+	if err != nil {
+		return Spec{}, err
+	}
+	spec := Spec{Options: redactOptions(cloneOptions(options)), Command: command}
+	if spec.Source, err = ParseEndpoint(source); err != nil {
+		return Spec{}, err
+	}
+	// tasks listing a source have no destination
+	if destination != "" {
+		if spec.Destination, err = ParseEndpoint(destination); err != nil {
+			return Spec{}, err
+		}
+	}
+	spec.Transport = transport(spec.Source, spec.Destination, options)
+
+	definition.Options = cloneOptions(definition.Options)
+	spec.Definition = definition.Redacted()
+	return spec, nil
+}
+
+// NewTask returns a pending task created from the definition of the spec. If the definition had
+// secrets, e.g. RSYNC_PASSWORD in Env or a sshpass password in Rsh, the caller must set them on
+// Definition again, NewTask fails with ErrSpecRedacted otherwise
+func (s Spec) NewTask() (*Task, error) {
+	definition := s.Definition
+	if hasRedactedSecrets(definition.Options) {
+		return nil, ErrSpecRedacted
+	}
+	definition.Options = cloneOptions(definition.Options)
+	return definition.NewTask()
+}
+
+// transport returns how rsync reaches the remote one of source and destination
+func transport(source, destination Endpoint, options RsyncOptions) Transport {
+	kind := source.Kind
+	if destination.IsRemote() {
+		kind = destination.Kind
+	}
+	switch kind {
+	case EndpointShell:
+		return TransportShell
+	case EndpointDaemon:
+		if options.Rsh != "" || options.RemoteShell.Command != "" {
+			return TransportDaemonShell
+		}
+		return TransportDaemon
+	}
+	return TransportLocal
+}
+
+// cloneOptions returns a copy of options sharing no slices or maps with it
+func cloneOptions(options RsyncOptions) RsyncOptions {
+	options.Env = append([]string(nil), options.Env...)
+	if options.Credential != nil {
+		credential := *options.Credential
+		credential.Groups = append([]uint32(nil), credential.Groups...)
+		options.Credential = &credential
+	}
+	options.RemoteShell.Args = append([]string(nil), options.RemoteShell.Args...)
+	options.SkipCompress = append([]string(nil), options.SkipCompress...)
+	options.IgnoreFiles = append([]string(nil), options.IgnoreFiles...)
+	options.Exclude = append([]string(nil), options.Exclude...)
+	options.Include = append([]string(nil), options.Include...)
+	options.SocketOptions.Raw = append([]string(nil), options.SocketOptions.Raw...)
+	options.InfoFlags = cloneMap(options.InfoFlags)
+	options.DebugFlags = cloneMap(options.DebugFlags)
+	options.OutFormatFields = cloneMap(options.OutFormatFields)
+	options.ignoreRules = append([]string(nil), options.ignoreRules...)
+	return options
+}
+
+// cloneMap returns a copy of m, nil for nil
+func cloneMap[M ~map[K]V, K comparable, V any](m M) M {
+	if m == nil {
+		return nil
+	}
+	clone := make(M, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
@@ -0,0 +1,117 @@
+package grsync
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpec(t *testing.T) {
+	task, err := NewTask("/data/", "backup@host:/backups/", false, true, RsyncOptions{
+		Archive:     true,
+		Exclude:     []string{"*.tmp"},
+		RemoteShell: RemoteShell{Command: "ssh", Args: []string{"-p", "2222"}},
+		Preset:      PresetWAN,
+		InfoFlags:   OutputFlags{"stats": 2},
+	})
+	assert.Nil(t, err)
+	task.SetLabels(map[string]string{"job": "nightly"})
+
+	spec, err := task.Spec()
+	assert.Nil(t, err)
+	assert.Equal(t, TransportShell, spec.Transport)
+	assert.Equal(t, Endpoint{Kind: EndpointLocal, Path: "/data/"}, spec.Source)
+	assert.Equal(t, Endpoint{Kind: EndpointShell, User: "backup", Host: "host", Path: "/backups/"}, spec.Destination)
+	assert.True(t, spec.Options.HumanReadable)
+	assert.True(t, spec.Options.Compress)
+	assert.False(t, spec.Definition.Options.HumanReadable)
+	assert.Equal(t, task.Command(), spec.Command)
+
+	// the spec shares nothing with the task
+	spec.Definition.Options.Exclude[0] = "changed"
+	spec.Definition.Options.InfoFlags["stats"] = 1
+	spec.Definition.Labels["job"] = "changed"
+	assert.Equal(t, []string{"*.tmp"}, task.Definition().Options.Exclude)
+	assert.Equal(t, OutputFlags{"stats": 2}, task.Definition().Options.InfoFlags)
+	assert.Equal(t, "nightly", task.Labels()["job"])
+
+	spec, err = task.Spec()
+	assert.Nil(t, err)
+	data, err := json.Marshal(spec)
+	assert.Nil(t, err)
+	var restored Spec
+	assert.Nil(t, json.Unmarshal(data, &restored))
+	assert.Equal(t, spec, restored)
+
+	reconstructed, err := restored.NewTask()
+	assert.Nil(t, err)
+	assert.NotEqual(t, task.ID(), reconstructed.ID())
+	assert.Equal(t, task.Command(), reconstructed.Command())
+	assert.Equal(t, task.Definition(), reconstructed.Definition())
+}
+
+func TestSpecRedacted(t *testing.T) {
+	task, err := NewTask("/data/", "rsync://backup@host/module/", false, false, RsyncOptions{
+		Env: []string{"RSYNC_PASSWORD=hunter2", "LANG=C"},
+		Rsh: "sshpass -p hunter2 ssh",
+	})
+	assert.Nil(t, err)
+	spec, err := task.Spec()
+	assert.Nil(t, err)
+	data, err := json.Marshal(spec)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	assert.Equal(t, []string{"RSYNC_PASSWORD=" + redacted, "LANG=C"}, spec.Definition.Options.Env)
+
+	_, err = spec.NewTask()
+	assert.ErrorIs(t, err, ErrSpecRedacted)
+	spec.Definition.Options.Env = task.Definition().Options.Env
+	_, err = spec.NewTask()
+	assert.ErrorIs(t, err, ErrSpecRedacted)
+	spec.Definition.Options.Rsh = task.Definition().Options.Rsh
+	reconstructed, err := spec.NewTask()
+	assert.Nil(t, err)
+	assert.Equal(t, task.Definition(), reconstructed.Definition())
+	// the task kept its secrets
+	assert.Equal(t, "RSYNC_PASSWORD=hunter2", task.Definition().Options.Env[0])
+}
+
+func TestSpecTransport(t *testing.T) {
+	for _, test := range []struct {
+		source, destination string
+		options             RsyncOptions
+		transport           Transport
+	}{
+		{"/data/", "/backup/", RsyncOptions{}, TransportLocal},
+		{"host:/data/", "/backup/", RsyncOptions{}, TransportShell},
+		{"/data/", "rsync://host/module/", RsyncOptions{}, TransportDaemon},
+		{"host::module/", "/backup/", RsyncOptions{Rsh: "ssh"}, TransportDaemonShell},
+	} {
+		task, err := NewTask(test.source, test.destination, false, false, test.options)
+		assert.Nil(t, err)
+		spec, err := task.Spec()
+		assert.Nil(t, err)
+		assert.Equal(t, test.transport, spec.Transport, test.source)
+	}
+}
+
+func TestSpecWhileRunning(t *testing.T) {
+	task, err := NewTask("/data/", "/backup/", false, false, RsyncOptions{
+		RsyncBinaryPath: fakeRsync(t, "sleep 0.1"),
+	})
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = task.Run()
+	}()
+	for i := 0; i < 20; i++ {
+		spec, err := task.Spec()
+		assert.Nil(t, err)
+		assert.Equal(t, "/backup/", spec.Destination.Path)
+	}
+	wg.Wait()
+}
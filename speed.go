@@ -0,0 +1,70 @@
+package grsync
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultSpeedSmoothing is the time constant of the moving average speed
+const DefaultSpeedSmoothing = 5 * time.Second
+
+// speedAverage is an exponentially weighted moving average of the speed reported by rsync.
+// Samples are weighted by the time since the previous sample, so bursts of progress lines don't
+// dominate the average
+type speedAverage struct {
+	value float64
+	last  time.Time
+}
+
+func (a *speedAverage) add(speed float64, now time.Time, smoothing time.Duration) float64 {
+	if a.last.IsZero() || smoothing <= 0 {
+		a.value = speed
+	} else {
+		weight := 1 - math.Exp(-float64(now.Sub(a.last))/float64(smoothing))
+		a.value += weight * (speed - a.value)
+	}
+	a.last = now
+	return a.value
+}
+
+// SetSpeedSmoothing sets the time constant of State.AvgSpeed: the larger it is, the slower the
+// average follows changes of the speed. Zero makes AvgSpeed equal to Speed
+func (t *Task) SetSpeedSmoothing(smoothing time.Duration) {
+	t.mutex.Lock()
+	t.speedSmoothing = smoothing
+	t.mutex.Unlock()
+}
+
+// updateSpeed sets the numeric and the moving average speed from the current speed, the mutex must be held
+func (t *Task) updateSpeed(now time.Time) {
+	base := t.unitBase()
+	speed, err := parseSpeed(t.state.Speed, base)
+	if err != nil {
+		return
+	}
+	t.state.BytesPerSecond = speed
+	var buffer [32]byte
+	assign(&t.state.AvgSpeed, appendSpeed(buffer[:0], t.avgSpeed.add(speed, now, t.speedSmoothing), base))
+}
+
+// updateRunSpeed sets the average speed since the start of the run, the mutex must be held
+func (t *Task) updateRunSpeed(now time.Time) {
+	elapsed := now.Sub(t.startedAt).Seconds()
+	if t.startedAt.IsZero() || elapsed <= 0 {
+		return
+	}
+	t.state.RunBytesPerSecond = float64(t.state.CumulativeBytes) / elapsed
+	var buffer [32]byte
+	assign(&t.state.RunSpeed, appendSpeed(buffer[:0], t.state.RunBytesPerSecond, t.unitBase()))
+}
+
+// formatSpeed formats bytes per second the way rsync does with --human-readable, e.g. "92.23MB/s",
+// in units of base
+func formatSpeed(speed, base float64) string {
+	return string(appendSpeed(nil, speed, base))
+}
+
+// appendSpeed appends the formatted speed to b
+func appendSpeed(b []byte, speed, base float64) []byte {
+	return append(appendSize(b, speed, base), "/s"...)
+}
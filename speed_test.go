@@ -0,0 +1,82 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeedAverage(t *testing.T) {
+	var a speedAverage
+	now := time.Now()
+
+	assert.Equal(t, 100.0, a.add(100, now, time.Second))
+	// a sample right after the previous one barely moves the average
+	assert.InDelta(t, 100.0, a.add(1000, now.Add(time.Millisecond), time.Second), 1)
+	// one time constant later the average moved ~63% of the way
+	assert.InDelta(t, 100+0.632*900, a.add(1000, now.Add(time.Second+time.Millisecond), time.Second), 5)
+
+	a = speedAverage{}
+	a.add(100, now, 0)
+	assert.Equal(t, 500.0, a.add(500, now.Add(time.Millisecond), 0))
+}
+
+func TestFormatSpeed(t *testing.T) {
+	assert.Equal(t, "0.00B/s", formatSpeed(0, 1000))
+	assert.Equal(t, "512.00B/s", formatSpeed(512, 1000))
+	assert.Equal(t, "1.02kB/s", formatSpeed(1020, 1000))
+	assert.Equal(t, "92.23MB/s", formatSpeed(92230000, 1000))
+	assert.Equal(t, "3.40GB/s", formatSpeed(3.4e9, 1000))
+	assert.Equal(t, "1.00MB/s", formatSpeed(1048576, 1024))
+}
+
+func TestTaskAvgSpeed(t *testing.T) {
+	script := `echo "      1.00M   10%   10.00MB/s    0:00:09"
+echo "      2.00M   20%   90.00MB/s    0:00:08"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	state := task.State()
+	assert.Equal(t, "90.00MB/s", state.Speed)
+	// both lines arrive within a fraction of the smoothing period
+	speed, err := ParseSpeed(state.AvgSpeed)
+	assert.Nil(t, err)
+	assert.True(t, speed < 20e6, state.AvgSpeed)
+
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetSpeedSmoothing(0)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "90.00MB/s", task.State().AvgSpeed)
+}
+
+func TestTaskBytesPerSecond(t *testing.T) {
+	script := `echo "      1.00M   10%    1.50MB/s    0:00:09"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, 1.5e6, task.State().BytesPerSecond)
+
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), HumanReadableIEC: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, 1.5*1024*1024, task.State().BytesPerSecond)
+	assert.Equal(t, "1.50MB/s", task.State().AvgSpeed)
+}
+
+func TestTaskRunSpeed(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	start := time.Now()
+	task.startedAt = start
+	task.priorBytes = 1000000
+
+	task.applyProgress(progressFields{total: []byte("1,000,000"), percent: 50, speed: []byte("90.00MB/s")}, start.Add(4*time.Second))
+	state := task.State()
+	assert.Equal(t, "90.00MB/s", state.Speed)
+	assert.Equal(t, 500000.0, state.RunBytesPerSecond)
+	assert.Equal(t, "500.00kB/s", state.RunSpeed)
+}
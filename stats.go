@@ -0,0 +1,87 @@
+package grsync
+
+import (
+	"bytes"
+)
+
+// Stats are the file-transfer stats rsync prints at the end of a transfer with RsyncOptions.Stats
+type Stats struct {
+	Files                   int   `json:"files"`                   // Number of files
+	CreatedFiles            int   `json:"createdFiles"`            // Number of created files
+	DeletedFiles            int   `json:"deletedFiles"`            // Number of deleted files
+	RegularFilesTransferred int   `json:"regularFilesTransferred"` // Number of regular files transferred
+	TotalFileSize           int64 `json:"totalFileSize"`           // Total file size in bytes
+	TotalTransferredSize    int64 `json:"totalTransferredSize"`    // Total transferred file size in bytes
+	LiteralData             int64 `json:"literalData"`             // Literal data in bytes, sent as is
+	MatchedData             int64 `json:"matchedData"`             // Matched data in bytes, found in the basis files
+	FileListSize            int64 `json:"fileListSize"`            // File list size in bytes
+	BytesSent               int64 `json:"bytesSent"`               // Total bytes sent
+	BytesReceived           int64 `json:"bytesReceived"`           // Total bytes received
+	// HardLinks is the number of hard links preserved with RsyncOptions.HardLinks, counted from the
+	// itemized changes since rsync doesn't include it in --stats
+	HardLinks int `json:"hardLinks"`
+}
+
+// Stats returns the stats of the last attempt, they are only reported with RsyncOptions.Stats
+func (t *Task) Stats() Stats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.stats
+}
+
+// DeltaSavings returns the fraction of the transferred files that the delta-transfer algorithm
+// didn't have to send because it matched data in the destination, 0 without data. Savings close to
+// 0 for large files suggest that RsyncOptions.WholeFile would be faster
+func (s Stats) DeltaSavings() float64 {
+	total := s.LiteralData + s.MatchedData
+	if total == 0 {
+		return 0
+	}
+	return float64(s.MatchedData) / float64(total)
+}
+
+// parseStatsLine stores the value of a --stats line like `Total file size: 1.05M bytes` and reports
+// whether line was one. The mutex must be held
+func (t *Task) parseStatsLine(line []byte) bool {
+	name, value, ok := bytes.Cut(bytes.TrimSpace(line), []byte(": "))
+	if !ok {
+		return false
+	}
+	// the value is followed by a unit or by a breakdown like "(reg: 2, dir: 1)"
+	value, _, _ = bytes.Cut(value, []byte(" "))
+
+	n, err := parseSize(string(value), t.unitBase())
+	if err != nil {
+		return false
+	}
+
+	s := &t.stats
+	switch string(name) {
+	case "Number of files":
+		s.Files = int(n)
+	case "Number of created files":
+		s.CreatedFiles = int(n)
+	case "Number of deleted files":
+		s.DeletedFiles = int(n)
+	case "Number of regular files transferred":
+		s.RegularFilesTransferred = int(n)
+	case "Total file size":
+		s.TotalFileSize = n
+	case "Total transferred file size":
+		s.TotalTransferredSize = n
+	case "Literal data":
+		s.LiteralData = n
+	case "Matched data":
+		s.MatchedData = n
+	case "File list size":
+		s.FileListSize = n
+	case "Total bytes sent":
+		s.BytesSent = n
+	case "Total bytes received":
+		s.BytesReceived = n
+	default:
+		return false
+	}
+	t.hasStats = true
+	return true
+}
@@ -0,0 +1,78 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const statsOutput = `Number of files: 3 (reg: 2, dir: 1)
+Number of created files: 2 (reg: 2)
+Number of deleted files: 0
+Number of regular files transferred: 2
+Total file size: 1.05M bytes
+Total transferred file size: 1.05M bytes
+Literal data: 1.05M bytes
+Matched data: 0 bytes
+File list size: 0
+File list generation time: 0.001 seconds
+File list transfer time: 0.000 seconds
+Total bytes sent: 1.05M
+Total bytes received: 57
+
+sent 1.05M bytes  received 57 bytes  2.10M bytes/sec
+total size is 1.05M  speedup is 1.00`
+
+func TestTaskStats(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "cat <<'EOF'\n"+statsOutput+"\nEOF"), Stats: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+
+	assert.Equal(t, Stats{
+		Files:                   3,
+		CreatedFiles:            2,
+		RegularFilesTransferred: 2,
+		TotalFileSize:           1050000,
+		TotalTransferredSize:    1050000,
+		LiteralData:             1050000,
+		BytesSent:               1050000,
+		BytesReceived:           57,
+	}, task.Stats())
+}
+
+func TestParseStatsLine(t *testing.T) {
+	task := &Task{state: &State{}, definition: Definition{Options: RsyncOptions{HumanReadableIEC: true}}}
+	assert.True(t, task.parseStatsLine([]byte("Number of files: 1,234 (reg: 1,000, dir: 234)")))
+	assert.True(t, task.parseStatsLine([]byte("Total file size: 2.00M bytes")))
+	assert.Equal(t, 1234, task.stats.Files)
+	assert.Equal(t, int64(2*1024*1024), task.stats.TotalFileSize)
+
+	for _, line := range []string{"File list generation time: 0.001 seconds", "sending incremental file list", "Total file size: many bytes"} {
+		assert.False(t, task.parseStatsLine([]byte(line)), line)
+	}
+}
+
+func TestStatsDeltaSavings(t *testing.T) {
+	task := &Task{state: &State{}}
+	assert.True(t, task.parseStatsLine([]byte("Literal data: 250 bytes")))
+	assert.True(t, task.parseStatsLine([]byte("Matched data: 750 bytes")))
+	assert.Equal(t, 0.75, task.stats.DeltaSavings())
+	assert.Equal(t, 0.0, Stats{}.DeltaSavings())
+}
+
+func TestTaskHardLinks(t *testing.T) {
+	script := `case "$*" in *--out-format*) ;; *) exit 1;; esac
+echo "::grsync-file:: >f+++++++++ 5 maildir/cur/1"
+echo "::grsync-file:: hf+++++++++ 5 maildir/new/1"
+echo "::grsync-file:: hf+++++++++ 5 maildir/new/2"
+echo "Number of files: 3 (reg: 3)"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), HardLinks: true, Stats: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, 2, task.Stats().HardLinks)
+	assert.Equal(t, 3, task.Stats().Files)
+
+	assert.Nil(t, task.Run())
+	assert.Equal(t, 2, task.Stats().HardLinks)
+}
@@ -0,0 +1,29 @@
+package grsync
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrStdinPty is returned for an input set with Task.SetStdin and RsyncOptions.UsePty, whose
+// terminal would echo and reinterpret it
+var ErrStdinPty = errors.New("stdin can't be passed through a pseudo-terminal")
+
+// SetStdin makes r the input of rsync, which reads it for the options set to "-": FilesFrom,
+// ExcludeFrom or IncludeFrom. The lists never touch the file system, e.g. on read-only ones. Readers
+// implementing io.Seeker, like *strings.Reader, are rewound before every attempt, so retries read
+// them again. Other readers are drained by the first attempt. nil removes the input
+func (t *Task) SetStdin(r io.Reader) {
+	t.mutex.Lock()
+	t.stdin = r
+	t.mutex.Unlock()
+}
+
+// rewindStdin returns the input of the next attempt, rewound if it can be. The mutex must be held
+func (t *Task) rewindStdin() io.Reader {
+	if seeker, ok := t.stdin.(io.Seeker); ok {
+		// pipes implement io.Seeker as well but fail to seek, they are read on
+		_, _ = seeker.Seek(0, io.SeekStart)
+	}
+	return t.stdin
+}
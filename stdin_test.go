@@ -0,0 +1,38 @@
+package grsync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskStdin(t *testing.T) {
+	script := `case "$*" in *"--files-from=- "*) ;; *) exit 1;; esac
+cat`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), FilesFrom: "-"})
+	assert.Nil(t, err)
+	task.SetStdin(strings.NewReader("dir/a\ndir/b\n"))
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "dir/a\ndir/b\n", task.Log().Stdout)
+
+	// the reader is rewound for the next run
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "dir/a\ndir/b\n", task.Log().Stdout)
+
+	task.SetStdin(nil)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, "", task.Log().Stdout)
+}
+
+func TestStdinPty(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{UsePty: true, ExcludeFrom: "-"})
+	assert.Nil(t, err)
+	task.SetStdin(strings.NewReader("*.tmp\n"))
+	assert.ErrorIs(t, task.Run(), ErrStdinPty)
+}
+
+func TestStdinArguments(t *testing.T) {
+	args := getArguments(RsyncOptions{Include: []string{"keep"}, IncludeFrom: "-", ExcludeFrom: "excludes.txt"})
+	assert.Equal(t, []string{"--include=keep", "--include-from=-", "--exclude-from=excludes.txt"}, args)
+}
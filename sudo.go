@@ -0,0 +1,56 @@
+package grsync
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrRemoteSudoDaemon is returned for RsyncOptions.RemoteSudo with a rsync daemon, which doesn't
+// run a remote command
+var ErrRemoteSudoDaemon = errors.New("remote sudo needs a remote shell, not a rsync daemon")
+
+// sudoPasswordScript is the remote shell wrapper of RsyncOptions.RemoteSudoPasswordFile. It writes
+// the first line of the password file ($0) to the remote shell before forwarding the rsync
+// protocol, so the password is neither on a command line nor in the environment. sudo reads its
+// password byte by byte and leaves the rest of stdin to rsync
+const sudoPasswordScript = `{ IFS= read -r p < "$0"; printf '%s\n' "$p"; exec cat; } | "$@"`
+
+// sudoOptions returns options with the --rsync-path and --rsh running the remote rsync through sudo
+func sudoOptions(options RsyncOptions, source, destination string) (RsyncOptions, error) {
+	if !options.RemoteSudo {
+		return options, nil
+	}
+	if isDaemonPath(source) || isDaemonPath(destination) {
+		return options, ErrRemoteSudoDaemon
+	}
+
+	sudo := []string{"sudo"}
+	if options.RemoteSudoPasswordFile != "" {
+		// -k ignores cached credentials, so sudo always consumes the password
+		sudo = append(sudo, "-k", "-S", "-p", "''")
+	} else {
+		// fail instead of waiting for a password nobody enters
+		sudo = append(sudo, "-n")
+	}
+	if options.RemoteSudoUser != "" {
+		sudo = append(sudo, "-u", shellQuote(options.RemoteSudoUser))
+	}
+	rsyncPath := options.RsyncPath
+	if rsyncPath == "" {
+		rsyncPath = "rsync"
+	}
+	options.RsyncPath = strings.Join(append(sudo, rsyncPath), " ")
+
+	if options.RemoteSudoPasswordFile != "" {
+		rsh, err := ParseRemoteShell(options.remoteShell())
+		if err != nil {
+			return options, err
+		}
+		if rsh.Command == "" {
+			rsh.Command = "ssh"
+		}
+		args := []string{"-c", sudoPasswordScript, resolvePath(options.WorkDir, options.RemoteSudoPasswordFile), rsh.Command}
+		options.RemoteShell = RemoteShell{Command: "sh", Args: append(args, rsh.Args...)}
+	}
+	return options, nil
+}
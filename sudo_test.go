@@ -0,0 +1,52 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSudoOptions(t *testing.T) {
+	options, err := sudoOptions(RsyncOptions{RemoteSudo: true}, "host:/etc/", "/backup/")
+	assert.Nil(t, err)
+	assert.Equal(t, "sudo -n rsync", options.RsyncPath)
+	assert.Empty(t, options.remoteShell())
+
+	options, err = sudoOptions(RsyncOptions{RemoteSudo: true, RemoteSudoUser: "backup", RsyncPath: "/opt/rsync/bin/rsync"}, "/data/", "host:/srv/")
+	assert.Nil(t, err)
+	assert.Equal(t, "sudo -n -u 'backup' /opt/rsync/bin/rsync", options.RsyncPath)
+
+	options, err = sudoOptions(RsyncOptions{RemoteSudo: true, RemoteSudoPasswordFile: "sudo.pass", WorkDir: "/etc/grsync", Rsh: "ssh -p 2222"}, "host:/etc/", "/backup/")
+	assert.Nil(t, err)
+	assert.Equal(t, "sudo -k -S -p '' rsync", options.RsyncPath)
+	assert.Equal(t, RemoteShell{Command: "sh", Args: []string{"-c", sudoPasswordScript, "/etc/grsync/sudo.pass", "ssh", "-p", "2222"}}, options.RemoteShell)
+	// rsync splits --rsh back into the same arguments
+	parsed, err := ParseRemoteShell(options.remoteShell())
+	assert.Nil(t, err)
+	assert.Equal(t, options.RemoteShell, parsed)
+
+	_, err = sudoOptions(RsyncOptions{RemoteSudo: true}, "backup::data", "/backup/")
+	assert.Equal(t, ErrRemoteSudoDaemon, err)
+
+	options, err = sudoOptions(RsyncOptions{RsyncPath: "rsync"}, "host:/etc/", "/backup/")
+	assert.Nil(t, err)
+	assert.Equal(t, "rsync", options.RsyncPath)
+}
+
+func TestSudoPasswordScript(t *testing.T) {
+	dir := t.TempDir()
+	password := filepath.Join(dir, "password")
+	assert.Nil(t, ioutil.WriteFile(password, []byte("s3cret"), 0600))
+	// stands in for ssh, printing its arguments and what it receives
+	ssh := fakeRsync(t, `echo "$@"; cat`)
+
+	cmd := exec.Command("sh", "-c", sudoPasswordScript, password, ssh, "host", "rsync", "--server")
+	cmd.Stdin = strings.NewReader("protocol data")
+	out, err := cmd.Output()
+	assert.Nil(t, err)
+	assert.Equal(t, "host rsync --server\ns3cret\nprotocol data", string(out))
+}
@@ -0,0 +1,66 @@
+package grsync
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Summary is the closing summary rsync prints after a transfer, also without RsyncOptions.Stats:
+//
+//	sent 1.05M bytes  received 57 bytes  2.10M bytes/sec
+//	total size is 1.05M  speedup is 1.00
+type Summary struct {
+	BytesSent      int64   `json:"bytesSent"`
+	BytesReceived  int64   `json:"bytesReceived"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	// TotalSize is the size of all files, including the unchanged ones
+	TotalSize int64 `json:"totalSize"`
+	// Speedup is TotalSize divided by the bytes sent and received
+	Speedup float64 `json:"speedup"`
+}
+
+// Summary returns the closing summary of the last attempt, zero until rsync printed it
+func (t *Task) Summary() Summary {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.summary
+}
+
+// parseSummaryLine stores the values of a summary line and reports whether line was one. The
+// mutex must be held
+func (t *Task) parseSummaryLine(line []byte) bool {
+	fields := bytes.Fields(line)
+	base := t.unitBase()
+	s := &t.summary
+	switch {
+	// sent 1.05M bytes  received 57 bytes  2.10M bytes/sec
+	case len(fields) == 8 && string(fields[0]) == "sent" && string(fields[3]) == "received":
+		sent, err := parseSize(string(fields[1]), base)
+		if err != nil {
+			return false
+		}
+		received, err := parseSize(string(fields[4]), base)
+		if err != nil {
+			return false
+		}
+		speed, err := parseSize(string(fields[6]), base)
+		if err != nil {
+			return false
+		}
+		s.BytesSent, s.BytesReceived, s.BytesPerSecond = sent, received, float64(speed)
+	// total size is 1.05M  speedup is 1.00, followed by " (DRY RUN)" for dry runs
+	case len(fields) >= 7 && string(fields[0]) == "total" && string(fields[1]) == "size" && string(fields[4]) == "speedup":
+		total, err := parseSize(string(fields[3]), base)
+		if err != nil {
+			return false
+		}
+		speedup, err := strconv.ParseFloat(string(bytes.ReplaceAll(fields[6], []byte(","), nil)), 64)
+		if err != nil {
+			return false
+		}
+		s.TotalSize, s.Speedup = total, speedup
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskSummary(t *testing.T) {
+	script := `echo 'sent 1.05M bytes  received 57 bytes  2.10M bytes/sec'
+echo 'total size is 12.60M  speedup is 11.99 (DRY RUN)'`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	result, err := task.RunResult()
+	assert.Nil(t, err)
+
+	expected := Summary{BytesSent: 1050000, BytesReceived: 57, BytesPerSecond: 2100000, TotalSize: 12600000, Speedup: 11.99}
+	assert.Equal(t, expected, task.Summary())
+	assert.Equal(t, expected, result.Summary)
+}
+
+func TestParseSummaryLine(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+
+	assert.True(t, task.parseSummaryLine([]byte("sent 1,234 bytes  received 56 bytes  2,580.00 bytes/sec")))
+	assert.True(t, task.parseSummaryLine([]byte("total size is 1,234,567  speedup is 1,000.50")))
+	assert.Equal(t, Summary{BytesSent: 1234, BytesReceived: 56, BytesPerSecond: 2580, TotalSize: 1234567, Speedup: 1000.5}, task.summary)
+
+	assert.False(t, task.parseSummaryLine([]byte("sent by the sender")))
+	assert.False(t, task.parseSummaryLine([]byte("total size is large  speedup is big")))
+}
@@ -0,0 +1,134 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSymlinkConflict is returned for symlink options that contradict each other, e.g.
+// CopyUnsafeLinks together with SafeLinks
+var ErrSymlinkConflict = errors.New("conflicting symlink options")
+
+// SymlinkMode decides what is transferred for a symlink
+type SymlinkMode string
+
+const (
+	// SymlinksDefault copies symlinks as symlinks with Links or Archive and skips them otherwise
+	SymlinksDefault SymlinkMode = ""
+	// SymlinksPreserve copies symlinks as symlinks, --links
+	SymlinksPreserve SymlinkMode = "preserve"
+	// SymlinksFollow copies the files and directories symlinks point to, --copy-links
+	SymlinksFollow SymlinkMode = "follow"
+	// SymlinksSkip skips symlinks also with Archive, --no-links
+	SymlinksSkip SymlinkMode = "skip"
+)
+
+// UnsafeLinks decides what happens to unsafe symlinks, which are absolute or point outside the
+// transferred tree. Any symlink is unsafe if the source isn't trusted
+type UnsafeLinks string
+
+const (
+	// UnsafeLinksDefault treats unsafe symlinks like the others
+	UnsafeLinksDefault UnsafeLinks = ""
+	// UnsafeLinksIgnore skips unsafe symlinks, --safe-links
+	UnsafeLinksIgnore UnsafeLinks = "ignore"
+	// UnsafeLinksCopy copies the files and directories unsafe symlinks point to, --copy-unsafe-links
+	UnsafeLinksCopy UnsafeLinks = "copy"
+)
+
+// SymlinkPolicy groups the options deciding how symlinks are transferred. It is merged with the
+// individual options like CopyLinks or SafeLinks, which must not contradict it. Mirroring an
+// untrusted source safely needs UnsafeLinksIgnore or MungeLinks, so its symlinks can't make the
+// receiver write outside the destination
+type SymlinkPolicy struct {
+	Mode   SymlinkMode
+	Unsafe UnsafeLinks
+	// CopyDirLinks copies the directories symlinks point to, while other symlinks follow Mode, --copy-dirlinks
+	CopyDirLinks bool
+	// KeepDirLinks keeps symlinks to directories on the receiver instead of replacing them with
+	// the directories of the sender, --keep-dirlinks
+	KeepDirLinks bool
+	// MungeLinks stores received symlinks in a form that can't be followed and restores them when
+	// they are sent again, e.g. for a daemon module accepting uploads, --munge-links. rsync 3.1.0 and later
+	MungeLinks bool
+}
+
+// symlinkPolicy merges Symlinks with the individual symlink options and rejects contradicting and
+// unknown ones
+func (r RsyncOptions) symlinkPolicy() (SymlinkPolicy, error) {
+	policy := r.Symlinks
+	switch policy.Mode {
+	case SymlinksDefault, SymlinksPreserve, SymlinksFollow, SymlinksSkip:
+	default:
+		return policy, fmt.Errorf("unknown symlink mode %q", policy.Mode)
+	}
+	switch policy.Unsafe {
+	case UnsafeLinksDefault, UnsafeLinksIgnore, UnsafeLinksCopy:
+	default:
+		return policy, fmt.Errorf("unknown unsafe links handling %q", policy.Unsafe)
+	}
+
+	if r.CopyLinks {
+		if policy.Mode != SymlinksDefault && policy.Mode != SymlinksFollow {
+			return policy, fmt.Errorf("%w: CopyLinks with symlink mode %s", ErrSymlinkConflict, policy.Mode)
+		}
+		policy.Mode = SymlinksFollow
+	}
+	if r.Links && policy.Mode == SymlinksSkip {
+		return policy, fmt.Errorf("%w: Links with symlink mode %s", ErrSymlinkConflict, policy.Mode)
+	}
+	for _, option := range []struct {
+		name   string
+		set    bool
+		unsafe UnsafeLinks
+	}{
+		{"SafeLinks", r.SafeLinks, UnsafeLinksIgnore},
+		{"CopyUnsafeLinks", r.CopyUnsafeLinks, UnsafeLinksCopy},
+	} {
+		if !option.set {
+			continue
+		}
+		if policy.Unsafe != UnsafeLinksDefault && policy.Unsafe != option.unsafe {
+			return policy, fmt.Errorf("%w: %s with unsafe links %s", ErrSymlinkConflict, option.name, policy.Unsafe)
+		}
+		policy.Unsafe = option.unsafe
+	}
+
+	if policy.Mode == SymlinksFollow && policy.Unsafe != UnsafeLinksDefault {
+		return policy, fmt.Errorf("%w: unsafe links %s while all symlinks are followed", ErrSymlinkConflict, policy.Unsafe)
+	}
+	policy.CopyDirLinks = policy.CopyDirLinks || r.CopyDirLinks
+	policy.KeepDirLinks = policy.KeepDirLinks || r.KeepDirLinks
+	return policy, nil
+}
+
+// arguments returns the rsync arguments of the policy. Links is passed separately
+func (p SymlinkPolicy) arguments(links bool) []string {
+	var arguments []string
+	switch p.Mode {
+	case SymlinksPreserve:
+		if !links {
+			arguments = append(arguments, "--links")
+		}
+	case SymlinksFollow:
+		arguments = append(arguments, "--copy-links")
+	case SymlinksSkip:
+		arguments = append(arguments, "--no-links")
+	}
+	switch p.Unsafe {
+	case UnsafeLinksIgnore:
+		arguments = append(arguments, "--safe-links")
+	case UnsafeLinksCopy:
+		arguments = append(arguments, "--copy-unsafe-links")
+	}
+	if p.CopyDirLinks {
+		arguments = append(arguments, "--copy-dirlinks")
+	}
+	if p.KeepDirLinks {
+		arguments = append(arguments, "--keep-dirlinks")
+	}
+	if p.MungeLinks {
+		arguments = append(arguments, "--munge-links")
+	}
+	return arguments
+}
@@ -0,0 +1,81 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymlinkPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		options RsyncOptions
+		args    []string
+		err     string
+	}{
+		{
+			name:    "untrusted mirror",
+			options: RsyncOptions{Symlinks: SymlinkPolicy{Mode: SymlinksPreserve, Unsafe: UnsafeLinksIgnore, MungeLinks: true}},
+			args:    []string{"--links", "--safe-links", "--munge-links"},
+		},
+		{
+			name:    "links passed once",
+			options: RsyncOptions{Links: true, Symlinks: SymlinkPolicy{Mode: SymlinksPreserve}},
+			args:    []string{"--links"},
+		},
+		{
+			name:    "archive without symlinks",
+			options: RsyncOptions{Archive: true, Symlinks: SymlinkPolicy{Mode: SymlinksSkip, KeepDirLinks: true}},
+			args:    []string{"--archive", "--no-links", "--keep-dirlinks"},
+		},
+		{
+			name:    "individual options",
+			options: RsyncOptions{CopyLinks: true, CopyDirLinks: true, Symlinks: SymlinkPolicy{Mode: SymlinksFollow}},
+			args:    []string{"--copy-links", "--copy-dirlinks"},
+		},
+		{
+			name:    "copy unsafe links",
+			options: RsyncOptions{Links: true, CopyUnsafeLinks: true},
+			args:    []string{"--links", "--copy-unsafe-links"},
+		},
+		{
+			name:    "safe and copy unsafe",
+			options: RsyncOptions{SafeLinks: true, CopyUnsafeLinks: true},
+			err:     "conflicting symlink options: CopyUnsafeLinks with unsafe links ignore",
+		},
+		{
+			name:    "follow with unsafe handling",
+			options: RsyncOptions{CopyLinks: true, Symlinks: SymlinkPolicy{Unsafe: UnsafeLinksIgnore}},
+			err:     "conflicting symlink options: unsafe links ignore while all symlinks are followed",
+		},
+		{
+			name:    "preserve and copy links",
+			options: RsyncOptions{CopyLinks: true, Symlinks: SymlinkPolicy{Mode: SymlinksPreserve}},
+			err:     "conflicting symlink options: CopyLinks with symlink mode preserve",
+		},
+		{
+			name:    "skip and links",
+			options: RsyncOptions{Links: true, Symlinks: SymlinkPolicy{Mode: SymlinksSkip}},
+			err:     "conflicting symlink options: Links with symlink mode skip",
+		},
+		{
+			name:    "unknown mode",
+			options: RsyncOptions{Symlinks: SymlinkPolicy{Mode: "dereference"}},
+			err:     `unknown symlink mode "dereference"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.options.symlinkPolicy()
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				_, err = NewTask("a", "b", false, false, test.options)
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, test.args, getArguments(test.options))
+		})
+	}
+}
@@ -3,34 +3,241 @@ package grsync
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"io"
+	"log/slog"
+	"net"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TaskStatus describes the lifecycle stage of a task
+type TaskStatus string
+
+const (
+	// TaskPending is the status of a task that has not been run yet
+	TaskPending TaskStatus = "pending"
+	// TaskRunning is the status of a task while Run is in progress
+	TaskRunning TaskStatus = "running"
+	// TaskSucceeded is the status of a task whose last run finished without error
+	TaskSucceeded TaskStatus = "succeeded"
+	// TaskFailed is the status of a task whose last run returned an error
+	TaskFailed TaskStatus = "failed"
+	// TaskCancelled is the status of a task stopped by Cancel
+	TaskCancelled TaskStatus = "cancelled"
 )
 
 // Task is high-level API under rsync
 type Task struct {
-	rsync *Rsync
+	id         string
+	rsync      *Rsync
+	definition Definition
+	lock       Locker
+	// source and destination are the paths of the definition with their templates expanded
+	source      string
+	destination string
+	pathVars    map[string]string
+
+	hooks       []Hooks
+	preHooks    []CommandHook
+	postHooks   []CommandHook
+	hookResults []HookResult
+	retryPolicy RetryPolicy
+
+	fileEventCh        chan FileEvent
+	fileEventCallbacks []func(FileEvent)
+	stderrCh           chan string
+	stderrCallbacks    []func(string)
+	stdoutWriter       io.Writer
+	stderrWriter       io.Writer
+	backpressure       Backpressure
+	parsers            []LineParser
+	logFileOptions     *LogFileOptions
+	logger             *slog.Logger
+	logCategories      LineCategory
+	forwardCategories  LineCategory
+
+	compatibility Compatibility
+	runner        CommandRunner
+	stdin         io.Reader
+	argvHook      ArgvHook
+
+	checkpointStore CheckpointStore
+	checkpointKey   string
+	runStore        RunStore
+	runStoreKey     string
+
+	backpressureCounters backpressureCounters
+
+	state     *State
+	stdoutLog logBuffer
+	stderrLog logBuffer
+	mutex     sync.Mutex
+	status    TaskStatus
+	err       error
+	done      chan struct{}
+	// running is set from the start of Run until done is closed, after the status left TaskRunning
+	running   bool
+	cancel    chan struct{}
+	started   bool
+	cancelled bool
+	attempts  int
+	totals    Totals
+
+	startedAt  time.Time
+	finishedAt time.Time
+	// priorBytes are the bytes transferred by the finished attempts of the current run
+	priorBytes int64
+	// resumeNext makes the next attempt continue the partial files, see ResumeToken
+	resumeNext bool
+
+	maxReconnects  int
+	networkDropped bool
+
+	bandwidthSchedule BandwidthSchedule
+	bandwidthLimit    int
+	// bandwidthChange is when the limit of the current command changes, bandwidthRelaunch is set
+	// once rsync was killed for it
+	bandwidthChange   time.Time
+	bandwidthRelaunch bool
+
+	stallTimeout time.Duration
+	// watchdogTimeout is stallTimeout adjusted for the timeouts of the current command
+	watchdogTimeout time.Duration
+	// lastOutput is the time in Unix nanoseconds rsync last printed anything, see LastActivity
+	lastOutput atomic.Int64
+	// lastStateChange is the time the state last changed
+	lastStateChange time.Time
+
+	stats    Stats
+	hasStats bool
+	summary  Summary
+	warnings []Warning
+	deleted  []string
+	// negotiation is what rsync negotiated during the current attempt
+	negotiation Negotiation
+
+	fileErrors    []FileError
+	errorPatterns ErrorPatterns
+	deleteLimit   *DeleteLimit
+	// msgs2stderr is set if the current command prints its messages on stderr
+	msgs2stderr bool
+	// usePty is set if the current command runs on a pseudo-terminal, which merges stderr into stdout
+	usePty bool
+
+	maxLineLength  int
+	truncatedLines int
+
+	manifestEnabled bool
+	// manifestFormat is set if the current command prints its file events with manifestEventFormat
+	manifestFormat bool
+	manifest       Manifest
+	// outFormat parses the file events printed with RsyncOptions.CustomOutFormat, nil for the own formats
+	outFormat *outFormat
+	// logChecksums is set if RsyncOptions.LogFile is written with ChecksumLogFileFormat
+	logChecksums bool
 
-	state *State
-	log   *Log
-	mutex sync.Mutex
+	stateInterval  time.Duration
+	progressBasis  ProgressBasis
+	speedSmoothing time.Duration
+	avgSpeed       speedAverage
+
+	sizeEstimate int64
+
+	cleanupOnCancel bool
+	cleanup         *CleanupResult
+	// partialFiles are the files rsync named in the run, whose partial files cleanup removes
+	partialFiles []string
+
+	// process is the started rsync process of the current attempt
+	process          *ProcessInfo
+	processCallbacks []func(ProcessInfo)
+
+	unparsedLines     int
+	unparsedCallbacks []func(line string)
+
+	// dryRun is set if the current command only simulates the transfer
+	dryRun bool
+
+	// perFileProgress is set if the current command prints the progress of every file
+	perFileProgress       bool
+	fileProgress          FileProgress
+	fileProgressCallbacks []func(FileProgress)
+
+	askPass AskPassFunc
+	// secrets are the passwords of the rsync command, see Redact
+	secrets []string
+	// askPassEnv are the variables of the askpass helper of the current run
+	askPassEnv []string
+	// dscpRelay is the address of the DSCP relay of the current run, see startDSCPRelay
+	dscpRelay *net.TCPAddr
+
+	recordingEnabled bool
+	recording        *Recording
+
+	historyInterval time.Duration
+	historyLimit    int
+	history         []StateSample
 }
 
 // State contains information about rsync process
 type State struct {
-	TimeRemaining   string `json:"remain"`   // Time Remaining (hh:mm:ss)
-	DownloadedTotal string `json:"total"`    // Amount of downloaded Data in unknown unit
-	Speed           string `json:"speed"`    // Speed of download in unknown unit
-	Progress        int    `json:"progress"` // Progress in percent (0-100)
+	TimeRemaining    string  `json:"remain"`          // Time Remaining (hh:mm:ss)
+	DownloadedTotal  string  `json:"total"`           // Amount of downloaded Data in unknown unit
+	BytesTransferred int64   `json:"bytes"`           // DownloadedTotal converted into bytes
+	CumulativeBytes  int64   `json:"cumulativeBytes"` // BytesTransferred summed over all attempts of the run
+	Reconnects       int     `json:"reconnects"`      // Relaunches after a dropped connection, see SetReconnects
+	Speed            string  `json:"speed"`           // Speed of download in unknown unit
+	AvgSpeed         string  `json:"avgSpeed"`        // Moving average of Speed, see SetSpeedSmoothing
+	BytesPerSecond   float64 `json:"bytesPerSecond"`  // Speed converted into bytes per second
+	Progress         int     `json:"progress"`        // Progress in percent (0-100), see SetProgressBasis
+
+	// RunSpeed and RunBytesPerSecond are the average speed of the whole run, CumulativeBytes by
+	// the time since it started. Speed is the speed of the current file, which is much higher
+	// than the average on many small files since rsync doesn't count the time between files
+	RunSpeed          string  `json:"runSpeed"`
+	RunBytesPerSecond float64 `json:"runBytesPerSecond"`
+
+	// BytesProgress is the percentage printed by rsync and FileProgress the percentage of files
+	// checked, computed from FilesRemaining and FilesTotal as reported by to-chk or ir-chk.
+	// FilesTransferred is the number of files the current attempt transferred as counted by xfr#
+	BytesProgress    int `json:"bytesProgress"`
+	FileProgress     int `json:"fileProgress"`
+	FilesRemaining   int `json:"filesRemaining"`
+	FilesTotal       int `json:"filesTotal"`
+	FilesTransferred int `json:"filesTransferred"`
+
+	// Scanning is set while rsync builds the file list, during incremental recursion (ir-chk) or
+	// while it counts the files of a complete list. FilesScanned is the number of files found so far
+	Scanning     bool `json:"scanning,omitempty"`
+	FilesScanned int  `json:"filesScanned,omitempty"`
+
+	// ETA is the time remaining as reported by rsync or, if ETAEstimated is set, as computed
+	// from the progress and AvgSpeed because rsync didn't report it
+	ETA          time.Duration `json:"eta"`
+	ETAEstimated bool          `json:"etaEstimated"`
+
+	// DryRun is set for runs with RsyncOptions.DryRun. BytesTransferred and FilesTransferred are
+	// what a real run would transfer then, counted from the file events
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Custom contains the fields extracted by parsers registered with AddParser
+	Custom map[string]string `json:"custom,omitempty"`
 }
 
 // Log contains raw stderr and stdout outputs
 type Log struct {
 	Stderr string `json:"stderr"`
 	Stdout string `json:"stdout"`
+
+	// StderrTruncated and StdoutTruncated are the number of bytes dropped because of SetLogLimits
+	StderrTruncated int64 `json:"stderrTruncated,omitempty"`
+	StdoutTruncated int64 `json:"stdoutTruncated,omitempty"`
 }
 
 // State returns information about rsync processing task
@@ -38,16 +245,44 @@ type Log struct {
 func (t *Task) State() State {
 	t.mutex.Lock()
 	c := *t.state
+	if t.state.Custom != nil {
+		c.Custom = make(map[string]string, len(t.state.Custom))
+		for key, value := range t.state.Custom {
+			c.Custom[key] = value
+		}
+	}
 	t.mutex.Unlock()
 	return c
 }
 
+// SetLogLimits bounds the memory used to accumulate stdout and stderr each: only the first head and
+// the last tail bytes are kept. Zero for both keeps everything, which is the default.
+// Accumulated output is discarded
+func (t *Task) SetLogLimits(head, tail int) {
+	t.mutex.Lock()
+	t.stdoutLog.setLimits(head, tail)
+	t.stderrLog.setLimits(head, tail)
+	t.mutex.Unlock()
+}
+
+// DiscardLog stops accumulating stdout and stderr in memory, Log returns empty outputs afterwards.
+// The output is still parsed into State and passed to writers, events and the logger.
+// GetFileList needs the accumulated output and returns nothing for such tasks
+func (t *Task) DiscardLog() {
+	t.mutex.Lock()
+	t.stdoutLog = logBuffer{discard: true}
+	t.stderrLog = logBuffer{discard: true}
+	t.mutex.Unlock()
+}
+
 // Log return structure which contains raw stderr and stdout outputs
 func (t *Task) Log() Log {
 	t.mutex.Lock()
 	l := Log{
-		Stderr: t.log.Stderr,
-		Stdout: t.log.Stdout,
+		Stderr:          t.redact(t.stderrLog.String()),
+		Stdout:          t.redact(t.stdoutLog.String()),
+		StderrTruncated: t.stderrLog.Dropped(),
+		StdoutTruncated: t.stdoutLog.Dropped(),
 	}
 	t.mutex.Unlock()
 	return l
@@ -57,22 +292,385 @@ func (t *Task) Log() Log {
 // The Information is returned as a slice of slices of strings in the following format:
 // Index	Value
 // 0		Permissions
-// 1		Size in Bytes
+// 1		Size as printed by rsync, e.g. "1,234,567" or "1.23M", see ListEntries for bytes
 // 2		Date
 // 3		Time
 // 4		Name
 func (t *Task) GetFileList() (files [][]string) {
-	r := regexp.MustCompile(`([drwx-]{10}) +([\d.A-Z]+) ((?:\d+/){2}\d+) ((?:\d+:){2}\d+) (.*)`)
-	for _, l := range strings.Split(t.Log().Stdout, "\n") {
-		if r.MatchString(l) {
-			files = append(files, r.FindStringSubmatch(l)[1:])
+	t.mutex.Lock()
+	stdout := t.stdoutLog.view()
+	t.mutex.Unlock()
+
+	eachLine(stdout, func(line []byte) {
+		match := fileListPattern.FindSubmatch(line)
+		if match == nil {
+			return
 		}
-	}
+		file := make([]string, len(match)-1)
+		for i := range file {
+			file[i] = string(match[i+1])
+		}
+		file[4] = unescapeName(file[4])
+		files = append(files, file)
+	})
 	return
 }
 
-// Run starts rsync process with options
+// fileListPattern matches the entries of a listing. Sizes may contain thousands separators and
+// a human-readable suffix like "1.23K" or "4.00Mi"
+var fileListPattern = regexp.MustCompile(`([-bcdlpsStTrwx]{10}) +([\d,.]+[A-Za-z]*) ((?:\d+/){2}\d+) ((?:\d+:){2}\d+) (.*)`)
+
+// ID returns the identifier of the task, generated on creation unless set with SetID
+func (t *Task) ID() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.id
+}
+
+// SetID replaces the generated identifier of the task. It must be called before the task is
+// registered with a Manager
+func (t *Task) SetID(id string) {
+	t.mutex.Lock()
+	t.id = id
+	t.mutex.Unlock()
+}
+
+// Status returns the lifecycle stage of the task
+func (t *Task) Status() TaskStatus {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.status
+}
+
+// Err returns the error of the last finished run
+func (t *Task) Err() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.err
+}
+
+// Done returns a channel that is closed when the current or next run finishes
+func (t *Task) Done() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.done
+}
+
+// Definition returns the definition the task was created from
+func (t *Task) Definition() Definition {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	d := t.definition
+	d.Labels = cloneLabels(d.Labels)
+	return d
+}
+
+// Cancel stops the rsync process of a running task. If the task has not been started yet,
+// the process is killed as soon as it starts. A pending retry is abandoned
+func (t *Task) Cancel() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.cancelled {
+		t.cancelled = true
+		close(t.cancel)
+	}
+	if !t.started || t.status != TaskRunning {
+		return nil
+	}
+	if err := t.rsync.Kill(); !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// SetLock sets a lock which is held for the duration of Run. Run fails with the error
+// of the lock if it can't be acquired, e.g. ErrLocked
+func (t *Task) SetLock(lock Locker) {
+	t.lock = lock
+}
+
+// Run starts rsync process with options. A task can be run again once it finished, every run
+// starts with a fresh State and Log, see Totals for the counters of all runs. Run returns
+// ErrTaskRunning if the task is already running
 func (t *Task) Run() error {
+	t.mutex.Lock()
+	if t.running {
+		t.mutex.Unlock()
+		return ErrTaskRunning
+	}
+	t.running = true
+	t.status = TaskRunning
+	t.resetRun()
+	t.startRecording()
+	t.mutex.Unlock()
+
+	counted := expvarStart()
+	t.fireStart()
+	stopHistory := t.startHistory()
+	err := t.execute()
+	stopHistory()
+	t.completeManifest()
+	if counted {
+		expvarFinish(err, t.State())
+	}
+
+	t.mutex.Lock()
+	t.err = err
+	t.finishedAt = time.Now()
+	switch {
+	case t.cancelled:
+		t.status = TaskCancelled
+	case err != nil:
+		t.status = TaskFailed
+	default:
+		t.status = TaskSucceeded
+	}
+	cleanup := t.status == TaskCancelled && t.cleanupOnCancel
+	t.mutex.Unlock()
+
+	if cleanup {
+		t.cleanupCancelled()
+	}
+
+	t.saveRun(err)
+	t.closeFileEvents()
+	t.closeErrors()
+	t.fireFinish(err)
+
+	t.mutex.Lock()
+	t.countRun()
+	close(t.done)
+	t.running = false
+	t.mutex.Unlock()
+
+	return err
+}
+
+// execute runs the command hooks and the transfer while holding the lock of the task
+func (t *Task) execute() error {
+	if ReadOnly() {
+		if err := checkReadOnly(t.Definition()); err != nil {
+			return err
+		}
+	}
+	if t.lock != nil {
+		if err := t.lock.Lock(); err != nil {
+			return err
+		}
+		defer func() { _ = t.lock.Unlock() }()
+	}
+
+	stopAskPass, err := t.startAskPass()
+	if err != nil {
+		return err
+	}
+	defer stopAskPass()
+
+	stopRelay, err := t.startDSCPRelay()
+	if err != nil {
+		return err
+	}
+	defer stopRelay()
+
+	err = t.runPreHooks()
+	if err == nil {
+		err = t.transfer()
+	}
+	return t.runPostHooks(err)
+}
+
+// transfer runs the attempts, saving checkpoints if the task has a checkpoint store
+func (t *Task) transfer() error {
+	if t.checkpointStore == nil {
+		return t.attempt()
+	}
+
+	if err := t.checkpoint(nil); err != nil {
+		return err
+	}
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go t.checkpointLoop(done, stopped)
+
+	err := t.attempt()
+	close(done)
+	// a save in flight would recreate the checkpoint after it is deleted
+	<-stopped
+	if err != nil {
+		_ = t.checkpoint(err)
+		return err
+	}
+	_ = t.checkpointStore.Delete(t.checkpointKey)
+	return nil
+}
+
+// attempt runs rsync until it succeeds or the retry policy gives up
+func (t *Task) attempt() error {
+	t.mutex.Lock()
+	policy := t.retryPolicy
+	// the first attempt of a task created from a ResumeToken continues the partial files as well
+	resume := t.resumeNext
+	t.resumeNext = false
+	t.mutex.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		if err := t.prepare(resume); err != nil {
+			return err
+		}
+
+		t.mutex.Lock()
+		t.attempts++
+		t.networkDropped = false
+		t.bandwidthRelaunch = false
+		t.stats, t.hasStats = Stats{}, false
+		t.summary = Summary{}
+		t.negotiation = Negotiation{}
+		t.state.FilesTransferred = 0
+		t.mutex.Unlock()
+
+		err := t.run()
+		resume = policy.Resume
+		if err != nil && t.relaunchForBandwidth() {
+			t.logEvent(slog.LevelInfo, "rsync relaunching with new bandwidth limit", slog.Int("attempt", attempt))
+			t.carryBytes()
+			resume = true
+			attempt--
+			continue
+		}
+		if err != nil && t.reconnect() {
+			t.logEvent(slog.LevelWarn, "rsync reconnecting", slog.Int("attempt", attempt), slog.Any("error", err))
+			t.carryBytes()
+			attempt--
+			continue
+		}
+		if err == nil || t.isCancelled() || !policy.shouldRetry(attempt, err) {
+			return err
+		}
+
+		t.logEvent(slog.LevelInfo, "rsync retrying", slog.Int("attempt", attempt+1), slog.Any("error", err))
+		t.fireRetry(attempt+1, err)
+		if t.checkpointStore != nil {
+			_ = t.checkpoint(err)
+		}
+
+		t.carryBytes()
+
+		timer := time.NewTimer(policy.delay(attempt + 1))
+		select {
+		case <-timer.C:
+		case <-t.cancel:
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// carryBytes adds the bytes of the failed attempt to priorBytes, the next attempt reports its bytes from zero
+func (t *Task) carryBytes() {
+	t.mutex.Lock()
+	t.priorBytes += t.state.BytesTransferred
+	t.state.BytesTransferred = 0
+	t.mutex.Unlock()
+}
+
+func (t *Task) isCancelled() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.cancelled
+}
+
+// prepare builds a new rsync command from the definition of the task. resume adds the options
+// continuing the partial files of a failed attempt
+func (t *Task) prepare(resume bool) error {
+	t.mutex.Lock()
+	source, destination, err := t.expandPaths()
+	t.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	options := t.definition.Options
+	if resume {
+		options = resumeOptions(options)
+	}
+
+	options = forceOptions(options, t.definition.Config)
+	legacy := t.legacy()
+	if options.LogFile != "" && options.LogFileFormat == "" {
+		options.LogFileFormat = StructuredLogFileFormat
+		if !legacy && options.comparesChecksums() {
+			options.LogFileFormat = ChecksumLogFileFormat
+		}
+	}
+	if legacy {
+		options = legacyOptions(options)
+	}
+
+	var extraArguments []string
+	t.mutex.Lock()
+	t.dryRun = options.DryRun
+	t.state.DryRun = options.DryRun
+	t.logChecksums = options.LogFileFormat == ChecksumLogFileFormat
+	t.outFormat = nil
+	t.manifestFormat = false
+	switch {
+	case t.wantsFileEvents() && options.CustomOutFormat != "":
+		if t.outFormat, err = parseOutFormat(options.CustomOutFormat, options.OutFormatFields); err != nil {
+			t.mutex.Unlock()
+			return err
+		}
+		flag := "--out-format="
+		if legacy {
+			flag = "--log-format="
+		}
+		extraArguments = append(extraArguments, flag+fileEventPrefix+options.CustomOutFormat)
+	case t.wantsFileEvents() && legacy:
+		// the manifest lacks modification times and checksums, which rsync 2.6.9 can't print
+		extraArguments = append(extraArguments, legacyEventFormat)
+	case t.manifestEnabled:
+		t.manifestFormat = true
+		extraArguments = append(extraArguments, manifestEventFormat)
+	case t.wantsFileEvents():
+		extraArguments = append(extraArguments, fileEventFormat)
+	}
+	options.InfoFlags = requiredInfoFlags(options.InfoFlags, !t.definition.Config.NoProgress, t.wantsFileEvents())
+	t.msgs2stderr = options.Msgs2Stderr
+	t.usePty = options.UsePty
+	t.perFileProgress = perFileProgress(options)
+	if len(t.askPassEnv) > 0 {
+		options.Env = append(append([]string(nil), options.Env...), t.askPassEnv...)
+	}
+	t.watchdogTimeout = watchdogTimeout(t.stallTimeout, options)
+	options.stdin = t.rewindStdin()
+	options.argvHook = t.argvHook
+	options = t.scheduleBandwidth(options)
+	rsyncSource, rsyncDestination := t.relayed(source), t.relayed(destination)
+	t.mutex.Unlock()
+
+	d := t.definition
+	t.mutex.Lock()
+	runner := t.runner
+	t.mutex.Unlock()
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	rsync, err := newRsync(rsyncSource, rsyncDestination, d.UseSshPass, d.CreateDir, options, extraArguments, runner)
+	if err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	t.rsync = rsync
+	t.secrets = commandSecrets(rsync.command)
+	t.source = source
+	t.destination = destination
+	t.started = false
+	t.mutex.Unlock()
+	return nil
+}
+
+// run executes a single rsync process and processes its output
+func (t *Task) run() error {
 	stderr, err := t.rsync.StderrPipe()
 	if err != nil {
 		return err
@@ -84,10 +682,36 @@ func (t *Task) Run() error {
 		return err
 	}
 
+	t.mutex.Lock()
+	stdoutWriter, closeStdoutWriter := consumerWriter(t.stdoutWriter, t.backpressure, &t.backpressureCounters.stdoutWriter)
+	stderrWriter, closeStderrWriter := consumerWriter(t.stderrWriter, t.backpressure, &t.backpressureCounters.stderrWriter)
+	defer closeStdoutWriter()
+	defer closeStderrWriter()
+	attempt := t.attempts
+	stallTimeout := t.watchdogTimeout
+	recorder := t.recordAttempt()
+	t.mutex.Unlock()
+
+	logFile, err := t.openLogFile(attempt)
+	if err != nil {
+		_ = stdout.Close()
+		_ = stderr.Close()
+		return err
+	}
+	// assigning a nil *RotatingFile would make the interface non-nil
+	var logWriter io.Writer
+	if logFile != nil {
+		logWriter = logFile
+		defer logFile.Close()
+	}
+
+	stdoutReader := activityReader{r: stdout, last: &t.lastOutput}
+	stderrReader := activityReader{r: stderr, last: &t.lastOutput}
+
 	var wg sync.WaitGroup
-	go processStdout(&wg, t, stdout)
-	go processStderr(&wg, t, stderr)
 	wg.Add(2)
+	go processStdout(&wg, t, tee(stdoutReader, stdoutWriter, logWriter, recorder.writer("stdout")))
+	go processStderr(&wg, t, tee(stderrReader, stderrWriter, logWriter, recorder.writer("stderr")))
 
 	if err = t.rsync.Start(); err != nil {
 		// Close pipes to unblock goroutines
@@ -98,102 +722,370 @@ func (t *Task) Run() error {
 		return err
 	}
 
+	start := time.Now()
+	t.mutex.Lock()
+	t.started = true
+	if t.cancelled {
+		_ = t.rsync.Kill()
+	}
+	t.mutex.Unlock()
+	t.logEvent(slog.LevelInfo, "rsync started", slog.Int("attempt", attempt))
+	t.startProcess(attempt, start)
+
+	stopBandwidth := t.watchBandwidth()
+	stalled := make(chan bool, 1)
+	watchdogDone := make(chan struct{})
+	if stallTimeout > 0 {
+		go func() { stalled <- t.watchdog(stallTimeout, start, watchdogDone) }()
+	} else {
+		stalled <- false
+	}
+
 	wg.Wait()
 
-	return t.rsync.Wait()
+	err = t.rsync.Wait()
+	recorder.finish(t, err)
+	t.stopProcess()
+	stopBandwidth()
+	close(watchdogDone)
+	if <-stalled {
+		err = stallError(stallTimeout)
+	}
+	if err != nil {
+		t.logEvent(slog.LevelError, "rsync failed", slog.Int("attempt", attempt), slog.Duration("duration", time.Since(start)), slog.Any("error", err))
+	} else {
+		t.logEvent(slog.LevelInfo, "rsync finished", slog.Int("attempt", attempt), slog.Duration("duration", time.Since(start)))
+	}
+	return err
 }
 
 // NewTask returns new rsync task
 func NewTask(source, destination string, useSshPass, createDir bool, rsyncOptions RsyncOptions) (*Task, error) {
-	// Force set required options
-	rsyncOptions.HumanReadable = true
-	rsyncOptions.Partial = true
-	rsyncOptions.Progress = true
-
-	task, err := NewRsync(source, destination, useSshPass, createDir, rsyncOptions)
-	if err != nil {
+	task := newTask(source, destination, useSshPass, createDir, rsyncOptions)
+	if err := task.prepare(false); err != nil {
 		return nil, err
 	}
+	return task, nil
+}
 
+// newTask returns a task which still needs to be prepared
+func newTask(source, destination string, useSshPass, createDir bool, rsyncOptions RsyncOptions) *Task {
 	return &Task{
-		rsync: task,
-		state: &State{},
-		log:   &Log{},
-	}, nil
+		id: newTaskID(),
+		definition: Definition{
+			Source:      source,
+			Destination: destination,
+			UseSshPass:  useSshPass,
+			CreateDir:   createDir,
+			Options:     rsyncOptions,
+		},
+		state:  &State{},
+		status: TaskPending,
+
+		speedSmoothing:    DefaultSpeedSmoothing,
+		logCategories:     AllLines,
+		forwardCategories: AllLines,
+		done:              make(chan struct{}),
+		cancel:            make(chan struct{}),
+	}
 }
 
-func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
+// lineSplitter splits the output of rsync into lines for a bufio.Scanner. Lines end with LF, CRLF
+// or a single CR, which separates the updates of a progress line. The terminators may be split
+// across reads: a LF following a CR of the previous read completes that CRLF. rsync starts
+// progress updates with a CR as well, the empty segments this leaves are skipped.
+// Lines longer than maxLength, DefaultMaxLineLength if zero, are truncated to it and the remainder up to the next terminator is
+// discarded, truncated is set for the returned token then
+type lineSplitter struct {
+	maxLength  int
+	afterCR    bool
+	discarding bool
+	truncated  bool
+}
+
+// limit returns the maximum line length, DefaultMaxLineLength if it isn't set
+func (s *lineSplitter) limit() int {
+	if s.maxLength <= 0 {
+		return DefaultMaxLineLength
 	}
-	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
-		if data[i] == '\n' {
-			// We have a line terminated by single newline.
-			return i + 1, data[0:i], nil
+	return s.maxLength
+}
+
+// scanner returns a scanner splitting r, its buffer is large enough for the longest line
+func (s *lineSplitter) scanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	// the buffer also holds the terminators skipped before a line
+	scanner.Buffer(make([]byte, 0, min(s.limit()+2, bufio.MaxScanTokenSize)), s.limit()+2)
+	scanner.Split(s.split)
+	return scanner
+}
+
+func (s *lineSplitter) split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	s.truncated = false
+	maxLength := s.limit()
+	for {
+		if s.afterCR && advance < len(data) {
+			s.afterCR = false
+			if data[advance] == '\n' {
+				advance++
+			}
 		}
-		advance = i + 1
-		if len(data) > i+1 && data[i+1] == '\n' {
-			advance += 1
+		rest := data[advance:]
+		i := bytes.IndexAny(rest, "\r\n")
+		if s.discarding {
+			if i < 0 {
+				return len(data), nil, nil
+			}
+			s.discarding = false
+			advance += i + 1
+			if rest[i] == '\r' {
+				// a following LF belongs to the terminator
+				s.afterCR = true
+			}
+			continue
+		}
+		switch {
+		case i < 0 && len(rest) > maxLength, i > maxLength:
+			s.discarding, s.truncated = true, true
+			return advance + maxLength, rest[:maxLength], nil
+		case i < 0 && atEOF && len(rest) > 0:
+			return len(data), rest, nil
+		case i < 0:
+			// request more data, keeping the partial line
+			return advance, nil, nil
+		case rest[i] == '\n':
+			return advance + i + 1, rest[:i], nil
+		}
+
+		end := i + 1
+		switch {
+		case end < len(rest) && rest[end] == '\n':
+			// CRLF terminates a line even if it is empty
+			return advance + end + 1, rest[:i], nil
+		case end == len(rest) && !atEOF && i == 0:
+			// an empty line if a LF follows
+			return advance, nil, nil
+		case end == len(rest) && !atEOF:
+			s.afterCR = true
+		}
+		advance += end
+		if i > 0 {
+			return advance, rest[:i], nil
 		}
-		return advance, data[0:i], nil
-	}
-	if atEOF {
-		return len(data), data, nil
 	}
-	return 0, nil, nil
 }
 
 func processStdout(wg *sync.WaitGroup, task *Task, stdout io.Reader) {
 	defer wg.Done()
 
-	progressMatcher := newMatcher(`(\d+%)`)
-	speedMatcher := newMatcher(`(\d+\.\d+.{2}\/s)`)
-	totalMatcher := newMatcher(`^\s*(\d+.\d+[A-Za-z]*)`)
-	timeRemainingMatcher := newMatcher(`(\d+:){2}\d+`)
+	task.mutex.Lock()
+	interval := task.stateInterval
+	usePty := task.usePty
+	maxLineLength := task.maxLineLength
+	task.mutex.Unlock()
+
+	var batch stdoutBatch
+	if interval > 0 {
+		done, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			task.flushLoop(&batch, interval, done)
+			close(stopped)
+		}()
+		defer func() {
+			// the final flush must not race with one of the loop
+			close(done)
+			<-stopped
+			task.flushStdout(&batch)
+		}()
+	}
 
 	// Extract data from strings:
 	// 15.17G  10%   92.23MB/s    0:23:54
-	scanner := bufio.NewScanner(stdout)
-	scanner.Split(scanLines)
+	splitter := &lineSplitter{maxLength: maxLineLength}
+	scanner := splitter.scanner(stdout)
 	for scanner.Scan() {
-		logStr := scanner.Text()
-		task.mutex.Lock()
-
-		if totalMatcher.Match(logStr) {
-			task.state.DownloadedTotal = totalMatcher.Extract(logStr)
+		// the line is only valid until the next Scan and converted to a string only where needed
+		line := scanner.Bytes()
+		if splitter.truncated {
+			task.reportTruncated(len(line))
 		}
-
-		if progressMatcher.Match(logStr) {
-			tt := progressMatcher.Extract(logStr)
-			task.state.Progress, _ = strconv.Atoi(strings.TrimRight(tt, "%"))
+		if usePty && isWarningLine(string(line)) {
+			// the terminal merges stderr into stdout
+			task.processStderrLine(string(line))
+			continue
 		}
+		if interval > 0 {
+			task.processFileEvent(line)
+			batch.add(line)
+			continue
+		}
+		task.processMessage(line)
+	}
+}
 
-		if timeRemainingMatcher.Match(logStr) {
-			task.state.TimeRemaining = timeRemainingMatcher.All(logStr)[0]
+// processFileEvent emits the file event printed on line, if any
+func (t *Task) processFileEvent(line []byte) {
+	if bytes.HasPrefix(line, fileEventMarker) {
+		t.mutex.Lock()
+		event, ok := t.parseFileEvent(string(line))
+		if ok {
+			if event.Op == FileHardLink {
+				t.stats.HardLinks++
+			}
+			if t.dryRun {
+				t.countDryRun(event)
+			}
+		}
+		t.mutex.Unlock()
+		if ok {
+			t.recordManifest(event)
+			t.emitFileEvent(event)
 		}
+	}
+}
+
+// processMessage handles a line of the messages rsync prints on stdout, or on stderr with
+// --msgs2stderr
+func (t *Task) processMessage(line []byte) {
+	t.processFileEvent(line)
+	progress, isProgress := parseProgress(line)
+
+	t.mutex.Lock()
+	previousProgress := t.state.Progress
+	if isProgress {
+		t.applyProgress(progress, time.Now())
+	}
+	outcome := t.processStdoutLine(line)
+	t.logProgress(previousProgress)
+	t.mutex.Unlock()
+
+	t.emitLine(line, outcome)
+}
+
+// lineOutcome is what processing a stdout line yields for the callbacks
+type lineOutcome struct {
+	// forward is set if the line is forwarded to the logger, which is never the case without one
+	forward bool
+	// unparsed is set if none of the matchers understood the line, see OnUnparsedLine
+	unparsed bool
+	// fileProgress is the progress update of the current file, if the line was one
+	fileProgress    FileProgress
+	hasFileProgress bool
+}
+
+// processStdoutLine runs the parsers on line and accumulates it. The mutex must be held
+func (t *Task) processStdoutLine(line []byte) (outcome lineOutcome) {
+	if len(t.parsers) > 0 {
+		t.runParsers(string(line))
+	}
 
-		if speedMatcher.Match(logStr) {
-			task.state.Speed = getTaskSpeed(speedMatcher.ExtractAllStringSubmatch(logStr, 2))
+	category := classifyLine(line, false)
+	var parsed bool
+	var progress progressFields
+	switch category {
+	case LineInfo:
+		parsed = t.parseStatsLine(line) || t.parseSummaryLine(line) || t.parseScanLine(line)
+	case LineFile:
+		if parsed = t.recordDeleteWarning(line); !parsed {
+			parsed = t.recordDeleted(line)
+		}
+		if !parsed && t.outFormat != nil && bytes.HasPrefix(line, fileEventMarker) {
+			_, parsed = t.parseFileEvent(string(line))
 		}
+	case LineProgress:
+		progress, parsed = parseProgress(line)
+	case LineDebug:
+		parsed = t.parseNegotiationLine(line)
+	}
+	outcome.fileProgress, outcome.hasFileProgress = t.trackFileProgress(line, category, progress, parsed)
+	t.trackPartialFile(line, category)
+	if outcome.unparsed = isUnparsed(line, category, parsed); outcome.unparsed {
+		t.unparsedLines++
+	}
+	if t.logCategories&category != 0 {
+		t.stdoutLog.WriteLineBytes(line)
+	}
+	outcome.forward = t.logger != nil && t.forwardCategories&category != 0 && category != LineProgress
+	return outcome
+}
 
-		task.log.Stdout += logStr + "\n"
-		task.mutex.Unlock()
+// emitLine passes the outcome of processing line to the logger and callbacks
+func (t *Task) emitLine(line []byte, outcome lineOutcome) {
+	if outcome.forward {
+		t.logEvent(slog.LevelDebug, "rsync output", slog.String("line", string(line)))
+	}
+	if outcome.unparsed {
+		t.emitUnparsedLine(t.Redact(string(line)))
+	}
+	if outcome.hasFileProgress {
+		t.emitFileProgress(outcome.fileProgress)
 	}
 }
 
 func processStderr(wg *sync.WaitGroup, task *Task, stderr io.Reader) {
 	defer wg.Done()
 
+	task.mutex.Lock()
+	msgs2stderr := task.msgs2stderr
+	task.mutex.Unlock()
+
 	reader := bufio.NewReader(stderr)
 	for {
 		logStr, err := reader.ReadString('\n')
+		if err != nil && logStr == "" {
+			break
+		}
+
+		line := strings.TrimRight(logStr, "\r\n")
+		if msgs2stderr && !isWarningLine(line) {
+			// progress updates are separated by CR like on stdout
+			for _, message := range strings.Split(line, "\r") {
+				task.processMessage([]byte(message))
+			}
+			if err != nil {
+				break
+			}
+			continue
+		}
+		task.processStderrLine(line)
 		if err != nil {
 			break
 		}
+	}
+}
+
+// processStderrLine handles a warning, error or debug line rsync printed on stderr
+func (t *Task) processStderrLine(line string) {
+	category := ClassifyLine(line, true)
+
+	t.mutex.Lock()
+	if t.errorPatterns.isNetworkDrop(line) {
+		t.networkDropped = true
+	}
+	// the line ends up in warnings, records and callbacks
+	line = t.redact(line)
+	if category == LineWarning && line != "" {
+		fileErr, ok := t.errorPatterns.parseFileError(line)
+		t.warnings = append(t.warnings, classifyWarning(line, fileErr, ok))
+		if ok {
+			t.fileErrors = append(t.fileErrors, fileErr)
+		} else if limit, ok := parseDeleteLimit(line); ok {
+			t.deleteLimit = &limit
+		}
+	}
+	if t.logCategories&category != 0 {
+		t.stderrLog.WriteLine(line)
+	}
+	forward := t.forwardCategories&category != 0
+	t.mutex.Unlock()
 
-		task.mutex.Lock()
-		task.log.Stderr += logStr + "\n"
-		task.mutex.Unlock()
+	if forward {
+		level := slog.LevelWarn
+		if category == LineDebug {
+			level = slog.LevelDebug
+		}
+		t.logEvent(level, "rsync stderr", slog.String("line", line))
+		t.emitStderrLine(line)
 	}
 }
 
@@ -220,5 +1112,5 @@ func getTaskSpeed(data [][]string) string {
 	if len(data) < 1 || len(data[0]) < 2 {
 		return ""
 	}
-	return data[0][0]
+	return data[len(data)-1][1]
 }
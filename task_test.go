@@ -1,17 +1,39 @@
 package grsync
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func requireRsync(t *testing.T) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("rsync binary not found in PATH")
+	}
+}
+
+// fakeRsync writes a shell script which is used in place of the rsync binary and returns its path
+func fakeRsync(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "rsync")
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755)
+	assert.Nil(t, err)
+	return path
+}
+
 func TestTask(t *testing.T) {
 	t.Run("create new empty Task", func(t *testing.T) {
-		createdTask := NewTask("a", "b", false, false, RsyncOptions{})
+		createdTask, err := NewTask("a", "b", false, false, RsyncOptions{})
+		assert.Nil(t, err)
 
 		assert.Empty(t, createdTask.Log(), "Task log should return empty string")
 		assert.Empty(t, createdTask.State(), "Task should inited with empty state")
@@ -43,7 +65,15 @@ func TestTaskSpeedParse(t *testing.T) {
 	assert.Equal(t, "999.99kB/s", speed)
 }
 
+func TestTaskSpeedLastSample(t *testing.T) {
+	// the speed since the last progress line, not the full match of the first one
+	assert.Equal(t, "2.00MB/s", getTaskSpeed([][]string{{"1.00MB/s", "1.00MB/s"}, {"2.00MB/s", "2.00MB/s"}}))
+	assert.Equal(t, "1.00MB/s", getTaskSpeed([][]string{{" 1.00MB/s", "1.00MB/s"}}))
+	assert.Equal(t, "", getTaskSpeed(nil))
+}
+
 func TestRunTaskSuccess(t *testing.T) {
+	requireRsync(t)
 	tmpDir := os.TempDir()
 	if tmpDir == "" {
 		tmpDir = "/tmp"
@@ -57,7 +87,8 @@ func TestRunTaskSuccess(t *testing.T) {
 	f, e := os.Create(a)
 	assert.Nil(t, e)
 	f.Truncate(16 * 1024 * 1024)
-	createdTask := NewTask(a, b, false, false, RsyncOptions{})
+	createdTask, e := NewTask(a, b, false, false, RsyncOptions{})
+	assert.Nil(t, e)
 	e = createdTask.Run()
 	assert.Nil(t, e)
 	_, e = os.Stat(b)
@@ -65,6 +96,7 @@ func TestRunTaskSuccess(t *testing.T) {
 }
 
 func TestRunTaskSuccessProgress(t *testing.T) {
+	requireRsync(t)
 	tmpDir := os.TempDir()
 	if tmpDir == "" {
 		tmpDir = "/tmp"
@@ -78,7 +110,8 @@ func TestRunTaskSuccessProgress(t *testing.T) {
 	f, e := os.Create(a)
 	assert.Nil(t, e)
 	f.Truncate(16 * 1024 * 1024)
-	createdTask := NewTask(a, b, false, false, RsyncOptions{})
+	createdTask, e := NewTask(a, b, false, false, RsyncOptions{})
+	assert.Nil(t, e)
 	go func() {
 		for {
 			_ = createdTask.State()
@@ -93,6 +126,7 @@ func TestRunTaskSuccessProgress(t *testing.T) {
 }
 
 func TestRunTaskSuccessLog(t *testing.T) {
+	requireRsync(t)
 	tmpDir := os.TempDir()
 	if tmpDir == "" {
 		tmpDir = "/tmp"
@@ -106,7 +140,8 @@ func TestRunTaskSuccessLog(t *testing.T) {
 	f, e := os.Create(a)
 	assert.Nil(t, e)
 	f.Truncate(16 * 1024 * 1024)
-	createdTask := NewTask(a, b, false, false, RsyncOptions{})
+	createdTask, e := NewTask(a, b, false, false, RsyncOptions{})
+	assert.Nil(t, e)
 	go func() {
 		for {
 			_ = createdTask.Log()
@@ -134,9 +169,134 @@ func TestRunTaskFailure(t *testing.T) {
 	f, e := os.Create(a)
 	assert.Nil(t, e)
 	f.Truncate(16 * 1024 * 1024)
-	createdTask := NewTask(a, b, false, false, RsyncOptions{})
+	createdTask, e := NewTask(a, b, false, false, RsyncOptions{})
+	assert.Nil(t, e)
 	e = createdTask.Run()
 	assert.NotNil(t, e)
 	_, e = os.Stat(b)
 	assert.NotNil(t, e)
 }
+
+func TestTaskGetFileList(t *testing.T) {
+	script := `echo "receiving incremental file list"
+echo "drwxr-xr-x          4,096 2023/01/02 10:11:12 ."
+echo "-rw-r--r--          1.23K 2023/01/02 10:11:13 file name.txt"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script), ListOnly: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, [][]string{
+		{"drwxr-xr-x", "4,096", "2023/01/02", "10:11:12", "."},
+		{"-rw-r--r--", "1.23K", "2023/01/02", "10:11:13", "file name.txt"},
+	}, task.GetFileList())
+}
+
+func TestCRLFSplitter(t *testing.T) {
+	// reading a byte at a time splits every CRLF across reads
+	input := "sending incremental file list\r\n  1.00M  50%  1.00MB/s  0:00:01\r  2.00M 100%  1.00MB/s  0:00:00\r\nend"
+	scanner := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(input)))
+	scanner.Split((&lineSplitter{}).split)
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Equal(t, []string{
+		"sending incremental file list",
+		"  1.00M  50%  1.00MB/s  0:00:01",
+		"  2.00M 100%  1.00MB/s  0:00:00",
+		"end",
+	}, lines)
+}
+
+// chunkReader returns the data in reads of n bytes
+type chunkReader struct {
+	data string
+	n    int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[:min(r.n, len(r.data))])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestLineSplitter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		lines []string
+	}{
+		{
+			name: "progress2",
+			input: "sending incremental file list\n\r" +
+				"          1.05M  10%    1.00MB/s    0:00:09 (xfr#1, ir-chk=1009/1020)\r" +
+				"         10.49M 100%    9.87MB/s    0:00:01 (xfr#20, to-chk=0/1020)\n\n" +
+				"sent 10,490,000 bytes  received 400 bytes  6,993,600.00 bytes/sec\n",
+			lines: []string{
+				"sending incremental file list",
+				"          1.05M  10%    1.00MB/s    0:00:09 (xfr#1, ir-chk=1009/1020)",
+				"         10.49M 100%    9.87MB/s    0:00:01 (xfr#20, to-chk=0/1020)",
+				"",
+				"sent 10,490,000 bytes  received 400 bytes  6,993,600.00 bytes/sec",
+			},
+		},
+		{
+			name: "per-file progress",
+			input: "dir/file\n\r" +
+				"         32,768   0%    0.00kB/s    0:00:00\r" +
+				"      1,048,576 100%   12.35MB/s    0:00:00 (xfr#1, to-chk=1/3)\n" +
+				"dir/other\n\r      2,048 100%    1.95MB/s    0:00:00 (xfr#2, to-chk=0/3)\n",
+			lines: []string{
+				"dir/file",
+				"         32,768   0%    0.00kB/s    0:00:00",
+				"      1,048,576 100%   12.35MB/s    0:00:00 (xfr#1, to-chk=1/3)",
+				"dir/other",
+				"      2,048 100%    1.95MB/s    0:00:00 (xfr#2, to-chk=0/3)",
+			},
+		},
+		{
+			name:  "pty",
+			input: "sending incremental file list\r\n\r  1.00M  50%  1.00MB/s  0:00:01\r  2.00M 100%  1.00MB/s  0:00:00\r\n\r\nend\r",
+			lines: []string{
+				"sending incremental file list",
+				"  1.00M  50%  1.00MB/s  0:00:01",
+				"  2.00M 100%  1.00MB/s  0:00:00",
+				"",
+				"end",
+			},
+		},
+		{
+			name:  "repeated CR",
+			input: "\r\r\r  1.00M  50%\r\r  2.00M 100%",
+			lines: []string{"  1.00M  50%", "  2.00M 100%"},
+		},
+		{
+			name:  "long line",
+			input: strings.Repeat("x", 200000) + "\r" + strings.Repeat("y", 100) + "\n",
+			lines: []string{strings.Repeat("x", 200000), strings.Repeat("y", 100)},
+		},
+	}
+
+	for _, test := range tests {
+		for _, n := range []int{1, 2, 3, 7, 4096, len(test.input)} {
+			if n < 4096 && len(test.input) > 4096 {
+				// the scanner searches the whole partial line on every read
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/%d", test.name, n), func(t *testing.T) {
+				scanner := (&lineSplitter{}).scanner(&chunkReader{data: test.input, n: n})
+
+				var lines []string
+				for scanner.Scan() {
+					lines = append(lines, scanner.Text())
+				}
+				assert.Nil(t, scanner.Err())
+				assert.Equal(t, test.lines, lines)
+			})
+		}
+	}
+}
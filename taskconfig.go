@@ -0,0 +1,46 @@
+package grsync
+
+// TaskConfig relaxes the options a task forces onto every rsync command. By default a task
+// passes --human-readable, --partial and --progress regardless of its RsyncOptions
+type TaskConfig struct {
+	// NoHumanReadable passes --no-human-readable instead of --human-readable unless the
+	// RsyncOptions ask for human-readable numbers, so rsync prints plain digits. The parser reads
+	// either
+	NoHumanReadable bool
+	// NoPartial leaves --partial to the RsyncOptions. rsync deletes interrupted files without it, so
+	// retries and relaunches start them over. Dry runs never force it
+	NoPartial bool
+	// NoProgress leaves --progress to the RsyncOptions. Without progress lines State isn't updated
+	// while rsync runs, only the final summary and Stats are parsed
+	NoProgress bool
+	// AllowDestructive runs the task in read-only mode although its options delete files, see
+	// SetReadOnly
+	AllowDestructive bool
+}
+
+// NewTaskWithConfig is NewTask with the forced options relaxed by config
+func NewTaskWithConfig(source, destination string, useSshPass, createDir bool, rsyncOptions RsyncOptions, config TaskConfig) (*Task, error) {
+	task := newTask(source, destination, useSshPass, createDir, rsyncOptions)
+	task.definition.Config = config
+	if err := task.prepare(false); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// forceOptions sets the options the parser of a task depends on, as far as config allows
+func forceOptions(options RsyncOptions, config TaskConfig) RsyncOptions {
+	if !config.NoHumanReadable {
+		options.HumanReadable = true
+	} else if !options.HumanReadable && !options.HumanReadableIEC {
+		options.NoHumanReadable = true
+	}
+	// a dry run leaves no files to continue
+	if !config.NoPartial && !options.DryRun {
+		options.Partial = true
+	}
+	if !config.NoProgress {
+		options.Progress = true
+	}
+	return options
+}
@@ -0,0 +1,54 @@
+package grsync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForceOptions(t *testing.T) {
+	options := forceOptions(RsyncOptions{}, TaskConfig{})
+	assert.True(t, options.HumanReadable)
+	assert.True(t, options.Partial)
+	assert.True(t, options.Progress)
+	assert.False(t, options.NoHumanReadable)
+
+	options = forceOptions(RsyncOptions{}, TaskConfig{NoHumanReadable: true, NoPartial: true, NoProgress: true})
+	assert.Equal(t, RsyncOptions{NoHumanReadable: true}, options)
+
+	// the caller's choice wins
+	options = forceOptions(RsyncOptions{HumanReadableIEC: true, Partial: true}, TaskConfig{NoHumanReadable: true, NoPartial: true})
+	assert.False(t, options.NoHumanReadable)
+	assert.True(t, options.Partial)
+	rsync, err := NewRsync("a", "b", false, false, options)
+	assert.Nil(t, err)
+	// units of 1024 as the parser expects
+	assert.Equal(t, 2, strings.Count(strings.Join(rsync.command.Args, " "), "--human-readable"))
+
+	// dry runs leave no partial files
+	options = forceOptions(RsyncOptions{DryRun: true}, TaskConfig{})
+	assert.False(t, options.Partial)
+}
+
+func TestNewTaskWithConfig(t *testing.T) {
+	runner := &cannedRunner{stdout: "file\n\r      1048576 100%   12.35MB/s    0:00:00 (xfr#1, to-chk=0/1)\n"}
+	task, err := NewTaskWithConfig("a", "b", false, false, RsyncOptions{}, TaskConfig{NoHumanReadable: true, NoPartial: true})
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	assert.Nil(t, task.Run())
+
+	assert.Contains(t, runner.args, "--no-human-readable")
+	assert.Contains(t, runner.args, "--progress")
+	assert.NotContains(t, runner.args, "--human-readable")
+	assert.NotContains(t, runner.args, "--partial")
+	assert.Equal(t, int64(1048576), task.State().BytesTransferred)
+	assert.Equal(t, 100, task.State().Progress)
+
+	task, err = Definition{Source: "a", Destination: "b", Config: TaskConfig{NoProgress: true}}.NewTask()
+	assert.Nil(t, err)
+	task.SetRunner(runner)
+	assert.Nil(t, task.Run())
+	assert.NotContains(t, runner.args, "--progress")
+	assert.Equal(t, TaskConfig{NoProgress: true}, task.Definition().Config)
+}
@@ -0,0 +1,121 @@
+package grsync
+
+// TaskTemplate is the configuration shared by similar tasks, e.g. the modules of one server, so
+// they can be created without repeating it
+type TaskTemplate struct {
+	Options    RsyncOptions
+	Config     TaskConfig
+	UseSshPass bool
+	CreateDir  bool
+	Labels     map[string]string
+
+	RetryPolicy RetryPolicy
+	PreHooks    []CommandHook
+	PostHooks   []CommandHook
+	// Setup is called with every new task, e.g. to set a logger or register callbacks
+	Setup func(task *Task)
+}
+
+// NewTask returns a task syncing source to destination with the configuration of the template
+func (tt TaskTemplate) NewTask(source, destination string) (*Task, error) {
+	task, err := NewTaskWithConfig(source, destination, tt.UseSshPass, tt.CreateDir, tt.Options, tt.Config)
+	if err != nil {
+		return nil, err
+	}
+	task.SetLabels(tt.Labels)
+	task.SetRetryPolicy(tt.RetryPolicy)
+	for _, hook := range tt.PreHooks {
+		if err = task.AddPreHook(hook); err != nil {
+			return nil, err
+		}
+	}
+	for _, hook := range tt.PostHooks {
+		if err = task.AddPostHook(hook); err != nil {
+			return nil, err
+		}
+	}
+	if tt.Setup != nil {
+		tt.Setup(task)
+	}
+	return task, nil
+}
+
+// Template returns the configuration of the task as a template, see Clone to copy all of it
+func (t *Task) Template() TaskTemplate {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return TaskTemplate{
+		Options:     t.definition.Options,
+		Config:      t.definition.Config,
+		UseSshPass:  t.definition.UseSshPass,
+		CreateDir:   t.definition.CreateDir,
+		Labels:      cloneLabels(t.definition.Labels),
+		RetryPolicy: t.retryPolicy,
+		PreHooks:    append([]CommandHook(nil), t.preHooks...),
+		PostHooks:   append([]CommandHook(nil), t.postHooks...),
+	}
+}
+
+// Clone returns a pending task with a new ID and the definition and settings of t: hooks, retry
+// policy, callbacks, writers, logger, runner and stores. Neither the state of the runs nor the
+// FileEvents and Errors channels are copied, nor the checkpoint store, whose key identifies one
+// task. A lock set with SetLock is shared
+func (t *Task) Clone() (*Task, error) {
+	t.mutex.Lock()
+	definition := t.definition
+	t.mutex.Unlock()
+	return t.cloneWith(definition)
+}
+
+// cloneWith returns a pending task with a new ID, definition and the settings of t, see Clone
+func (t *Task) cloneWith(definition Definition) (*Task, error) {
+	definition.ID = ""
+	clone, err := definition.NewTask()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !definition.LockDestination {
+		clone.lock = t.lock
+	}
+	clone.pathVars = cloneLabels(t.pathVars)
+	clone.hooks = append([]Hooks(nil), t.hooks...)
+	clone.preHooks = append([]CommandHook(nil), t.preHooks...)
+	clone.postHooks = append([]CommandHook(nil), t.postHooks...)
+	clone.retryPolicy = t.retryPolicy
+
+	clone.fileEventCallbacks = append(clone.fileEventCallbacks, t.fileEventCallbacks...)
+	clone.stderrCallbacks = append(clone.stderrCallbacks, t.stderrCallbacks...)
+	clone.processCallbacks = append(clone.processCallbacks, t.processCallbacks...)
+	clone.unparsedCallbacks = append(clone.unparsedCallbacks, t.unparsedCallbacks...)
+	clone.stdoutWriter, clone.stderrWriter = t.stdoutWriter, t.stderrWriter
+	clone.backpressure = t.backpressure
+	clone.parsers = append([]LineParser(nil), t.parsers...)
+	clone.logFileOptions = t.logFileOptions
+	clone.logger = t.logger
+	clone.logCategories, clone.forwardCategories = t.logCategories, t.forwardCategories
+	clone.stdoutLog.setLimits(t.stdoutLog.headLimit, t.stdoutLog.tailLimit)
+	clone.stderrLog.setLimits(t.stderrLog.headLimit, t.stderrLog.tailLimit)
+	clone.stdoutLog.discard, clone.stderrLog.discard = t.stdoutLog.discard, t.stderrLog.discard
+
+	clone.compatibility = t.compatibility
+	clone.runner = t.runner
+	clone.runStore, clone.runStoreKey = t.runStore, t.runStoreKey
+
+	clone.maxReconnects = t.maxReconnects
+	clone.bandwidthSchedule = append(BandwidthSchedule(nil), t.bandwidthSchedule...)
+	clone.stallTimeout = t.stallTimeout
+	clone.errorPatterns = t.errorPatterns
+	clone.maxLineLength = t.maxLineLength
+	clone.manifestEnabled = t.manifestEnabled
+	clone.stateInterval = t.stateInterval
+	clone.progressBasis = t.progressBasis
+	clone.speedSmoothing = t.speedSmoothing
+	clone.historyInterval, clone.historyLimit = t.historyInterval, t.historyLimit
+	clone.cleanupOnCancel = t.cleanupOnCancel
+	clone.askPass = t.askPass
+	clone.recordingEnabled = t.recordingEnabled
+	return clone, nil
+}
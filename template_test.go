@@ -0,0 +1,66 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskTemplate(t *testing.T) {
+	var setup []string
+	template := TaskTemplate{
+		Options:     RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0"), Archive: true},
+		Labels:      map[string]string{"server": "backup"},
+		RetryPolicy: RetryPolicy{MaxAttempts: 3},
+		PreHooks:    []CommandHook{{Name: "check", Func: func(*Task, error) error { return nil }}},
+		Setup:       func(task *Task) { setup = append(setup, task.Definition().Destination) },
+	}
+
+	photos, err := template.NewTask("/photos/", "backup::photos")
+	assert.Nil(t, err)
+	music, err := template.NewTask("/music/", "backup::music")
+	assert.Nil(t, err)
+	assert.NotEqual(t, photos.ID(), music.ID())
+	assert.Equal(t, []string{"backup::photos", "backup::music"}, setup)
+	assert.Equal(t, "backup", music.Label("server"))
+	assert.True(t, music.Definition().Options.Archive)
+
+	assert.Nil(t, music.Run())
+	assert.Len(t, music.HookResults(), 1)
+
+	template.PreHooks = []CommandHook{{Name: "empty"}}
+	_, err = template.NewTask("/a/", "backup::a")
+	assert.ErrorIs(t, err, ErrEmptyHook)
+}
+
+func TestTaskClone(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo done")})
+	assert.Nil(t, err)
+	task.SetLabels(map[string]string{"job": "nightly"})
+	task.SetRetryPolicy(RetryPolicy{MaxAttempts: 2})
+	task.SetStallTimeout(time.Minute)
+	task.SetLogLimits(10, 10)
+	var stderr int
+	task.OnStderrLine(func(string) { stderr++ })
+	assert.Nil(t, task.Run())
+
+	clone, err := task.Clone()
+	assert.Nil(t, err)
+	assert.NotEqual(t, task.ID(), clone.ID())
+	assert.Equal(t, TaskPending, clone.Status())
+	assert.Empty(t, clone.Log().Stdout)
+	assert.Equal(t, "nightly", clone.Label("job"))
+	assert.Equal(t, task.Definition().Source, clone.Definition().Source)
+
+	clone.mutex.Lock()
+	assert.Equal(t, RetryPolicy{MaxAttempts: 2}, clone.retryPolicy)
+	assert.Equal(t, time.Minute, clone.stallTimeout)
+	assert.Equal(t, 10, clone.stdoutLog.headLimit)
+	assert.Len(t, clone.stderrCallbacks, 1)
+	clone.mutex.Unlock()
+
+	// the labels are copied
+	clone.SetLabels(map[string]string{"job": "weekly"})
+	assert.Equal(t, "nightly", task.Label("job"))
+}
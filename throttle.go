@@ -0,0 +1,77 @@
+package grsync
+
+import (
+	"sync"
+	"time"
+)
+
+// SetStateInterval sets the minimum interval between state updates. Output lines arriving in
+// between are coalesced: the state is updated from the last progress line and the lines are
+// parsed and logged in one batch, so the mutex is acquired once per interval rather than once
+// per line. Zero, the default, updates the state for every line
+func (t *Task) SetStateInterval(interval time.Duration) {
+	t.mutex.Lock()
+	t.stateInterval = interval
+	t.mutex.Unlock()
+}
+
+// stdoutBatch collects stdout lines until they are flushed into the task
+type stdoutBatch struct {
+	mutex       sync.Mutex
+	lines       [][]byte
+	progress    progressFields
+	hasProgress bool
+}
+
+// add copies line into the batch
+func (b *stdoutBatch) add(line []byte) {
+	line = append([]byte(nil), line...)
+	progress, isProgress := parseProgress(line)
+	b.mutex.Lock()
+	b.lines = append(b.lines, line)
+	if isProgress {
+		b.progress, b.hasProgress = progress, true
+	}
+	b.mutex.Unlock()
+}
+
+// flushLoop flushes batch every interval until done is closed
+func (t *Task) flushLoop(batch *stdoutBatch, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flushStdout(batch)
+		case <-done:
+			return
+		}
+	}
+}
+
+// flushStdout applies the lines collected in batch to the task
+func (t *Task) flushStdout(batch *stdoutBatch) {
+	batch.mutex.Lock()
+	lines, progress, hasProgress := batch.lines, batch.progress, batch.hasProgress
+	batch.lines, batch.hasProgress = nil, false
+	batch.mutex.Unlock()
+	if len(lines) == 0 {
+		return
+	}
+
+	outcomes := make([]lineOutcome, len(lines))
+	t.mutex.Lock()
+	previousProgress := t.state.Progress
+	if hasProgress {
+		t.applyProgress(progress, time.Now())
+	}
+	for i, line := range lines {
+		outcomes[i] = t.processStdoutLine(line)
+	}
+	t.logProgress(previousProgress)
+	t.mutex.Unlock()
+
+	for i, line := range lines {
+		t.emitLine(line, outcomes[i])
+	}
+}
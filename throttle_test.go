@@ -0,0 +1,40 @@
+package grsync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateInterval(t *testing.T) {
+	script := `for i in 1 2 3 4 5 6 7 8 9; do echo "      ${i}.00M   ${i}0%    1.00MB/s    0:00:0${i}"; done
+echo "file"
+sleep 0.3
+echo "     10.00M  100%    1.00MB/s    0:00:10"`
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+	task.SetStateInterval(100 * time.Millisecond)
+
+	// parsers run with the mutex held
+	var samples []int
+	task.AddParser(LineParserFunc(func(line string, set func(key, value string)) {
+		samples = append(samples, task.state.Progress)
+	}))
+	assert.Nil(t, task.Run())
+
+	state := task.State()
+	assert.Equal(t, 100, state.Progress)
+	assert.Equal(t, "10.00M", state.DownloadedTotal)
+	// every line is still accumulated and parsed
+	assert.Equal(t, 11, strings.Count(task.Log().Stdout, "\n"))
+	assert.Len(t, samples, 11)
+	// the burst is coalesced into few state updates
+	distinct := map[int]bool{}
+	for _, progress := range samples {
+		distinct[progress] = true
+	}
+	assert.True(t, len(distinct) < 5, samples)
+}
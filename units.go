@@ -0,0 +1,140 @@
+package grsync
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sizeUnits are the suffixes rsync uses for human-readable numbers and their power of the unit
+var sizeUnits = map[byte]float64{
+	'K': 1,
+	'k': 1,
+	'M': 2,
+	'G': 3,
+	'T': 4,
+	'P': 5,
+}
+
+// ParseSize converts a size printed by rsync, e.g. "15.17G", "1,234,567" or "3.20kB", into bytes.
+// Suffixes are in units of 1000 unless they carry an IEC "i", e.g. "1.50MiB"
+func ParseSize(s string) (int64, error) {
+	return parseSize(s, 1000)
+}
+
+// ParseSizeIEC is ParseSize for sizes printed in units of 1024, which rsync does if
+// --human-readable is given twice
+func ParseSizeIEC(s string) (int64, error) {
+	return parseSize(s, 1024)
+}
+
+func parseSize(s string, base float64) (int64, error) {
+	value := strings.Replace(strings.TrimSpace(s), ",", "", -1)
+	value = strings.TrimSuffix(value, "B")
+	if strings.HasSuffix(value, "i") {
+		value, base = value[:len(value)-1], 1024
+	}
+	if value == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	multiplier := 1.0
+	if power, ok := sizeUnits[value[len(value)-1]]; ok {
+		multiplier = math.Pow(base, power)
+		value = value[:len(value)-1]
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(math.Round(number * multiplier)), nil
+}
+
+// ParseSpeed converts a transfer speed printed by rsync, e.g. "92.23MB/s", into bytes per second
+func ParseSpeed(s string) (float64, error) {
+	return parseSpeed(s, 1000)
+}
+
+// ParseSpeedIEC is ParseSpeed for speeds printed in units of 1024, which rsync does if
+// --human-readable is given twice
+func ParseSpeedIEC(s string) (float64, error) {
+	return parseSpeed(s, 1024)
+}
+
+func parseSpeed(s string, base float64) (float64, error) {
+	size, err := parseSize(strings.TrimSuffix(strings.TrimSpace(s), "/s"), base)
+	if err != nil {
+		return 0, fmt.Errorf("invalid speed %q", s)
+	}
+	return float64(size), nil
+}
+
+var (
+	sizeNames    = []string{"B", "kB", "MB", "GB", "TB", "PB"}
+	iecSizeNames = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+)
+
+// formatSize formats bytes like a speed without "/s", e.g. "15.17GB", in units of base
+func formatSize(size, base float64) string {
+	return string(appendSize(nil, size, base))
+}
+
+// appendSize appends the formatted size to b
+func appendSize(b []byte, size, base float64) []byte {
+	return appendSizeNames(b, size, base, sizeNames)
+}
+
+// appendSizeNames appends size in units of base with the unit names
+func appendSizeNames(b []byte, size, base float64, names []string) []byte {
+	unit := 0
+	for size >= base && unit < len(names)-1 {
+		size /= base
+		unit++
+	}
+	return append(strconv.AppendFloat(b, size, 'f', 2, 64), names[unit]...)
+}
+
+// FormatSize formats bytes in units of 1000 the way rsync prints speeds, e.g. "15.17GB", which
+// ParseSize reads back
+func FormatSize(bytes int64) string {
+	return formatSize(float64(bytes), 1000)
+}
+
+// FormatSizeIEC formats bytes in units of 1024 with IEC units, e.g. "14.13GiB", which ParseSize
+// and ParseSizeIEC read back
+func FormatSizeIEC(bytes int64) string {
+	return string(appendSizeNames(nil, float64(bytes), 1024, iecSizeNames))
+}
+
+// FormatSpeed formats bytes per second in units of 1000 like rsync, e.g. "92.23MB/s", which
+// ParseSpeed reads back
+func FormatSpeed(bytesPerSecond float64) string {
+	return formatSpeed(bytesPerSecond, 1000)
+}
+
+// FormatSpeedIEC formats bytes per second in units of 1024 with IEC units, e.g. "87.96MiB/s",
+// which ParseSpeed and ParseSpeedIEC read back
+func FormatSpeedIEC(bytesPerSecond float64) string {
+	return string(append(appendSizeNames(nil, bytesPerSecond, 1024, iecSizeNames), "/s"...))
+}
+
+// FormatDuration formats d like the time remaining rsync prints, e.g. "0:23:54" or "26:03:00"
+// for more than a day, rounded to seconds
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	seconds := int64(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// unitBase returns the unit rsync prints the human-readable numbers of the task in
+func (t *Task) unitBase() float64 {
+	if t.definition.Options.HumanReadableIEC {
+		return 1024
+	}
+	return 1000
+}
@@ -0,0 +1,126 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"0":         0,
+		"1,234,567": 1234567,
+		"15.17G":    15170000000,
+		"342.82M":   342820000,
+		"3.20kB":    3200,
+		" 12.00K":   12000,
+		"1.5T":      1500000000000,
+	} {
+		size, err := ParseSize(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, size, input)
+	}
+
+	for _, input := range []string{"", "B", "abc", "-1", "1.2X"} {
+		_, err := ParseSize(input)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	speed, err := ParseSpeed("92.23MB/s")
+	assert.Nil(t, err)
+	assert.Equal(t, 92230000.0, speed)
+
+	speed, err = ParseSpeed("512.00kB/s")
+	assert.Nil(t, err)
+	assert.Equal(t, 512000.0, speed)
+
+	_, err = ParseSpeed("fast")
+	assert.NotNil(t, err)
+}
+
+func TestParseSizeIEC(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"1,024":   1024,
+		"1.50K":   1536,
+		"2.00M":   2097152,
+		"1.00GiB": 1073741824,
+	} {
+		size, err := ParseSizeIEC(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, size, input)
+	}
+
+	// IEC suffixes are in units of 1024 regardless of the base
+	size, err := ParseSize("1.00MiB")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1048576), size)
+}
+
+func TestParseSpeedIEC(t *testing.T) {
+	speed, err := ParseSpeedIEC("1.02kB/s")
+	assert.Nil(t, err)
+	assert.Equal(t, 1044.0, speed)
+
+	speed, err = ParseSpeedIEC("3.40GB/s")
+	assert.Nil(t, err)
+	assert.Equal(t, 3650722202.0, speed)
+
+	speed, err = ParseSpeed("3.4GiB/s")
+	assert.Nil(t, err)
+	assert.Equal(t, 3650722202.0, speed)
+}
+
+func TestTaskBytesTransferred(t *testing.T) {
+	for script, expected := range map[string]int64{
+		`echo "     15.17G   10%   92.23MB/s    0:23:54"`:               15170000000,
+		`echo "  1,234,567   10%    1.00MB/s    0:00:09"`:               1234567,
+		`echo "          0    0%    0.00kB/s    0:00:00"`:               0,
+		`echo "1.txt"; echo "      2.00K  100%    1.00kB/s    0:00:02"`: 2000,
+	} {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		assert.Nil(t, task.Run())
+		assert.Equal(t, expected, task.State().BytesTransferred, script)
+	}
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, `echo "      1.50K  100%    1.00kB/s    0:00:02"`), HumanReadableIEC: true})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Run())
+	assert.Equal(t, int64(1536), task.State().BytesTransferred)
+}
+
+func TestFormatUnits(t *testing.T) {
+	assert.Equal(t, "15.17GB", FormatSize(15170000000))
+	assert.Equal(t, "512.00B", FormatSize(512))
+	assert.Equal(t, "1.50MiB", FormatSizeIEC(1572864))
+	assert.Equal(t, "1.00KiB", FormatSizeIEC(1024))
+	assert.Equal(t, "92.23MB/s", FormatSpeed(92230000))
+	assert.Equal(t, "1.00MiB/s", FormatSpeedIEC(1048576))
+
+	for _, size := range []int64{0, 999, 15170000000, 2500000000000000} {
+		parsed, err := ParseSize(FormatSize(size))
+		assert.Nil(t, err)
+		assert.InDelta(t, size, parsed, float64(size)/1000)
+	}
+	for _, size := range []int64{1024, 1572864, 15170000000} {
+		parsed, err := ParseSizeIEC(FormatSizeIEC(size))
+		assert.Nil(t, err)
+		assert.InDelta(t, size, parsed, float64(size)/1000)
+		parsed, err = ParseSize(FormatSizeIEC(size))
+		assert.Nil(t, err)
+		assert.InDelta(t, size, parsed, float64(size)/1000)
+	}
+	speed, err := ParseSpeedIEC(FormatSpeedIEC(1048576))
+	assert.Nil(t, err)
+	assert.Equal(t, 1048576.0, speed)
+
+	assert.Equal(t, "0:23:54", FormatDuration(23*time.Minute+54*time.Second))
+	assert.Equal(t, "26:03:00", FormatDuration(26*time.Hour+3*time.Minute))
+	assert.Equal(t, "0:00:00", FormatDuration(-time.Second))
+	d, ok := parseRemaining(FormatDuration(time.Hour + 2*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour+2*time.Second, d)
+}
@@ -0,0 +1,64 @@
+package grsync
+
+import (
+	"bytes"
+)
+
+// statsPrefixes start the --stats and summary lines grsync parses
+var statsPrefixes = byteStrings("Number of ", "Total ", "Literal data:", "Matched data:", "File list size:", "sent ", "total size is")
+
+// OnUnparsedLine registers a callback invoked with every stdout line that looks like progress,
+// stats or summary output but none of the built-in matchers understood, e.g. because a new rsync
+// version changed its format and State silently stopped updating. File names can't be told
+// apart from unknown lines and are never reported. See UnparsedLines for the number of lines
+func (t *Task) OnUnparsedLine(callback func(line string)) {
+	t.mutex.Lock()
+	t.unparsedCallbacks = append(t.unparsedCallbacks, callback)
+	t.mutex.Unlock()
+}
+
+// UnparsedLines returns the number of unparsed lines of the current or last run, see OnUnparsedLine
+func (t *Task) UnparsedLines() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.unparsedLines
+}
+
+// isUnparsed reports whether line of category wasn't understood by the matchers. parsed is
+// whether a matcher of the category took it
+func isUnparsed(line []byte, category LineCategory, parsed bool) bool {
+	if parsed {
+		return false
+	}
+	switch category {
+	case LineProgress:
+		return true
+	case LineInfo:
+		trimmed := bytes.TrimSpace(line)
+		for _, prefix := range statsPrefixes {
+			if bytes.HasPrefix(trimmed, prefix) {
+				return true
+			}
+		}
+	case LineFile:
+		if bytes.HasPrefix(line, fileEventMarker) {
+			_, ok := parseFileEvent(string(line), false)
+			return !ok
+		}
+		// a progress line the classifier doesn't recognize either, its speed or counts moved
+		i := bytes.IndexByte(line, '%')
+		return i > 0 && isDigit(line[i-1]) && bytes.Contains(line, []byte(":"))
+	}
+	return false
+}
+
+// emitUnparsedLine passes line to the OnUnparsedLine callbacks
+func (t *Task) emitUnparsedLine(line string) {
+	t.mutex.Lock()
+	callbacks := t.unparsedCallbacks
+	t.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(line)
+	}
+}
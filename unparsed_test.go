@@ -0,0 +1,47 @@
+package grsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnparsedLines(t *testing.T) {
+	script := `echo "sending incremental file list"
+echo "dir/file.txt"
+echo "      1.00M   10%   10.00MB/s    0:00:09"
+echo "      2.00M   20%   10.00MiBps    0:00:08"
+echo "Number of files: 3 (reg: 2, dir: 1)"
+echo "Number of files: three"
+echo "sent 1.05M bytes  received 57 bytes  2.10M bytes/sec"
+echo "sent 1.05M bytes"
+echo "total size is 1.05M  speedup is 1.00"`
+
+	// with and without batched output
+	for _, interval := range []time.Duration{0, 10 * time.Millisecond} {
+		task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+		assert.Nil(t, err)
+		task.SetStateInterval(interval)
+		var lines []string
+		task.OnUnparsedLine(func(line string) { lines = append(lines, line) })
+
+		assert.Nil(t, task.Run())
+		assert.Equal(t, []string{
+			"      2.00M   20%   10.00MiBps    0:00:08",
+			"Number of files: three",
+			"sent 1.05M bytes",
+		}, lines)
+		assert.Equal(t, 3, task.UnparsedLines())
+		assert.Equal(t, 3, task.result(nil).UnparsedLines)
+	}
+}
+
+func TestIsUnparsed(t *testing.T) {
+	assert.True(t, isUnparsed([]byte("  1.00M  10%  1.00MB/s  0:00:01 (xfr#1)"), LineProgress, false))
+	assert.False(t, isUnparsed([]byte("  1.00M  10%  1.00MB/s  0:00:01"), LineProgress, true))
+	assert.True(t, isUnparsed([]byte(fileEventPrefix+"garbage"), LineFile, false))
+	assert.False(t, isUnparsed([]byte("photos/100% done.jpg"), LineFile, false))
+	assert.False(t, isUnparsed([]byte("created directory b"), LineInfo, false))
+	assert.False(t, isUnparsed([]byte("[sender] make_file(a,*,0)"), LineDebug, false))
+}
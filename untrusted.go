@@ -0,0 +1,90 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUntrustedOption is returned by ValidateUntrusted for the options untrusted definitions may not set
+var ErrUntrustedOption = errors.New("may not be set")
+
+// ValidateUntrusted rejects definitions which make grsync or the remote side execute other programs
+// than rsync, read or write local files besides the source and the destination or act with other
+// privileges than the current process, e.g. definitions submitted through an API.
+//
+// It rejects a Source or Destination starting with "-", which rsync would parse as an option, and
+// RsyncBinaryPath, Rsh, RemoteShell, RsyncPath, RemoteSudo, RemoteSudoUser, RemoteSudoPasswordFile,
+// ClearEnv, Credential, WorkDir, Limits.Cgroup, CopyAs, PasswordFile, ExcludeFrom, IncludeFrom,
+// FilesFrom, IgnoreFiles, merge rules in Filter, ReadBatch, WriteBatch, OnlyWriteBatch, LogFile
+// and a Priority above the one of the current process. Env may only set the locale and
+// RSYNC_PASSWORD, variables like LD_PRELOAD, PATH or RSYNC_CONNECT_PROG would run other programs.
+// UsePty is allowed, script(1) only runs rsync
+func ValidateUntrusted(definition Definition) error {
+	if strings.HasPrefix(definition.Source, "-") {
+		return untrustedOption("Source starting with -")
+	}
+	if strings.HasPrefix(definition.Destination, "-") {
+		return untrustedOption("Destination starting with -")
+	}
+
+	options := definition.Options
+	for _, o := range []struct {
+		option string
+		set    bool
+	}{
+		{"RsyncBinaryPath", options.RsyncBinaryPath != ""},
+		{"Rsh", options.Rsh != ""},
+		{"RemoteShell", options.RemoteShell.Command != "" || len(options.RemoteShell.Args) > 0},
+		{"RsyncPath", options.RsyncPath != ""},
+		{"RemoteSudo", options.RemoteSudo},
+		{"RemoteSudoUser", options.RemoteSudoUser != ""},
+		{"RemoteSudoPasswordFile", options.RemoteSudoPasswordFile != ""},
+		{"ClearEnv", options.ClearEnv},
+		{"Credential", options.Credential != nil},
+		{"WorkDir", options.WorkDir != ""},
+		{"Limits.Cgroup", options.Limits.Cgroup != ""},
+		{"CopyAs", options.CopyAs != ""},
+		{"PasswordFile", options.PasswordFile != ""},
+		{"ExcludeFrom", options.ExcludeFrom != ""},
+		{"IncludeFrom", options.IncludeFrom != ""},
+		{"FilesFrom", options.FilesFrom != ""},
+		{"IgnoreFiles", len(options.IgnoreFiles) > 0},
+		{"Filter merge rule", mergeFilterRule(options.Filter)},
+		{"ReadBatch", options.ReadBatch != ""},
+		{"WriteBatch", options.WriteBatch != ""},
+		{"OnlyWriteBatch", options.OnlyWriteBatch != ""},
+		{"LogFile", options.LogFile != ""},
+	} {
+		if o.set {
+			return untrustedOption(o.option)
+		}
+	}
+	if p := options.Priority; p.Nice < 0 || p.IOClass != IOClassNone && p.IOClass != IOClassBestEffort && p.IOClass != IOClassIdle {
+		return untrustedOption("Priority above the current one")
+	}
+	for _, variable := range options.Env {
+		if name, _, _ := strings.Cut(variable, "="); !untrustedEnvAllowed(name) {
+			return untrustedOption("Env variable " + name)
+		}
+	}
+	return nil
+}
+
+// mergeFilterRule reports whether the filter rule reads rules from a file, e.g. "merge FILE",
+// ". FILE", "dir-merge FILE" or ":- FILE"
+func mergeFilterRule(rule string) bool {
+	rule = strings.TrimSpace(rule)
+	return strings.HasPrefix(rule, ".") || strings.HasPrefix(rule, ":") ||
+		strings.HasPrefix(rule, "merge") || strings.HasPrefix(rule, "dir-merge")
+}
+
+// untrustedEnvAllowed reports whether untrusted definitions may set the variable name
+func untrustedEnvAllowed(name string) bool {
+	return name == "LANG" || name == "RSYNC_PASSWORD" || strings.HasPrefix(name, "LC_")
+}
+
+// untrustedOption returns the error for an option untrusted definitions may not set
+func untrustedOption(option string) error {
+	return fmt.Errorf("%s %w", option, ErrUntrustedOption)
+}
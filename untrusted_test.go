@@ -0,0 +1,66 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUntrusted(t *testing.T) {
+	assert.Nil(t, ValidateUntrusted(Definition{Source: "/data/", Destination: "host:/backup/", Options: RsyncOptions{
+		Archive:  true,
+		Env:      []string{"RSYNC_PASSWORD=secret", "LANG=C.UTF-8", "LC_ALL=C.UTF-8"},
+		Priority: Priority{Nice: 10, IOClass: IOClassIdle},
+		Limits:   ResourceLimits{OpenFiles: 1024},
+		UsePty:   true,
+	}}))
+
+	for option, options := range map[string]RsyncOptions{
+		"RsyncBinaryPath":                 {RsyncBinaryPath: "/tmp/rsync"},
+		"Rsh":                             {Rsh: "ssh -p 2222"},
+		"RemoteShell":                     {RemoteShell: RemoteShell{Command: "/tmp/evil"}},
+		"ClearEnv":                        {ClearEnv: true},
+		"Env variable LD_PRELOAD":         {Env: []string{"LANG=C", "LD_PRELOAD=/tmp/evil.so"}},
+		"Env variable RSYNC_CONNECT_PROG": {Env: []string{"RSYNC_CONNECT_PROG=/tmp/evil"}},
+		"Env variable PATH":               {Env: []string{"PATH=/tmp"}},
+		"RsyncPath":                       {RsyncPath: "touch /tmp/x; rsync"},
+		"RemoteSudo":                      {RemoteSudo: true},
+		"RemoteSudoUser":                  {RemoteSudoUser: "root"},
+		"CopyAs":                          {CopyAs: "root"},
+		"PasswordFile":                    {PasswordFile: "/etc/shadow"},
+		"ExcludeFrom":                     {ExcludeFrom: "/etc/shadow"},
+		"IncludeFrom":                     {IncludeFrom: "/etc/shadow"},
+		"FilesFrom":                       {FilesFrom: "/etc/shadow"},
+		"IgnoreFiles":                     {IgnoreFiles: []string{"/etc/shadow"}},
+		"Filter merge rule":               {Filter: "merge /etc/shadow"},
+		"ReadBatch":                       {ReadBatch: "/tmp/batch"},
+		"WriteBatch":                      {WriteBatch: "/tmp/batch"},
+		"OnlyWriteBatch":                  {OnlyWriteBatch: "/tmp/batch"},
+		"LogFile":                         {LogFile: "/etc/cron.d/x"},
+	} {
+		err := ValidateUntrusted(Definition{Source: "/data/", Destination: "/backup/", Options: options})
+		assert.ErrorIs(t, err, ErrUntrustedOption, option)
+		assert.EqualError(t, err, option+" may not be set")
+	}
+}
+
+func TestValidateUntrustedPaths(t *testing.T) {
+	err := ValidateUntrusted(Definition{Source: "--rsh=sh -c 'id > /tmp/x' #", Destination: "x:y"})
+	assert.EqualError(t, err, "Source starting with - may not be set")
+	err = ValidateUntrusted(Definition{Source: "/data/", Destination: "-e.sh"})
+	assert.EqualError(t, err, "Destination starting with - may not be set")
+}
+
+func TestMergeFilterRule(t *testing.T) {
+	for _, rule := range []string{"merge /etc/x", ". /etc/x", "dir-merge .rules", ":- .gitignore", "merge,- /etc/x"} {
+		assert.True(t, mergeFilterRule(rule), rule)
+	}
+	for _, rule := range []string{"", "- *.tmp", "+ */", "exclude .git", "P /keep"} {
+		assert.False(t, mergeFilterRule(rule), rule)
+	}
+}
+
+func TestValidateUntrustedIOClass(t *testing.T) {
+	realtime := Definition{Source: "/data/", Destination: "/backup/", Options: RsyncOptions{Priority: Priority{IOClass: 1}}}
+	assert.ErrorIs(t, ValidateUntrusted(realtime), ErrUntrustedOption)
+}
@@ -0,0 +1,100 @@
+package grsync
+
+import (
+	"strings"
+)
+
+// WarningKind classifies a Warning
+type WarningKind string
+
+const (
+	// WarningVanished is a source file deleted while rsync transferred it
+	WarningVanished WarningKind = "vanished"
+	// WarningAttributes is a file transferred without some of its attributes, e.g. rsync failed to
+	// set its times, owner or permissions
+	WarningAttributes WarningKind = "attributes"
+	// WarningFile is a file rsync failed to transfer, delete or stat
+	WarningFile WarningKind = "file"
+	// WarningDeletion is a deletion rsync skipped, a kept non-empty directory or all deletions
+	// after an I/O error
+	WarningDeletion WarningKind = "deletion"
+	// WarningPartial is rsync's closing note that some files or attributes were not transferred
+	WarningPartial WarningKind = "partial"
+	// WarningHook is a failed command hook with HookWarn
+	WarningHook WarningKind = "hook"
+	// WarningOther is any other warning or error rsync printed
+	WarningOther WarningKind = "other"
+)
+
+// Warning is a non-fatal condition of a run, collected separately from the error it failed with
+type Warning struct {
+	Kind WarningKind `json:"kind"`
+	// Path is the affected file, empty if the warning isn't about a single file
+	Path string `json:"path,omitempty"`
+	// Message is the line rsync printed or the failure of the hook
+	Message string `json:"message"`
+}
+
+var (
+	// attributePrefixes start the messages about attributes rsync failed to set, after "rsync: "
+	attributePrefixes = []string{"failed to set ", "chown ", "chgrp ", "set_acl", "rsync_xal_set", "lsetxattr", "failed to modify permissions", "failed to chmod"}
+	// partialPrefixes start the closing notes of runs which didn't transfer everything
+	partialPrefixes = []string{"rsync error: some files/attrs were not transferred", "rsync warning: some files vanished"}
+)
+
+// Warnings returns the warnings of the current or last run
+func (t *Task) Warnings() []Warning {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]Warning(nil), t.warnings...)
+}
+
+// classifyWarning returns the warning of a line rsync printed, fileErr is the file error parsed
+// from it if ok
+func classifyWarning(line string, fileErr FileError, ok bool) Warning {
+	warning := Warning{Kind: WarningOther, Message: line}
+	for _, prefix := range partialPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			warning.Kind = WarningPartial
+			if strings.HasPrefix(line, "rsync warning") {
+				warning.Kind = WarningVanished
+			}
+			return warning
+		}
+	}
+	if line == ioErrorDeletion {
+		warning.Kind = WarningDeletion
+		return warning
+	}
+	if !ok {
+		return warning
+	}
+
+	warning.Path = fileErr.Path
+	message := strings.TrimPrefix(line, "rsync: ")
+	if strings.HasPrefix(message, "[") {
+		if _, rest, found := strings.Cut(message, "] "); found {
+			message = rest
+		}
+	}
+	switch {
+	case fileErr.Reason == vanishedReason:
+		warning.Kind = WarningVanished
+	case strings.HasPrefix(line, nonEmptyDirPrefix):
+		warning.Kind = WarningDeletion
+	case hasAnyPrefix(message, attributePrefixes):
+		warning.Kind = WarningAttributes
+	default:
+		warning.Kind = WarningFile
+	}
+	return warning
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package grsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyWarning(t *testing.T) {
+	for line, expected := range map[string]Warning{
+		`file has vanished: "/src/tmp"`:                                                                      {Kind: WarningVanished, Path: "/src/tmp"},
+		`rsync: failed to set times on "/dst/file": Operation not permitted (1)`:                             {Kind: WarningAttributes, Path: "/dst/file"},
+		`rsync: [receiver] chgrp "/dst/file" failed: Operation not permitted (1)`:                            {Kind: WarningAttributes, Path: "/dst/file"},
+		`rsync: send_files failed to open "/src/secret": Permission denied (13)`:                             {Kind: WarningFile, Path: "/src/secret"},
+		"cannot delete non-empty directory: old/dir":                                                         {Kind: WarningDeletion, Path: "old/dir"},
+		"IO error encountered -- skipping file deletion":                                                     {Kind: WarningDeletion},
+		"rsync error: some files/attrs were not transferred (see previous errors) (code 23) at main.c(1338)": {Kind: WarningPartial},
+		"rsync warning: some files vanished before they could be transferred (code 24) at main.c(1338)":      {Kind: WarningVanished},
+		"rsync: connection unexpectedly closed":                                                              {Kind: WarningOther},
+	} {
+		fileErr, ok := parseFileError(line)
+		expected.Message = line
+		assert.Equal(t, expected, classifyWarning(line, fileErr, ok), line)
+	}
+}
+
+func TestTaskWarnings(t *testing.T) {
+	script := `echo 'rsync: failed to set times on "/dst/a": Operation not permitted (1)' >&2
+echo 'file has vanished: "/src/b"' >&2
+echo 'rsync error: some files/attrs were not transferred (see previous errors) (code 23)' >&2
+exit 23`
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, script)})
+	assert.Nil(t, err)
+
+	result, err := task.RunResult()
+	assert.Equal(t, ExitPartial, ExitCodeOf(err))
+	assert.Equal(t, result.Warnings, task.Warnings())
+
+	var kinds []WarningKind
+	for _, warning := range result.Warnings {
+		kinds = append(kinds, warning.Kind)
+	}
+	assert.Equal(t, []WarningKind{WarningAttributes, WarningVanished, WarningPartial}, kinds)
+	assert.Equal(t, "/dst/a", result.Warnings[0].Path)
+	assert.Equal(t, "/src/b", result.Warnings[1].Path)
+}
@@ -0,0 +1,81 @@
+package grsync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is returned for an attempt the watchdog killed because rsync printed nothing for the
+// stall timeout, see SetStallTimeout
+var ErrStalled = errors.New("rsync stalled")
+
+// watchdogGrace is the time rsync gets beyond its own --timeout or --contimeout to report it
+const watchdogGrace = 5 * time.Second
+
+// SetStallTimeout enables a watchdog which kills rsync when it prints nothing for timeout; the
+// attempt fails with ErrStalled then. rsync's own RsyncOptions.Timeout and Contimeout take
+// precedence: the watchdog waits for the longer of them plus a grace period, so rsync reports
+// ExitTimeout or ExitConnectTimeout before it would kill it
+func (t *Task) SetStallTimeout(timeout time.Duration) {
+	t.mutex.Lock()
+	t.stallTimeout = timeout
+	t.mutex.Unlock()
+}
+
+// watchdogTimeout returns the time without output after which the watchdog kills rsync, 0 if it
+// is disabled
+func watchdogTimeout(timeout time.Duration, options RsyncOptions) time.Duration {
+	if timeout <= 0 {
+		return 0
+	}
+	if own := time.Duration(max(options.Timeout, options.Contimeout)) * time.Second; own > 0 && timeout < own+watchdogGrace {
+		return own + watchdogGrace
+	}
+	return timeout
+}
+
+// activityReader records the time of the last read returning data
+type activityReader struct {
+	r    io.Reader
+	last *atomic.Int64
+}
+
+func (r activityReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.last.Store(time.Now().UnixNano())
+	}
+	return n, err
+}
+
+// watchdog kills rsync started at start when it printed nothing for timeout until done is closed
+// and reports whether it did
+func (t *Task) watchdog(timeout time.Duration, start time.Time, done <-chan struct{}) bool {
+	ticker := time.NewTicker(max(min(timeout/4, time.Second), time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return false
+		case now := <-ticker.C:
+			last := time.Unix(0, t.lastOutput.Load())
+			if last.Before(start) {
+				last = start
+			}
+			if idle := now.Sub(last); idle >= timeout {
+				t.mutex.Lock()
+				_ = t.rsync.Kill()
+				t.mutex.Unlock()
+				return true
+			}
+		}
+	}
+}
+
+// stallError returns the error of an attempt the watchdog killed
+func stallError(timeout time.Duration) error {
+	return fmt.Errorf("%w: no output for %s", ErrStalled, timeout)
+}
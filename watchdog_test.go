@@ -0,0 +1,36 @@
+package grsync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdogTimeout(t *testing.T) {
+	assert.Equal(t, time.Duration(0), watchdogTimeout(0, RsyncOptions{Timeout: 30}))
+	assert.Equal(t, time.Minute, watchdogTimeout(time.Minute, RsyncOptions{}))
+	assert.Equal(t, time.Minute, watchdogTimeout(time.Minute, RsyncOptions{Timeout: 30}))
+	// rsync reports its own timeout first
+	assert.Equal(t, 35*time.Second, watchdogTimeout(10*time.Second, RsyncOptions{Timeout: 30}))
+	assert.Equal(t, 65*time.Second, watchdogTimeout(10*time.Second, RsyncOptions{Timeout: 30, Contimeout: 60}))
+}
+
+func TestTaskStallTimeout(t *testing.T) {
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "echo start\nexec sleep 10")})
+	assert.Nil(t, err)
+	task.SetStallTimeout(200 * time.Millisecond)
+
+	start := time.Now()
+	err = task.Run()
+	assert.True(t, errors.Is(err, ErrStalled))
+	assert.EqualError(t, err, "rsync stalled: no output for 200ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// output keeps the watchdog from firing
+	task, err = NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "for i in 1 2 3 4 5; do echo $i; sleep 0.1; done")})
+	assert.Nil(t, err)
+	task.SetStallTimeout(300 * time.Millisecond)
+	assert.Nil(t, task.Run())
+}
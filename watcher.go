@@ -0,0 +1,275 @@
+package grsync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is the quiet period a Watcher waits for after the last change before syncing
+const DefaultDebounce = 2 * time.Second
+
+// Watcher monitors a local source and runs an incremental rsync whenever it changes.
+// Bursts of changes are debounced into a single run and changes during a run schedule another one
+type Watcher struct {
+	definition Definition
+	debounce   time.Duration
+
+	mutex   sync.Mutex
+	status  WatcherStatus
+	current *Task
+	running bool
+	pending bool
+	stopped bool
+
+	fsWatcher *fsnotify.Watcher
+	polling   *Polling
+	changes   chan string
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// WatcherStatus contains information about the runs of a watcher
+type WatcherStatus struct {
+	Runs         int           `json:"runs"`
+	Failures     int           `json:"failures"`
+	Changes      int           `json:"changes"`
+	Running      bool          `json:"running"`
+	LastChange   time.Time     `json:"lastChange"`
+	LastStart    time.Time     `json:"lastStart"`
+	LastDuration time.Duration `json:"lastDuration"`
+	LastError    error         `json:"-"`
+	// Totals are the counters of the tasks of the runs
+	Totals Totals `json:"totals"`
+}
+
+// NewWatcher returns a watcher syncing the local source of definition whenever it changes.
+// If debounce is zero, DefaultDebounce is used
+func NewWatcher(definition Definition, debounce time.Duration) (*Watcher, error) {
+	if definition.Source == "" {
+		return nil, errors.New("watcher needs a source")
+	}
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	return &Watcher{
+		definition: definition,
+		debounce:   debounce,
+		changes:    make(chan string, 64),
+		stop:       make(chan struct{}),
+	}, nil
+}
+
+// Start starts watching the source recursively and runs an initial sync
+func (w *Watcher) Start() error {
+	root := strings.TrimSuffix(w.definition.Source, string(os.PathSeparator))
+	w.mutex.Lock()
+	polling := w.polling
+	w.mutex.Unlock()
+	if polling != nil {
+		if err := w.startPolling(root, *polling); err != nil {
+			return err
+		}
+	} else {
+		fsWatcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		if err = addRecursive(fsWatcher, root); err != nil {
+			_ = fsWatcher.Close()
+			return err
+		}
+		w.fsWatcher = fsWatcher
+		w.wg.Add(1)
+		go w.watch()
+	}
+
+	w.wg.Add(1)
+	go w.loop()
+
+	w.sync()
+	return nil
+}
+
+// Stop stops watching, cancels a sync in progress and waits for it to finish
+func (w *Watcher) Stop() error {
+	w.mutex.Lock()
+	if w.stopped {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.stopped = true
+	close(w.stop)
+	if w.current != nil {
+		_ = w.current.Cancel()
+	}
+	w.mutex.Unlock()
+
+	var err error
+	if w.fsWatcher != nil {
+		err = w.fsWatcher.Close()
+	}
+	w.wg.Wait()
+	return err
+}
+
+// Status returns information about the runs of the watcher
+func (w *Watcher) Status() WatcherStatus {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.status
+}
+
+// watch forwards file system events and adds newly created directories to the watch list
+func (w *Watcher) watch() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+					_ = addRecursive(w.fsWatcher, event.Name)
+				}
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			w.notify(event.Name)
+		case _, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// notify records a change of path and hands it to the debounce loop
+func (w *Watcher) notify(path string) {
+	select {
+	case w.changes <- path:
+	case <-w.stop:
+	}
+}
+
+// loop waits until changes have settled for the debounce period and starts a sync
+func (w *Watcher) loop() {
+	defer w.wg.Done()
+
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			timer.Stop()
+			return
+		case <-w.changes:
+			w.mutex.Lock()
+			w.status.Changes++
+			w.status.LastChange = time.Now()
+			w.mutex.Unlock()
+
+			timer.Stop()
+			timer.Reset(w.debounce)
+		case <-timer.C:
+			w.sync()
+		}
+	}
+}
+
+// sync starts a run or marks one as pending if a run is already in progress
+func (w *Watcher) sync() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stopped {
+		return
+	}
+	if w.running {
+		w.pending = true
+		return
+	}
+
+	w.running = true
+	w.status.Running = true
+	w.wg.Add(1)
+	go w.run()
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	for {
+		start := time.Now()
+		task, err := w.definition.NewTask()
+
+		w.mutex.Lock()
+		// Stop may have run since the task was created and found no task to cancel
+		if w.stopped {
+			w.pending = false
+			w.running = false
+			w.status.Running = false
+			w.mutex.Unlock()
+			return
+		}
+		w.current = task
+		w.status.LastStart = start
+		w.mutex.Unlock()
+
+		if err == nil {
+			err = task.Run()
+		}
+
+		w.mutex.Lock()
+		w.current = nil
+		w.status.Runs++
+		w.status.LastDuration = time.Since(start)
+		w.status.LastError = err
+		if task != nil {
+			w.status.Totals.merge(task.Totals())
+		}
+		if err != nil {
+			w.status.Failures++
+		}
+
+		if !w.pending || w.stopped {
+			w.pending = false
+			w.running = false
+			w.status.Running = false
+			w.mutex.Unlock()
+			return
+		}
+		w.pending = false
+		w.mutex.Unlock()
+	}
+}
+
+func addRecursive(fsWatcher *fsnotify.Watcher, root string) error {
+	stat, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return fsWatcher.Add(root)
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fsWatcher.Add(path)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package grsync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForWatcher(t *testing.T, w *Watcher, runs int) WatcherStatus {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status := w.Status()
+		if status.Runs >= runs && !status.Running {
+			return status
+		}
+		<-time.After(10 * time.Millisecond)
+	}
+	t.Fatalf("watcher did not reach %d runs", runs)
+	return WatcherStatus{}
+}
+
+func TestWatcher(t *testing.T) {
+	t.Run("syncs on start and on change", func(t *testing.T) {
+		source := t.TempDir()
+		w, err := NewWatcher(Definition{
+			Source:      source + "/",
+			Destination: t.TempDir(),
+			Options:     RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")},
+		}, 50*time.Millisecond)
+		assert.Nil(t, err)
+		assert.Nil(t, w.Start())
+		defer w.Stop()
+
+		waitForWatcher(t, w, 1)
+
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "a"), []byte("a"), 0644))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "b"), []byte("b"), 0644))
+		status := waitForWatcher(t, w, 2)
+		assert.Equal(t, 2, status.Runs)
+		assert.GreaterOrEqual(t, status.Changes, 2)
+		assert.Nil(t, status.LastError)
+	})
+
+	t.Run("watches new directories", func(t *testing.T) {
+		source := t.TempDir()
+		w, err := NewWatcher(Definition{
+			Source:      source,
+			Destination: t.TempDir(),
+			Options:     RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")},
+		}, 50*time.Millisecond)
+		assert.Nil(t, err)
+		assert.Nil(t, w.Start())
+		defer w.Stop()
+
+		sub := filepath.Join(source, "sub")
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(source, "a"), nil, 0644))
+		waitForWatcher(t, w, 2)
+		assert.Nil(t, os.Mkdir(sub, 0755))
+		waitForWatcher(t, w, 3)
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(sub, "b"), nil, 0644))
+		waitForWatcher(t, w, 4)
+	})
+
+	t.Run("no run after stop", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "ran")
+		w, err := NewWatcher(Definition{
+			Source:      t.TempDir() + "/",
+			Destination: t.TempDir(),
+			Options:     RsyncOptions{RsyncBinaryPath: fakeRsync(t, "touch "+marker)},
+		}, 0)
+		assert.Nil(t, err)
+
+		// Stop ran between the creation of the task and its assignment to current
+		w.stopped, w.running = true, true
+		w.wg.Add(1)
+		w.run()
+		assert.NoFileExists(t, marker)
+		assert.Equal(t, 0, w.Status().Runs)
+		assert.False(t, w.running)
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		w, err := NewWatcher(Definition{Source: filepath.Join(t.TempDir(), "missing")}, 0)
+		assert.Nil(t, err)
+		assert.NotNil(t, w.Start())
+
+		_, err = NewWatcher(Definition{}, 0)
+		assert.NotNil(t, err)
+	})
+}
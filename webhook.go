@@ -0,0 +1,140 @@
+package grsync
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookSignatureHeader carries the hex encoded HMAC-SHA256 of the request body if Webhook.Secret is set
+const WebhookSignatureHeader = "X-Grsync-Signature"
+
+// Notification is the JSON summary of a finished run posted by a Webhook
+type Notification struct {
+	TaskID      string     `json:"taskId"`
+	Result      TaskStatus `json:"result"`
+	Source      string     `json:"source"`
+	Destination string     `json:"destination"`
+	Attempts    int        `json:"attempts"`
+	State       State      `json:"state"`
+	Error       string     `json:"error,omitempty"`
+	FinishedAt  time.Time  `json:"finishedAt"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Webhook posts a Notification to every URL when a task finished. Requests answered with an
+// error or a status other than 2xx are retried
+type Webhook struct {
+	URLs []string
+	// Secret signs the body, the signature is sent in WebhookSignatureHeader as "sha256=<hex>"
+	Secret string
+	// MaxAttempts per URL; values below 1 mean a single attempt
+	MaxAttempts int
+	// Delay between attempts
+	Delay time.Duration
+	// Client used for the requests, http.DefaultClient if nil
+	Client *http.Client
+	// OnlyFailures skips notifications of successful runs
+	OnlyFailures bool
+}
+
+// Hooks returns hooks posting the notification of a task after Run finished. They block until
+// every URL was notified, errors are passed to onError if it is non-nil
+func (w *Webhook) Hooks(onError func(err error)) Hooks {
+	notify := func(task *Task, err error) {
+		if err == nil && w.OnlyFailures {
+			return
+		}
+		if notifyErr := w.Notify(NewNotification(task, err)); notifyErr != nil && onError != nil {
+			onError(notifyErr)
+		}
+	}
+	return Hooks{
+		OnComplete: func(task *Task) { notify(task, nil) },
+		OnError:    notify,
+	}
+}
+
+// NewNotification summarises a task whose run returned err
+func NewNotification(task *Task, err error) Notification {
+	definition := task.Definition()
+	n := Notification{
+		TaskID:      task.ID(),
+		Result:      task.Status(),
+		Source:      definition.Source,
+		Destination: definition.Destination,
+		Attempts:    task.Attempts(),
+		State:       task.State(),
+		FinishedAt:  time.Now(),
+		Labels:      definition.Labels,
+	}
+	if err != nil {
+		n.Error = task.Redact(err.Error())
+	}
+	return n
+}
+
+// Notify posts n to all URLs and returns the first error
+func (w *Webhook) Notify(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, url := range w.URLs {
+		if err := w.post(url, body); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (w *Webhook) post(url string, body []byte) error {
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = w.send(client, url, body); err == nil || attempt >= w.MaxAttempts {
+			return err
+		}
+		time.Sleep(w.Delay)
+	}
+}
+
+func (w *Webhook) send(client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+Sign(w.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: unexpected status %s", url, resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body, as sent in WebhookSignatureHeader
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,76 @@
+package grsync
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook(t *testing.T) {
+	var (
+		mutex     sync.Mutex
+		requests  int
+		received  Notification
+		signature string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+		assert.Equal(t, "sha256="+Sign("secret", body), signature)
+		assert.Nil(t, json.Unmarshal(body, &received))
+	}))
+	defer server.Close()
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 23")})
+	assert.Nil(t, err)
+	task.SetID("backup")
+
+	webhook := &Webhook{URLs: []string{server.URL}, Secret: "secret", MaxAttempts: 2}
+	task.AddHooks(webhook.Hooks(func(err error) { t.Error(err) }))
+	assert.NotNil(t, task.Run())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 2, requests)
+	assert.NotEmpty(t, signature)
+	assert.Equal(t, "backup", received.TaskID)
+	assert.Equal(t, TaskFailed, received.Result)
+	assert.Equal(t, "a", received.Source)
+	assert.Equal(t, 1, received.Attempts)
+	assert.Contains(t, received.Error, "exit status 23")
+}
+
+func TestWebhookErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	task, err := NewTask("a", "b", false, false, RsyncOptions{RsyncBinaryPath: fakeRsync(t, "exit 0")})
+	assert.Nil(t, err)
+
+	var errs []error
+	webhook := &Webhook{URLs: []string{server.URL}}
+	task.AddHooks(webhook.Hooks(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, task.Run())
+	assert.Len(t, errs, 1)
+
+	// successful runs are not reported with OnlyFailures
+	errs = nil
+	webhook.OnlyFailures = true
+	assert.Nil(t, task.Run())
+	assert.Empty(t, errs)
+}
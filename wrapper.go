@@ -0,0 +1,67 @@
+package grsync
+
+import (
+	"strings"
+)
+
+// WrapperRunner is a CommandRunner prefixing the commands of tasks with Wrapper, e.g.
+// `kubectl exec -i mypod --` to run rsync in a pod. Env and the working directory apply to the
+// wrapper, which may or may not pass them on
+type WrapperRunner struct {
+	Wrapper []string
+	// Runner runs the wrapper, by default ExecRunner
+	Runner CommandRunner
+}
+
+// NewProcess returns a process running cmd through the wrapper
+func (r WrapperRunner) NewProcess(cmd Command) Process {
+	runner := r.Runner
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	if len(r.Wrapper) == 0 {
+		return runner.NewProcess(cmd)
+	}
+	args := append(append(append([]string(nil), r.Wrapper[1:]...), cmd.Name), cmd.Args...)
+	return runner.NewProcess(Command{Name: r.Wrapper[0], Args: args, Env: cmd.Env, Dir: cmd.Dir})
+}
+
+// HostPlaceholder stands for the host of the remote path in the command of WrapperRsh
+const HostPlaceholder = "{host}"
+
+// WrapperRsh returns a remote shell for RsyncOptions.Rsh that starts the remote rsync with command,
+// where HostPlaceholder is replaced by the host of the remote path and the rsync command line is
+// appended. E.g. `kubectl exec -i {host} --` reaches pods named like "mypod:/data/". Remote paths
+// must not contain a user, which rsync would pass with -l
+func WrapperRsh(command ...string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		if arg == HostPlaceholder {
+			quoted[i] = `"$0"`
+		} else {
+			quoted[i] = rshQuote(arg)
+		}
+	}
+	// rsync appends the host and the command, which the script receives as $0 and $@
+	script := "exec " + strings.Join(quoted, " ") + ` "$@"`
+	return RemoteShell{Command: "sh", Args: []string{"-c", script}}.String()
+}
+
+// rshQuote double quotes s for the script of WrapperRsh
+func rshQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(s) + `"`
+}
+
+// KubectlRsh returns a remote shell for RsyncOptions.Rsh that reaches pods named as the host of a
+// path, e.g. "mypod:/data/", with `kubectl exec`. namespace and container are optional
+func KubectlRsh(namespace, container string) string {
+	command := []string{"kubectl"}
+	if namespace != "" {
+		command = append(command, "--namespace", namespace)
+	}
+	command = append(command, "exec", "-i")
+	if container != "" {
+		command = append(command, "--container", container)
+	}
+	return WrapperRsh(append(command, HostPlaceholder, "--")...)
+}
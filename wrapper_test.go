@@ -0,0 +1,28 @@
+package grsync
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapperRunner(t *testing.T) {
+	runner := &cannedRunner{}
+	task, err := NewTask("/data/", "/backup/", false, false, RsyncOptions{})
+	assert.Nil(t, err)
+	task.SetRunner(WrapperRunner{Wrapper: []string{"kubectl", "exec", "-i", "mypod", "--"}, Runner: runner})
+	assert.Nil(t, task.Run())
+	assert.Equal(t, []string{"kubectl", "exec", "-i", "mypod", "--", "rsync"}, runner.args[:6])
+	assert.Equal(t, []string{"/data/", "/backup/"}, runner.args[len(runner.args)-2:])
+}
+
+func TestWrapperRsh(t *testing.T) {
+	assert.Equal(t, `sh -c 'exec "kubectl" "--namespace" "prod" "exec" "-i" "$0" "--" "$@"'`, KubectlRsh("prod", ""))
+
+	// a shell splits the remote shell like rsync and appends the host and the command
+	rsh := WrapperRsh("echo", "it's", `$HOME`, HostPlaceholder, "--")
+	out, err := exec.Command("sh", "-c", rsh+" mypod rsync --server '.'").Output()
+	assert.Nil(t, err)
+	assert.Equal(t, "it's $HOME mypod -- rsync --server .\n", string(out))
+}